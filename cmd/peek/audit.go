@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+func runAuditCommand(args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	configPath := fs.String("config", "~/.peek/config.toml", "Path to config file")
+	dbPath := fs.String("db-path", "", "Database path (overrides config)")
+	since := fs.String("since", "", "Only show queries run within this duration (e.g., 1h, 7d)")
+	limit := fs.Int("limit", 50, "Maximum number of queries to print (0 for all)")
+	output := fs.String("output", outputText, "Output format: text or json (one query per line)")
+	fs.Parse(args)
+
+	if err := validateNoPositionalArgs(fs.Args()); err != nil {
+		return err
+	}
+	if err := checkTextOrJSON(*output); err != nil {
+		return err
+	}
+	var from time.Time
+	if *since != "" {
+		d, err := parseDuration(*since)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid --since %q", *since)
+		}
+		from = time.Now().Add(-d)
+	}
+
+	db, err := openCatalogStorage(*configPath, *dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	records, err := db.GetAudit(from, *limit)
+	if err != nil {
+		return err
+	}
+	return printAudit(os.Stdout, records, *output)
+}
+
+// printAudit writes audit records, most recent first.
+func printAudit(w io.Writer, records []storage.AuditRecord, output string) error {
+	if output == outputJSON {
+		for _, r := range records {
+			if err := writeJSONLine(w, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tENDPOINT\tCLIENT\tDURATION\tRESULTS\tRANGE\tQUERY")
+	for _, r := range records {
+		client := r.Client
+		if r.Namespace != "" {
+			client += " (" + r.Namespace + ")"
+		}
+		results := fmt.Sprint(r.Results)
+		if r.Error != "" {
+			results = "error: " + r.Error
+		}
+		rng := "-"
+		if r.Start != "" || r.End != "" {
+			rng = r.Start + ".." + r.End
+		}
+		query := r.Query
+		if query == "" {
+			query = "*"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%dms\t%s\t%s\t%s\n", r.Time.Local().Format(time.DateTime),
+			r.Endpoint, client, r.DurationMS, results, rng, query)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mchurichi/peek/internal/config"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+func TestPrintAudit(t *testing.T) {
+	ts := time.Date(2026, 3, 10, 15, 30, 0, 0, time.UTC)
+	records := []storage.AuditRecord{
+		{Time: ts, Endpoint: "query", Query: "level:ERROR", Start: "2026-03-10T14:00:00Z", DurationMS: 12, Results: 40, Client: "10.0.0.7", Namespace: "alice"},
+		{Time: ts.Add(-time.Minute), Endpoint: "live", DurationMS: 3, Client: "127.0.0.1", Error: "context canceled"},
+	}
+
+	var out bytes.Buffer
+	if err := printAudit(&out, records, outputJSON); err != nil {
+		t.Fatalf("printAudit(json) error = %v", err)
+	}
+	want := `{"time":"2026-03-10T15:30:00Z","endpoint":"query","query":"level:ERROR","start":"2026-03-10T14:00:00Z","duration_ms":12,"results":40,"client":"10.0.0.7","namespace":"alice"}` + "\n"
+	if first, _, _ := strings.Cut(out.String(), "\n"); first+"\n" != want {
+		t.Fatalf("printAudit(json) first line = %q, want %q", first, want)
+	}
+
+	out.Reset()
+	if err := printAudit(&out, records, outputText); err != nil {
+		t.Fatalf("printAudit(text) error = %v", err)
+	}
+	text := out.String()
+	for _, want := range []string{"ENDPOINT", "10.0.0.7 (alice)", "12ms", "2026-03-10T14:00:00Z..", "level:ERROR", "error: context canceled"} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("printAudit(text) = %q, want %q", text, want)
+		}
+	}
+}
+
+func TestNewAuditRetention(t *testing.T) {
+	tests := []struct {
+		name    string
+		audit   config.AuditConfig
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "default", audit: config.DefaultConfig().Audit, want: 7 * 24 * time.Hour},
+		{name: "disabled", audit: config.AuditConfig{Retention: "7d"}, want: 0},
+		{name: "hours", audit: config.AuditConfig{Enabled: true, Retention: "12h"}, want: 12 * time.Hour},
+		{name: "invalid", audit: config.AuditConfig{Enabled: true, Retention: "soon"}, wantErr: true},
+		{name: "zero", audit: config.AuditConfig{Enabled: true, Retention: "0s"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newAuditRetention(tt.audit)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Fatalf("newAuditRetention() = %v, %v, want %v (error %v)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/mchurichi/peek/internal/config"
+	"github.com/mchurichi/peek/pkg/mdns"
+)
+
+// openUI points the user at the web UI: it prints url with --print-url-only,
+// and otherwise opens a browser when server.auto_open_browser is set. The
+// platform's default opener is skipped in SSH and display-less sessions,
+// where it would fail or open a browser on the wrong machine; a configured
+// server.browser_command always runs.
+func openUI(s config.ServerConfig, url string) {
+	switch {
+	case s.PrintURLOnly:
+		fmt.Println(url)
+	case !s.AutoOpenBrowser:
+	case s.BrowserCommand != "":
+		go runBrowserCommand(s.BrowserCommand, url)
+	default:
+		if reason := headlessSession(); reason != "" {
+			log.Printf("Not opening a browser (%s). Open: %s", reason, url)
+			return
+		}
+		go openBrowser(url)
+	}
+}
+
+// advertiseUI announces the web UI on the local network over mDNS when
+// server.mdns is set, and returns a func that withdraws it. A failure to
+// advertise is logged; the UI keeps running.
+func advertiseUI(s config.ServerConfig) func() {
+	if !s.MDNS {
+		return func() {}
+	}
+	hostname, _ := os.Hostname()
+	svc := mdns.NewService(hostname, s.Port)
+	r, err := mdns.Start(svc)
+	if err != nil {
+		log.Printf("Warning: mDNS advertisement failed: %v", err)
+		return func() {}
+	}
+	log.Printf("Advertising the web UI on the local network as http://%s:%d", strings.TrimSuffix(svc.HostName(), "."), s.Port)
+	return func() { r.Close() }
+}
+
+// headlessSession returns why a browser can't be opened on this machine's
+// display, or "" when it can.
+func headlessSession() string {
+	for _, v := range []string{"SSH_CONNECTION", "SSH_CLIENT", "SSH_TTY"} {
+		if os.Getenv(v) != "" {
+			return "SSH session"
+		}
+	}
+	switch runtime.GOOS {
+	case "darwin", "windows":
+		return ""
+	}
+	if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+		return "no display"
+	}
+	return ""
+}
+
+// runBrowserCommand runs a server.browser_command template. Every {url} in
+// it is replaced by url; without a placeholder, url is the last argument.
+func runBrowserCommand(template, url string) {
+	args, err := browserCommandArgs(template, url)
+	if err != nil {
+		log.Printf("Invalid browser_command: %v. Please open: %s", err, url)
+		return
+	}
+	if err := exec.Command(args[0], args[1:]...).Start(); err != nil {
+		log.Printf("Failed to open browser: %v. Please open: %s", err, url)
+	}
+}
+
+// browserCommandArgs splits template into arguments like a shell would for
+// plain words and single- or double-quoted strings, then fills in url. The
+// command runs directly, not through a shell.
+func browserCommandArgs(template, url string) ([]string, error) {
+	var (
+		args   []string
+		cur    strings.Builder
+		inWord bool
+		quote  rune
+		placed bool
+	)
+	for _, r := range template {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			cur.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				args = append(args, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		args = append(args, cur.String())
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	for i, arg := range args {
+		if strings.Contains(arg, "{url}") {
+			args[i] = strings.ReplaceAll(arg, "{url}", url)
+			placed = true
+		}
+	}
+	if !placed {
+		args = append(args, url)
+	}
+	return args, nil
+}
+
+// openBrowser opens url with the platform's default opener.
+func openBrowser(url string) {
+	var cmd *exec.Cmd
+
+	switch runtime.GOOS {
+	case "linux":
+		cmd = exec.Command("xdg-open", url)
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		log.Printf("Cannot auto-open browser on %s. Please open: %s", runtime.GOOS, url)
+		return
+	}
+
+	if err := cmd.Start(); err != nil {
+		log.Printf("Failed to open browser: %v. Please open: %s", err, url)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/mchurichi/peek/internal/config"
+)
+
+func TestBrowserCommandArgs(t *testing.T) {
+	const url = "http://localhost:8080"
+	tests := []struct {
+		name     string
+		template string
+		want     []string
+		wantErr  bool
+	}{
+		{name: "placeholder", template: "firefox --new-window {url}", want: []string{"firefox", "--new-window", url}},
+		{name: "no placeholder appends", template: "chromium", want: []string{"chromium", url}},
+		{name: "placeholder inside argument", template: "open --args --app={url}", want: []string{"open", "--args", "--app=" + url}},
+		{name: "quoted words", template: `open -a 'Google Chrome' "{url}"`, want: []string{"open", "-a", "Google Chrome", url}},
+		{name: "extra whitespace", template: "  w3m\t {url} ", want: []string{"w3m", url}},
+		{name: "empty quotes are an argument", template: `cmd "" {url}`, want: []string{"cmd", "", url}},
+		{name: "unterminated quote", template: `open -a "Google Chrome`, wantErr: true},
+		{name: "empty", template: "   ", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := browserCommandArgs(tt.template, url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("browserCommandArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("browserCommandArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeadlessSession(t *testing.T) {
+	for _, v := range []string{"SSH_CONNECTION", "SSH_CLIENT", "SSH_TTY"} {
+		t.Setenv(v, "")
+	}
+	t.Setenv("DISPLAY", ":0")
+	if got := headlessSession(); got != "" {
+		t.Fatalf("headlessSession() with a display = %q, want \"\"", got)
+	}
+
+	t.Setenv("DISPLAY", "")
+	t.Setenv("WAYLAND_DISPLAY", "")
+	want := "no display"
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		want = ""
+	}
+	if got := headlessSession(); got != want {
+		t.Fatalf("headlessSession() without a display = %q, want %q", got, want)
+	}
+
+	t.Setenv("DISPLAY", ":0")
+	t.Setenv("SSH_CONNECTION", "10.0.0.1 52144 10.0.0.2 22")
+	if got := headlessSession(); got != "SSH session" {
+		t.Fatalf("headlessSession() over SSH = %q, want \"SSH session\"", got)
+	}
+}
+
+func TestOpenUIPrintURLOnly(t *testing.T) {
+	s := config.ServerConfig{AutoOpenBrowser: true, BrowserCommand: "false", PrintURLOnly: true}
+	out := captureStdout(t, func() { openUI(s, "http://localhost:8080") })
+	if out != "http://localhost:8080\n" {
+		t.Fatalf("openUI() printed %q, want the URL on its own line", out)
+	}
+
+	s.PrintURLOnly, s.AutoOpenBrowser = false, false
+	if out := captureStdout(t, func() { openUI(s, "http://localhost:8080") }); out != "" {
+		t.Fatalf("openUI() with auto_open_browser off printed %q", out)
+	}
+}
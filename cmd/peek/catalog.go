@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mchurichi/peek/internal/config"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+func runFieldsCommand(args []string) error {
+	fs := flag.NewFlagSet("fields", flag.ExitOnError)
+	configPath := fs.String("config", "~/.peek/config.toml", "Path to config file")
+	dbPath := fs.String("db-path", "", "Database path (overrides config)")
+	output := fs.String("output", outputText, "Output format: text or json (one field per line)")
+	fs.Parse(args)
+
+	if err := validateNoPositionalArgs(fs.Args()); err != nil {
+		return err
+	}
+	if err := checkTextOrJSON(*output); err != nil {
+		return err
+	}
+
+	db, err := openCatalogStorage(*configPath, *dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	fields, err := db.GetFields(time.Time{}, time.Time{})
+	if err != nil {
+		return fmt.Errorf("failed to get fields: %w", err)
+	}
+	return printFields(os.Stdout, fields, *output)
+}
+
+// printFields writes the field catalog sorted by name.
+func printFields(w io.Writer, fields []storage.FieldInfo, output string) error {
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+
+	if output == outputJSON {
+		for _, f := range fields {
+			if err := writeJSONLine(w, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTYPE\tDISTINCT\tTOP VALUES")
+	for _, f := range fields {
+		distinct := fmt.Sprint(f.Cardinality)
+		top := strings.Join(f.TopValues, ", ")
+		if f.HighCardinality {
+			distinct = "~" + distinct
+			top = "(high cardinality)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.Name, f.Type, distinct, top)
+	}
+	return tw.Flush()
+}
+
+func runSessionsCommand(args []string) error {
+	fs := flag.NewFlagSet("sessions", flag.ExitOnError)
+	configPath := fs.String("config", "~/.peek/config.toml", "Path to config file")
+	dbPath := fs.String("db-path", "", "Database path (overrides config)")
+	output := fs.String("output", outputText, "Output format: text or json (one session per line)")
+	fs.Parse(args)
+
+	if err := validateNoPositionalArgs(fs.Args()); err != nil {
+		return err
+	}
+	if err := checkTextOrJSON(*output); err != nil {
+		return err
+	}
+
+	db, err := openCatalogStorage(*configPath, *dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	sessions, err := db.GetSessions(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to get sessions: %w", err)
+	}
+	return printSessions(os.Stdout, sessions, *output)
+}
+
+// printSessions writes collect sessions, most recently active first.
+func printSessions(w io.Writer, sessions []storage.SessionInfo, output string) error {
+	if output == outputJSON {
+		for _, s := range sessions {
+			if err := writeJSONLine(w, s); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SESSION\tNAME\tENTRIES\tFIRST\tLAST")
+	for _, s := range sessions {
+		name := s.Name
+		if name == "" {
+			name = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", s.Session, name, s.Count,
+			s.First.Local().Format(time.DateTime), s.Last.Local().Format(time.DateTime))
+	}
+	return tw.Flush()
+}
+
+// openCatalogStorage loads the config and opens the database for a read command.
+func openCatalogStorage(configPath, dbPath string) (*storage.BadgerStorage, error) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if dbPath != "" {
+		cfg.Storage.DBPath = dbPath
+	}
+
+	storageCfg, err := newStorageConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := storage.NewBadgerStorage(storageCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	return db, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+func TestPrintFields(t *testing.T) {
+	fields := []storage.FieldInfo{
+		{Name: "service", Type: "string", TopValues: []string{"api", "web"}, Cardinality: 2},
+		{Name: "request_id", Type: "string", TopValues: []string{}, Cardinality: 4821, HighCardinality: true},
+	}
+
+	var out bytes.Buffer
+	if err := printFields(&out, fields, outputText); err != nil {
+		t.Fatalf("printFields(text) error = %v", err)
+	}
+	text := out.String()
+	if !strings.HasPrefix(text, "NAME") || !strings.Contains(text, "~4821") || !strings.Contains(text, "api, web") {
+		t.Fatalf("printFields(text) = %q", text)
+	}
+	if strings.Index(text, "request_id") > strings.Index(text, "service") {
+		t.Fatalf("printFields(text) = %q, want fields sorted by name", text)
+	}
+
+	out.Reset()
+	if err := printFields(&out, fields, outputJSON); err != nil {
+		t.Fatalf("printFields(json) error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("printFields(json) = %q, want one line per field", out.String())
+	}
+	var f storage.FieldInfo
+	if err := json.Unmarshal([]byte(lines[0]), &f); err != nil || f.Name != "request_id" || !f.HighCardinality {
+		t.Fatalf("printFields(json) first line = %s (%v)", lines[0], err)
+	}
+}
+
+func TestPrintSessions(t *testing.T) {
+	ts := time.Date(2026, 3, 10, 15, 30, 0, 0, time.UTC)
+	sessions := []storage.SessionInfo{{Session: "run-b", Count: 3, First: ts, Last: ts.Add(time.Minute)}}
+
+	var out bytes.Buffer
+	if err := printSessions(&out, sessions, outputJSON); err != nil {
+		t.Fatalf("printSessions(json) error = %v", err)
+	}
+	want := `{"session":"run-b","count":3,"first":"2026-03-10T15:30:00Z","last":"2026-03-10T15:31:00Z"}` + "\n"
+	if out.String() != want {
+		t.Fatalf("printSessions(json) = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	sessions = append(sessions, storage.SessionInfo{Session: "run-a", Name: "kubectl logs api-7f9c -f", Count: 1, First: ts, Last: ts})
+	if err := printSessions(&out, sessions, outputText); err != nil {
+		t.Fatalf("printSessions(text) error = %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "SESSION  NAME") || !strings.Contains(out.String(), "run-b") || !strings.Contains(out.String(), "kubectl logs api-7f9c -f  1") {
+		t.Fatalf("printSessions(text) = %q", out.String())
+	}
+}
+
+func TestStatsReportJSON(t *testing.T) {
+	ts := time.Date(2026, 3, 10, 15, 30, 0, 0, time.UTC)
+	data, err := json.Marshal(statsReport{Path: "/db", Oldest: &ts, Newest: &ts, Stats: storage.Stats{TotalLogs: 7}})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	for _, key := range []string{`"path":"/db"`, `"oldest":"2026-03-10T15:30:00Z"`, `"total_logs":7`} {
+		if !strings.Contains(string(data), key) {
+			t.Fatalf("statsReport JSON = %s, want %s at the top level", data, key)
+		}
+	}
+	if strings.Contains(string(data), "digest") {
+		t.Fatalf("statsReport JSON = %s, want digest omitted without --digest", data)
+	}
+}
+
+func TestReadCommandsOutputValidation(t *testing.T) {
+	dbPath := t.TempDir()
+	tests := []struct {
+		name    string
+		run     func() error
+		wantErr bool
+	}{
+		{name: "fields json", run: func() error { return runFieldsCommand([]string{"--db-path", dbPath, "--output", "json"}) }},
+		{name: "sessions text", run: func() error { return runSessionsCommand([]string{"--db-path", dbPath}) }},
+		{name: "stats json", run: func() error { return runDbStats([]string{"--db-path", dbPath, "--output", "json", "--digest"}) }},
+		{name: "fields bad output", run: func() error { return runFieldsCommand([]string{"--db-path", dbPath, "--output", "yaml"}) }, wantErr: true},
+		{name: "stats bad output", run: func() error { return runDbStats([]string{"--db-path", dbPath, "--output", "pretty"}) }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
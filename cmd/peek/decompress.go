@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Magic bytes that start gzip and zstd streams.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// decompressReader reads a stream that may be gzip or zstd compressed,
+// decompressing it when it starts with either's magic bytes and passing it
+// through otherwise. Detection happens on the first Read, so building one
+// never blocks, and only waits for as many bytes as the magic needs, so a
+// live plain-text pipe isn't held back. Concatenated gzip members and zstd
+// frames read as one stream. Decoding is synchronous, so there are no
+// decoder goroutines to stop when reading ends.
+type decompressReader struct {
+	src      *bufio.Reader
+	r        io.Reader         // nil until the first Read
+	name     string            // compression detected, "" for none
+	detected func(name string) // called once a compression is detected; may be nil
+}
+
+func newDecompressReader(r io.Reader) *decompressReader {
+	return &decompressReader{src: bufio.NewReader(r)}
+}
+
+func (d *decompressReader) Read(p []byte) (int, error) {
+	if d.r == nil {
+		if err := d.detect(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := d.r.Read(p)
+	if err != nil && d.name != "" && !errors.Is(err, io.EOF) {
+		// A truncated archive reads as such rather than as a bare
+		// "unexpected EOF".
+		err = fmt.Errorf("%s: %w", d.name, err)
+	}
+	return n, err
+}
+
+// detect peeks at the magic bytes and sets up the decoder.
+func (d *decompressReader) detect() error {
+	head, err := d.src.Peek(len(gzipMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	switch {
+	case bytes.Equal(head, gzipMagic):
+		zr, err := gzip.NewReader(d.src)
+		if err != nil {
+			return fmt.Errorf("gzip: %w", err)
+		}
+		d.r, d.name = zr, "gzip"
+	case len(head) == len(gzipMagic) && bytes.HasPrefix(zstdMagic, head):
+		if head, err = d.src.Peek(len(zstdMagic)); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if !bytes.Equal(head, zstdMagic) {
+			d.r = d.src
+			return nil
+		}
+		// Without concurrency the decoder reads only as output is
+		// wanted, instead of reading ahead of a live pipe.
+		zr, err := zstd.NewReader(d.src, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return fmt.Errorf("zstd: %w", err)
+		}
+		d.r, d.name = zr, "zstd"
+	default:
+		d.r = d.src
+		return nil
+	}
+	if d.detected != nil {
+		d.detected(d.name)
+	}
+	return nil
+}
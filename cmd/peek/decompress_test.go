@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestDecompressReader(t *testing.T) {
+	text := "level=info msg=one\nlevel=error msg=two\n"
+	gz := func(parts ...string) []byte {
+		var buf bytes.Buffer
+		for _, p := range parts {
+			w := gzip.NewWriter(&buf)
+			w.Write([]byte(p))
+			w.Close()
+		}
+		return buf.Bytes()
+	}
+	zst := func(s string) []byte {
+		var buf bytes.Buffer
+		w, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatalf("zstd.NewWriter() error = %v", err)
+		}
+		w.Write([]byte(s))
+		w.Close()
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name    string
+		in      []byte
+		want    string
+		wantAs  string
+		wantErr string
+	}{
+		{name: "plain", in: []byte(text), want: text},
+		{name: "gzip", in: gz(text), want: text, wantAs: "gzip"},
+		{name: "concatenated gzip", in: gz("level=info msg=one\n", "level=error msg=two\n"), want: text, wantAs: "gzip"},
+		{name: "zstd", in: zst(text), want: text, wantAs: "zstd"},
+		{name: "starts like zstd", in: []byte("(\xb5 not zstd\n"), want: "(\xb5 not zstd\n"},
+		{name: "one byte", in: []byte("x"), want: "x"},
+		{name: "empty", in: nil, want: ""},
+		{name: "truncated gzip", in: gz(text)[:20], wantAs: "gzip", wantErr: "gzip: unexpected EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newDecompressReader(bytes.NewReader(tt.in))
+			var detected string
+			r.detected = func(name string) { detected = name }
+			got, err := io.ReadAll(r)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ReadAll() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if tt.wantErr == "" && string(got) != tt.want {
+				t.Fatalf("read %q, want %q", got, tt.want)
+			}
+			if detected != tt.wantAs {
+				t.Fatalf("detected %q, want %q", detected, tt.wantAs)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mchurichi/peek/internal/config"
+)
+
+func runDemoCommand(args []string) error {
+	fs := flag.NewFlagSet("demo", flag.ExitOnError)
+	port := fs.Int("port", 0, "HTTP server port")
+	noBrowser := fs.Bool("no-browser", false, "Don't auto-open browser")
+	printURLOnly := fs.Bool("print-url-only", false, "Print the web UI URL instead of opening a browser")
+	rate := fs.Float64("rate", 20, "Live lines per second after the backfill (0 stops after the backfill)")
+	backfill := fs.Int("backfill", 2000, "Lines spread over the last hour before streaming starts")
+	seed := fs.Int64("seed", 0, "Seed for a repeatable stream (default: random)")
+	fs.Parse(args)
+
+	if err := validateNoPositionalArgs(fs.Args()); err != nil {
+		return err
+	}
+	if *rate < 0 || *backfill < 0 {
+		return fmt.Errorf("--rate and --backfill must not be negative")
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
+	// The demo never touches the real database: it writes to a temporary
+	// one that is removed on exit.
+	dir, err := os.MkdirTemp("", "peek-demo-")
+	if err != nil {
+		return fmt.Errorf("failed to create demo database: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := config.DefaultConfig()
+	cfg.Storage.DBPath = dir
+	cfg.Storage.Badger.SyncWrites = false
+	if *port > 0 {
+		cfg.Server.Port = *port
+	}
+	if *noBrowser {
+		cfg.Server.AutoOpenBrowser = false
+	}
+	cfg.Server.PrintURLOnly = *printURLOnly
+
+	return collect(cfg, true, "peek demo", nil, func(c *collector) error {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		c.input = "demo"
+
+		log.Printf("Generating demo logs into %s — press Ctrl+C to exit", dir)
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(streamDemo(ctx, pw, newDemoGenerator(*seed), *backfill, *rate, time.Now))
+		}()
+		err := c.readFrom(ctx, pr)
+		c.finish()
+		if err != nil {
+			return fmt.Errorf("demo stream failed: %w", err)
+		}
+		if *rate == 0 {
+			// Keep serving the backfill until Ctrl+C.
+			<-ctx.Done()
+		}
+		log.Println("Shutting down...")
+		return nil
+	})
+}
+
+// streamDemo writes backfill lines with timestamps spread evenly over the
+// hour before now, then one fresh line every 1/rate seconds until ctx is
+// done. With rate 0 it returns after the backfill.
+func streamDemo(ctx context.Context, w io.Writer, g *demoGenerator, backfill int, rate float64, now func() time.Time) error {
+	start := now()
+	for i := range backfill {
+		ts := start.Add(-time.Hour + time.Duration(i)*time.Hour/time.Duration(backfill))
+		if _, err := io.WriteString(w, g.line(ts)+"\n"); err != nil {
+			return err
+		}
+	}
+	if rate <= 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if _, err := io.WriteString(w, g.line(now())+"\n"); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// demoLevels is the level mix of service logs, as cumulative percentages.
+var demoLevels = []struct {
+	level string
+	upTo  int
+}{
+	{"DEBUG", 15},
+	{"INFO", 75},
+	{"WARN", 89},
+	{"ERROR", 99},
+	{"FATAL", 100},
+}
+
+var (
+	demoServices = []string{"api", "auth", "billing", "worker", "search"}
+	demoPaths    = []string{"/api/orders", "/api/orders/1042", "/api/users/me", "/api/search", "/login", "/healthz"}
+	demoMethods  = []string{"GET", "GET", "GET", "POST", "PUT", "DELETE"}
+	demoMessages = map[string][]string{
+		"DEBUG": {"cache lookup", "loaded feature flags", "retrying upstream call", "query plan selected"},
+		"INFO":  {"request completed", "user signed in", "order created", "job finished", "index refreshed"},
+		"WARN":  {"slow query", "retry budget low", "deprecated API version used", "queue depth above threshold"},
+		"ERROR": {"payment declined by provider", "database connection reset", "upstream timeout", "failed to publish event"},
+		"FATAL": {"out of memory", "config invalid, shutting down"},
+	}
+)
+
+// demoGenerator produces a realistic mixed stream for peek demo: JSON
+// service logs, logfmt access logs, and errors that sometimes carry a stack
+// trace.
+type demoGenerator struct {
+	rng *rand.Rand
+}
+
+func newDemoGenerator(seed int64) *demoGenerator {
+	return &demoGenerator{rng: rand.New(rand.NewSource(seed))}
+}
+
+// line returns one log line stamped ts.
+func (g *demoGenerator) line(ts time.Time) string {
+	if g.rng.Intn(10) < 3 {
+		return g.accessLine(ts)
+	}
+	return g.serviceLine(ts)
+}
+
+// accessLine returns a logfmt HTTP access log line; 4xx are WARN and 5xx
+// are ERROR.
+func (g *demoGenerator) accessLine(ts time.Time) string {
+	status := 200
+	switch r := g.rng.Intn(100); {
+	case r < 3:
+		status = 500 + g.rng.Intn(4)
+	case r < 12:
+		status = []int{400, 401, 403, 404, 429}[g.rng.Intn(5)]
+	case r < 20:
+		status = 201
+	}
+	level := "INFO"
+	if status >= 500 {
+		level = "ERROR"
+	} else if status >= 400 {
+		level = "WARN"
+	}
+	method, path := g.pick(demoMethods), g.pick(demoPaths)
+	return fmt.Sprintf(`time=%s level=%s msg="%s %s %d" service=gateway method=%s path=%s status=%d duration_ms=%d client_ip=10.0.%d.%d`,
+		ts.UTC().Format(time.RFC3339Nano), level, method, path, status, method, path, status,
+		g.latency(), g.rng.Intn(256), g.rng.Intn(256))
+}
+
+// serviceLine returns a JSON application log line.
+func (g *demoGenerator) serviceLine(ts time.Time) string {
+	level := "INFO"
+	r := g.rng.Intn(100)
+	for _, l := range demoLevels {
+		if r < l.upTo {
+			level = l.level
+			break
+		}
+	}
+
+	service := g.pick(demoServices)
+	obj := map[string]interface{}{
+		"timestamp":   ts.UTC().Format(time.RFC3339Nano),
+		"level":       level,
+		"msg":         g.pick(demoMessages[level]),
+		"service":     service,
+		"request_id":  fmt.Sprintf("req-%08x", g.rng.Uint32()),
+		"user_id":     fmt.Sprintf("u%d", 1000+g.rng.Intn(50)),
+		"duration_ms": g.latency(),
+	}
+	if level == "ERROR" || level == "FATAL" {
+		obj["error"] = obj["msg"]
+		if g.rng.Intn(3) == 0 {
+			obj["stack"] = g.stack(service)
+		}
+	}
+
+	data, _ := json.Marshal(obj)
+	return string(data)
+}
+
+// stack returns a Go-style goroutine dump for service.
+func (g *demoGenerator) stack(service string) string {
+	return fmt.Sprintf("goroutine %d [running]:\n"+
+		"main.(*%sHandler).ServeHTTP(0xc000%04x)\n\t/src/%s/handler.go:%d +0x1a4\n"+
+		"net/http.serverHandler.ServeHTTP(...)\n\t/usr/local/go/src/net/http/server.go:3137 +0x8e",
+		1+g.rng.Intn(500), service, g.rng.Intn(0x10000), service, 20+g.rng.Intn(200))
+}
+
+// latency returns a long-tailed request duration in milliseconds.
+func (g *demoGenerator) latency() int {
+	ms := int(g.rng.ExpFloat64()*40) + 1
+	if g.rng.Intn(50) == 0 {
+		ms += 1000 + g.rng.Intn(4000) // occasional slow request
+	}
+	return ms
+}
+
+func (g *demoGenerator) pick(options []string) string {
+	return options[g.rng.Intn(len(options))]
+}
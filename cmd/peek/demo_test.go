@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/parser"
+)
+
+func TestDemoGeneratorLinesParse(t *testing.T) {
+	g := newDemoGenerator(42)
+	detector := parser.NewDetector()
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	levels := make(map[string]int)
+	formats := make(map[string]int)
+	stacks := 0
+	for range 2000 {
+		line := g.line(now)
+		entry, err := detector.Parse(line)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", line, err)
+		}
+		if !entry.Timestamp.Equal(now) || entry.Message == "" {
+			t.Fatalf("Parse(%q) = %+v, want timestamp and message", line, entry)
+		}
+		levels[entry.Level]++
+		if strings.HasPrefix(line, "{") {
+			formats["json"]++
+		} else {
+			formats["logfmt"]++
+		}
+		if _, ok := entry.Fields["stack"]; ok {
+			stacks++
+		}
+	}
+
+	for _, level := range []string{"DEBUG", "INFO", "WARN", "ERROR"} {
+		if levels[level] == 0 {
+			t.Errorf("no %s lines in %v", level, levels)
+		}
+	}
+	if levels["INFO"] < levels["ERROR"] {
+		t.Errorf("level mix %v, want INFO to dominate", levels)
+	}
+	if formats["json"] == 0 || formats["logfmt"] == 0 {
+		t.Errorf("formats = %v, want both JSON and logfmt", formats)
+	}
+	if stacks == 0 {
+		t.Error("no stack traces generated")
+	}
+
+	// The same seed gives the same stream.
+	if a, b := newDemoGenerator(7).line(now), newDemoGenerator(7).line(now); a != b {
+		t.Errorf("seeded lines differ: %q vs %q", a, b)
+	}
+}
+
+func TestStreamDemoBackfill(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	if err := streamDemo(context.Background(), &buf, newDemoGenerator(1), 60, 0, func() time.Time { return now }); err != nil {
+		t.Fatalf("streamDemo() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 60 {
+		t.Fatalf("streamDemo() wrote %d lines, want 60", len(lines))
+	}
+	detector := parser.NewDetector()
+	var prev time.Time
+	for i, line := range lines {
+		entry, err := detector.Parse(line)
+		if err != nil {
+			t.Fatalf("Parse(line %d) error = %v", i, err)
+		}
+		if entry.Timestamp.Before(now.Add(-time.Hour)) || !entry.Timestamp.Before(now) || entry.Timestamp.Before(prev) {
+			t.Fatalf("line %d timestamp %s, want ascending within the hour before %s", i, entry.Timestamp, now)
+		}
+		prev = entry.Timestamp
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// Output formats accepted by --output. JSON output is one JSON object per
+// line (NDJSON) with stable snake_case keys, so scripts can pipe it to jq.
+const (
+	outputJSON   = "json"
+	outputPretty = "pretty" // peek query only
+	outputText   = "text"   // human-readable tables for the other read commands
+)
+
+// entryWriter prints a page of entries in one output format.
+type entryWriter func(w io.Writer, entries []*storage.LogEntry) error
+
+// ANSI escape sequences used by the pretty formatter.
+const (
+	ansiReset   = "\x1b[0m"
+	ansiDim     = "\x1b[2m"
+	ansiRed     = "\x1b[31m"
+	ansiGreen   = "\x1b[32m"
+	ansiYellow  = "\x1b[33m"
+	ansiCyan    = "\x1b[36m"
+	ansiBoldRed = "\x1b[1;31m"
+)
+
+// levelColors maps levels to the color their column is printed in.
+var levelColors = map[string]string{
+	"FATAL": ansiBoldRed,
+	"ERROR": ansiRed,
+	"WARN":  ansiYellow,
+	"INFO":  ansiGreen,
+	"DEBUG": ansiCyan,
+	"TRACE": ansiDim,
+}
+
+// prettyTimeLayout is the timestamp column layout, in local time.
+const prettyTimeLayout = "2006-01-02 15:04:05.000"
+
+// prettyFormatter prints entries as aligned time, level, field and message
+// columns for reading in a terminal.
+type prettyFormatter struct {
+	color  bool
+	fields []string // extra field columns, printed between level and message
+}
+
+// write prints entries, sizing each field column to its widest value.
+func (f prettyFormatter) write(w io.Writer, entries []*storage.LogEntry) error {
+	widths := make([]int, len(f.fields))
+	for i, name := range f.fields {
+		widths[i] = len(name) + 1 // room for "name=" when the value is empty
+		for _, e := range entries {
+			widths[i] = max(widths[i], len(name)+1+len(fieldString(e, name)))
+		}
+	}
+
+	var b strings.Builder
+	for _, e := range entries {
+		b.Reset()
+		b.WriteString(f.paint(ansiDim, e.Timestamp.Local().Format(prettyTimeLayout)))
+		b.WriteString("  ")
+		b.WriteString(f.paint(levelColors[e.Level], fmt.Sprintf("%-5s", e.Level)))
+		for i, name := range f.fields {
+			col := name + "=" + fieldString(e, name)
+			b.WriteString("  ")
+			b.WriteString(f.paint(ansiDim, name+"="))
+			b.WriteString(fieldString(e, name))
+			b.WriteString(strings.Repeat(" ", widths[i]-len(col)))
+		}
+		b.WriteString("  ")
+		b.WriteString(e.Message)
+		b.WriteByte('\n')
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// paint wraps s in the given color when coloring is enabled.
+func (f prettyFormatter) paint(color, s string) string {
+	if !f.color || color == "" {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// fieldString returns a field's value as printed in a column, or "".
+func fieldString(e *storage.LogEntry, name string) string {
+	switch name {
+	case "trace_id":
+		traceID, _ := e.TraceContext()
+		return traceID
+	case "span_id":
+		_, spanID := e.TraceContext()
+		return spanID
+	case "source":
+		return e.SourceName()
+	}
+	if v, ok := e.HostField(name); ok {
+		return v
+	}
+	v, ok := e.Fields[name]
+	if !ok || v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// writeJSONLines prints one JSON object per entry.
+func writeJSONLines(w io.Writer, entries []*storage.LogEntry) error {
+	for _, entry := range entries {
+		data, err := entry.ToJSON()
+		if err != nil {
+			return fmt.Errorf("failed to encode entry %s: %w", entry.ID, err)
+		}
+		if _, err := fmt.Fprintln(w, string(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkTextOrJSON validates --output for commands that print text or JSON.
+func checkTextOrJSON(output string) error {
+	if output != outputText && output != outputJSON {
+		return fmt.Errorf("invalid --output %q (use text or json)", output)
+	}
+	return nil
+}
+
+// writeJSONLine writes v as a single line of JSON.
+func writeJSONLine(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// useColor reports whether pretty output to f should be colored: never with
+// --no-color or a non-empty NO_COLOR (https://no-color.org), otherwise only
+// when f is a terminal.
+func useColor(f *os.File, noColor bool) bool {
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// parseFieldList splits a comma-separated --fields value.
+func parseFieldList(s string) []string {
+	var fields []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
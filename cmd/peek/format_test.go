@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+func TestPrettyFormatter(t *testing.T) {
+	ts := time.Date(2026, 3, 10, 15, 30, 0, 0, time.Local)
+	entries := []*storage.LogEntry{
+		{Timestamp: ts, Level: "ERROR", Message: "payment failed", Fields: map[string]interface{}{"service": "payments", "status": 502}},
+		{Timestamp: ts.Add(time.Second), Level: "INFO", Message: "ok", Fields: map[string]interface{}{"service": "api"}},
+	}
+
+	tests := []struct {
+		name string
+		f    prettyFormatter
+		want string
+	}{
+		{
+			name: "plain with field columns",
+			f:    prettyFormatter{fields: []string{"service", "status"}},
+			want: "2026-03-10 15:30:00.000  ERROR  service=payments  status=502  payment failed\n" +
+				"2026-03-10 15:30:01.000  INFO   service=api       status=     ok\n",
+		},
+		{
+			name: "colored",
+			f:    prettyFormatter{color: true},
+			want: ansiDim + "2026-03-10 15:30:00.000" + ansiReset + "  " + ansiRed + "ERROR" + ansiReset + "  payment failed\n" +
+				ansiDim + "2026-03-10 15:30:01.000" + ansiReset + "  " + ansiGreen + "INFO " + ansiReset + "  ok\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := tt.f.write(&out, entries); err != nil {
+				t.Fatalf("write() error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Fatalf("write() =\n%q\nwant\n%q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatalf("CreateTemp() error = %v", err)
+	}
+	defer f.Close()
+
+	t.Setenv("NO_COLOR", "")
+	if useColor(f, false) {
+		t.Errorf("useColor(regular file) = true, want false")
+	}
+	if useColor(f, true) {
+		t.Errorf("useColor(--no-color) = true, want false")
+	}
+	t.Setenv("NO_COLOR", "1")
+	if useColor(os.Stdout, false) {
+		t.Errorf("useColor(NO_COLOR=1) = true, want false")
+	}
+}
+
+func TestParseFieldList(t *testing.T) {
+	if got := strings.Join(parseFieldList(" service, ,request_id "), "|"); got != "service|request_id" {
+		t.Fatalf("parseFieldList() = %q, want service|request_id", got)
+	}
+}
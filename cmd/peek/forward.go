@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mchurichi/peek/internal/config"
+	"github.com/mchurichi/peek/pkg/parser"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// Forwarding defaults: lines per /ingest request, how long read lines wait
+// before they are queued, and the retry backoff bounds.
+const (
+	forwardBatch      = 500
+	forwardFlushEvery = 100 * time.Millisecond
+	forwardMinBackoff = time.Second
+	forwardMaxBackoff = 30 * time.Second
+)
+
+func runForwardCommand(args []string) error {
+	fs := flag.NewFlagSet("forward", flag.ExitOnError)
+	to := fs.String("to", "", "URL of the peek server to forward to (e.g., http://logs.internal:8080)")
+	token := fs.String("token", "", "API token sent as a bearer token")
+	format := fs.String("format", "", "Log format the server parses lines as: auto, access, cef, csv, gelf, journald, json, klog, leef, log4j, logfmt, syslog, tsv")
+	namespace := fs.String("namespace", "", "Namespace for forwarded entries (admin tokens only)")
+	source := fs.String("source", "", "Source recorded on forwarded entries (e.g., the host or file name)")
+	hostMetadata := fs.Bool("host-metadata", false, "Attach this machine's hostname, OS and user to forwarded entries")
+	queuePath := fs.String("queue-path", "~/.peek/forward-queue", "Directory of the durable local queue")
+	queueSize := fs.String("queue-size", "64MB", "Cap on queued lines; the oldest are dropped beyond it")
+	batch := fs.Int("batch", forwardBatch, "Lines per request")
+	compress := fs.Bool("gzip", false, "Gzip request bodies (the server must support Content-Encoding: gzip)")
+	maxBackoff := fs.String("max-backoff", forwardMaxBackoff.String(), "Longest wait between retries (e.g., 30s, 5m)")
+	fs.Parse(args)
+
+	if err := validateNoPositionalArgs(fs.Args()); err != nil {
+		return err
+	}
+	var host *storage.HostInfo
+	if *hostMetadata {
+		host = localHost()
+	}
+	endpoint, err := forwardEndpoint(*to, *format, *namespace, *source, host)
+	if err != nil {
+		return err
+	}
+	maxBytes, err := config.ParseSize(*queueSize)
+	if err != nil {
+		return fmt.Errorf("invalid --queue-size: %w", err)
+	}
+	if *batch <= 0 {
+		return fmt.Errorf("--batch must be positive")
+	}
+	backoffCap, err := parseDuration(*maxBackoff)
+	if err != nil || backoffCap < forwardMinBackoff {
+		return fmt.Errorf("invalid --max-backoff %q (minimum %s)", *maxBackoff, forwardMinBackoff)
+	}
+
+	db, err := storage.NewBadgerStorage(storage.Config{DBPath: *queuePath})
+	if err != nil {
+		return fmt.Errorf("failed to open forward queue: %w", err)
+	}
+	defer db.Close()
+
+	if lines, _, err := db.QueueLen(); err != nil {
+		return err
+	} else if lines > 0 {
+		log.Printf("Replaying %d lines queued by an earlier run", lines)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	f := &forwarder{
+		db:         db,
+		client:     &http.Client{Timeout: 30 * time.Second},
+		endpoint:   endpoint,
+		token:      *token,
+		batch:      *batch,
+		gzip:       *compress,
+		minBackoff: forwardMinBackoff,
+		maxBackoff: backoffCap,
+		wake:       make(chan struct{}, 1),
+	}
+
+	log.Printf("Forwarding stdin to %s", *to)
+	readDone := make(chan struct{})
+	var readErr error
+	go func() {
+		defer close(readDone)
+		readErr = f.enqueueFrom(ctx, os.Stdin, maxBytes)
+	}()
+
+	f.send(ctx, readDone)
+	<-readDone
+
+	if lines, _, err := db.QueueLen(); err == nil && lines > 0 {
+		log.Printf("%d lines stay queued in %s and are sent on the next run", lines, *queuePath)
+	}
+	log.Printf("Forwarded %d lines", f.sent)
+	if f.dropped > 0 {
+		log.Printf("Dropped %d lines while the queue was full", f.dropped)
+	}
+	if readErr != nil {
+		return fmt.Errorf("error reading stdin: %w", readErr)
+	}
+	return nil
+}
+
+// forwardEndpoint returns the /ingest URL on the server at base. A non-nil
+// host is sent as host metadata for the forwarded entries.
+func forwardEndpoint(base, format, namespace, source string, host *storage.HostInfo) (string, error) {
+	if base == "" {
+		return "", fmt.Errorf("missing --to (usage: peek forward --to URL)")
+	}
+	u, err := url.Parse(base)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid --to %q (use http://host:port)", base)
+	}
+	if format != "" && !parser.ValidFormat(format) {
+		return "", fmt.Errorf("invalid format: %s (use %s)", format, strings.Join(parser.Formats(), ", "))
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/ingest"
+	q := u.Query()
+	if format != "" {
+		q.Set("format", format)
+	}
+	if namespace != "" {
+		q.Set("namespace", namespace)
+	}
+	if source != "" {
+		q.Set("source", source)
+	}
+	if host != nil {
+		q.Set("host", host.Name)
+		q.Set("host_os", host.OS)
+		q.Set("host_user", host.User)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// forwarder moves lines from stdin through the durable queue to a remote
+// /ingest endpoint. Lines are queued before they are sent and removed only
+// once the server accepted them, so an unreachable server or a restart of
+// peek forward never loses them.
+type forwarder struct {
+	db         *storage.BadgerStorage
+	client     *http.Client
+	endpoint   string
+	token      string
+	batch      int
+	gzip       bool // compress request bodies
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	wake       chan struct{} // signalled when lines are queued
+
+	sent    int
+	dropped int
+}
+
+// enqueueFrom queues the lines of r in small batches until EOF or until
+// ctx is done. Lines already read are queued before it returns.
+func (f *forwarder) enqueueFrom(ctx context.Context, r io.Reader, maxBytes int64) error {
+	lines := make(chan string)
+	scanErr := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+		scanErr <- scanner.Err()
+	}()
+
+	ticker := time.NewTicker(forwardFlushEvery)
+	defer ticker.Stop()
+
+	var pending []string
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		dropped, err := f.db.Enqueue(pending, maxBytes)
+		if err != nil {
+			return err
+		}
+		if dropped > 0 {
+			f.dropped += dropped
+			log.Printf("Warning: forward queue full, dropped the %d oldest lines", dropped)
+		}
+		pending = pending[:0]
+		select {
+		case f.wake <- struct{}{}:
+		default:
+		}
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return flush()
+		case <-ticker.C:
+			if err := flush(); err != nil {
+				return err
+			}
+		case line, ok := <-lines:
+			if !ok {
+				if err := flush(); err != nil {
+					return err
+				}
+				select {
+				case err := <-scanErr:
+					return err
+				default:
+					return nil
+				}
+			}
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			pending = append(pending, line)
+			if len(pending) >= f.batch {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// send delivers queued lines until ctx is done, or until input has ended
+// (readDone is closed) and the queue is empty. Failed requests are retried
+// with a backoff that doubles from minBackoff up to maxBackoff.
+func (f *forwarder) send(ctx context.Context, readDone <-chan struct{}) {
+	backoff := f.minBackoff
+	for {
+		lines, err := f.db.PeekQueue(f.batch)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			lines = nil
+		}
+		if len(lines) == 0 {
+			select {
+			case <-readDone:
+				// The reader queues its last lines before closing readDone.
+				if n, _, err := f.db.QueueLen(); err != nil || n == 0 {
+					return
+				}
+			case <-ctx.Done():
+				return
+			case <-f.wake:
+			}
+			continue
+		}
+
+		retry, err := f.post(ctx, lines)
+		if err != nil && retry {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("forward: %v; retrying in %s", err, backoff)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, f.maxBackoff)
+			continue
+		}
+		if err != nil {
+			log.Printf("Warning: forward: %v; dropping %d lines", err, len(lines))
+		} else {
+			f.sent += len(lines)
+		}
+		backoff = f.minBackoff
+		if err := f.db.AckQueue(lines[len(lines)-1].Seq); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+}
+
+// post sends one batch to /ingest. retry reports whether a failed batch may
+// succeed later: the server is down, overloaded or rejects the token. A 400
+// or 413 means the batch itself is refused and will never be accepted.
+func (f *forwarder) post(ctx context.Context, lines []storage.QueuedLine) (retry bool, err error) {
+	var body bytes.Buffer
+	w := io.Writer(&body)
+	var zw *gzip.Writer
+	if f.gzip {
+		zw = gzip.NewWriter(&body)
+		w = zw
+	}
+	for _, l := range lines {
+		io.WriteString(w, l.Line)
+		io.WriteString(w, "\n")
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return false, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.endpoint, &body)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	if f.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if f.token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.token)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusRequestEntityTooLarge:
+		return false, fmt.Errorf("server refused batch: %s", resp.Status)
+	default:
+		return true, fmt.Errorf("server answered %s", resp.Status)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+func TestForwardEndpoint(t *testing.T) {
+	tests := []struct {
+		name      string
+		base      string
+		format    string
+		namespace string
+		source    string
+		host      *storage.HostInfo
+		want      string
+		wantErr   bool
+	}{
+		{name: "plain", base: "http://logs:8080", want: "http://logs:8080/ingest"},
+		{name: "trailing slash and options", base: "https://logs/peek/", format: "json", namespace: "web", want: "https://logs/peek/ingest?format=json&namespace=web"},
+		{name: "source", base: "http://logs:8080", source: "web-1:/var/log/app.log", want: "http://logs:8080/ingest?source=web-1%3A%2Fvar%2Flog%2Fapp.log"},
+		{name: "host metadata", base: "http://logs:8080", host: &storage.HostInfo{Name: "web-1", OS: "linux", User: "deploy"}, want: "http://logs:8080/ingest?host=web-1&host_os=linux&host_user=deploy"},
+		{name: "missing", base: "", wantErr: true},
+		{name: "no scheme", base: "logs:8080", wantErr: true},
+		{name: "bad format", base: "http://logs", format: "xml", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := forwardEndpoint(tt.base, tt.format, tt.namespace, tt.source, tt.host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("forwardEndpoint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("forwardEndpoint() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForwarderQueuesUntilServerIsBack(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	failures := 2
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if failures > 0 {
+			failures--
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, strings.Split(strings.TrimSpace(string(body)), "\n")...)
+	}))
+	defer ts.Close()
+
+	db, err := storage.NewBadgerStorage(storage.Config{DBPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewBadgerStorage() error = %v", err)
+	}
+	defer db.Close()
+
+	f := &forwarder{
+		db:         db,
+		client:     ts.Client(),
+		endpoint:   ts.URL + "/ingest",
+		token:      "secret",
+		batch:      2,
+		minBackoff: time.Millisecond,
+		maxBackoff: 5 * time.Millisecond,
+		wake:       make(chan struct{}, 1),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		if err := f.enqueueFrom(ctx, strings.NewReader("a\nb\n\nc\n"), 0); err != nil {
+			t.Errorf("enqueueFrom() error = %v", err)
+		}
+	}()
+	f.send(ctx, readDone)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(received, ",") != "a,b,c" {
+		t.Fatalf("received %q, want a,b,c in order", received)
+	}
+	if f.sent != 3 {
+		t.Fatalf("sent = %d, want 3", f.sent)
+	}
+	if n, _, _ := db.QueueLen(); n != 0 {
+		t.Fatalf("queue holds %d lines after delivery, want 0", n)
+	}
+}
+
+func TestForwarderDropsRefusedBatch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "line too long", http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	db, err := storage.NewBadgerStorage(storage.Config{DBPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewBadgerStorage() error = %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Enqueue([]string{"x"}, 0); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+
+	f := &forwarder{db: db, client: ts.Client(), endpoint: ts.URL, batch: 10, minBackoff: time.Millisecond, maxBackoff: time.Millisecond, wake: make(chan struct{}, 1)}
+	readDone := make(chan struct{})
+	close(readDone)
+	f.send(context.Background(), readDone)
+
+	if n, _, _ := db.QueueLen(); n != 0 {
+		t.Fatalf("queue holds %d lines, want the refused batch dropped", n)
+	}
+}
+
+func TestForwarderGzipsBatches(t *testing.T) {
+	var got string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			http.Error(w, "not gzip", http.StatusBadRequest)
+			return
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(zr)
+		got = string(body)
+	}))
+	defer ts.Close()
+
+	db, err := storage.NewBadgerStorage(storage.Config{DBPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewBadgerStorage() error = %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Enqueue([]string{"a", "b"}, 0); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+
+	f := &forwarder{db: db, client: ts.Client(), endpoint: ts.URL, batch: 10, gzip: true, minBackoff: time.Millisecond, maxBackoff: time.Millisecond, wake: make(chan struct{}, 1)}
+	readDone := make(chan struct{})
+	close(readDone)
+	f.send(context.Background(), readDone)
+
+	if got != "a\nb\n" || f.sent != 2 {
+		t.Fatalf("server received %q (sent %d), want both lines", got, f.sent)
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"os"
+	"os/user"
+	"runtime"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// localHost describes this machine for host metadata. Parts that cannot be
+// determined are left empty.
+func localHost() *storage.HostInfo {
+	h := &storage.HostInfo{OS: runtime.GOOS}
+	h.Name, _ = os.Hostname()
+	if u, err := user.Current(); err == nil {
+		h.User = u.Username
+	} else {
+		h.User = os.Getenv("USER")
+	}
+	return h
+}
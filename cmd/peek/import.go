@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mchurichi/peek/internal/config"
+	"github.com/mchurichi/peek/pkg/parser"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// defaultImportDedupe is the dedupe window of imports when
+// parsing.dedupe_window is unset. Lines stored before an interruption but
+// after the last checkpoint are read again on --resume and skipped by it.
+const defaultImportDedupe = 7 * 24 * time.Hour
+
+// importHeadSize is how much of the start of a file identifies it for
+// --resume.
+const importHeadSize = 4096
+
+func runImportCommand(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	configPath := fs.String("config", "~/.peek/config.toml", "Path to config file")
+	dbPath := fs.String("db-path", "", "Database path (overrides config)")
+	format := fs.String("format", "", "Log format (default: parsing.format, or the source's)")
+	source := fs.String("source", "", "Source recorded for imported entries (default: the file path)")
+	resume := fs.Bool("resume", false, "Continue interrupted imports from their last checkpoint")
+	dedupe := fs.String("dedupe", "", "Skip lines already stored within this window (default: parsing.dedupe_window, else 7d; 0 disables)")
+	batch := fs.Int("batch", 500, "Entries stored per batch and checkpoint")
+	quiet := fs.Bool("quiet", false, "Don't print progress")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return fmt.Errorf("no files to import (usage: peek import [OPTIONS] FILE...)")
+	}
+	if *batch < 1 {
+		return fmt.Errorf("invalid --batch %d (use 1 or more)", *batch)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if *dbPath != "" {
+		cfg.Storage.DBPath = *dbPath
+	}
+	if *format != "" {
+		cfg.Parsing.Format = *format
+	}
+	if err := setParserDefaults(cfg.Parsing); err != nil {
+		return err
+	}
+	settings, err := newIngestSettings(cfg.Parsing)
+	if err != nil {
+		return err
+	}
+	if settings.format == "" {
+		settings.format = "auto"
+	}
+	// An explicit --format wins over per-source formats.
+	if *format != "" {
+		settings.sourceFormats = nil
+	}
+	window := settings.dedupeWindow
+	if window == 0 {
+		window = defaultImportDedupe
+	}
+	if *dedupe != "" {
+		if window, err = parseDuration(*dedupe); err != nil || window < 0 {
+			return fmt.Errorf("invalid --dedupe %q", *dedupe)
+		}
+	}
+
+	storageCfg, err := newStorageConfig(cfg)
+	if err != nil {
+		return err
+	}
+	db, err := storage.NewBadgerStorage(storageCfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for _, path := range fs.Args() {
+		opts := importOptions{source: *source, resume: *resume, dedupe: window, batch: *batch}
+		if !*quiet {
+			opts.progress = os.Stderr
+		}
+		if err := importFile(ctx, os.Stdout, db, settings, path, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importOptions are the per-file settings of peek import.
+type importOptions struct {
+	source   string // "" records the file path
+	resume   bool
+	dedupe   time.Duration
+	batch    int
+	progress io.Writer // nil prints no progress
+}
+
+// errImportInterrupted is returned by importFile when ctx ends first.
+var errImportInterrupted = errors.New("import interrupted")
+
+// importFile parses the lines of the file at path and stores them in
+// batches, saving a checkpoint after each so an interrupted import resumes
+// with opts.resume instead of starting over. The checkpoint is removed once
+// the file is imported. When ctx ends, the batch being read is stored and
+// importFile returns errImportInterrupted.
+func importFile(ctx context.Context, w io.Writer, db *storage.BadgerStorage, settings ingestSettings, path string, opts importOptions) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(abs)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	source := opts.source
+	if source == "" {
+		source = abs
+	}
+	format := settings.formatFor(source)
+	detector := settings.detector.Stream(nil)
+
+	cp := &storage.ImportCheckpoint{Path: abs}
+	cp.HeadHash, cp.HeadSize, err = fileHead(f, importHeadSize)
+	if err != nil {
+		return err
+	}
+	prev, err := db.GetImportCheckpoint(abs)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		prev = nil
+	case err != nil:
+		return err
+	}
+	if prev != nil && opts.resume {
+		if hash, _, err := fileHead(f, prev.HeadSize); err != nil || hash != prev.HeadHash || info.Size() < prev.Offset {
+			return fmt.Errorf("%s changed since its import was interrupted; import it again without --resume", path)
+		}
+		cp.Offset, cp.Line, cp.Stored, cp.Session = prev.Offset, prev.Line, prev.Stored, prev.Session
+		// Stream state such as a CSV header row comes from the file's
+		// first line.
+		if cp.Offset > 0 {
+			if first, err := bufio.NewReader(f).ReadString('\n'); err == nil || first != "" {
+				detector.ParseLines([]string{strings.TrimRight(first, "\r\n")}, format)
+			}
+		}
+		fmt.Fprintf(w, "Resuming %s at line %d (%d entries stored)\n", path, cp.Line+1, cp.Stored)
+	} else if prev != nil {
+		fmt.Fprintf(w, "Importing %s from the start; its interrupted import stopped at line %d (use --resume to continue)\n", path, prev.Line+1)
+	}
+	if _, err := f.Seek(cp.Offset, io.SeekStart); err != nil {
+		return err
+	}
+	if cp.Session == "" {
+		cp.Session = newSessionID()
+		if err := db.SetSessionName(cp.Session, "peek import "+abs); err != nil {
+			return err
+		}
+	}
+
+	imp := &importer{db: db, settings: settings, detector: detector, format: format, source: source, opts: opts, cp: cp}
+	progress := newProgress(opts.progress, "importing "+filepath.Base(path), "bytes", int(info.Size()), outputText, opts.progress == nil)
+	err = imp.run(ctx, bufio.NewReaderSize(f, 64*1024), progress)
+	progress.finish(int(imp.offset))
+	if err != nil && !errors.Is(err, errImportInterrupted) {
+		return err
+	}
+
+	summary := fmt.Sprintf("%d entries stored", imp.stored)
+	if imp.duplicates > 0 {
+		summary += fmt.Sprintf(", %d duplicates skipped", imp.duplicates)
+	}
+	if imp.failed > 0 {
+		summary += fmt.Sprintf(", %d lines failed to parse (see peek reparse-failures)", imp.failed)
+	}
+	if err != nil {
+		fmt.Fprintf(w, "Interrupted %s at line %d: %s. Run the same import with --resume to continue.\n", path, cp.Line+1, summary)
+		return err
+	}
+	if err := db.DeleteImportCheckpoint(abs); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+	fmt.Fprintf(w, "Imported %s: %s\n", path, summary)
+	return nil
+}
+
+// fileHead returns the SHA-256 of the first n bytes of f, or of all of it
+// when shorter, and how many bytes that was.
+func fileHead(f *os.File, n int64) (string, int64, error) {
+	h := sha256.New()
+	size, err := io.Copy(h, io.NewSectionReader(f, 0, n))
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// importer reads one file for importFile.
+type importer struct {
+	db       *storage.BadgerStorage
+	settings ingestSettings
+	detector *parser.Detector
+	format   string
+	source   string
+	opts     importOptions
+	cp       *storage.ImportCheckpoint
+
+	offset int64 // bytes read, including those before the checkpoint
+	line   int   // lines read
+	// recordOffset and recordLine are where the record being joined began;
+	// a checkpoint never falls inside a record.
+	recordOffset int64
+	recordLine   int
+	pending      []*storage.LogEntry
+
+	stored, duplicates, failed int
+}
+
+// run reads lines from r until EOF or until ctx ends, storing a batch and
+// a checkpoint every opts.batch entries.
+func (imp *importer) run(ctx context.Context, r *bufio.Reader, progress *progressReporter) error {
+	imp.offset, imp.line = imp.cp.Offset, imp.cp.Line
+	imp.recordOffset, imp.recordLine = imp.offset, imp.line
+	m := parser.NewMultiline(imp.settings.multilineFor(imp.source))
+	for {
+		text, err := r.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if text == "" {
+			break
+		}
+		start, recordLine := imp.offset, imp.recordLine
+		imp.offset += int64(len(text))
+		imp.line++
+
+		wasPending := m.Pending()
+		record := m.Add(strings.TrimRight(text, "\r\n"))
+		switch {
+		case !m.Pending():
+			imp.recordOffset, imp.recordLine = imp.offset, imp.line
+		case record != nil || !wasPending: // the line starts a record
+			imp.recordOffset, imp.recordLine = start, imp.line-1
+		}
+		if record != nil {
+			if err := imp.add(record, recordLine); err != nil {
+				return err
+			}
+		}
+		if len(imp.pending) >= imp.opts.batch {
+			if err := imp.flush(); err != nil {
+				return err
+			}
+			progress.update(int(imp.offset))
+			if ctx.Err() != nil {
+				return errImportInterrupted
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+	}
+	if record := m.Flush(); record != nil {
+		if err := imp.add(record, imp.recordLine); err != nil {
+			return err
+		}
+	}
+	imp.recordOffset, imp.recordLine = imp.offset, imp.line
+	return imp.flush()
+}
+
+// add parses a record that began after line lineNo and queues its entry.
+// Records that don't parse are kept for peek reparse-failures.
+func (imp *importer) add(record []string, lineNo int) error {
+	entry, err := imp.detector.ParseLines(record, imp.format)
+	if errors.Is(err, parser.ErrHeaderRow) {
+		return nil
+	}
+	if err != nil {
+		imp.failed++
+		return imp.db.RecordParseFailures([]storage.ParseFailure{{
+			Format:  imp.format,
+			Reason:  fmt.Sprintf("line %d: %v", lineNo+1, err),
+			Line:    strings.Join(record, "\n"),
+			Session: imp.cp.Session,
+			Source:  imp.source,
+			Host:    imp.settings.host,
+		}})
+	}
+	parser.Truncate(entry, imp.settings.maxValueSize)
+	entry.Session = imp.cp.Session
+	entry.Source = imp.source
+	entry.Host = imp.settings.host
+	entry.ID = imp.settings.newID(entry)
+	imp.pending = append(imp.pending, entry)
+	return nil
+}
+
+// flush stores the queued entries and checkpoints the start of the record
+// being joined.
+func (imp *importer) flush() error {
+	stored, err := imp.db.StoreBatchUnique(imp.pending, imp.opts.dedupe)
+	if err != nil {
+		return err
+	}
+	for _, ok := range stored {
+		if ok {
+			imp.stored++
+			imp.cp.Stored++
+		} else {
+			imp.duplicates++
+		}
+	}
+	imp.pending = imp.pending[:0]
+	imp.cp.Offset, imp.cp.Line = imp.recordOffset, imp.recordLine
+	return imp.db.SaveImportCheckpoint(imp.cp)
+}
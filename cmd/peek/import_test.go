@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mchurichi/peek/internal/config"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+func TestImportFileResume(t *testing.T) {
+	db, err := storage.NewBadgerStorage(storage.Config{DBPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewBadgerStorage() error = %v", err)
+	}
+	defer db.Close()
+	settings, err := newIngestSettings(config.ParsingConfig{Format: "json", MultilinePattern: `^\s+at `})
+	if err != nil {
+		t.Fatalf("newIngestSettings() error = %v", err)
+	}
+
+	var lines []string
+	for i := 0; i < 10; i++ {
+		lines = append(lines, fmt.Sprintf(`{"time":"2026-03-10T15:%02d:00Z","level":"info","msg":"request %d"}`, i, i))
+		if i == 3 {
+			lines = append(lines, "  at handler.go:12")
+		}
+	}
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	abs, _ := filepath.Abs(path)
+	count := func() int {
+		_, total, err := db.Query(storage.AllFilter{}, 100, 0)
+		if err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+		return total
+	}
+
+	// An interrupted import stores the batch it was reading and checkpoints
+	// the start of the next record.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var out bytes.Buffer
+	opts := importOptions{dedupe: time.Hour, batch: 3}
+	if err := importFile(ctx, &out, db, settings, path, opts); !errors.Is(err, errImportInterrupted) {
+		t.Fatalf("importFile() error = %v, want errImportInterrupted", err)
+	}
+	cp, err := db.GetImportCheckpoint(abs)
+	if err != nil {
+		t.Fatalf("GetImportCheckpoint() error = %v", err)
+	}
+	if cp.Line != 3 || cp.Stored != 3 || count() != 3 {
+		t.Fatalf("checkpoint at line %d with %d stored, %d in the database; want 3, 3, 3", cp.Line, cp.Stored, count())
+	}
+	if !strings.Contains(out.String(), "--resume") {
+		t.Fatalf("output = %q, want a hint to resume", out.String())
+	}
+
+	// A crash between storing a batch and saving its checkpoint leaves the
+	// checkpoint behind; the lines read again are skipped as duplicates.
+	cp.Offset, cp.Line = 0, 0
+	if err := db.SaveImportCheckpoint(cp); err != nil {
+		t.Fatalf("SaveImportCheckpoint() error = %v", err)
+	}
+	out.Reset()
+	opts.resume = true
+	if err := importFile(context.Background(), &out, db, settings, path, opts); err != nil {
+		t.Fatalf("resumed importFile() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "7 entries stored, 3 duplicates skipped") || count() != 10 {
+		t.Fatalf("resumed output = %q with %d entries, want 7 new and 10 in all", out.String(), count())
+	}
+	entries, _, err := db.Query(storage.AllFilter{}, 100, 0)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	for _, e := range entries {
+		if e.Session != cp.Session {
+			t.Fatalf("entry %q in session %q, want the interrupted import's %q", e.Message, e.Session, cp.Session)
+		}
+		if e.Message == "request 3" && !strings.Contains(e.Raw, "handler.go:12") {
+			t.Fatalf("request 3 raw = %q, want its continuation line", e.Raw)
+		}
+	}
+	if _, err := db.GetImportCheckpoint(abs); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("GetImportCheckpoint() after the import error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestImportFileResumeChangedFile(t *testing.T) {
+	db, err := storage.NewBadgerStorage(storage.Config{DBPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewBadgerStorage() error = %v", err)
+	}
+	defer db.Close()
+	settings, err := newIngestSettings(config.ParsingConfig{Format: "logfmt"})
+	if err != nil {
+		t.Fatalf("newIngestSettings() error = %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte("level=info msg=one\nlevel=info msg=two\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	abs, _ := filepath.Abs(path)
+	if err := db.SaveImportCheckpoint(&storage.ImportCheckpoint{Path: abs, Offset: 19, Line: 1, HeadHash: "rotated", HeadSize: 38}); err != nil {
+		t.Fatalf("SaveImportCheckpoint() error = %v", err)
+	}
+	var out bytes.Buffer
+	err = importFile(context.Background(), &out, db, settings, path, importOptions{resume: true, batch: 10})
+	if err == nil || !strings.Contains(err.Error(), "changed") {
+		t.Fatalf("importFile() error = %v, want the file reported as changed", err)
+	}
+}
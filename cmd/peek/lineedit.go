@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// errInterrupted is returned by readLine when Ctrl+C discards the line.
+var errInterrupted = errors.New("interrupted")
+
+// completer returns the candidates for line at the byte offset cursor and
+// the span of line that a chosen candidate replaces.
+type completer func(line string, cursor int) (start, end int, candidates []string)
+
+// lineEditor reads lines from a terminal in raw mode: it echoes keys
+// itself and supports cursor movement, history and tab completion.
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	history  []string
+	complete completer // nil disables tab completion
+}
+
+// lineState is the line being edited.
+type lineState struct {
+	prompt string
+	buf    []rune
+	pos    int // cursor, an index into buf
+}
+
+// readLine prompts for a line. Enter returns it, Ctrl+C returns
+// errInterrupted and Ctrl+D on an empty line io.EOF. Up and down walk
+// e.history; the line is not added to it.
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	s := &lineState{prompt: prompt}
+	e.redraw(s)
+
+	hist := len(e.history) // index into history; len means the new line
+	draft := ""
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(s.buf), nil
+		case 3: // Ctrl+C
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case 4: // Ctrl+D
+			if len(s.buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			s.delete()
+		case 1: // Ctrl+A
+			s.pos = 0
+		case 5: // Ctrl+E
+			s.pos = len(s.buf)
+		case 21: // Ctrl+U
+			s.buf, s.pos = s.buf[s.pos:], 0
+		case 127, 8: // Backspace
+			if s.pos > 0 {
+				s.pos--
+				s.delete()
+			}
+		case '\t':
+			e.tab(s)
+		case 27: // escape sequence
+			switch e.escape() {
+			case "[A", "OA": // up
+				if hist > 0 {
+					if hist == len(e.history) {
+						draft = string(s.buf)
+					}
+					hist--
+					s.set(e.history[hist])
+				}
+			case "[B", "OB": // down
+				if hist < len(e.history) {
+					hist++
+					if hist == len(e.history) {
+						s.set(draft)
+					} else {
+						s.set(e.history[hist])
+					}
+				}
+			case "[C", "OC": // right
+				s.pos = min(s.pos+1, len(s.buf))
+			case "[D", "OD": // left
+				s.pos = max(s.pos-1, 0)
+			case "[H", "OH", "[1~":
+				s.pos = 0
+			case "[F", "OF", "[4~":
+				s.pos = len(s.buf)
+			case "[3~": // delete
+				s.delete()
+			}
+		default:
+			if r >= ' ' {
+				s.insert(string(r))
+			}
+		}
+		e.redraw(s)
+	}
+}
+
+// escape reads the rest of an escape sequence after ESC.
+func (e *lineEditor) escape() string {
+	var seq []byte
+	for {
+		b, err := e.in.ReadByte()
+		if err != nil {
+			return string(seq)
+		}
+		seq = append(seq, b)
+		// CSI sequences end at a byte in @..~; SS3 ones after one letter.
+		if len(seq) >= 2 && (b >= '@' && b <= '~') {
+			return string(seq)
+		}
+		if len(seq) == 1 && b != '[' && b != 'O' {
+			return string(seq)
+		}
+	}
+}
+
+// tab completes the word under the cursor: a single candidate replaces it,
+// several are listed and their common prefix is filled in.
+func (e *lineEditor) tab(s *lineState) {
+	if e.complete == nil {
+		return
+	}
+	line := string(s.buf)
+	cursor := len(string(s.buf[:s.pos]))
+	start, end, candidates := e.complete(line, cursor)
+	if len(candidates) == 0 || start > cursor || end < cursor || end > len(line) {
+		return
+	}
+	replacement := candidates[0]
+	if len(candidates) > 1 {
+		replacement = commonPrefix(candidates)
+		if len(replacement) < cursor-start {
+			replacement = line[start:cursor]
+		}
+		fmt.Fprint(e.out, "\r\n"+strings.Join(candidates, "  ")+"\r\n")
+	}
+	s.buf = []rune(line[:start] + replacement + line[end:])
+	s.pos = utf8.RuneCountInString(line[:start] + replacement)
+}
+
+// redraw rewrites the prompt and line and places the cursor.
+func (e *lineEditor) redraw(s *lineState) {
+	fmt.Fprintf(e.out, "\r\x1b[K%s%s", s.prompt, string(s.buf))
+	if back := len(s.buf) - s.pos; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+func (s *lineState) insert(text string) {
+	r := []rune(text)
+	s.buf = append(s.buf[:s.pos], append(r, s.buf[s.pos:]...)...)
+	s.pos += len(r)
+}
+
+// delete removes the rune under the cursor.
+func (s *lineState) delete() {
+	if s.pos < len(s.buf) {
+		s.buf = append(s.buf[:s.pos], s.buf[s.pos+1:]...)
+	}
+}
+
+func (s *lineState) set(line string) {
+	s.buf = []rune(line)
+	s.pos = len(s.buf)
+}
+
+// commonPrefix returns the longest prefix shared by all of words, compared
+// without case as completion matches.
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, w := range words[1:] {
+		n := 0
+		for n < len(prefix) && n < len(w) && strings.EqualFold(prefix[n:n+1], w[n:n+1]) {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	for !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return prefix
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLineEditorReadLine(t *testing.T) {
+	complete := func(line string, cursor int) (int, int, []string) {
+		switch line[:cursor] {
+		case "le":
+			return 0, 2, []string{"level:"}
+		case "level:E":
+			return 6, 7, []string{"ERROR", "Emergency"}
+		}
+		return 0, 0, nil
+	}
+
+	tests := []struct {
+		name    string
+		keys    string
+		history []string
+		want    string
+		wantErr error
+	}{
+		{name: "plain line", keys: "level:ERROR\r", want: "level:ERROR"},
+		{name: "backspace", keys: "levex\x7fl\r", want: "level"},
+		{name: "cursor movement", keys: "evel\x1b[D\x1b[D\x1b[D\x1b[Dl\x1b[F!\r", want: "level!"},
+		{name: "home and delete", keys: "xlevel\x01\x1b[3~\r", want: "level"},
+		{name: "kill to start", keys: "foo bar\x1b[D\x1b[D\x1b[D\x15\r", want: "bar"},
+		{name: "single completion", keys: "le\t\r", want: "level:"},
+		{name: "common prefix of several", keys: "level:E\t\r", want: "level:E"},
+		{name: "completion mid-line", keys: "le AND x\x1b[D\x1b[D\x1b[D\x1b[D\x1b[D\x1b[D\t\r", want: "level: AND x"},
+		{name: "history up and down", keys: "\x1b[A\x1b[A\x1b[B\r", history: []string{"first", "second"}, want: "second"},
+		{name: "history keeps the draft", keys: "dra\x1b[A\x1b[Bft\r", history: []string{"old"}, want: "draft"},
+		{name: "ctrl-c", keys: "abc\x03", wantErr: errInterrupted},
+		{name: "ctrl-d on empty line", keys: "\x04", wantErr: io.EOF},
+		{name: "ctrl-d deletes", keys: "ab\x01\x04\r", want: "b"},
+		{name: "utf-8", keys: "héllo\x7f\r", want: "héll"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			e := &lineEditor{in: bufio.NewReader(strings.NewReader(tt.keys)), out: &out, history: tt.history, complete: complete}
+			got, err := e.readLine("> ")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("readLine() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("readLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineEditorListsCandidates(t *testing.T) {
+	var out strings.Builder
+	e := &lineEditor{
+		in:  bufio.NewReader(strings.NewReader("s\t\r")),
+		out: &out,
+		complete: func(line string, cursor int) (int, int, []string) {
+			return 0, 1, []string{"service:", "status:"}
+		},
+	}
+	got, err := e.readLine("> ")
+	if err != nil || got != "s" {
+		t.Fatalf("readLine() = %q, %v, want the shared prefix s", got, err)
+	}
+	if !strings.Contains(out.String(), "\r\nservice:  status:\r\n") {
+		t.Fatalf("output = %q, want the candidates listed", out.String())
+	}
+}
@@ -2,20 +2,27 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path/filepath"
-	"runtime"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/mchurichi/peek/internal/config"
 	"github.com/mchurichi/peek/pkg/parser"
+	"github.com/mchurichi/peek/pkg/query"
+	"github.com/mchurichi/peek/pkg/scheduler"
 	"github.com/mchurichi/peek/pkg/server"
 	"github.com/mchurichi/peek/pkg/storage"
 )
@@ -39,6 +46,61 @@ func main() {
 				log.Fatalf("DB command error: %v", err)
 			}
 			return
+		case "query":
+			if err := runQueryCommand(args[1:]); err != nil {
+				log.Fatalf("Query command error: %v", err)
+			}
+			return
+		case "repl":
+			if err := runReplCommand(args[1:]); err != nil {
+				log.Fatalf("Repl command error: %v", err)
+			}
+			return
+		case "import":
+			if err := runImportCommand(args[1:]); err != nil {
+				log.Fatalf("Import command error: %v", err)
+			}
+			return
+		case "report":
+			if err := runReportCommand(args[1:]); err != nil {
+				log.Fatalf("Report command error: %v", err)
+			}
+			return
+		case "fields":
+			if err := runFieldsCommand(args[1:]); err != nil {
+				log.Fatalf("Fields command error: %v", err)
+			}
+			return
+		case "sessions":
+			if err := runSessionsCommand(args[1:]); err != nil {
+				log.Fatalf("Sessions command error: %v", err)
+			}
+			return
+		case "audit":
+			if err := runAuditCommand(args[1:]); err != nil {
+				log.Fatalf("Audit command error: %v", err)
+			}
+			return
+		case "watch":
+			if err := runWatchCommand(args[1:]); err != nil {
+				log.Fatalf("Watch command error: %v", err)
+			}
+			return
+		case "demo":
+			if err := runDemoCommand(args[1:]); err != nil {
+				log.Fatalf("Demo command error: %v", err)
+			}
+			return
+		case "forward":
+			if err := runForwardCommand(args[1:]); err != nil {
+				log.Fatalf("Forward command error: %v", err)
+			}
+			return
+		case "reparse-failures":
+			if err := runReparseFailuresCommand(args[1:]); err != nil {
+				log.Fatalf("Reparse-failures command error: %v", err)
+			}
+			return
 		default:
 			if !strings.HasPrefix(args[0], "-") {
 				log.Fatalf("Unknown command: %s (use --help)", args[0])
@@ -51,10 +113,22 @@ func main() {
 	dbPath := flag.String("db-path", "", "Database path (overrides config)")
 	retentionSize := flag.String("retention-size", "", "Max storage size (e.g., 1GB, 500MB)")
 	retentionDays := flag.Int("retention-days", 0, "Max age of logs in days")
-	format := flag.String("format", "auto", "Log format: auto, json, logfmt")
+	format := flag.String("format", "auto", "Log format: auto, access, cef, csv, gelf, journald, json, klog, leef, log4j, logfmt, syslog, tsv")
 	port := flag.Int("port", 0, "HTTP server port")
 	noBrowser := flag.Bool("no-browser", false, "Don't auto-open browser")
+	printURLOnly := flag.Bool("print-url-only", false, "Print the web UI URL instead of opening a browser")
 	all := flag.Bool("all", false, "Show all historic logs (collect mode only)")
+	dedupe := flag.String("dedupe", "", "Skip lines already ingested within this window (e.g., 24h, 7d)")
+	source := flag.String("source", "", "Source recorded on collected entries (e.g., a file path or pod name)")
+	hostMetadata := flag.Bool("host-metadata", false, "Attach this machine's hostname, OS and user to collected entries")
+	advertise := flag.Bool("mdns", false, "Advertise the web UI on the local network as peek-<host>.local")
+	federate := flag.String("federate", "", "Comma-separated peek URLs whose logs queries and live tails include")
+	uiDir := flag.String("ui-dir", "", "Serve the web UI from this directory instead of the embedded one")
+	gelfUDP := flag.String("gelf-udp", "", "Receive GELF messages over UDP on this address (e.g., :12201)")
+	grpcAddr := flag.String("grpc", "", "Serve the gRPC API on this address (e.g., :9090)")
+	debugPipeline := flag.Bool("debug-pipeline", false, "Trace a sample of ingested entries for /pipeline-debug")
+	strict := flag.Bool("strict", false, "Stop at the first line --format can't parse (collect mode only)")
+	name := flag.String("name", "", "Name recorded on the collect session (default: the command piping into peek, where detectable)")
 	help := flag.Bool("help", false, "Show help")
 
 	flag.Parse()
@@ -89,23 +163,61 @@ func main() {
 	if *retentionDays > 0 {
 		cfg.Storage.RetentionDays = *retentionDays
 	}
-	if *format != "auto" {
-		cfg.Parsing.Format = *format
+	applyParsingFlags := func(p *config.ParsingConfig) {
+		if *format != "auto" {
+			// An explicit --format also wins over [parsing.sources].
+			p.Format = *format
+			p.Sources = nil
+		}
+		if *dedupe != "" {
+			p.DedupeWindow = *dedupe
+		}
+		if *hostMetadata {
+			p.HostMetadata = true
+		}
 	}
+	applyParsingFlags(&cfg.Parsing)
+	if err := setParserDefaults(cfg.Parsing); err != nil {
+		log.Fatalf("%v", err)
+	}
+	load := newParsingLoader(*configPath, applyParsingFlags)
 	if *port > 0 {
 		cfg.Server.Port = *port
 	}
 	if *noBrowser {
 		cfg.Server.AutoOpenBrowser = false
 	}
+	cfg.Server.PrintURLOnly = *printURLOnly
+	if *advertise {
+		cfg.Server.MDNS = true
+	}
+	if *federate != "" {
+		cfg.Server.Federate = strings.Split(*federate, ",")
+	}
+	if *uiDir != "" {
+		cfg.Server.UIDir = *uiDir
+	}
+	if *gelfUDP != "" {
+		cfg.Server.GELFUDP = *gelfUDP
+	}
+	if *grpcAddr != "" {
+		cfg.Server.GRPC = *grpcAddr
+	}
+	if *debugPipeline && cfg.Server.DebugPipeline == 0 {
+		cfg.Server.DebugPipeline = defaultPipelineSample
+	}
 
 	// Execute based on mode
 	if mode == "collect" {
-		if err := runCollectMode(cfg, *all); err != nil {
+		sessionName := *name
+		if sessionName == "" {
+			sessionName = upstreamCommand()
+		}
+		if err := runCollectMode(cfg, *all, sessionName, *source, *strict, load); err != nil {
 			log.Fatalf("Collect mode error: %v", err)
 		}
 	} else {
-		if err := runServerMode(cfg); err != nil {
+		if err := runServerMode(cfg, load); err != nil {
 			log.Fatalf("Server mode error: %v", err)
 		}
 	}
@@ -118,8 +230,22 @@ USAGE:
     peek version                         Print build version
     cat app.log | peek [OPTIONS]         Collect logs from stdin (+ embedded web UI)
     peek [OPTIONS]                       Start web UI (browse previously collected logs)
-    peek db stats                        Show database info
+    peek watch [OPTIONS] -- COMMAND      Collect a command's output, restarting it when it exits
+    peek demo [OPTIONS]                  Stream generated sample logs into a throwaway database
+    peek import [OPTIONS] FILE...        Store log files in batches; --resume continues an interrupted import
+    peek forward --to URL [OPTIONS]      Send stdin to a remote peek server through a durable queue
+    peek db stats [--digest|--top-size]  Show database info (top recurring errors, largest entries)
     peek db clean [OPTIONS]              Delete logs from database
+    peek db retention [--simulate]       Apply the retention policy now, or report what it would delete
+    peek db reparse [OPTIONS]            Re-run parsers over stored raw lines
+    peek db verify [--quarantine]        Check entries for corruption and orphaned records
+    peek query [OPTIONS] [QUERY]         Print matching logs (JSON lines or pretty columns)
+    peek repl [--url URL]                Query a running peek server at an interactive prompt
+    peek report [OPTIONS] -o FILE        Write an HTML or Markdown report of matching logs for a ticket
+    peek fields [--output json]          List fields with types and top values
+    peek sessions [--output json]        List collect sessions with entry counts
+    peek audit [OPTIONS]                 List queries run against the API
+    peek reparse-failures [OPTIONS]      Retry lines that failed to parse, storing those that now parse
 
 COLLECT OPTIONS:
     --all                  Show all historic logs alongside new ones (default: only current session)
@@ -127,20 +253,139 @@ COLLECT OPTIONS:
     --db-path PATH         Database path (default: ~/.peek/db)
     --retention-size SIZE  Max storage (e.g., 1GB, 500MB)
     --retention-days DAYS  Max age of logs (e.g., 7, 30)
-    --format FORMAT        auto | json | logfmt (default: auto)
+    --format FORMAT        auto | access | cef | csv | gelf | journald | json | klog | leef | log4j | logfmt | syslog | tsv (default: auto)
+    --strict               Exit non-zero at the first line FORMAT can't parse; exit when stdin ends
+    --dedupe WINDOW        Skip lines already ingested within WINDOW (e.g., 24h, 7d)
+    --source NAME          Record NAME as the source of collected entries (query with source:)
+    --name NAME            Name the session in peek sessions (default: the upstream command, on Linux)
+    --host-metadata        Attach hostname, OS and user to collected entries (host.name, host.os, host.user)
     --port PORT            HTTP port for web UI (default: 8080)
     --no-browser           Don't auto-open browser
+    --print-url-only       Print the web UI URL instead of opening a browser
+    --mdns                 Advertise the web UI on the local network as peek-<host>.local
+    --federate URLS        Include the logs of other peek instances (comma-separated, e.g. http://vm1:8080)
+    --ui-dir DIR           Serve the web UI from DIR (index.html and its /assets/ files)
+    --gelf-udp ADDR        Also receive GELF messages over UDP on ADDR (e.g., :12201)
+    --grpc ADDR            Also serve the gRPC API on ADDR (e.g., :9090)
+    --debug-pipeline       Trace 1 in 10 entries (parser, transforms, latency) at /pipeline-debug
 
 STANDALONE OPTIONS:
     --config FILE      Path to config file (default: ~/.peek/config.toml)
     --db-path PATH     Database path (default: ~/.peek/db)
     --port PORT        HTTP port (default: 8080)
     --no-browser       Don't auto-open browser
+    --print-url-only   Print the web UI URL instead of opening a browser
+    --mdns             Advertise the web UI on the local network as peek-<host>.local
+    --federate URLS    Include the logs of other peek instances (comma-separated, e.g. http://vm1:8080)
+    --ui-dir DIR       Serve the web UI from DIR (index.html and its /assets/ files)
+    --gelf-udp ADDR    Receive GELF messages over UDP on ADDR (e.g., :12201)
+    --grpc ADDR        Serve the gRPC API on ADDR (e.g., :9090)
+    --debug-pipeline   Trace 1 in 10 pushed entries (parser, transforms, latency) at /pipeline-debug
+
+WATCH OPTIONS:
+    --all, --config, --db-path, --format, --dedupe, --host-metadata, --port, --no-browser,
+    --print-url-only, --debug-pipeline
+                           Same as collect mode
+    --source NAME          Source of collected entries (default: the command name)
+    --name NAME            Name of the session (default: the command line)
+    --max-backoff DURATION Longest wait between restarts (default: 30s)
+
+DEMO OPTIONS:
+    --rate N               Live lines per second after the backfill (default: 20; 0 stops)
+    --backfill N           Lines spread over the last hour before streaming (default: 2000)
+    --seed N               Seed for a repeatable stream
+    --port, --no-browser, --print-url-only
+                           Same as collect mode
+
+IMPORT OPTIONS:
+    --config, --db-path    Same as collect mode
+    --format FORMAT        Log format (default: parsing.format)
+    --source NAME          Source of imported entries (default: the file's absolute path)
+    --resume               Continue from the checkpoint an interrupted import left
+    --dedupe WINDOW        Skip lines stored within WINDOW (default: parsing.dedupe_window, else 7d; 0 disables)
+    --batch N              Entries stored per batch and checkpoint (default: 500)
+    --quiet                Don't print progress
+
+FORWARD OPTIONS:
+    --to URL               Peek server to send lines to (required)
+    --token TOKEN          API token sent as a bearer token
+    --format FORMAT        auto | access | cef | csv | gelf | journald | json | klog | leef | log4j | logfmt | syslog | tsv, parsed by the server (default: auto)
+    --namespace NAME       Namespace for forwarded entries (admin tokens only)
+    --queue-path PATH      Durable local queue (default: ~/.peek/forward-queue)
+    --queue-size SIZE      Queue cap; the oldest lines are dropped beyond it (default: 64MB)
+    --batch N              Lines per request (default: 500)
+    --gzip                 Gzip request bodies (the server must accept Content-Encoding: gzip)
+    --max-backoff DURATION Longest wait between retries (default: 30s)
+
+DB STATS OPTIONS:
+    --digest               Show the top recurring ERROR/WARN message patterns
+    --window DURATION      Digest window (default: 1h; e.g., 30m, 7d)
+    --top-size N           List the N largest entries and the fields that make them large (default: 0, off)
+    --output FORMAT        text | json (default: text)
 
 DB CLEAN OPTIONS:
     --older-than DURATION  Delete logs older than duration (e.g., 24h, 7d, 2w)
     --level LEVEL          Delete only logs matching level (e.g., DEBUG)
     --force                Skip confirmation prompt
+    --dry-run              Report what would be deleted (count, size, per level and source) without deleting
+    --output FORMAT        text | json (default: text; json requires --force or --dry-run)
+    --quiet                Don't print progress
+
+DB RETENTION OPTIONS:
+    --retention-size SIZE  Size cap to apply instead of the configured one (e.g., 500MB)
+    --retention-days DAYS  Age limit to apply instead of the configured one
+    --simulate             Report what the policy would delete without deleting
+    --force                Skip confirmation prompt
+    --output FORMAT        text | json (default: text; json requires --force or --simulate)
+    --quiet                Don't print progress
+
+DB REPARSE OPTIONS:
+    --query QUERY          Only reparse entries matching the query (default: all)
+    --format FORMAT        auto | access | cef | csv | gelf | journald | json | klog | leef | log4j | logfmt | syslog | tsv (default: auto)
+    --output FORMAT        text | json (default: text)
+    --quiet                Don't print progress
+
+DB VERIFY OPTIONS:
+    --quarantine           Move corrupt and orphaned records under quarantine: keys
+
+QUERY OPTIONS:
+    --view NAME            Apply a saved view's query and time range
+    --limit N              Maximum entries to print (default: 100)
+    --output FORMAT        json | pretty (default: json)
+    --fields LIST          Comma-separated field columns for pretty output
+    --no-color             Disable colors in pretty output (also honors NO_COLOR)
+
+REPL OPTIONS:
+    --url URL              Peek server to query (default: http://localhost:PORT from the config)
+    --token TOKEN          API token sent as a bearer token
+    --page N               Entries per page; Enter on an empty line shows the next (default: 20)
+    --fields LIST          Comma-separated field columns
+    --history FILE         Query history kept across sessions (default: ~/.peek/repl_history)
+    --no-color             Disable colors (also honors NO_COLOR)
+
+REPORT OPTIONS:
+    --query QUERY          Only report entries matching the query (default: all)
+    --start TIME           Start of the range: RFC 3339 time, date, or duration ago (e.g., 2h)
+    --end TIME             End of the range (default: now)
+    -o FILE                File to write (default: stdout)
+    --format FORMAT        html | md (default: md for .md files, else html)
+    --title TITLE          Report title (default: the query and range)
+    --limit N              Most recent matching entries to include (default: 50)
+    --patterns N           Top ERROR/WARN patterns to include (default: 10)
+
+FIELDS / SESSIONS OPTIONS:
+    --output FORMAT        text | json (default: text; json prints one object per line)
+
+AUDIT OPTIONS:
+    --since DURATION       Only queries run within DURATION (e.g., 1h, 7d)
+    --limit N              Maximum queries to print (default: 50; 0 for all)
+    --output FORMAT        text | json (default: text)
+
+REPARSE-FAILURES OPTIONS:
+    --format FORMAT        Parse with FORMAT instead of the format each line failed with
+    --failed-format NAME   Only retry lines that failed with format NAME
+    --dry-run              Report what would be recovered without storing anything
+    --output FORMAT        text | json (default: text)
 
 EXAMPLES:
     # Collect and view logs in real time (fresh mode - only current session)
@@ -149,15 +394,33 @@ EXAMPLES:
     # Collect and view all historic logs alongside new ones
     cat app.log | peek --all
 
+    # Re-run a pipeline without doubling the database
+    cat app.log | peek --all --dedupe 24h
+
     # Collect from a running process with custom port
     kubectl logs my-pod -f | peek --port 8081
 
+    # Keep following a pod across restarts and dropped connections
+    peek watch -- kubectl logs -f my-pod
+
     # Browse previously collected logs
     peek
 
+    # Try peek without a log source
+    peek demo
+
+    # Import archived logs, continuing where an interrupted run stopped
+    peek import --resume /var/log/app/app.log.1 /var/log/app/app.log
+
+    # Ship a host's logs to a central peek, surviving network blips
+    journalctl -f -o json | peek forward --to http://logs.internal:8080 --token $PEEK_TOKEN
+
     # Show database info
     peek db stats
 
+    # What's been failing in the last 6 hours
+    peek db stats --digest --window 6h
+
     # Delete all logs (with confirmation)
     peek db clean
 
@@ -167,6 +430,28 @@ EXAMPLES:
     # Delete debug logs
     peek db clean --level DEBUG
 
+    # See what a tighter retention policy would delete
+    peek db retention --simulate --retention-days 3
+
+    # Pick up parser improvements for plain lines that contain key=value pairs
+    peek db reparse --query 'level:INFO AND message:*=*'
+
+    # Check the database and set aside anything broken
+    peek db verify --quarantine
+
+    # Print errors from a saved view
+    peek query --view "Payments errors" service:payments
+
+    # Attach the last two hours of checkout errors to an incident
+    peek report --query 'service:checkout AND level:ERROR' --start 2h -o incident.html
+
+    # Script against the database with jq
+    peek db stats --output json | jq .total_logs
+    peek fields --output json | jq -r 'select(.high_cardinality) | .name'
+
+    # Read errors in the terminal with a service column
+    peek query --output pretty --fields service level:ERROR
+
 For more information: https://github.com/mchurichi/peek`)
 }
 
@@ -188,7 +473,7 @@ func validateNoPositionalArgs(args []string) error {
 
 func runDbCommand(args []string) error {
 	if len(args) == 0 {
-		fmt.Println("Usage: peek db [stats|clean]")
+		fmt.Println("Usage: peek db [stats|clean|retention|reparse|verify]")
 		return fmt.Errorf("missing db subcommand")
 	}
 
@@ -198,6 +483,12 @@ func runDbCommand(args []string) error {
 		return runDbStats(args[1:])
 	case "clean":
 		return runDbClean(args[1:])
+	case "retention":
+		return runDbRetention(args[1:])
+	case "reparse":
+		return runDbReparse(args[1:])
+	case "verify":
+		return runDbVerify(args[1:])
 	default:
 		return fmt.Errorf("unknown db subcommand: %s", subcommand)
 	}
@@ -207,8 +498,28 @@ func runDbStats(args []string) error {
 	fs := flag.NewFlagSet("db stats", flag.ExitOnError)
 	configPath := fs.String("config", "~/.peek/config.toml", "Path to config file")
 	dbPath := fs.String("db-path", "", "Database path (overrides config)")
+	digest := fs.Bool("digest", false, "Also show the top recurring ERROR/WARN message patterns")
+	window := fs.String("window", "1h", "Digest window (e.g., 30m, 1h, 7d)")
+	topSize := fs.Int("top-size", 0, "Also list the N largest entries and the fields that make them large")
+	output := fs.String("output", outputText, "Output format: text or json (a single JSON line)")
 	fs.Parse(args)
 
+	if err := checkTextOrJSON(*output); err != nil {
+		return err
+	}
+	if *topSize < 0 {
+		return fmt.Errorf("--top-size must not be negative")
+	}
+
+	var digestWindow time.Duration
+	if *digest {
+		d, err := parseDuration(*window)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid --window %q", *window)
+		}
+		digestWindow = d
+	}
+
 	// Load configuration
 	cfg, err := config.Load(*configPath)
 	if err != nil {
@@ -221,10 +532,9 @@ func runDbStats(args []string) error {
 	}
 
 	// Initialize storage
-	storageCfg := storage.Config{
-		DBPath:        expandPath(cfg.Storage.DBPath),
-		RetentionSize: cfg.GetRetentionSizeBytes(),
-		RetentionDays: cfg.Storage.RetentionDays,
+	storageCfg, err := newStorageConfig(cfg)
+	if err != nil {
+		return err
 	}
 
 	db, err := storage.NewBadgerStorage(storageCfg)
@@ -244,6 +554,30 @@ func runDbStats(args []string) error {
 		return fmt.Errorf("failed to get oldest/newest: %w", err)
 	}
 
+	var patterns []storage.DigestPattern
+	if *digest {
+		patterns, err = db.GetDigest(context.Background(), time.Now().Add(-digestWindow), nil, 10)
+		if err != nil {
+			return fmt.Errorf("failed to get digest: %w", err)
+		}
+	}
+
+	var largest []storage.EntrySize
+	if *topSize > 0 {
+		largest, err = db.GetLargestEntries(context.Background(), nil, nil, *topSize)
+		if err != nil {
+			return err
+		}
+	}
+
+	if *output == outputJSON {
+		report := statsReport{Path: db.GetDBPath(), Stats: stats, Digest: patterns, Largest: largest}
+		if !oldest.IsZero() {
+			report.Oldest, report.Newest = &oldest, &newest
+		}
+		return writeJSONLine(os.Stdout, report)
+	}
+
 	// Print stats
 	fmt.Println("Database Statistics")
 	fmt.Println("===================")
@@ -262,10 +596,113 @@ func runDbStats(args []string) error {
 			fmt.Printf("  %s: %d\n", level, count)
 		}
 	}
+	printStorageStats(os.Stdout, stats)
+	printVolume(os.Stdout, "sources", stats.Sources)
+	printVolume(os.Stdout, "sessions", stats.Sessions)
+
+	if *digest {
+		printDigest(os.Stdout, patterns, *window)
+	}
+	if *topSize > 0 {
+		printLargest(os.Stdout, largest)
+	}
 
 	return nil
 }
 
+// statsReport is the JSON form of peek db stats: the storage stats plus the
+// database path, entry time bounds and, with --digest and --top-size, the
+// top patterns and largest entries.
+type statsReport struct {
+	Path   string     `json:"path"`
+	Oldest *time.Time `json:"oldest,omitempty"`
+	Newest *time.Time `json:"newest,omitempty"`
+	storage.Stats
+	Digest  []storage.DigestPattern `json:"digest,omitempty"`
+	Largest []storage.EntrySize     `json:"largest_entries,omitempty"`
+}
+
+// printStorageStats writes the on-disk breakdown and size forecast.
+func printStorageStats(w io.Writer, stats storage.Stats) {
+	const mb = 1024 * 1024
+	fmt.Fprintln(w, "\nStorage:")
+	fmt.Fprintf(w, "  LSM tree:        %.2f MB\n", float64(stats.LSMSizeBytes)/mb)
+	fmt.Fprintf(w, "  Value log:       %.2f MB\n", float64(stats.VlogSizeBytes)/mb)
+	fmt.Fprintf(w, "  Reclaimable:     ~%.2f MB (freed by compaction)\n", float64(stats.ReclaimableBytes)/mb)
+	fmt.Fprintf(w, "  Avg entry size:  %.0f bytes\n", stats.AvgEntryBytes)
+	fmt.Fprintf(w, "  Ingest rate:     %d entries/hour (last hour)\n", stats.IngestRatePerHour)
+	if stats.RetentionSizeBytes <= 0 {
+		fmt.Fprintln(w, "  Retention cap:   none")
+		return
+	}
+	fmt.Fprintf(w, "  Retention cap:   %.2f MB\n", float64(stats.RetentionSizeBytes)/mb)
+	if stats.DaysUntilFull == nil {
+		fmt.Fprintln(w, "  Cap reached in:  n/a (no recent ingest)")
+		return
+	}
+	fmt.Fprintf(w, "  Cap reached in:  ~%.1f days\n", *stats.DaysUntilFull)
+}
+
+// volumeRows is how many sources and sessions peek db stats prints.
+const volumeRows = 10
+
+// printVolume writes the largest sources or sessions by stored bytes.
+func printVolume(w io.Writer, kind string, groups []storage.VolumeStats) {
+	if len(groups) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nLargest %s:\n", kind)
+	for _, g := range groups[:min(len(groups), volumeRows)] {
+		name := g.Name
+		if name == "" {
+			name = "(none)"
+		}
+		fmt.Fprintf(w, "  %9s  %8d entries  %s\n", formatBytes(g.Bytes), g.Count, name)
+	}
+}
+
+// printDigest writes the top message patterns as an aligned table.
+func printDigest(w io.Writer, patterns []storage.DigestPattern, window string) {
+	fmt.Fprintf(w, "\nTop errors (last %s):\n", window)
+	if len(patterns) == 0 {
+		fmt.Fprintln(w, "  none")
+		return
+	}
+	for _, p := range patterns {
+		fmt.Fprintf(w, "  %6d  %-5s  %s  (first %s, last %s)\n",
+			p.Count, p.Level, p.Pattern,
+			p.FirstSeen.Local().Format(time.DateTime), p.LastSeen.Local().Format(time.DateTime))
+	}
+}
+
+// printLargest writes the largest entries with their biggest parts.
+func printLargest(w io.Writer, entries []storage.EntrySize) {
+	fmt.Fprintln(w, "\nLargest entries:")
+	if len(entries) == 0 {
+		fmt.Fprintln(w, "  none")
+		return
+	}
+	for _, e := range entries {
+		parts := make([]string, len(e.Parts))
+		for i, p := range e.Parts {
+			parts[i] = fmt.Sprintf("%s %s", p.Name, formatBytes(p.Bytes))
+		}
+		fmt.Fprintf(w, "  %9s  %s  %s  %s\n", formatBytes(e.Bytes), e.ID, e.Timestamp.Local().Format(time.DateTime), e.Message)
+		fmt.Fprintf(w, "             %s\n", strings.Join(parts, ", "))
+	}
+}
+
+// formatBytes renders n in B, KB or MB.
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+	case n >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(n)/1024)
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
 func runDbClean(args []string) error {
 	fs := flag.NewFlagSet("db clean", flag.ExitOnError)
 	configPath := fs.String("config", "~/.peek/config.toml", "Path to config file")
@@ -273,8 +710,18 @@ func runDbClean(args []string) error {
 	olderThan := fs.String("older-than", "", "Delete logs older than duration (e.g., 24h, 7d, 2w)")
 	level := fs.String("level", "", "Delete only logs matching level (e.g., DEBUG)")
 	force := fs.Bool("force", false, "Skip confirmation prompt")
+	output := fs.String("output", outputText, "Output format: text or json (requires --force or --dry-run)")
+	quiet := fs.Bool("quiet", false, "Don't print progress")
+	dryRun := fs.Bool("dry-run", false, "Report what would be deleted without deleting")
 	fs.Parse(args)
 
+	if err := checkTextOrJSON(*output); err != nil {
+		return err
+	}
+	if *output == outputJSON && !*force && !*dryRun {
+		return fmt.Errorf("--output json requires --force or --dry-run")
+	}
+
 	// Load configuration
 	cfg, err := config.Load(*configPath)
 	if err != nil {
@@ -287,10 +734,13 @@ func runDbClean(args []string) error {
 	}
 
 	// Initialize storage
-	storageCfg := storage.Config{
-		DBPath:        expandPath(cfg.Storage.DBPath),
-		RetentionSize: cfg.GetRetentionSizeBytes(),
-		RetentionDays: cfg.Storage.RetentionDays,
+	storageCfg, err := newStorageConfig(cfg)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		// Opening the database sweeps retention; a dry run must not delete.
+		storageCfg.RetentionSize, storageCfg.RetentionDays = 0, 0
 	}
 
 	db, err := storage.NewBadgerStorage(storageCfg)
@@ -299,6 +749,30 @@ func runDbClean(args []string) error {
 	}
 	defer db.Close()
 
+	if *dryRun {
+		var plan storage.DeletePlan
+		switch {
+		case *level != "":
+			plan, err = db.PlanDeleteByLevel(*level)
+		case *olderThan != "":
+			duration, perr := parseDuration(*olderThan)
+			if perr != nil {
+				return fmt.Errorf("invalid duration: %w", perr)
+			}
+			plan, err = db.PlanDeleteOlderThan(time.Now().Add(-duration))
+		default:
+			plan, err = db.PlanDeleteAll()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to plan deletion: %w", err)
+		}
+		if *output == outputJSON {
+			return writeJSONLine(os.Stdout, plan)
+		}
+		printDeletePlan(os.Stdout, plan)
+		return nil
+	}
+
 	// Get stats before deletion
 	stats, err := db.GetStats()
 	if err != nil {
@@ -318,6 +792,9 @@ func runDbClean(args []string) error {
 			}
 			confirmMsg = fmt.Sprintf("This will delete %d log entries with level %s (%.2f MB estimated) and then attempt to reclaim disk space. Continue?", count, *level, estimatedSize)
 		} else {
+			if *output == outputJSON {
+				return writeJSONLine(os.Stdout, cleanReport{})
+			}
 			fmt.Printf("No logs found with level %s\n", *level)
 			return nil
 		}
@@ -345,7 +822,10 @@ func runDbClean(args []string) error {
 		}
 	}
 
-	// Perform deletion
+	// Perform deletion, reporting progress on stderr while filtered deletes
+	// walk the entries. Deleting everything drops whole prefixes at once.
+	progress := newProgress(os.Stderr, "deleting", "entries", deleteCount, *output, *quiet)
+	db.SetProgress(progress.update)
 	var deleted int
 	if *level != "" {
 		deleted, err = db.DeleteByLevel(*level)
@@ -366,15 +846,34 @@ func runDbClean(args []string) error {
 			return fmt.Errorf("failed to delete all: %w", err)
 		}
 	}
+	progress.finish(deleted)
 
-	fmt.Printf("Deleted %d entries.\n", deleted)
+	if *output == outputText {
+		fmt.Printf("Deleted %d entries.\n", deleted)
+	}
+	return compactAfterDelete(db, deleted, *output, *quiet)
+}
 
-	// Compact database until there is no more reclaimable value-log data.
+// compactAfterDelete compacts the database until there is no more
+// reclaimable value-log data and prints the outcome, or with --output json
+// a cleanReport of deleted entries and the compaction.
+func compactAfterDelete(db *storage.BadgerStorage, deleted int, output string, quiet bool) error {
+	progress := newProgress(os.Stderr, "compacting", "GC passes", 0, output, quiet)
+	db.SetProgress(progress.update)
 	compaction, err := db.CompactDatabaseFully()
+	progress.finish(compaction.Passes)
 	if err != nil {
 		log.Printf("Warning: Failed to fully compact database: %v", err)
 	}
 
+	if output == outputJSON {
+		report := cleanReport{Deleted: deleted, Compaction: compaction}
+		if err != nil {
+			report.CompactionError = err.Error()
+		}
+		return writeJSONLine(os.Stdout, report)
+	}
+
 	beforeMB := float64(compaction.BeforeBytes) / (1024 * 1024)
 	afterMB := float64(compaction.AfterBytes) / (1024 * 1024)
 	reclaimedMB := float64(compaction.ReclaimedBytes) / (1024 * 1024)
@@ -402,6 +901,348 @@ func runDbClean(args []string) error {
 	return nil
 }
 
+// cleanReport is the JSON form of the peek db clean summary.
+type cleanReport struct {
+	Deleted         int                      `json:"deleted"`
+	Compaction      storage.CompactionResult `json:"compaction"`
+	CompactionError string                   `json:"compaction_error,omitempty"`
+}
+
+func runDbReparse(args []string) error {
+	fs := flag.NewFlagSet("db reparse", flag.ExitOnError)
+	configPath := fs.String("config", "~/.peek/config.toml", "Path to config file")
+	dbPath := fs.String("db-path", "", "Database path (overrides config)")
+	queryStr := fs.String("query", "", "Only reparse entries matching this query")
+	format := fs.String("format", "auto", "Log format: auto, access, cef, csv, gelf, journald, json, klog, leef, log4j, logfmt, syslog, tsv")
+	output := fs.String("output", outputText, "Output format: text or json")
+	quiet := fs.Bool("quiet", false, "Don't print progress")
+	fs.Parse(args)
+
+	if err := validateNoPositionalArgs(fs.Args()); err != nil {
+		return err
+	}
+	if err := checkTextOrJSON(*output); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if *dbPath != "" {
+		cfg.Storage.DBPath = *dbPath
+	}
+	maxValueSize, err := newMaxValueSize(cfg.Parsing)
+	if err != nil {
+		return err
+	}
+	detector, err := newDetector(cfg.Parsing)
+	if err != nil {
+		return err
+	}
+	if err := setParserDefaults(cfg.Parsing); err != nil {
+		return err
+	}
+
+	storageCfg, err := newStorageConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	db, err := storage.NewBadgerStorage(storageCfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer db.Close()
+
+	var progress *progressReporter
+	if !*quiet {
+		// Reparse visits every entry, so the entry count is the total work.
+		stats, err := db.GetStats()
+		if err != nil {
+			return fmt.Errorf("failed to get stats: %w", err)
+		}
+		progress = newProgress(os.Stderr, "reparsing", "entries", stats.TotalLogs, *output, false)
+	}
+
+	return runReparse(os.Stdout, db, detector, *queryStr, *format, maxValueSize, *output, progress)
+}
+
+// runReparse re-parses the raw lines of entries matching queryStr with
+// detector's parsers, truncating values beyond maxValueSize (0 for none), and
+// prints a summary in output format, reporting progress to progress (nil for
+// none).
+func runReparse(w io.Writer, db *storage.BadgerStorage, detector *parser.Detector, queryStr, format string, maxValueSize int, output string, progress *progressReporter) error {
+	var filter storage.Filter
+	if queryStr != "" {
+		q, err := query.Parse(queryStr)
+		if err != nil {
+			return fmt.Errorf("invalid query: %w", err)
+		}
+		filter = q
+	}
+
+	if !detector.ValidFormat(format) {
+		return fmt.Errorf("invalid --format %q (use %s)", format, strings.Join(detector.Formats(), ", "))
+	}
+
+	db.SetProgress(progress.update)
+	defer db.SetProgress(nil)
+	res, err := db.Reparse(context.Background(), filter, func(raw string) (*storage.LogEntry, error) {
+		// Raw lines of joined multiline entries are split again so the
+		// first line alone is parsed, as it was at ingest.
+		entry, err := detector.ParseLines(strings.Split(raw, "\n"), format)
+		if err == nil {
+			parser.Truncate(entry, maxValueSize)
+		}
+		return entry, err
+	})
+	if err != nil {
+		return err
+	}
+	if progress != nil {
+		progress.finish(progress.total)
+	}
+
+	if output == outputJSON {
+		return writeJSONLine(w, res)
+	}
+
+	fmt.Fprintf(w, "Reparsed %d matching entries: %d updated, %d unchanged, %d skipped.\n",
+		res.Matched, res.Updated, res.Unchanged, res.Skipped)
+	return nil
+}
+
+func runDbVerify(args []string) error {
+	fs := flag.NewFlagSet("db verify", flag.ExitOnError)
+	configPath := fs.String("config", "~/.peek/config.toml", "Path to config file")
+	dbPath := fs.String("db-path", "", "Database path (overrides config)")
+	quarantine := fs.Bool("quarantine", false, "Move corrupt and orphaned records under quarantine: keys")
+	fs.Parse(args)
+
+	if err := validateNoPositionalArgs(fs.Args()); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if *dbPath != "" {
+		cfg.Storage.DBPath = *dbPath
+	}
+
+	storageCfg, err := newStorageConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	db, err := storage.NewBadgerStorage(storageCfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer db.Close()
+
+	return runVerify(os.Stdout, db, *quarantine)
+}
+
+// maxVerifyIssues bounds how many issues runVerify lists individually.
+const maxVerifyIssues = 50
+
+// runVerify checks the database and prints its findings. It returns an error
+// when issues remain, so scripts can rely on the exit status.
+func runVerify(w io.Writer, db *storage.BadgerStorage, quarantine bool) error {
+	res, err := db.Verify(context.Background(), quarantine)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Checked %d entries, found %d issues.\n", res.Checked, len(res.Issues))
+	for i, issue := range res.Issues {
+		if i == maxVerifyIssues {
+			fmt.Fprintf(w, "  ... and %d more\n", len(res.Issues)-maxVerifyIssues)
+			break
+		}
+		if issue.Detail != "" {
+			fmt.Fprintf(w, "  %-18s  %s  (%s)\n", issue.Kind, issue.Key, issue.Detail)
+		} else {
+			fmt.Fprintf(w, "  %-18s  %s\n", issue.Kind, issue.Key)
+		}
+	}
+
+	if quarantine {
+		fmt.Fprintf(w, "Quarantined %d records.\n", res.Quarantined)
+		return nil
+	}
+	if len(res.Issues) > 0 {
+		return fmt.Errorf("%d issues found (rerun with --quarantine to set them aside)", len(res.Issues))
+	}
+	return nil
+}
+
+// newDedupeWindow parses parsing.dedupe_window; empty disables deduplication.
+func newDedupeWindow(cfg *config.Config) (time.Duration, error) {
+	if cfg.Parsing.DedupeWindow == "" {
+		return 0, nil
+	}
+	d, err := parseDuration(cfg.Parsing.DedupeWindow)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid dedupe window %q", cfg.Parsing.DedupeWindow)
+	}
+	return d, nil
+}
+
+// newMaxValueSize parses parsing.max_value_size; 0 means values are kept
+// whole.
+func newMaxValueSize(p config.ParsingConfig) (int, error) {
+	if p.MaxValueSize == "" {
+		return 0, nil
+	}
+	n, err := config.ParseSize(p.MaxValueSize)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid max value size %q", p.MaxValueSize)
+	}
+	return int(n), nil
+}
+
+// newUIConfig maps the [ui] config section to the defaults served to the web UI.
+func newUIConfig(cfg *config.Config) server.UIConfig {
+	return server.UIConfig{
+		DefaultTimePreset: cfg.UI.DefaultTimePreset,
+		DefaultQuery:      cfg.UI.DefaultQuery,
+		PinnedColumns:     cfg.UI.PinnedColumns,
+		Theme:             cfg.UI.Theme,
+		AutoScroll:        cfg.UI.AutoScroll,
+	}
+}
+
+// newServerTokens converts configured API tokens for the server.
+func newServerTokens(cfg *config.Config) []server.Token {
+	tokens := make([]server.Token, len(cfg.Auth.Tokens))
+	for i, t := range cfg.Auth.Tokens {
+		tokens[i] = server.Token{Token: t.Token, Namespace: t.Namespace, Admin: t.Admin}
+	}
+	return tokens
+}
+
+// newServerPeers converts the configured federation peers for the server.
+func newServerPeers(s config.ServerConfig) []server.Peer {
+	var peers []server.Peer
+	for _, u := range s.Federate {
+		if u = strings.TrimSpace(u); u != "" {
+			peers = append(peers, server.Peer{URL: u, Token: s.FederateToken})
+		}
+	}
+	return peers
+}
+
+// newServerSecurity converts the server's browser protection settings.
+func newServerSecurity(s config.ServerConfig) server.SecurityConfig {
+	return server.SecurityConfig{
+		FrameAncestors:        s.FrameAncestors,
+		ContentSecurityPolicy: s.ContentSecurityPolicy,
+		AllowedOrigins:        s.AllowedOrigins,
+	}
+}
+
+// listenGELF starts the GELF UDP listener when server.gelf_udp is set.
+func listenGELF(srv *server.Server, s config.ServerConfig) error {
+	if s.GELFUDP == "" {
+		return nil
+	}
+	if _, err := srv.ListenGELF(s.GELFUDP); err != nil {
+		return err
+	}
+	return nil
+}
+
+// listenGRPC starts the gRPC API when server.grpc is set.
+func listenGRPC(srv *server.Server, s config.ServerConfig) error {
+	if s.GRPC == "" {
+		return nil
+	}
+	if _, err := srv.ListenGRPC(s.GRPC); err != nil {
+		return err
+	}
+	return nil
+}
+
+// defaultPipelineSample is the rate --debug-pipeline traces entries at
+// when server.debug_pipeline doesn't set one: one in every 10.
+const defaultPipelineSample = 10
+
+// enablePipelineDebug enables pipeline tracing when server.debug_pipeline
+// is set.
+func enablePipelineDebug(srv *server.Server, s config.ServerConfig) error {
+	switch {
+	case s.DebugPipeline < 0:
+		return fmt.Errorf("invalid server.debug_pipeline %d (use a positive sampling rate, or 0 to disable)", s.DebugPipeline)
+	case s.DebugPipeline == 0:
+		return nil
+	}
+	srv.SetPipelineDebug(s.DebugPipeline)
+	log.Printf("Tracing 1 in %d ingested entries at /pipeline-debug", s.DebugPipeline)
+	return nil
+}
+
+// newAuditRetention returns how long audit records are kept, or 0 when the
+// audit log is disabled.
+func newAuditRetention(a config.AuditConfig) (time.Duration, error) {
+	if !a.Enabled {
+		return 0, nil
+	}
+	retention, err := parseDuration(a.Retention)
+	if err != nil || retention <= 0 {
+		return 0, fmt.Errorf("invalid audit retention %q", a.Retention)
+	}
+	return retention, nil
+}
+
+// newStorageConfig builds the storage configuration from the loaded config.
+func newStorageConfig(cfg *config.Config) (storage.Config, error) {
+	storageCfg := storage.Config{
+		DBPath:        expandPath(cfg.Storage.DBPath),
+		RetentionSize: cfg.GetRetentionSizeBytes(),
+		RetentionDays: cfg.Storage.RetentionDays,
+		Badger: storage.BadgerTuning{
+			DisableSyncWrites: !cfg.Storage.Badger.SyncWrites,
+			Compression:       cfg.Storage.Badger.Compression,
+			NumCompactors:     cfg.Storage.Badger.NumCompactors,
+		},
+	}
+
+	if v := cfg.Storage.MinFreeSpace; v != "" {
+		size, err := config.ParseSize(v)
+		if err != nil {
+			return storageCfg, fmt.Errorf("invalid storage min_free_space: %w", err)
+		}
+		storageCfg.MinFreeBytes = size
+	}
+	if v := cfg.Storage.MaintenanceWindow; v != "" {
+		w, err := storage.ParseMaintenanceWindow(v)
+		if err != nil {
+			return storageCfg, fmt.Errorf("invalid storage maintenance_window: %w", err)
+		}
+		storageCfg.MaintenanceWindow = w
+	}
+	if v := cfg.Storage.Badger.ValueLogFileSize; v != "" {
+		size, err := config.ParseSize(v)
+		if err != nil {
+			return storageCfg, fmt.Errorf("invalid badger value_log_file_size: %w", err)
+		}
+		storageCfg.Badger.ValueLogFileSize = size
+	}
+	if v := cfg.Storage.Badger.MemTableSize; v != "" {
+		size, err := config.ParseSize(v)
+		if err != nil {
+			return storageCfg, fmt.Errorf("invalid badger memtable_size: %w", err)
+		}
+		storageCfg.Badger.MemTableSize = size
+	}
+
+	return storageCfg, nil
+}
+
 func parseDuration(s string) (time.Duration, error) {
 	// Support Go durations (24h) and shorthand (7d, 2w)
 	if strings.HasSuffix(s, "d") {
@@ -421,14 +1262,62 @@ func parseDuration(s string) (time.Duration, error) {
 	return time.ParseDuration(s)
 }
 
-func runCollectMode(cfg *config.Config, showAll bool) error {
+func runCollectMode(cfg *config.Config, showAll bool, name, source string, strict bool, load parsingLoader) error {
+	return collect(cfg, showAll, name, load, func(c *collector) error {
+		c.source = source
+		c.strict = strict
+		if strict && c.currentSettings().formatFor(source) == "auto" {
+			return errors.New("--strict needs an explicit --format (auto mode stores unrecognized lines as raw text)")
+		}
+		// Watch for signals from the start: a SIGTERM while piping stops
+		// intake, and every line already read is stored before shutdown.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		// Archived logs can be piped as they are: gzip and zstd input is
+		// decompressed on the fly.
+		stdin := newDecompressReader(os.Stdin)
+		stdin.detected = func(name string) { log.Printf("Decompressing %s input from stdin", name) }
+
+		c.srv.SetSourceStatus("stdin", server.SourceRunning, nil)
+		err := c.readFrom(ctx, stdin)
+		if err != nil {
+			c.srv.SetSourceStatus("stdin", server.SourceFailed, err)
+		} else {
+			c.srv.SetSourceStatus("stdin", server.SourceStopped, nil)
+		}
+		c.finish()
+		var lineErr *lineParseError
+		if errors.As(err, &lineErr) {
+			return fmt.Errorf("strict mode: %w", err)
+		}
+		if err != nil {
+			return fmt.Errorf("error reading stdin: %w", err)
+		}
+		// Strict runs check a log format contract, e.g. in CI, so they end
+		// with the input instead of keeping the UI up.
+		if ctx.Err() == nil && !strict {
+			log.Printf("Server still running at http://localhost:%d — press Ctrl+C to exit", cfg.Server.Port)
+
+			// Keep server alive after stdin closes so user can still browse logs
+			<-ctx.Done()
+		}
+		log.Println("Shutting down...")
+		return nil
+	})
+}
+
+// collect opens storage, starts the embedded server for one collect session
+// and hands a collector to feed, which supplies the input lines. A non-nil
+// load enables live reload of the parsing config. Once feed returns, the
+// server and background workers are stopped before storage is closed.
+func collect(cfg *config.Config, showAll bool, name string, load parsingLoader, feed func(c *collector) error) error {
 	log.Println("Starting collect mode...")
 
 	// Initialize storage (single instance shared with embedded server)
-	storageCfg := storage.Config{
-		DBPath:        expandPath(cfg.Storage.DBPath),
-		RetentionSize: cfg.GetRetentionSizeBytes(),
-		RetentionDays: cfg.Storage.RetentionDays,
+	storageCfg, err := newStorageConfig(cfg)
+	if err != nil {
+		return err
 	}
 
 	db, err := storage.NewBadgerStorage(storageCfg)
@@ -437,106 +1326,456 @@ func runCollectMode(cfg *config.Config, showAll bool) error {
 	}
 	defer db.Close()
 
-	// Record start time for fresh mode
-	var startTime *time.Time
-	if !showAll {
-		// For fresh mode, use the newest entry's timestamp as the baseline
-		// Only show logs newer than what's already in the database
-		_, newest, err := db.GetOldestNewest()
-		if err == nil && !newest.IsZero() {
-			// Use newest timestamp as baseline (logs must be newer than this)
-			baseline := newest.Add(1 * time.Nanosecond)
-			startTime = &baseline
-			log.Printf("Fresh mode: showing logs newer than %s", newest.Format(time.RFC3339))
-		} else {
-			// Database is empty, show all logs (no filter)
-			log.Println("Fresh mode: showing all logs (database is empty)")
+	settings, err := newIngestSettings(cfg.Parsing)
+	if err != nil {
+		return err
+	}
+	auditRetention, err := newAuditRetention(cfg.Audit)
+	if err != nil {
+		return err
+	}
+
+	// Every entry collected in this run is tagged with the session ID. Fresh
+	// mode filters by session so logs with historical timestamps still show.
+	session := newSessionID()
+	if name != "" {
+		if err := db.SetSessionName(session, name); err != nil {
+			log.Printf("Warning: Failed to name the session: %v", err)
 		}
+		log.Printf("Session %s: %s", session, name)
+	}
+	freshSession := ""
+	if !showAll {
+		freshSession = session
+		log.Printf("Fresh mode: showing logs from session %s", session)
 	} else {
 		log.Println("Showing all historic logs alongside new ones")
 	}
 
 	// Start embedded server for real-time viewing
-	srv := server.NewServer(db, startTime)
+	srv := server.NewServer(db, freshSession)
+	srv.SetUIConfig(newUIConfig(cfg))
+	srv.SetSecurity(newServerSecurity(cfg.Server))
+	if err := srv.SetUIDir(cfg.Server.UIDir); err != nil {
+		return err
+	}
+	if err := srv.SetTokens(newServerTokens(cfg)); err != nil {
+		return fmt.Errorf("invalid auth config: %w", err)
+	}
+	if err := srv.SetPeers(newServerPeers(cfg.Server)); err != nil {
+		return fmt.Errorf("invalid federate config: %w", err)
+	}
+	srv.SetIDGenerator(settings.newID)
+	srv.SetDedupeWindow(settings.dedupeWindow)
+	srv.SetMaxValueSize(settings.maxValueSize)
+	srv.SetDetector(settings.detector)
+	srv.SetSourceFormats(settings.sourceFormats)
+	srv.SetAuditRetention(auditRetention)
 	srv.StartBroadcastWorker()
 
+	ctx, cancel := context.WithCancel(context.Background())
+	sched := scheduler.New(db, scheduler.DefaultTick)
+	sched.Start(ctx)
+	defer stopServices(srv, cancel, sched)
+	if err := listenGELF(srv, cfg.Server); err != nil {
+		return err
+	}
+	if err := listenGRPC(srv, cfg.Server); err != nil {
+		return err
+	}
+	if err := enablePipelineDebug(srv, cfg.Server); err != nil {
+		return err
+	}
+
 	go func() {
-		if err := srv.Start(cfg.Server.Port); err != nil {
+		if err := srv.Start(cfg.Server.Port); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Printf("Server error: %v", err)
 		}
 	}()
 
-	if cfg.Server.AutoOpenBrowser {
-		url := fmt.Sprintf("http://localhost:%d", cfg.Server.Port)
-		go openBrowser(url)
-	}
+	openUI(cfg.Server, fmt.Sprintf("http://localhost:%d", cfg.Server.Port))
+	defer advertiseUI(cfg.Server)()
 
 	log.Printf("Web UI available at http://localhost:%d", cfg.Server.Port)
 
-	// Initialize parser
-	detector := parser.NewDetector()
+	c := &collector{
+		cfg:      cfg,
+		db:       db,
+		srv:      srv,
+		session:  session,
+		input:    "stdin",
+		settings: settings,
+	}
+	if load != nil {
+		(&reloader{load: load, srv: srv, coll: c, detector: settings.detector}).enable(ctx)
+	}
+	return feed(c)
+}
+
+// collector parses, stores and broadcasts the lines of one collect session.
+type collector struct {
+	cfg     *config.Config
+	db      *storage.BadgerStorage
+	srv     *server.Server
+	session string
+	// source is recorded on every collected entry.
+	source string
+	// input names what feeds the session in pipeline traces.
+	input string
+
+	mu       sync.Mutex // guards settings, which live reload swaps
+	settings ingestSettings
+
+	// strict stops reading at the first record the forced format rejects.
+	strict bool
+
+	// pending holds parsed entries until flush stores them in one batch;
+	// traces holds the pipeline traces of its sampled entries, by index.
+	pending []*storage.LogEntry
+	traces  map[int]server.PipelineTrace
+
+	count      int
+	duplicates int
+	// failed counts records the forced format rejected; firstFailure
+	// describes the first of them for the session summary.
+	failed       int
+	firstFailure *lineParseError
+	// unstored counts entries broadcast but not stored while the disk guard
+	// paused storing; paused tracks the pause to log its start and end.
+	unstored int
+	paused   bool
+}
 
-	// Read from stdin line by line
-	scanner := bufio.NewScanner(os.Stdin)
-	count := 0
+// currentSettings returns the parsing settings in effect.
+func (c *collector) currentSettings() ingestSettings {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.settings
+}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "" {
-			continue
+// setSettings swaps in reloaded parsing settings for the following lines.
+// A CSV header row already read stays in effect while the format is
+// unchanged.
+func (c *collector) setSettings(settings ingestSettings) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	settings.detector = settings.detector.Stream(c.settings.detector)
+	c.settings = settings
+}
+
+// startStream gives the input readFrom starts on its own parser state, so
+// a CSV header row read from an earlier input no longer applies.
+func (c *collector) startStream() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.settings.detector = c.settings.detector.Stream(nil)
+}
+
+// multilineFlushTimeout is how long an open multiline record waits for
+// another continuation line before it is ingested.
+const multilineFlushTimeout = time.Second
+
+// Collected entries are stored in batches of up to collectBatchLines, or
+// once collectFlushInterval passes without filling one, so heavy piping
+// commits one write per batch instead of one per line.
+const (
+	collectBatchLines    = 500
+	collectFlushInterval = 100 * time.Millisecond
+)
+
+// readFrom ingests r line by line until EOF or until ctx is done. Lines are
+// read in a separate goroutine so a blocked read never delays shutdown; a
+// line that was handed over is always stored before readFrom returns. With
+// parsing.multiline_pattern set, continuation lines are joined onto the
+// entry before them, which is ingested once the next entry starts or no line
+// arrives for multilineFlushTimeout. Entries are stored and broadcast in
+// batches, flushed before readFrom returns. In strict mode it returns a
+// *lineParseError for the first record the forced format rejects. Each call
+// reads a new stream: a csv or tsv input starts with its header row.
+func (c *collector) readFrom(ctx context.Context, r io.Reader) (err error) {
+	c.startStream()
+	lines := make(chan string)
+	scanErr := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
 		}
+		scanErr <- scanner.Err()
+	}()
 
-		// Parse log entry
-		entry, err := detector.ParseWithFormat(line, cfg.Parsing.Format)
-		if err != nil {
-			log.Printf("Warning: Failed to parse line: %v", err)
-			continue
+	// lineNo is the number of the last line read and start the line the
+	// open record started on, for diagnostics.
+	lineNo, start := 0, 0
+	asm := parser.NewMultiline(c.currentSettings().multilineFor(c.source))
+	defer func() {
+		if ingestErr := c.ingest(asm.Flush(), start); err == nil {
+			err = ingestErr
+		}
+		c.flush()
+	}()
+	timer := time.NewTimer(multilineFlushTimeout)
+	timer.Stop()
+	defer timer.Stop()
+	var flush <-chan time.Time
+	// store fires collectFlushInterval after an entry was queued.
+	batchTimer := time.NewTimer(collectFlushInterval)
+	batchTimer.Stop()
+	defer batchTimer.Stop()
+	var store <-chan time.Time
+	queued := func() {
+		if store == nil && len(c.pending) > 0 {
+			batchTimer.Reset(collectFlushInterval)
+			store = batchTimer.C
 		}
+	}
 
-		// Store entry
-		if err := db.Store(entry); err != nil {
-			log.Printf("Warning: Failed to store entry: %v", err)
-			continue
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-store:
+			store = nil
+			c.flush()
+		case <-flush:
+			flush = nil
+			if err := c.ingest(asm.Flush(), start); err != nil {
+				return err
+			}
+			queued()
+		case line, ok := <-lines:
+			if !ok {
+				select {
+				case err := <-scanErr:
+					return err
+				default:
+					return nil
+				}
+			}
+			lineNo++
+			// A reload may have changed the pattern; the open record is
+			// finished under the old one.
+			if re := c.currentSettings().multilineFor(c.source); re != asm.Continuation() {
+				if err := c.ingest(asm.Flush(), start); err != nil {
+					return err
+				}
+				asm = parser.NewMultiline(re)
+			}
+			wasPending := asm.Pending()
+			record := asm.Add(line)
+			recordStart := start
+			if !wasPending {
+				recordStart = lineNo
+			}
+			if asm.Pending() && (record != nil || !wasPending) {
+				start = lineNo
+			}
+			if err := c.ingest(record, recordStart); err != nil {
+				return err
+			}
+			queued()
+			flush = nil
+			if asm.Pending() {
+				timer.Reset(multilineFlushTimeout)
+				flush = timer.C
+			}
 		}
+	}
+}
 
-		// Broadcast to connected WebSocket clients in real time
-		srv.BroadcastLog(entry)
+// lineParseError reports a record the forced format rejected, by the line
+// number it started on.
+type lineParseError struct {
+	line   int
+	format string
+	err    error
+}
+
+func (e *lineParseError) Error() string {
+	return fmt.Sprintf("line %d did not parse as %s: %v", e.line, e.format, e.err)
+}
+
+func (e *lineParseError) Unwrap() error {
+	return e.err
+}
+
+// quarantine keeps a record that failed to parse under parsefail: so
+// peek reparse-failures can retry it once the format is fixed.
+func (c *collector) quarantine(record []string, settings ingestSettings, err error) {
+	failure := storage.ParseFailure{
+		Format:  settings.formatFor(c.source),
+		Reason:  err.Error(),
+		Line:    strings.Join(record, "\n"),
+		Session: c.session,
+		Source:  c.source,
+		Host:    settings.host,
+	}
+	if err := c.db.RecordParseFailures([]storage.ParseFailure{failure}); err != nil {
+		log.Printf("Warning: Failed to keep the unparsed line: %v", err)
+	}
+}
+
+// ingest parses one record, a line followed by the continuation lines
+// joined onto it, starting on line lineNo of the input, and queues its
+// entry for flush, which it calls once collectBatchLines are queued. An
+// empty record is ignored. A record the forced format rejects is
+// quarantined; in strict mode ingest also returns it as a *lineParseError.
+func (c *collector) ingest(record []string, lineNo int) error {
+	if len(record) == 0 {
+		return nil
+	}
+
+	// Parse log entry
+	settings := c.currentSettings()
+	format := settings.formatFor(c.source)
+	sampled := c.srv.SamplePipeline()
+	started := time.Now()
+	entry, info, err := settings.detector.ParseLinesInfo(record, format)
+	parsed := time.Since(started)
+	if errors.Is(err, parser.ErrHeaderRow) {
+		return nil
+	}
+	if err != nil {
+		if sampled {
+			c.srv.RecordPipeline(server.PipelineTrace{
+				Time: time.Now(), Input: c.input, Source: c.source, Format: format, Lines: len(record),
+				Transforms: []string{}, Fields: []string{}, ParseUS: parsed.Microseconds(),
+				Outcome: server.PipelineFailed, Error: err.Error(),
+			})
+		}
+		lineErr := &lineParseError{line: lineNo, format: format, err: err}
+		log.Printf("Warning: %v", lineErr)
+		c.quarantine(record, settings, err)
+		c.failed++
+		if c.firstFailure == nil {
+			c.firstFailure = lineErr
+		}
+		if c.strict {
+			return lineErr
+		}
+		return nil
+	}
+
+	truncated := parser.Truncate(entry, settings.maxValueSize)
+	entry.Session = c.session
+	entry.Source = c.source
+	entry.Host = settings.host
+	entry.ID = settings.newID(entry)
+
+	if sampled {
+		if c.traces == nil {
+			c.traces = make(map[int]server.PipelineTrace)
+		}
+		t := server.NewPipelineTrace(entry, info, format, len(record), truncated, settings.maxValueSize, parsed)
+		t.EntryID, t.Input, t.Source = entry.ID, c.input, c.source
+		c.traces[len(c.pending)] = t
+	}
+	c.pending = append(c.pending, entry)
+	if len(c.pending) >= collectBatchLines {
+		c.flush()
+	}
+	return nil
+}
+
+// flush stores and broadcasts the queued entries in one batch. While the
+// disk guard pauses storing, they are still shown live.
+func (c *collector) flush() {
+	if len(c.pending) == 0 {
+		return
+	}
+	started := time.Now()
+	stored, err := c.db.StoreBatchUnique(c.pending, c.currentSettings().dedupeWindow)
+	took := time.Since(started)
+	for i, t := range c.traces {
+		t.StoreUS = took.Microseconds()
+		switch {
+		case errors.Is(err, storage.ErrIngestPaused):
+			t.Outcome = server.PipelineUnstored
+		case err != nil:
+			t.Outcome, t.Error = server.PipelineFailed, err.Error()
+		case !stored[i]:
+			t.Outcome = server.PipelineDuplicate
+		default:
+			t.Outcome = server.PipelineStored
+		}
+		c.srv.RecordPipeline(t)
+	}
+	clear(c.traces)
+
+	entries := c.pending
+	c.pending = c.pending[:0]
+	c.setPaused(errors.Is(err, storage.ErrIngestPaused))
+	switch {
+	case c.paused:
+		c.unstored += len(entries)
+		for _, entry := range entries {
+			c.srv.BroadcastLog(entry)
+		}
+		return
+	case err != nil:
+		log.Printf("Warning: Failed to store %d entries: %v", len(entries), err)
+		return
+	}
 
-		count++
-		if count%1000 == 0 {
-			log.Printf("Collected %d log entries", count)
+	before := c.count
+	for i, entry := range entries {
+		if !stored[i] {
+			c.duplicates++
+			continue
 		}
+		// Broadcast to connected WebSocket clients in real time
+		c.srv.BroadcastLog(entry)
+		c.count++
 	}
+	if c.count/1000 > before/1000 {
+		log.Printf("Collected %d log entries", c.count)
+	}
+}
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading stdin: %w", err)
+// setPaused logs when the disk guard starts or stops holding back entries.
+func (c *collector) setPaused(paused bool) {
+	if paused == c.paused {
+		return
+	}
+	c.paused = paused
+	if paused {
+		log.Printf("Warning: low disk space; new lines are shown live but not stored until space frees up")
+		return
 	}
+	log.Printf("Disk space available again; storing resumed (%d lines were not stored)", c.unstored)
+}
 
-	// Sync database before closing
+// finish syncs the database and logs the session totals.
+func (c *collector) finish() {
 	log.Println("Syncing database...")
-	if err := db.Sync(); err != nil {
+	if err := c.db.Sync(); err != nil {
 		log.Printf("Warning: Failed to sync database: %v", err)
 	}
 
-	log.Printf("Collection complete. Total entries: %d", count)
-	log.Printf("Server still running at http://localhost:%d — press Ctrl+C to exit", cfg.Server.Port)
-
-	// Keep server alive after stdin closes so user can still browse logs
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-	<-sigChan
-	log.Println("Shutting down...")
-	return nil
+	log.Printf("Collection complete. Total entries: %d", c.count)
+	if c.duplicates > 0 {
+		log.Printf("Skipped %d duplicate lines already ingested within %s", c.duplicates, c.currentSettings().dedupeWindow)
+	}
+	if c.unstored > 0 {
+		log.Printf("Did not store %d lines while disk space was low", c.unstored)
+	}
+	if c.failed > 0 {
+		log.Printf("Kept %d unparsed lines for peek reparse-failures; the first: %v", c.failed, c.firstFailure)
+	}
 }
 
-func runServerMode(cfg *config.Config) error {
+func runServerMode(cfg *config.Config, load parsingLoader) error {
 	log.Println("Starting server mode...")
 
 	// Initialize storage
-	storageCfg := storage.Config{
-		DBPath:        expandPath(cfg.Storage.DBPath),
-		RetentionSize: cfg.GetRetentionSizeBytes(),
-		RetentionDays: cfg.Storage.RetentionDays,
+	storageCfg, err := newStorageConfig(cfg)
+	if err != nil {
+		return err
 	}
 
 	db, err := storage.NewBadgerStorage(storageCfg)
@@ -545,21 +1784,64 @@ func runServerMode(cfg *config.Config) error {
 	}
 	defer db.Close()
 
+	settings, err := newIngestSettings(cfg.Parsing)
+	if err != nil {
+		return err
+	}
+	auditRetention, err := newAuditRetention(cfg.Audit)
+	if err != nil {
+		return err
+	}
+
 	// Initialize server
-	srv := server.NewServer(db, nil)
+	srv := server.NewServer(db, "")
+	srv.SetUIConfig(newUIConfig(cfg))
+	srv.SetSecurity(newServerSecurity(cfg.Server))
+	if err := srv.SetUIDir(cfg.Server.UIDir); err != nil {
+		return err
+	}
+	if err := srv.SetTokens(newServerTokens(cfg)); err != nil {
+		return fmt.Errorf("invalid auth config: %w", err)
+	}
+	if err := srv.SetPeers(newServerPeers(cfg.Server)); err != nil {
+		return fmt.Errorf("invalid federate config: %w", err)
+	}
+	srv.SetIDGenerator(settings.newID)
+	srv.SetDedupeWindow(settings.dedupeWindow)
+	srv.SetMaxValueSize(settings.maxValueSize)
+	srv.SetDetector(settings.detector)
+	srv.SetSourceFormats(settings.sourceFormats)
+	srv.SetAuditRetention(auditRetention)
 
 	// Start broadcast worker for real-time updates
 	srv.StartBroadcastWorker()
 
-	// Auto-open browser
-	if cfg.Server.AutoOpenBrowser {
-		url := fmt.Sprintf("http://localhost:%d", cfg.Server.Port)
-		go openBrowser(url)
+	// Run scheduled queries in the background
+	ctx, cancel := context.WithCancel(context.Background())
+	sched := scheduler.New(db, scheduler.DefaultTick)
+	sched.Start(ctx)
+	defer stopServices(srv, cancel, sched)
+	if err := listenGELF(srv, cfg.Server); err != nil {
+		return err
 	}
+	if err := listenGRPC(srv, cfg.Server); err != nil {
+		return err
+	}
+	if err := enablePipelineDebug(srv, cfg.Server); err != nil {
+		return err
+	}
+
+	if load != nil {
+		(&reloader{load: load, srv: srv, detector: settings.detector}).enable(ctx)
+	}
+
+	// Auto-open browser
+	openUI(cfg.Server, fmt.Sprintf("http://localhost:%d", cfg.Server.Port))
+	defer advertiseUI(cfg.Server)()
 
 	// Setup graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Start server in goroutine
 	errChan := make(chan error, 1)
@@ -569,7 +1851,7 @@ func runServerMode(cfg *config.Config) error {
 
 	// Wait for shutdown signal or error
 	select {
-	case <-sigChan:
+	case <-sigCtx.Done():
 		log.Println("Shutting down gracefully...")
 		return nil
 	case err := <-errChan:
@@ -577,6 +1859,26 @@ func runServerMode(cfg *config.Config) error {
 	}
 }
 
+// newSessionID returns a sortable, unique ID for a collect run.
+func newSessionID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(b)
+}
+
+// maxSessionName caps session names taken from command lines.
+const maxSessionName = 120
+
+// commandName joins argv into a session name, shortened to maxSessionName
+// runes.
+func commandName(argv []string) string {
+	name := []rune(strings.TrimSpace(strings.Join(argv, " ")))
+	if len(name) > maxSessionName {
+		return string(name[:maxSessionName-1]) + "…"
+	}
+	return string(name)
+}
+
 func expandPath(path string) string {
 	if len(path) > 0 && path[0] == '~' {
 		home, err := os.UserHomeDir()
@@ -586,23 +1888,3 @@ func expandPath(path string) string {
 	}
 	return path
 }
-
-func openBrowser(url string) {
-	var cmd *exec.Cmd
-
-	switch runtime.GOOS {
-	case "linux":
-		cmd = exec.Command("xdg-open", url)
-	case "darwin":
-		cmd = exec.Command("open", url)
-	case "windows":
-		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
-	default:
-		log.Printf("Cannot auto-open browser on %s. Please open: %s", runtime.GOOS, url)
-		return
-	}
-
-	if err := cmd.Start(); err != nil {
-		log.Printf("Failed to open browser: %v. Please open: %s", err, url)
-	}
-}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"io"
 	"net"
 	"os"
@@ -51,6 +53,28 @@ func TestParseDuration(t *testing.T) {
 	}
 }
 
+func TestNewDedupeWindow(t *testing.T) {
+	tests := []struct {
+		window  string
+		want    time.Duration
+		wantErr bool
+	}{
+		{window: "", want: 0},
+		{window: "24h", want: 24 * time.Hour},
+		{window: "7d", want: 7 * 24 * time.Hour},
+		{window: "soon", wantErr: true},
+		{window: "0s", wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg := config.DefaultConfig()
+		cfg.Parsing.DedupeWindow = tt.window
+		got, err := newDedupeWindow(cfg)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("newDedupeWindow(%q) = %v, %v, want %v (err %v)", tt.window, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
 func TestExpandPath(t *testing.T) {
 	home := t.TempDir()
 	t.Setenv("HOME", home)
@@ -65,6 +89,35 @@ func TestExpandPath(t *testing.T) {
 	}
 }
 
+func TestNewStorageConfig(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Storage.Badger.ValueLogFileSize = "64MB"
+	cfg.Storage.Badger.MemTableSize = "16MB"
+	cfg.Storage.Badger.Compression = "snappy"
+
+	got, err := newStorageConfig(cfg)
+	if err != nil {
+		t.Fatalf("newStorageConfig() error = %v", err)
+	}
+	if got.Badger.DisableSyncWrites || got.Badger.ValueLogFileSize != 64<<20 || got.Badger.MemTableSize != 16<<20 || got.Badger.Compression != "snappy" {
+		t.Fatalf("unexpected badger tuning: %+v", got.Badger)
+	}
+	if got.MinFreeBytes != 500<<20 {
+		t.Fatalf("MinFreeBytes = %d, want the 500MB default", got.MinFreeBytes)
+	}
+
+	cfg.Storage.MinFreeSpace = "little"
+	if _, err := newStorageConfig(cfg); err == nil {
+		t.Fatalf("expected error for invalid min_free_space")
+	}
+	cfg.Storage.MinFreeSpace = ""
+
+	cfg.Storage.Badger.MemTableSize = "lots"
+	if _, err := newStorageConfig(cfg); err == nil {
+		t.Fatalf("expected error for invalid memtable_size")
+	}
+}
+
 func TestRunDbCommandValidation(t *testing.T) {
 	tests := []struct {
 		name string
@@ -94,6 +147,12 @@ func TestRunDbSubcommandsWithTempDB(t *testing.T) {
 		{name: "clean level", run: func() error { return runDbClean([]string{"--db-path", dbPath, "--level", "DEBUG", "--force"}) }},
 		{name: "clean older than", run: func() error { return runDbClean([]string{"--db-path", dbPath, "--older-than", "1h", "--force"}) }},
 		{name: "clean all", run: func() error { return runDbClean([]string{"--db-path", dbPath, "--force"}) }},
+		{name: "clean json quiet", run: func() error {
+			return runDbClean([]string{"--db-path", dbPath, "--force", "--output", "json", "--quiet"})
+		}},
+		{name: "reparse json", run: func() error { return runDbReparse([]string{"--db-path", dbPath, "--output", "json"}) }},
+		{name: "verify", run: func() error { return runDbVerify([]string{"--db-path", dbPath}) }},
+		{name: "verify quarantine", run: func() error { return runDbVerify([]string{"--db-path", dbPath, "--quarantine"}) }},
 	}
 
 	for _, tt := range tests {
@@ -103,6 +162,10 @@ func TestRunDbSubcommandsWithTempDB(t *testing.T) {
 			}
 		})
 	}
+
+	if err := runDbClean([]string{"--db-path", dbPath, "--output", "json"}); err == nil {
+		t.Fatal("clean --output json without --force error = nil")
+	}
 }
 
 func TestRunServerModeGracefulShutdown(t *testing.T) {
@@ -118,7 +181,7 @@ func TestRunServerModeGracefulShutdown(t *testing.T) {
 		_ = p.Signal(os.Interrupt)
 	}()
 
-	if err := runServerMode(cfg); err != nil {
+	if err := runServerMode(cfg, nil); err != nil {
 		t.Fatalf("runServerMode() error = %v", err)
 	}
 }
@@ -150,9 +213,19 @@ func TestRunCollectModeProcessesInputAndShutsDown(t *testing.T) {
 		_ = p.Signal(os.Interrupt)
 	}()
 
-	if err := runCollectMode(cfg, true); err != nil {
+	if err := runCollectMode(cfg, true, "tail -f app.log", "", false, nil); err != nil {
 		t.Fatalf("runCollectMode() error = %v", err)
 	}
+
+	db, err := storage.NewBadgerStorage(storage.Config{DBPath: cfg.Storage.DBPath})
+	if err != nil {
+		t.Fatalf("NewBadgerStorage() error = %v", err)
+	}
+	defer db.Close()
+	sessions, err := db.GetSessions(context.Background())
+	if err != nil || len(sessions) != 1 || sessions[0].Name != "tail -f app.log" {
+		t.Fatalf("GetSessions() = %+v, %v, want one session named after the command", sessions, err)
+	}
 }
 
 func captureStdout(t *testing.T, fn func()) string {
@@ -327,7 +400,7 @@ func TestRunServerModePortInUseReturnsError(t *testing.T) {
 	cfg.Server.AutoOpenBrowser = false
 	cfg.Server.Port = port
 
-	err = runServerMode(cfg)
+	err = runServerMode(cfg, nil)
 	if err == nil {
 		t.Fatalf("expected server start error for occupied port")
 	}
@@ -352,6 +425,12 @@ func TestRunDbStatsAndCleanWithExistingLogs(t *testing.T) {
 	if err := runDbStats([]string{"--db-path", dbPath}); err != nil {
 		t.Fatalf("runDbStats() error = %v", err)
 	}
+	if err := runDbStats([]string{"--db-path", dbPath, "--digest", "--window", "2h"}); err != nil {
+		t.Fatalf("runDbStats(--digest) error = %v", err)
+	}
+	if err := runDbStats([]string{"--db-path", dbPath, "--digest", "--window", "soon"}); err == nil {
+		t.Fatalf("runDbStats(--window soon) error = nil")
+	}
 
 	origStdin := os.Stdin
 	r, w, err := os.Pipe()
@@ -441,7 +520,84 @@ func TestRunCollectModeFreshModeWithExistingLogs(t *testing.T) {
 		_ = p.Signal(os.Interrupt)
 	}()
 
-	if err := runCollectMode(cfg, false); err != nil {
+	if err := runCollectMode(cfg, false, "", "", false, nil); err != nil {
 		t.Fatalf("runCollectMode(fresh mode) error = %v", err)
 	}
 }
+
+func TestCommandName(t *testing.T) {
+	if got := commandName([]string{"kubectl", "logs", "api", "-f"}); got != "kubectl logs api -f" {
+		t.Errorf("commandName() = %q", got)
+	}
+	long := commandName([]string{"echo", strings.Repeat("x", 200)})
+	if n := len([]rune(long)); n != maxSessionName || !strings.HasSuffix(long, "…") {
+		t.Errorf("commandName(long) = %q (%d runes), want %d ending in …", long, n, maxSessionName)
+	}
+}
+
+func TestPrintDigest(t *testing.T) {
+	ts := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	printDigest(&buf, []storage.DigestPattern{{Level: "ERROR", Pattern: "timeout after <num>ms", Count: 12, FirstSeen: ts, LastSeen: ts}}, "1h")
+	if out := buf.String(); !strings.Contains(out, "last 1h") || !strings.Contains(out, "12  ERROR  timeout after <num>ms") {
+		t.Fatalf("printDigest() = %q", out)
+	}
+
+	buf.Reset()
+	printDigest(&buf, nil, "1h")
+	if !strings.Contains(buf.String(), "none") {
+		t.Fatalf("printDigest(nil) = %q, want none", buf.String())
+	}
+}
+
+func TestPrintLargest(t *testing.T) {
+	ts := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	printLargest(&buf, []storage.EntrySize{{ID: "e1", Timestamp: ts, Message: "crashed", Bytes: 3 * 1024 * 1024, Parts: []storage.PartSize{{Name: "stack", Bytes: 2 * 1024 * 1024}, {Name: "message", Bytes: 9}}}})
+	if out := buf.String(); !strings.Contains(out, "3.0 MB  e1") || !strings.Contains(out, "stack 2.0 MB, message 9 B") {
+		t.Fatalf("printLargest() = %q", out)
+	}
+
+	buf.Reset()
+	printLargest(&buf, nil)
+	if !strings.Contains(buf.String(), "none") {
+		t.Fatalf("printLargest(nil) = %q, want none", buf.String())
+	}
+}
+
+func TestPrintVolume(t *testing.T) {
+	var buf bytes.Buffer
+	printVolume(&buf, "sources", []storage.VolumeStats{{Name: "api", Count: 12, Bytes: 2048}, {Count: 3, Bytes: 90}})
+	if out := buf.String(); !strings.Contains(out, "Largest sources:") || !strings.Contains(out, "2.0 KB        12 entries  api") || !strings.Contains(out, "(none)") {
+		t.Fatalf("printVolume() = %q", out)
+	}
+
+	buf.Reset()
+	printVolume(&buf, "sessions", nil)
+	if buf.Len() != 0 {
+		t.Fatalf("printVolume(nil) = %q, want nothing", buf.String())
+	}
+}
+
+func TestPrintStorageStats(t *testing.T) {
+	days := 12.5
+	tests := []struct {
+		name  string
+		stats storage.Stats
+		want  string
+	}{
+		{name: "projection", stats: storage.Stats{RetentionSizeBytes: 1024 * 1024, IngestRatePerHour: 40, DaysUntilFull: &days}, want: "~12.5 days"},
+		{name: "no ingest", stats: storage.Stats{RetentionSizeBytes: 1024 * 1024}, want: "no recent ingest"},
+		{name: "no cap", stats: storage.Stats{}, want: "Retention cap:   none"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			printStorageStats(&buf, tt.stats)
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Fatalf("printStorageStats() = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
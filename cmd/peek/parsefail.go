@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mchurichi/peek/internal/config"
+	"github.com/mchurichi/peek/pkg/parser"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// reparseFailuresResult summarizes a peek reparse-failures run.
+type reparseFailuresResult struct {
+	Matched   int  `json:"matched"`
+	Recovered int  `json:"recovered"`
+	Failed    int  `json:"failed"`
+	DryRun    bool `json:"dry_run,omitempty"`
+}
+
+func runReparseFailuresCommand(args []string) error {
+	fs := flag.NewFlagSet("reparse-failures", flag.ExitOnError)
+	configPath := fs.String("config", "~/.peek/config.toml", "Path to config file")
+	dbPath := fs.String("db-path", "", "Database path (overrides config)")
+	format := fs.String("format", "", "Parse with this format instead of the one each line failed with")
+	failedFormat := fs.String("failed-format", "", "Only retry lines that failed with this format")
+	dryRun := fs.Bool("dry-run", false, "Report what would be recovered without storing anything")
+	output := fs.String("output", outputText, "Output format: text or json")
+	fs.Parse(args)
+
+	if err := validateNoPositionalArgs(fs.Args()); err != nil {
+		return err
+	}
+	if err := checkTextOrJSON(*output); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if *dbPath != "" {
+		cfg.Storage.DBPath = *dbPath
+	}
+	settings, err := newIngestSettings(cfg.Parsing)
+	if err != nil {
+		return err
+	}
+	if err := setParserDefaults(cfg.Parsing); err != nil {
+		return err
+	}
+	if *format != "" && !settings.detector.ValidFormat(*format) {
+		return fmt.Errorf("invalid --format %q (use %s)", *format, strings.Join(settings.detector.Formats(), ", "))
+	}
+
+	storageCfg, err := newStorageConfig(cfg)
+	if err != nil {
+		return err
+	}
+	db, err := storage.NewBadgerStorage(storageCfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer db.Close()
+
+	return runReparseFailures(os.Stdout, db, settings, *format, *failedFormat, *dryRun, *output)
+}
+
+// runReparseFailures parses the quarantined lines again with the current
+// parsing settings: format, or the format each line failed with when empty.
+// Recovered lines are stored as entries and leave the quarantine; the rest
+// stay. failedFormat restricts the run to lines that failed with it.
+func runReparseFailures(w io.Writer, db *storage.BadgerStorage, settings ingestSettings, format, failedFormat string, dryRun bool, output string) error {
+	var failures []storage.ParseFailure
+	err := db.ScanParseFailures(func(f storage.ParseFailure) error {
+		if failedFormat == "" || f.Format == failedFormat {
+			failures = append(failures, f)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Oldest first, so a CSV header row is read before the rows after it.
+	res := reparseFailuresResult{Matched: len(failures), DryRun: dryRun}
+	var (
+		entries   []*storage.LogEntry
+		recovered []string
+	)
+	for i := len(failures) - 1; i >= 0; i-- {
+		f := failures[i]
+		lineFormat := format
+		if lineFormat == "" {
+			lineFormat = f.Format
+		}
+		entry, err := settings.detector.ParseLines(strings.Split(f.Line, "\n"), lineFormat)
+		if errors.Is(err, parser.ErrHeaderRow) {
+			// A header row has nothing to store; it leaves the quarantine.
+			recovered = append(recovered, f.ID)
+			res.Recovered++
+			continue
+		}
+		if err != nil {
+			res.Failed++
+			continue
+		}
+		parser.Truncate(entry, settings.maxValueSize)
+		entry.Session = f.Session
+		entry.Source = f.Source
+		entry.Namespace = f.Namespace
+		entry.Host = f.Host
+		entry.ID = settings.newID(entry)
+		entries = append(entries, entry)
+		recovered = append(recovered, f.ID)
+		res.Recovered++
+	}
+
+	if !dryRun {
+		if err := db.StoreBatch(entries); err != nil {
+			return fmt.Errorf("failed to store recovered entries: %w", err)
+		}
+		if err := db.DeleteParseFailures(recovered); err != nil {
+			return err
+		}
+	}
+
+	if output == outputJSON {
+		return writeJSONLine(w, res)
+	}
+	verb := "recovered"
+	if dryRun {
+		verb = "would be recovered"
+	}
+	fmt.Fprintf(w, "Reparsed %d parse failures: %d %s, %d still failing.\n", res.Matched, res.Recovered, verb, res.Failed)
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mchurichi/peek/internal/config"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+func TestRunReparseFailures(t *testing.T) {
+	db, err := storage.NewBadgerStorage(storage.Config{DBPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewBadgerStorage() error = %v", err)
+	}
+	defer db.Close()
+
+	if err := db.RecordParseFailures([]storage.ParseFailure{
+		{Format: "legacy", Reason: "line does not match format legacy", Line: "ERROR [db] down", Source: "app.log", Namespace: "team-a"},
+		{Format: "legacy", Reason: "line does not match format legacy", Line: "garbage"},
+		{Format: "json", Reason: "line does not match format json", Line: "level=info msg=hi"},
+	}); err != nil {
+		t.Fatalf("RecordParseFailures() error = %v", err)
+	}
+
+	// The pattern has been fixed since the lines failed.
+	settings, err := newIngestSettings(config.ParsingConfig{Custom: []config.CustomFormatConfig{
+		{Name: "legacy", Pattern: `^(?P<level>[A-Z]+) \[(?P<module>\w+)\] (?P<message>.*)$`},
+	}})
+	if err != nil {
+		t.Fatalf("newIngestSettings() error = %v", err)
+	}
+
+	remaining := func() int {
+		n := 0
+		db.ScanParseFailures(func(storage.ParseFailure) error { n++; return nil })
+		return n
+	}
+
+	var out bytes.Buffer
+	if err := runReparseFailures(&out, db, settings, "", "legacy", true, outputText); err != nil {
+		t.Fatalf("runReparseFailures(dry run) error = %v", err)
+	}
+	if got := out.String(); got != "Reparsed 2 parse failures: 1 would be recovered, 1 still failing.\n" || remaining() != 3 {
+		t.Fatalf("dry run = %q, %d remaining", got, remaining())
+	}
+
+	out.Reset()
+	if err := runReparseFailures(&out, db, settings, "", "", false, outputJSON); err != nil {
+		t.Fatalf("runReparseFailures() error = %v", err)
+	}
+	if got := strings.TrimSpace(out.String()); got != `{"matched":3,"recovered":1,"failed":2}` {
+		t.Fatalf("output = %s", got)
+	}
+	if remaining() != 2 {
+		t.Fatalf("%d failures remaining, want 2", remaining())
+	}
+	entries, total, err := db.Query(&storage.AllFilter{}, 10, 0)
+	if err != nil || total != 1 {
+		t.Fatalf("Query() = %d, %v", total, err)
+	}
+	if e := entries[0]; e.Message != "down" || e.Fields["module"] != "db" || e.Source != "app.log" || e.Namespace != "team-a" {
+		t.Fatalf("recovered entry = %+v", e)
+	}
+
+	// A different format recovers the logfmt line that failed as JSON.
+	out.Reset()
+	if err := runReparseFailures(&out, db, settings, "logfmt", "json", false, outputText); err != nil {
+		t.Fatalf("runReparseFailures(--format logfmt) error = %v", err)
+	}
+	if remaining() != 1 {
+		t.Fatalf("%d failures remaining, want 1", remaining())
+	}
+}
+
+func TestReadFromStrict(t *testing.T) {
+	input := `{"message":"one"}` + "\n\n" + "level=info msg=two\n" + `{"message":"three"}` + "\n" + "not json\n"
+	tests := []struct {
+		name      string
+		strict    bool
+		wantErr   string
+		wantCount int
+	}{
+		{name: "lenient", wantCount: 2},
+		{name: "strict", strict: true, wantErr: "line 3 did not parse as json", wantCount: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, db := newShutdownCollector(t)
+			settings, err := newIngestSettings(config.ParsingConfig{Format: "json"})
+			if err != nil {
+				t.Fatalf("newIngestSettings() error = %v", err)
+			}
+			c.settings = settings
+			c.strict = tt.strict
+
+			err = c.readFrom(context.Background(), strings.NewReader(input))
+			var lineErr *lineParseError
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("readFrom() error = %v", err)
+			case tt.wantErr != "" && (!errors.As(err, &lineErr) || !strings.HasPrefix(err.Error(), tt.wantErr)):
+				t.Fatalf("readFrom() error = %v, want %q", err, tt.wantErr)
+			}
+			if c.count != tt.wantCount {
+				t.Fatalf("count = %d, want %d", c.count, tt.wantCount)
+			}
+			// Rejected lines are kept aside either way.
+			kept := 0
+			db.ScanParseFailures(func(storage.ParseFailure) error { kept++; return nil })
+			if kept != c.failed || c.firstFailure == nil || c.firstFailure.line != 3 {
+				t.Fatalf("kept %d, failed %d, first %v", kept, c.failed, c.firstFailure)
+			}
+		})
+	}
+}
+
+func TestReadFromLineNumbersWithMultiline(t *testing.T) {
+	c, _ := newShutdownCollector(t)
+	settings, err := newIngestSettings(config.ParsingConfig{Format: "json", MultilinePattern: `^\s+at `})
+	if err != nil {
+		t.Fatalf("newIngestSettings() error = %v", err)
+	}
+	c.settings = settings
+	c.strict = true
+
+	input := `{"message":"one"}` + "\n" + "oops\n" + "  at main.go:1\n" + `{"message":"two"}` + "\n"
+	err = c.readFrom(context.Background(), strings.NewReader(input))
+	if err == nil || !strings.HasPrefix(err.Error(), "line 2 did not parse as json") {
+		t.Fatalf("readFrom() error = %v, want line 2", err)
+	}
+}
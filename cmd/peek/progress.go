@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// progressInterval is the minimum time between progress updates.
+const progressInterval = 250 * time.Millisecond
+
+// progressEvent is one machine-readable progress update, printed as a JSON
+// line to stderr when a command runs with --output json.
+type progressEvent struct {
+	Op         string  `json:"op"`
+	Done       int     `json:"done"`
+	Total      int     `json:"total,omitempty"`
+	Percent    float64 `json:"percent,omitempty"`
+	Rate       float64 `json:"rate"`
+	ETASeconds float64 `json:"eta_seconds,omitempty"`
+	Final      bool    `json:"final,omitempty"`
+}
+
+// progressReporter prints the progress of a long-running operation: a status
+// line rewritten in place in text mode, or one progressEvent per update in
+// JSON mode. Updates are throttled to progressInterval; a nil reporter
+// prints nothing, which is what --quiet gives.
+type progressReporter struct {
+	w       io.Writer
+	op      string
+	unit    string
+	total   int // 0 when unknown
+	json    bool
+	now     func() time.Time
+	start   time.Time
+	last    time.Time
+	printed bool
+}
+
+// newProgress starts a reporter for op counting unit (e.g. "entries"), with
+// total 0 when the amount of work is unknown. It returns nil when quiet.
+func newProgress(w io.Writer, op, unit string, total int, output string, quiet bool) *progressReporter {
+	if quiet {
+		return nil
+	}
+	p := &progressReporter{w: w, op: op, unit: unit, total: total, json: output == outputJSON, now: time.Now}
+	p.start = p.now()
+	return p
+}
+
+// update reports done units of work so far.
+func (p *progressReporter) update(done int) {
+	if p == nil {
+		return
+	}
+	now := p.now()
+	if now.Sub(p.last) < progressInterval {
+		return
+	}
+	p.last = now
+	p.print(p.event(done, now))
+}
+
+// finish reports the final count and ends the text status line.
+func (p *progressReporter) finish(done int) {
+	if p == nil || (!p.printed && !p.json) {
+		return // nothing shown yet; the command's summary says it all
+	}
+	ev := p.event(done, p.now())
+	ev.Final = true
+	ev.ETASeconds = 0
+	p.print(ev)
+	if !p.json {
+		fmt.Fprintln(p.w)
+	}
+}
+
+// event builds the update for done units at now.
+func (p *progressReporter) event(done int, now time.Time) progressEvent {
+	ev := progressEvent{Op: p.op, Done: done, Total: p.total}
+	if elapsed := now.Sub(p.start).Seconds(); elapsed > 0 {
+		ev.Rate = float64(done) / elapsed
+	}
+	if p.total > 0 {
+		ev.Percent = min(100, 100*float64(done)/float64(p.total))
+		if ev.Rate > 0 && done < p.total {
+			ev.ETASeconds = float64(p.total-done) / ev.Rate
+		}
+	}
+	return ev
+}
+
+// print writes ev in the reporter's format.
+func (p *progressReporter) print(ev progressEvent) {
+	p.printed = true
+	if p.json {
+		writeJSONLine(p.w, ev)
+		return
+	}
+
+	line := fmt.Sprintf("%s: %d", p.op, ev.Done)
+	if ev.Total > 0 {
+		line += fmt.Sprintf("/%d %s (%.0f%%)", ev.Total, p.unit, ev.Percent)
+	} else {
+		line += " " + p.unit
+	}
+	line += fmt.Sprintf(", %.0f/s", ev.Rate)
+	if ev.ETASeconds > 0 {
+		line += ", ETA " + time.Duration(ev.ETASeconds*float64(time.Second)).Round(time.Second).String()
+	}
+	// Pad over the remains of a longer previous line.
+	fmt.Fprintf(p.w, "\r%-60s", line)
+}
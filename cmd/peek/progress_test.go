@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressReporterText(t *testing.T) {
+	var buf bytes.Buffer
+	p := newProgress(&buf, "deleting", "entries", 4000, outputText, false)
+	clock := p.start
+	p.now = func() time.Time { return clock }
+
+	clock = clock.Add(time.Second)
+	p.update(1000)
+	p.update(1500) // throttled
+	clock = clock.Add(time.Second)
+	p.update(2000)
+	p.finish(4000)
+
+	lines := strings.Split(strings.TrimPrefix(buf.String(), "\r"), "\r")
+	if len(lines) != 3 {
+		t.Fatalf("progress output = %q, want 3 updates", buf.String())
+	}
+	for i, want := range []string{
+		"deleting: 1000/4000 entries (25%), 1000/s, ETA 3s",
+		"deleting: 2000/4000 entries (50%), 1000/s, ETA 2s",
+		"deleting: 4000/4000 entries (100%), 2000/s",
+	} {
+		if got := strings.TrimSpace(lines[i]); got != want {
+			t.Errorf("update %d = %q, want %q", i, got, want)
+		}
+	}
+	if !strings.HasSuffix(buf.String(), "\n") {
+		t.Errorf("progress output %q does not end the status line", buf.String())
+	}
+}
+
+func TestProgressReporterJSON(t *testing.T) {
+	var buf bytes.Buffer
+	p := newProgress(&buf, "compacting", "GC passes", 0, outputJSON, false)
+	clock := p.start
+	p.now = func() time.Time { return clock }
+
+	clock = clock.Add(2 * time.Second)
+	p.update(1)
+	p.finish(2)
+
+	want := `{"op":"compacting","done":1,"rate":0.5}` + "\n" +
+		`{"op":"compacting","done":2,"rate":1,"final":true}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("progress output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestProgressReporterQuiet(t *testing.T) {
+	var buf bytes.Buffer
+	p := newProgress(&buf, "reparsing", "entries", 10, outputText, true)
+	p.update(5)
+	p.finish(10)
+	if p != nil || buf.Len() != 0 {
+		t.Fatalf("quiet progress = %v, output %q, want nil and nothing printed", p, buf.String())
+	}
+
+	// A text reporter that never printed leaves the summary alone.
+	p = newProgress(&buf, "reparsing", "entries", 10, outputText, false)
+	p.finish(10)
+	if buf.Len() != 0 {
+		t.Fatalf("finish() without updates printed %q", buf.String())
+	}
+}
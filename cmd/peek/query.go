@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mchurichi/peek/internal/config"
+	"github.com/mchurichi/peek/pkg/query"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+func runQueryCommand(args []string) error {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	configPath := fs.String("config", "~/.peek/config.toml", "Path to config file")
+	dbPath := fs.String("db-path", "", "Database path (overrides config)")
+	viewName := fs.String("view", "", "Saved view to apply (query and time range)")
+	limit := fs.Int("limit", 100, "Maximum number of entries to print")
+	output := fs.String("output", outputJSON, "Output format: json (JSON lines) or pretty (aligned, colored columns)")
+	fields := fs.String("fields", "", "Comma-separated fields to print as columns in pretty output")
+	noColor := fs.Bool("no-color", false, "Disable colors in pretty output (also honors NO_COLOR)")
+	fs.Parse(args)
+
+	var write entryWriter
+	switch *output {
+	case outputJSON:
+		write = writeJSONLines
+	case outputPretty:
+		write = prettyFormatter{color: useColor(os.Stdout, *noColor), fields: parseFieldList(*fields)}.write
+	default:
+		return fmt.Errorf("invalid --output %q (use json or pretty)", *output)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if *dbPath != "" {
+		cfg.Storage.DBPath = *dbPath
+	}
+	if err := setParserDefaults(cfg.Parsing); err != nil {
+		return err
+	}
+
+	storageCfg, err := newStorageConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	db, err := storage.NewBadgerStorage(storageCfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer db.Close()
+
+	var view *storage.View
+	if *viewName != "" {
+		view, err = db.GetView(*viewName)
+		if err != nil {
+			return fmt.Errorf("failed to load view: %w", err)
+		}
+	}
+
+	return runQuery(os.Stdout, db, view, strings.Join(fs.Args(), " "), *limit, time.Now(), write)
+}
+
+// runQuery prints entries matching the view and query with write, newest first.
+func runQuery(w io.Writer, db *storage.BadgerStorage, view *storage.View, queryStr string, limit int, now time.Time, write entryWriter) error {
+	var filter query.Filter = &query.AllFilter{}
+	var tr *storage.TimeRange
+
+	if view != nil {
+		if view.Query != "" {
+			q, err := query.Parse(view.Query)
+			if err != nil {
+				return fmt.Errorf("invalid view query: %w", err)
+			}
+			filter = q
+		}
+		tr = viewTimeRange(view, now)
+	}
+
+	if queryStr != "" {
+		q, err := query.Parse(queryStr)
+		if err != nil {
+			return fmt.Errorf("invalid query: %w", err)
+		}
+		if view != nil && view.Query != "" {
+			filter = &query.AndFilter{Left: filter, Right: q}
+		} else {
+			filter = q
+		}
+	}
+
+	entries, _, err := db.QueryContext(context.Background(), filter, storage.QueryOptions{TimeRange: tr, Limit: limit, SkipTotal: true})
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+
+	return write(w, entries)
+}
+
+// viewTimeRange resolves a view's time preset relative to now, mirroring the
+// web UI presets. It returns nil when the view has no time bound.
+func viewTimeRange(v *storage.View, now time.Time) *storage.TimeRange {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch v.TimePreset {
+	case "15m":
+		return &storage.TimeRange{Start: now.Add(-15 * time.Minute)}
+	case "1h":
+		return &storage.TimeRange{Start: now.Add(-time.Hour)}
+	case "6h":
+		return &storage.TimeRange{Start: now.Add(-6 * time.Hour)}
+	case "24h":
+		return &storage.TimeRange{Start: now.Add(-24 * time.Hour)}
+	case "7d":
+		return &storage.TimeRange{Start: now.Add(-7 * 24 * time.Hour)}
+	case "today":
+		return &storage.TimeRange{Start: midnight}
+	case "yesterday":
+		return &storage.TimeRange{Start: midnight.AddDate(0, 0, -1), End: midnight}
+	case "custom":
+		tr := &storage.TimeRange{}
+		if v.Start != nil {
+			tr.Start = *v.Start
+		}
+		if v.End != nil {
+			tr.End = *v.End
+		}
+		return tr
+	default:
+		return nil
+	}
+}
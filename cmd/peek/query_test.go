@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/parser"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+func TestViewTimeRange(t *testing.T) {
+	now := time.Date(2026, 3, 10, 15, 30, 0, 0, time.UTC)
+	midnight := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	start := now.Add(-48 * time.Hour)
+
+	tests := []struct {
+		view storage.View
+		want *storage.TimeRange
+	}{
+		{view: storage.View{}, want: nil},
+		{view: storage.View{TimePreset: "all"}, want: nil},
+		{view: storage.View{TimePreset: "1h"}, want: &storage.TimeRange{Start: now.Add(-time.Hour)}},
+		{view: storage.View{TimePreset: "7d"}, want: &storage.TimeRange{Start: now.Add(-7 * 24 * time.Hour)}},
+		{view: storage.View{TimePreset: "today"}, want: &storage.TimeRange{Start: midnight}},
+		{view: storage.View{TimePreset: "yesterday"}, want: &storage.TimeRange{Start: midnight.AddDate(0, 0, -1), End: midnight}},
+		{view: storage.View{TimePreset: "custom", Start: &start}, want: &storage.TimeRange{Start: start}},
+	}
+	for _, tt := range tests {
+		got := viewTimeRange(&tt.view, now)
+		if (got == nil) != (tt.want == nil) || (got != nil && (!got.Start.Equal(tt.want.Start) || !got.End.Equal(tt.want.End))) {
+			t.Errorf("viewTimeRange(%q) = %+v, want %+v", tt.view.TimePreset, got, tt.want)
+		}
+	}
+}
+
+func TestRunQueryWithView(t *testing.T) {
+	db, err := storage.NewBadgerStorage(storage.Config{DBPath: t.TempDir(), RetentionSize: 1024 * 1024 * 100, RetentionDays: 7})
+	if err != nil {
+		t.Fatalf("NewBadgerStorage() error = %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now().UTC()
+	for _, e := range []*storage.LogEntry{
+		{ID: "old", Timestamp: now.Add(-3 * time.Hour), Level: "ERROR", Message: "old", Fields: map[string]interface{}{"service": "payments"}},
+		{ID: "pay", Timestamp: now.Add(-time.Minute), Level: "ERROR", Message: "pay", Fields: map[string]interface{}{"service": "payments"}},
+		{ID: "api", Timestamp: now.Add(-time.Minute), Level: "ERROR", Message: "api", Fields: map[string]interface{}{"service": "api"}},
+		{ID: "info", Timestamp: now.Add(-time.Minute), Level: "INFO", Message: "info", Fields: map[string]interface{}{"service": "payments"}},
+	} {
+		if err := db.Store(e); err != nil {
+			t.Fatalf("Store() error = %v", err)
+		}
+	}
+
+	view := &storage.View{Name: "errors", Query: "level:ERROR", TimePreset: "1h"}
+
+	var out bytes.Buffer
+	if err := runQuery(&out, db, view, "service:payments", 10, now, writeJSONLines); err != nil {
+		t.Fatalf("runQuery() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], `"id":"pay"`) {
+		t.Fatalf("runQuery() output = %q, want only entry pay", out.String())
+	}
+
+	out.Reset()
+	if err := runQuery(&out, db, nil, "level:[bad", 10, now, writeJSONLines); err == nil {
+		t.Fatalf("runQuery() with invalid query error = nil")
+	}
+}
+
+func TestRunReparse(t *testing.T) {
+	db, err := storage.NewBadgerStorage(storage.Config{DBPath: t.TempDir(), RetentionSize: 1024 * 1024 * 100, RetentionDays: 7})
+	if err != nil {
+		t.Fatalf("NewBadgerStorage() error = %v", err)
+	}
+	defer db.Close()
+
+	// Stored as plain text, e.g. by an older parser that missed logfmt.
+	line := "level=error msg=\"db down\" source=api"
+	ts := time.Now().UTC().Add(-time.Minute)
+	if err := db.Store(&storage.LogEntry{ID: "a", Timestamp: ts, Level: "INFO", Message: line, Fields: map[string]interface{}{}, Raw: line}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := runReparse(&out, db, parser.NewDetector(), "level:INFO AND message:*=*", "auto", 0, outputText, nil); err != nil {
+		t.Fatalf("runReparse() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "1 updated") {
+		t.Fatalf("runReparse() output = %q, want 1 updated", out.String())
+	}
+
+	got, err := db.GetEntry("a")
+	if err != nil {
+		t.Fatalf("GetEntry() error = %v", err)
+	}
+	if got.Level != "ERROR" || got.Message != "db down" || got.Fields["source"] != "api" || !got.Timestamp.Equal(ts) {
+		t.Fatalf("reparsed entry = %+v, want ERROR/db down with source field and original timestamp", got)
+	}
+
+	if err := runReparse(&out, db, parser.NewDetector(), "level:[bad", "auto", 0, outputText, nil); err == nil {
+		t.Fatalf("runReparse() with invalid query error = nil")
+	}
+	if err := runReparse(&out, db, parser.NewDetector(), "", "xml", 0, outputText, nil); err == nil {
+		t.Fatalf("runReparse() with invalid format error = nil")
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+	_ "time/tzdata" // parsing.default_timezone works where the OS lacks a zone database
+
+	"github.com/mchurichi/peek/internal/config"
+	"github.com/mchurichi/peek/pkg/parser"
+	"github.com/mchurichi/peek/pkg/query"
+	"github.com/mchurichi/peek/pkg/server"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// ingestSettings are the parsing settings applied to every ingested line.
+// Live reload swaps them without restarting the session.
+type ingestSettings struct {
+	// detector knows the built-in and [[parsing.custom]] formats.
+	detector     *parser.Detector
+	format       string
+	newID        parser.IDGenerator
+	dedupeWindow time.Duration
+	// maxValueSize truncates longer messages and field values; 0 disables.
+	maxValueSize int
+	// host is attached to collected entries; nil unless host metadata is on.
+	host *storage.HostInfo
+	// multiline matches continuation lines joined onto the entry before
+	// them; nil disables joining.
+	multiline *regexp.Regexp
+	// sourceFormats maps source labels to the format their lines are
+	// parsed with instead of format ([parsing.sources]).
+	sourceFormats map[string]string
+}
+
+// multilineFor returns the continuation pattern for lines from source.
+// Without parsing.multiline_pattern, journald export entries are joined,
+// and auto mode joins stack traces (parser.AutoContinuation).
+func (s ingestSettings) multilineFor(source string) *regexp.Regexp {
+	if s.multiline != nil {
+		return s.multiline
+	}
+	switch s.formatFor(source) {
+	case "journald":
+		return parser.JournaldExportContinuation
+	case "auto":
+		return parser.AutoContinuation
+	}
+	return nil
+}
+
+// formatFor returns the format lines from source are parsed with.
+func (s ingestSettings) formatFor(source string) string {
+	if f, ok := s.sourceFormats[source]; ok {
+		return f
+	}
+	return s.format
+}
+
+// newIngestSettings validates the [parsing] config section.
+func newIngestSettings(p config.ParsingConfig) (ingestSettings, error) {
+	detector, err := newDetector(p)
+	if err != nil {
+		return ingestSettings{}, err
+	}
+	if p.Format != "" && !detector.ValidFormat(p.Format) {
+		return ingestSettings{}, fmt.Errorf("invalid parsing format %q (use %s)", p.Format, strings.Join(detector.Formats(), ", "))
+	}
+	newID, err := parser.NewIDGenerator(p.IDStrategy)
+	if err != nil {
+		return ingestSettings{}, fmt.Errorf("invalid parsing config: %w", err)
+	}
+	dedupeWindow, err := newDedupeWindow(&config.Config{Parsing: p})
+	if err != nil {
+		return ingestSettings{}, err
+	}
+	maxValueSize, err := newMaxValueSize(p)
+	if err != nil {
+		return ingestSettings{}, err
+	}
+	settings := ingestSettings{detector: detector, format: p.Format, newID: newID, dedupeWindow: dedupeWindow, maxValueSize: maxValueSize}
+	if p.HostMetadata {
+		settings.host = localHost()
+	}
+	if p.MultilinePattern != "" {
+		re, err := regexp.Compile(p.MultilinePattern)
+		if err != nil {
+			return ingestSettings{}, fmt.Errorf("invalid parsing multiline_pattern: %w", err)
+		}
+		settings.multiline = re
+	}
+	for name, src := range p.Sources {
+		if src.Format == "" {
+			continue
+		}
+		if !detector.ValidFormat(src.Format) {
+			return ingestSettings{}, fmt.Errorf("invalid parsing.sources.%s format %q (use %s)", name, src.Format, strings.Join(detector.Formats(), ", "))
+		}
+		if settings.sourceFormats == nil {
+			settings.sourceFormats = make(map[string]string)
+		}
+		settings.sourceFormats[name] = src.Format
+	}
+	return settings, nil
+}
+
+// setParserDefaults makes parsers and queries read timestamps without a
+// zone in parsing.default_timezone, and parsers read numeric levels with
+// parsing.numeric_levels.
+func setParserDefaults(p config.ParsingConfig) error {
+	var loc *time.Location
+	if p.DefaultTimezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(p.DefaultTimezone); err != nil {
+			return fmt.Errorf("invalid parsing default_timezone %q: %w", p.DefaultTimezone, err)
+		}
+	}
+	levels, err := parser.ParseNumericLevels(p.NumericLevels)
+	if err != nil {
+		return fmt.Errorf("invalid parsing.numeric_levels: %w", err)
+	}
+	parser.SetDefaultLocation(loc)
+	query.SetDefaultLocation(loc)
+	parser.SetNumericLevels(levels)
+	return nil
+}
+
+// newDetector builds a format detector that knows the [[parsing.custom]]
+// formats and applies [parsing.aliases].
+func newDetector(p config.ParsingConfig) (*parser.Detector, error) {
+	custom := make([]parser.CustomFormat, len(p.Custom))
+	for i, c := range p.Custom {
+		custom[i] = parser.CustomFormat{
+			Name:       c.Name,
+			Pattern:    c.Pattern,
+			Grok:       c.Grok,
+			Layout:     c.Layout,
+			Delimiter:  c.Delimiter,
+			Columns:    c.Columns,
+			TimeFormat: c.TimeFormat,
+			Command:    c.Command,
+		}
+		if c.Timeout != "" {
+			timeout, err := parseDuration(c.Timeout)
+			if err != nil || timeout <= 0 {
+				return nil, fmt.Errorf("invalid parsing.custom: format %s: invalid timeout %q", c.Name, c.Timeout)
+			}
+			custom[i].Timeout = timeout
+		}
+	}
+	detector, err := parser.NewDetectorWithCustom(custom)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parsing.custom: %w", err)
+	}
+	if err := detector.SetAliases(p.Aliases); err != nil {
+		return nil, fmt.Errorf("invalid parsing.aliases: %w", err)
+	}
+	return detector, nil
+}
+
+// parsingLoader re-reads the [parsing] config section for live reload.
+type parsingLoader func() (config.ParsingConfig, error)
+
+// newParsingLoader loads the parsing section from the config file at path,
+// then applies override so command-line flags keep winning after a reload.
+func newParsingLoader(path string, override func(p *config.ParsingConfig)) parsingLoader {
+	return func() (config.ParsingConfig, error) {
+		cfg, err := config.Load(path)
+		if err != nil {
+			return config.ParsingConfig{}, fmt.Errorf("failed to load config: %w", err)
+		}
+		override(&cfg.Parsing)
+		return cfg.Parsing, nil
+	}
+}
+
+// reloader applies a freshly loaded parsing config to a running process, on
+// POST /admin/reload or SIGHUP. The session, server and open connections are
+// kept, so fresh mode keeps its baseline.
+type reloader struct {
+	load parsingLoader
+	srv  *server.Server
+	coll *collector // nil in server mode
+	// detector is the one in effect, whose parser commands a reload stops.
+	detector *parser.Detector
+}
+
+// enable wires r into the server and reloads on SIGHUP until ctx is done.
+func (r *reloader) enable(ctx context.Context) {
+	r.srv.SetReloader(r.reload)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				if err := r.reload(); err != nil {
+					log.Printf("Reload failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// reload re-reads the parsing config and swaps it in. Invalid config is
+// rejected and the current settings stay in effect.
+func (r *reloader) reload() error {
+	p, err := r.load()
+	if err != nil {
+		return err
+	}
+	settings, err := newIngestSettings(p)
+	if err != nil {
+		return err
+	}
+	if err := setParserDefaults(p); err != nil {
+		return err
+	}
+
+	r.srv.SetIDGenerator(settings.newID)
+	r.srv.SetDedupeWindow(settings.dedupeWindow)
+	r.srv.SetMaxValueSize(settings.maxValueSize)
+	r.srv.SetDetector(settings.detector)
+	r.srv.SetSourceFormats(settings.sourceFormats)
+	if r.coll != nil {
+		r.coll.setSettings(settings)
+	}
+	if r.detector != nil {
+		r.detector.Close()
+	}
+	r.detector = settings.detector
+	log.Printf("Reloaded parsing config (format %s, id strategy %s, dedupe window %s)", p.Format, p.IDStrategy, settings.dedupeWindow)
+	return nil
+}
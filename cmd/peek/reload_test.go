@@ -0,0 +1,192 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/mchurichi/peek/internal/config"
+	"github.com/mchurichi/peek/pkg/parser"
+	"github.com/mchurichi/peek/pkg/query"
+	"github.com/mchurichi/peek/pkg/server"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+func TestReloaderSwapsParsingSettings(t *testing.T) {
+	db, err := storage.NewBadgerStorage(storage.Config{DBPath: t.TempDir(), RetentionSize: 1024 * 1024 * 100, RetentionDays: 7})
+	if err != nil {
+		t.Fatalf("NewBadgerStorage() error = %v", err)
+	}
+	defer db.Close()
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeConfig := func(body string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+
+	settings, err := newIngestSettings(config.ParsingConfig{Format: "auto"})
+	if err != nil {
+		t.Fatalf("newIngestSettings() error = %v", err)
+	}
+	c := &collector{session: "s1", settings: settings}
+	flagDedupe := ""
+	r := &reloader{
+		load: newParsingLoader(path, func(p *config.ParsingConfig) {
+			if flagDedupe != "" {
+				p.DedupeWindow = flagDedupe
+			}
+		}),
+		srv:  server.NewServer(db, "s1"),
+		coll: c,
+	}
+
+	writeConfig("[parsing]\nformat = \"logfmt\"\ndedupe_window = \"1h\"\n")
+	if err := r.reload(); err != nil {
+		t.Fatalf("reload() error = %v", err)
+	}
+	if got := c.currentSettings(); got.format != "logfmt" || got.dedupeWindow != time.Hour {
+		t.Fatalf("settings after reload = %+v, want logfmt with 1h dedupe", got)
+	}
+
+	// Command-line flags keep precedence over the reloaded file.
+	flagDedupe = "2h"
+	if err := r.reload(); err != nil {
+		t.Fatalf("reload() error = %v", err)
+	}
+	if got := c.currentSettings().dedupeWindow; got != 2*time.Hour {
+		t.Fatalf("dedupe window after reload = %s, want the flag's 2h", got)
+	}
+
+	// Invalid config is rejected and the running settings stay.
+	writeConfig("[parsing]\nformat = \"xml\"\n")
+	if err := r.reload(); err == nil {
+		t.Fatal("reload() with invalid format error = nil")
+	}
+	if got := c.currentSettings().format; got != "logfmt" {
+		t.Fatalf("format after failed reload = %q, want logfmt", got)
+	}
+	if c.session != "s1" {
+		t.Fatalf("session after reload = %q, want s1 kept", c.session)
+	}
+}
+
+func TestNewIngestSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		parsing config.ParsingConfig
+		wantErr bool
+	}{
+		{name: "defaults", parsing: config.ParsingConfig{}},
+		{name: "json ulid", parsing: config.ParsingConfig{Format: "json", IDStrategy: "ulid"}},
+		{name: "bad format", parsing: config.ParsingConfig{Format: "xml"}, wantErr: true},
+		{name: "bad id strategy", parsing: config.ParsingConfig{IDStrategy: "uuid"}, wantErr: true},
+		{name: "bad dedupe window", parsing: config.ParsingConfig{DedupeWindow: "soon"}, wantErr: true},
+		{name: "max value size", parsing: config.ParsingConfig{MaxValueSize: "16KB"}},
+		{name: "bad max value size", parsing: config.ParsingConfig{MaxValueSize: "big"}, wantErr: true},
+		{name: "multiline pattern", parsing: config.ParsingConfig{MultilinePattern: `^(\s|Caused by:)`}},
+		{name: "bad multiline pattern", parsing: config.ParsingConfig{MultilinePattern: `^(`}, wantErr: true},
+		{name: "custom format", parsing: config.ParsingConfig{Format: "legacy", Custom: []config.CustomFormatConfig{{Name: "legacy", Pattern: `^(?P<message>.*)$`}}}},
+		{name: "unknown custom format", parsing: config.ParsingConfig{Format: "legacy"}, wantErr: true},
+		{name: "bad custom pattern", parsing: config.ParsingConfig{Custom: []config.CustomFormatConfig{{Name: "legacy", Pattern: `^(?P<message>`}}}, wantErr: true},
+		{name: "grok format", parsing: config.ParsingConfig{Custom: []config.CustomFormatConfig{{Name: "api", Grok: `%{IP:client} %{GREEDYDATA:message}`}}}},
+		{name: "bad grok pattern", parsing: config.ParsingConfig{Custom: []config.CustomFormatConfig{{Name: "api", Grok: `%{NOPE:x}`}}}, wantErr: true},
+		{name: "source format", parsing: config.ParsingConfig{Sources: map[string]config.SourceConfig{"nginx": {Format: "access"}, "api": {}}}},
+		{name: "unknown source format", parsing: config.ParsingConfig{Sources: map[string]config.SourceConfig{"nginx": {Format: "access_log"}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newIngestSettings(tt.parsing); (err != nil) != tt.wantErr {
+				t.Fatalf("newIngestSettings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewIngestSettingsHostMetadata(t *testing.T) {
+	settings, err := newIngestSettings(config.ParsingConfig{})
+	if err != nil || settings.host != nil {
+		t.Fatalf("newIngestSettings() = %+v, %v, want no host", settings, err)
+	}
+	settings, err = newIngestSettings(config.ParsingConfig{HostMetadata: true})
+	if err != nil || settings.host == nil || settings.host.OS != runtime.GOOS {
+		t.Fatalf("newIngestSettings(host_metadata) = %+v, %v", settings, err)
+	}
+}
+
+func TestSetParserDefaults(t *testing.T) {
+	defer setParserDefaults(config.ParsingConfig{})
+
+	if err := setParserDefaults(config.ParsingConfig{DefaultTimezone: "Nowhere/City"}); err == nil {
+		t.Fatal("setParserDefaults(unknown zone) error = nil, want error")
+	}
+	if err := setParserDefaults(config.ParsingConfig{DefaultTimezone: "America/Argentina/Buenos_Aires"}); err != nil {
+		t.Fatalf("setParserDefaults() error = %v", err)
+	}
+	entry, err := parser.NewJSONParser().Parse(`{"time":"2026-02-17 10:30:45","msg":"hi"}`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if want := time.Date(2026, 2, 17, 13, 30, 45, 0, time.UTC); !entry.Timestamp.Equal(want) {
+		t.Fatalf("Timestamp = %v, want %v", entry.Timestamp, want)
+	}
+	q, err := query.Parse("timestamp:[2026-02-17T10:00:00 TO 2026-02-17T11:00:00]")
+	if err != nil {
+		t.Fatalf("query.Parse() error = %v", err)
+	}
+	if !q.Match(entry) {
+		t.Fatal("query in the default timezone does not match the entry")
+	}
+
+	if err := setParserDefaults(config.ParsingConfig{NumericLevels: map[string]string{"high": "ERROR"}}); err == nil {
+		t.Fatal("setParserDefaults(non-numeric level) error = nil, want error")
+	}
+	if err := setParserDefaults(config.ParsingConfig{NumericLevels: map[string]string{"1": "info", "5": "critical"}}); err != nil {
+		t.Fatalf("setParserDefaults(numeric_levels) error = %v", err)
+	}
+	if entry, err = parser.NewJSONParser().Parse(`{"level":5,"msg":"down"}`); err != nil || entry.Level != "FATAL" {
+		t.Fatalf("Parse() level = %q, %v, want FATAL", entry.Level, err)
+	}
+}
+
+func TestIngestSettingsFormatFor(t *testing.T) {
+	settings, err := newIngestSettings(config.ParsingConfig{Format: "json", Sources: map[string]config.SourceConfig{"nginx": {Format: "access"}, "api": {}}})
+	if err != nil {
+		t.Fatalf("newIngestSettings() error = %v", err)
+	}
+	for source, want := range map[string]string{"nginx": "access", "api": "json", "": "json"} {
+		if got := settings.formatFor(source); got != want {
+			t.Errorf("formatFor(%q) = %q, want %q", source, got, want)
+		}
+	}
+}
+
+func TestIngestSettingsMultilineFor(t *testing.T) {
+	settings, err := newIngestSettings(config.ParsingConfig{Format: "json", Sources: map[string]config.SourceConfig{"journal": {Format: "journald"}}})
+	if err != nil {
+		t.Fatalf("newIngestSettings() error = %v", err)
+	}
+	if settings.multilineFor("api") != nil || settings.multilineFor("journal") != parser.JournaldExportContinuation {
+		t.Fatal("journald sources should join export entries, others nothing")
+	}
+
+	settings, err = newIngestSettings(config.ParsingConfig{Format: "journald", MultilinePattern: `^\s`})
+	if err != nil {
+		t.Fatalf("newIngestSettings() error = %v", err)
+	}
+	if re := settings.multilineFor(""); re == nil || re.String() != `^\s` {
+		t.Fatalf("multilineFor() = %v, want the configured pattern", re)
+	}
+
+	settings, err = newIngestSettings(config.ParsingConfig{Format: "auto", Sources: map[string]config.SourceConfig{"api": {Format: "json"}}})
+	if err != nil {
+		t.Fatalf("newIngestSettings() error = %v", err)
+	}
+	if settings.multilineFor("") != parser.AutoContinuation || settings.multilineFor("api") != nil {
+		t.Fatal("auto mode should join stack traces, explicit formats nothing")
+	}
+}
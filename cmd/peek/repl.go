@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mchurichi/peek/internal/config"
+	"github.com/mchurichi/peek/pkg/query"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// replMaxHistory caps the queries kept in the history file.
+const replMaxHistory = 1000
+
+func runReplCommand(args []string) error {
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	configPath := fs.String("config", "~/.peek/config.toml", "Path to config file")
+	serverURL := fs.String("url", "", "URL of the peek server to query (default: http://localhost:<server.port>)")
+	token := fs.String("token", "", "API token sent as a bearer token")
+	page := fs.Int("page", 20, "Entries per page")
+	fields := fs.String("fields", "", "Comma-separated fields to print as columns")
+	noColor := fs.Bool("no-color", false, "Disable colors (also honors NO_COLOR)")
+	historyPath := fs.String("history", "~/.peek/repl_history", "File keeping query history across sessions (empty disables it)")
+	fs.Parse(args)
+
+	if err := validateNoPositionalArgs(fs.Args()); err != nil {
+		return err
+	}
+	if *page < 1 {
+		return fmt.Errorf("invalid --page %d (use 1 or more)", *page)
+	}
+	base := *serverURL
+	if base == "" {
+		cfg, err := config.Load(*configPath)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		base = fmt.Sprintf("http://localhost:%d", cfg.Server.Port)
+	}
+	if u, err := url.Parse(base); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid --url %q (use http://host:port)", base)
+	}
+
+	r := &repl{
+		client:  &replClient{base: strings.TrimSuffix(base, "/"), token: *token, http: &http.Client{Timeout: 30 * time.Second}},
+		out:     os.Stdout,
+		format:  prettyFormatter{color: useColor(os.Stdout, *noColor), fields: parseFieldList(*fields)},
+		page:    *page,
+		history: loadHistory(expandPath(*historyPath)),
+	}
+	if *historyPath != "" {
+		r.historyPath = expandPath(*historyPath)
+	}
+
+	fmt.Fprintf(r.out, "Querying %s. Tab completes fields and values, Enter on an empty line shows more, Ctrl+D exits.\n", r.client.base)
+	// Raw mode is only available on a terminal; switch it on per prompt so
+	// results print in the terminal's normal mode.
+	if restore, err := makeRaw(os.Stdin.Fd()); err == nil {
+		restore()
+		return r.run(func(prompt string) (string, error) {
+			restore, err := makeRaw(os.Stdin.Fd())
+			if err != nil {
+				return "", err
+			}
+			defer restore()
+			e := &lineEditor{in: r.stdin(), out: os.Stdout, history: r.history, complete: r.complete}
+			return e.readLine(prompt)
+		})
+	}
+	// Not a terminal: read plain lines, as from a script.
+	in := bufio.NewScanner(os.Stdin)
+	return r.run(func(prompt string) (string, error) {
+		if !in.Scan() {
+			if err := in.Err(); err != nil {
+				return "", err
+			}
+			return "", io.EOF
+		}
+		return in.Text(), nil
+	})
+}
+
+// repl runs queries typed at a prompt against a peek server and pages
+// through the results.
+type repl struct {
+	client      *replClient
+	out         io.Writer
+	format      prettyFormatter
+	page        int
+	history     []string
+	historyPath string // "" keeps history in memory only
+	in          *bufio.Reader
+
+	// The query being paged through and how many entries are shown.
+	last  string
+	shown int
+	total int
+}
+
+// stdin returns one buffered reader of os.Stdin shared by every prompt, so
+// keys typed ahead are not lost between lines.
+func (r *repl) stdin() *bufio.Reader {
+	if r.in == nil {
+		r.in = bufio.NewReader(os.Stdin)
+	}
+	return r.in
+}
+
+// run reads lines with readLine until EOF, running each as a query. An
+// empty line shows the next page of the last query.
+func (r *repl) run(readLine func(prompt string) (string, error)) error {
+	for {
+		line, err := readLine("peek> ")
+		if errors.Is(err, errInterrupted) {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		line = strings.TrimSpace(line)
+		switch line {
+		case "exit", "quit", `\q`:
+			return nil
+		case "":
+			if r.last == "" || r.shown >= r.total {
+				continue
+			}
+		default:
+			r.remember(line)
+			r.last, r.shown, r.total = line, 0, 0
+		}
+		if err := r.showPage(); err != nil {
+			fmt.Fprintf(r.out, "Error: %v\n", err)
+		}
+	}
+}
+
+// showPage prints the next page of the last query.
+func (r *repl) showPage() error {
+	entries, total, err := r.client.query(context.Background(), r.last, r.page, r.shown)
+	if err != nil {
+		return err
+	}
+	if err := r.format.write(r.out, entries); err != nil {
+		return err
+	}
+	r.shown += len(entries)
+	r.total = total
+	switch {
+	case r.total == 0:
+		fmt.Fprintln(r.out, r.format.paint(ansiDim, "No matching entries."))
+	case r.shown < r.total:
+		fmt.Fprintln(r.out, r.format.paint(ansiDim, fmt.Sprintf("-- %d of %d, Enter for more --", r.shown, r.total)))
+	default:
+		fmt.Fprintln(r.out, r.format.paint(ansiDim, fmt.Sprintf("-- %d of %d --", r.shown, r.total)))
+	}
+	return nil
+}
+
+// complete asks the server for completions of line at cursor.
+func (r *repl) complete(line string, cursor int) (start, end int, candidates []string) {
+	c, err := r.client.complete(context.Background(), line, cursor)
+	if err != nil {
+		return 0, 0, nil
+	}
+	for _, cand := range c.Candidates {
+		candidates = append(candidates, cand.Text)
+	}
+	return c.Start, c.End, candidates
+}
+
+// remember adds line to the history unless it repeats the last one, and
+// appends it to the history file.
+func (r *repl) remember(line string) {
+	if n := len(r.history); n > 0 && r.history[n-1] == line {
+		return
+	}
+	r.history = append(r.history, line)
+	if len(r.history) > replMaxHistory {
+		r.history = r.history[len(r.history)-replMaxHistory:]
+	}
+	if r.historyPath == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(r.historyPath), 0o755); err != nil {
+		return
+	}
+	f, err := os.OpenFile(r.historyPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
+
+// loadHistory reads the last replMaxHistory lines of the history file at
+// path. A missing file is an empty history.
+func loadHistory(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var history []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			history = append(history, line)
+		}
+	}
+	if len(history) > replMaxHistory {
+		history = history[len(history)-replMaxHistory:]
+	}
+	return history
+}
+
+// replClient calls the query API of a peek server.
+type replClient struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+// query returns a page of the entries matching q, newest first, and the
+// total number of matches.
+func (c *replClient) query(ctx context.Context, q string, limit, offset int) ([]*storage.LogEntry, int, error) {
+	body, err := json.Marshal(map[string]interface{}{"query": q, "limit": limit, "offset": offset})
+	if err != nil {
+		return nil, 0, err
+	}
+	var res struct {
+		Logs  []*storage.LogEntry `json:"logs"`
+		Total int                 `json:"total"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/query", bytes.NewReader(body), &res); err != nil {
+		return nil, 0, err
+	}
+	return res.Logs, res.Total, nil
+}
+
+// complete returns the server's completions of q at the byte offset cursor.
+func (c *replClient) complete(ctx context.Context, q string, cursor int) (query.Completions, error) {
+	var res query.Completions
+	path := "/complete?" + url.Values{"q": {q}, "cursor": {fmt.Sprint(cursor)}}.Encode()
+	err := c.do(ctx, http.MethodGet, path, nil, &res)
+	return res, err
+}
+
+// do sends a request and decodes the JSON answer into v. Error answers
+// return the message of their error envelope.
+func (c *replClient) do(ctx context.Context, method, path string, body io.Reader, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && e.Error.Message != "" {
+			return errors.New(e.Error.Message)
+		}
+		return fmt.Errorf("server answered %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
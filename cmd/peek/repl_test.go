@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/query"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+func TestReplRunPagesQueries(t *testing.T) {
+	ts := time.Date(2026, 3, 10, 15, 30, 0, 0, time.UTC)
+	var requests []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/query":
+			var req map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&req)
+			requests = append(requests, req)
+			if req["query"] == "bad:[" {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, `{"error":{"code":"invalid_query","message":"Invalid query: invalid range format"}}`)
+				return
+			}
+			offset := int(req["offset"].(float64))
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"logs":  []*storage.LogEntry{{ID: "e", Timestamp: ts, Level: "ERROR", Message: "failure " + string(rune('a'+offset))}},
+				"total": 2,
+			})
+		case "/complete":
+			c := query.Complete(r.URL.Query().Get("q"), len(r.URL.Query().Get("q")), []storage.FieldInfo{{Name: "level"}})
+			json.NewEncoder(w).Encode(c)
+		}
+	}))
+	defer srv.Close()
+
+	var out strings.Builder
+	historyPath := filepath.Join(t.TempDir(), "history")
+	r := &repl{
+		client:      &replClient{base: srv.URL, token: "secret", http: srv.Client()},
+		out:         &out,
+		page:        1,
+		historyPath: historyPath,
+	}
+	lines := []string{"level:ERROR", "", "", "bad:[", "quit", "never run"}
+	err := r.run(func(string) (string, error) {
+		line := lines[0]
+		lines = lines[1:]
+		return line, nil
+	})
+	if err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	if len(requests) != 3 || requests[1]["offset"] != float64(1) || requests[1]["limit"] != float64(1) {
+		t.Fatalf("requests = %v, want the query, its second page and the bad query", requests)
+	}
+	got := out.String()
+	for _, want := range []string{"failure a", "-- 1 of 2, Enter for more --", "failure b", "-- 2 of 2 --", "Error: Invalid query: invalid range format"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output = %q, want %q", got, want)
+		}
+	}
+	if strings.Count(got, "failure") != 2 {
+		t.Errorf("output = %q, want no third page", got)
+	}
+
+	data, err := os.ReadFile(historyPath)
+	if err != nil || string(data) != "level:ERROR\nbad:[\n" {
+		t.Fatalf("history file = %q, %v", data, err)
+	}
+	if h := loadHistory(historyPath); !reflect.DeepEqual(h, []string{"level:ERROR", "bad:["}) {
+		t.Fatalf("loadHistory() = %q", h)
+	}
+
+	start, end, candidates := r.complete("lev", 3)
+	if start != 0 || end != 3 || !reflect.DeepEqual(candidates, []string{"level:"}) {
+		t.Fatalf("complete() = %d, %d, %q", start, end, candidates)
+	}
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mchurichi/peek/internal/config"
+	"github.com/mchurichi/peek/pkg/query"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// Report output formats.
+const (
+	reportHTML     = "html"
+	reportMarkdown = "md"
+)
+
+// reportBucketWidths are the volume chart bucket sizes, the smallest of
+// which gives at most reportMaxBuckets bars.
+var reportBucketWidths = []time.Duration{
+	time.Minute, 5 * time.Minute, 15 * time.Minute, time.Hour, 6 * time.Hour, 24 * time.Hour, 7 * 24 * time.Hour,
+}
+
+const reportMaxBuckets = 60
+
+func runReportCommand(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	configPath := fs.String("config", "~/.peek/config.toml", "Path to config file")
+	dbPath := fs.String("db-path", "", "Database path (overrides config)")
+	queryStr := fs.String("query", "", "Only report entries matching this query")
+	start := fs.String("start", "", "Start of the range: RFC 3339 time, date, or a duration ago (e.g., 2h, 7d)")
+	end := fs.String("end", "", "End of the range: RFC 3339 time, date, or a duration ago")
+	out := fs.String("o", "", "File to write (default: stdout); a .md name writes Markdown")
+	format := fs.String("format", "", "Report format: html or md (default: from the -o extension, else html)")
+	title := fs.String("title", "", "Report title (default: the query and range)")
+	limit := fs.Int("limit", 50, "Most recent matching entries to include")
+	patterns := fs.Int("patterns", 10, "Top ERROR/WARN message patterns to include")
+	fs.Parse(args)
+
+	if err := validateNoPositionalArgs(fs.Args()); err != nil {
+		return err
+	}
+	if *format == "" {
+		*format = reportHTML
+		if ext := strings.ToLower(filepath.Ext(*out)); ext == ".md" || ext == ".markdown" {
+			*format = reportMarkdown
+		}
+	}
+	if *format != reportHTML && *format != reportMarkdown {
+		return fmt.Errorf("invalid --format %q (use html or md)", *format)
+	}
+	if *limit < 0 || *patterns < 0 {
+		return fmt.Errorf("--limit and --patterns must not be negative")
+	}
+	now := time.Now()
+	tr := &storage.TimeRange{}
+	var err error
+	if tr.Start, err = parseReportTime(*start, now); err != nil {
+		return fmt.Errorf("invalid --start: %w", err)
+	}
+	if tr.End, err = parseReportTime(*end, now); err != nil {
+		return fmt.Errorf("invalid --end: %w", err)
+	}
+	if !tr.Start.IsZero() && !tr.End.IsZero() && tr.End.Before(tr.Start) {
+		return fmt.Errorf("--end is before --start")
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if *dbPath != "" {
+		cfg.Storage.DBPath = *dbPath
+	}
+	if err := setParserDefaults(cfg.Parsing); err != nil {
+		return err
+	}
+	storageCfg, err := newStorageConfig(cfg)
+	if err != nil {
+		return err
+	}
+	db, err := storage.NewBadgerStorage(storageCfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer db.Close()
+
+	rep, err := buildReport(context.Background(), db, *queryStr, tr, *limit, *patterns)
+	if err != nil {
+		return err
+	}
+	rep.Title = *title
+	if rep.Title == "" {
+		rep.Title = "Peek report: " + rep.describe()
+	}
+	rep.Generated = now
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if *format == reportMarkdown {
+		err = writeMarkdownReport(w, rep)
+	} else {
+		err = writeHTMLReport(w, rep)
+	}
+	if err != nil {
+		return err
+	}
+	if *out != "" {
+		fmt.Fprintf(os.Stderr, "Wrote %s report of %d entries to %s\n", *format, rep.Total, *out)
+	}
+	return nil
+}
+
+// parseReportTime reads a --start or --end value: an RFC 3339 time, a
+// date, or a duration before now. Empty is no bound.
+func parseReportTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	if d, err := parseDuration(s); err == nil && d > 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not an RFC 3339 time, date or duration", s)
+}
+
+// report is what peek report writes: a summary of the entries matching a
+// query in a time range.
+type report struct {
+	Title     string
+	Query     string
+	Range     storage.TimeRange // requested range; zero bounds are open
+	Generated time.Time
+
+	Total       int
+	First, Last time.Time // oldest and newest matching entry
+	Levels      []levelCount
+	BucketWidth time.Duration
+	Volume      []volumeBucket
+	Patterns    []storage.DigestPattern
+	Entries     []reportEntry // most recent matches, newest first
+}
+
+type levelCount struct {
+	Level string
+	Count int
+}
+
+type volumeBucket struct {
+	Start time.Time
+	Count int
+}
+
+// reportEntry is a matching entry with its original line.
+type reportEntry struct {
+	*storage.LogEntry
+	Line string
+}
+
+// describe names the query and range of r.
+func (r *report) describe() string {
+	q := r.Query
+	if q == "" {
+		q = "all entries"
+	}
+	from, to := "the beginning", "now"
+	if !r.Range.Start.IsZero() {
+		from = r.Range.Start.Local().Format(time.DateTime)
+	}
+	if !r.Range.End.IsZero() {
+		to = r.Range.End.Local().Format(time.DateTime)
+	}
+	return fmt.Sprintf("%s, %s to %s", q, from, to)
+}
+
+// buildReport scans the entries matching queryStr within tr once for the
+// totals, level breakdown, volume buckets and the limit newest entries,
+// and adds the top message patterns.
+func buildReport(ctx context.Context, db *storage.BadgerStorage, queryStr string, tr *storage.TimeRange, limit, patterns int) (*report, error) {
+	var filter query.Filter = &query.AllFilter{}
+	if queryStr != "" {
+		q, err := query.Parse(queryStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid query: %w", err)
+		}
+		filter = q
+	}
+	if !tr.Start.IsZero() || !tr.End.IsZero() {
+		filter = &query.AndFilter{Left: filter, Right: &query.TimestampRangeFilter{Start: tr.Start, End: tr.End}}
+	}
+
+	rep := &report{Query: queryStr, Range: *tr}
+	levels := make(map[string]int)
+	minutes := make(map[int64]int)
+	var recent []reportEntry // ring of the newest limit entries
+	err := db.ScanRaw(ctx, filter, tr, func(e *storage.LogEntry, raw string) error {
+		rep.Total++
+		if rep.First.IsZero() || e.Timestamp.Before(rep.First) {
+			rep.First = e.Timestamp
+		}
+		if e.Timestamp.After(rep.Last) {
+			rep.Last = e.Timestamp
+		}
+		level := e.Level
+		if level == "" {
+			level = "(none)"
+		}
+		levels[level]++
+		minutes[e.Timestamp.Unix()/60]++
+		if limit > 0 {
+			if len(recent) == limit {
+				recent = recent[1:]
+			}
+			recent = append(recent, reportEntry{LogEntry: e, Line: raw})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan failed: %w", err)
+	}
+
+	for level, n := range levels {
+		rep.Levels = append(rep.Levels, levelCount{Level: level, Count: n})
+	}
+	sort.Slice(rep.Levels, func(i, j int) bool {
+		if rep.Levels[i].Count != rep.Levels[j].Count {
+			return rep.Levels[i].Count > rep.Levels[j].Count
+		}
+		return rep.Levels[i].Level < rep.Levels[j].Level
+	})
+	rep.BucketWidth, rep.Volume = volumeBuckets(minutes, rep.First, rep.Last)
+	sort.SliceStable(recent, func(i, j int) bool { return recent[i].Timestamp.After(recent[j].Timestamp) })
+	rep.Entries = recent
+
+	if patterns > 0 && rep.Total > 0 {
+		rep.Patterns, err = db.GetDigest(ctx, tr.Start, filter, patterns)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get patterns: %w", err)
+		}
+	}
+	return rep, nil
+}
+
+// volumeBuckets groups per-minute counts into evenly sized buckets from
+// first to last, empty ones included, choosing the smallest width of
+// reportBucketWidths that needs at most reportMaxBuckets.
+func volumeBuckets(minutes map[int64]int, first, last time.Time) (time.Duration, []volumeBucket) {
+	if len(minutes) == 0 {
+		return 0, nil
+	}
+	width := reportBucketWidths[len(reportBucketWidths)-1]
+	for _, w := range reportBucketWidths {
+		if last.Sub(first.Truncate(w)) < time.Duration(reportMaxBuckets)*w {
+			width = w
+			break
+		}
+	}
+	start := first.Truncate(width)
+	buckets := make([]volumeBucket, int(last.Sub(start)/width)+1)
+	for i := range buckets {
+		buckets[i].Start = start.Add(time.Duration(i) * width)
+	}
+	for minute, n := range minutes {
+		i := int(time.Unix(minute*60, 0).Sub(start) / width)
+		buckets[max(0, min(i, len(buckets)-1))].Count += n
+	}
+	return width, buckets
+}
+
+// reportTemplate renders a report as a standalone HTML page: no scripts or
+// external assets, so it can be attached to a ticket and opened anywhere.
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"time":   func(t time.Time) string { return t.Local().Format("2006-01-02 15:04:05.000") },
+	"height": barHeight,
+	"level":  func(l string) string { return strings.ToLower(l) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem auto; max-width: 72rem; padding: 0 1rem; color: #1f2328; }
+h1 { font-size: 1.5rem; } h2 { font-size: 1.15rem; margin-top: 2rem; border-bottom: 1px solid #d0d7de; }
+table { border-collapse: collapse; width: 100%; font-size: .9rem; }
+th, td { text-align: left; padding: .3rem .6rem; border-bottom: 1px solid #eaeef2; vertical-align: top; }
+td.n { text-align: right; font-variant-numeric: tabular-nums; }
+.meta { color: #59636e; }
+.chart { display: flex; align-items: flex-end; gap: 2px; height: 8rem; border-bottom: 1px solid #d0d7de; }
+.chart div { flex: 1; background: #0969da; min-height: 1px; }
+pre { margin: 0; white-space: pre-wrap; word-break: break-all; font-size: .85rem; }
+.error, .fatal { color: #cf222e; } .warn { color: #9a6700; } .info { color: #1a7f37; } .debug, .trace { color: #59636e; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">Query <code>{{if .Query}}{{.Query}}{{else}}*{{end}}</code> · generated {{time .Generated}}</p>
+
+<h2>Summary</h2>
+<table>
+<tr><th>Matching entries</th><td class="n">{{.Total}}</td></tr>
+{{- if .Total}}
+<tr><th>First</th><td>{{time .First}}</td></tr>
+<tr><th>Last</th><td>{{time .Last}}</td></tr>
+{{- end}}
+{{- range .Levels}}
+<tr><th class="{{level .Level}}">{{.Level}}</th><td class="n">{{.Count}}</td></tr>
+{{- end}}
+</table>
+{{- if .Volume}}
+
+<h2>Volume</h2>
+<p class="meta">Entries per {{.BucketWidth}}</p>
+<div class="chart">
+{{- range .Volume}}
+<div style="height: {{height $ .Count}}%" title="{{time .Start}}: {{.Count}}"></div>
+{{- end}}
+</div>
+{{- end}}
+{{- if .Patterns}}
+
+<h2>Top patterns</h2>
+<table>
+<tr><th>Count</th><th>Level</th><th>Pattern</th><th>First</th><th>Last</th></tr>
+{{- range .Patterns}}
+<tr><td class="n">{{.Count}}</td><td class="{{level .Level}}">{{.Level}}</td><td><code>{{.Pattern}}</code></td><td>{{time .FirstSeen}}</td><td>{{time .LastSeen}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Entries}}
+
+<h2>Most recent entries</h2>
+<table>
+<tr><th>Time</th><th>Level</th><th>Line</th></tr>
+{{- range .Entries}}
+<tr><td>{{time .Timestamp}}</td><td class="{{level .Level}}">{{.Level}}</td><td><pre>{{.Line}}</pre></td></tr>
+{{- end}}
+</table>
+{{- end}}
+</body>
+</html>
+`))
+
+// barHeight is a volume bar's height as a percentage of the busiest one.
+func barHeight(r *report, n int) int {
+	peak := 0
+	for _, b := range r.Volume {
+		peak = max(peak, b.Count)
+	}
+	if peak == 0 {
+		return 0
+	}
+	return n * 100 / peak
+}
+
+// writeHTMLReport writes r as a standalone HTML page.
+func writeHTMLReport(w io.Writer, r *report) error {
+	return reportTemplate.Execute(w, r)
+}
+
+// writeMarkdownReport writes r as Markdown, with the volume as a table.
+func writeMarkdownReport(w io.Writer, r *report) error {
+	ts := func(t time.Time) string { return t.Local().Format("2006-01-02 15:04:05.000") }
+	q := r.Query
+	if q == "" {
+		q = "*"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\nQuery `%s` · generated %s\n\n", r.Title, q, ts(r.Generated))
+
+	fmt.Fprintf(&b, "## Summary\n\n| | |\n|---|--:|\n| Matching entries | %d |\n", r.Total)
+	if r.Total > 0 {
+		fmt.Fprintf(&b, "| First | %s |\n| Last | %s |\n", ts(r.First), ts(r.Last))
+	}
+	for _, l := range r.Levels {
+		fmt.Fprintf(&b, "| %s | %d |\n", l.Level, l.Count)
+	}
+
+	if len(r.Volume) > 0 {
+		fmt.Fprintf(&b, "\n## Volume\n\nEntries per %s.\n\n| Start | Entries |\n|---|--:|\n", r.BucketWidth)
+		for _, v := range r.Volume {
+			fmt.Fprintf(&b, "| %s | %d |\n", ts(v.Start), v.Count)
+		}
+	}
+
+	if len(r.Patterns) > 0 {
+		b.WriteString("\n## Top patterns\n\n| Count | Level | Pattern | First | Last |\n|--:|---|---|---|---|\n")
+		for _, p := range r.Patterns {
+			fmt.Fprintf(&b, "| %d | %s | `%s` | %s | %s |\n", p.Count, p.Level, markdownCell(p.Pattern), ts(p.FirstSeen), ts(p.LastSeen))
+		}
+	}
+
+	if len(r.Entries) > 0 {
+		b.WriteString("\n## Most recent entries\n\n```\n")
+		for _, e := range r.Entries {
+			b.WriteString(strings.ReplaceAll(e.Line, "```", "` ` `"))
+			b.WriteString("\n")
+		}
+		b.WriteString("```\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownCell escapes text for a Markdown table cell.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	s = strings.ReplaceAll(s, "`", "'")
+	return strings.ReplaceAll(s, "\n", " ")
+}
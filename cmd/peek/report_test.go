@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+func TestBuildReport(t *testing.T) {
+	db, err := storage.NewBadgerStorage(storage.Config{DBPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewBadgerStorage() error = %v", err)
+	}
+	defer db.Close()
+
+	base := time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC)
+	for i := 0; i < 30; i++ {
+		level := "INFO"
+		msg := fmt.Sprintf("request %d served", i)
+		if i%3 == 0 {
+			level, msg = "ERROR", fmt.Sprintf("timeout after %dms", 100+i)
+		}
+		e := &storage.LogEntry{
+			ID:        fmt.Sprintf("e%02d", i),
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+			Level:     level,
+			Message:   msg,
+			Fields:    map[string]interface{}{"service": "checkout"},
+			Raw:       fmt.Sprintf("%s %s", level, msg),
+		}
+		if err := db.Store(e); err != nil {
+			t.Fatalf("Store() error = %v", err)
+		}
+	}
+
+	tr := &storage.TimeRange{Start: base.Add(5 * time.Minute), End: base.Add(24 * time.Minute)}
+	rep, err := buildReport(context.Background(), db, "service:checkout", tr, 3, 5)
+	if err != nil {
+		t.Fatalf("buildReport() error = %v", err)
+	}
+	if rep.Total != 20 || !rep.First.Equal(tr.Start) || !rep.Last.Equal(tr.End) {
+		t.Fatalf("report total %d from %v to %v, want 20 from %v to %v", rep.Total, rep.First, rep.Last, tr.Start, tr.End)
+	}
+	if len(rep.Levels) != 2 || rep.Levels[0] != (levelCount{"INFO", 13}) || rep.Levels[1] != (levelCount{"ERROR", 7}) {
+		t.Fatalf("levels = %v", rep.Levels)
+	}
+	if rep.BucketWidth != time.Minute || len(rep.Volume) != 20 || rep.Volume[0].Count != 1 {
+		t.Fatalf("volume = %s x %d", rep.BucketWidth, len(rep.Volume))
+	}
+	if len(rep.Entries) != 3 || rep.Entries[0].ID != "e24" || rep.Entries[2].ID != "e22" || rep.Entries[0].Line != "ERROR timeout after 124ms" {
+		t.Fatalf("entries = %+v, want e24..e22 newest first", rep.Entries)
+	}
+	if len(rep.Patterns) != 1 || rep.Patterns[0].Count != 7 {
+		t.Fatalf("patterns = %+v, want the timeout pattern 7 times", rep.Patterns)
+	}
+
+	if _, err := buildReport(context.Background(), db, "level:(", tr, 3, 5); err == nil {
+		t.Fatal("buildReport() with an invalid query succeeded")
+	}
+}
+
+func TestVolumeBuckets(t *testing.T) {
+	first := time.Date(2026, 3, 10, 15, 7, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		span      time.Duration
+		wantWidth time.Duration
+		wantLen   int
+	}{
+		{name: "minutes", span: 30 * time.Minute, wantWidth: time.Minute, wantLen: 31},
+		{name: "five minutes", span: 3 * time.Hour, wantWidth: 5 * time.Minute, wantLen: 37},
+		{name: "hours", span: 2 * 24 * time.Hour, wantWidth: time.Hour, wantLen: 49},
+		{name: "days", span: 30 * 24 * time.Hour, wantWidth: 24 * time.Hour, wantLen: 31},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			last := first.Add(tt.span)
+			minutes := map[int64]int{first.Unix() / 60: 2, last.Unix() / 60: 3}
+			width, buckets := volumeBuckets(minutes, first, last)
+			if width != tt.wantWidth || len(buckets) != tt.wantLen {
+				t.Fatalf("volumeBuckets() = %s x %d, want %s x %d", width, len(buckets), tt.wantWidth, tt.wantLen)
+			}
+			if buckets[0].Count != 2 || buckets[len(buckets)-1].Count != 3 {
+				t.Fatalf("first and last buckets = %d, %d, want 2, 3", buckets[0].Count, buckets[len(buckets)-1].Count)
+			}
+		})
+	}
+}
+
+func TestWriteReports(t *testing.T) {
+	ts := time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC)
+	rep := &report{
+		Title:       "Checkout <outage>",
+		Query:       "level:ERROR",
+		Generated:   ts,
+		Total:       2,
+		First:       ts,
+		Last:        ts.Add(time.Minute),
+		Levels:      []levelCount{{"ERROR", 2}},
+		BucketWidth: time.Minute,
+		Volume:      []volumeBucket{{ts, 1}, {ts.Add(time.Minute), 1}},
+		Patterns:    []storage.DigestPattern{{Level: "ERROR", Pattern: "a|b <N>", Count: 2, FirstSeen: ts, LastSeen: ts}},
+		Entries:     []reportEntry{{LogEntry: &storage.LogEntry{Timestamp: ts, Level: "ERROR"}, Line: `<script>alert("x")</script>`}},
+	}
+
+	var out bytes.Buffer
+	if err := writeHTMLReport(&out, rep); err != nil {
+		t.Fatalf("writeHTMLReport() error = %v", err)
+	}
+	html := out.String()
+	if strings.Contains(html, "<script>") || !strings.Contains(html, "&lt;script&gt;") || !strings.Contains(html, "Checkout &lt;outage&gt;") {
+		t.Fatalf("writeHTMLReport() did not escape entries:\n%s", html)
+	}
+	if strings.Count(html, `style="height: 100%"`) != 2 {
+		t.Fatalf("writeHTMLReport() bars:\n%s", html)
+	}
+
+	out.Reset()
+	if err := writeMarkdownReport(&out, rep); err != nil {
+		t.Fatalf("writeMarkdownReport() error = %v", err)
+	}
+	md := out.String()
+	for _, want := range []string{"# Checkout <outage>", "| Matching entries | 2 |", "| 2 | ERROR | `a\\|b <N>` |", "## Most recent entries"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("writeMarkdownReport() is missing %q:\n%s", want, md)
+		}
+	}
+}
+
+func TestParseReportTime(t *testing.T) {
+	now := time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: "", want: time.Time{}},
+		{in: "2026-03-09T08:00:00Z", want: time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC)},
+		{in: "2026-03-09", want: time.Date(2026, 3, 9, 0, 0, 0, 0, time.Local)},
+		{in: "2h", want: now.Add(-2 * time.Hour)},
+		{in: "1d", want: now.Add(-24 * time.Hour)},
+		{in: "yesterday", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseReportTime(tt.in, now)
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("parseReportTime(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
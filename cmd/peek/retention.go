@@ -0,0 +1,155 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mchurichi/peek/internal/config"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// runDbRetention applies the retention policy now, or with --simulate
+// reports what it would delete. --retention-size and --retention-days
+// override the configured policy, so a tighter one can be tried first.
+func runDbRetention(args []string) error {
+	fs := flag.NewFlagSet("db retention", flag.ExitOnError)
+	configPath := fs.String("config", "~/.peek/config.toml", "Path to config file")
+	dbPath := fs.String("db-path", "", "Database path (overrides config)")
+	retentionSize := fs.String("retention-size", "", "Max storage size (e.g., 1GB, 500MB; overrides config)")
+	retentionDays := fs.Int("retention-days", 0, "Max age of logs in days (overrides config)")
+	simulate := fs.Bool("simulate", false, "Report what the policy would delete without deleting")
+	force := fs.Bool("force", false, "Skip confirmation prompt")
+	output := fs.String("output", outputText, "Output format: text or json (requires --force or --simulate)")
+	quiet := fs.Bool("quiet", false, "Don't print progress")
+	fs.Parse(args)
+
+	if err := validateNoPositionalArgs(fs.Args()); err != nil {
+		return err
+	}
+	if err := checkTextOrJSON(*output); err != nil {
+		return err
+	}
+	if *output == outputJSON && !*force && !*simulate {
+		return fmt.Errorf("--output json requires --force or --simulate")
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if *dbPath != "" {
+		cfg.Storage.DBPath = *dbPath
+	}
+	if *retentionSize != "" {
+		if _, err := config.ParseSize(*retentionSize); err != nil {
+			return fmt.Errorf("invalid --retention-size: %w", err)
+		}
+		cfg.Storage.RetentionSize = *retentionSize
+	}
+	if *retentionDays > 0 {
+		cfg.Storage.RetentionDays = *retentionDays
+	}
+	sizeBytes, days := cfg.GetRetentionSizeBytes(), cfg.Storage.RetentionDays
+
+	storageCfg, err := newStorageConfig(cfg)
+	if err != nil {
+		return err
+	}
+	// Opening the database sweeps retention; sweep here instead, after the
+	// plan is known.
+	storageCfg.RetentionSize, storageCfg.RetentionDays = 0, 0
+
+	db, err := storage.NewBadgerStorage(storageCfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer db.Close()
+
+	plan, err := db.PlanRetention(sizeBytes, days)
+	if err != nil {
+		return fmt.Errorf("failed to plan retention: %w", err)
+	}
+	if *simulate {
+		if *output == outputJSON {
+			return writeJSONLine(os.Stdout, plan)
+		}
+		printDeletePlan(os.Stdout, plan)
+		return nil
+	}
+
+	if plan.Entries == 0 {
+		if *output == outputJSON {
+			return writeJSONLine(os.Stdout, cleanReport{})
+		}
+		fmt.Println("Nothing is due for deletion under the retention policy.")
+		return nil
+	}
+	if !*force {
+		printDeletePlan(os.Stdout, plan)
+		fmt.Printf("⚠️  Delete these entries and then attempt to reclaim disk space? [y/N] ")
+		var response string
+		fmt.Scanln(&response)
+		if strings.ToLower(response) != "y" && strings.ToLower(response) != "yes" {
+			fmt.Println("Aborted.")
+			return nil
+		}
+	}
+
+	before, err := db.GetStats()
+	if err != nil {
+		return fmt.Errorf("failed to get stats: %w", err)
+	}
+	if err := db.EnforceRetention(sizeBytes, days); err != nil {
+		return fmt.Errorf("failed to enforce retention: %w", err)
+	}
+	after, err := db.GetStats()
+	if err != nil {
+		return fmt.Errorf("failed to get stats: %w", err)
+	}
+	deleted := before.TotalLogs - after.TotalLogs
+
+	if *output == outputText {
+		fmt.Printf("Deleted %d entries.\n", deleted)
+	}
+	return compactAfterDelete(db, deleted, *output, *quiet)
+}
+
+// printDeletePlan writes what a delete would remove: totals, the breakdown
+// by level and source, and the oldest entry that would be kept.
+func printDeletePlan(w io.Writer, plan storage.DeletePlan) {
+	fmt.Fprintf(w, "Would delete %d entries (~%s)\n", plan.Entries, formatBytes(plan.Bytes))
+	printPlanCounts(w, "By level", plan.Levels)
+	printPlanCounts(w, "By source", plan.Sources)
+	switch {
+	case plan.OldestSurviving != nil:
+		fmt.Fprintf(w, "\nOldest surviving entry: %s\n", plan.OldestSurviving.Local().Format(time.RFC3339))
+	case plan.Entries > 0:
+		fmt.Fprintln(w, "\nOldest surviving entry: none (every entry would be deleted)")
+	}
+}
+
+// printPlanCounts writes counts largest first; entries without a name are
+// listed as "(none)".
+func printPlanCounts(w io.Writer, title string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	names := slices.SortedFunc(maps.Keys(counts), func(a, b string) int {
+		return cmp.Or(cmp.Compare(counts[b], counts[a]), cmp.Compare(a, b))
+	})
+	fmt.Fprintf(w, "\n%s:\n", title)
+	for _, name := range names {
+		label := name
+		if label == "" {
+			label = "(none)"
+		}
+		fmt.Fprintf(w, "  %8d  %s\n", counts[name], label)
+	}
+}
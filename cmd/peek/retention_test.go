@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+func TestPrintDeletePlan(t *testing.T) {
+	oldest := time.Date(2026, 3, 10, 15, 30, 0, 0, time.UTC)
+	plan := storage.DeletePlan{
+		Entries:         12,
+		Bytes:           2048,
+		Levels:          map[string]int{"INFO": 2, "DEBUG": 10},
+		Sources:         map[string]int{"": 5, "worker": 5, "api": 2},
+		OldestSurviving: &oldest,
+	}
+
+	var out bytes.Buffer
+	printDeletePlan(&out, plan)
+	text := out.String()
+	for _, want := range []string{
+		"Would delete 12 entries (~2.0 KB)",
+		"        10  DEBUG\n         2  INFO\n",
+		"         5  (none)\n         5  worker\n         2  api\n",
+		"Oldest surviving entry: " + oldest.Local().Format(time.RFC3339),
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("printDeletePlan() = %q, want %q", text, want)
+		}
+	}
+
+	out.Reset()
+	printDeletePlan(&out, storage.DeletePlan{})
+	if text := out.String(); text != "Would delete 0 entries (~0 B)\n" {
+		t.Fatalf("printDeletePlan(empty) = %q", text)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/scheduler"
+	"github.com/mchurichi/peek/pkg/server"
+)
+
+// shutdownTimeout bounds how long shutdown waits for in-flight requests.
+const shutdownTimeout = 10 * time.Second
+
+// stopServices stops everything that may still write to or read from
+// storage, in order: the server finishes in-flight /ingest requests and
+// closes live connections, then cancel stops the scheduler and SIGHUP
+// reloader and the scheduler's current run is waited for. Callers defer it
+// after opening storage so the deferred db.Close runs last, and Close in
+// turn waits for the retention worker.
+func stopServices(srv *server.Server, cancel context.CancelFunc, sched *scheduler.Runner) {
+	ctx, done := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer done()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Warning: server shutdown: %v", err)
+	}
+	cancel()
+	sched.Wait()
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mchurichi/peek/internal/config"
+	"github.com/mchurichi/peek/pkg/scheduler"
+	"github.com/mchurichi/peek/pkg/server"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+func newShutdownCollector(t *testing.T) (*collector, *storage.BadgerStorage) {
+	t.Helper()
+	db, err := storage.NewBadgerStorage(storage.Config{DBPath: t.TempDir(), RetentionSize: 1024 * 1024 * 100, RetentionDays: 7})
+	if err != nil {
+		t.Fatalf("NewBadgerStorage() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	settings, err := newIngestSettings(config.ParsingConfig{Format: "auto"})
+	if err != nil {
+		t.Fatalf("newIngestSettings() error = %v", err)
+	}
+	return &collector{
+		cfg:      config.DefaultConfig(),
+		db:       db,
+		srv:      server.NewServer(db, "s1"),
+		session:  "s1",
+		settings: settings,
+	}, db
+}
+
+func TestReadFromStoresUntilEOF(t *testing.T) {
+	c, db := newShutdownCollector(t)
+
+	input := `{"level":"INFO","message":"one"}` + "\n\n" + `{"level":"ERROR","message":"two"}` + "\n"
+	if err := c.readFrom(context.Background(), strings.NewReader(input)); err != nil {
+		t.Fatalf("readFrom() error = %v", err)
+	}
+	if c.count != 2 {
+		t.Fatalf("count = %d, want 2", c.count)
+	}
+	if stats, _ := db.GetStats(); stats.TotalLogs != 2 {
+		t.Fatalf("stored = %d, want 2", stats.TotalLogs)
+	}
+}
+
+func TestReadFromStoresLinesInBatches(t *testing.T) {
+	c, db := newShutdownCollector(t)
+
+	var input strings.Builder
+	for i := 0; i < collectBatchLines+1; i++ {
+		fmt.Fprintf(&input, `{"level":"INFO","message":"line %d"}`+"\n", i)
+	}
+	before := db.Generation()
+	if err := c.readFrom(context.Background(), strings.NewReader(input.String())); err != nil {
+		t.Fatalf("readFrom() error = %v", err)
+	}
+	if stats, _ := db.GetStats(); stats.TotalLogs != collectBatchLines+1 || c.count != collectBatchLines+1 {
+		t.Fatalf("stored = %d, count = %d, want %d", stats.TotalLogs, c.count, collectBatchLines+1)
+	}
+	// Each write bumps the generation; a write per line would add 501.
+	if writes := db.Generation() - before; writes > 10 {
+		t.Fatalf("%d writes for %d lines, want them batched", writes, collectBatchLines+1)
+	}
+}
+
+func TestReadFromStoresPartialBatchAfterInterval(t *testing.T) {
+	c, db := newShutdownCollector(t)
+
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.readFrom(ctx, pr)
+
+	if _, err := io.WriteString(pw, `{"level":"INFO","message":"alone"}`+"\n"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	deadline := time.Now().Add(collectFlushInterval + 2*time.Second)
+	for {
+		if stats, _ := db.GetStats(); stats.TotalLogs == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("queued entry was not stored while the input stayed open")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestReadFromStopsOnCancelWithoutLosingReadLines(t *testing.T) {
+	c, db := newShutdownCollector(t)
+
+	// The writer never closes, like a producer still piping when SIGTERM
+	// arrives.
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.readFrom(ctx, pr) }()
+
+	// io.Pipe hands each write over only once it is read, so both lines
+	// have reached the collector when the second write returns.
+	for _, line := range []string{`{"level":"INFO","message":"a"}` + "\n", `{"level":"INFO","message":"b"}` + "\n"} {
+		if _, err := io.WriteString(pw, line); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("readFrom() error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("readFrom did not return after cancel")
+	}
+	if stats, _ := db.GetStats(); stats.TotalLogs < 1 || stats.TotalLogs != c.count {
+		t.Fatalf("stored = %d, collected = %d; want every collected line stored", stats.TotalLogs, c.count)
+	}
+}
+
+func TestStopServicesStopsSchedulerAndServer(t *testing.T) {
+	c, _ := newShutdownCollector(t)
+	c.srv.StartBroadcastWorker()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sched := scheduler.New(c.db, time.Hour)
+	sched.Start(ctx)
+
+	stopped := make(chan struct{})
+	go func() {
+		stopServices(c.srv, cancel, sched)
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stopServices did not return")
+	}
+	if ctx.Err() == nil {
+		t.Fatal("stopServices did not cancel the worker context")
+	}
+}
+
+func TestReadFromJoinsMultilineRecords(t *testing.T) {
+	c, db := newShutdownCollector(t)
+	settings, err := newIngestSettings(config.ParsingConfig{Format: "auto", MultilinePattern: `^(\s|Caused by:)`})
+	if err != nil {
+		t.Fatalf("newIngestSettings() error = %v", err)
+	}
+	c.settings = settings
+
+	input := `{"level":"ERROR","message":"boom"}` + "\n" +
+		"\tat com.example.A.run(A.java:10)\n" +
+		"Caused by: java.io.IOException\n" +
+		"\n" +
+		"\tat orphan.Frame\n" +
+		"next line\n"
+	if err := c.readFrom(context.Background(), strings.NewReader(input)); err != nil {
+		t.Fatalf("readFrom() error = %v", err)
+	}
+	if c.count != 3 {
+		t.Fatalf("count = %d, want 3", c.count)
+	}
+
+	messages := map[string]string{}
+	db.Scan(func(e *storage.LogEntry) error {
+		messages[e.Message] = e.Level
+		return nil
+	})
+	want := "boom\n\tat com.example.A.run(A.java:10)\nCaused by: java.io.IOException"
+	if level, ok := messages[want]; !ok || level != "ERROR" {
+		t.Fatalf("stored messages = %q, want the joined ERROR entry %q", messages, want)
+	}
+	if _, ok := messages["\tat orphan.Frame"]; !ok {
+		t.Fatalf("stored messages = %q, want the frame after an empty line stored alone", messages)
+	}
+}
+
+func TestReadFromFlushesMultilineRecordAfterTimeout(t *testing.T) {
+	c, db := newShutdownCollector(t)
+	settings, err := newIngestSettings(config.ParsingConfig{Format: "auto", MultilinePattern: `^\s`})
+	if err != nil {
+		t.Fatalf("newIngestSettings() error = %v", err)
+	}
+	c.settings = settings
+
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.readFrom(ctx, pr)
+
+	if _, err := io.WriteString(pw, "panic: oops\n  main.go:12\n"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	deadline := time.Now().Add(multilineFlushTimeout + 2*time.Second)
+	for {
+		if stats, _ := db.GetStats(); stats.TotalLogs == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("open multiline record was not stored after the flush timeout")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd)
+
+package main
+
+import "errors"
+
+// makeRaw can't switch the terminal to raw input on this platform, which
+// leaves peek repl reading plain lines without editing or completion.
+func makeRaw(fd uintptr) (restore func(), err error) {
+	return nil, errors.New("raw terminal input is not supported on this platform")
+}
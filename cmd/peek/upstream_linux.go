@@ -0,0 +1,48 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// upstreamCommand returns the command line of the process writing to
+// peek's stdin pipe, as in "kubectl logs api-7f9c -f", or "" when stdin is
+// not a pipe or its writer can't be read.
+func upstreamCommand() string {
+	link, err := os.Readlink("/proc/self/fd/0")
+	if err != nil || !strings.HasPrefix(link, "pipe:") {
+		return ""
+	}
+	return pipeWriter("/proc", link, os.Getpid())
+}
+
+// pipeWriter finds the process other than self whose stdout is the pipe
+// link and returns its command line.
+func pipeWriter(procDir, link string, self int) string {
+	dirs, err := os.ReadDir(procDir)
+	if err != nil {
+		return ""
+	}
+	for _, d := range dirs {
+		pid, err := strconv.Atoi(d.Name())
+		if err != nil || pid == self {
+			continue
+		}
+		dir := filepath.Join(procDir, d.Name())
+		if out, err := os.Readlink(filepath.Join(dir, "fd", "1")); err != nil || out != link {
+			continue
+		}
+		cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline"))
+		if err != nil {
+			continue
+		}
+		if name := commandName(strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")); name != "" {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,38 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestPipeWriter(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	cmd := exec.Command("sleep", "5")
+	cmd.Stdout = w
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot run sleep: %v", err)
+	}
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+	w.Close()
+
+	link, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", r.Fd()))
+	if err != nil {
+		t.Skipf("no /proc: %v", err)
+	}
+	if got := pipeWriter("/proc", link, os.Getpid()); got != "sleep 5" {
+		t.Errorf("pipeWriter() = %q, want %q", got, "sleep 5")
+	}
+	if got := pipeWriter("/proc", "pipe:[0]", os.Getpid()); got != "" {
+		t.Errorf("pipeWriter(unknown pipe) = %q, want empty", got)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+// upstreamCommand can't find the process writing to stdin on this
+// platform, which leaves sessions unnamed unless --name is given.
+func upstreamCommand() string {
+	return ""
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mchurichi/peek/internal/config"
+	"github.com/mchurichi/peek/pkg/server"
+)
+
+// Restart backoff bounds for peek watch.
+const (
+	watchMinBackoff = time.Second
+	watchMaxBackoff = 30 * time.Second
+)
+
+func runWatchCommand(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	configPath := fs.String("config", "~/.peek/config.toml", "Path to config file")
+	dbPath := fs.String("db-path", "", "Database path (overrides config)")
+	format := fs.String("format", "", "Log format: auto, access, cef, csv, gelf, journald, json, klog, leef, log4j, logfmt, syslog, tsv")
+	dedupe := fs.String("dedupe", "", "Skip lines already ingested within this window (e.g., 24h, 7d)")
+	port := fs.Int("port", 0, "HTTP server port")
+	noBrowser := fs.Bool("no-browser", false, "Don't auto-open browser")
+	printURLOnly := fs.Bool("print-url-only", false, "Print the web UI URL instead of opening a browser")
+	all := fs.Bool("all", false, "Show all historic logs alongside new ones")
+	maxBackoff := fs.String("max-backoff", watchMaxBackoff.String(), "Longest wait between restarts (e.g., 30s, 5m)")
+	source := fs.String("source", "", "Source recorded on collected entries (default: the command name)")
+	name := fs.String("name", "", "Name recorded on the collect session (default: the command line)")
+	hostMetadata := fs.Bool("host-metadata", false, "Attach this machine's hostname, OS and user to collected entries")
+	debugPipeline := fs.Bool("debug-pipeline", false, "Trace a sample of ingested entries for /pipeline-debug")
+	fs.Parse(args)
+
+	argv := fs.Args()
+	if len(argv) == 0 {
+		return fmt.Errorf("missing command (usage: peek watch [OPTIONS] -- COMMAND [ARGS...])")
+	}
+	if _, err := exec.LookPath(argv[0]); err != nil {
+		return fmt.Errorf("cannot run %s: %w", argv[0], err)
+	}
+	backoffCap, err := parseDuration(*maxBackoff)
+	if err != nil || backoffCap < watchMinBackoff {
+		return fmt.Errorf("invalid --max-backoff %q (minimum %s)", *maxBackoff, watchMinBackoff)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if *dbPath != "" {
+		cfg.Storage.DBPath = *dbPath
+	}
+	applyParsingFlags := func(p *config.ParsingConfig) {
+		if *format != "" {
+			p.Format = *format
+			p.Sources = nil
+		}
+		if *dedupe != "" {
+			p.DedupeWindow = *dedupe
+		}
+		if *hostMetadata {
+			p.HostMetadata = true
+		}
+	}
+	applyParsingFlags(&cfg.Parsing)
+	if err := setParserDefaults(cfg.Parsing); err != nil {
+		return err
+	}
+	if *port > 0 {
+		cfg.Server.Port = *port
+	}
+	if *noBrowser {
+		cfg.Server.AutoOpenBrowser = false
+	}
+	cfg.Server.PrintURLOnly = *printURLOnly
+	if *debugPipeline && cfg.Server.DebugPipeline == 0 {
+		cfg.Server.DebugPipeline = defaultPipelineSample
+	}
+
+	sessionName := *name
+	if sessionName == "" {
+		sessionName = commandName(argv)
+	}
+	return collect(cfg, *all, sessionName, newParsingLoader(*configPath, applyParsingFlags), func(c *collector) error {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		c.source = *source
+		if c.source == "" {
+			c.source = argv[0]
+		}
+		c.input = "watch"
+		log.Printf("Watching %q — press Ctrl+C to exit", argv)
+		report := func(state string, err error) { c.srv.SetSourceStatus(argv[0], state, err) }
+		supervise(ctx, argv, func(r io.Reader) error { return c.readFrom(ctx, r) }, report, watchMinBackoff, backoffCap)
+		log.Println("Shutting down...")
+		c.finish()
+		return nil
+	})
+}
+
+// supervise runs argv until ctx is done, feeding its stdout to ingest. When
+// the command exits it is started again after a backoff that doubles up to
+// maxBackoff and resets once a run outlasts maxBackoff. report, when
+// non-nil, is told when the command runs, restarts and stops.
+func supervise(ctx context.Context, argv []string, ingest func(io.Reader) error, report func(state string, err error), minBackoff, maxBackoff time.Duration) {
+	if report == nil {
+		report = func(string, error) {}
+	}
+	defer report(server.SourceStopped, nil)
+
+	backoff := minBackoff
+	for {
+		started := time.Now()
+		report(server.SourceRunning, nil)
+		err := runWatched(ctx, argv, ingest)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > maxBackoff {
+			backoff = minBackoff
+		}
+
+		status := "exited"
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			status = fmt.Sprintf("exited with status %d", exitErr.ExitCode())
+		} else if err != nil {
+			status = fmt.Sprintf("failed: %v", err)
+		}
+		log.Printf("watch: %s %s; restarting in %s", argv[0], status, backoff)
+		if err == nil {
+			err = errors.New("exited")
+		}
+		report(server.SourceRestarting, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// runWatched runs argv once, passing stderr through and its stdout to ingest.
+func runWatched(ctx context.Context, argv []string, ingest func(io.Reader) error) error {
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	ingestErr := ingest(stdout)
+	if ingestErr != nil {
+		// Unblock the child if we stopped reading early.
+		io.Copy(io.Discard, stdout)
+	}
+	if err := cmd.Wait(); err != nil {
+		return err
+	}
+	return ingestErr
+}
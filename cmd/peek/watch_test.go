@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSupervise(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var lines []string
+	ingest := func(r io.Reader) error {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			mu.Lock()
+			lines = append(lines, scanner.Text())
+			if len(lines) == 3 {
+				cancel()
+			}
+			mu.Unlock()
+		}
+		return scanner.Err()
+	}
+
+	var states []string
+	report := func(state string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		states = append(states, state)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		supervise(ctx, []string{"sh", "-c", "echo run; exit 3"}, ingest, report, time.Millisecond, 10*time.Millisecond)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("supervise() did not stop after cancel")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got := strings.Join(lines, ","); got != "run,run,run" {
+		t.Fatalf("ingested lines = %q, want output of three runs", got)
+	}
+	if got := strings.Join(states, ","); !strings.HasPrefix(got, "running,restarting,running") || !strings.HasSuffix(got, "stopped") {
+		t.Fatalf("reported states = %q, want running and restarting, then stopped", got)
+	}
+}
+
+func TestRunWatchCommandValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "missing command", args: []string{"--no-browser"}},
+		{name: "unknown command", args: []string{"--", "peek-no-such-command"}},
+		{name: "invalid backoff", args: []string{"--max-backoff", "10ms", "--", "sh"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := runWatchCommand(tt.args); err == nil {
+				t.Fatalf("runWatchCommand(%v) error = nil", tt.args)
+			}
+		})
+	}
+}
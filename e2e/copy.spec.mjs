@@ -131,4 +131,29 @@ test.describe('copy', () => {
     );
     expect(query.length).toBeGreaterThan(0);
   });
+
+  test('row link button copies a deep link that reopens the entry', async ({ page }) => {
+    await mockClipboard(page);
+    await page.goto(baseURL);
+
+    await expect.poll(
+      () => page.evaluate(() => document.querySelectorAll('.log-row').length),
+      { timeout: 8_000 },
+    ).toBeGreaterThanOrEqual(1);
+
+    const id = await page.evaluate(() => document.querySelector('.log-row')?.dataset.id);
+    await page.evaluate(() => document.querySelector('.row-link-btn')?.click());
+    await expect.poll(() => page.evaluate(() => window.__clipboardWrites.at(-1))).toBe(`${baseURL}/#/log/${id}`);
+
+    await page.goto(`${baseURL}/#/log/${id}`);
+    await page.reload();
+    await expect(page.locator('.search-editor-input')).toHaveValue(`id:${id}`);
+    await expect(page.locator('.log-row')).toHaveCount(1);
+    await expect(page.locator('.detail-row.visible')).toHaveCount(1);
+  });
+
+  test('deep link to a missing entry shows an empty state', async ({ page }) => {
+    await page.goto(`${baseURL}/#/log/does-not-exist`);
+    await expect(page.locator('.empty-state')).toContainText('Linked entry not found');
+  });
 });
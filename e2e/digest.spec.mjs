@@ -0,0 +1,46 @@
+/**
+ * digest.spec.mjs — Header error digest.
+ */
+
+import { test, expect } from '@playwright/test';
+import { portForTestFile, startServer, stopServer } from './helpers.mjs';
+
+let server;
+let baseURL;
+
+test.describe('digest', () => {
+  test.beforeAll(async ({}, workerInfo) => {
+    const port = portForTestFile(workerInfo);
+    const now = Date.now();
+    const lines = [
+      ...[120, 340, 95].map((ms, i) => JSON.stringify({
+        level: 'ERROR',
+        msg: `upstream timeout after ${ms}ms`,
+        time: new Date(now - (i + 1) * 60_000).toISOString(),
+      })),
+      JSON.stringify({ level: 'INFO', msg: 'healthy', time: new Date(now).toISOString() }),
+    ];
+
+    server = await startServer(port, { lines });
+    baseURL = `http://localhost:${port}`;
+  });
+
+  test.afterAll(async () => {
+    await stopServer(server);
+  });
+
+  test('lists recurring error patterns and narrows the query on click', async ({ page }) => {
+    await page.goto(baseURL);
+
+    const digestBtn = page.locator('[data-testid="digest-btn"]');
+    await expect(digestBtn).toContainText('3 errors · 1 pattern (1h)');
+    await digestBtn.click();
+
+    const item = page.locator('[data-testid="digest-item"]');
+    await expect(item).toHaveCount(1);
+    await expect(item).toContainText('3× ERROR upstream timeout after <num>ms');
+    await item.click();
+
+    await expect(page.locator('.search-editor-input')).toHaveValue('level:ERROR AND message:"upstream timeout after 120ms"');
+  });
+});
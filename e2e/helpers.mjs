@@ -17,6 +17,7 @@ const POLL_INTERVAL_MS = 1_000;
 const FILE_PORT_OFFSETS = Object.freeze({
   'copy.spec.mjs': 8,
   'datetime.spec.mjs': 0,
+  'digest.spec.mjs': 10,
   'field-filter-append.spec.mjs': 1,
   'levelless.spec.mjs': 2,
   'resize.spec.mjs': 3,
@@ -1,9 +1,10 @@
 /**
- * query-history.spec.mjs — Query history and starred queries.
+ * query-history.spec.mjs — Query history, starred queries and saved views.
  */
 
 import { test, expect } from '@playwright/test';
 import {
+  getHeaders,
   portForTestFile,
   readJSONLocalStorage,
   startServer,
@@ -153,4 +154,26 @@ test.describe('query-history', () => {
     await searchInput.press('Escape');
     await expect(page.locator('.search-autocomplete-item').first()).toBeHidden();
   });
+
+  test('applies a server-side saved view from the views tab', async ({ page }) => {
+    const res = await fetch(`${baseURL}/views/Payments%20errors`, {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ query: 'level:ERROR', columns: ['service'], time_preset: 'all' }),
+    });
+    expect(res.status).toBe(200);
+
+    await page.goto(baseURL);
+    const searchInput = page.locator('.search-editor-input');
+    await expect(searchInput).toBeVisible();
+
+    await page.locator('[data-testid="history-btn"]').click();
+    await page.locator('[data-testid="views-tab"]').click();
+    const viewItem = page.locator('[data-testid="view-item"]', { hasText: 'Payments errors' });
+    await expect(viewItem).toBeVisible();
+    await viewItem.click();
+
+    await expect(searchInput).toHaveValue('level:ERROR');
+    await expect.poll(() => getHeaders(page)).toContain('service');
+  });
 });
@@ -14,25 +14,164 @@ type Config struct {
 	Storage StorageConfig `toml:"storage"`
 	Server  ServerConfig  `toml:"server"`
 	Parsing ParsingConfig `toml:"parsing"`
+	UI      UIConfig      `toml:"ui"`
+	Auth    AuthConfig    `toml:"auth"`
+	Audit   AuditConfig   `toml:"audit"`
 }
 
 // StorageConfig holds storage-related configuration
 type StorageConfig struct {
-	RetentionSize string `toml:"retention_size"` // e.g., "1GB", "500MB"
-	RetentionDays int    `toml:"retention_days"`
-	DBPath        string `toml:"db_path"`
+	RetentionSize string       `toml:"retention_size"` // e.g., "1GB", "500MB"
+	RetentionDays int          `toml:"retention_days"`
+	DBPath        string       `toml:"db_path"`
+	MinFreeSpace  string       `toml:"min_free_space"` // pause storing below this much free disk; "" disables
+	Badger        BadgerConfig `toml:"badger"`
+	// MaintenanceWindow is a daily local-time window (e.g. "03:00-05:00",
+	// or "03:00" for an hour) for size-cap sweeps and full compaction;
+	// outside it only the age limit is enforced. "" runs everything on writes.
+	MaintenanceWindow string `toml:"maintenance_window"`
+}
+
+// BadgerConfig holds BadgerDB tuning options. Empty sizes and zero values keep
+// Badger's defaults.
+type BadgerConfig struct {
+	SyncWrites       bool   `toml:"sync_writes"`
+	ValueLogFileSize string `toml:"value_log_file_size"` // e.g., "256MB"
+	MemTableSize     string `toml:"memtable_size"`       // e.g., "64MB"
+	Compression      string `toml:"compression"`         // none, snappy, zstd
+	NumCompactors    int    `toml:"num_compactors"`
 }
 
 // ServerConfig holds server-related configuration
 type ServerConfig struct {
 	Port            int  `toml:"port"`
 	AutoOpenBrowser bool `toml:"auto_open_browser"`
+	// BrowserCommand opens the web UI instead of the platform's default
+	// opener, e.g. "firefox --new-window {url}". {url} is replaced by the
+	// UI's address; without it the address is appended.
+	BrowserCommand string `toml:"browser_command"`
+	// PrintURLOnly prints the UI's address instead of opening a browser.
+	// It is set by --print-url-only and can't be configured.
+	PrintURLOnly bool `toml:"-"`
+	// MDNS advertises the UI on the local network as peek-<host>.local.
+	MDNS bool `toml:"mdns"`
+	// Federate lists other peek instances (e.g. "http://hostA:8080") whose
+	// logs /query and live tails include.
+	Federate []string `toml:"federate"`
+	// FederateToken is the bearer token sent to federated peers.
+	FederateToken string `toml:"federate_token"`
+	// UIDir serves the web UI from this directory (index.html plus the
+	// files it loads from /assets/) instead of the embedded one.
+	UIDir string `toml:"ui_dir"`
+	// FrameAncestors lists the CSP sources allowed to embed the UI in a
+	// frame (e.g. "'self'"); empty allows none.
+	FrameAncestors []string `toml:"frame_ancestors"`
+	// ContentSecurityPolicy replaces the UI page's generated policy.
+	ContentSecurityPolicy string `toml:"content_security_policy"`
+	// AllowedOrigins are origins, besides the server's own, whose pages may
+	// call mutating endpoints while auth is enabled (e.g. a proxy's address).
+	AllowedOrigins []string `toml:"allowed_origins"`
+	// GELFUDP receives Graylog Extended Log Format messages over UDP on
+	// this address (e.g. ":12201"); empty disables the listener.
+	GELFUDP string `toml:"gelf_udp"`
+	// GRPC serves the gRPC API on this address (e.g. ":9090"); empty
+	// disables it.
+	GRPC string `toml:"grpc"`
+	// DebugPipeline traces one in every DebugPipeline ingested entries
+	// (parser, transforms, parse and store latency) for GET
+	// /pipeline-debug; 0 disables it. --debug-pipeline enables it at
+	// a default rate.
+	DebugPipeline int `toml:"debug_pipeline"`
 }
 
 // ParsingConfig holds parsing-related configuration
 type ParsingConfig struct {
-	Format        string `toml:"format"` // auto, json, logfmt
+	Format        string `toml:"format"` // auto, access, cef, csv, gelf, journald, json, klog, leef, log4j, logfmt, syslog, tsv
 	AutoTimestamp bool   `toml:"auto_timestamp"`
+	IDStrategy    string `toml:"id_strategy"` // random, ulid, hash
+	// DefaultTimezone is the IANA zone (e.g. "America/Argentina/Buenos_Aires")
+	// that timestamps without a zone are read in, by parsers and queries.
+	// Empty reads them in local time when parsing and UTC in queries.
+	DefaultTimezone string `toml:"default_timezone"`
+	// DedupeWindow skips lines already ingested within this duration
+	// (e.g. "24h", "7d"); empty disables duplicate detection.
+	DedupeWindow string `toml:"dedupe_window"`
+	// MaxValueSize truncates the message and field values longer than this
+	// size (e.g. "16KB") at ingest; empty keeps values whole.
+	MaxValueSize string `toml:"max_value_size"`
+	// HostMetadata attaches this machine's hostname, OS and user to every
+	// collected entry.
+	HostMetadata bool `toml:"host_metadata"`
+	// MultilinePattern is a regular expression matching continuation lines
+	// (e.g. `^\s` for indented stack frames); in collect mode they are
+	// joined onto the entry before them. Empty stores every line separately.
+	MultilinePattern string `toml:"multiline_pattern"`
+	// NumericLevels maps level numbers to names ([parsing.numeric_levels],
+	// e.g. "30" = "INFO"), replacing the pino/bunyan table. A number reads
+	// as the level of the highest mapped number not above it.
+	NumericLevels map[string]string `toml:"numeric_levels"`
+	// Aliases rename fields of parsed entries ([parsing.aliases], e.g.
+	// lvl = "level", "log.level" = "level"); timestamp, level, message,
+	// trace_id and span_id targets fill the entry's own fields.
+	Aliases map[string]string `toml:"aliases"`
+	// Custom declares user-defined regex formats ([[parsing.custom]]).
+	Custom []CustomFormatConfig `toml:"custom"`
+	// Sources sets parsing per source label ([parsing.sources.api]), for
+	// inputs collected or pushed with that --source.
+	Sources map[string]SourceConfig `toml:"sources"`
+}
+
+// SourceConfig overrides parsing for the lines of one source.
+type SourceConfig struct {
+	Format string `toml:"format"` // a built-in or custom format name; empty uses parsing.format
+}
+
+// CustomFormatConfig is a user-defined format: a regex (or Grok expression)
+// whose named captures timestamp, level and message fill the entry, with
+// every other named capture stored as a field. Name selects it with --format.
+// A delimiter or columns make it a delimited (CSV/TSV) format instead, a
+// layout a log4j/logback pattern layout, and a command an external program
+// that answers each line with the entry as JSON.
+type CustomFormatConfig struct {
+	Name       string   `toml:"name"`
+	Pattern    string   `toml:"pattern"`
+	Grok       string   `toml:"grok"`        // e.g. "%{IP:client} %{WORD:method} %{URIPATH:path}"; instead of pattern
+	Layout     string   `toml:"layout"`      // log4j/logback PatternLayout, e.g. "%d [%t] %-5p %c - %m%n"; instead of pattern
+	Delimiter  string   `toml:"delimiter"`   // e.g. "," or "\t"; instead of pattern
+	Columns    []string `toml:"columns"`     // column names; empty reads them from the header row
+	TimeFormat string   `toml:"time_format"` // Go layout, e.g. "2006-01-02 15:04:05"; empty means RFC 3339
+	Command    []string `toml:"command"`     // program and arguments that parse each line; instead of pattern
+	Timeout    string   `toml:"timeout"`     // how long command may take per line, e.g. "2s"; default 5s
+}
+
+// UIConfig holds defaults for the web UI's initial view. Preferences saved in
+// the browser take precedence over these values.
+type UIConfig struct {
+	DefaultTimePreset string   `toml:"default_time_preset"` // all, 15m, 1h, 6h, 24h, 7d, today, yesterday
+	DefaultQuery      string   `toml:"default_query"`
+	PinnedColumns     []string `toml:"pinned_columns"`
+	Theme             string   `toml:"theme"` // dark, light
+	AutoScroll        bool     `toml:"auto_scroll"`
+}
+
+// AuthConfig maps API tokens to namespaces. Authentication is enabled when at
+// least one token is configured.
+type AuthConfig struct {
+	Tokens []TokenConfig `toml:"tokens"`
+}
+
+// TokenConfig is one API token. Entries pushed with it are stored in
+// Namespace and its queries only see that namespace unless Admin is set.
+type TokenConfig struct {
+	Token     string `toml:"token"`
+	Namespace string `toml:"namespace"`
+	Admin     bool   `toml:"admin"`
+}
+
+// AuditConfig controls the audit log of queries run against the API.
+type AuditConfig struct {
+	Enabled   bool   `toml:"enabled"`
+	Retention string `toml:"retention"` // e.g., "7d", "24h"
 }
 
 // DefaultConfig returns the default configuration
@@ -43,6 +182,10 @@ func DefaultConfig() *Config {
 			RetentionSize: "1GB",
 			RetentionDays: 7,
 			DBPath:        filepath.Join(home, ".peek", "db"),
+			MinFreeSpace:  "500MB",
+			Badger: BadgerConfig{
+				SyncWrites: true,
+			},
 		},
 		Server: ServerConfig{
 			Port:            8080,
@@ -51,6 +194,16 @@ func DefaultConfig() *Config {
 		Parsing: ParsingConfig{
 			Format:        "auto",
 			AutoTimestamp: true,
+			IDStrategy:    "random",
+		},
+		UI: UIConfig{
+			DefaultTimePreset: "all",
+			Theme:             "dark",
+			AutoScroll:        true,
+		},
+		Audit: AuditConfig{
+			Enabled:   true,
+			Retention: "7d",
 		},
 	}
 }
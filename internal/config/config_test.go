@@ -39,6 +39,14 @@ func TestDefaultConfig(t *testing.T) {
 	if cfg.Parsing.AutoTimestamp != true {
 		t.Errorf("DefaultConfig() Parsing.AutoTimestamp = %v, want true", cfg.Parsing.AutoTimestamp)
 	}
+	if cfg.Parsing.IDStrategy != "random" {
+		t.Errorf("DefaultConfig() Parsing.IDStrategy = %v, want random", cfg.Parsing.IDStrategy)
+	}
+
+	// Check audit defaults
+	if !cfg.Audit.Enabled || cfg.Audit.Retention != "7d" {
+		t.Errorf("DefaultConfig() Audit = %+v, want enabled for 7d", cfg.Audit)
+	}
 }
 
 func TestLoad_NonExistentFile(t *testing.T) {
@@ -77,6 +85,7 @@ auto_open_browser = false
 [parsing]
 format = "json"
 auto_timestamp = false
+id_strategy = "hash"
 `
 
 	err := os.WriteFile(configPath, []byte(configContent), 0644)
@@ -111,6 +120,9 @@ auto_timestamp = false
 	if cfg.Parsing.AutoTimestamp != false {
 		t.Errorf("Load() Parsing.AutoTimestamp = %v, want false", cfg.Parsing.AutoTimestamp)
 	}
+	if cfg.Parsing.IDStrategy != "hash" {
+		t.Errorf("Load() Parsing.IDStrategy = %v, want hash", cfg.Parsing.IDStrategy)
+	}
 }
 
 func TestLoad_PartialFile(t *testing.T) {
@@ -366,3 +378,114 @@ func TestLoad_EmptyFile(t *testing.T) {
 		t.Errorf("Load() empty file should return defaults")
 	}
 }
+
+func TestLoad_BadgerTuning(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.toml")
+
+	configContent := `
+[storage.badger]
+sync_writes = false
+value_log_file_size = "128MB"
+memtable_size = "32MB"
+compression = "zstd"
+num_compactors = 2
+`
+
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := BadgerConfig{
+		SyncWrites:       false,
+		ValueLogFileSize: "128MB",
+		MemTableSize:     "32MB",
+		Compression:      "zstd",
+		NumCompactors:    2,
+	}
+	if cfg.Storage.Badger != want {
+		t.Errorf("Load() Storage.Badger = %+v, want %+v", cfg.Storage.Badger, want)
+	}
+
+	if !DefaultConfig().Storage.Badger.SyncWrites {
+		t.Errorf("DefaultConfig() Storage.Badger.SyncWrites = false, want true")
+	}
+}
+
+func TestLoad_UI(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.toml")
+
+	configContent := `
+[ui]
+default_time_preset = "1h"
+default_query = "level:ERROR"
+pinned_columns = ["service", "request_id"]
+theme = "light"
+auto_scroll = false
+`
+
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.UI.DefaultTimePreset != "1h" || cfg.UI.DefaultQuery != "level:ERROR" || cfg.UI.Theme != "light" || cfg.UI.AutoScroll {
+		t.Errorf("Load() UI = %+v", cfg.UI)
+	}
+	if len(cfg.UI.PinnedColumns) != 2 || cfg.UI.PinnedColumns[0] != "service" || cfg.UI.PinnedColumns[1] != "request_id" {
+		t.Errorf("Load() UI.PinnedColumns = %v", cfg.UI.PinnedColumns)
+	}
+
+	def := DefaultConfig().UI
+	if def.DefaultTimePreset != "all" || def.Theme != "dark" || !def.AutoScroll {
+		t.Errorf("DefaultConfig() UI = %+v", def)
+	}
+}
+
+func TestLoad_Auth(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.toml")
+
+	configContent := `
+[[auth.tokens]]
+token = "alice-token"
+namespace = "alice"
+
+[[auth.tokens]]
+token = "admin-token"
+admin = true
+`
+
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := []TokenConfig{{Token: "alice-token", Namespace: "alice"}, {Token: "admin-token", Admin: true}}
+	if len(cfg.Auth.Tokens) != len(want) {
+		t.Fatalf("Load() Auth.Tokens = %+v, want %+v", cfg.Auth.Tokens, want)
+	}
+	for i := range want {
+		if cfg.Auth.Tokens[i] != want[i] {
+			t.Errorf("Load() Auth.Tokens[%d] = %+v, want %+v", i, cfg.Auth.Tokens[i], want[i])
+		}
+	}
+
+	if len(DefaultConfig().Auth.Tokens) != 0 {
+		t.Errorf("DefaultConfig() enables auth")
+	}
+}
@@ -0,0 +1,436 @@
+// Package mdns advertises the peek web UI on the local network with
+// multicast DNS (RFC 6762) and DNS-SD (RFC 6763): the host name
+// peek-<host>.local resolves to this machine, and the UI is listed as an
+// _http._tcp service for browsers and discovery apps.
+package mdns
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DNS record types and classes used by the responder.
+const (
+	typeA   = 1
+	typePTR = 12
+	typeTXT = 16
+	typeSRV = 33
+	typeANY = 255
+
+	classIN = 1
+	// cacheFlush marks records only this host answers for (RFC 6762 §10.2).
+	cacheFlush = 0x8000
+	// unicastResponse is the QU bit of a question's class (RFC 6762 §5.4).
+	unicastResponse = 0x8000
+)
+
+const (
+	// recordTTL is the TTL of advertised records, in seconds.
+	recordTTL = 120
+	// legacyTTL caps TTLs in replies to one-shot queries (RFC 6762 §6.7).
+	legacyTTL = 10
+
+	serviceType  = "_http._tcp.local."
+	servicesEnum = "_services._dns-sd._udp.local."
+)
+
+// group is the mDNS IPv4 multicast address.
+var group = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// Service is what the responder advertises.
+type Service struct {
+	// Instance is the DNS-SD instance name shown by discovery apps.
+	Instance string
+	// Host is the host label; the service resolves as Host.local.
+	Host string
+	Port int
+	// Text holds the DNS-SD TXT "key=value" strings.
+	Text []string
+	// IPs are the IPv4 addresses Host resolves to; Start fills in the
+	// addresses of the local interfaces when empty.
+	IPs []net.IP
+}
+
+// NewService describes the peek UI on hostname's port: host
+// peek-<host>.local and the DNS-SD instance "peek on <host>".
+func NewService(hostname string, port int) Service {
+	host := hostLabel(hostname)
+	if host == "" {
+		return Service{Instance: "peek", Host: "peek", Port: port, Text: []string{"path=/"}}
+	}
+	return Service{Instance: "peek on " + host, Host: "peek-" + host, Port: port, Text: []string{"path=/"}}
+}
+
+// hostLabel turns a hostname into a DNS label: its first label, lowercased,
+// with anything but letters, digits and hyphens replaced by hyphens.
+func hostLabel(hostname string) string {
+	hostname, _, _ = strings.Cut(strings.ToLower(hostname), ".")
+	label := []byte(hostname)
+	for i, c := range label {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			label[i] = '-'
+		}
+	}
+	// Leave room for the "peek-" prefix within the 63-byte label limit.
+	if len(label) > 58 {
+		label = label[:58]
+	}
+	return strings.Trim(string(label), "-")
+}
+
+// HostName returns the fully qualified .local name of the service's host.
+func (s Service) HostName() string {
+	return s.Host + ".local."
+}
+
+func (s Service) instanceName() string {
+	return s.Instance + "." + serviceType
+}
+
+// Responder answers mDNS queries for a Service until closed.
+type Responder struct {
+	svc  Service
+	conn *net.UDPConn
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Start announces svc on the local network and answers queries for it.
+func Start(svc Service) (*Responder, error) {
+	if len(svc.IPs) == 0 {
+		svc.IPs = localIPv4()
+	}
+	if len(svc.IPs) == 0 {
+		return nil, errors.New("mdns: no IPv4 address to advertise")
+	}
+	conn, err := net.ListenMulticastUDP("udp4", nil, group)
+	if err != nil {
+		return nil, fmt.Errorf("mdns: %w", err)
+	}
+	r := &Responder{svc: svc, conn: conn, done: make(chan struct{})}
+	r.wg.Add(2)
+	go r.serve()
+	go r.announce()
+	return r, nil
+}
+
+// Close withdraws the advertisement with a goodbye (TTL 0) so caches drop
+// it at once, and stops answering.
+func (r *Responder) Close() error {
+	close(r.done)
+	r.conn.WriteToUDP(r.svc.announcement(0), group)
+	err := r.conn.Close()
+	r.wg.Wait()
+	return err
+}
+
+// serve answers queries until the connection is closed. One-shot queries
+// from a port other than 5353 get a unicast reply; the rest are answered on
+// the multicast group.
+func (r *Responder) serve() {
+	defer r.wg.Done()
+	buf := make([]byte, 9000)
+	for {
+		n, src, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		legacy := src.Port != group.Port
+		resp := r.svc.respond(buf[:n], legacy)
+		if resp == nil {
+			continue
+		}
+		dst := group
+		if legacy {
+			dst = src
+		}
+		r.conn.WriteToUDP(resp, dst)
+	}
+}
+
+// announce sends the records unsolicited twice, a second apart
+// (RFC 6762 §8.3), so discovery apps already listening see peek appear.
+func (r *Responder) announce() {
+	defer r.wg.Done()
+	for i := 0; i < 2; i++ {
+		r.conn.WriteToUDP(r.svc.announcement(recordTTL), group)
+		select {
+		case <-time.After(time.Second):
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// localIPv4 returns the IPv4 addresses of the interfaces that are up and
+// multicast-capable, loopback excluded.
+func localIPv4() []net.IP {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var ips []net.IP
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok {
+				if ip := ipnet.IP.To4(); ip != nil {
+					ips = append(ips, ip)
+				}
+			}
+		}
+	}
+	return ips
+}
+
+// record is a resource record without its TTL, which depends on the reply.
+type record struct {
+	name  string
+	rtype uint16
+	// unique records are only answered by this host and get the
+	// cache-flush bit in multicast replies.
+	unique bool
+	data   []byte
+}
+
+func (r record) equal(o record) bool {
+	return strings.EqualFold(r.name, o.name) && r.rtype == o.rtype && bytes.Equal(r.data, o.data)
+}
+
+func (s Service) enumRecord() record {
+	return record{name: servicesEnum, rtype: typePTR, data: appendName(nil, serviceType)}
+}
+
+func (s Service) ptrRecord() record {
+	return record{name: serviceType, rtype: typePTR, data: appendName(nil, s.instanceName())}
+}
+
+func (s Service) srvRecord() record {
+	data := binary.BigEndian.AppendUint16(nil, 0) // priority
+	data = binary.BigEndian.AppendUint16(data, 0) // weight
+	data = binary.BigEndian.AppendUint16(data, uint16(s.Port))
+	return record{name: s.instanceName(), rtype: typeSRV, unique: true, data: appendName(data, s.HostName())}
+}
+
+func (s Service) txtRecord() record {
+	var data []byte
+	for _, t := range s.Text {
+		if len(t) > 255 {
+			t = t[:255]
+		}
+		data = append(append(data, byte(len(t))), t...)
+	}
+	if len(data) == 0 {
+		data = []byte{0}
+	}
+	return record{name: s.instanceName(), rtype: typeTXT, unique: true, data: data}
+}
+
+func (s Service) addrRecords() []record {
+	recs := make([]record, 0, len(s.IPs))
+	for _, ip := range s.IPs {
+		if ip4 := ip.To4(); ip4 != nil {
+			recs = append(recs, record{name: s.HostName(), rtype: typeA, unique: true, data: []byte(ip4)})
+		}
+	}
+	return recs
+}
+
+// announcement is an unsolicited response carrying every record of s.
+func (s Service) announcement(ttl uint32) []byte {
+	recs := append([]record{s.ptrRecord(), s.srvRecord(), s.txtRecord()}, s.addrRecords()...)
+	return buildMessage(0, nil, recs, nil, ttl, true)
+}
+
+// question is one entry of a query's question section.
+type question struct {
+	name   string
+	qtype  uint16
+	qclass uint16
+}
+
+// respond builds the reply to the query in msg, or returns nil when msg is
+// not a query or asks nothing s answers. A legacy reply echoes the query ID
+// and questions and uses short TTLs without the cache-flush bit.
+func (s Service) respond(msg []byte, legacy bool) []byte {
+	id, questions, err := parseQuery(msg)
+	if err != nil {
+		return nil
+	}
+
+	var answers, extra []record
+	add := func(list *[]record, recs ...record) {
+		for _, r := range recs {
+			dup := false
+			for _, have := range *list {
+				dup = dup || have.equal(r)
+			}
+			if !dup {
+				*list = append(*list, r)
+			}
+		}
+	}
+	for _, q := range questions {
+		is := func(t uint16) bool { return q.qtype == t || q.qtype == typeANY }
+		switch {
+		case strings.EqualFold(q.name, servicesEnum) && is(typePTR):
+			add(&answers, s.enumRecord())
+		case strings.EqualFold(q.name, serviceType) && is(typePTR):
+			add(&answers, s.ptrRecord())
+			add(&extra, s.srvRecord(), s.txtRecord())
+			add(&extra, s.addrRecords()...)
+		case strings.EqualFold(q.name, s.instanceName()):
+			if is(typeSRV) {
+				add(&answers, s.srvRecord())
+				add(&extra, s.addrRecords()...)
+			}
+			if is(typeTXT) {
+				add(&answers, s.txtRecord())
+			}
+		case strings.EqualFold(q.name, s.HostName()) && is(typeA):
+			add(&answers, s.addrRecords()...)
+		}
+	}
+	if len(answers) == 0 {
+		return nil
+	}
+	var additional []record
+	for _, r := range extra {
+		inAnswers := false
+		for _, a := range answers {
+			inAnswers = inAnswers || a.equal(r)
+		}
+		if !inAnswers {
+			additional = append(additional, r)
+		}
+	}
+
+	if legacy {
+		return buildMessage(id, questions, answers, additional, legacyTTL, false)
+	}
+	return buildMessage(0, nil, answers, additional, recordTTL, true)
+}
+
+// buildMessage encodes an authoritative response. flush sets the
+// cache-flush bit on unique records.
+func buildMessage(id uint16, questions []question, answers, additional []record, ttl uint32, flush bool) []byte {
+	b := binary.BigEndian.AppendUint16(nil, id)
+	b = binary.BigEndian.AppendUint16(b, 0x8400) // QR, AA
+	b = binary.BigEndian.AppendUint16(b, uint16(len(questions)))
+	b = binary.BigEndian.AppendUint16(b, uint16(len(answers)))
+	b = binary.BigEndian.AppendUint16(b, 0)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(additional)))
+	for _, q := range questions {
+		b = appendName(b, q.name)
+		b = binary.BigEndian.AppendUint16(b, q.qtype)
+		b = binary.BigEndian.AppendUint16(b, q.qclass&^unicastResponse)
+	}
+	for _, r := range append(answers, additional...) {
+		class := uint16(classIN)
+		if r.unique && flush {
+			class |= cacheFlush
+		}
+		b = appendName(b, r.name)
+		b = binary.BigEndian.AppendUint16(b, r.rtype)
+		b = binary.BigEndian.AppendUint16(b, class)
+		b = binary.BigEndian.AppendUint32(b, ttl)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(r.data)))
+		b = append(b, r.data...)
+	}
+	return b
+}
+
+// appendName appends name in DNS wire format, uncompressed.
+func appendName(b []byte, name string) []byte {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		if len(label) > 63 {
+			label = label[:63]
+		}
+		b = append(append(b, byte(len(label))), label...)
+	}
+	return append(b, 0)
+}
+
+var errMalformed = errors.New("mdns: malformed message")
+
+// parseQuery decodes the ID and questions of a standard query.
+func parseQuery(msg []byte) (uint16, []question, error) {
+	if len(msg) < 12 {
+		return 0, nil, errMalformed
+	}
+	flags := binary.BigEndian.Uint16(msg[2:])
+	if flags&0x8000 != 0 || flags&0x7800 != 0 {
+		return 0, nil, errors.New("mdns: not a standard query")
+	}
+	count := int(binary.BigEndian.Uint16(msg[4:]))
+	off := 12
+	questions := make([]question, 0, count)
+	for i := 0; i < count; i++ {
+		name, next, err := readName(msg, off)
+		if err != nil {
+			return 0, nil, err
+		}
+		if next+4 > len(msg) {
+			return 0, nil, errMalformed
+		}
+		questions = append(questions, question{
+			name:   name,
+			qtype:  binary.BigEndian.Uint16(msg[next:]),
+			qclass: binary.BigEndian.Uint16(msg[next+2:]),
+		})
+		off = next + 4
+	}
+	return binary.BigEndian.Uint16(msg), questions, nil
+}
+
+// readName decodes the possibly compressed name at off and returns it with
+// the offset just past it.
+func readName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	end := -1
+	for jumps := 0; ; {
+		if off >= len(msg) {
+			return "", 0, errMalformed
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			return strings.Join(labels, ".") + ".", end, nil
+		case n&0xC0 == 0xC0:
+			if off+1 >= len(msg) || jumps > 16 {
+				return "", 0, errMalformed
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3FFF)
+			jumps++
+		case n&0xC0 != 0:
+			return "", 0, errMalformed
+		default:
+			if off+1+n > len(msg) {
+				return "", 0, errMalformed
+			}
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package mdns
+
+import (
+	"encoding/binary"
+	"net"
+	"slices"
+	"testing"
+)
+
+func testService() Service {
+	return Service{
+		Instance: "peek on laptop",
+		Host:     "peek-laptop",
+		Port:     8080,
+		Text:     []string{"path=/"},
+		IPs:      []net.IP{net.IPv4(192, 168, 1, 20), net.IPv4(10, 0, 0, 5)},
+	}
+}
+
+// query encodes a standard query for the given name and type.
+func query(id uint16, name string, qtype, qclass uint16) []byte {
+	b := binary.BigEndian.AppendUint16(nil, id)
+	b = append(b, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0)
+	b = appendName(b, name)
+	b = binary.BigEndian.AppendUint16(b, qtype)
+	return binary.BigEndian.AppendUint16(b, qclass)
+}
+
+type decoded struct {
+	id                  uint16
+	questions           int
+	answers, additional []decodedRecord
+}
+
+type decodedRecord struct {
+	name  string
+	rtype uint16
+	class uint16
+	ttl   uint32
+	data  []byte
+}
+
+func decode(t *testing.T, msg []byte) decoded {
+	t.Helper()
+	d := decoded{id: binary.BigEndian.Uint16(msg), questions: int(binary.BigEndian.Uint16(msg[4:]))}
+	if flags := binary.BigEndian.Uint16(msg[2:]); flags != 0x8400 {
+		t.Fatalf("flags = %#x, want an authoritative response", flags)
+	}
+	off := 12
+	for i := 0; i < d.questions; i++ {
+		_, next, err := readName(msg, off)
+		if err != nil {
+			t.Fatalf("readName(question) error = %v", err)
+		}
+		off = next + 4
+	}
+	read := func(n int) []decodedRecord {
+		var recs []decodedRecord
+		for i := 0; i < n; i++ {
+			name, next, err := readName(msg, off)
+			if err != nil {
+				t.Fatalf("readName(record) error = %v", err)
+			}
+			r := decodedRecord{
+				name:  name,
+				rtype: binary.BigEndian.Uint16(msg[next:]),
+				class: binary.BigEndian.Uint16(msg[next+2:]),
+				ttl:   binary.BigEndian.Uint32(msg[next+4:]),
+			}
+			size := int(binary.BigEndian.Uint16(msg[next+8:]))
+			r.data = msg[next+10 : next+10+size]
+			off = next + 10 + size
+			recs = append(recs, r)
+		}
+		return recs
+	}
+	d.answers = read(int(binary.BigEndian.Uint16(msg[6:])))
+	d.additional = read(int(binary.BigEndian.Uint16(msg[10:])))
+	return d
+}
+
+func types(recs []decodedRecord) []uint16 {
+	var ts []uint16
+	for _, r := range recs {
+		ts = append(ts, r.rtype)
+	}
+	return ts
+}
+
+func TestRespond(t *testing.T) {
+	s := testService()
+	tests := []struct {
+		name           string
+		msg            []byte
+		legacy         bool
+		wantAnswers    []uint16
+		wantAdditional []uint16
+	}{
+		{name: "host address", msg: query(0, "peek-laptop.local.", typeA, classIN), wantAnswers: []uint16{typeA, typeA}},
+		{name: "host name is case-insensitive", msg: query(0, "PEEK-Laptop.local.", typeANY, classIN), wantAnswers: []uint16{typeA, typeA}},
+		{name: "service browse", msg: query(0, "_http._tcp.local.", typePTR, classIN), wantAnswers: []uint16{typePTR}, wantAdditional: []uint16{typeSRV, typeTXT, typeA, typeA}},
+		{name: "service enumeration", msg: query(0, "_services._dns-sd._udp.local.", typePTR, classIN), wantAnswers: []uint16{typePTR}},
+		{name: "instance SRV", msg: query(0, "peek on laptop._http._tcp.local.", typeSRV, classIN), wantAnswers: []uint16{typeSRV}, wantAdditional: []uint16{typeA, typeA}},
+		{name: "instance ANY", msg: query(0, "peek on laptop._http._tcp.local.", typeANY, classIN), wantAnswers: []uint16{typeSRV, typeTXT}, wantAdditional: []uint16{typeA, typeA}},
+		{name: "legacy unicast query", msg: query(0x1234, "peek-laptop.local.", typeA, classIN|unicastResponse), legacy: true, wantAnswers: []uint16{typeA, typeA}},
+		{name: "other host", msg: query(0, "printer.local.", typeA, classIN)},
+		{name: "unsupported type", msg: query(0, "peek-laptop.local.", typeSRV, classIN)},
+		{name: "response", msg: append([]byte{0, 0, 0x84, 0}, query(0, "peek-laptop.local.", typeA, classIN)[4:]...)},
+		{name: "truncated", msg: query(0, "peek-laptop.local.", typeA, classIN)[:20]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := s.respond(tt.msg, tt.legacy)
+			if tt.wantAnswers == nil {
+				if resp != nil {
+					t.Fatalf("respond() = %x, want no reply", resp)
+				}
+				return
+			}
+			if resp == nil {
+				t.Fatal("respond() = nil, want a reply")
+			}
+			d := decode(t, resp)
+			if !slices.Equal(types(d.answers), tt.wantAnswers) || !slices.Equal(types(d.additional), tt.wantAdditional) {
+				t.Fatalf("answers %v + additional %v, want %v + %v", types(d.answers), types(d.additional), tt.wantAnswers, tt.wantAdditional)
+			}
+			if tt.legacy {
+				if d.id != 0x1234 || d.questions != 1 || d.answers[0].ttl != legacyTTL || d.answers[0].class != classIN {
+					t.Fatalf("legacy reply id=%#x questions=%d ttl=%d class=%#x", d.id, d.questions, d.answers[0].ttl, d.answers[0].class)
+				}
+			} else if d.id != 0 || d.questions != 0 {
+				t.Fatalf("multicast reply id=%#x questions=%d, want 0 and 0", d.id, d.questions)
+			}
+		})
+	}
+}
+
+func TestRespondRecordData(t *testing.T) {
+	s := testService()
+	d := decode(t, s.respond(query(0, "_http._tcp.local.", typePTR, classIN), false))
+
+	if name, _, err := readName(d.answers[0].data, 0); err != nil || name != "peek on laptop._http._tcp.local." {
+		t.Fatalf("PTR target = %q, %v", name, err)
+	}
+	srv := d.additional[0]
+	if port := binary.BigEndian.Uint16(srv.data[4:]); port != 8080 || srv.class != classIN|cacheFlush {
+		t.Fatalf("SRV port = %d class = %#x", port, srv.class)
+	}
+	if target, _, err := readName(srv.data, 6); err != nil || target != "peek-laptop.local." {
+		t.Fatalf("SRV target = %q, %v", target, err)
+	}
+	if txt := string(d.additional[1].data); txt != "\x06path=/" {
+		t.Fatalf("TXT = %q", txt)
+	}
+	if ip := net.IP(d.additional[2].data); !ip.Equal(net.IPv4(192, 168, 1, 20)) {
+		t.Fatalf("A = %v", ip)
+	}
+}
+
+func TestAnnouncement(t *testing.T) {
+	s := testService()
+	d := decode(t, s.announcement(0))
+	if !slices.Equal(types(d.answers), []uint16{typePTR, typeSRV, typeTXT, typeA, typeA}) {
+		t.Fatalf("announcement records = %v", types(d.answers))
+	}
+	for _, r := range d.answers {
+		if r.ttl != 0 {
+			t.Fatalf("goodbye %s TTL = %d, want 0", r.name, r.ttl)
+		}
+	}
+}
+
+func TestReadNameCompression(t *testing.T) {
+	// "local." at offset 0, then "peek.local." pointing back to it.
+	msg := append(appendName(nil, "local."), 4, 'p', 'e', 'e', 'k', 0xC0, 0x00)
+	name, next, err := readName(msg, 7)
+	if err != nil || name != "peek.local." || next != len(msg) {
+		t.Fatalf("readName() = %q, %d, %v", name, next, err)
+	}
+
+	loop := []byte{0xC0, 0x00}
+	if _, _, err := readName(loop, 0); err == nil {
+		t.Fatal("readName() with a pointer loop error = nil")
+	}
+}
+
+func TestNewService(t *testing.T) {
+	tests := []struct {
+		hostname     string
+		wantHost     string
+		wantInstance string
+	}{
+		{hostname: "Laptop", wantHost: "peek-laptop", wantInstance: "peek on laptop"},
+		{hostname: "dev-box.corp.example.com", wantHost: "peek-dev-box", wantInstance: "peek on dev-box"},
+		{hostname: "Mary's_MacBook", wantHost: "peek-mary-s-macbook", wantInstance: "peek on mary-s-macbook"},
+		{hostname: "", wantHost: "peek", wantInstance: "peek"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.hostname, func(t *testing.T) {
+			s := NewService(tt.hostname, 8080)
+			if s.Host != tt.wantHost || s.Instance != tt.wantInstance || s.Port != 8080 {
+				t.Fatalf("NewService(%q) = %+v", tt.hostname, s)
+			}
+		})
+	}
+}
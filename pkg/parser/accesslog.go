@@ -0,0 +1,139 @@
+package parser
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// AccessLogParser handles web server access logs in the Common Log Format
+// and the Combined format written by Apache and nginx:
+//
+//	127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /index.html HTTP/1.0" 200 2326
+//	127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.1" 200 612 "https://example.com/" "curl/8.0"
+//
+// The level comes from the status code: 5xx is ERROR, 4xx is WARN and
+// anything else INFO. Client IP, user, method, path, protocol, status,
+// bytes, referer and user agent are stored as fields.
+type AccessLogParser struct{}
+
+// NewAccessLogParser creates a new access log parser
+func NewAccessLogParser() *AccessLogParser {
+	return &AccessLogParser{}
+}
+
+// accessLogTimeLayout is the CLF timestamp, e.g. "10/Oct/2000:13:55:36 -0700".
+const accessLogTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// accessLogLine matches a CLF line, optionally followed by the quoted
+// referer and user agent of the Combined format. Anything after those
+// (nginx variables appended to the format) is ignored.
+var accessLogLine = regexp.MustCompile(`^(\S+) (\S+) (\S+) \[([^\]]+)\] "((?:[^"\\]|\\.)*)" (\d{3}) (\d+|-)(?: "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)")?(?:\s.*)?$`)
+
+// CanParse checks if the line is a CLF or Combined access log line
+func (p *AccessLogParser) CanParse(line string) bool {
+	_, err := p.parse(line)
+	return err == nil
+}
+
+// Parse parses an access log line into a LogEntry
+func (p *AccessLogParser) Parse(line string) (*storage.LogEntry, error) {
+	entry, err := p.parse(line)
+	if err != nil {
+		return nil, err
+	}
+	entry.ID = generateID()
+	promoteCorrelationIDs(entry)
+	return entry, nil
+}
+
+func (p *AccessLogParser) parse(line string) (*storage.LogEntry, error) {
+	m := accessLogLine.FindStringSubmatch(line)
+	if m == nil {
+		return nil, errors.New("access log: line does not match the common or combined format")
+	}
+	ts, err := time.Parse(accessLogTimeLayout, m[4])
+	if err != nil {
+		return nil, errors.New("access log: invalid timestamp")
+	}
+	status, _ := strconv.Atoi(m[6])
+
+	fields := map[string]interface{}{
+		"client_ip": m[1],
+		"status":    status,
+	}
+	setAccessField(fields, "ident", m[2])
+	setAccessField(fields, "user", m[3])
+	if m[7] != "-" {
+		bytes, _ := strconv.Atoi(m[7])
+		fields["bytes"] = bytes
+	}
+	setAccessField(fields, "referer", unescapeAccessLog(m[8]))
+	setAccessField(fields, "user_agent", unescapeAccessLog(m[9]))
+
+	// The request line is normally "METHOD path protocol"; keep anything
+	// else (a probe sending garbage, or "-") whole as the request.
+	request := unescapeAccessLog(m[5])
+	if parts := strings.Split(request, " "); len(parts) == 3 {
+		fields["method"], fields["path"], fields["protocol"] = parts[0], parts[1], parts[2]
+		request = parts[0] + " " + parts[1]
+	} else {
+		setAccessField(fields, "request", request)
+	}
+
+	return &storage.LogEntry{
+		Timestamp: ts,
+		Level:     accessLogLevel(status),
+		Message:   strings.TrimSpace(request + " " + m[6]),
+		Fields:    fields,
+		Raw:       line,
+	}, nil
+}
+
+// accessLogLevel derives a level from an HTTP status code.
+func accessLogLevel(status int) string {
+	switch {
+	case status >= 500:
+		return "ERROR"
+	case status >= 400:
+		return "WARN"
+	}
+	return "INFO"
+}
+
+// setAccessField stores value under key unless it is empty or the "-"
+// placeholder servers write for a missing value.
+func setAccessField(fields map[string]interface{}, key, value string) {
+	if value != "" && value != "-" {
+		fields[key] = value
+	}
+}
+
+// unescapeAccessLog undoes the backslash escaping servers apply inside
+// quoted values (\" and \\; nginx also writes \xHH).
+func unescapeAccessLog(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		if s[i+1] == 'x' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+2:i+4], 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		i++
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
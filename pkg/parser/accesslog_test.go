@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAccessLogParser_CanParse(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want bool
+	}{
+		{name: "common", line: `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`, want: true},
+		{name: "combined", line: `127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.1" 200 612 "-" "curl/8.0"`, want: true},
+		{name: "nginx extra variables", line: `127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.1" 200 612 "-" "curl/8.0" "10.1.1.1" rt=0.002`, want: true},
+		{name: "bad timestamp", line: `127.0.0.1 - - [yesterday] "GET / HTTP/1.1" 200 612`, want: false},
+		{name: "missing status", line: `127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.1"`, want: false},
+		{name: "logfmt", line: `level=INFO msg="test"`, want: false},
+		{name: "syslog", line: "<34>Oct 11 22:14:15 host app[123]: message", want: false},
+	}
+
+	parser := NewAccessLogParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parser.CanParse(tt.line); got != tt.want {
+				t.Errorf("AccessLogParser.CanParse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessLogParser_Parse(t *testing.T) {
+	tests := []struct {
+		name          string
+		line          string
+		wantLevel     string
+		wantMessage   string
+		wantTimestamp time.Time
+		wantFields    map[string]interface{}
+	}{
+		{
+			name:          "common",
+			line:          `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`,
+			wantLevel:     "INFO",
+			wantMessage:   "GET /apache_pb.gif 200",
+			wantTimestamp: time.Date(2000, 10, 10, 20, 55, 36, 0, time.UTC),
+			wantFields: map[string]interface{}{
+				"client_ip": "127.0.0.1", "user": "frank", "method": "GET", "path": "/apache_pb.gif",
+				"protocol": "HTTP/1.0", "status": 200, "bytes": 2326,
+			},
+		},
+		{
+			name:          "combined with escaped user agent",
+			line:          `10.0.0.1 - - [17/Feb/2026:10:30:45 +0000] "POST /login HTTP/1.1" 404 - "https://example.com/" "Mozilla \"x\" \x41"`,
+			wantLevel:     "WARN",
+			wantMessage:   "POST /login 404",
+			wantTimestamp: time.Date(2026, 2, 17, 10, 30, 45, 0, time.UTC),
+			wantFields: map[string]interface{}{
+				"client_ip": "10.0.0.1", "method": "POST", "path": "/login", "protocol": "HTTP/1.1",
+				"status": 404, "referer": "https://example.com/", "user_agent": `Mozilla "x" A`,
+			},
+		},
+		{
+			name:          "malformed request line",
+			line:          `10.0.0.1 - - [17/Feb/2026:10:30:45 +0000] "\x16\x03\x01" 502 0 "-" "-"`,
+			wantLevel:     "ERROR",
+			wantMessage:   "\x16\x03\x01 502",
+			wantTimestamp: time.Date(2026, 2, 17, 10, 30, 45, 0, time.UTC),
+			wantFields:    map[string]interface{}{"client_ip": "10.0.0.1", "status": 502, "bytes": 0, "request": "\x16\x03\x01"},
+		},
+		{
+			name:          "empty request",
+			line:          `10.0.0.1 - - [17/Feb/2026:10:30:45 +0000] "-" 400 0`,
+			wantLevel:     "WARN",
+			wantMessage:   "- 400",
+			wantTimestamp: time.Date(2026, 2, 17, 10, 30, 45, 0, time.UTC),
+			wantFields:    map[string]interface{}{"client_ip": "10.0.0.1", "status": 400, "bytes": 0},
+		},
+	}
+
+	parser := NewAccessLogParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := parser.Parse(tt.line)
+			if err != nil {
+				t.Fatalf("AccessLogParser.Parse() error = %v", err)
+			}
+			if entry.Level != tt.wantLevel {
+				t.Errorf("AccessLogParser.Parse() Level = %v, want %v", entry.Level, tt.wantLevel)
+			}
+			if entry.Message != tt.wantMessage {
+				t.Errorf("AccessLogParser.Parse() Message = %q, want %q", entry.Message, tt.wantMessage)
+			}
+			if !entry.Timestamp.Equal(tt.wantTimestamp) {
+				t.Errorf("AccessLogParser.Parse() Timestamp = %v, want %v", entry.Timestamp, tt.wantTimestamp)
+			}
+			if !reflect.DeepEqual(entry.Fields, tt.wantFields) {
+				t.Errorf("AccessLogParser.Parse() Fields = %v, want %v", entry.Fields, tt.wantFields)
+			}
+			if entry.Raw != tt.line || entry.ID == "" {
+				t.Errorf("AccessLogParser.Parse() Raw = %q, ID = %q", entry.Raw, entry.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package parser
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// Alias targets that fill an entry's own fields rather than renaming a
+// field.
+const (
+	AliasTimestamp = "timestamp"
+	AliasLevel     = "level"
+	AliasMessage   = "message"
+	AliasTraceID   = "trace_id"
+	AliasSpanID    = "span_id"
+)
+
+// fieldAlias maps a field the parsers leave in Fields to another name.
+type fieldAlias struct {
+	from, to string
+}
+
+// SetAliases makes d rename fields of every entry it parses, mapping each
+// key of aliases (a field name as stored, nested JSON as dotted names) to
+// its value. Aliases to timestamp, level, message, trace_id and span_id
+// fill the entry's own fields, replacing what the parser read; values that
+// don't fit, such as an unreadable timestamp, stay in Fields. Any other
+// target renames the field unless that name is already taken. Call it
+// before d is shared: streams copy the aliases of the detector they come
+// from.
+func (d *Detector) SetAliases(aliases map[string]string) error {
+	d.aliases = nil
+	for _, from := range slices.Sorted(maps.Keys(aliases)) {
+		to := aliases[from]
+		switch {
+		case from == "" || to == "":
+			return fmt.Errorf("alias %q = %q needs both a field and a target", from, to)
+		case from == to:
+			return fmt.Errorf("alias %q maps the field to itself", from)
+		}
+		d.aliases = append(d.aliases, fieldAlias{from: from, to: to})
+	}
+	return nil
+}
+
+// applyAliases renames the fields of entry by d's aliases and returns the
+// ones applied, as "from -> to". When several aliases of one target are
+// present, the first in alphabetical order of field name wins.
+func (d *Detector) applyAliases(entry *storage.LogEntry) []string {
+	if len(d.aliases) == 0 || len(entry.Fields) == 0 {
+		return nil
+	}
+	var applied []string
+	filled := make(map[string]bool)
+	for _, a := range d.aliases {
+		v, ok := entry.Fields[a.from]
+		if !ok || filled[a.to] {
+			continue
+		}
+		if aliasEntryField(entry, a.to, v) {
+			filled[a.to] = true
+			delete(entry.Fields, a.from)
+			applied = append(applied, a.from+" -> "+a.to)
+		}
+	}
+	return applied
+}
+
+// aliasEntryField stores v as the target field to of entry and reports
+// whether it did.
+func aliasEntryField(entry *storage.LogEntry, to string, v interface{}) bool {
+	switch to {
+	case AliasTimestamp:
+		t, ok := parseTimestamp(v)
+		if ok {
+			entry.Timestamp = t
+		}
+		return ok
+	case AliasLevel:
+		if n, ok := v.(float64); ok {
+			level, ok := levelFromNumber(n)
+			if ok {
+				entry.Level = level
+			}
+			return ok
+		}
+		s, ok := v.(string)
+		if ok && s != "" {
+			entry.Level = NormalizeLevel(s)
+		}
+		return ok && s != ""
+	case AliasMessage:
+		s, ok := v.(string)
+		if ok {
+			entry.Message = s
+		}
+		return ok
+	case AliasTraceID, AliasSpanID:
+		s, ok := v.(string)
+		if !ok || s == "" {
+			return false
+		}
+		if to == AliasTraceID {
+			entry.TraceID = s
+		} else {
+			entry.SpanID = s
+		}
+		return true
+	default:
+		if _, taken := entry.Fields[to]; taken {
+			return false
+		}
+		entry.Fields[to] = v
+		return true
+	}
+}
@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDetector_SetAliases(t *testing.T) {
+	d := NewDetector()
+	if err := d.SetAliases(map[string]string{
+		"lvl":       "level",
+		"log.level": "level",
+		"@message":  "message",
+		"@t":        "timestamp",
+		"traceid":   "trace_id",
+		"svc":       "service",
+		"env":       "region",
+	}); err != nil {
+		t.Fatalf("SetAliases() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		line        string
+		format      string
+		wantLevel   string
+		wantMessage string
+		wantTime    time.Time
+		wantTrace   string
+		wantFields  map[string]interface{}
+	}{
+		{
+			name:        "serilog compact JSON",
+			line:        `{"@t":"2026-03-10T15:00:00Z","@message":"Charge failed","lvl":"err","traceid":"abc"}`,
+			format:      "auto",
+			wantLevel:   "ERROR",
+			wantMessage: "Charge failed",
+			wantTime:    time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC),
+			wantTrace:   "abc",
+			wantFields:  map[string]interface{}{},
+		},
+		{
+			name:        "ECS nested level",
+			line:        `{"message":"started","log":{"level":"warn","logger":"main"}}`,
+			format:      "json",
+			wantLevel:   "WARN",
+			wantMessage: "started",
+			wantFields:  map[string]interface{}{"log.logger": "main"},
+		},
+		{
+			name:        "logfmt rename",
+			line:        `msg=hi lvl=debug svc=api`,
+			format:      "logfmt",
+			wantLevel:   "DEBUG",
+			wantMessage: "hi",
+			wantFields:  map[string]interface{}{"service": "api"},
+		},
+		{
+			name:        "first alias of a target wins",
+			line:        `{"message":"x","lvl":"info","log":{"level":"error"}}`,
+			format:      "auto",
+			wantLevel:   "ERROR",
+			wantMessage: "x",
+			wantFields:  map[string]interface{}{"lvl": "info"},
+		},
+		{
+			name:        "unfit values stay fields",
+			line:        `{"message":"x","@t":"soon","lvl":3,"env":"eu","region":"us"}`,
+			format:      "auto",
+			wantMessage: "x",
+			wantFields:  map[string]interface{}{"@t": "soon", "lvl": float64(3), "env": "eu", "region": "us"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := d.Stream(nil).ParseWithFormat(tt.line, tt.format)
+			if err != nil {
+				t.Fatalf("ParseWithFormat() error = %v", err)
+			}
+			if entry.Level != tt.wantLevel || entry.Message != tt.wantMessage || entry.TraceID != tt.wantTrace {
+				t.Errorf("entry = level %q message %q trace %q, want %q %q %q", entry.Level, entry.Message, entry.TraceID, tt.wantLevel, tt.wantMessage, tt.wantTrace)
+			}
+			if !tt.wantTime.IsZero() && !entry.Timestamp.Equal(tt.wantTime) {
+				t.Errorf("Timestamp = %v, want %v", entry.Timestamp, tt.wantTime)
+			}
+			if len(entry.Fields) != len(tt.wantFields) {
+				t.Fatalf("Fields = %v, want %v", entry.Fields, tt.wantFields)
+			}
+			for k, v := range tt.wantFields {
+				if entry.Fields[k] != v {
+					t.Errorf("Fields[%q] = %v, want %v", k, entry.Fields[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestDetector_SetAliasesInvalid(t *testing.T) {
+	for _, aliases := range []map[string]string{
+		{"lvl": ""},
+		{"": "level"},
+		{"service": "service"},
+	} {
+		if err := NewDetector().SetAliases(aliases); err == nil {
+			t.Errorf("SetAliases(%v) succeeded", aliases)
+		}
+	}
+}
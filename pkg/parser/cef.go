@@ -0,0 +1,256 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// CEFParser handles ArcSight Common Event Format records, as sent by
+// firewalls, WAFs and other security appliances, optionally behind the
+// syslog header of the relay that forwarded them:
+//
+//	<134>Feb 17 10:30:45 fw01 CEF:0|Fortinet|FortiGate|7.2|13|Blocked connection|7|src=10.0.0.5 dst=203.0.113.9 dpt=443 act=deny
+//
+// Name is the message and Severity (0-10 or Low, Medium, High, Very-High)
+// sets the level. The other header fields are stored as cef_version,
+// device_vendor, device_product, device_version, signature_id and
+// severity, and every extension key=value pair under its key. The rt
+// extension (receipt time) or the syslog header sets the timestamp.
+type CEFParser struct{}
+
+// NewCEFParser creates a new CEF parser
+func NewCEFParser() *CEFParser {
+	return &CEFParser{}
+}
+
+// cefHeaderFields name the pipe-separated header fields after "CEF:".
+var cefHeaderFields = []string{"cef_version", "device_vendor", "device_product", "device_version", "signature_id", "name", "severity"}
+
+// CanParse checks if the line holds a CEF record
+func (p *CEFParser) CanParse(line string) bool {
+	if !strings.Contains(line, "CEF:") {
+		return false
+	}
+	_, err := p.parse(line)
+	return err == nil
+}
+
+// Parse parses a CEF record into a LogEntry
+func (p *CEFParser) Parse(line string) (*storage.LogEntry, error) {
+	entry, err := p.parse(line)
+	if err != nil {
+		return nil, err
+	}
+	entry.ID = generateID()
+	promoteCorrelationIDs(entry)
+	return entry, nil
+}
+
+func (p *CEFParser) parse(line string) (*storage.LogEntry, error) {
+	entry := &storage.LogEntry{Fields: make(map[string]interface{}), Raw: line}
+	record, err := securityRecord(entry, line, "CEF:")
+	if err != nil {
+		return nil, fmt.Errorf("cef: %w", err)
+	}
+
+	header, extension, err := splitCEFHeader(record)
+	if err != nil {
+		return nil, err
+	}
+	ext, err := parseCEFExtension(extension)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range ext {
+		entry.Fields[k] = v
+	}
+	for i, name := range cefHeaderFields {
+		if name != "name" {
+			entry.Fields[name] = header[i]
+		}
+	}
+	entry.Message = header[5]
+	entry.Level = securitySeverityLevel(header[6])
+	if t, ok := parseSecurityTime(ext["rt"]); ok {
+		entry.Timestamp = t
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = timeNow()
+	}
+	return entry, nil
+}
+
+// splitCEFHeader splits a record after "CEF:" into its seven header fields,
+// unescaping \| and \\, and the extension after them.
+func splitCEFHeader(record string) ([]string, string, error) {
+	var fields []string
+	var b strings.Builder
+	for i := 0; i < len(record); i++ {
+		switch c := record[i]; {
+		case c == '\\' && i+1 < len(record) && (record[i+1] == '|' || record[i+1] == '\\'):
+			i++
+			b.WriteByte(record[i])
+		case c == '|':
+			fields = append(fields, b.String())
+			b.Reset()
+			if len(fields) == len(cefHeaderFields) {
+				return fields, record[i+1:], nil
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	if len(fields) == len(cefHeaderFields)-1 {
+		// The trailing pipe is sometimes left out when there is no extension.
+		return append(fields, b.String()), "", nil
+	}
+	return nil, "", fmt.Errorf("cef: header has %d fields, want %d", len(fields), len(cefHeaderFields))
+}
+
+// cefKey matches the keys of a CEF extension.
+var cefKey = regexp.MustCompile(`^[A-Za-z0-9_.\[\]-]+$`)
+
+// parseCEFExtension parses the space-separated key=value pairs of a CEF
+// extension. Values may contain spaces, so a value runs until the last
+// space before the next key; \=, \\, \n and \r in values are unescaped.
+func parseCEFExtension(s string) (map[string]string, error) {
+	type pair struct{ start, eq int }
+	var pairs []pair
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '=':
+			start := strings.LastIndexByte(s[:i], ' ') + 1
+			if len(pairs) > 0 && start <= pairs[len(pairs)-1].eq {
+				// An unescaped '=' inside a value, e.g. in a URL.
+				continue
+			}
+			if !cefKey.MatchString(s[start:i]) {
+				continue
+			}
+			pairs = append(pairs, pair{start, i})
+		}
+	}
+	if len(pairs) == 0 && strings.TrimSpace(s) != "" || len(pairs) > 0 && strings.TrimSpace(s[:pairs[0].start]) != "" {
+		return nil, errors.New("cef: extension is not key=value pairs")
+	}
+
+	ext := make(map[string]string, len(pairs))
+	for i, p := range pairs {
+		end := len(s)
+		if i+1 < len(pairs) {
+			end = pairs[i+1].start
+		}
+		ext[s[p.start:p.eq]] = cefUnescaper.Replace(strings.TrimRight(s[p.eq+1:end], " "))
+	}
+	return ext, nil
+}
+
+var cefUnescaper = strings.NewReplacer(`\=`, "=", `\\`, `\`, `\n`, "\n", `\r`, "\r", `\|`, "|")
+
+// securityRecord returns the CEF or LEEF record starting at marker in line.
+// Text before it must be the syslog header of a relay, whose timestamp,
+// host and app are stored on entry.
+func securityRecord(entry *storage.LogEntry, line, marker string) (string, error) {
+	i := strings.Index(line, marker)
+	if i < 0 {
+		return "", fmt.Errorf("missing %s", marker)
+	}
+	if i > 0 && line[i-1] != ' ' {
+		return "", fmt.Errorf("%s inside a word", marker)
+	}
+	if err := parseSyslogHeader(entry, strings.TrimSpace(line[:i])); err != nil {
+		return "", err
+	}
+	return line[i+len(marker):], nil
+}
+
+// parseSyslogHeader parses the syslog header of a relayed record, with or
+// without its <PRI>. The header has no message of its own, so a placeholder
+// one is parsed and then dropped.
+func parseSyslogHeader(entry *storage.LogEntry, header string) error {
+	if header == "" {
+		return nil
+	}
+	if m := syslogPriority.FindStringSubmatch(header); m != nil {
+		pri, _ := strconv.Atoi(m[1])
+		if pri > 191 {
+			return errors.New("syslog priority out of range")
+		}
+		entry.Fields["facility"] = syslogFacilities[pri/8]
+		header = strings.TrimPrefix(header[len(m[0]):], " ")
+	}
+	var err error
+	if strings.HasPrefix(header, "1 ") {
+		err = parseRFC5424(entry, header[2:])
+	} else {
+		err = parseRFC3164(entry, header+" -")
+	}
+	if err != nil {
+		return errors.New("text before the record is not a syslog header")
+	}
+	entry.Message = ""
+	return nil
+}
+
+// securitySeverityLevel maps a CEF or LEEF severity, 0-10 or one of CEF's
+// names, to a level: 0-3 (Low) INFO, 4-6 (Medium) WARN, 7-8 (High) ERROR
+// and 9-10 (Very-High) FATAL. Other values have no level.
+func securitySeverityLevel(severity string) string {
+	switch strings.ToLower(severity) {
+	case "low":
+		return "INFO"
+	case "medium":
+		return "WARN"
+	case "high":
+		return "ERROR"
+	case "very-high", "very high":
+		return "FATAL"
+	}
+	n, err := strconv.Atoi(severity)
+	switch {
+	case err != nil || n < 0 || n > 10:
+		return ""
+	case n <= 3:
+		return "INFO"
+	case n <= 6:
+		return "WARN"
+	case n <= 8:
+		return "ERROR"
+	}
+	return "FATAL"
+}
+
+// securityTimeLayouts are the date formats CEF's rt and LEEF's devTime are
+// written in, besides milliseconds since the epoch.
+var securityTimeLayouts = []string{
+	"Jan 02 2006 15:04:05",
+	"Jan 02 2006 15:04:05.000",
+	"Jan 02 2006 15:04:05 MST",
+	"Jan 02 2006 15:04:05.000 MST",
+	"Jan 02 2006 15:04:05 -0700",
+	time.RFC3339Nano,
+}
+
+// parseSecurityTime parses a CEF or LEEF event time.
+func parseSecurityTime(s string) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, false
+	}
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.UnixMilli(ms), true
+	}
+	for _, layout := range securityTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, location()); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
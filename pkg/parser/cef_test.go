@@ -0,0 +1,134 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCEFParser_Parse(t *testing.T) {
+	now := time.Date(2026, 2, 17, 12, 0, 0, 0, time.Local)
+	originalTimeNow := timeNow
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = originalTimeNow }()
+
+	tests := []struct {
+		name       string
+		line       string
+		wantTime   time.Time
+		wantLevel  string
+		wantMsg    string
+		wantFields map[string]interface{}
+	}{
+		{
+			name:      "bare record",
+			line:      `CEF:0|Security|threatmanager|1.0|100|worm successfully stopped|10|src=10.0.0.1 dst=2.1.2.2 spt=1232`,
+			wantTime:  now,
+			wantLevel: "FATAL",
+			wantMsg:   "worm successfully stopped",
+			wantFields: map[string]interface{}{
+				"cef_version": "0", "device_vendor": "Security", "device_product": "threatmanager", "device_version": "1.0",
+				"signature_id": "100", "severity": "10", "src": "10.0.0.1", "dst": "2.1.2.2", "spt": "1232",
+			},
+		},
+		{
+			name:      "syslog header, escapes and spaces in values",
+			line:      `<134>Feb 17 10:30:45 fw01 CEF:0|Acme|WAF \| edge|2.3|sqli|SQL injection attempt|High|act=blocked request=https://shop.example.com/?q=1 OR 1\=1 msg=matched rule 942100\nsecond line cs1Label=rule`,
+			wantTime:  time.Date(2026, 2, 17, 10, 30, 45, 0, time.Local),
+			wantLevel: "ERROR",
+			wantMsg:   "SQL injection attempt",
+			wantFields: map[string]interface{}{
+				"facility": "local0", "host": "fw01",
+				"cef_version": "0", "device_vendor": "Acme", "device_product": "WAF | edge", "device_version": "2.3",
+				"signature_id": "sqli", "severity": "High", "act": "blocked",
+				"request": "https://shop.example.com/?q=1 OR 1=1", "msg": "matched rule 942100\nsecond line", "cs1Label": "rule",
+			},
+		},
+		{
+			name:      "receipt time and no extension",
+			line:      `CEF:1|Vendor|Product|1|login|User logged in|3|rt=1771324245003`,
+			wantTime:  time.UnixMilli(1771324245003),
+			wantLevel: "INFO",
+			wantMsg:   "User logged in",
+			wantFields: map[string]interface{}{
+				"cef_version": "1", "device_vendor": "Vendor", "device_product": "Product", "device_version": "1",
+				"signature_id": "login", "severity": "3", "rt": "1771324245003",
+			},
+		},
+		{
+			name:      "formatted receipt time",
+			line:      `CEF:0|V|P|1|x|Port scan|5|rt=Feb 17 2026 10:30:45 src=10.0.0.9`,
+			wantTime:  time.Date(2026, 2, 17, 10, 30, 45, 0, time.Local),
+			wantLevel: "WARN",
+			wantMsg:   "Port scan",
+			wantFields: map[string]interface{}{
+				"cef_version": "0", "device_vendor": "V", "device_product": "P", "device_version": "1",
+				"signature_id": "x", "severity": "5", "rt": "Feb 17 2026 10:30:45", "src": "10.0.0.9",
+			},
+		},
+		{
+			name:     "missing trailing pipe",
+			line:     `CEF:0|V|P|1|x|Heartbeat|Unknown`,
+			wantTime: now,
+			wantMsg:  "Heartbeat",
+			wantFields: map[string]interface{}{
+				"cef_version": "0", "device_vendor": "V", "device_product": "P", "device_version": "1",
+				"signature_id": "x", "severity": "Unknown",
+			},
+		},
+	}
+
+	parser := NewCEFParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !parser.CanParse(tt.line) {
+				t.Fatal("CanParse() = false")
+			}
+			entry, err := parser.Parse(tt.line)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !entry.Timestamp.Equal(tt.wantTime) {
+				t.Errorf("Timestamp = %v, want %v", entry.Timestamp, tt.wantTime)
+			}
+			if entry.Level != tt.wantLevel || entry.Message != tt.wantMsg {
+				t.Errorf("Level, Message = %q, %q; want %q, %q", entry.Level, entry.Message, tt.wantLevel, tt.wantMsg)
+			}
+			if !reflect.DeepEqual(entry.Fields, tt.wantFields) {
+				t.Errorf("Fields = %#v, want %#v", entry.Fields, tt.wantFields)
+			}
+			if entry.Raw != tt.line || entry.ID == "" {
+				t.Errorf("Raw, ID = %q, %q", entry.Raw, entry.ID)
+			}
+		})
+	}
+}
+
+func TestCEFParser_Rejects(t *testing.T) {
+	parser := NewCEFParser()
+	for _, line := range []string{
+		`CEF:0|Vendor|Product|1.0|100`,
+		`CEF:0|V|P|1|x|Name|5|not an extension`,
+		`notCEF:0|V|P|1|x|Name|5|src=1`,
+		`some text CEF:0|V|P|1|x|Name|5|src=1`,
+		`level=info msg=hello`,
+	} {
+		if parser.CanParse(line) {
+			t.Errorf("CanParse(%q) = true", line)
+		}
+		if _, err := parser.Parse(line); err == nil {
+			t.Errorf("Parse(%q) error = nil", line)
+		}
+	}
+}
+
+func TestSecuritySeverityLevel(t *testing.T) {
+	for severity, want := range map[string]string{
+		"0": "INFO", "3": "INFO", "4": "WARN", "6": "WARN", "7": "ERROR", "8": "ERROR", "9": "FATAL", "10": "FATAL",
+		"low": "INFO", "Medium": "WARN", "HIGH": "ERROR", "Very-High": "FATAL", "Unknown": "", "11": "", "-1": "", "": "",
+	} {
+		if got := securitySeverityLevel(severity); got != want {
+			t.Errorf("securitySeverityLevel(%q) = %q, want %q", severity, got, want)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package parser
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// defaultCommandTimeout is how long a parser command may take to answer a
+// line when its format sets no timeout.
+const defaultCommandTimeout = 5 * time.Second
+
+// After a parser command fails it is restarted on the first line after a
+// backoff that doubles from commandMinBackoff up to commandMaxBackoff, and
+// resets once it answers again. Variables so tests can shorten them.
+var (
+	commandMinBackoff = time.Second
+	commandMaxBackoff = 30 * time.Second
+)
+
+// States of a parser command reported by CommandStatus.
+const (
+	CommandIdle    = "idle"    // not started yet, or stopped by Close
+	CommandRunning = "running" // answering lines
+	CommandFailed  = "failed"  // exited, hung or failed to start; restarted after a backoff
+)
+
+// CommandStatus reports the health of a custom format's parser command.
+type CommandStatus struct {
+	Name        string     `json:"name"`
+	Command     []string   `json:"command"`
+	State       string     `json:"state"`
+	Restarts    int        `json:"restarts"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+	RetryAt     *time.Time `json:"retry_at,omitempty"`
+}
+
+// CommandParser handles a custom format whose parsing is done by an
+// external program, for proprietary formats peek doesn't know. The program
+// is started on the first line and kept running: each line is written to
+// its stdin, and it answers each with one line on stdout, either a JSON
+// object read like the json format (timestamp, level, message and the
+// remaining keys as fields) or null when the line isn't in its format. The
+// program should exit when its stdin closes.
+//
+// A program that exits, hangs past the timeout or can't be started is
+// marked failed and restarted after a backoff. Until then lines are not
+// recognized, so auto mode falls back to the other formats and an explicit
+// format keeps the lines for peek reparse-failures. It is safe for
+// concurrent use; lines are answered one at a time.
+type CommandParser struct {
+	name    string
+	argv    []string
+	timeout time.Duration
+	json    *JSONParser
+
+	mu      sync.Mutex
+	proc    *commandProcess // nil until started, and while failed
+	status  CommandStatus
+	backoff time.Duration
+	retryAt time.Time
+	// The answer CanParse got, which the Parse of the same line takes
+	// instead of asking again.
+	asked      bool
+	askedLine  string
+	askedReply string
+	askedErr   error
+}
+
+// commandProcess is one run of a parser command.
+type commandProcess struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	replies chan string // closed when stdout ends
+}
+
+// NewCommandParser creates the parser of a custom format run by argv. A
+// zero timeout uses defaultCommandTimeout.
+func NewCommandParser(name string, argv []string, timeout time.Duration) (*CommandParser, error) {
+	if len(argv) == 0 || argv[0] == "" {
+		return nil, fmt.Errorf("format %s: command is empty", name)
+	}
+	if timeout < 0 {
+		return nil, fmt.Errorf("format %s: timeout must not be negative", name)
+	}
+	if timeout == 0 {
+		timeout = defaultCommandTimeout
+	}
+	return &CommandParser{
+		name:    name,
+		argv:    argv,
+		timeout: timeout,
+		json:    NewJSONParser(),
+		status:  CommandStatus{Name: name, Command: argv, State: CommandIdle},
+	}, nil
+}
+
+// CanParse asks the command whether line is in its format.
+func (p *CommandParser) CanParse(line string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	reply, err := p.exchange(line)
+	p.asked, p.askedLine, p.askedReply, p.askedErr = true, line, reply, err
+	return err == nil && reply != "null"
+}
+
+// Parse parses line into the entry the command answers with.
+func (p *CommandParser) Parse(line string) (*storage.LogEntry, error) {
+	reply, err := p.answer(line)
+	if err != nil {
+		return nil, err
+	}
+	if reply == "null" {
+		return nil, fmt.Errorf("line does not match format %s", p.name)
+	}
+	if !strings.HasPrefix(reply, "{") {
+		return nil, fmt.Errorf("format %s: command answered %q, want a JSON object or null", p.name, truncateString(reply, 80))
+	}
+	entry, err := p.json.Parse(reply)
+	if err != nil {
+		return nil, fmt.Errorf("format %s: invalid answer from command: %w", p.name, err)
+	}
+	entry.Raw = line
+	return entry, nil
+}
+
+// answer returns the command's answer to line: the one CanParse just got
+// for it, or a new one.
+func (p *CommandParser) answer(line string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.asked && p.askedLine == line {
+		p.asked = false
+		return p.askedReply, p.askedErr
+	}
+	return p.exchange(line)
+}
+
+// unavailable returns the error CanParse just got for line when the
+// command couldn't answer it, rather than answering null.
+func (p *CommandParser) unavailable(line string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.asked && p.askedLine == line {
+		return p.askedErr
+	}
+	return nil
+}
+
+// exchange writes line to the command, starting it if needed, and reads
+// its answer. p.mu is held.
+func (p *CommandParser) exchange(line string) (string, error) {
+	if p.proc == nil {
+		if time.Now().Before(p.retryAt) {
+			return "", fmt.Errorf("format %s: command unavailable until %s: %s", p.name, p.retryAt.Format(time.TimeOnly), p.status.LastError)
+		}
+		if err := p.start(); err != nil {
+			return "", p.fail(err)
+		}
+	}
+	// Lines are one request each, so an embedded newline is sent as a
+	// space.
+	if _, err := io.WriteString(p.proc.stdin, strings.ReplaceAll(line, "\n", " ")+"\n"); err != nil {
+		return "", p.fail(fmt.Errorf("write: %w", err))
+	}
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+	select {
+	case reply, ok := <-p.proc.replies:
+		if !ok {
+			return "", p.fail(p.proc.exitError())
+		}
+		p.backoff = 0
+		return strings.TrimSpace(reply), nil
+	case <-timer.C:
+		return "", p.fail(fmt.Errorf("no answer within %s", p.timeout))
+	}
+}
+
+// start runs the command. p.mu is held.
+func (p *CommandParser) start() error {
+	cmd := exec.Command(p.argv[0], p.argv[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	proc := &commandProcess{cmd: cmd, stdin: stdin, replies: make(chan string)}
+	go func() {
+		defer close(proc.replies)
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			proc.replies <- scanner.Text()
+		}
+	}()
+	if p.status.State == CommandFailed {
+		p.status.Restarts++
+	}
+	p.proc = proc
+	p.status.State = CommandRunning
+	p.status.RetryAt = nil
+	return nil
+}
+
+// fail stops the command after err and schedules its restart. It returns
+// the error to report for the line. p.mu is held.
+func (p *CommandParser) fail(err error) error {
+	if p.proc != nil {
+		p.proc.stop()
+		p.proc = nil
+	}
+	p.backoff = min(max(2*p.backoff, commandMinBackoff), commandMaxBackoff)
+	now := time.Now()
+	p.retryAt = now.Add(p.backoff)
+	p.status.State = CommandFailed
+	p.status.LastError = err.Error()
+	p.status.LastErrorAt = &now
+	p.status.RetryAt = &p.retryAt
+	log.Printf("Warning: parser command of format %s failed: %v (retrying in %s)", p.name, err, p.backoff)
+	return fmt.Errorf("format %s: command failed: %w", p.name, err)
+}
+
+// Status reports the command's health.
+func (p *CommandParser) Status() CommandStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := p.status
+	if st.LastErrorAt != nil {
+		t := *st.LastErrorAt
+		st.LastErrorAt = &t
+	}
+	if st.RetryAt != nil {
+		t := *st.RetryAt
+		st.RetryAt = &t
+	}
+	return st
+}
+
+// Close stops the command. A later line starts it again.
+func (p *CommandParser) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.proc != nil {
+		p.proc.stop()
+		p.proc = nil
+	}
+	p.status.State = CommandIdle
+	p.asked = false
+	return nil
+}
+
+// stop closes the command's stdin and kills it if it doesn't exit on its
+// own.
+func (c *commandProcess) stop() {
+	c.stdin.Close()
+	done := make(chan struct{})
+	go func() {
+		// Drain answers so the reader can see stdout end.
+		for range c.replies {
+		}
+		c.cmd.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		c.cmd.Process.Kill()
+	}
+}
+
+// exitError describes why the command's stdout ended.
+func (c *commandProcess) exitError() error {
+	c.stdin.Close()
+	err := c.cmd.Wait()
+	var exit *exec.ExitError
+	switch {
+	case errors.As(err, &exit):
+		return fmt.Errorf("command exited: %v", exit)
+	case err != nil:
+		return err
+	}
+	return errors.New("command exited")
+}
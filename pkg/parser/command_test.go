@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// acmeScript answers lines starting with "ACME " with an entry and others
+// with null.
+const acmeScript = `while read -r l; do
+case "$l" in
+"ACME "*) printf '{"level":"warn","message":"%s","vendor":"acme"}\n' "${l#ACME }" ;;
+*) echo null ;;
+esac
+done`
+
+func newCommandDetector(t *testing.T, script string, timeout time.Duration) *Detector {
+	t.Helper()
+	d, err := NewDetectorWithCustom([]CustomFormat{{Name: "acme", Command: []string{"sh", "-c", script}, Timeout: timeout}})
+	if err != nil {
+		t.Fatalf("NewDetectorWithCustom() error = %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func TestCommandParser(t *testing.T) {
+	d := newCommandDetector(t, acmeScript, 0)
+
+	entry, err := d.ParseWithFormat("ACME disk almost full", "auto")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if entry.Level != "WARN" || entry.Message != "disk almost full" || entry.Fields["vendor"] != "acme" || entry.Raw != "ACME disk almost full" {
+		t.Errorf("entry = %s %q %v raw=%q", entry.Level, entry.Message, entry.Fields, entry.Raw)
+	}
+
+	// Lines the command doesn't recognize fall through to the built-in
+	// formats.
+	entry, err = d.ParseWithFormat("level=error msg=boom", "auto")
+	if err != nil || entry.Level != "ERROR" || entry.Message != "boom" {
+		t.Fatalf("logfmt line = %+v, %v", entry, err)
+	}
+	if _, err := d.ParseWithFormat("level=error msg=boom", "acme"); err == nil || !strings.Contains(err.Error(), "does not match format acme") {
+		t.Errorf("explicit format on another line error = %v", err)
+	}
+
+	st := d.CommandStatuses()
+	if len(st) != 1 || st[0].Name != "acme" || st[0].State != CommandRunning || st[0].Restarts != 0 {
+		t.Errorf("CommandStatuses() = %+v", st)
+	}
+}
+
+func TestCommandParserRestartsAfterFailure(t *testing.T) {
+	minBackoff := commandMinBackoff
+	commandMinBackoff = 50 * time.Millisecond
+	t.Cleanup(func() { commandMinBackoff = minBackoff })
+
+	// The command answers one line, then exits.
+	d := newCommandDetector(t, `read -r l; echo '{"message":"first"}'`, 0)
+	if entry, err := d.ParseWithFormat("one", "acme"); err != nil || entry.Message != "first" {
+		t.Fatalf("first line = %+v, %v", entry, err)
+	}
+
+	_, err := d.ParseWithFormat("two", "acme")
+	if err == nil || !strings.Contains(err.Error(), "command failed") {
+		t.Fatalf("line after exit error = %v, want command failed", err)
+	}
+	st := d.CommandStatuses()[0]
+	if st.State != CommandFailed || st.LastError == "" || st.RetryAt == nil {
+		t.Fatalf("status after exit = %+v", st)
+	}
+
+	// While it waits to restart, explicit lines fail with the reason and
+	// auto mode falls back.
+	if _, err := d.ParseWithFormat("three", "acme"); err == nil || !strings.Contains(err.Error(), "unavailable") {
+		t.Errorf("line during backoff error = %v, want unavailable", err)
+	}
+	if entry, err := d.ParseWithFormat("three", "auto"); err != nil || entry.Message != "three" {
+		t.Errorf("auto line during backoff = %+v, %v, want raw", entry, err)
+	}
+
+	time.Sleep(commandMinBackoff)
+	if entry, err := d.ParseWithFormat("four", "acme"); err != nil || entry.Message != "first" {
+		t.Fatalf("line after backoff = %+v, %v", entry, err)
+	}
+	if st := d.CommandStatuses()[0]; st.State != CommandRunning || st.Restarts != 1 {
+		t.Errorf("status after restart = %+v", st)
+	}
+}
+
+func TestCommandParserTimeout(t *testing.T) {
+	d := newCommandDetector(t, `read -r l; exec sleep 5`, 50*time.Millisecond)
+	started := time.Now()
+	_, err := d.ParseWithFormat("ACME hang", "acme")
+	if err == nil || !strings.Contains(err.Error(), "no answer within 50ms") {
+		t.Fatalf("error = %v, want timeout", err)
+	}
+	if took := time.Since(started); took > 3*time.Second {
+		t.Errorf("timed out line took %s", took)
+	}
+}
+
+func TestCommandParserInvalidConfig(t *testing.T) {
+	for _, f := range []CustomFormat{
+		{Name: "empty", Command: []string{""}},
+		{Name: "both", Command: []string{"cat"}, Pattern: `^(?P<message>.*)$`},
+		{Name: "negative", Command: []string{"cat"}, Timeout: -time.Second},
+	} {
+		if _, err := NewDetectorWithCustom([]CustomFormat{f}); err == nil {
+			t.Errorf("NewDetectorWithCustom(%s) error = nil", f.Name)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package parser
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// ErrHeaderRow is returned for the line a CSVParser reads its column names
+// from. It is not an entry; callers skip the line.
+var ErrHeaderRow = errors.New("csv header row")
+
+// CSVParser handles delimited lines such as CSV and TSV exports. Columns
+// name the values of each line; without configured columns the first line
+// parsed is taken as the header row. The columns "timestamp" (or "time"),
+// "level" and "message" (or "msg") fill those entry properties, columns
+// named "" or "-" are ignored, and every other column becomes a field.
+// Numbers and booleans are stored typed.
+type CSVParser struct {
+	name       string
+	delimiter  rune
+	timeFormat string
+	// fixed is set when the columns are configured rather than read from
+	// a header row
+	fixed bool
+
+	// mu guards columns, which the header row sets once
+	mu      sync.Mutex
+	columns []string
+}
+
+// NewCSVParser creates a parser for lines separated by delimiter. Empty
+// columns are read from the header row. timeFormat is the Go layout of the
+// timestamp column; empty accepts RFC 3339 and "2006-01-02 15:04:05".
+func NewCSVParser(name string, delimiter rune, columns []string, timeFormat string) *CSVParser {
+	return &CSVParser{name: name, delimiter: delimiter, columns: columns, timeFormat: timeFormat, fixed: columns != nil}
+}
+
+// fresh returns p itself when its columns are configured. Otherwise it
+// returns a parser that has yet to read a header row, or that has the one
+// prev read when prev splits lines the same way.
+func (p *CSVParser) fresh(prev Parser) Parser {
+	if p.fixed {
+		return p
+	}
+	next := NewCSVParser(p.name, p.delimiter, nil, p.timeFormat)
+	if old, ok := prev.(*CSVParser); ok && !old.fixed && old.name == p.name &&
+		old.delimiter == p.delimiter && old.timeFormat == p.timeFormat {
+		next.columns = old.header()
+	}
+	return next
+}
+
+// csvTimeLayouts are tried on the timestamp column without a time format
+var csvTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02T15:04:05.999999999"}
+
+// CanParse checks if the line splits into as many values as there are
+// columns. Before the header row is read, any line with two or more values
+// qualifies.
+func (p *CSVParser) CanParse(line string) bool {
+	values, err := p.split(line)
+	if err != nil {
+		return false
+	}
+	if columns := p.header(); columns != nil {
+		return len(values) == len(columns)
+	}
+	return len(values) > 1
+}
+
+// Parse parses a delimited line into a LogEntry, or records the header row
+// and returns ErrHeaderRow
+func (p *CSVParser) Parse(line string) (*storage.LogEntry, error) {
+	values, err := p.split(line)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", p.name, err)
+	}
+
+	p.mu.Lock()
+	if p.columns == nil {
+		for i, v := range values {
+			values[i] = strings.TrimSpace(strings.TrimPrefix(v, "\ufeff"))
+		}
+		p.columns = values
+		p.mu.Unlock()
+		return nil, ErrHeaderRow
+	}
+	columns := p.columns
+	p.mu.Unlock()
+
+	if len(values) != len(columns) {
+		return nil, fmt.Errorf("%s: line has %d values, expected %d", p.name, len(values), len(columns))
+	}
+
+	entry := &storage.LogEntry{
+		ID:     generateID(),
+		Fields: make(map[string]interface{}),
+		Raw:    line,
+	}
+	for i, column := range columns {
+		value := values[i]
+		if value == "" {
+			continue
+		}
+		switch strings.ToLower(column) {
+		case "", "-":
+		case "timestamp", "time":
+			entry.Timestamp = p.parseTime(value)
+		case "level":
+			entry.Level = NormalizeLevel(value)
+		case "message", "msg":
+			entry.Message = value
+		default:
+			entry.Fields[column] = csvValue(value)
+		}
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = timeNow()
+	}
+	promoteCorrelationIDs(entry)
+	return entry, nil
+}
+
+// header returns the columns, nil until the header row is read.
+func (p *CSVParser) header() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.columns
+}
+
+func (p *CSVParser) split(line string) ([]string, error) {
+	r := csv.NewReader(strings.NewReader(line))
+	r.Comma = p.delimiter
+	r.LazyQuotes = true
+	r.FieldsPerRecord = -1
+	values, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// parseTime reads the timestamp column, returning the zero time when it
+// doesn't match.
+func (p *CSVParser) parseTime(value string) time.Time {
+	if p.timeFormat != "" {
+		t, _ := time.ParseInLocation(p.timeFormat, value, location())
+		return t
+	}
+	for _, layout := range csvTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, location()); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// csvValue types a field value: integers as int64, other numbers as
+// float64, true and false as bool, anything else as the string.
+func csvValue(v string) interface{} {
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return n
+	}
+	// ParseFloat also accepts "Inf" and "NaN", which are rather text.
+	if c := v[0]; c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9') {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	switch v {
+	case "true", "TRUE", "True":
+		return true
+	case "false", "FALSE", "False":
+		return false
+	}
+	return v
+}
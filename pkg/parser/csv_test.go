@@ -0,0 +1,166 @@
+package parser
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCSVParserHeaderRow(t *testing.T) {
+	p := NewCSVParser("csv", ',', nil, "")
+
+	if !p.CanParse("timestamp,level,message,duration_ms,cached") {
+		t.Fatal("CanParse(header) = false")
+	}
+	if _, err := p.Parse("\ufefftimestamp, level ,message,duration_ms,cached"); !errors.Is(err, ErrHeaderRow) {
+		t.Fatalf("Parse(header) error = %v, want ErrHeaderRow", err)
+	}
+
+	entry, err := p.Parse(`2026-02-17T10:30:45Z,warning,"slow query, 2 joins",1250,true`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if !entry.Timestamp.Equal(time.Date(2026, 2, 17, 10, 30, 45, 0, time.UTC)) || entry.Level != "WARN" || entry.Message != "slow query, 2 joins" {
+		t.Fatalf("Parse() = %+v", entry)
+	}
+	want := map[string]interface{}{"duration_ms": int64(1250), "cached": true}
+	if !reflect.DeepEqual(entry.Fields, want) {
+		t.Fatalf("Parse() Fields = %#v, want %#v", entry.Fields, want)
+	}
+
+	if p.CanParse("2026-02-17T10:30:45Z,INFO,too few") {
+		t.Fatal("CanParse() with a missing column = true")
+	}
+	if _, err := p.Parse("a,b"); err == nil {
+		t.Fatal("Parse() with a missing column error = nil")
+	}
+}
+
+func TestCSVParserColumns(t *testing.T) {
+	tests := []struct {
+		name       string
+		delimiter  rune
+		columns    []string
+		timeFormat string
+		line       string
+		wantTime   time.Time
+		wantLevel  string
+		wantMsg    string
+		wantFields map[string]interface{}
+	}{
+		{
+			name:       "tsv with typed values",
+			delimiter:  '\t',
+			columns:    []string{"time", "account", "amount", "currency", "ratio"},
+			line:       "2026-02-17 10:30:45\tacme\t12.50\tUSD\t-0.5",
+			wantTime:   time.Date(2026, 2, 17, 10, 30, 45, 0, time.Local),
+			wantFields: map[string]interface{}{"account": "acme", "amount": 12.5, "currency": "USD", "ratio": -0.5},
+		},
+		{
+			name:       "ignored and empty columns",
+			delimiter:  ';',
+			columns:    []string{"-", "msg", "", "user"},
+			line:       "42;login failed;x;",
+			wantMsg:    "login failed",
+			wantFields: map[string]interface{}{},
+		},
+		{
+			name:       "time format and trace context",
+			delimiter:  ',',
+			columns:    []string{"timestamp", "level", "trace_id", "note"},
+			timeFormat: "02/01/2006 15:04",
+			line:       "17/02/2026 10:30,error,abc123,NaN",
+			wantTime:   time.Date(2026, 2, 17, 10, 30, 0, 0, time.Local),
+			wantLevel:  "ERROR",
+			wantFields: map[string]interface{}{"note": "NaN"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewCSVParser("test", tt.delimiter, tt.columns, tt.timeFormat)
+			if !p.CanParse(tt.line) {
+				t.Fatal("CanParse() = false")
+			}
+			entry, err := p.Parse(tt.line)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !tt.wantTime.IsZero() && !entry.Timestamp.Equal(tt.wantTime) {
+				t.Errorf("Timestamp = %v, want %v", entry.Timestamp, tt.wantTime)
+			}
+			if entry.Level != tt.wantLevel || entry.Message != tt.wantMsg {
+				t.Errorf("Level, Message = %q, %q; want %q, %q", entry.Level, entry.Message, tt.wantLevel, tt.wantMsg)
+			}
+			if !reflect.DeepEqual(entry.Fields, tt.wantFields) {
+				t.Errorf("Fields = %#v, want %#v", entry.Fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestCustomDelimitedFormats(t *testing.T) {
+	d, err := NewDetectorWithCustom([]CustomFormat{{Name: "billing", Columns: []string{"account", "amount", "message"}}})
+	if err != nil {
+		t.Fatalf("NewDetectorWithCustom() error = %v", err)
+	}
+	entry, err := d.ParseWithFormat("acme,3,invoice sent", "billing")
+	if err != nil || entry.Message != "invoice sent" || entry.Fields["amount"] != int64(3) {
+		t.Fatalf("ParseWithFormat() = %+v, %v", entry, err)
+	}
+	// In auto mode only lines with the right number of values match.
+	if entry, _ := d.Parse("just some text"); entry.Fields["account"] != nil {
+		t.Fatalf("Parse() matched a plain line: %+v", entry)
+	}
+
+	for _, f := range []CustomFormat{
+		{Name: "x", Delimiter: ",,"},
+		{Name: "x", Delimiter: `"`},
+		{Name: "x", Delimiter: ",", Pattern: `(?P<a>.)`},
+	} {
+		if _, err := NewDetectorWithCustom([]CustomFormat{f}); err == nil {
+			t.Errorf("NewDetectorWithCustom(%+v) error = nil", f)
+		}
+	}
+}
+
+func TestDetectorStreamHeaderRows(t *testing.T) {
+	d, err := NewDetectorWithCustom([]CustomFormat{{Name: "export", Delimiter: ";"}})
+	if err != nil {
+		t.Fatalf("NewDetectorWithCustom() error = %v", err)
+	}
+
+	for _, format := range []string{"csv", "export"} {
+		sep := map[string]string{"csv": ",", "export": ";"}[format]
+		first := d.Stream(nil)
+		if _, err := first.ParseWithFormat("level"+sep+"message", format); !errors.Is(err, ErrHeaderRow) {
+			t.Fatalf("%s: first header error = %v, want ErrHeaderRow", format, err)
+		}
+
+		// Another stream reads its own header row.
+		second := d.Stream(nil)
+		if _, err := second.ParseWithFormat("message"+sep+"user", format); !errors.Is(err, ErrHeaderRow) {
+			t.Fatalf("%s: second header error = %v, want ErrHeaderRow", format, err)
+		}
+		entry, err := second.ParseWithFormat("hello"+sep+"ana", format)
+		if err != nil || entry.Message != "hello" || entry.Fields["user"] != "ana" {
+			t.Fatalf("%s: second stream = %+v, %v", format, entry, err)
+		}
+
+		// A stream continuing the first, as after a reload, keeps its header.
+		resumed := d.Stream(first)
+		entry, err = resumed.ParseWithFormat("error"+sep+"boom", format)
+		if err != nil || entry.Level != "ERROR" || entry.Message != "boom" {
+			t.Fatalf("%s: resumed stream = %+v, %v", format, entry, err)
+		}
+	}
+
+	// In auto mode the stream's custom parser is the one tried.
+	s := d.Stream(nil)
+	if _, err := s.Parse("level;message"); !errors.Is(err, ErrHeaderRow) {
+		t.Fatalf("Parse(header) error = %v, want ErrHeaderRow", err)
+	}
+	if entry, err := s.ParseWithFormat("warn;disk", "export"); err != nil || entry.Message != "disk" {
+		t.Fatalf("ParseWithFormat() after auto header = %+v, %v", entry, err)
+	}
+}
@@ -2,6 +2,11 @@ package parser
 
 import (
 	"fmt"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
 	"time"
 
 	"github.com/mchurichi/peek/pkg/storage"
@@ -10,28 +15,126 @@ import (
 // Detector auto-detects and parses log formats
 type Detector struct {
 	parsers []Parser
+	// builtin maps the explicit format names to this detector's parsers
+	builtin map[string]Parser
+	// custom maps the names of user-defined formats to their parsers
+	custom map[string]Parser
+	// aliases rename fields of parsed entries (SetAliases)
+	aliases []fieldAlias
 }
 
 // NewDetector creates a new format detector
 func NewDetector() *Detector {
+	builtin := make(map[string]Parser, len(formats))
+	for name, newParser := range formats {
+		builtin[name] = newParser()
+	}
 	return &Detector{
+		builtin: builtin,
 		parsers: []Parser{
-			NewLogfmtParser(), // Try logfmt first (key=value)
-			NewJSONParser(),   // Then generic JSON
+			NewCEFParser(),          // Try CEF and LEEF records first, which may
+			NewLEEFParser(),         // follow a syslog header
+			NewSyslogParser(),       // Then syslog (<PRI> header)
+			NewAccessLogParser(),    // Then web server access logs (CLF/Combined)
+			NewKlogParser(),         // Then Kubernetes klog/glog headers
+			newDefaultLog4jParser(), // Then log4j/logback pattern layouts
+			NewJournaldParser(),     // Then journalctl -o json (before generic JSON)
+			NewGELFParser(),         // Then GELF JSON messages
+			NewLogfmtParser(),       // Then logfmt (key=value)
+			NewJSONParser(),         // Then generic JSON
 		},
 	}
 }
 
+// customFormatName restricts custom format names to what reads well as a
+// --format value
+var customFormatName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// NewDetectorWithCustom creates a format detector that also knows the
+// user-defined formats in custom. They are selectable by name and, in auto
+// mode, tried before the built-in parsers in the order given.
+func NewDetectorWithCustom(custom []CustomFormat) (*Detector, error) {
+	d := NewDetector()
+	if len(custom) == 0 {
+		return d, nil
+	}
+	d.custom = make(map[string]Parser, len(custom))
+	parsers := make([]Parser, 0, len(custom)+len(d.parsers))
+	for _, f := range custom {
+		switch _, builtin := formats[f.Name]; {
+		case !customFormatName.MatchString(f.Name):
+			return nil, fmt.Errorf("invalid custom format name %q (use letters, digits, - and _)", f.Name)
+		case builtin || f.Name == "auto":
+			return nil, fmt.Errorf("custom format %s clashes with a built-in format", f.Name)
+		case d.custom[f.Name] != nil:
+			return nil, fmt.Errorf("custom format %s is defined twice", f.Name)
+		}
+		p, err := newCustomParser(f)
+		if err != nil {
+			return nil, err
+		}
+		d.custom[f.Name] = p
+		parsers = append(parsers, p)
+	}
+	d.parsers = append(parsers, d.parsers...)
+	return d, nil
+}
+
+// ParseInfo describes how a Detector parsed a record, for pipeline
+// debugging.
+type ParseInfo struct {
+	// Parser is the format the record was parsed as, or "raw" when auto
+	// mode recognized none.
+	Parser string
+	// Aliases lists the aliases applied, as "from -> to".
+	Aliases []string
+}
+
+// CommandStatuses reports the health of the parser commands of d's custom
+// formats, in alphabetical order of format name.
+func (d *Detector) CommandStatuses() []CommandStatus {
+	var statuses []CommandStatus
+	for _, name := range slices.Sorted(maps.Keys(d.custom)) {
+		if p, ok := d.custom[name].(*CommandParser); ok {
+			statuses = append(statuses, p.Status())
+		}
+	}
+	return statuses
+}
+
+// Close stops the parser commands of d's custom formats, and so those of
+// its streams, e.g. once a reload has replaced d.
+func (d *Detector) Close() error {
+	for _, p := range d.custom {
+		if p, ok := p.(*CommandParser); ok {
+			p.Close()
+		}
+	}
+	return nil
+}
+
 // Parse attempts to parse a line with auto-detection
 func (d *Detector) Parse(line string) (*storage.LogEntry, error) {
+	return d.parseAuto(line, nil)
+}
+
+// parseAuto parses line with the first parser that recognizes it, filling
+// info when it is not nil.
+func (d *Detector) parseAuto(line string, info *ParseInfo) (*storage.LogEntry, error) {
 	// Try each parser
 	for _, parser := range d.parsers {
 		if parser.CanParse(line) {
-			return parser.Parse(line)
+			if info != nil {
+				info.Parser = d.formatName(parser)
+			}
+			return d.parse(parser, line, info)
 		}
 	}
 
 	// If no parser worked, create a raw entry
+	if info != nil {
+		info.Parser = "raw"
+	}
 	return &storage.LogEntry{
 		ID:        generateID(),
 		Timestamp: timeNow(),
@@ -41,26 +144,176 @@ func (d *Detector) Parse(line string) (*storage.LogEntry, error) {
 	}, nil
 }
 
+// formats maps the explicit format names to constructors of their parsers.
+// csv and tsv read their columns from the header row and are never
+// auto-detected; every Detector, and every Stream, builds its own.
+var formats = map[string]func() Parser{
+	"access":   func() Parser { return NewAccessLogParser() },
+	"cef":      func() Parser { return NewCEFParser() },
+	"csv":      func() Parser { return NewCSVParser("csv", ',', nil, "") },
+	"gelf":     func() Parser { return NewGELFParser() },
+	"tsv":      func() Parser { return NewCSVParser("tsv", '\t', nil, "") },
+	"json":     func() Parser { return NewJSONParser() },
+	"journald": func() Parser { return NewJournaldParser() },
+	"klog":     func() Parser { return NewKlogParser() },
+	"leef":     func() Parser { return NewLEEFParser() },
+	"log4j":    func() Parser { return newDefaultLog4jParser() },
+	"logfmt":   func() Parser { return NewLogfmtParser() },
+	"syslog":   func() Parser { return NewSyslogParser() },
+}
+
+// A streamParser keeps state read from earlier lines of a stream, such as
+// a CSV header row, so each stream needs its own.
+type streamParser interface {
+	Parser
+	// fresh returns a parser for a new stream, carrying over the state of
+	// prev when prev parses the same way; prev may be nil.
+	fresh(prev Parser) Parser
+}
+
+// Stream returns a detector for one input stream: a collected file or
+// command, or one /ingest request. It shares d's stateless parsers and
+// gets its own of the parsers that remember earlier lines, so a CSV header
+// row only applies to the stream it was read from. prev is the stream
+// detector the input was parsed with so far, or nil for a new input; a
+// header row prev already read carries over when the format is unchanged,
+// as on a live reload.
+func (d *Detector) Stream(prev *Detector) *Detector {
+	if prev == nil {
+		prev = &Detector{}
+	}
+	s := &Detector{
+		parsers: slices.Clone(d.parsers),
+		builtin: renewParsers(d.builtin, prev.builtin),
+		custom:  renewParsers(d.custom, prev.custom),
+		aliases: d.aliases,
+	}
+	for i, p := range s.parsers {
+		if _, ok := p.(streamParser); !ok {
+			continue
+		}
+		for name, old := range d.custom {
+			if old == p {
+				s.parsers[i] = s.custom[name]
+			}
+		}
+	}
+	return s
+}
+
+// renewParsers copies parsers by name, with a fresh parser for each
+// streamParser that carries over the state of the one prev has by the name.
+func renewParsers(parsers, prev map[string]Parser) map[string]Parser {
+	if parsers == nil {
+		return nil
+	}
+	renewed := make(map[string]Parser, len(parsers))
+	for name, p := range parsers {
+		if sp, ok := p.(streamParser); ok {
+			p = sp.fresh(prev[name])
+		}
+		renewed[name] = p
+	}
+	return renewed
+}
+
+// Formats returns the built-in names accepted by ParseWithFormat: "auto"
+// followed by the explicit formats in alphabetical order
+func Formats() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append([]string{"auto"}, names...)
+}
+
+// ValidFormat reports whether format is one of the built-in Formats
+func ValidFormat(format string) bool {
+	return slices.Contains(Formats(), format)
+}
+
+// Formats returns the names d.ParseWithFormat accepts: Formats followed by
+// the custom formats in alphabetical order
+func (d *Detector) Formats() []string {
+	names := slices.Sorted(maps.Keys(d.custom))
+	return append(Formats(), names...)
+}
+
+// ValidFormat reports whether d.ParseWithFormat accepts format
+func (d *Detector) ValidFormat(format string) bool {
+	return ValidFormat(format) || d.custom[format] != nil
+}
+
 // ParseWithFormat parses a line with a specific format
 func (d *Detector) ParseWithFormat(line, format string) (*storage.LogEntry, error) {
-	var parser Parser
-
-	switch format {
-	case "json":
-		parser = NewJSONParser()
-	case "logfmt":
-		parser = NewLogfmtParser()
-	case "auto":
-		return d.Parse(line)
-	default:
+	return d.parseWithFormat(line, format, nil)
+}
+
+// parseWithFormat is ParseWithFormat, filling info when it is not nil.
+func (d *Detector) parseWithFormat(line, format string, info *ParseInfo) (*storage.LogEntry, error) {
+	if format == "auto" {
+		return d.parseAuto(line, info)
+	}
+	parser, ok := d.parser(format)
+	if !ok {
 		return nil, fmt.Errorf("unknown format: %s", format)
 	}
 
 	if !parser.CanParse(line) {
+		// A parser command that is down is not a mismatch; keep its
+		// reason for the quarantined line.
+		if cp, ok := parser.(*CommandParser); ok {
+			if err := cp.unavailable(line); err != nil {
+				return nil, err
+			}
+		}
 		return nil, fmt.Errorf("line does not match format %s", format)
 	}
 
-	return parser.Parse(line)
+	if info != nil {
+		info.Parser = format
+	}
+	return d.parse(parser, line, info)
+}
+
+// parse parses line with parser and applies d's aliases, listing them in
+// info when it is not nil
+func (d *Detector) parse(parser Parser, line string, info *ParseInfo) (*storage.LogEntry, error) {
+	entry, err := parser.Parse(line)
+	if err != nil {
+		return nil, err
+	}
+	applied := d.applyAliases(entry)
+	if info != nil {
+		info.Aliases = applied
+	}
+	return entry, nil
+}
+
+// formatName returns the format name of p, one of d's auto-detected
+// parsers.
+func (d *Detector) formatName(p Parser) string {
+	for name, c := range d.custom {
+		if c == p {
+			return name
+		}
+	}
+	for name, b := range d.builtin {
+		if reflect.TypeOf(b) == reflect.TypeOf(p) {
+			return name
+		}
+	}
+	return fmt.Sprintf("%T", p)
+}
+
+// parser returns the parser of an explicit format name
+func (d *Detector) parser(format string) (Parser, bool) {
+	if p, ok := d.custom[format]; ok {
+		return p, true
+	}
+	p, ok := d.builtin[format]
+	return p, ok
 }
 
 // timeNow is a helper for testing
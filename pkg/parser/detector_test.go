@@ -27,6 +27,62 @@ func TestDetector_Parse(t *testing.T) {
 			wantMessage: "test message",
 			wantFormat:  "logfmt",
 		},
+		{
+			name:        "auto-detect syslog",
+			line:        `<11>Oct 11 22:14:15 host app[123]: msg=failed`,
+			wantLevel:   "ERROR",
+			wantMessage: "msg=failed",
+			wantFormat:  "syslog",
+		},
+		{
+			name:        "auto-detect access log",
+			line:        `10.0.0.1 - - [17/Feb/2026:10:30:45 +0000] "GET /search?msg=x HTTP/1.1" 404 0 "-" "curl/8.0"`,
+			wantLevel:   "WARN",
+			wantMessage: "GET /search?msg=x 404",
+			wantFormat:  "access",
+		},
+		{
+			name:        "auto-detect klog",
+			line:        `W0217 10:30:45.000000 1 leader.go:88] "Lost lease" lease=kube-system/ctrl`,
+			wantLevel:   "WARN",
+			wantMessage: "Lost lease",
+			wantFormat:  "klog",
+		},
+		{
+			name:        "auto-detect journald JSON",
+			line:        `{"__REALTIME_TIMESTAMP":"1771324245003000","PRIORITY":"4","_SYSTEMD_UNIT":"ssh.service","MESSAGE":"timeout"}`,
+			wantLevel:   "WARN",
+			wantMessage: "timeout",
+			wantFormat:  "journald",
+		},
+		{
+			name:        "auto-detect GELF",
+			line:        `{"version":"1.1","host":"web01","short_message":"timeout","timestamp":1771324245.003,"level":4}`,
+			wantLevel:   "WARN",
+			wantMessage: "timeout",
+			wantFormat:  "gelf",
+		},
+		{
+			name:        "auto-detect CEF behind a syslog header",
+			line:        `<134>Feb 17 10:30:45 fw01 CEF:0|Acme|Firewall|1.0|13|Blocked connection|7|src=10.0.0.5 act=deny`,
+			wantLevel:   "ERROR",
+			wantMessage: "Blocked connection",
+			wantFormat:  "cef",
+		},
+		{
+			name:        "auto-detect LEEF",
+			line:        "LEEF:1.0|Acme|IDS|1.0|PortScan|src=10.0.0.5\tsev=4",
+			wantLevel:   "WARN",
+			wantMessage: "PortScan",
+			wantFormat:  "leef",
+		},
+		{
+			name:        "auto-detect log4j",
+			line:        "2026-02-17 10:30:45,003 [main] WARN  com.example.App - Pool exhausted",
+			wantLevel:   "WARN",
+			wantMessage: "Pool exhausted",
+			wantFormat:  "log4j",
+		},
 		{
 			name:        "fallback to raw for plain text",
 			line:        `This is just plain text`,
@@ -103,6 +159,28 @@ func TestDetector_ParseWithFormat(t *testing.T) {
 			wantMessage: "test",
 			wantErr:     false,
 		},
+		{
+			name:        "explicit syslog format",
+			line:        `<12>1 - host app - - - disk low`,
+			format:      "syslog",
+			wantLevel:   "WARN",
+			wantMessage: "disk low",
+			wantErr:     false,
+		},
+		{
+			name:        "explicit access format",
+			line:        `10.0.0.1 - - [17/Feb/2026:10:30:45 +0000] "POST /api HTTP/1.1" 503 12`,
+			format:      "access",
+			wantLevel:   "ERROR",
+			wantMessage: "POST /api 503",
+			wantErr:     false,
+		},
+		{
+			name:    "syslog format with non-syslog line",
+			line:    `level=INFO msg="test"`,
+			format:  "syslog",
+			wantErr: true,
+		},
 		{
 			name:        "auto format falls back to Parse",
 			line:        `plain text`,
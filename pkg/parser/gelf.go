@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// GELFParser handles Graylog Extended Log Format messages, the JSON
+// payloads GELF libraries and Docker's gelf log driver send:
+//
+//	{"version":"1.1","host":"web01","short_message":"Connection timeout","timestamp":1771324245.003,"level":3,"_service":"api"}
+//
+// short_message is the message and level a syslog severity (also stored as
+// the severity field). host, full_message and the deprecated facility,
+// file and line are kept as fields, and additional fields lose their
+// leading underscore (_service becomes service). Messages without a
+// timestamp get the current time.
+type GELFParser struct{}
+
+// NewGELFParser creates a new GELF parser
+func NewGELFParser() *GELFParser {
+	return &GELFParser{}
+}
+
+// CanParse checks if the line is a GELF JSON message
+func (p *GELFParser) CanParse(line string) bool {
+	if !strings.Contains(line, `"short_message"`) {
+		return false
+	}
+	_, err := p.parse(line)
+	return err == nil
+}
+
+// Parse parses a GELF message into a LogEntry
+func (p *GELFParser) Parse(line string) (*storage.LogEntry, error) {
+	entry, err := p.parse(line)
+	if err != nil {
+		return nil, err
+	}
+	entry.ID = generateID()
+	promoteCorrelationIDs(entry)
+	return entry, nil
+}
+
+func (p *GELFParser) parse(line string) (*storage.LogEntry, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &obj); err != nil {
+		return nil, fmt.Errorf("gelf: invalid JSON: %w", err)
+	}
+	msg, ok := obj["short_message"].(string)
+	if !ok {
+		return nil, errors.New("gelf: missing short_message")
+	}
+
+	entry := &storage.LogEntry{
+		Message: msg,
+		Fields:  make(map[string]interface{}),
+		Raw:     line,
+	}
+	switch ts := obj["timestamp"].(type) {
+	case float64:
+		sec, frac := math.Modf(ts)
+		entry.Timestamp = time.Unix(int64(sec), int64(math.Round(frac*1e6))*1e3)
+	case nil:
+		entry.Timestamp = timeNow()
+	default:
+		return nil, errors.New("gelf: timestamp is not a number")
+	}
+	if level, ok := obj["level"].(float64); ok {
+		if level < 0 || level >= float64(len(syslogLevels)) || level != math.Trunc(level) {
+			return nil, fmt.Errorf("gelf: invalid level %v", level)
+		}
+		entry.Level = syslogLevels[int(level)]
+		entry.Fields["severity"] = syslogSeverities[int(level)]
+	}
+
+	for k, v := range obj {
+		switch k {
+		case "version", "short_message", "timestamp", "level":
+			continue
+		case "_id":
+			// Reserved by the spec; Graylog drops it too.
+			continue
+		}
+		entry.Fields[strings.TrimPrefix(k, "_")] = v
+	}
+	return entry, nil
+}
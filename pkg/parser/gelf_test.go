@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGELFParser_Parse(t *testing.T) {
+	now := time.Date(2026, 2, 17, 10, 30, 45, 0, time.UTC)
+	originalTimeNow := timeNow
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = originalTimeNow }()
+
+	tests := []struct {
+		name       string
+		line       string
+		wantTime   time.Time
+		wantLevel  string
+		wantMsg    string
+		wantFields map[string]interface{}
+	}{
+		{
+			name:      "full message",
+			line:      `{"version":"1.1","host":"web01","short_message":"Connection timeout","full_message":"Connection timeout\nat db.go:12","timestamp":1771324245.003,"level":3,"_service":"api","_user_id":42,"_id":"dropped"}`,
+			wantTime:  time.UnixMilli(1771324245003),
+			wantLevel: "ERROR",
+			wantMsg:   "Connection timeout",
+			wantFields: map[string]interface{}{
+				"severity": "err", "host": "web01", "full_message": "Connection timeout\nat db.go:12",
+				"service": "api", "user_id": float64(42),
+			},
+		},
+		{
+			name:       "minimal message",
+			line:       `{"version":"1.1","host":"web01","short_message":"hello"}`,
+			wantTime:   now,
+			wantMsg:    "hello",
+			wantFields: map[string]interface{}{"host": "web01"},
+		},
+	}
+
+	parser := NewGELFParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !parser.CanParse(tt.line) {
+				t.Fatal("CanParse() = false")
+			}
+			entry, err := parser.Parse(tt.line)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !entry.Timestamp.Equal(tt.wantTime) {
+				t.Errorf("Timestamp = %v, want %v", entry.Timestamp, tt.wantTime)
+			}
+			if entry.Level != tt.wantLevel || entry.Message != tt.wantMsg {
+				t.Errorf("Level, Message = %q, %q; want %q, %q", entry.Level, entry.Message, tt.wantLevel, tt.wantMsg)
+			}
+			if !reflect.DeepEqual(entry.Fields, tt.wantFields) {
+				t.Errorf("Fields = %#v, want %#v", entry.Fields, tt.wantFields)
+			}
+			if entry.Raw != tt.line || entry.ID == "" {
+				t.Errorf("Raw, ID = %q, %q", entry.Raw, entry.ID)
+			}
+		})
+	}
+}
+
+func TestGELFParser_Rejects(t *testing.T) {
+	parser := NewGELFParser()
+	for _, line := range []string{
+		`{"version":"1.1","host":"web01"}`,
+		`{"short_message":"x","timestamp":"yesterday"}`,
+		`{"short_message":"x","level":9}`,
+		`{"short_message":"x","level":2.5}`,
+		`{"short_message":`,
+		`level=info msg=hello`,
+	} {
+		if parser.CanParse(line) {
+			t.Errorf("CanParse(%q) = true", line)
+		}
+		if _, err := parser.Parse(line); err == nil {
+			t.Errorf("Parse(%q) error = nil", line)
+		}
+	}
+}
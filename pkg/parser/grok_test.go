@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGrokPatternsCompile(t *testing.T) {
+	for name := range grokPatterns {
+		if _, _, err := compileGrok("%{" + name + "}"); err != nil {
+			t.Errorf("compileGrok(%%{%s}) error = %v", name, err)
+		}
+	}
+}
+
+func TestGrokParser(t *testing.T) {
+	tests := []struct {
+		name          string
+		format        CustomFormat
+		line          string
+		wantLevel     string
+		wantMessage   string
+		wantTimestamp time.Time
+		wantFields    map[string]interface{}
+	}{
+		{
+			name:        "simple expression with conversions",
+			format:      CustomFormat{Name: "api", Grok: `%{IP:client} %{WORD:method} %{URIPATHPARAM:path} %{NUMBER:duration:float} %{INT:status:int}`},
+			line:        "10.0.0.1 GET /users?id=7 0.25 404",
+			wantMessage: "10.0.0.1 GET /users?id=7 0.25 404",
+			wantFields:  map[string]interface{}{"client": "10.0.0.1", "method": "GET", "path": "/users?id=7", "duration": 0.25, "status": int64(404)},
+		},
+		{
+			name:          "level, timestamp and message",
+			format:        CustomFormat{Name: "app", Grok: `%{TIMESTAMP_ISO8601:timestamp} +%{LOGLEVEL:level} \[%{DATA:thread}\] %{GREEDYDATA:message}`},
+			line:          "2026-02-17T10:30:45Z  WARNING [main-1] disk almost full",
+			wantLevel:     "WARN",
+			wantMessage:   "disk almost full",
+			wantTimestamp: time.Date(2026, 2, 17, 10, 30, 45, 0, time.UTC),
+			wantFields:    map[string]interface{}{"thread": "main-1"},
+		},
+		{
+			name:          "combined apache log",
+			format:        CustomFormat{Name: "apache", Grok: `%{COMBINEDAPACHELOG}`, TimeFormat: "02/Jan/2006:15:04:05 -0700"},
+			line:          `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://example.com/" "Mozilla/4.08"`,
+			wantMessage:   `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://example.com/" "Mozilla/4.08"`,
+			wantTimestamp: time.Date(2000, 10, 10, 20, 55, 36, 0, time.UTC),
+			wantFields: map[string]interface{}{
+				"clientip": "127.0.0.1", "ident": "-", "auth": "frank", "verb": "GET", "request": "/apache_pb.gif",
+				"httpversion": "1.0", "response": "200", "bytes": "2326", "referrer": `"http://example.com/"`, "agent": `"Mozilla/4.08"`,
+			},
+		},
+		{
+			name:        "syslog line with nested captures",
+			format:      CustomFormat{Name: "sys", Grok: `%{SYSLOGLINE}`, TimeFormat: time.Stamp},
+			line:        "Feb 17 10:30:45 web01 sshd[4721]: Failed password",
+			wantMessage: "Failed password",
+			wantFields:  map[string]interface{}{"logsource": "web01", "program": "sshd", "pid": "4721"},
+		},
+		{
+			name:        "raw named groups mixed in",
+			format:      CustomFormat{Name: "mixed", Grok: `%{WORD:user} (?P<action>login|logout)`},
+			line:        "alice login",
+			wantMessage: "alice login",
+			wantFields:  map[string]interface{}{"user": "alice", "action": "login"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser, err := NewRegexParser(tt.format)
+			if err != nil {
+				t.Fatalf("NewRegexParser() error = %v", err)
+			}
+			entry, err := parser.Parse(tt.line)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if entry.Level != tt.wantLevel || entry.Message != tt.wantMessage {
+				t.Errorf("Parse() Level = %q, Message = %q; want %q, %q", entry.Level, entry.Message, tt.wantLevel, tt.wantMessage)
+			}
+			if !tt.wantTimestamp.IsZero() && !entry.Timestamp.Equal(tt.wantTimestamp) {
+				t.Errorf("Parse() Timestamp = %v, want %v", entry.Timestamp, tt.wantTimestamp)
+			}
+			if !reflect.DeepEqual(entry.Fields, tt.wantFields) {
+				t.Errorf("Parse() Fields = %#v, want %#v", entry.Fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestGrokErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format CustomFormat
+	}{
+		{name: "unknown pattern", format: CustomFormat{Name: "x", Grok: `%{NOPE:field}`}},
+		{name: "unknown type", format: CustomFormat{Name: "x", Grok: `%{INT:n:bool}`}},
+		{name: "no captures", format: CustomFormat{Name: "x", Grok: `%{IP} %{WORD}`}},
+		{name: "invalid regex around references", format: CustomFormat{Name: "x", Grok: `%{IP:ip} (`}},
+		{name: "pattern and grok", format: CustomFormat{Name: "x", Pattern: `(?P<a>.)`, Grok: `%{IP:ip}`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRegexParser(tt.format); err == nil {
+				t.Fatal("NewRegexParser() error = nil")
+			}
+		})
+	}
+}
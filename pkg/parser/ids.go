@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// Entry ID strategies accepted by NewIDGenerator.
+const (
+	// IDRandom is 16 random hex characters (the default).
+	IDRandom = "random"
+	// IDULID is a 26-character ULID whose time part is the entry timestamp,
+	// so IDs sort like the entries they name.
+	IDULID = "ulid"
+	// IDHash is a content hash of namespace, timestamp and raw line, so
+	// importing the same lines twice overwrites instead of duplicating.
+	IDHash = "hash"
+)
+
+// IDGenerator returns the ID for a parsed entry. It is applied after the
+// entry's timestamp, raw line and namespace are final.
+type IDGenerator func(entry *storage.LogEntry) string
+
+// NewIDGenerator returns the generator for strategy; empty means IDRandom.
+func NewIDGenerator(strategy string) (IDGenerator, error) {
+	switch strategy {
+	case "", IDRandom:
+		return func(*storage.LogEntry) string { return generateID() }, nil
+	case IDULID:
+		return ulidID, nil
+	case IDHash:
+		return contentHashID, nil
+	default:
+		return nil, fmt.Errorf("unknown id strategy: %s (use random, ulid, or hash)", strategy)
+	}
+}
+
+// crockford is the ULID base32 alphabet.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulidID builds a ULID from the entry timestamp (milliseconds) and 80 random bits.
+func ulidID(entry *storage.LogEntry) string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(entry.Timestamp.UnixMilli())<<16)
+	rand.Read(b[6:])
+
+	// 128 bits encode as 26 base32 characters, most significant first, with
+	// the first character carrying the top 3 bits.
+	out := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		bit := 128 - (26-i)*5 // offset of this character's lowest bit from the top
+		var v byte
+		for j := 0; j < 5; j++ {
+			pos := bit + 4 - j // bit index from the most significant end
+			if pos < 0 || pos >= 128 {
+				continue
+			}
+			if b[pos/8]&(0x80>>(pos%8)) != 0 {
+				v |= 1 << j
+			}
+		}
+		out[i] = crockford[v]
+	}
+	return string(out)
+}
+
+// contentHashID hashes the namespace, timestamp and raw line. Lines without
+// their own timestamp get the ingest time, so only timestamped lines
+// dedupe across imports.
+func contentHashID(entry *storage.LogEntry) string {
+	h := sha256.New()
+	h.Write([]byte(entry.Namespace))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.FormatInt(entry.Timestamp.UnixNano(), 10)))
+	h.Write([]byte{0})
+	h.Write([]byte(entry.Raw))
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+func TestNewIDGenerator(t *testing.T) {
+	ts := time.UnixMilli(1469918176385).UTC()
+	entry := &storage.LogEntry{Timestamp: ts, Raw: `{"msg":"hi"}`}
+
+	tests := []struct {
+		strategy string
+		check    func(t *testing.T, gen IDGenerator)
+	}{
+		{strategy: "", check: func(t *testing.T, gen IDGenerator) {
+			if id := gen(entry); len(id) != 16 {
+				t.Fatalf("random id = %q, want 16 hex characters", id)
+			}
+		}},
+		{strategy: IDULID, check: func(t *testing.T, gen IDGenerator) {
+			a, b := gen(entry), gen(entry)
+			// Time part from the ULID spec example for this millisecond.
+			if len(a) != 26 || a[:10] != "01ARYZ6S41" || a == b {
+				t.Fatalf("ulid ids = %q, %q, want distinct 26-char ids starting 01ARYZ6S41", a, b)
+			}
+			later := gen(&storage.LogEntry{Timestamp: ts.Add(time.Millisecond)})
+			if later <= a {
+				t.Fatalf("ulid %q for a later entry does not sort after %q", later, a)
+			}
+		}},
+		{strategy: IDHash, check: func(t *testing.T, gen IDGenerator) {
+			a := gen(entry)
+			if len(a) != 32 || gen(&storage.LogEntry{Timestamp: ts, Raw: entry.Raw}) != a {
+				t.Fatalf("hash id = %q, want a stable 32-char id", a)
+			}
+			for _, other := range []*storage.LogEntry{
+				{Timestamp: ts.Add(time.Nanosecond), Raw: entry.Raw},
+				{Timestamp: ts, Raw: `{"msg":"bye"}`},
+				{Timestamp: ts, Raw: entry.Raw, Namespace: "team"},
+			} {
+				if gen(other) == a {
+					t.Fatalf("hash id for %+v collides with %+v", other, entry)
+				}
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			gen, err := NewIDGenerator(tt.strategy)
+			if err != nil {
+				t.Fatalf("NewIDGenerator(%q) error = %v", tt.strategy, err)
+			}
+			tt.check(t, gen)
+		})
+	}
+
+	if _, err := NewIDGenerator("uuid"); err == nil {
+		t.Fatalf("NewIDGenerator(uuid) error = nil, want error")
+	}
+}
@@ -0,0 +1,147 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// JournaldParser handles systemd journal entries as printed by
+// journalctl -o json, one JSON object per line:
+//
+//	{"__REALTIME_TIMESTAMP":"1771324245003000","PRIORITY":"3","_SYSTEMD_UNIT":"nginx.service","MESSAGE":"bind() failed"}
+//
+// and by journalctl -o export, one KEY=VALUE line per field with entries
+// separated by an empty line. An export entry spans several lines, so the
+// parser takes the whole record (see JournaldExportContinuation).
+//
+// __REALTIME_TIMESTAMP gives the timestamp, PRIORITY the level (as a
+// syslog severity, also stored as the severity field) and MESSAGE the
+// message. Every other field is kept under its journal name, such as
+// _SYSTEMD_UNIT or SYSLOG_IDENTIFIER, except the __ address fields (cursor,
+// monotonic timestamp, sequence numbers).
+type JournaldParser struct{}
+
+// NewJournaldParser creates a new journald parser
+func NewJournaldParser() *JournaldParser {
+	return &JournaldParser{}
+}
+
+// JournaldExportContinuation matches the KEY=VALUE lines of a journalctl
+// -o export entry. Used as the multiline pattern, it joins each entry into
+// one record, which the empty line between entries ends.
+var JournaldExportContinuation = regexp.MustCompile(`^[A-Za-z0-9_]+=`)
+
+// CanParse checks if the line is a journald JSON entry or export record
+func (p *JournaldParser) CanParse(line string) bool {
+	if !strings.Contains(line, "__REALTIME_TIMESTAMP") {
+		return false
+	}
+	_, err := p.parse(line)
+	return err == nil
+}
+
+// Parse parses a journald entry into a LogEntry
+func (p *JournaldParser) Parse(line string) (*storage.LogEntry, error) {
+	entry, err := p.parse(line)
+	if err != nil {
+		return nil, err
+	}
+	entry.ID = generateID()
+	promoteCorrelationIDs(entry)
+	return entry, nil
+}
+
+// parsesRecords marks JournaldParser as a recordParser.
+func (p *JournaldParser) parsesRecords() {}
+
+func (p *JournaldParser) parse(text string) (*storage.LogEntry, error) {
+	var obj map[string]interface{}
+	if strings.HasPrefix(text, "{") {
+		if err := json.Unmarshal([]byte(text), &obj); err != nil {
+			return nil, fmt.Errorf("journald: invalid JSON: %w", err)
+		}
+	} else {
+		var err error
+		if obj, err = parseJournalExport(text); err != nil {
+			return nil, err
+		}
+	}
+
+	realtime, _ := obj["__REALTIME_TIMESTAMP"].(string)
+	usec, err := strconv.ParseInt(realtime, 10, 64)
+	if err != nil {
+		return nil, errors.New("journald: missing or invalid __REALTIME_TIMESTAMP")
+	}
+	entry := &storage.LogEntry{
+		Timestamp: time.UnixMicro(usec),
+		Message:   journalString(obj["MESSAGE"]),
+		Fields:    make(map[string]interface{}),
+		Raw:       text,
+	}
+	if prio, err := strconv.Atoi(journalString(obj["PRIORITY"])); err == nil && prio >= 0 && prio < len(syslogLevels) {
+		entry.Level = syslogLevels[prio]
+		entry.Fields["severity"] = syslogSeverities[prio]
+	}
+	for k, v := range obj {
+		if strings.HasPrefix(k, "__") || k == "MESSAGE" || k == "PRIORITY" || v == nil {
+			continue
+		}
+		if s, ok := journalBytes(v); ok {
+			v = s
+		}
+		entry.Fields[k] = v
+	}
+	return entry, nil
+}
+
+// parseJournalExport reads the KEY=VALUE lines of an export entry. Binary
+// fields, written as the name alone followed by a length-prefixed value,
+// can't survive line splitting and are rejected.
+func parseJournalExport(text string) (map[string]interface{}, error) {
+	obj := make(map[string]interface{})
+	for _, line := range strings.Split(text, "\n") {
+		if line == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("journald: binary field %s is not supported (use journalctl -o json)", line)
+		}
+		obj[key] = value
+	}
+	return obj, nil
+}
+
+// journalString returns a field value as text.
+func journalString(v interface{}) string {
+	if s, ok := journalBytes(v); ok {
+		return s
+	}
+	s, _ := v.(string)
+	return s
+}
+
+// journalBytes decodes a binary value, which -o json writes as an array of
+// byte values. Arrays of strings (repeated fields) are left alone.
+func journalBytes(v interface{}) (string, bool) {
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) == 0 {
+		return "", false
+	}
+	b := make([]byte, len(arr))
+	for i, x := range arr {
+		n, ok := x.(float64)
+		if !ok || n < 0 || n > 255 {
+			return "", false
+		}
+		b[i] = byte(n)
+	}
+	return string(b), true
+}
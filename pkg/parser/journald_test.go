@@ -0,0 +1,121 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJournaldParser_Parse(t *testing.T) {
+	ts := time.UnixMicro(1771324245003000)
+	tests := []struct {
+		name       string
+		line       string
+		wantLevel  string
+		wantMsg    string
+		wantFields map[string]interface{}
+	}{
+		{
+			name:      "json",
+			line:      `{"__CURSOR":"s=abc;i=1","__REALTIME_TIMESTAMP":"1771324245003000","__MONOTONIC_TIMESTAMP":"123","PRIORITY":"3","_SYSTEMD_UNIT":"nginx.service","_PID":"812","MESSAGE":"bind() failed"}`,
+			wantLevel: "ERROR",
+			wantMsg:   "bind() failed",
+			wantFields: map[string]interface{}{
+				"severity": "err", "_SYSTEMD_UNIT": "nginx.service", "_PID": "812",
+			},
+		},
+		{
+			name:      "json binary message and repeated field",
+			line:      `{"__REALTIME_TIMESTAMP":"1771324245003000","PRIORITY":"6","MESSAGE":[104,105,10,116,104,101,114,101],"TAG":["a","b"],"_BIG":null}`,
+			wantLevel: "INFO",
+			wantMsg:   "hi\nthere",
+			wantFields: map[string]interface{}{
+				"severity": "info", "TAG": []interface{}{"a", "b"},
+			},
+		},
+		{
+			name:      "export record",
+			line:      "__CURSOR=s=abc;i=1\n__REALTIME_TIMESTAMP=1771324245003000\nPRIORITY=4\nSYSLOG_IDENTIFIER=kernel\nMESSAGE=temperature above threshold=90",
+			wantLevel: "WARN",
+			wantMsg:   "temperature above threshold=90",
+			wantFields: map[string]interface{}{
+				"severity": "warning", "SYSLOG_IDENTIFIER": "kernel",
+			},
+		},
+		{
+			name:       "no priority",
+			line:       `{"__REALTIME_TIMESTAMP":"1771324245003000","MESSAGE":"hello"}`,
+			wantMsg:    "hello",
+			wantFields: map[string]interface{}{},
+		},
+	}
+
+	parser := NewJournaldParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !parser.CanParse(tt.line) {
+				t.Fatal("CanParse() = false")
+			}
+			entry, err := parser.Parse(tt.line)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !entry.Timestamp.Equal(ts) {
+				t.Errorf("Timestamp = %v, want %v", entry.Timestamp, ts)
+			}
+			if entry.Level != tt.wantLevel || entry.Message != tt.wantMsg {
+				t.Errorf("Level, Message = %q, %q; want %q, %q", entry.Level, entry.Message, tt.wantLevel, tt.wantMsg)
+			}
+			if !reflect.DeepEqual(entry.Fields, tt.wantFields) {
+				t.Errorf("Fields = %#v, want %#v", entry.Fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestJournaldParser_Rejects(t *testing.T) {
+	parser := NewJournaldParser()
+	for _, line := range []string{
+		`{"MESSAGE":"no timestamp"}`,
+		`{"__REALTIME_TIMESTAMP":"yesterday","MESSAGE":"x"}`,
+		"__REALTIME_TIMESTAMP=1771324245003000\nMESSAGE\n\x05\x00\x00\x00\x00\x00\x00\x00hello",
+		`{"__REALTIME_TIMESTAMP":`,
+		"level=info msg=hello",
+	} {
+		if parser.CanParse(line) {
+			t.Errorf("CanParse(%q) = true", line)
+		}
+		if _, err := parser.Parse(line); err == nil {
+			t.Errorf("Parse(%q) error = nil", line)
+		}
+	}
+}
+
+func TestJournaldExportRecords(t *testing.T) {
+	export := "__CURSOR=s=abc\n__REALTIME_TIMESTAMP=1771324245003000\nPRIORITY=3\nMESSAGE=disk full\n\n" +
+		"__CURSOR=s=abd\n__REALTIME_TIMESTAMP=1771324246003000\nMESSAGE=recovered\n"
+
+	m := NewMultiline(JournaldExportContinuation)
+	var records [][]string
+	for _, line := range strings.Split(export, "\n") {
+		if r := m.Add(line); r != nil {
+			records = append(records, r)
+		}
+	}
+	if r := m.Flush(); r != nil {
+		records = append(records, r)
+	}
+	if len(records) != 2 {
+		t.Fatalf("records = %q, want 2", records)
+	}
+
+	d := NewDetector()
+	entry, err := d.ParseLines(records[0], "journald")
+	if err != nil || entry.Message != "disk full" || entry.Level != "ERROR" {
+		t.Fatalf("ParseLines() = %+v, %v", entry, err)
+	}
+	if entry.Raw != strings.Join(records[0], "\n") {
+		t.Errorf("Raw = %q", entry.Raw)
+	}
+}
@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// KlogParser handles the klog/glog header written by Kubernetes system
+// components (kubelet, kube-apiserver, controllers, most operators):
+//
+//	I0102 15:04:05.000000       1 controller.go:123] Starting workers
+//	E0102 15:04:05.123456   12345 reflector.go:42] "Failed to watch" err="connection refused" kind="Pod"
+//
+// The severity letter gives the level (I, W, E, F). The header has no year,
+// so like RFC 3164 syslog the current one is assumed. The thread ID, source
+// file and line are stored as fields. klog v2 structured lines quote the
+// message and follow it with key=value pairs, which become fields too.
+type KlogParser struct{}
+
+// NewKlogParser creates a new klog parser
+func NewKlogParser() *KlogParser {
+	return &KlogParser{}
+}
+
+// klogLine matches "Lmmdd hh:mm:ss.uuuuuu threadid file:line] msg".
+var klogLine = regexp.MustCompile(`^([IWEF])(\d{4} \d{2}:\d{2}:\d{2}(?:\.\d{1,9})?)\s+(\d+) ([^\s:\]]+):(\d+)\] ?(.*)$`)
+
+// klogLevels maps the severity letter to a level.
+var klogLevels = map[string]string{"I": "INFO", "W": "WARN", "E": "ERROR", "F": "FATAL"}
+
+// CanParse checks if the line starts with a klog header
+func (p *KlogParser) CanParse(line string) bool {
+	_, err := p.parse(line)
+	return err == nil
+}
+
+// Parse parses a klog line into a LogEntry
+func (p *KlogParser) Parse(line string) (*storage.LogEntry, error) {
+	entry, err := p.parse(line)
+	if err != nil {
+		return nil, err
+	}
+	entry.ID = generateID()
+	promoteCorrelationIDs(entry)
+	return entry, nil
+}
+
+func (p *KlogParser) parse(line string) (*storage.LogEntry, error) {
+	m := klogLine.FindStringSubmatch(line)
+	if m == nil {
+		return nil, errors.New("klog: line does not start with a klog header")
+	}
+	t, err := time.ParseInLocation("0102 15:04:05.999999999", m[2], location())
+	if err != nil {
+		return nil, errors.New("klog: invalid timestamp")
+	}
+	t = withCurrentYear(t)
+	thread, _ := strconv.ParseInt(m[3], 10, 64)
+	lineNo, _ := strconv.Atoi(m[5])
+
+	fields := map[string]interface{}{
+		"thread_id": thread,
+		"file":      m[4],
+		"line":      lineNo,
+	}
+	msg := m[6]
+	if quoted, err := strconv.QuotedPrefix(msg); err == nil {
+		if unquoted, err := strconv.Unquote(quoted); err == nil {
+			msg = unquoted
+			for k, v := range parseLogfmt(strings.TrimSpace(m[6][len(quoted):])) {
+				if _, taken := fields[k]; !taken {
+					fields[k] = v
+				}
+			}
+		}
+	}
+
+	return &storage.LogEntry{
+		Timestamp: t,
+		Level:     klogLevels[m[1]],
+		Message:   msg,
+		Fields:    fields,
+		Raw:       line,
+	}, nil
+}
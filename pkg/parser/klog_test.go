@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKlogParser_CanParse(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want bool
+	}{
+		{name: "klog", line: "I0102 15:04:05.000000       1 controller.go:123] Starting workers", want: true},
+		{name: "structured", line: `E0102 15:04:05.123456   12345 reflector.go:42] "Failed to watch" err="boom"`, want: true},
+		{name: "glog path", line: "W1231 23:59:59.999999 7 pkg/util/retry.go:9] retrying", want: true},
+		{name: "unknown severity", line: "D0102 15:04:05.000000 1 controller.go:123] debug", want: false},
+		{name: "missing line number", line: "I0102 15:04:05.000000 1 controller.go] message", want: false},
+		{name: "logfmt", line: "level=info msg=hello", want: false},
+	}
+
+	parser := NewKlogParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parser.CanParse(tt.line); got != tt.want {
+				t.Errorf("KlogParser.CanParse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKlogParser_Parse(t *testing.T) {
+	now := time.Date(2026, 1, 2, 16, 0, 0, 0, time.Local)
+	originalTimeNow := timeNow
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = originalTimeNow }()
+
+	tests := []struct {
+		name       string
+		line       string
+		wantTime   time.Time
+		wantLevel  string
+		wantMsg    string
+		wantFields map[string]interface{}
+	}{
+		{
+			name:       "plain message",
+			line:       "I0102 15:04:05.000123       1 controller.go:123] Starting workers",
+			wantTime:   time.Date(2026, 1, 2, 15, 4, 5, 123000, time.Local),
+			wantLevel:  "INFO",
+			wantMsg:    "Starting workers",
+			wantFields: map[string]interface{}{"thread_id": int64(1), "file": "controller.go", "line": 123},
+		},
+		{
+			name:      "structured message",
+			line:      `E0102 15:04:05.000000   12345 reflector.go:42] "Failed to watch \"pods\"" err="connection refused" kind=Pod`,
+			wantTime:  time.Date(2026, 1, 2, 15, 4, 5, 0, time.Local),
+			wantLevel: "ERROR",
+			wantMsg:   `Failed to watch "pods"`,
+			wantFields: map[string]interface{}{
+				"thread_id": int64(12345), "file": "reflector.go", "line": 42,
+				"err": "connection refused", "kind": "Pod",
+			},
+		},
+		{
+			name:       "last year",
+			line:       "F1231 23:59:59.500000 7 main.go:9] fatal",
+			wantTime:   time.Date(2025, 12, 31, 23, 59, 59, 500000000, time.Local),
+			wantLevel:  "FATAL",
+			wantMsg:    "fatal",
+			wantFields: map[string]interface{}{"thread_id": int64(7), "file": "main.go", "line": 9},
+		},
+	}
+
+	parser := NewKlogParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := parser.Parse(tt.line)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !entry.Timestamp.Equal(tt.wantTime) {
+				t.Errorf("Timestamp = %v, want %v", entry.Timestamp, tt.wantTime)
+			}
+			if entry.Level != tt.wantLevel || entry.Message != tt.wantMsg {
+				t.Errorf("Level, Message = %q, %q; want %q, %q", entry.Level, entry.Message, tt.wantLevel, tt.wantMsg)
+			}
+			if !reflect.DeepEqual(entry.Fields, tt.wantFields) {
+				t.Errorf("Fields = %#v, want %#v", entry.Fields, tt.wantFields)
+			}
+			if entry.Raw != tt.line {
+				t.Errorf("Raw = %q, want %q", entry.Raw, tt.line)
+			}
+		})
+	}
+}
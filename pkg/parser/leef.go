@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// LEEFParser handles IBM QRadar Log Event Extended Format records,
+// optionally behind the syslog header of the relay that forwarded them:
+//
+//	LEEF:1.0|Palo Alto Networks|PAN-OS|10.1|TRAFFIC|src=10.0.0.5	dst=203.0.113.9	sev=7	usrName=alice
+//	LEEF:2.0|Vendor|Product|1.0|LoginFailed|^|src=10.0.0.5^sev=5
+//
+// EventID is the message, and the vendor, product, version and event ID
+// are stored as device_vendor, device_product, device_version and
+// event_id next to leef_version. Attributes are separated by tabs, or in
+// LEEF 2.0 by the character (or its hex code, e.g. x5E) after EventID, and
+// stored under their keys. sev (1-10) sets the level and devTime the
+// timestamp.
+type LEEFParser struct{}
+
+// NewLEEFParser creates a new LEEF parser
+func NewLEEFParser() *LEEFParser {
+	return &LEEFParser{}
+}
+
+// leefDelimiter matches LEEF 2.0's delimiter field: one character or its
+// hex code.
+var leefDelimiter = regexp.MustCompile(`^(?:.|0?[xX][0-9A-Fa-f]{2,4})$`)
+
+// CanParse checks if the line holds a LEEF record
+func (p *LEEFParser) CanParse(line string) bool {
+	if !strings.Contains(line, "LEEF:") {
+		return false
+	}
+	_, err := p.parse(line)
+	return err == nil
+}
+
+// Parse parses a LEEF record into a LogEntry
+func (p *LEEFParser) Parse(line string) (*storage.LogEntry, error) {
+	entry, err := p.parse(line)
+	if err != nil {
+		return nil, err
+	}
+	entry.ID = generateID()
+	promoteCorrelationIDs(entry)
+	return entry, nil
+}
+
+func (p *LEEFParser) parse(line string) (*storage.LogEntry, error) {
+	entry := &storage.LogEntry{Fields: make(map[string]interface{}), Raw: line}
+	record, err := securityRecord(entry, line, "LEEF:")
+	if err != nil {
+		return nil, fmt.Errorf("leef: %w", err)
+	}
+
+	header := strings.SplitN(record, "|", 6)
+	if len(header) < 6 {
+		return nil, fmt.Errorf("leef: header has %d fields, want 5", len(header)-1)
+	}
+	version, attrs := header[0], header[5]
+	if version != "1.0" && version != "2.0" {
+		return nil, fmt.Errorf("leef: unsupported version %q", version)
+	}
+	delim := "\t"
+	if version == "2.0" {
+		if d, rest, ok := strings.Cut(attrs, "|"); ok && leefDelimiter.MatchString(d) {
+			if delim, err = leefDelimiterChar(d); err != nil {
+				return nil, err
+			}
+			attrs = rest
+		}
+	}
+
+	for _, attr := range strings.Split(attrs, delim) {
+		if strings.TrimSpace(attr) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(attr, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("leef: attribute %q is not key=value", attr)
+		}
+		entry.Fields[k] = v
+	}
+	for i, name := range []string{"leef_version", "device_vendor", "device_product", "device_version", "event_id"} {
+		entry.Fields[name] = header[i]
+	}
+	entry.Message = header[4]
+	if sev, ok := entry.Fields["sev"].(string); ok {
+		entry.Level = securitySeverityLevel(sev)
+	}
+	if devTime, ok := entry.Fields["devTime"].(string); ok {
+		if t, ok := parseSecurityTime(devTime); ok {
+			entry.Timestamp = t
+		}
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = timeNow()
+	}
+	return entry, nil
+}
+
+// leefDelimiterChar decodes LEEF 2.0's delimiter field.
+func leefDelimiterChar(d string) (string, error) {
+	if utf8.RuneCountInString(d) == 1 {
+		return d, nil
+	}
+	code, err := strconv.ParseUint(d[strings.IndexAny(d, "xX")+1:], 16, 32)
+	if err != nil || code == 0 {
+		return "", errors.New("leef: invalid delimiter " + d)
+	}
+	return string(rune(code)), nil
+}
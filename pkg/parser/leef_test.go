@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLEEFParser_Parse(t *testing.T) {
+	now := time.Date(2026, 2, 17, 12, 0, 0, 0, time.Local)
+	originalTimeNow := timeNow
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = originalTimeNow }()
+
+	tests := []struct {
+		name       string
+		line       string
+		wantTime   time.Time
+		wantLevel  string
+		wantMsg    string
+		wantFields map[string]interface{}
+	}{
+		{
+			name:      "1.0 with tabs",
+			line:      "LEEF:1.0|Palo Alto Networks|PAN-OS|10.1|TRAFFIC|src=10.0.0.5\tdst=203.0.113.9\tsev=7\tusrName=alice smith",
+			wantTime:  now,
+			wantLevel: "ERROR",
+			wantMsg:   "TRAFFIC",
+			wantFields: map[string]interface{}{
+				"leef_version": "1.0", "device_vendor": "Palo Alto Networks", "device_product": "PAN-OS", "device_version": "10.1",
+				"event_id": "TRAFFIC", "src": "10.0.0.5", "dst": "203.0.113.9", "sev": "7", "usrName": "alice smith",
+			},
+		},
+		{
+			name:      "2.0 with delimiter and syslog header",
+			line:      "<13>Feb 17 10:30:45 qradar LEEF:2.0|Vendor|Product|1.0|LoginFailed|^|src=10.0.0.5^sev=5^devTime=Feb 17 2026 10:30:40",
+			wantTime:  time.Date(2026, 2, 17, 10, 30, 40, 0, time.Local),
+			wantLevel: "WARN",
+			wantMsg:   "LoginFailed",
+			wantFields: map[string]interface{}{
+				"facility": "user", "host": "qradar",
+				"leef_version": "2.0", "device_vendor": "Vendor", "device_product": "Product", "device_version": "1.0",
+				"event_id": "LoginFailed", "src": "10.0.0.5", "sev": "5", "devTime": "Feb 17 2026 10:30:40",
+			},
+		},
+		{
+			name:     "2.0 with hex delimiter",
+			line:     "LEEF:2.0|V|P|1|Event|x7C|a=1|b=2",
+			wantTime: now,
+			wantMsg:  "Event",
+			wantFields: map[string]interface{}{
+				"leef_version": "2.0", "device_vendor": "V", "device_product": "P", "device_version": "1",
+				"event_id": "Event", "a": "1", "b": "2",
+			},
+		},
+		{
+			name:     "2.0 without delimiter",
+			line:     "LEEF:2.0|V|P|1|Event|a=1\tdevTime=1771324245003",
+			wantTime: time.UnixMilli(1771324245003),
+			wantMsg:  "Event",
+			wantFields: map[string]interface{}{
+				"leef_version": "2.0", "device_vendor": "V", "device_product": "P", "device_version": "1",
+				"event_id": "Event", "a": "1", "devTime": "1771324245003",
+			},
+		},
+	}
+
+	parser := NewLEEFParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !parser.CanParse(tt.line) {
+				t.Fatal("CanParse() = false")
+			}
+			entry, err := parser.Parse(tt.line)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !entry.Timestamp.Equal(tt.wantTime) {
+				t.Errorf("Timestamp = %v, want %v", entry.Timestamp, tt.wantTime)
+			}
+			if entry.Level != tt.wantLevel || entry.Message != tt.wantMsg {
+				t.Errorf("Level, Message = %q, %q; want %q, %q", entry.Level, entry.Message, tt.wantLevel, tt.wantMsg)
+			}
+			if !reflect.DeepEqual(entry.Fields, tt.wantFields) {
+				t.Errorf("Fields = %#v, want %#v", entry.Fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestLEEFParser_Rejects(t *testing.T) {
+	parser := NewLEEFParser()
+	for _, line := range []string{
+		"LEEF:1.0|V|P|1",
+		"LEEF:3.0|V|P|1|Event|a=1",
+		"LEEF:1.0|V|P|1|Event|a=1\tjunk",
+		"LEEF:2.0|V|P|1|Event|x00|a=1",
+		"CEF:0|V|P|1|x|Name|5|src=1",
+	} {
+		if parser.CanParse(line) {
+			t.Errorf("CanParse(%q) = true", line)
+		}
+		if _, err := parser.Parse(line); err == nil {
+			t.Errorf("Parse(%q) error = nil", line)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultNumericLevels are the pino and bunyan level numbers.
+var DefaultNumericLevels = map[int]string{
+	10: "TRACE",
+	20: "DEBUG",
+	30: "INFO",
+	40: "WARN",
+	50: "ERROR",
+	60: "FATAL",
+}
+
+// numericLevel is one step of the numeric level table.
+type numericLevel struct {
+	min   int
+	level string
+}
+
+// numericLevels is the table numeric levels are read with, ascending; nil
+// reads them with DefaultNumericLevels.
+var numericLevels atomic.Pointer[[]numericLevel]
+
+var defaultNumericLevels = newNumericLevels(DefaultNumericLevels)
+
+// SetNumericLevels replaces the table every parser reads numeric levels
+// with ([parsing.numeric_levels]). nil restores DefaultNumericLevels.
+func SetNumericLevels(levels map[int]string) {
+	if levels == nil {
+		numericLevels.Store(nil)
+		return
+	}
+	table := newNumericLevels(levels)
+	numericLevels.Store(&table)
+}
+
+func newNumericLevels(levels map[int]string) []numericLevel {
+	table := make([]numericLevel, 0, len(levels))
+	for _, n := range slices.Sorted(maps.Keys(levels)) {
+		table = append(table, numericLevel{min: n, level: levelName(levels[n])})
+	}
+	return table
+}
+
+// ParseNumericLevels reads a [parsing.numeric_levels] table, whose keys are
+// level numbers and values level names.
+func ParseNumericLevels(levels map[string]string) (map[int]string, error) {
+	if len(levels) == 0 {
+		return nil, nil
+	}
+	parsed := make(map[int]string, len(levels))
+	for k, v := range levels {
+		n, err := strconv.Atoi(strings.TrimSpace(k))
+		if err != nil {
+			return nil, fmt.Errorf("level number %q is not an integer", k)
+		}
+		if strings.TrimSpace(v) == "" {
+			return nil, fmt.Errorf("level number %d has no level name", n)
+		}
+		parsed[n] = v
+	}
+	return parsed, nil
+}
+
+// levelFromNumber returns the level of a numeric level: the level of the
+// highest number in the table not above n, so a custom pino level of 35
+// reads as INFO. Numbers below the whole table are not levels.
+func levelFromNumber(n float64) (string, bool) {
+	table := defaultNumericLevels
+	if t := numericLevels.Load(); t != nil {
+		table = *t
+	}
+	if math.IsNaN(n) {
+		return "", false
+	}
+	level, ok := "", false
+	for _, step := range table {
+		if float64(step.min) > n {
+			break
+		}
+		level, ok = step.level, true
+	}
+	return level, ok
+}
+
+// levelValue reads a decoded JSON level: a name, or a number or numeric
+// string read with the numeric level table.
+func levelValue(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			return levelFromNumber(float64(n))
+		}
+		return strings.ToUpper(v), true
+	case float64:
+		return levelFromNumber(v)
+	}
+	return "", false
+}
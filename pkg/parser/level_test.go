@@ -0,0 +1,63 @@
+package parser
+
+import "testing"
+
+func TestJSONParser_NumericLevels(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		wantLevel string
+		wantField bool // level is left in Fields
+	}{
+		{name: "pino info", line: `{"level":30,"time":1771324245003,"msg":"listening"}`, wantLevel: "INFO"},
+		{name: "pino fatal", line: `{"level":60,"msg":"out of memory"}`, wantLevel: "FATAL"},
+		{name: "bunyan trace", line: `{"v":0,"level":10,"msg":"enter"}`, wantLevel: "TRACE"},
+		{name: "custom level between", line: `{"level":45,"msg":"x"}`, wantLevel: "WARN"},
+		{name: "above the table", line: `{"level":100,"msg":"x"}`, wantLevel: "FATAL"},
+		{name: "numeric string", line: `{"level":"40","msg":"x"}`, wantLevel: "WARN"},
+		{name: "below the table", line: `{"level":5,"msg":"x"}`, wantField: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := NewJSONParser().Parse(tt.line)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if entry.Level != tt.wantLevel {
+				t.Errorf("Level = %q, want %q", entry.Level, tt.wantLevel)
+			}
+			if _, ok := entry.Fields["level"]; ok != tt.wantField {
+				t.Errorf("Fields = %v, level kept = %v, want %v", entry.Fields, ok, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestSetNumericLevels(t *testing.T) {
+	defer SetNumericLevels(nil)
+
+	levels, err := ParseNumericLevels(map[string]string{"1": "debug", "2": "info", "3": "warning", "4": "err"})
+	if err != nil {
+		t.Fatalf("ParseNumericLevels() error = %v", err)
+	}
+	SetNumericLevels(levels)
+	for line, want := range map[string]string{
+		`{"level":1,"msg":"x"}`:  "DEBUG",
+		`{"level":3,"msg":"x"}`:  "WARN",
+		`{"level":30,"msg":"x"}`: "ERROR",
+	} {
+		entry, err := NewJSONParser().Parse(line)
+		if err != nil || entry.Level != want {
+			t.Errorf("Parse(%s) level = %q, %v, want %q", line, entry.Level, err, want)
+		}
+	}
+	if entry, _ := NewLogfmtParser().Parse(`level=2 msg=hi`); entry.Level != "INFO" {
+		t.Errorf("logfmt level = %q, want INFO", entry.Level)
+	}
+
+	for _, bad := range []map[string]string{{"high": "ERROR"}, {"50": " "}} {
+		if _, err := ParseNumericLevels(bad); err == nil {
+			t.Errorf("ParseNumericLevels(%v) succeeded", bad)
+		}
+	}
+}
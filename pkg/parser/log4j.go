@@ -0,0 +1,455 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// DefaultLog4jLayout is the layout of the built-in log4j format, the
+// PatternLayout most JVM applications (log4j, log4j2, logback) ship with.
+const DefaultLog4jLayout = "%d [%t] %-5p %c - %m%n"
+
+// Log4jParser handles lines written by a log4j/logback PatternLayout:
+//
+//	2026-01-02 15:04:05,123 [main] INFO  com.example.App - Started in 2.1s
+//
+// The layout is compiled into a regular expression. %d fills the timestamp,
+// %p the level and %m the message; the thread (%t), logger (%c), class
+// (%C), method (%M), file (%F), line (%L), elapsed milliseconds (%r), NDC
+// (%x) and MDC values (%X{key}) become fields.
+type Log4jParser struct {
+	name string
+	re   *regexp.Regexp
+	// groups lists what each capture group of re fills, in order
+	groups []log4jConversion
+	// timeLayout is the Go layout of %d; empty reads ISO 8601 with a
+	// comma or dot before the fraction
+	timeLayout string
+	timeZone   *time.Location
+	// noYear and noDate are set when timeLayout lacks them; the current
+	// year or day is assumed
+	noYear, noDate bool
+}
+
+// log4jConversion is what a conversion specifier matches and where the
+// match is stored.
+type log4jConversion struct {
+	field   string
+	pattern string
+	number  bool
+}
+
+// log4jLevelPattern matches the levels log4j and logback print, so that in
+// auto mode other bracketed formats aren't mistaken for a layout.
+const log4jLevelPattern = `(?i:TRACE|DEBUG|INFO|WARN(?:ING)?|ERROR|FATAL)`
+
+// log4jConversions maps the conversion names, short and long, to what they
+// match. %d and %X are handled separately since their option changes the
+// match.
+var log4jConversions = map[string]log4jConversion{
+	"t":        {field: "thread", pattern: `.+?`},
+	"thread":   {field: "thread", pattern: `.+?`},
+	"p":        {field: "level", pattern: log4jLevelPattern},
+	"le":       {field: "level", pattern: log4jLevelPattern},
+	"level":    {field: "level", pattern: log4jLevelPattern},
+	"c":        {field: "logger", pattern: `\S+`},
+	"lo":       {field: "logger", pattern: `\S+`},
+	"logger":   {field: "logger", pattern: `\S+`},
+	"C":        {field: "class", pattern: `\S+`},
+	"class":    {field: "class", pattern: `\S+`},
+	"M":        {field: "method", pattern: `\S+`},
+	"method":   {field: "method", pattern: `\S+`},
+	"F":        {field: "file", pattern: `\S+`},
+	"file":     {field: "file", pattern: `\S+`},
+	"L":        {field: "line", pattern: `\d+`, number: true},
+	"line":     {field: "line", pattern: `\d+`, number: true},
+	"r":        {field: "elapsed_ms", pattern: `\d+`, number: true},
+	"relative": {field: "elapsed_ms", pattern: `\d+`, number: true},
+	"x":        {field: "ndc", pattern: `.*?`},
+	"NDC":      {field: "ndc", pattern: `.*?`},
+	"m":        {field: "message", pattern: `.*?`},
+	"msg":      {field: "message", pattern: `.*?`},
+	"message":  {field: "message", pattern: `.*?`},
+}
+
+// log4jNamedDates maps the named %d formats to their SimpleDateFormat
+// pattern.
+var log4jNamedDates = map[string]string{
+	"ABSOLUTE": "HH:mm:ss,SSS",
+	"DATE":     "dd MMM yyyy HH:mm:ss,SSS",
+}
+
+// log4jISO8601 matches %d without a pattern, which log4j prints as
+// "yyyy-MM-dd HH:mm:ss,SSS" and logback with a dot.
+const log4jISO8601 = `\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}(?:[.,]\d{1,9})?`
+
+// NewLog4jParser creates a parser for lines written with the PatternLayout
+// layout (e.g. DefaultLog4jLayout), checking that its conversions are known
+func NewLog4jParser(name, layout string) (*Log4jParser, error) {
+	p := &Log4jParser{name: name}
+	var re strings.Builder
+	re.WriteString(`^`)
+	for i := 0; i < len(layout); {
+		c := layout[i]
+		switch {
+		case c != '%':
+			j := i
+			for j < len(layout) && layout[j] != '%' {
+				j++
+			}
+			re.WriteString(log4jLiteral(layout[i:j]))
+			i = j
+			continue
+		case strings.HasPrefix(layout[i:], "%%"):
+			re.WriteString(`%`)
+			i += 2
+			continue
+		}
+
+		// %[-][min][.max]name[{option}]
+		i++
+		leftAlign := i < len(layout) && layout[i] == '-'
+		if leftAlign {
+			i++
+		}
+		j := i
+		for j < len(layout) && (layout[j] >= '0' && layout[j] <= '9' || layout[j] == '.') {
+			j++
+		}
+		padded := j > i && layout[i] != '.'
+		i = j
+		for j < len(layout) && (layout[j] >= 'a' && layout[j] <= 'z' || layout[j] >= 'A' && layout[j] <= 'Z') {
+			j++
+		}
+		conv := layout[i:j]
+		i = j
+		var options []string
+		for i < len(layout) && layout[i] == '{' {
+			end := strings.IndexByte(layout[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("format %s: unterminated option of %%%s", name, conv)
+			}
+			options = append(options, layout[i+1:i+end])
+			i += end + 1
+		}
+
+		var group log4jConversion
+		switch conv {
+		case "":
+			return nil, fmt.Errorf("format %s: missing conversion name after %%", name)
+		case "n":
+			continue
+		case "d", "date":
+			if p.hasGroup("timestamp") {
+				return nil, fmt.Errorf("format %s: layout has more than one %%d", name)
+			}
+			pattern, err := p.setTimeFormat(options)
+			if err != nil {
+				return nil, fmt.Errorf("format %s: %w", name, err)
+			}
+			group = log4jConversion{field: "timestamp", pattern: pattern}
+		case "X", "mdc", "MDC":
+			if len(options) == 0 || options[0] == "" {
+				group = log4jConversion{field: "mdc", pattern: `.*?`}
+			} else {
+				group = log4jConversion{field: options[0], pattern: `.*?`}
+			}
+		default:
+			var ok bool
+			if group, ok = log4jConversions[conv]; !ok {
+				return nil, fmt.Errorf("format %s: unsupported conversion %%%s", name, conv)
+			}
+		}
+
+		if padded && !leftAlign {
+			re.WriteString(` *`)
+		}
+		re.WriteString(`(` + group.pattern + `)`)
+		if padded && leftAlign {
+			re.WriteString(` *`)
+		}
+		p.groups = append(p.groups, group)
+	}
+	re.WriteString(`\s*$`)
+
+	if len(p.groups) == 0 {
+		return nil, fmt.Errorf("format %s: layout has no conversions", name)
+	}
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return nil, fmt.Errorf("format %s: invalid layout: %w", name, err)
+	}
+	p.re = compiled
+	return p, nil
+}
+
+// newDefaultLog4jParser creates the parser of the built-in log4j format.
+func newDefaultLog4jParser() *Log4jParser {
+	p, err := NewLog4jParser("log4j", DefaultLog4jLayout)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// log4jLiteral quotes the literal text between conversions; any run of
+// whitespace matches one or more spaces or tabs.
+func log4jLiteral(s string) string {
+	var b strings.Builder
+	inSpace := false
+	for _, r := range s {
+		if r == ' ' || r == '\t' {
+			if !inSpace {
+				b.WriteString(`\s+`)
+			}
+			inSpace = true
+			continue
+		}
+		inSpace = false
+		b.WriteString(regexp.QuoteMeta(string(r)))
+	}
+	return b.String()
+}
+
+// hasGroup reports whether a conversion already fills field.
+func (p *Log4jParser) hasGroup(field string) bool {
+	for _, g := range p.groups {
+		if g.field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// setTimeFormat reads the options of %d: a SimpleDateFormat pattern or
+// named format, then a time zone. It returns the pattern %d matches.
+func (p *Log4jParser) setTimeFormat(options []string) (string, error) {
+	if len(options) > 1 && options[1] != "" {
+		loc, err := time.LoadLocation(options[1])
+		if err != nil {
+			return "", fmt.Errorf("invalid %%d time zone %q", options[1])
+		}
+		p.timeZone = loc
+	}
+	format := ""
+	if len(options) > 0 {
+		format = options[0]
+	}
+	if named, ok := log4jNamedDates[format]; ok {
+		format = named
+	}
+	if format == "" || format == "ISO8601" || format == "DEFAULT" {
+		return log4jISO8601, nil
+	}
+	layout, pattern, err := javaDateLayout(format)
+	if err != nil {
+		return "", err
+	}
+	p.timeLayout = layout
+	p.noYear = !strings.ContainsRune(format, 'y')
+	p.noDate = p.noYear && !strings.ContainsRune(format, 'd')
+	return pattern, nil
+}
+
+// javaDateLayout converts a SimpleDateFormat pattern into a Go time layout
+// and a regular expression matching the times it prints.
+func javaDateLayout(format string) (layout, pattern string, err error) {
+	var l, re strings.Builder
+	for i := 0; i < len(format); {
+		c := format[i]
+		j := i
+		for j < len(format) && format[j] == c {
+			j++
+		}
+		n := j - i
+		switch {
+		case c == '\'':
+			// 'quoted text', with '' as a literal quote
+			end := strings.IndexByte(format[i+1:], '\'')
+			if end < 0 {
+				return "", "", fmt.Errorf("unterminated quote in %%d pattern %q", format)
+			}
+			text := format[i+1 : i+1+end]
+			if end == 0 {
+				text = "'"
+			}
+			l.WriteString(text)
+			re.WriteString(regexp.QuoteMeta(text))
+			i += end + 2
+			continue
+		case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			goLayout, goPattern, ok := javaDateField(c, n)
+			if !ok {
+				return "", "", fmt.Errorf("unsupported %%d pattern letter %q in %q", strings.Repeat(string(c), n), format)
+			}
+			if c == 'S' {
+				if prev := l.String(); !strings.HasSuffix(prev, ".") && !strings.HasSuffix(prev, ",") {
+					return "", "", fmt.Errorf("%%d pattern %q: milliseconds must follow a dot or comma", format)
+				}
+			}
+			l.WriteString(goLayout)
+			re.WriteString(goPattern)
+		default:
+			l.WriteString(format[i:j])
+			re.WriteString(regexp.QuoteMeta(format[i:j]))
+		}
+		i = j
+	}
+	return l.String(), re.String(), nil
+}
+
+// javaDateField maps a run of n SimpleDateFormat letters c to its Go
+// layout element and pattern.
+func javaDateField(c byte, n int) (layout, pattern string, ok bool) {
+	switch c {
+	case 'y':
+		if n == 2 {
+			return "06", `\d{2}`, true
+		}
+		return "2006", `\d{4}`, true
+	case 'M':
+		switch n {
+		case 1:
+			return "1", `\d{1,2}`, true
+		case 2:
+			return "01", `\d{2}`, true
+		case 3:
+			return "Jan", `[A-Za-z]{3}`, true
+		}
+		return "January", `[A-Za-z]+`, true
+	case 'd':
+		if n == 1 {
+			return "2", `\d{1,2}`, true
+		}
+		return "02", `\d{2}`, true
+	case 'H':
+		return "15", `\d{1,2}`, true
+	case 'h':
+		if n == 1 {
+			return "3", `\d{1,2}`, true
+		}
+		return "03", `\d{2}`, true
+	case 'm':
+		if n == 1 {
+			return "4", `\d{1,2}`, true
+		}
+		return "04", `\d{2}`, true
+	case 's':
+		if n == 1 {
+			return "5", `\d{1,2}`, true
+		}
+		return "05", `\d{2}`, true
+	case 'S':
+		return strings.Repeat("0", n), `\d{` + strconv.Itoa(n) + `}`, true
+	case 'a':
+		return "PM", `[AaPp][Mm]`, true
+	case 'E':
+		if n <= 3 {
+			return "Mon", `[A-Za-z]{3}`, true
+		}
+		return "Monday", `[A-Za-z]+`, true
+	case 'Z':
+		return "-0700", `[+-]\d{4}`, true
+	case 'X':
+		switch n {
+		case 1:
+			return "Z07", `(?:Z|[+-]\d{2})`, true
+		case 2:
+			return "Z0700", `(?:Z|[+-]\d{4})`, true
+		}
+		return "Z07:00", `(?:Z|[+-]\d{2}:\d{2})`, true
+	case 'z':
+		return "MST", `[A-Za-z]+`, true
+	}
+	return "", "", false
+}
+
+// Name returns the format name
+func (p *Log4jParser) Name() string {
+	return p.name
+}
+
+// CanParse checks if the line matches the layout
+func (p *Log4jParser) CanParse(line string) bool {
+	return p.re.MatchString(line)
+}
+
+// Parse parses a line written with the layout into a LogEntry
+func (p *Log4jParser) Parse(line string) (*storage.LogEntry, error) {
+	m := p.re.FindStringSubmatch(line)
+	if m == nil {
+		return nil, errors.New(p.name + ": line does not match the layout")
+	}
+
+	entry := &storage.LogEntry{
+		ID:     generateID(),
+		Fields: make(map[string]interface{}),
+		Raw:    line,
+	}
+	hasMessage := false
+	for i, group := range p.groups {
+		value := strings.TrimSpace(m[i+1])
+		if value == "" {
+			continue
+		}
+		switch group.field {
+		case "timestamp":
+			entry.Timestamp = p.parseTime(value)
+		case "level":
+			entry.Level = NormalizeLevel(value)
+		case "message":
+			if !hasMessage {
+				entry.Message, hasMessage = value, true
+			}
+		default:
+			if group.number {
+				if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+					entry.Fields[group.field] = n
+					continue
+				}
+			}
+			entry.Fields[group.field] = value
+		}
+	}
+	if !hasMessage {
+		entry.Message = line
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = timeNow()
+	}
+	promoteCorrelationIDs(entry)
+	return entry, nil
+}
+
+// parseTime reads the %d value, returning the zero time when it doesn't
+// match. Formats without a date, such as ABSOLUTE, are given today's, and
+// formats without a year the current one.
+func (p *Log4jParser) parseTime(value string) time.Time {
+	loc := p.timeZone
+	if loc == nil {
+		loc = location()
+	}
+	if p.timeLayout == "" {
+		value = strings.Replace(strings.Replace(value, "T", " ", 1), ",", ".", 1)
+		t, _ := time.ParseInLocation("2006-01-02 15:04:05.999999999", value, loc)
+		return t
+	}
+	t, err := time.ParseInLocation(p.timeLayout, value, loc)
+	if err != nil {
+		return time.Time{}
+	}
+	now := timeNow().In(loc)
+	switch {
+	case p.noDate:
+		t = time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	case p.noYear:
+		t = t.AddDate(now.Year(), 0, 0)
+	}
+	return t
+}
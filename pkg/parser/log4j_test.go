@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLog4jParser_CanParse(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want bool
+	}{
+		{name: "log4j", line: "2026-01-02 15:04:05,123 [main] INFO  com.example.App - Started", want: true},
+		{name: "logback", line: "2026-01-02 15:04:05.123 [http-nio-8080-exec-3] ERROR c.e.PaymentClient - Charge failed", want: true},
+		{name: "without milliseconds", line: "2026-01-02T15:04:05 [worker 1] DEBUG app - tick", want: true},
+		{name: "unknown level", line: "2026-01-02 15:04:05,123 [main] NOTICE app - hello", want: false},
+		{name: "missing logger separator", line: "2026-01-02 15:04:05,123 [main] INFO app hello", want: false},
+		{name: "klog", line: "I0102 15:04:05.000000       1 controller.go:123] Starting workers", want: false},
+		{name: "logfmt", line: "level=info msg=hello", want: false},
+	}
+
+	parser := newDefaultLog4jParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parser.CanParse(tt.line); got != tt.want {
+				t.Errorf("Log4jParser.CanParse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLog4jParser_Parse(t *testing.T) {
+	now := time.Date(2026, 1, 2, 16, 0, 0, 0, time.Local)
+	originalTimeNow := timeNow
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = originalTimeNow }()
+
+	tests := []struct {
+		name       string
+		layout     string
+		line       string
+		wantTime   time.Time
+		wantLevel  string
+		wantMsg    string
+		wantFields map[string]interface{}
+	}{
+		{
+			name:       "default layout",
+			layout:     DefaultLog4jLayout,
+			line:       "2026-01-02 15:04:05,123 [main] INFO  com.example.App - Started in 2.1s",
+			wantTime:   time.Date(2026, 1, 2, 15, 4, 5, 123000000, time.Local),
+			wantLevel:  "INFO",
+			wantMsg:    "Started in 2.1s",
+			wantFields: map[string]interface{}{"thread": "main", "logger": "com.example.App"},
+		},
+		{
+			name:       "logback layout with MDC",
+			layout:     "%d{yyyy-MM-dd HH:mm:ss.SSS} [%thread] %-5level %logger{36} %X{requestId} - %msg%n",
+			line:       "2026-01-02 15:04:05.120 [exec-3] WARN  c.e.Client req-42 - Retrying - attempt 2",
+			wantTime:   time.Date(2026, 1, 2, 15, 4, 5, 120000000, time.Local),
+			wantLevel:  "WARN",
+			wantMsg:    "Retrying - attempt 2",
+			wantFields: map[string]interface{}{"thread": "exec-3", "logger": "c.e.Client", "request_id": "req-42"},
+		},
+		{
+			name:       "location and right-aligned level",
+			layout:     "%d{ABSOLUTE} %5p %C.%M(%F:%L) %r: %m",
+			line:       "15:04:05,007  WARN com.example.Db.connect(Db.java:88) 1532: slow",
+			wantTime:   time.Date(2026, 1, 2, 15, 4, 5, 7000000, time.Local),
+			wantLevel:  "WARN",
+			wantMsg:    "slow",
+			wantFields: map[string]interface{}{"class": "com.example.Db", "method": "connect", "file": "Db.java", "line": int64(88), "elapsed_ms": int64(1532)},
+		},
+		{
+			name:       "time zone",
+			layout:     "%d{yyyy-MM-dd'T'HH:mm:ss}{UTC} %p %m",
+			line:       "2026-01-02T15:04:05 error disk full",
+			wantTime:   time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC),
+			wantLevel:  "ERROR",
+			wantMsg:    "disk full",
+			wantFields: map[string]interface{}{},
+		},
+		{
+			name:       "no timestamp",
+			layout:     "[%t] %p %c - %m",
+			line:       "[main] DEBUG app - tick",
+			wantTime:   now,
+			wantLevel:  "DEBUG",
+			wantMsg:    "tick",
+			wantFields: map[string]interface{}{"thread": "main", "logger": "app"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser, err := NewLog4jParser("test", tt.layout)
+			if err != nil {
+				t.Fatalf("NewLog4jParser() error = %v", err)
+			}
+			entry, err := parser.Parse(tt.line)
+			if err != nil {
+				t.Fatalf("Log4jParser.Parse() error = %v", err)
+			}
+			if !entry.Timestamp.Equal(tt.wantTime) {
+				t.Errorf("Timestamp = %v, want %v", entry.Timestamp, tt.wantTime)
+			}
+			if entry.Level != tt.wantLevel {
+				t.Errorf("Level = %q, want %q", entry.Level, tt.wantLevel)
+			}
+			if entry.Message != tt.wantMsg {
+				t.Errorf("Message = %q, want %q", entry.Message, tt.wantMsg)
+			}
+			if !reflect.DeepEqual(entry.Fields, tt.wantFields) {
+				t.Errorf("Fields = %v, want %v", entry.Fields, tt.wantFields)
+			}
+			if entry.Raw != tt.line {
+				t.Errorf("Raw = %q, want %q", entry.Raw, tt.line)
+			}
+		})
+	}
+}
+
+func TestNewLog4jParser_InvalidLayouts(t *testing.T) {
+	tests := []struct {
+		name   string
+		layout string
+	}{
+		{name: "unknown conversion", layout: "%d %nope %m"},
+		{name: "missing conversion", layout: "%d % %m"},
+		{name: "unterminated option", layout: "%d{yyyy %m"},
+		{name: "unsupported date letter", layout: "%d{yyyy-ww} %m"},
+		{name: "milliseconds without separator", layout: "%d{HHmmssSSS} %m"},
+		{name: "unknown time zone", layout: "%d{HH:mm:ss}{Nowhere/City} %m"},
+		{name: "two dates", layout: "%d %d %m"},
+		{name: "no conversions", layout: "plain text%n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewLog4jParser("test", tt.layout); err == nil {
+				t.Errorf("NewLog4jParser(%q) error = nil, want error", tt.layout)
+			}
+		})
+	}
+}
+
+func TestDetector_CustomLayout(t *testing.T) {
+	d, err := NewDetectorWithCustom([]CustomFormat{{Name: "billing", Layout: "%d [%t] %p %X{account} - %m"}})
+	if err != nil {
+		t.Fatalf("NewDetectorWithCustom() error = %v", err)
+	}
+	entry, err := d.ParseWithFormat("2026-01-02 15:04:05.000 [main] ERROR acct-7 - Charge failed", "billing")
+	if err != nil {
+		t.Fatalf("ParseWithFormat() error = %v", err)
+	}
+	if entry.Level != "ERROR" || entry.Message != "Charge failed" || entry.Fields["account"] != "acct-7" {
+		t.Errorf("entry = %+v", entry)
+	}
+
+	if _, err := NewDetectorWithCustom([]CustomFormat{{Name: "x", Layout: "%m", Pattern: "(?P<message>.*)"}}); err == nil {
+		t.Error("NewDetectorWithCustom() with layout and pattern error = nil, want error")
+	}
+}
@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// MaxMultilineLines caps the lines joined into one record, so a stream of
+// continuation lines can't grow a record without bound.
+const MaxMultilineLines = 1000
+
+// AutoContinuation matches the continuation lines auto mode joins onto the
+// entry before them when no pattern is configured: indented lines such as
+// stack frames, and the "at " and "Caused by:" lines of Java traces. An
+// indented JSON object is an entry of its own.
+var AutoContinuation = regexp.MustCompile(`^(\s+[^\s{]|at |Caused by:)`)
+
+// Multiline joins continuation lines, such as the frames of a Java, Go or
+// Python stack trace, onto the line that starts their entry. A line matching
+// the continuation pattern belongs to the record before it; any other line
+// starts a new record. Multiline is not safe for concurrent use.
+type Multiline struct {
+	continuation *regexp.Regexp
+	lines        []string
+}
+
+// NewMultiline creates an assembler joining lines that match continuation.
+// A nil continuation passes every line through as its own record.
+func NewMultiline(continuation *regexp.Regexp) *Multiline {
+	return &Multiline{continuation: continuation}
+}
+
+// Continuation returns the pattern the assembler was created with.
+func (m *Multiline) Continuation() *regexp.Regexp {
+	return m.continuation
+}
+
+// Add feeds one line and returns the record it completed, or nil while the
+// record is still open. Empty lines end the open record and are dropped.
+func (m *Multiline) Add(line string) []string {
+	if m.continuation == nil {
+		if line == "" {
+			return nil
+		}
+		return []string{line}
+	}
+	if line != "" && len(m.lines) > 0 && len(m.lines) < MaxMultilineLines && m.continuation.MatchString(line) {
+		m.lines = append(m.lines, line)
+		return nil
+	}
+	record := m.Flush()
+	if line != "" {
+		m.lines = []string{line}
+	}
+	return record
+}
+
+// Flush returns the open record, or nil if there is none, and resets the
+// assembler. Call it when no more lines are expected soon.
+func (m *Multiline) Flush() []string {
+	record := m.lines
+	m.lines = nil
+	return record
+}
+
+// Pending reports whether a record is open.
+func (m *Multiline) Pending() bool {
+	return len(m.lines) > 0
+}
+
+// A recordParser reads a whole multiline record, joined with "\n", rather
+// than its first line: the format itself spans lines (journald export).
+type recordParser interface {
+	Parser
+	parsesRecords()
+}
+
+// ParseLines parses a record of one or more lines: the first line with
+// format, as ParseWithFormat would, and the rest appended to the message and
+// the raw line. Formats whose entries span lines get the whole record.
+func (d *Detector) ParseLines(lines []string, format string) (*storage.LogEntry, error) {
+	return d.parseLines(lines, format, nil)
+}
+
+// ParseLinesInfo is ParseLines, also describing how the record was parsed.
+func (d *Detector) ParseLinesInfo(lines []string, format string) (*storage.LogEntry, ParseInfo, error) {
+	var info ParseInfo
+	entry, err := d.parseLines(lines, format, &info)
+	return entry, info, err
+}
+
+// parseLines is ParseLines, filling info when it is not nil.
+func (d *Detector) parseLines(lines []string, format string, info *ParseInfo) (*storage.LogEntry, error) {
+	if p, ok := d.parser(format); ok && len(lines) > 1 {
+		if _, ok := p.(recordParser); ok {
+			return d.parseWithFormat(strings.Join(lines, "\n"), format, info)
+		}
+	}
+	entry, err := d.parseWithFormat(lines[0], format, info)
+	if err != nil || len(lines) == 1 {
+		return entry, err
+	}
+	rest := strings.Join(lines[1:], "\n")
+	if entry.Message == "" {
+		entry.Message = rest
+	} else {
+		entry.Message += "\n" + rest
+	}
+	entry.Raw += "\n" + rest
+	return entry, nil
+}
@@ -0,0 +1,154 @@
+package parser
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestMultiline(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		lines   []string
+		want    [][]string
+	}{
+		{
+			name:    "no pattern passes lines through",
+			pattern: "",
+			lines:   []string{"a", "", "  b"},
+			want:    [][]string{{"a"}, {"  b"}},
+		},
+		{
+			name:    "java stack trace",
+			pattern: `^(\s|Caused by:)`,
+			lines:   []string{"ERROR failed", "\tat A.run", "Caused by: IOException", "\t... 3 more", "INFO next"},
+			want:    [][]string{{"ERROR failed", "\tat A.run", "Caused by: IOException", "\t... 3 more"}, {"INFO next"}},
+		},
+		{
+			name:    "continuation without a record starts one",
+			pattern: `^\s`,
+			lines:   []string{"  orphan", "  frame"},
+			want:    [][]string{{"  orphan", "  frame"}},
+		},
+		{
+			name:    "auto continuation",
+			pattern: AutoContinuation.String(),
+			lines: []string{
+				"2026-03-01 ERROR c.e.Job - failed", "java.lang.IllegalStateException: boom", "at c.e.Job.run(Job.java:12)",
+				"\tat c.e.Main.main(Main.java:5)", "Caused by: java.io.IOException: gone", "    ... 2 more",
+				"panic: oops", "", "goroutine 1 [running]:", "\tmain.main()",
+				"level=info msg=next", `  {"level":"info","msg":"indented json"}`,
+			},
+			want: [][]string{
+				{"2026-03-01 ERROR c.e.Job - failed"},
+				{"java.lang.IllegalStateException: boom", "at c.e.Job.run(Job.java:12)", "\tat c.e.Main.main(Main.java:5)", "Caused by: java.io.IOException: gone", "    ... 2 more"},
+				{"panic: oops"},
+				{"goroutine 1 [running]:", "\tmain.main()"},
+				{"level=info msg=next"},
+				{`  {"level":"info","msg":"indented json"}`},
+			},
+		},
+		{
+			name:    "empty line ends the record",
+			pattern: `^\s`,
+			lines:   []string{"first", "", "  second"},
+			want:    [][]string{{"first"}, {"  second"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var re *regexp.Regexp
+			if tt.pattern != "" {
+				re = regexp.MustCompile(tt.pattern)
+			}
+			m := NewMultiline(re)
+			var got [][]string
+			for _, line := range tt.lines {
+				if record := m.Add(line); record != nil {
+					got = append(got, record)
+				}
+			}
+			if record := m.Flush(); record != nil {
+				got = append(got, record)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("records = %q, want %q", got, tt.want)
+			}
+			if m.Pending() {
+				t.Fatal("Pending() after Flush = true")
+			}
+		})
+	}
+}
+
+func TestMultilineCapsRecordLength(t *testing.T) {
+	m := NewMultiline(regexp.MustCompile(`^\s`))
+	m.Add("start")
+	for i := 1; i < MaxMultilineLines; i++ {
+		if record := m.Add(" frame"); record != nil {
+			t.Fatalf("record closed early at line %d", i+1)
+		}
+	}
+	if record := m.Add(" frame"); len(record) != MaxMultilineLines {
+		t.Fatalf("record at the cap has %d lines, want %d", len(record), MaxMultilineLines)
+	}
+	if !m.Pending() {
+		t.Fatal("the line past the cap should start a new record")
+	}
+}
+
+func TestDetector_ParseLines(t *testing.T) {
+	d := NewDetector()
+	lines := []string{`{"level":"ERROR","message":"boom"}`, "\tat A.run", "\tat B.call"}
+	entry, err := d.ParseLines(lines, "auto")
+	if err != nil {
+		t.Fatalf("ParseLines() error = %v", err)
+	}
+	if entry.Level != "ERROR" {
+		t.Errorf("ParseLines() Level = %q, want ERROR", entry.Level)
+	}
+	if want := "boom\n\tat A.run\n\tat B.call"; entry.Message != want {
+		t.Errorf("ParseLines() Message = %q, want %q", entry.Message, want)
+	}
+	if want := strings.Join(lines, "\n"); entry.Raw != want {
+		t.Errorf("ParseLines() Raw = %q, want %q", entry.Raw, want)
+	}
+
+	if _, err := d.ParseLines([]string{"plain", " frame"}, "json"); err == nil {
+		t.Error("ParseLines() with a first line not matching the format should fail")
+	}
+}
+
+func TestDetector_ParseLinesInfo(t *testing.T) {
+	d, err := NewDetectorWithCustom([]CustomFormat{{Name: "app", Pattern: `^APP (?P<message>.*)$`}})
+	if err != nil {
+		t.Fatalf("NewDetectorWithCustom() error = %v", err)
+	}
+	if err := d.SetAliases(map[string]string{"svc": "service", "msg_text": "message"}); err != nil {
+		t.Fatalf("SetAliases() error = %v", err)
+	}
+	d = d.Stream(nil)
+
+	tests := []struct {
+		lines  []string
+		format string
+		want   ParseInfo
+	}{
+		{lines: []string{`{"level":"info","svc":"api"}`}, format: "auto", want: ParseInfo{Parser: "json", Aliases: []string{"svc -> service"}}},
+		{lines: []string{"level=info msg=hi", " frame"}, format: "auto", want: ParseInfo{Parser: "logfmt"}},
+		{lines: []string{"APP started"}, format: "auto", want: ParseInfo{Parser: "app"}},
+		{lines: []string{"just text"}, format: "auto", want: ParseInfo{Parser: "raw"}},
+		{lines: []string{"level=warn msg=hi svc=api"}, format: "logfmt", want: ParseInfo{Parser: "logfmt", Aliases: []string{"svc -> service"}}},
+	}
+	for _, tt := range tests {
+		_, info, err := d.ParseLinesInfo(tt.lines, tt.format)
+		if err != nil {
+			t.Fatalf("ParseLinesInfo(%q) error = %v", tt.lines, err)
+		}
+		if !reflect.DeepEqual(info, tt.want) {
+			t.Errorf("ParseLinesInfo(%q) info = %+v, want %+v", tt.lines, info, tt.want)
+		}
+	}
+}
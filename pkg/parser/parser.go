@@ -4,8 +4,10 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"maps"
+	"slices"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/mchurichi/peek/pkg/storage"
 )
@@ -43,30 +45,19 @@ func (p *JSONParser) Parse(line string) (*storage.LogEntry, error) {
 		Raw:    line,
 	}
 
-	// Extract timestamp
-	if ts, ok := obj["timestamp"].(string); ok {
-		if t, err := time.Parse(time.RFC3339, ts); err == nil {
-			entry.Timestamp = t
-		}
-		delete(obj, "timestamp")
-	} else if ts, ok := obj["time"].(string); ok {
-		if t, err := time.Parse(time.RFC3339, ts); err == nil {
-			entry.Timestamp = t
-		}
-		delete(obj, "time")
-	}
-
-	// If no timestamp found, use current time
-	if entry.Timestamp.IsZero() {
-		entry.Timestamp = time.Now()
+	// Extract timestamp; without a readable one, use the current time
+	if t, ok := takeTimestamp(obj); ok {
+		entry.Timestamp = t
+	} else {
+		entry.Timestamp = timeNow()
 	}
 
-	// Extract level
-	if level, ok := obj["level"].(string); ok {
-		entry.Level = strings.ToUpper(level)
+	// Extract level, a name or a pino/bunyan number
+	if level, ok := levelValue(obj["level"]); ok {
+		entry.Level = level
 		delete(obj, "level")
-	} else if level, ok := obj["severity"].(string); ok {
-		entry.Level = strings.ToUpper(level)
+	} else if level, ok := levelValue(obj["severity"]); ok {
+		entry.Level = level
 		delete(obj, "severity")
 	}
 
@@ -79,14 +70,51 @@ func (p *JSONParser) Parse(line string) (*storage.LogEntry, error) {
 		delete(obj, "msg")
 	}
 
-	// Remaining fields go to Fields
-	for k, v := range obj {
-		entry.Fields[k] = v
-	}
+	// Remaining fields go to Fields, nested objects as dotted names
+	flattenFields(entry.Fields, obj)
+	promoteCorrelationIDs(entry)
 
 	return entry, nil
 }
 
+// maxFlattenDepth is how many levels of nested JSON objects become dotted
+// field names: {"http":{"status":500}} is stored as http.status. Objects
+// nested deeper are kept whole under the dotted name of their parent.
+const maxFlattenDepth = 5
+
+// flattenFields copies obj into fields with nested objects flattened into
+// dotted names. When keys flatten to the same name, a top-level key such as
+// "http.status" wins over a nested one, and otherwise the first in key order.
+func flattenFields(fields, obj map[string]interface{}) {
+	for _, k := range slices.Sorted(maps.Keys(obj)) {
+		if _, nested := obj[k].(map[string]interface{}); !nested {
+			fields[k] = obj[k]
+		}
+	}
+	for _, k := range slices.Sorted(maps.Keys(obj)) {
+		if nested, ok := obj[k].(map[string]interface{}); ok {
+			flattenInto(fields, k, nested, 1)
+		}
+	}
+}
+
+func flattenInto(fields map[string]interface{}, key string, obj map[string]interface{}, depth int) {
+	if len(obj) == 0 || depth >= maxFlattenDepth {
+		if _, taken := fields[key]; !taken {
+			fields[key] = obj
+		}
+		return
+	}
+	for _, k := range slices.Sorted(maps.Keys(obj)) {
+		name := key + "." + k
+		if nested, ok := obj[k].(map[string]interface{}); ok {
+			flattenInto(fields, name, nested, depth+1)
+		} else if _, taken := fields[name]; !taken {
+			fields[name] = obj[k]
+		}
+	}
+}
+
 // LogfmtParser handles key=value log format (logfmt)
 type LogfmtParser struct{}
 
@@ -114,25 +142,11 @@ func (p *LogfmtParser) Parse(line string) (*storage.LogEntry, error) {
 		Raw:    line,
 	}
 
-	// Extract timestamp
-	if ts, ok := fields["time"]; ok {
-		if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
-			entry.Timestamp = t
-		} else if t, err := time.Parse(time.RFC3339, ts); err == nil {
-			entry.Timestamp = t
-		}
-		delete(fields, "time")
-	}
-	if entry.Timestamp.IsZero() {
-		if ts, ok := fields["timestamp"]; ok {
-			if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
-				entry.Timestamp = t
-			}
-			delete(fields, "timestamp")
-		}
-	}
-	if entry.Timestamp.IsZero() {
-		entry.Timestamp = time.Now()
+	// Extract timestamp; without a readable one, use the current time
+	if t, ok := takeTimestamp(fields); ok {
+		entry.Timestamp = t
+	} else {
+		entry.Timestamp = timeNow()
 	}
 
 	// Extract level
@@ -154,10 +168,66 @@ func (p *LogfmtParser) Parse(line string) (*storage.LogEntry, error) {
 	for k, v := range fields {
 		entry.Fields[k] = v
 	}
+	promoteCorrelationIDs(entry)
 
 	return entry, nil
 }
 
+// Field names promoted to LogEntry.TraceID and SpanID, in order of
+// preference: snake_case, the camelCase used by OpenTelemetry exporters, and
+// the dotted ECS names.
+var (
+	traceIDKeys = []string{"trace_id", "traceId", "traceID", "trace.id"}
+	spanIDKeys  = []string{"span_id", "spanId", "spanID", "span.id"}
+)
+
+// Canonical request and correlation id fields, and the names normalized to
+// them in the same order of preference.
+const (
+	RequestIDField     = "request_id"
+	CorrelationIDField = "correlation_id"
+)
+
+var (
+	requestIDKeys     = []string{"requestId", "requestID", "request.id", "req_id", "reqId", "x_request_id"}
+	correlationIDKeys = []string{"correlationId", "correlationID", "correlation.id"}
+)
+
+// promoteCorrelationIDs moves the first non-empty string trace and span id
+// out of entry.Fields into their dedicated fields, and renames request and
+// correlation ids to RequestIDField and CorrelationIDField.
+func promoteCorrelationIDs(entry *storage.LogEntry) {
+	entry.TraceID = promoteField(entry.Fields, traceIDKeys)
+	entry.SpanID = promoteField(entry.Fields, spanIDKeys)
+	canonicalizeField(entry.Fields, RequestIDField, requestIDKeys)
+	canonicalizeField(entry.Fields, CorrelationIDField, correlationIDKeys)
+}
+
+// canonicalizeField renames the first of keys with a non-empty value to
+// name, unless the entry already has name.
+func canonicalizeField(fields map[string]interface{}, name string, keys []string) {
+	if _, ok := fields[name]; ok {
+		return
+	}
+	for _, k := range keys {
+		if v, ok := fields[k]; ok && v != nil && v != "" {
+			delete(fields, k)
+			fields[name] = v
+			return
+		}
+	}
+}
+
+func promoteField(fields map[string]interface{}, keys []string) string {
+	for _, k := range keys {
+		if v, ok := fields[k].(string); ok && v != "" {
+			delete(fields, k)
+			return v
+		}
+	}
+	return ""
+}
+
 // parseLogfmt parses a logfmt-style line into key-value pairs.
 // Handles: key=value, key="quoted value", key="value with \"escapes\""
 func parseLogfmt(line string) map[string]string {
@@ -231,8 +301,19 @@ func generateID() string {
 	return hex.EncodeToString(b)
 }
 
-// NormalizeLevel normalizes log levels to standard values
+// NormalizeLevel normalizes log levels to standard values. Numbers are
+// read with the numeric level table (SetNumericLevels).
 func NormalizeLevel(level string) string {
+	if n, err := strconv.Atoi(strings.TrimSpace(level)); err == nil {
+		if name, ok := levelFromNumber(float64(n)); ok {
+			return name
+		}
+	}
+	return levelName(level)
+}
+
+// levelName normalizes a level name
+func levelName(level string) string {
 	level = strings.ToUpper(strings.TrimSpace(level))
 	switch level {
 	case "ERROR", "ERR":
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -132,6 +133,52 @@ func TestJSONParser_Parse(t *testing.T) {
 	}
 }
 
+func TestJSONParser_FlattensNestedObjects(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want map[string]interface{}
+	}{
+		{
+			name: "nested objects",
+			line: `{"msg":"done","http":{"status":500,"path":"/x","req":{"headers":{"ua":"curl"}}},"tags":["a"]}`,
+			want: map[string]interface{}{"http.status": float64(500), "http.path": "/x", "http.req.headers.ua": "curl", "tags": []interface{}{"a"}},
+		},
+		{
+			name: "depth limit keeps deeper objects whole",
+			line: `{"msg":"deep","a":{"b":{"c":{"d":{"e":{"f":1}}}}}}`,
+			want: map[string]interface{}{"a.b.c.d.e": map[string]interface{}{"f": float64(1)}},
+		},
+		{
+			name: "empty object",
+			line: `{"msg":"empty","ctx":{}}`,
+			want: map[string]interface{}{"ctx": map[string]interface{}{}},
+		},
+		{
+			name: "top-level dotted key wins",
+			line: `{"msg":"clash","http.status":"literal","http":{"status":500}}`,
+			want: map[string]interface{}{"http.status": "literal"},
+		},
+		{
+			name: "nested trace context is promoted",
+			line: `{"msg":"traced","trace":{"id":"abc"}}`,
+			want: map[string]interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := NewJSONParser().Parse(tt.line)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(entry.Fields, tt.want) {
+				t.Errorf("Fields = %#v, want %#v", entry.Fields, tt.want)
+			}
+		})
+	}
+}
+
 func TestLogfmtParser_CanParse(t *testing.T) {
 	tests := []struct {
 		name string
@@ -277,6 +324,9 @@ func TestNormalizeLevel(t *testing.T) {
 		{"uppercase ERROR", "ERROR", "ERROR"},
 		{"with spaces", "  INFO  ", "INFO"},
 		{"unknown level", "CUSTOM", "CUSTOM"},
+		{"pino number", "50", "ERROR"},
+		{"number between levels", "35", "INFO"},
+		{"number below the table", "3", "3"},
 	}
 
 	for _, tt := range tests {
@@ -400,3 +450,91 @@ func TestLogfmtParser_ParsesTimestampField(t *testing.T) {
 		t.Fatalf("expected parsed timestamp")
 	}
 }
+
+func TestParsersPromoteCorrelationIDs(t *testing.T) {
+	tests := []struct {
+		name       string
+		parser     Parser
+		line       string
+		wantTrace  string
+		wantSpan   string
+		wantFields []string
+		wantValues map[string]interface{}
+	}{
+		{
+			name:      "json snake_case",
+			parser:    NewJSONParser(),
+			line:      `{"msg":"hi","trace_id":"4bf92f3577b34da6","span_id":"00f067aa0ba902b7","user":"ann"}`,
+			wantTrace: "4bf92f3577b34da6", wantSpan: "00f067aa0ba902b7", wantFields: []string{"user"},
+		},
+		{
+			name:      "json camelCase",
+			parser:    NewJSONParser(),
+			line:      `{"msg":"hi","traceId":"abc","spanId":"def"}`,
+			wantTrace: "abc", wantSpan: "def",
+		},
+		{
+			name:      "json dotted ECS names",
+			parser:    NewJSONParser(),
+			line:      `{"msg":"hi","trace.id":"abc"}`,
+			wantTrace: "abc",
+		},
+		{
+			name:       "json non-string id stays a field",
+			parser:     NewJSONParser(),
+			line:       `{"msg":"hi","trace_id":42}`,
+			wantFields: []string{"trace_id"},
+		},
+		{
+			name:      "logfmt",
+			parser:    NewLogfmtParser(),
+			line:      `level=info msg=hi trace_id=abc span_id=def`,
+			wantTrace: "abc", wantSpan: "def",
+		},
+		{
+			name:       "request and correlation ids renamed",
+			parser:     NewJSONParser(),
+			line:       `{"msg":"hi","requestId":"req-1","correlationId":"c-9"}`,
+			wantFields: []string{"request_id", "correlation_id"},
+			wantValues: map[string]interface{}{"request_id": "req-1", "correlation_id": "c-9"},
+		},
+		{
+			name:       "canonical name wins",
+			parser:     NewLogfmtParser(),
+			line:       `msg=hi request_id=a req_id=b`,
+			wantFields: []string{"request_id", "req_id"},
+			wantValues: map[string]interface{}{"request_id": "a", "req_id": "b"},
+		},
+		{
+			name:       "nested request id",
+			parser:     NewJSONParser(),
+			line:       `{"msg":"hi","request":{"id":7}}`,
+			wantFields: []string{"request_id"},
+			wantValues: map[string]interface{}{"request_id": float64(7)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := tt.parser.Parse(tt.line)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if entry.TraceID != tt.wantTrace || entry.SpanID != tt.wantSpan {
+				t.Fatalf("TraceID, SpanID = %q, %q; want %q, %q", entry.TraceID, entry.SpanID, tt.wantTrace, tt.wantSpan)
+			}
+			if len(entry.Fields) != len(tt.wantFields) {
+				t.Fatalf("Fields = %v, want keys %v", entry.Fields, tt.wantFields)
+			}
+			for _, k := range tt.wantFields {
+				if _, ok := entry.Fields[k]; !ok {
+					t.Fatalf("Fields = %v, want key %q", entry.Fields, k)
+				}
+			}
+			for k, want := range tt.wantValues {
+				if entry.Fields[k] != want {
+					t.Fatalf("Fields[%q] = %v, want %v", k, entry.Fields[k], want)
+				}
+			}
+		})
+	}
+}
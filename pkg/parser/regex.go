@@ -0,0 +1,179 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// CustomFormat is a user-defined format: a regular expression whose named
+// capture groups map the line onto an entry. The groups "timestamp",
+// "level" and "message" (or "msg") fill those entry properties; every other
+// named group becomes a field.
+type CustomFormat struct {
+	Name    string
+	Pattern string
+	// Grok is a Grok expression used instead of Pattern, e.g.
+	// "%{IP:client} %{WORD:method} %{URIPATH:path}". %{NAME:field:int} and
+	// :float store the field as a number.
+	Grok string
+	// Layout is a log4j/logback PatternLayout used instead of Pattern, e.g.
+	// "%d{yyyy-MM-dd HH:mm:ss.SSS} [%t] %-5level %logger - %msg%n" (see
+	// Log4jParser). Its %d pattern replaces TimeFormat.
+	Layout string
+	// Delimiter and Columns make the format delimited instead (see
+	// CSVParser). Delimiter defaults to a comma; without Columns they are
+	// read from the header row.
+	Delimiter string
+	Columns   []string
+	// TimeFormat is the Go layout of the timestamp group, e.g.
+	// "2006-01-02 15:04:05"; empty accepts RFC 3339. Timestamps without a
+	// zone are read in the default location.
+	TimeFormat string
+	// Command makes an external program parse the format instead (see
+	// CommandParser), answering each line within Timeout.
+	Command []string
+	Timeout time.Duration
+}
+
+// newCustomParser creates the parser for a custom format: a CommandParser
+// when it sets a command, a Log4jParser when it sets a layout, a CSVParser
+// when it sets a delimiter or columns, a RegexParser otherwise.
+func newCustomParser(f CustomFormat) (Parser, error) {
+	if len(f.Command) > 0 {
+		if f.Layout != "" || f.Pattern != "" || f.Grok != "" || f.Delimiter != "" || len(f.Columns) > 0 {
+			return nil, fmt.Errorf("format %s: set command, layout, pattern, grok or delimiter/columns, not several", f.Name)
+		}
+		return NewCommandParser(f.Name, f.Command, f.Timeout)
+	}
+	if f.Layout != "" {
+		if f.Pattern != "" || f.Grok != "" || f.Delimiter != "" || len(f.Columns) > 0 {
+			return nil, fmt.Errorf("format %s: set layout, pattern, grok or delimiter/columns, not several", f.Name)
+		}
+		return NewLog4jParser(f.Name, f.Layout)
+	}
+	if f.Delimiter == "" && len(f.Columns) == 0 {
+		return NewRegexParser(f)
+	}
+	if f.Pattern != "" || f.Grok != "" {
+		return nil, fmt.Errorf("format %s: set pattern, grok or delimiter/columns, not several", f.Name)
+	}
+	delimiter := ','
+	if f.Delimiter != "" {
+		runes := []rune(f.Delimiter)
+		if len(runes) != 1 || runes[0] == '"' || runes[0] == '\r' || runes[0] == '\n' {
+			return nil, fmt.Errorf("format %s: delimiter must be a single character other than a quote or newline", f.Name)
+		}
+		delimiter = runes[0]
+	}
+	return NewCSVParser(f.Name, delimiter, f.Columns, f.TimeFormat), nil
+}
+
+// RegexParser handles a CustomFormat
+type RegexParser struct {
+	name string
+	re   *regexp.Regexp
+	// captures maps the named groups of re to what they store
+	captures   map[string]grokCapture
+	timeFormat string
+}
+
+// NewRegexParser creates a parser for a custom format, checking that its
+// pattern or Grok expression compiles
+func NewRegexParser(f CustomFormat) (*RegexParser, error) {
+	var (
+		re       *regexp.Regexp
+		captures map[string]grokCapture
+		err      error
+	)
+	switch {
+	case f.Pattern != "" && f.Grok != "":
+		return nil, fmt.Errorf("format %s: set pattern or grok, not both", f.Name)
+	case f.Grok != "":
+		re, captures, err = compileGrok(f.Grok)
+		if err != nil {
+			return nil, fmt.Errorf("format %s: %w", f.Name, err)
+		}
+	default:
+		re, err = regexp.Compile(f.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("format %s: invalid pattern: %w", f.Name, err)
+		}
+		captures = make(map[string]grokCapture)
+		for _, name := range re.SubexpNames() {
+			if name != "" {
+				captures[name] = grokCapture{field: name}
+			}
+		}
+	}
+	if len(captures) == 0 {
+		return nil, fmt.Errorf("format %s: pattern has no named capture groups", f.Name)
+	}
+	return &RegexParser{name: f.Name, re: re, captures: captures, timeFormat: f.TimeFormat}, nil
+}
+
+// Name returns the format name
+func (p *RegexParser) Name() string {
+	return p.name
+}
+
+// CanParse checks if the line matches the pattern
+func (p *RegexParser) CanParse(line string) bool {
+	return p.re.MatchString(line)
+}
+
+// Parse parses a matching line into a LogEntry
+func (p *RegexParser) Parse(line string) (*storage.LogEntry, error) {
+	m := p.re.FindStringSubmatch(line)
+	if m == nil {
+		return nil, errors.New(p.name + ": line does not match the pattern")
+	}
+
+	entry := &storage.LogEntry{
+		ID:     generateID(),
+		Fields: make(map[string]interface{}),
+		Raw:    line,
+	}
+	hasMessage := false
+	for i, group := range p.re.SubexpNames() {
+		capture, ok := p.captures[group]
+		value := m[i]
+		if !ok || value == "" {
+			continue
+		}
+		switch name := capture.field; name {
+		case "timestamp":
+			entry.Timestamp = p.parseTime(value)
+		case "level":
+			entry.Level = NormalizeLevel(value)
+		case "message", "msg":
+			if !hasMessage {
+				entry.Message, hasMessage = value, true
+			}
+		default:
+			entry.Fields[name] = capture.convert(value)
+		}
+	}
+	if !hasMessage {
+		entry.Message = line
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = timeNow()
+	}
+	promoteCorrelationIDs(entry)
+	return entry, nil
+}
+
+// parseTime reads the timestamp group, returning the zero time when it
+// doesn't match the layout.
+func (p *RegexParser) parseTime(value string) time.Time {
+	if p.timeFormat != "" {
+		t, _ := time.ParseInLocation(p.timeFormat, value, location())
+		return t
+	}
+	t, _ := time.Parse(time.RFC3339Nano, value)
+	return t
+}
@@ -0,0 +1,148 @@
+package parser
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRegexParser_Parse(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	originalTimeNow := timeNow
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = originalTimeNow }()
+
+	tests := []struct {
+		name          string
+		format        CustomFormat
+		line          string
+		wantLevel     string
+		wantMessage   string
+		wantTimestamp time.Time
+		wantFields    map[string]interface{}
+	}{
+		{
+			name:          "all groups with a layout",
+			format:        CustomFormat{Name: "app", Pattern: `^(?P<timestamp>\S+ \S+) (?P<level>\w+) \[(?P<module>\w+)\] (?P<message>.*)$`, TimeFormat: "2006-01-02 15:04:05"},
+			line:          "2026-01-02 10:00:00 warning [db] slow query",
+			wantLevel:     "WARN",
+			wantMessage:   "slow query",
+			wantTimestamp: time.Date(2026, 1, 2, 10, 0, 0, 0, time.Local),
+			wantFields:    map[string]interface{}{"module": "db"},
+		},
+		{
+			name:          "RFC 3339 timestamp and msg group",
+			format:        CustomFormat{Name: "app", Pattern: `^(?P<timestamp>\S+) (?P<msg>.*)$`},
+			line:          "2026-01-02T10:00:00.5Z hello",
+			wantMessage:   "hello",
+			wantTimestamp: time.Date(2026, 1, 2, 10, 0, 0, 500000000, time.UTC),
+			wantFields:    map[string]interface{}{},
+		},
+		{
+			name:          "no message group keeps the line, empty groups are skipped",
+			format:        CustomFormat{Name: "app", Pattern: `^(?P<user>\w+)(?: (?P<action>\w+))?`},
+			line:          "alice",
+			wantMessage:   "alice",
+			wantTimestamp: now,
+			wantFields:    map[string]interface{}{"user": "alice"},
+		},
+		{
+			name:          "unparseable timestamp falls back to now",
+			format:        CustomFormat{Name: "app", Pattern: `^(?P<timestamp>\S+) (?P<message>.*)$`},
+			line:          "yesterday hello",
+			wantMessage:   "hello",
+			wantTimestamp: now,
+			wantFields:    map[string]interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser, err := NewRegexParser(tt.format)
+			if err != nil {
+				t.Fatalf("NewRegexParser() error = %v", err)
+			}
+			if !parser.CanParse(tt.line) {
+				t.Fatalf("RegexParser.CanParse() = false")
+			}
+			entry, err := parser.Parse(tt.line)
+			if err != nil {
+				t.Fatalf("RegexParser.Parse() error = %v", err)
+			}
+			if entry.Level != tt.wantLevel {
+				t.Errorf("RegexParser.Parse() Level = %v, want %v", entry.Level, tt.wantLevel)
+			}
+			if entry.Message != tt.wantMessage {
+				t.Errorf("RegexParser.Parse() Message = %q, want %q", entry.Message, tt.wantMessage)
+			}
+			if !entry.Timestamp.Equal(tt.wantTimestamp) {
+				t.Errorf("RegexParser.Parse() Timestamp = %v, want %v", entry.Timestamp, tt.wantTimestamp)
+			}
+			if !reflect.DeepEqual(entry.Fields, tt.wantFields) {
+				t.Errorf("RegexParser.Parse() Fields = %v, want %v", entry.Fields, tt.wantFields)
+			}
+			if entry.Raw != tt.line || entry.ID == "" {
+				t.Errorf("RegexParser.Parse() Raw = %q, ID = %q", entry.Raw, entry.ID)
+			}
+		})
+	}
+}
+
+func TestNewDetectorWithCustom(t *testing.T) {
+	tests := []struct {
+		name    string
+		custom  []CustomFormat
+		wantErr bool
+	}{
+		{name: "none", custom: nil},
+		{name: "valid", custom: []CustomFormat{{Name: "my-app_1", Pattern: `(?P<message>.*)`}}},
+		{name: "bad name", custom: []CustomFormat{{Name: "my app", Pattern: `(?P<message>.*)`}}, wantErr: true},
+		{name: "built-in name", custom: []CustomFormat{{Name: "json", Pattern: `(?P<message>.*)`}}, wantErr: true},
+		{name: "auto", custom: []CustomFormat{{Name: "auto", Pattern: `(?P<message>.*)`}}, wantErr: true},
+		{name: "duplicate", custom: []CustomFormat{{Name: "a", Pattern: `(?P<message>.*)`}, {Name: "a", Pattern: `(?P<msg>.*)`}}, wantErr: true},
+		{name: "bad pattern", custom: []CustomFormat{{Name: "a", Pattern: `(?P<message>`}}, wantErr: true},
+		{name: "no named groups", custom: []CustomFormat{{Name: "a", Pattern: `^(\w+)`}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewDetectorWithCustom(tt.custom); (err != nil) != tt.wantErr {
+				t.Fatalf("NewDetectorWithCustom() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDetectorCustomFormats(t *testing.T) {
+	d, err := NewDetectorWithCustom([]CustomFormat{
+		{Name: "legacy", Pattern: `^(?P<level>[A-Z]+) \| (?P<message>.*)$`},
+		{Name: "kv", Pattern: `^msg=(?P<message>\S+)$`},
+	})
+	if err != nil {
+		t.Fatalf("NewDetectorWithCustom() error = %v", err)
+	}
+
+	if got := d.Formats(); !slices.Equal(got, append(Formats(), "kv", "legacy")) {
+		t.Fatalf("Formats() = %v", got)
+	}
+	if !d.ValidFormat("legacy") || !d.ValidFormat("json") || d.ValidFormat("xml") || ValidFormat("legacy") {
+		t.Fatal("ValidFormat() does not cover exactly the built-in and custom formats")
+	}
+
+	// Custom formats are tried before the built-in parsers: this line is
+	// also valid logfmt.
+	entry, err := d.Parse("msg=hello")
+	if err != nil || entry.Message != "hello" || len(entry.Fields) != 0 {
+		t.Fatalf("Parse() = %+v, %v; want the custom format", entry, err)
+	}
+	entry, err = d.ParseWithFormat("ERROR | disk full", "legacy")
+	if err != nil || entry.Level != "ERROR" || entry.Message != "disk full" {
+		t.Fatalf("ParseWithFormat(legacy) = %+v, %v", entry, err)
+	}
+	if _, err := d.ParseWithFormat(`{"msg":"x"}`, "legacy"); err == nil {
+		t.Fatal("ParseWithFormat(legacy) with a non-matching line should fail")
+	}
+	if _, err := NewDetector().ParseWithFormat("ERROR | disk full", "legacy"); err == nil {
+		t.Fatal("a plain detector should not know custom formats")
+	}
+}
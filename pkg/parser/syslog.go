@@ -0,0 +1,223 @@
+package parser
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// SyslogParser handles syslog lines in the BSD (RFC 3164) and IETF
+// (RFC 5424) formats:
+//
+//	<34>Oct 11 22:14:15 host app[123]: message
+//	<34>1 2003-10-11T22:14:15.003Z host app 123 ID47 [origin ip="10.0.0.1"] message
+//
+// The severity of the <PRI> header becomes the entry level; facility,
+// severity, host, app, pid and msgid are stored as fields, and RFC 5424
+// structured data as "<sd-id>.<param>" fields.
+type SyslogParser struct{}
+
+// NewSyslogParser creates a new syslog parser
+func NewSyslogParser() *SyslogParser {
+	return &SyslogParser{}
+}
+
+// syslogFacilities are the facility names, indexed by facility code.
+var syslogFacilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// syslogSeverities are the severity names, indexed by severity code, and
+// syslogLevels the levels they map to.
+var (
+	syslogSeverities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+	syslogLevels     = []string{"FATAL", "FATAL", "FATAL", "ERROR", "WARN", "INFO", "INFO", "DEBUG"}
+)
+
+var (
+	syslogPriority = regexp.MustCompile(`^<(\d{1,3})>`)
+	// rfc3164Header matches the timestamp: "Mmm dd hh:mm:ss", or RFC 3339
+	// as written by rsyslog's high-precision templates.
+	rfc3164Header = regexp.MustCompile(`^([A-Z][a-z]{2} {1,2}\d{1,2} \d{2}:\d{2}:\d{2}(?:\.\d{1,9})?|\d{4}-\d{2}-\d{2}T\S+)(?: |$)(.*)$`)
+	// rfc3164Tag matches "app[pid]: message" and "app: message".
+	rfc3164Tag = regexp.MustCompile(`^([^\s\[\]:]+)(?:\[([^\]]*)\])?: ?(.*)$`)
+)
+
+// CanParse checks if the line has a syslog <PRI> header followed by an
+// RFC 5424 or RFC 3164 header
+func (p *SyslogParser) CanParse(line string) bool {
+	_, err := p.parse(line)
+	return err == nil
+}
+
+// Parse parses a syslog line into a LogEntry
+func (p *SyslogParser) Parse(line string) (*storage.LogEntry, error) {
+	entry, err := p.parse(line)
+	if err != nil {
+		return nil, err
+	}
+	entry.ID = generateID()
+	promoteCorrelationIDs(entry)
+	return entry, nil
+}
+
+func (p *SyslogParser) parse(line string) (*storage.LogEntry, error) {
+	m := syslogPriority.FindStringSubmatch(line)
+	if m == nil {
+		return nil, errors.New("syslog: missing <PRI> header")
+	}
+	pri, _ := strconv.Atoi(m[1])
+	if pri > 191 {
+		return nil, errors.New("syslog: priority out of range")
+	}
+	entry := &storage.LogEntry{
+		Level: syslogLevels[pri%8],
+		Fields: map[string]interface{}{
+			"facility": syslogFacilities[pri/8],
+			"severity": syslogSeverities[pri%8],
+		},
+		Raw: line,
+	}
+
+	rest := line[len(m[0]):]
+	var err error
+	if strings.HasPrefix(rest, "1 ") {
+		err = parseRFC5424(entry, rest[2:])
+	} else {
+		err = parseRFC3164(entry, rest)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// parseRFC5424 parses "TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD [MSG]",
+// where "-" marks an empty header field.
+func parseRFC5424(entry *storage.LogEntry, rest string) error {
+	parts := strings.SplitN(rest, " ", 6)
+	if len(parts) < 6 {
+		return errors.New("syslog: short RFC 5424 header")
+	}
+	if parts[0] == "-" {
+		entry.Timestamp = timeNow()
+	} else {
+		t, err := time.Parse(time.RFC3339Nano, parts[0])
+		if err != nil {
+			return errors.New("syslog: invalid RFC 5424 timestamp")
+		}
+		entry.Timestamp = t
+	}
+	for i, key := range []string{"host", "app", "pid", "msgid"} {
+		if v := parts[i+1]; v != "-" {
+			entry.Fields[key] = v
+		}
+	}
+
+	msg, err := parseStructuredData(parts[5], entry.Fields)
+	if err != nil {
+		return err
+	}
+	entry.Message = strings.TrimPrefix(msg, "\ufeff")
+	return nil
+}
+
+// parseStructuredData stores the params of the structured data at the start
+// of s in fields and returns the message after it.
+func parseStructuredData(s string, fields map[string]interface{}) (string, error) {
+	if s == "-" || strings.HasPrefix(s, "- ") {
+		return strings.TrimPrefix(s[1:], " "), nil
+	}
+	if !strings.HasPrefix(s, "[") {
+		return "", errors.New("syslog: invalid structured data")
+	}
+	for strings.HasPrefix(s, "[") {
+		end := strings.IndexAny(s, " ]")
+		if end < 0 {
+			return "", errors.New("syslog: unterminated structured data")
+		}
+		id := s[1:end]
+		s = s[end:]
+		for strings.HasPrefix(s, " ") {
+			eq := strings.Index(s, `="`)
+			if eq < 0 {
+				return "", errors.New("syslog: invalid structured data param")
+			}
+			name := s[1:eq]
+			value, n, ok := sdValue(s[eq+2:])
+			if !ok {
+				return "", errors.New("syslog: unterminated structured data value")
+			}
+			fields[id+"."+name] = value
+			s = s[eq+2+n:]
+		}
+		if !strings.HasPrefix(s, "]") {
+			return "", errors.New("syslog: unterminated structured data")
+		}
+		s = s[1:]
+	}
+	return strings.TrimPrefix(s, " "), nil
+}
+
+// sdValue reads a param value up to its closing quote, undoing the \", \\
+// and \] escapes, and returns it with the bytes consumed.
+func sdValue(s string) (string, int, bool) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(`"\]`, s[i+1]) >= 0:
+			b.WriteByte(s[i+1])
+			i++
+		case c == '"':
+			return b.String(), i + 1, true
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, false
+}
+
+// parseRFC3164 parses "TIMESTAMP [HOSTNAME] TAG[PID]: MSG". The hostname is
+// often left out by local loggers, so a first word that reads as a tag is
+// taken as one. Timestamps without a year get the current one, or last
+// year's when that would put them more than a day in the future.
+func parseRFC3164(entry *storage.LogEntry, rest string) error {
+	m := rfc3164Header.FindStringSubmatch(rest)
+	if m == nil {
+		return errors.New("syslog: invalid RFC 3164 timestamp")
+	}
+	if strings.Contains(m[1], "T") {
+		t, err := time.Parse(time.RFC3339Nano, m[1])
+		if err != nil {
+			return errors.New("syslog: invalid RFC 3164 timestamp")
+		}
+		entry.Timestamp = t
+	} else {
+		t, err := time.ParseInLocation(time.Stamp, m[1], location())
+		if err != nil {
+			return errors.New("syslog: invalid RFC 3164 timestamp")
+		}
+		entry.Timestamp = withCurrentYear(t)
+	}
+
+	msg := m[2]
+	if word, after, ok := strings.Cut(msg, " "); ok && !rfc3164Tag.MatchString(msg) {
+		entry.Fields["host"] = word
+		msg = after
+	}
+	if t := rfc3164Tag.FindStringSubmatch(msg); t != nil {
+		entry.Fields["app"] = t[1]
+		if t[2] != "" {
+			entry.Fields["pid"] = t[2]
+		}
+		msg = t[3]
+	}
+	entry.Message = msg
+	return nil
+}
@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSyslogParser_CanParse(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want bool
+	}{
+		{name: "RFC 3164", line: "<34>Oct 11 22:14:15 host app[123]: message", want: true},
+		{name: "RFC 3164 without host", line: "<13>Oct  1 22:14:15 app: message", want: true},
+		{name: "RFC 5424", line: "<165>1 2003-10-11T22:14:15.003Z host app - - - message", want: true},
+		{name: "no priority", line: "Oct 11 22:14:15 host app[123]: message", want: false},
+		{name: "priority out of range", line: "<192>Oct 11 22:14:15 host app: message", want: false},
+		{name: "priority without header", line: "<34>hello", want: false},
+		{name: "RFC 5424 short header", line: "<34>1 2003-10-11T22:14:15Z host app", want: false},
+		{name: "RFC 5424 bad timestamp", line: "<34>1 yesterday host app - - - message", want: false},
+		{name: "RFC 5424 unterminated structured data", line: `<34>1 - host app - - [id k="v message`, want: false},
+		{name: "JSON", line: `{"level":"ERROR"}`, want: false},
+	}
+
+	parser := NewSyslogParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parser.CanParse(tt.line); got != tt.want {
+				t.Errorf("SyslogParser.CanParse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyslogParser_Parse(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.Local)
+	originalTimeNow := timeNow
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = originalTimeNow }()
+
+	tests := []struct {
+		name          string
+		line          string
+		wantLevel     string
+		wantMessage   string
+		wantTimestamp time.Time
+		wantFields    map[string]interface{}
+	}{
+		{
+			name:          "RFC 3164 with host and pid",
+			line:          "<34>Jan  1 22:14:15 host su[123]: 'su root' failed",
+			wantLevel:     "FATAL",
+			wantMessage:   "'su root' failed",
+			wantTimestamp: time.Date(2026, 1, 1, 22, 14, 15, 0, time.Local),
+			wantFields:    map[string]interface{}{"facility": "auth", "severity": "crit", "host": "host", "app": "su", "pid": "123"},
+		},
+		{
+			name:          "RFC 3164 without host, last year",
+			line:          "<14>Dec 31 23:59:59 cron: job done",
+			wantLevel:     "INFO",
+			wantMessage:   "job done",
+			wantTimestamp: time.Date(2025, 12, 31, 23, 59, 59, 0, time.Local),
+			wantFields:    map[string]interface{}{"facility": "user", "severity": "info", "app": "cron"},
+		},
+		{
+			name:          "RFC 3164 without tag",
+			line:          "<191>Jan  2 11:00:00 host plain text",
+			wantLevel:     "DEBUG",
+			wantMessage:   "plain text",
+			wantTimestamp: time.Date(2026, 1, 2, 11, 0, 0, 0, time.Local),
+			wantFields:    map[string]interface{}{"facility": "local7", "severity": "debug", "host": "host"},
+		},
+		{
+			name:          "RFC 3164 with RFC 3339 timestamp",
+			line:          "<12>2026-01-02T10:00:00.5Z host app[7]: disk low",
+			wantLevel:     "WARN",
+			wantMessage:   "disk low",
+			wantTimestamp: time.Date(2026, 1, 2, 10, 0, 0, 500000000, time.UTC),
+			wantFields:    map[string]interface{}{"facility": "user", "severity": "warning", "host": "host", "app": "app", "pid": "7"},
+		},
+		{
+			name:          "RFC 5424 with structured data",
+			line:          `<165>1 2003-10-11T22:14:15.003Z host app 1234 ID47 [exampleSDID@32473 iut="3" note="a \"b\" \] c"][origin ip="10.0.0.1"] ` + "\ufeff" + `An application event`,
+			wantLevel:     "INFO",
+			wantMessage:   "An application event",
+			wantTimestamp: time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC),
+			wantFields: map[string]interface{}{
+				"facility": "local4", "severity": "notice", "host": "host", "app": "app", "pid": "1234", "msgid": "ID47",
+				"exampleSDID@32473.iut": "3", "exampleSDID@32473.note": `a "b" ] c`, "origin.ip": "10.0.0.1",
+			},
+		},
+		{
+			name:          "RFC 5424 with nil fields and no message",
+			line:          "<11>1 - - - - - -",
+			wantLevel:     "ERROR",
+			wantMessage:   "",
+			wantTimestamp: now,
+			wantFields:    map[string]interface{}{"facility": "user", "severity": "err"},
+		},
+	}
+
+	parser := NewSyslogParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := parser.Parse(tt.line)
+			if err != nil {
+				t.Fatalf("SyslogParser.Parse() error = %v", err)
+			}
+			if entry.Level != tt.wantLevel {
+				t.Errorf("SyslogParser.Parse() Level = %v, want %v", entry.Level, tt.wantLevel)
+			}
+			if entry.Message != tt.wantMessage {
+				t.Errorf("SyslogParser.Parse() Message = %q, want %q", entry.Message, tt.wantMessage)
+			}
+			if !entry.Timestamp.Equal(tt.wantTimestamp) {
+				t.Errorf("SyslogParser.Parse() Timestamp = %v, want %v", entry.Timestamp, tt.wantTimestamp)
+			}
+			if !reflect.DeepEqual(entry.Fields, tt.wantFields) {
+				t.Errorf("SyslogParser.Parse() Fields = %v, want %v", entry.Fields, tt.wantFields)
+			}
+			if entry.Raw != tt.line || entry.ID == "" {
+				t.Errorf("SyslogParser.Parse() Raw = %q, ID = %q", entry.Raw, entry.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package parser
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// defaultLocation is where timestamps without a zone are read; nil reads
+// them in local time.
+var defaultLocation atomic.Pointer[time.Location]
+
+// SetDefaultLocation sets where every parser reads timestamps that carry no
+// zone ([parsing] default_timezone). nil restores local time.
+func SetDefaultLocation(loc *time.Location) {
+	defaultLocation.Store(loc)
+}
+
+// location returns where timestamps without a zone are read.
+func location() *time.Location {
+	if loc := defaultLocation.Load(); loc != nil {
+		return loc
+	}
+	return time.Local
+}
+
+// timestampKeys name the JSON and logfmt keys read as the entry timestamp,
+// in order of preference.
+var timestampKeys = []string{"timestamp", "time", "ts", "@timestamp"}
+
+// timestampLayouts are tried on text timestamps. Fractional seconds are
+// accepted after the seconds of any of them; layouts without a zone are
+// read in the default location.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05",
+	"2006/01/02 15:04:05",
+	"2006/01/02T15:04:05",
+	"02/Jan/2006:15:04:05 -0700",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC850,
+	time.UnixDate,
+	time.RubyDate,
+	time.ANSIC,
+	"Jan _2 2006 15:04:05",
+}
+
+// yearlessLayouts are tried last, giving the time the current year.
+var yearlessLayouts = []string{time.Stamp}
+
+// minEpochSeconds rejects small numbers, such as durations, as epoch
+// timestamps: anything before 1973-03-03.
+const minEpochSeconds = 1e8
+
+// takeTimestamp removes the first timestamp key of fields whose value reads
+// as a timestamp and returns the time. Keys whose values don't are left in
+// fields.
+func takeTimestamp[V any](fields map[string]V) (time.Time, bool) {
+	for _, key := range timestampKeys {
+		v, ok := fields[key]
+		if !ok {
+			continue
+		}
+		if t, ok := parseTimestamp(v); ok {
+			delete(fields, key)
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseTimestamp reads a timestamp value: text in one of timestampLayouts,
+// or epoch seconds, milliseconds, microseconds or nanoseconds as a number
+// or numeric string. The unit of an epoch follows from its magnitude.
+func parseTimestamp(v interface{}) (time.Time, bool) {
+	switch v := v.(type) {
+	case float64:
+		return epochTime(v)
+	case string:
+		return parseTimestampText(strings.TrimSpace(v))
+	}
+	return time.Time{}, false
+}
+
+func parseTimestampText(s string) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, false
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return epochInt(n)
+	}
+	if c := s[0]; c >= '0' && c <= '9' {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return epochTime(f)
+		}
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.ParseInLocation(layout, s, location()); err == nil {
+			return t, true
+		}
+	}
+	for _, layout := range yearlessLayouts {
+		if t, err := time.ParseInLocation(layout, s, location()); err == nil {
+			return withCurrentYear(t), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// epochInt reads an integer epoch without losing nanosecond precision.
+func epochInt(n int64) (time.Time, bool) {
+	switch abs := max(n, -n); {
+	case abs < minEpochSeconds:
+		return time.Time{}, false
+	case abs < 1e11:
+		return time.Unix(n, 0), true
+	case abs < 1e14:
+		return time.UnixMilli(n), true
+	case abs < 1e17:
+		return time.UnixMicro(n), true
+	}
+	return time.Unix(0, n), true
+}
+
+// epochTime reads a fractional epoch. Nanosecond epochs exceed float64
+// precision, so JSON numbers of that size are only exact to about 256ns.
+func epochTime(f float64) (time.Time, bool) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return time.Time{}, false
+	}
+	var unit time.Duration
+	switch abs := math.Abs(f); {
+	case abs < minEpochSeconds:
+		return time.Time{}, false
+	case abs < 1e11:
+		unit = time.Second
+	case abs < 1e14:
+		unit = time.Millisecond
+	case abs < 1e17:
+		unit = time.Microsecond
+	case abs < math.MaxInt64:
+		unit = time.Nanosecond
+	default:
+		return time.Time{}, false
+	}
+	whole, frac := math.Modf(f)
+	return time.Unix(0, int64(whole)*int64(unit)+int64(math.Round(frac*float64(unit)))), true
+}
+
+// withCurrentYear gives a time parsed without a year the current one, or
+// last year's when that puts it more than a day ahead.
+func withCurrentYear(t time.Time) time.Time {
+	now := timeNow().In(t.Location())
+	t = time.Date(now.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	if t.After(now.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t
+}
@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+	originalTimeNow := timeNow
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = originalTimeNow }()
+
+	epoch := time.Date(2026, 2, 17, 10, 30, 45, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value interface{}
+		want  time.Time
+		ok    bool
+	}{
+		{name: "RFC 3339", value: "2026-02-17T10:30:45Z", want: epoch, ok: true},
+		{name: "RFC 3339 with nanos", value: "2026-02-17T10:30:45.123456789+01:00", want: time.Date(2026, 2, 17, 10, 30, 45, 123456789, time.FixedZone("", 3600)), ok: true},
+		{name: "space separated", value: "2026-02-17 10:30:45.250", want: time.Date(2026, 2, 17, 10, 30, 45, 250000000, time.Local), ok: true},
+		{name: "comma millis", value: "2026-02-17 10:30:45,250", want: time.Date(2026, 2, 17, 10, 30, 45, 250000000, time.Local), ok: true},
+		{name: "slashes", value: "2026/02/17 10:30:45", want: time.Date(2026, 2, 17, 10, 30, 45, 0, time.Local), ok: true},
+		{name: "access log", value: "17/Feb/2026:10:30:45 +0000", want: epoch, ok: true},
+		{name: "ruby date", value: "Tue Feb 17 10:30:45 +0000 2026", want: epoch, ok: true},
+		{name: "without year", value: "Feb 17 10:30:45", want: time.Date(2026, 2, 17, 10, 30, 45, 0, time.Local), ok: true},
+		{name: "without year, last year", value: "Dec 31 23:00:00", want: time.Date(2025, 12, 31, 23, 0, 0, 0, time.Local), ok: true},
+		{name: "epoch seconds", value: float64(epoch.Unix()), want: epoch, ok: true},
+		{name: "fractional epoch seconds", value: float64(epoch.Unix()) + 0.5, want: epoch.Add(500 * time.Millisecond), ok: true},
+		{name: "epoch millis", value: float64(epoch.UnixMilli() + 7), want: epoch.Add(7 * time.Millisecond), ok: true},
+		{name: "epoch micros string", value: "1771324245000007", want: epoch.Add(7 * time.Microsecond), ok: true},
+		{name: "epoch nanos string", value: "1771324245000000007", want: epoch.Add(7), ok: true},
+		{name: "fractional epoch string", value: "1771324245.25", want: epoch.Add(250 * time.Millisecond), ok: true},
+		{name: "small number", value: float64(12.5)},
+		{name: "small integer string", value: "42"},
+		{name: "text", value: "yesterday"},
+		{name: "bool", value: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseTimestamp(tt.value)
+			if ok != tt.ok || (ok && !got.Equal(tt.want)) {
+				t.Fatalf("parseTimestamp(%v) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParsersReadTimestampKeys(t *testing.T) {
+	want := time.Date(2026, 2, 17, 10, 30, 45, 0, time.UTC)
+	tests := []struct {
+		name      string
+		parser    Parser
+		line      string
+		wantField string
+	}{
+		{name: "json ts millis", parser: NewJSONParser(), line: `{"ts":1771324245000,"level":"info","msg":"ok"}`},
+		{name: "json @timestamp", parser: NewJSONParser(), line: `{"@timestamp":"2026-02-17T10:30:45Z","message":"ok"}`},
+		{name: "json unreadable time kept", parser: NewJSONParser(), line: `{"time":12.5,"ts":1771324245,"msg":"ok"}`, wantField: "time"},
+		{name: "logfmt epoch", parser: NewLogfmtParser(), line: `ts=1771324245 level=info msg=ok`},
+		{name: "logfmt timestamp", parser: NewLogfmtParser(), line: `timestamp="Tue, 17 Feb 2026 10:30:45 +0000" level=info msg=ok`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := tt.parser.Parse(tt.line)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !entry.Timestamp.Equal(want) {
+				t.Errorf("Timestamp = %v, want %v", entry.Timestamp, want)
+			}
+			for _, key := range timestampKeys {
+				if _, ok := entry.Fields[key]; ok && key != tt.wantField {
+					t.Errorf("Fields[%q] kept after it set the timestamp", key)
+				}
+			}
+			if tt.wantField != "" && entry.Fields[tt.wantField] == nil {
+				t.Errorf("Fields[%q] dropped, want it kept", tt.wantField)
+			}
+		})
+	}
+}
+
+func TestParsersReadDefaultLocation(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	originalTimeNow := timeNow
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = originalTimeNow }()
+
+	loc := time.FixedZone("ART", -3*60*60)
+	SetDefaultLocation(loc)
+	defer SetDefaultLocation(nil)
+
+	csv := NewCSVParser("csv", ',', []string{"timestamp", "message"}, "")
+	tests := []struct {
+		name   string
+		parser Parser
+		line   string
+		want   time.Time
+	}{
+		{name: "json", parser: NewJSONParser(), line: `{"time":"2026-02-17 10:30:45","msg":"hi"}`, want: time.Date(2026, 2, 17, 10, 30, 45, 0, loc)},
+		{name: "json with zone", parser: NewJSONParser(), line: `{"time":"2026-02-17T10:30:45Z","msg":"hi"}`, want: time.Date(2026, 2, 17, 10, 30, 45, 0, time.UTC)},
+		{name: "logfmt", parser: NewLogfmtParser(), line: `ts="2026-02-17 10:30:45" msg=hi`, want: time.Date(2026, 2, 17, 10, 30, 45, 0, loc)},
+		{name: "syslog", parser: NewSyslogParser(), line: "<34>Feb 17 10:30:45 host app: hi", want: time.Date(2026, 2, 17, 10, 30, 45, 0, loc)},
+		{name: "klog", parser: NewKlogParser(), line: "I0217 10:30:45.000000       1 main.go:1] hi", want: time.Date(2026, 2, 17, 10, 30, 45, 0, loc)},
+		{name: "log4j", parser: newDefaultLog4jParser(), line: "2026-02-17 10:30:45,000 [main] INFO  app - hi", want: time.Date(2026, 2, 17, 10, 30, 45, 0, loc)},
+		{name: "csv", parser: csv, line: "2026-02-17 10:30:45,hi", want: time.Date(2026, 2, 17, 10, 30, 45, 0, loc)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := tt.parser.Parse(tt.line)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !entry.Timestamp.Equal(tt.want) {
+				t.Errorf("Timestamp = %v, want %v", entry.Timestamp, tt.want)
+			}
+		})
+	}
+}
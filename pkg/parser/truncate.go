@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"encoding/json"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// TruncatedFieldsKey is the field listing which values Truncate cut short.
+const TruncatedFieldsKey = "truncated_fields"
+
+// truncationMark ends every truncated value.
+const truncationMark = "…"
+
+// Truncate cuts the message and every field value longer than maxBytes down
+// to maxBytes, and records the names of the values it cut under
+// TruncatedFieldsKey ("message" for the message). Non-string values are
+// measured as JSON and replaced by their truncated JSON text. The raw line
+// is left intact. It reports whether anything was cut; maxBytes <= 0 keeps
+// every value.
+func Truncate(entry *storage.LogEntry, maxBytes int) bool {
+	if maxBytes <= 0 {
+		return false
+	}
+
+	var cut []string
+	if len(entry.Message) > maxBytes {
+		entry.Message = truncateString(entry.Message, maxBytes)
+		cut = append(cut, "message")
+	}
+	for k, v := range entry.Fields {
+		s, ok := v.(string)
+		if !ok {
+			data, err := json.Marshal(v)
+			if err != nil || len(data) <= maxBytes {
+				continue
+			}
+			s = string(data)
+		} else if len(s) <= maxBytes {
+			continue
+		}
+		entry.Fields[k] = truncateString(s, maxBytes)
+		cut = append(cut, k)
+	}
+	if len(cut) == 0 {
+		return false
+	}
+
+	sort.Strings(cut)
+	if entry.Fields == nil {
+		entry.Fields = make(map[string]interface{})
+	}
+	entry.Fields[TruncatedFieldsKey] = cut
+	return true
+}
+
+// truncateString cuts s to at most maxBytes on a rune boundary and appends
+// truncationMark.
+func truncateString(s string, maxBytes int) string {
+	n := maxBytes
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + truncationMark
+}
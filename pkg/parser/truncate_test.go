@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name       string
+		entry      storage.LogEntry
+		max        int
+		wantCut    bool
+		wantMsg    string
+		wantFields map[string]interface{}
+	}{
+		{
+			name:       "disabled",
+			entry:      storage.LogEntry{Message: "0123456789", Fields: map[string]interface{}{"body": "0123456789"}},
+			max:        0,
+			wantMsg:    "0123456789",
+			wantFields: map[string]interface{}{"body": "0123456789"},
+		},
+		{
+			name:       "within limit",
+			entry:      storage.LogEntry{Message: "short", Fields: map[string]interface{}{"n": 42.0}},
+			max:        8,
+			wantMsg:    "short",
+			wantFields: map[string]interface{}{"n": 42.0},
+		},
+		{
+			name:       "message and string field",
+			entry:      storage.LogEntry{Message: "0123456789", Fields: map[string]interface{}{"body": "abcdefghij", "ok": "abc"}},
+			max:        4,
+			wantCut:    true,
+			wantMsg:    "0123…",
+			wantFields: map[string]interface{}{"body": "abcd…", "ok": "abc", TruncatedFieldsKey: []string{"body", "message"}},
+		},
+		{
+			name:       "rune boundary",
+			entry:      storage.LogEntry{Message: "ééé"},
+			max:        3,
+			wantCut:    true,
+			wantMsg:    "é…",
+			wantFields: map[string]interface{}{TruncatedFieldsKey: []string{"message"}},
+		},
+		{
+			name:       "nested value",
+			entry:      storage.LogEntry{Message: "m", Fields: map[string]interface{}{"req": map[string]interface{}{"a": "bbbbbbbb"}}},
+			max:        6,
+			wantCut:    true,
+			wantMsg:    "m",
+			wantFields: map[string]interface{}{"req": `{"a":"…`, TruncatedFieldsKey: []string{"req"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := tt.entry
+			if got := Truncate(&entry, tt.max); got != tt.wantCut {
+				t.Fatalf("Truncate() = %v, want %v", got, tt.wantCut)
+			}
+			if entry.Message != tt.wantMsg || !reflect.DeepEqual(entry.Fields, tt.wantFields) {
+				t.Fatalf("Truncate() entry = %q %v, want %q %v", entry.Message, entry.Fields, tt.wantMsg, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestTruncateKeepsRawLine(t *testing.T) {
+	raw := `{"msg":"` + strings.Repeat("x", 100) + `"}`
+	entry, err := NewDetector().ParseWithFormat(raw, "json")
+	if err != nil {
+		t.Fatalf("ParseWithFormat() error = %v", err)
+	}
+	Truncate(entry, 10)
+	if entry.Raw != raw || len(entry.Message) != 10+len(truncationMark) {
+		t.Fatalf("Truncate() raw = %d bytes, message = %q", len(entry.Raw), entry.Message)
+	}
+}
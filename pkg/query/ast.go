@@ -0,0 +1,259 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Node is a parsed query expression: *AndNode, *OrNode, *NotNode, *TermNode
+// or AllNode. ParseAST returns the tree of a query string and Compile turns a
+// tree, parsed or built by hand, into a Query.
+type Node interface {
+	// String returns the expression in query syntax, with parentheses only
+	// where precedence needs them. For trees from ParseAST, parsing it
+	// yields the same tree.
+	String() string
+	isNode()
+}
+
+// AllNode matches every entry: the empty query and "*".
+type AllNode struct{}
+
+// AndNode matches entries that match every child. Implicit AND (terms
+// separated by whitespace) parses to the same node, and nested ANDs are
+// flattened into one.
+type AndNode struct {
+	Children []Node
+}
+
+// OrNode matches entries that match any child. Nested ORs are flattened
+// into one.
+type OrNode struct {
+	Children []Node
+}
+
+// NotNode matches entries that Child does not match.
+type NotNode struct {
+	Child Node
+}
+
+// TermKind selects how a TermNode matches.
+type TermKind int
+
+const (
+	TermKeyword  TermKind = iota // Value anywhere in the message or fields
+	TermMatch                    // Field:Value, case-insensitive substring
+	TermPhrase                   // Field:"Value", exact
+	TermWildcard                 // Field:pat*tern, Value holds the pattern
+	TermRange                    // Field:[Low TO High]
+)
+
+// TermNode is a single search term.
+type TermNode struct {
+	Kind  TermKind
+	Field string // empty for TermKeyword
+	Value string // unused by TermRange
+	Low   string // TermRange bounds as written: numbers or times
+	High  string
+}
+
+func (AllNode) isNode()   {}
+func (*AndNode) isNode()  {}
+func (*OrNode) isNode()   {}
+func (*NotNode) isNode()  {}
+func (*TermNode) isNode() {}
+
+func (AllNode) String() string { return "*" }
+
+func (n *AndNode) String() string {
+	parts := make([]string, len(n.Children))
+	for i, c := range n.Children {
+		parts[i] = c.String()
+		if _, ok := c.(*OrNode); ok {
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+	return strings.Join(parts, " AND ")
+}
+
+func (n *OrNode) String() string {
+	parts := make([]string, len(n.Children))
+	for i, c := range n.Children {
+		parts[i] = c.String()
+	}
+	return strings.Join(parts, " OR ")
+}
+
+func (n *NotNode) String() string {
+	switch n.Child.(type) {
+	case *AndNode, *OrNode:
+		return "NOT (" + n.Child.String() + ")"
+	}
+	return "NOT " + n.Child.String()
+}
+
+func (n *TermNode) String() string {
+	switch n.Kind {
+	case TermKeyword:
+		if bareKeyword(n.Value) {
+			return n.Value
+		}
+		return quote(n.Value)
+	case TermPhrase:
+		return n.Field + ":" + quote(n.Value)
+	case TermRange:
+		return n.Field + ":[" + n.Low + " TO " + n.High + "]"
+	}
+	return n.Field + ":" + n.Value
+}
+
+// bareKeyword reports whether keyword reads back as the same keyword
+// without quotes.
+func bareKeyword(keyword string) bool {
+	if _, op := operators[keyword]; op || keyword == "" || keyword[0] == '[' {
+		return false
+	}
+	return !strings.ContainsAny(keyword, " \t\r\n()\":")
+}
+
+// quote returns s as a quoted string, escaping quotes and backslashes.
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// Walk calls fn for n and then, while fn returns true, for each of its
+// descendants in order.
+func Walk(n Node, fn func(Node) bool) {
+	if n == nil || !fn(n) {
+		return
+	}
+	switch n := n.(type) {
+	case *AndNode:
+		for _, c := range n.Children {
+			Walk(c, fn)
+		}
+	case *OrNode:
+		for _, c := range n.Children {
+			Walk(c, fn)
+		}
+	case *NotNode:
+		Walk(n.Child, fn)
+	}
+}
+
+// Compile returns the Query that evaluates n. Trees built by hand are
+// checked like parsed queries: at most MaxClauses terms and MaxPatternLength
+// bytes of wildcard pattern, and every operator needs an operand.
+func Compile(n Node) (*Query, error) {
+	clauses := 0
+	if err := validate(n, &clauses); err != nil {
+		return nil, err
+	}
+	return &Query{root: n, filters: []Filter{(&parser{}).compile(n)}}, nil
+}
+
+// Format returns the query text of n after checking it with Compile. Trees
+// built by hand can hold terms that have no query syntax, such as a match
+// value with spaces; Format reports those instead of returning text that
+// parses differently.
+func Format(n Node) (string, error) {
+	if _, err := Compile(n); err != nil {
+		return "", err
+	}
+	text := n.String()
+	back, err := ParseAST(text)
+	if err != nil || back.String() != text || !slices.Equal(terms(back), terms(n)) {
+		return "", fmt.Errorf("query tree has no query syntax: %s", text)
+	}
+	return text, nil
+}
+
+// terms returns the terms of n in order.
+func terms(n Node) []TermNode {
+	var out []TermNode
+	Walk(n, func(n Node) bool {
+		if t, ok := n.(*TermNode); ok {
+			out = append(out, *t)
+		}
+		return true
+	})
+	return out
+}
+
+// validate reports the first problem of n, counting its terms in clauses.
+func validate(n Node, clauses *int) error {
+	var children []Node
+	switch n := n.(type) {
+	case nil:
+		return errors.New("missing query node")
+	case *AndNode:
+		children = n.Children
+	case *OrNode:
+		children = n.Children
+	case *NotNode:
+		children = []Node{n.Child}
+	case *TermNode:
+		*clauses++
+		if *clauses > MaxClauses {
+			return fmt.Errorf("query has more than %d clauses", MaxClauses)
+		}
+		if n.Kind < TermKeyword || n.Kind > TermRange {
+			return fmt.Errorf("unknown term kind %d", n.Kind)
+		}
+		if n.Kind == TermWildcard && len(n.Value) > MaxPatternLength {
+			return fmt.Errorf("wildcard pattern is longer than %d bytes", MaxPatternLength)
+		}
+		return nil
+	default:
+		return nil
+	}
+	if len(children) == 0 {
+		return errors.New("AND or OR node without children")
+	}
+	for _, c := range children {
+		if err := validate(c, clauses); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compile turns n into the Filter that evaluates it. AND and OR chains
+// become left-nested AndFilter and OrFilter pairs.
+func (p *parser) compile(n Node) Filter {
+	switch n := n.(type) {
+	case *AndNode:
+		f := p.compile(n.Children[0])
+		for _, c := range n.Children[1:] {
+			f = &AndFilter{Left: f, Right: p.compile(c)}
+		}
+		return f
+	case *OrNode:
+		f := p.compile(n.Children[0])
+		for _, c := range n.Children[1:] {
+			f = &OrFilter{Left: f, Right: p.compile(c)}
+		}
+		return f
+	case *NotNode:
+		return &NotFilter{Filter: p.compile(n.Child)}
+	case *TermNode:
+		switch n.Kind {
+		case TermKeyword:
+			return &KeywordFilter{Keyword: n.Value}
+		case TermPhrase:
+			return &FieldFilter{Field: n.Field, Value: n.Value, Exact: true}
+		case TermWildcard:
+			return newWildcardFilter(n.Field, n.Value)
+		case TermRange:
+			if n.Field == "timestamp" {
+				return &TimestampRangeFilter{Start: p.parseTimeValue(n.Low), End: p.parseTimeValue(n.High)}
+			}
+			return &NumericRangeFilter{Field: n.Field, Start: p.parseNumericValue(n.Low), End: p.parseNumericValue(n.High)}
+		}
+		return &FieldFilter{Field: n.Field, Value: n.Value}
+	}
+	return &AllFilter{}
+}
@@ -0,0 +1,100 @@
+package query
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+func TestCompileBuiltTree(t *testing.T) {
+	tree := &AndNode{Children: []Node{
+		&TermNode{Kind: TermMatch, Field: "service", Value: "api"},
+		&OrNode{Children: []Node{
+			&TermNode{Kind: TermRange, Field: "status", Low: "500", High: "599"},
+			&NotNode{Child: &TermNode{Kind: TermPhrase, Field: "level", Value: "INFO"}},
+		}},
+	}}
+	q, err := Compile(tree)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	if got, want := q.AST().String(), `service:api AND (status:[500 TO 599] OR NOT level:"INFO")`; got != want {
+		t.Fatalf("String() = %s, want %s", got, want)
+	}
+
+	tests := []struct {
+		name  string
+		entry *storage.LogEntry
+		want  bool
+	}{
+		{name: "server error", entry: &storage.LogEntry{Level: "INFO", Fields: map[string]interface{}{"service": "api", "status": 503}}, want: true},
+		{name: "warning", entry: &storage.LogEntry{Level: "WARN", Fields: map[string]interface{}{"service": "api", "status": 200}}, want: true},
+		{name: "info ok", entry: &storage.LogEntry{Level: "INFO", Fields: map[string]interface{}{"service": "api", "status": 200}}, want: false},
+		{name: "other service", entry: &storage.LogEntry{Level: "WARN", Fields: map[string]interface{}{"service": "web"}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := q.Match(tt.entry); got != tt.want {
+				t.Fatalf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileRejectsInvalidTrees(t *testing.T) {
+	many := make([]Node, MaxClauses+1)
+	for i := range many {
+		many[i] = &TermNode{Kind: TermKeyword, Value: "a"}
+	}
+	tests := []struct {
+		name    string
+		tree    Node
+		wantErr string
+	}{
+		{name: "nil", tree: nil, wantErr: "missing"},
+		{name: "empty and", tree: &AndNode{}, wantErr: "without children"},
+		{name: "not without child", tree: &NotNode{}, wantErr: "missing"},
+		{name: "unknown kind", tree: &TermNode{Kind: TermKind(42)}, wantErr: "unknown term kind"},
+		{name: "long pattern", tree: &TermNode{Kind: TermWildcard, Field: "msg", Value: strings.Repeat("x", MaxPatternLength) + "*"}, wantErr: "wildcard pattern"},
+		{name: "too many clauses", tree: &OrNode{Children: many}, wantErr: "clauses"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Compile(tt.tree); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Compile() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWalkAndQueryAST(t *testing.T) {
+	q, err := Parse("level:ERROR AND (service:api OR NOT host.name:web*) timeout")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	var fields []string
+	Walk(q.AST(), func(n Node) bool {
+		if _, ok := n.(*NotNode); ok {
+			return false // skip negated terms
+		}
+		if term, ok := n.(*TermNode); ok {
+			fields = append(fields, term.Field)
+		}
+		return true
+	})
+	if got := strings.Join(fields, ","); got != "level,service," {
+		t.Fatalf("walked fields = %q, want %q", got, "level,service,")
+	}
+
+	// A transformed tree compiles without reparsing.
+	root := q.AST().(*AndNode)
+	root.Children[0] = &TermNode{Kind: TermMatch, Field: "level", Value: "WARN"}
+	if got, want := root.String(), "level:WARN AND (service:api OR NOT host.name:web*) AND timeout"; got != want {
+		t.Fatalf("String() = %s, want %s", got, want)
+	}
+	if _, err := Compile(root); err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+}
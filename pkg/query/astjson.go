@@ -0,0 +1,131 @@
+package query
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// The JSON form of a Node, for clients that edit queries as structures:
+//
+//	{"op": "and", "children": [
+//	  {"op": "term", "kind": "match", "field": "level", "value": "ERROR"},
+//	  {"op": "not", "child": {"op": "term", "kind": "range", "field": "status", "low": "200", "high": "299"}}
+//	]}
+//
+// op is "all", "and", "or", "not" or "term"; kind is "keyword", "match",
+// "phrase", "wildcard" or "range".
+type jsonNode struct {
+	Op       string      `json:"op"`
+	Children []*jsonNode `json:"children,omitempty"`
+	Child    *jsonNode   `json:"child,omitempty"`
+	Kind     string      `json:"kind,omitempty"`
+	Field    string      `json:"field,omitempty"`
+	Value    string      `json:"value,omitempty"`
+	Low      string      `json:"low,omitempty"`
+	High     string      `json:"high,omitempty"`
+}
+
+// termKindNames are the JSON names of the term kinds, indexed by TermKind.
+var termKindNames = []string{
+	TermKeyword:  "keyword",
+	TermMatch:    "match",
+	TermPhrase:   "phrase",
+	TermWildcard: "wildcard",
+	TermRange:    "range",
+}
+
+// String returns the JSON name of k.
+func (k TermKind) String() string {
+	if k < 0 || int(k) >= len(termKindNames) {
+		return fmt.Sprintf("TermKind(%d)", int(k))
+	}
+	return termKindNames[k]
+}
+
+// MarshalAST encodes n in its JSON form.
+func MarshalAST(n Node) ([]byte, error) {
+	j, err := toJSONNode(n)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalAST decodes a tree from its JSON form. The tree is not checked
+// against the query limits; Compile does that.
+func UnmarshalAST(data []byte) (Node, error) {
+	var j jsonNode
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("decode query tree: %w", err)
+	}
+	return fromJSONNode(&j)
+}
+
+func toJSONNode(n Node) (*jsonNode, error) {
+	switch n := n.(type) {
+	case AllNode:
+		return &jsonNode{Op: "all"}, nil
+	case *AndNode:
+		children, err := toJSONNodes(n.Children)
+		return &jsonNode{Op: "and", Children: children}, err
+	case *OrNode:
+		children, err := toJSONNodes(n.Children)
+		return &jsonNode{Op: "or", Children: children}, err
+	case *NotNode:
+		child, err := toJSONNode(n.Child)
+		return &jsonNode{Op: "not", Child: child}, err
+	case *TermNode:
+		return &jsonNode{Op: "term", Kind: n.Kind.String(), Field: n.Field, Value: n.Value, Low: n.Low, High: n.High}, nil
+	}
+	return nil, fmt.Errorf("unknown query node %T", n)
+}
+
+func toJSONNodes(nodes []Node) ([]*jsonNode, error) {
+	out := make([]*jsonNode, len(nodes))
+	for i, n := range nodes {
+		j, err := toJSONNode(n)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = j
+	}
+	return out, nil
+}
+
+func fromJSONNode(j *jsonNode) (Node, error) {
+	if j == nil {
+		return nil, errors.New("missing query node")
+	}
+	switch j.Op {
+	case "all":
+		return AllNode{}, nil
+	case "and", "or":
+		children := make([]Node, len(j.Children))
+		for i, c := range j.Children {
+			n, err := fromJSONNode(c)
+			if err != nil {
+				return nil, err
+			}
+			children[i] = n
+		}
+		if j.Op == "and" {
+			return &AndNode{Children: children}, nil
+		}
+		return &OrNode{Children: children}, nil
+	case "not":
+		child, err := fromJSONNode(j.Child)
+		if err != nil {
+			return nil, err
+		}
+		return &NotNode{Child: child}, nil
+	case "term":
+		for kind, name := range termKindNames {
+			if name == j.Kind {
+				return &TermNode{Kind: TermKind(kind), Field: j.Field, Value: j.Value, Low: j.Low, High: j.High}, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown term kind %q", j.Kind)
+	}
+	return nil, fmt.Errorf("unknown query op %q", j.Op)
+}
@@ -0,0 +1,72 @@
+package query
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestASTJSONRoundTrip(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "*", want: `{"op":"all"}`},
+		{query: "timeout", want: `{"op":"term","kind":"keyword","value":"timeout"}`},
+		{
+			query: `level:ERROR AND NOT status:[200 TO 299] AND (msg:"a b" OR host:web*)`,
+			want: `{"op":"and","children":[` +
+				`{"op":"term","kind":"match","field":"level","value":"ERROR"},` +
+				`{"op":"not","child":{"op":"term","kind":"range","field":"status","low":"200","high":"299"}},` +
+				`{"op":"or","children":[{"op":"term","kind":"phrase","field":"msg","value":"a b"},{"op":"term","kind":"wildcard","field":"host","value":"web*"}]}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			n, err := ParseAST(tt.query)
+			if err != nil {
+				t.Fatalf("ParseAST() error = %v", err)
+			}
+			data, err := MarshalAST(n)
+			if err != nil {
+				t.Fatalf("MarshalAST() error = %v", err)
+			}
+			if string(data) != tt.want {
+				t.Fatalf("MarshalAST() = %s, want %s", data, tt.want)
+			}
+			back, err := UnmarshalAST(data)
+			if err != nil {
+				t.Fatalf("UnmarshalAST() error = %v", err)
+			}
+			if text, err := Format(back); err != nil || text != n.String() {
+				t.Fatalf("Format(UnmarshalAST()) = %q, %v, want %q", text, err, n.String())
+			}
+		})
+	}
+}
+
+func TestUnmarshalASTAndFormatErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr string
+	}{
+		{name: "not json", json: `{`, wantErr: "decode query tree"},
+		{name: "unknown op", json: `{"op":"xor"}`, wantErr: "unknown query op"},
+		{name: "unknown kind", json: `{"op":"term","kind":"regex"}`, wantErr: "unknown term kind"},
+		{name: "not without child", json: `{"op":"not"}`, wantErr: "missing query node"},
+		{name: "empty or", json: `{"op":"or","children":[]}`, wantErr: "without children"},
+		{name: "match value with spaces", json: `{"op":"term","kind":"match","field":"msg","value":"a b"}`, wantErr: "no query syntax"},
+		{name: "field with colon", json: `{"op":"term","kind":"phrase","field":"a:b","value":"x"}`, wantErr: "no query syntax"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := UnmarshalAST([]byte(tt.json))
+			if err == nil {
+				_, err = Format(n)
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
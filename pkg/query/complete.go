@@ -0,0 +1,181 @@
+package query
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// MaxCompletions caps the candidates Complete returns.
+const MaxCompletions = 50
+
+// Candidate kinds returned by Complete.
+const (
+	CandidateField    = "field"
+	CandidateOperator = "operator"
+	CandidateValue    = "value"
+)
+
+// Completions are the candidates for the token under a cursor. Choosing a
+// candidate replaces input[Start:End] with its Text.
+type Completions struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+	// Field is the field whose value is under the cursor; empty while a
+	// field name or keyword is typed.
+	Field string `json:"field,omitempty"`
+	// Prefix is the text typed so far that candidates start with.
+	Prefix     string      `json:"prefix"`
+	Candidates []Candidate `json:"candidates"`
+}
+
+// Candidate is one completion of the token under the cursor.
+type Candidate struct {
+	Text string `json:"text"`
+	Kind string `json:"kind"` // CandidateField, CandidateOperator or CandidateValue
+	// Detail is the field type for fields.
+	Detail string `json:"detail,omitempty"`
+}
+
+// Complete suggests completions for the token of input under cursor, a byte
+// offset: field names and operators while a bare word is typed, and the
+// field's top values after "field:". fields is the field catalog, as from
+// /fields. Ranges and quoted keywords get no candidates.
+func Complete(input string, cursor int, fields []storage.FieldInfo) Completions {
+	cursor = max(0, min(cursor, len(input)))
+	start, end := tokenAround(input, cursor)
+	c := Completions{Start: start, End: end, Prefix: input[start:cursor], Candidates: []Candidate{}}
+
+	colon := unquotedColon(c.Prefix)
+	if colon < 0 {
+		if strings.HasPrefix(c.Prefix, `"`) {
+			return c
+		}
+		c.Candidates = fieldCandidates(c.Prefix, fields, strings.TrimSpace(input[:start]) != "")
+		return c
+	}
+
+	c.Field = c.Prefix[:colon]
+	c.Start += colon + 1
+	c.Prefix = c.Prefix[colon+1:]
+	if strings.HasPrefix(c.Prefix, "[") {
+		return c
+	}
+	c.Candidates = valueCandidates(c.Field, strings.TrimPrefix(c.Prefix, `"`), fields)
+	return c
+}
+
+// tokenAround returns the span of the term touching cursor, using the
+// lexer's rules: terms end at unquoted spaces and parentheses.
+func tokenAround(input string, cursor int) (start, end int) {
+	quoted := false
+	for i := 0; i < cursor; i++ {
+		switch c := input[i]; {
+		case c == '\\' && quoted:
+			i++
+		case c == '"':
+			quoted = !quoted
+		case !quoted && (isSpace(c) || c == '(' || c == ')'):
+			start = i + 1
+		}
+	}
+	end = cursor
+	for end < len(input) {
+		c := input[end]
+		if c == '\\' && quoted {
+			end += 2
+			continue
+		}
+		if c == '"' {
+			quoted = !quoted
+		} else if !quoted && (isSpace(c) || c == '(' || c == ')') {
+			break
+		}
+		end++
+	}
+	return start, min(end, len(input))
+}
+
+// unquotedColon returns the index of the first ':' of word outside quotes,
+// or -1.
+func unquotedColon(word string) int {
+	quoted := false
+	for i := 0; i < len(word); i++ {
+		switch word[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case ':':
+			if !quoted {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// fieldCandidates lists the fields, then the operators, starting with
+// prefix. AND and OR are offered only after an earlier term.
+func fieldCandidates(prefix string, fields []storage.FieldInfo, afterTerm bool) []Candidate {
+	var candidates []Candidate
+	for _, f := range fields {
+		if hasPrefixFold(f.Name, prefix) {
+			candidates = append(candidates, Candidate{Text: f.Name + ":", Kind: CandidateField, Detail: f.Type})
+		}
+	}
+	ops := make([]string, 0, len(operators))
+	for op, kind := range operators {
+		if kind != tokNot && !afterTerm {
+			continue
+		}
+		if prefix != "" && hasPrefixFold(op, prefix) {
+			ops = append(ops, op)
+		}
+	}
+	sort.Strings(ops)
+	for _, op := range ops {
+		candidates = append(candidates, Candidate{Text: op, Kind: CandidateOperator})
+	}
+	return limitCandidates(candidates)
+}
+
+// valueCandidates lists the top values of field starting with prefix,
+// quoted where the lexer would otherwise split them.
+func valueCandidates(field, prefix string, fields []storage.FieldInfo) []Candidate {
+	var candidates []Candidate
+	for _, f := range fields {
+		if f.Name != field {
+			continue
+		}
+		for _, v := range f.TopValues {
+			if v == "" || !hasPrefixFold(v, prefix) {
+				continue
+			}
+			if strings.ContainsAny(v, " \t\n\r()\"*") || strings.HasPrefix(v, "[") {
+				v = strconv.Quote(v)
+			}
+			candidates = append(candidates, Candidate{Text: v, Kind: CandidateValue})
+		}
+	}
+	return limitCandidates(candidates)
+}
+
+func limitCandidates(candidates []Candidate) []Candidate {
+	if candidates == nil {
+		return []Candidate{}
+	}
+	if len(candidates) > MaxCompletions {
+		return candidates[:MaxCompletions]
+	}
+	return candidates
+}
+
+// hasPrefixFold reports whether s starts with prefix, ignoring case.
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
@@ -0,0 +1,58 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+func TestComplete(t *testing.T) {
+	fields := []storage.FieldInfo{
+		{Name: "level", Type: "string", TopValues: []string{"ERROR", "INFO", "WARN"}},
+		{Name: "message", Type: "string", TopValues: []string{"connection refused", "ok"}},
+		{Name: "service", Type: "string", TopValues: []string{"api", "billing"}},
+		{Name: "status", Type: "number", TopValues: []string{"500", "200"}},
+	}
+
+	tests := []struct {
+		name      string
+		input     string
+		cursor    int
+		wantStart int
+		wantEnd   int
+		wantField string
+		want      []string
+	}{
+		{name: "empty query", input: "", cursor: 0, want: []string{"level:", "message:", "service:", "status:"}},
+		{name: "field prefix", input: "s", cursor: 1, wantEnd: 1, want: []string{"service:", "status:"}},
+		{name: "field prefix ignores case", input: "LE", cursor: 2, wantEnd: 2, want: []string{"level:"}},
+		{name: "operator after term", input: "level:ERROR a", cursor: 13, wantStart: 12, wantEnd: 13, want: []string{"AND"}},
+		{name: "no AND at the start", input: "a", cursor: 1, wantEnd: 1, want: []string{}},
+		{name: "NOT at the start", input: "n", cursor: 1, wantEnd: 1, want: []string{"NOT"}},
+		{name: "values", input: "level:", cursor: 6, wantStart: 6, wantEnd: 6, wantField: "level", want: []string{"ERROR", "INFO", "WARN"}},
+		{name: "value prefix", input: "level:e", cursor: 7, wantStart: 6, wantEnd: 7, wantField: "level", want: []string{"ERROR"}},
+		{name: "quoted values", input: `(message:"con`, cursor: 13, wantStart: 9, wantEnd: 13, wantField: "message", want: []string{`"connection refused"`}},
+		{name: "cursor inside token", input: "service:a AND x", cursor: 8, wantStart: 8, wantEnd: 9, wantField: "service", want: []string{"api", "billing"}},
+		{name: "unknown field", input: "nope:", cursor: 5, wantStart: 5, wantEnd: 5, wantField: "nope", want: []string{}},
+		{name: "range", input: "status:[", cursor: 8, wantStart: 7, wantEnd: 8, wantField: "status", want: []string{}},
+		{name: "quoted keyword", input: `"lev`, cursor: 4, wantEnd: 4, want: []string{}},
+		{name: "cursor past end", input: "le", cursor: 10, wantEnd: 2, want: []string{"level:"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Complete(tt.input, tt.cursor, fields)
+			if c.Start != tt.wantStart || c.End != tt.wantEnd || c.Field != tt.wantField {
+				t.Errorf("Complete() span = [%d, %d) field %q, want [%d, %d) field %q", c.Start, c.End, c.Field, tt.wantStart, tt.wantEnd, tt.wantField)
+			}
+			got := []string{}
+			for _, cand := range c.Candidates {
+				got = append(got, cand.Text)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Complete() candidates = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mchurichi/peek/pkg/storage"
@@ -12,6 +14,7 @@ import (
 
 // Query represents a parsed Lucene-style query
 type Query struct {
+	root    Node
 	filters []Filter
 }
 
@@ -20,28 +23,39 @@ type Filter interface {
 	Match(entry *storage.LogEntry) bool
 }
 
-// Parse parses a Lucene-style query string
-func Parse(queryStr string) (*Query, error) {
-	if queryStr == "" || queryStr == "*" {
-		return &Query{filters: []Filter{&AllFilter{}}}, nil
-	}
+// ParseError reports why a query failed to parse and the byte offset in
+// the query where the problem was found.
+type ParseError struct {
+	Pos int
+	Msg string
+}
 
-	parser := &parser{
-		input: queryStr,
-		pos:   0,
-	}
+func (e *ParseError) Error() string {
+	return e.Msg
+}
 
-	filter, err := parser.parse()
+// Query complexity limits. A query over any of them is rejected with a
+// ParseError so a single request cannot pin the CPU or exhaust memory.
+const (
+	MaxQueryLength   = 16 * 1024 // bytes of query text
+	MaxClauses       = 256       // field, keyword, wildcard and range terms
+	MaxNesting       = 32        // depth of parentheses
+	MaxPatternLength = 512       // bytes of a wildcard pattern
+)
+
+// Parse parses a Lucene-style query string. Syntax errors and queries over
+// the complexity limits are *ParseError.
+func Parse(queryStr string) (*Query, error) {
+	n, err := ParseAST(queryStr)
 	if err != nil {
 		return nil, err
 	}
+	return &Query{root: n, filters: []Filter{(&parser{}).compile(n)}}, nil
+}
 
-	parser.skipWhitespace()
-	if parser.pos < len(parser.input) {
-		return nil, fmt.Errorf("unexpected token near %q", parser.input[parser.pos:])
-	}
-
-	return &Query{filters: []Filter{filter}}, nil
+// AST returns the expression tree the query was parsed or compiled from.
+func (q *Query) AST() Node {
+	return q.root
 }
 
 // Match checks if an entry matches the query
@@ -54,291 +68,33 @@ func (q *Query) Match(entry *storage.LogEntry) bool {
 	return true
 }
 
-// parser implements a simple Lucene query parser
-type parser struct {
-	input string
-	pos   int
-}
-
-func (p *parser) parse() (Filter, error) {
-	return p.parseOr()
-}
-
-func (p *parser) parseOr() (Filter, error) {
-	left, err := p.parseAnd()
-	if err != nil {
-		return nil, err
-	}
-
-	for {
-		p.skipWhitespace()
-		if p.peek("OR") {
-			p.consume(2)
-			p.skipWhitespace()
-			right, err := p.parseAnd()
-			if err != nil {
-				return nil, err
-			}
-			left = &OrFilter{Left: left, Right: right}
-		} else {
-			break
-		}
-	}
-
-	return left, nil
-}
-
-func (p *parser) parseAnd() (Filter, error) {
-	left, err := p.parseNot()
-	if err != nil {
-		return nil, err
-	}
-
-	for {
-		p.skipWhitespace()
-		if p.peek("AND") {
-			p.consume(3)
-			p.skipWhitespace()
-			right, err := p.parseNot()
-			if err != nil {
-				return nil, err
-			}
-			left = &AndFilter{Left: left, Right: right}
-		} else if p.pos < len(p.input) && !p.peek("OR") && !p.peek(")") {
-			// Implicit AND
-			right, err := p.parseNot()
-			if err != nil {
-				return nil, err
-			}
-			left = &AndFilter{Left: left, Right: right}
-		} else {
-			break
-		}
-	}
-
-	return left, nil
-}
-
-func (p *parser) parseNot() (Filter, error) {
-	p.skipWhitespace()
-	if p.peek("NOT") {
-		p.consume(3)
-		p.skipWhitespace()
-		filter, err := p.parsePrimary()
-		if err != nil {
-			return nil, err
-		}
-		return &NotFilter{Filter: filter}, nil
-	}
-	return p.parsePrimary()
-}
-
-func (p *parser) parsePrimary() (Filter, error) {
-	p.skipWhitespace()
-
-	// Handle parentheses
-	if p.peekChar('(') {
-		p.consume(1)
-		filter, err := p.parseOr()
-		if err != nil {
-			return nil, err
-		}
-		p.skipWhitespace()
-		if !p.peekChar(')') {
-			return nil, fmt.Errorf("expected closing parenthesis")
-		}
-		p.consume(1)
-		return filter, nil
-	}
-
-	// Parse field:value or keyword
-	token := p.readToken()
-	if token == "" {
-		return nil, fmt.Errorf("unexpected end of query")
-	}
-
-	// Check for field:value syntax
-	if strings.Contains(token, ":") {
-		parts := strings.SplitN(token, ":", 2)
-		field := parts[0]
-		value := parts[1]
-
-		// Handle range queries
-		if strings.HasPrefix(value, "[") {
-			return p.parseRange(field, value)
-		}
-
-		// Handle quoted strings
-		if strings.HasPrefix(value, "\"") {
-			value = strings.Trim(value, "\"")
-			return &FieldFilter{Field: field, Value: value, Exact: true}, nil
-		}
-
-		// Handle wildcards
-		if strings.Contains(value, "*") {
-			return &WildcardFilter{Field: field, Pattern: value}, nil
-		}
-
-		return &FieldFilter{Field: field, Value: value, Exact: false}, nil
-	}
-
-	// Keyword search (searches message and fields)
-	return &KeywordFilter{Keyword: token}, nil
-}
-
-func (p *parser) parseRange(field, rangeStr string) (Filter, error) {
-	// Range format: [start TO end]
-	rangeStr = strings.TrimPrefix(rangeStr, "[")
-	rangeStr = strings.TrimSuffix(rangeStr, "]")
-
-	parts := strings.Split(rangeStr, " TO ")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid range format")
-	}
-
-	start := strings.TrimSpace(parts[0])
-	end := strings.TrimSpace(parts[1])
-
-	// Handle timestamp ranges
-	if field == "timestamp" {
-		return &TimestampRangeFilter{
-			Start: p.parseTimeValue(start),
-			End:   p.parseTimeValue(end),
-		}, nil
-	}
-
-	// Handle numeric ranges
-	return &NumericRangeFilter{
-		Field: field,
-		Start: p.parseNumericValue(start),
-		End:   p.parseNumericValue(end),
-	}, nil
-}
-
-// reDay matches a number followed by 'd' (days), e.g. "7d".
-var reDay = regexp.MustCompile(`(\d+)d`)
-
-// reWeek matches a number followed by 'w' (weeks), e.g. "2w".
-var reWeek = regexp.MustCompile(`(\d+)w`)
-
-// parseDurationExtended extends time.ParseDuration to support day ('d') and
-// week ('w') units by converting them to hours before parsing.
-func parseDurationExtended(s string) (time.Duration, error) {
-	s = reDay.ReplaceAllStringFunc(s, func(m string) string {
-		n, _ := strconv.Atoi(m[:len(m)-1])
-		return fmt.Sprintf("%dh", n*24)
-	})
-	s = reWeek.ReplaceAllStringFunc(s, func(m string) string {
-		n, _ := strconv.Atoi(m[:len(m)-1])
-		return fmt.Sprintf("%dh", n*7*24)
-	})
-	return time.ParseDuration(s)
-}
-
-func (p *parser) parseTimeValue(val string) time.Time {
-	// Handle relative time (e.g., now-1h, now-7d, now-2w)
-	if strings.HasPrefix(val, "now") {
-		duration := strings.TrimPrefix(val, "now")
-		if duration == "" {
-			return time.Now()
-		}
-		duration = strings.TrimPrefix(duration, "-")
-		if d, err := parseDurationExtended(duration); err == nil {
-			return time.Now().Add(-d)
-		}
-	}
-
-	// Parse absolute RFC3339 timestamp
-	if t, err := time.Parse(time.RFC3339, val); err == nil {
-		return t
-	}
-
-	// Parse datetime without timezone (assume UTC)
-	if t, err := time.Parse("2006-01-02T15:04:05", val); err == nil {
-		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
-	}
-
-	// Parse date-only string (start of day UTC)
-	if t, err := time.Parse("2006-01-02", val); err == nil {
-		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
-	}
-
-	// Parse epoch milliseconds (values > 1e12 are clearly milliseconds, not seconds)
-	if ms, err := strconv.ParseInt(val, 10, 64); err == nil && ms > 1_000_000_000_000 {
-		return time.Unix(0, ms*int64(time.Millisecond)).UTC()
-	}
-
-	return time.Time{}
-}
-
-func (p *parser) parseNumericValue(val string) float64 {
-	if f, err := strconv.ParseFloat(val, 64); err == nil {
-		return f
-	}
-	return 0
-}
-
-func (p *parser) readToken() string {
-	start := p.pos
-
-	// Handle quoted strings
-	if p.peekChar('"') {
-		p.consume(1)
-		for p.pos < len(p.input) && !p.peekChar('"') {
-			p.pos++
-		}
-		if p.peekChar('"') {
-			p.pos++
-		}
-		return p.input[start:p.pos]
-	}
-
-	// Handle ranges
-	if p.peekChar('[') {
-		p.consume(1)
-		for p.pos < len(p.input) && !p.peekChar(']') {
-			p.pos++
-		}
-		if p.peekChar(']') {
-			p.pos++
-		}
-		return p.input[start:p.pos]
-	}
-
-	// Read until whitespace or special char
-	for p.pos < len(p.input) {
-		ch := p.input[p.pos]
-		if ch == ' ' || ch == '(' || ch == ')' {
-			break
+// MatchMeta evaluates the query against key metadata. The result is only
+// certain when every filter can be decided without decoding the entry.
+func (q *Query) MatchMeta(meta storage.EntryMeta) (bool, bool) {
+	certain := true
+	for _, filter := range q.filters {
+		match, ok := matchMeta(filter, meta)
+		if ok && !match {
+			return false, true
 		}
-		p.pos++
+		certain = certain && ok
 	}
-
-	return p.input[start:p.pos]
+	return true, certain
 }
 
-func (p *parser) skipWhitespace() {
-	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
-		p.pos++
-	}
+// IndexLookup reports the indexed field value the query is pinned to, if
+// any.
+func (q *Query) IndexLookup() (string, string, bool) {
+	return indexLookup(q.filters...)
 }
 
-func (p *parser) peek(str string) bool {
-	if p.pos+len(str) > len(p.input) {
-		return false
-	}
-	return p.input[p.pos:p.pos+len(str)] == str
-}
-
-func (p *parser) peekChar(ch byte) bool {
-	if p.pos >= len(p.input) {
-		return false
+// matchMeta evaluates f against meta when f implements storage.MetaFilter;
+// other filters are never certain.
+func matchMeta(f Filter, meta storage.EntryMeta) (match, certain bool) {
+	if mf, ok := f.(storage.MetaFilter); ok {
+		return mf.MatchMeta(meta)
 	}
-	return p.input[p.pos] == ch
-}
-
-func (p *parser) consume(n int) {
-	p.pos += n
+	return true, false
 }
 
 // Filter implementations
@@ -350,6 +106,10 @@ func (f *AllFilter) Match(entry *storage.LogEntry) bool {
 	return true
 }
 
+func (f *AllFilter) MatchMeta(meta storage.EntryMeta) (bool, bool) {
+	return true, true
+}
+
 // AndFilter combines two filters with AND logic
 type AndFilter struct {
 	Left  Filter
@@ -360,6 +120,36 @@ func (f *AndFilter) Match(entry *storage.LogEntry) bool {
 	return f.Left.Match(entry) && f.Right.Match(entry)
 }
 
+func (f *AndFilter) MatchMeta(meta storage.EntryMeta) (bool, bool) {
+	left, leftCertain := matchMeta(f.Left, meta)
+	if leftCertain && !left {
+		return false, true
+	}
+	right, rightCertain := matchMeta(f.Right, meta)
+	if rightCertain && !right {
+		return false, true
+	}
+	return true, leftCertain && rightCertain
+}
+
+// IndexLookup reports the indexed field value either side is pinned to.
+func (f *AndFilter) IndexLookup() (string, string, bool) {
+	return indexLookup(f.Left, f.Right)
+}
+
+// indexLookup returns the indexed field value the first of filters pinned to
+// one is pinned to.
+func indexLookup(filters ...Filter) (string, string, bool) {
+	for _, f := range filters {
+		if xf, ok := f.(storage.IndexFilter); ok {
+			if field, value, ok := xf.IndexLookup(); ok {
+				return field, value, true
+			}
+		}
+	}
+	return "", "", false
+}
+
 // OrFilter combines two filters with OR logic
 type OrFilter struct {
 	Left  Filter
@@ -370,6 +160,18 @@ func (f *OrFilter) Match(entry *storage.LogEntry) bool {
 	return f.Left.Match(entry) || f.Right.Match(entry)
 }
 
+func (f *OrFilter) MatchMeta(meta storage.EntryMeta) (bool, bool) {
+	left, leftCertain := matchMeta(f.Left, meta)
+	if leftCertain && left {
+		return true, true
+	}
+	right, rightCertain := matchMeta(f.Right, meta)
+	if rightCertain && right {
+		return true, true
+	}
+	return false, leftCertain && rightCertain
+}
+
 // NotFilter negates a filter
 type NotFilter struct {
 	Filter Filter
@@ -379,6 +181,11 @@ func (f *NotFilter) Match(entry *storage.LogEntry) bool {
 	return !f.Filter.Match(entry)
 }
 
+func (f *NotFilter) MatchMeta(meta storage.EntryMeta) (bool, bool) {
+	match, certain := matchMeta(f.Filter, meta)
+	return !match, certain
+}
+
 // FieldFilter matches a specific field value
 type FieldFilter struct {
 	Field string
@@ -387,23 +194,64 @@ type FieldFilter struct {
 }
 
 func (f *FieldFilter) Match(entry *storage.LogEntry) bool {
-	var value string
+	value, ok := fieldValue(entry, f.Field)
+	if !ok {
+		return false
+	}
+	return f.matchValue(value)
+}
+
+// IndexLookup reports the value an exact filter on an indexed field is
+// pinned to, so storage can answer it from the field's index.
+func (f *FieldFilter) IndexLookup() (string, string, bool) {
+	if !f.Exact || !storage.IndexedField(f.Field) {
+		return "", "", false
+	}
+	return f.Field, f.Value, true
+}
 
-	// Check special fields
-	switch f.Field {
+// fieldValue returns the value of a built-in or dynamic field of entry.
+func fieldValue(entry *storage.LogEntry, field string) (string, bool) {
+	switch field {
 	case "level":
-		value = entry.Level
+		return entry.Level, true
 	case "message":
-		value = entry.Message
-	default:
-		// Check in Fields map
-		if v, ok := entry.Fields[f.Field]; ok {
-			value = fmt.Sprintf("%v", v)
-		} else {
-			return false
+		return entry.Message, true
+	case "namespace":
+		return entry.Namespace, true
+	case "id":
+		return entry.ID, true
+	case "trace_id", "span_id":
+		traceID, spanID := entry.TraceContext()
+		if field == "span_id" {
+			traceID = spanID
+		}
+		return traceID, traceID != ""
+	case "source":
+		if entry.Source != "" {
+			return entry.Source, true
 		}
+	case "host.name", "host.os", "host.user":
+		value, _ := entry.HostField(field)
+		return value, value != ""
+	}
+	v, ok := entry.Fields[field]
+	if !ok {
+		return "", false
 	}
+	return fmt.Sprintf("%v", v), true
+}
 
+// MatchMeta decides level filters from key metadata; other fields need the
+// decoded entry.
+func (f *FieldFilter) MatchMeta(meta storage.EntryMeta) (bool, bool) {
+	if f.Field != "level" || !meta.LevelKnown {
+		return true, false
+	}
+	return f.matchValue(meta.Level), true
+}
+
+func (f *FieldFilter) matchValue(value string) bool {
 	if f.Exact {
 		return value == f.Value
 	}
@@ -430,36 +278,134 @@ func (f *KeywordFilter) Match(entry *storage.LogEntry) bool {
 		}
 	}
 
-	return false
+	// Search in promoted trace context, source and host name
+	hostName, _ := entry.HostField("host.name")
+	return strings.Contains(strings.ToLower(entry.TraceID), keyword) ||
+		strings.Contains(strings.ToLower(entry.SpanID), keyword) ||
+		strings.Contains(strings.ToLower(entry.Source), keyword) ||
+		strings.Contains(strings.ToLower(hostName), keyword)
 }
 
 // WildcardFilter matches field values with wildcards
 type WildcardFilter struct {
 	Field   string
 	Pattern string
+
+	// Parse sets matcher; filters built by hand compile it on first use.
+	once    sync.Once
+	matcher *wildcardMatcher
 }
 
-func (f *WildcardFilter) Match(entry *storage.LogEntry) bool {
-	var value string
+// newWildcardFilter returns a filter whose matcher is compiled up front.
+func newWildcardFilter(field, pattern string) *WildcardFilter {
+	f := &WildcardFilter{Field: field, Pattern: pattern, matcher: compileWildcard(pattern)}
+	f.once.Do(func() {})
+	return f
+}
 
-	switch f.Field {
-	case "level":
-		value = entry.Level
-	case "message":
-		value = entry.Message
+// wildcardKind selects how a wildcardMatcher tests a value.
+type wildcardKind int
+
+const (
+	wildcardRegexp   wildcardKind = iota
+	wildcardAny                   // *
+	wildcardPrefix                // lit*
+	wildcardSuffix                // *lit
+	wildcardContains              // *lit*
+)
+
+// wildcardMatcher is a compiled wildcard pattern. Patterns with a single
+// literal and leading or trailing stars skip the regexp and compare the
+// lower-cased value directly.
+type wildcardMatcher struct {
+	kind wildcardKind
+	lit  string // lower-cased literal for the fast paths
+	re   *regexp.Regexp
+}
+
+func (m *wildcardMatcher) match(value string) bool {
+	switch m.kind {
+	case wildcardAny:
+		return true
+	case wildcardPrefix:
+		return strings.HasPrefix(strings.ToLower(value), m.lit)
+	case wildcardSuffix:
+		return strings.HasSuffix(strings.ToLower(value), m.lit)
+	case wildcardContains:
+		return strings.Contains(strings.ToLower(value), m.lit)
 	default:
-		if v, ok := entry.Fields[f.Field]; ok {
-			value = fmt.Sprintf("%v", v)
-		} else {
-			return false
+		return m.re.MatchString(value)
+	}
+}
+
+// maxCachedWildcards bounds wildcardCache so a long-running server that
+// sees many distinct ad-hoc patterns does not grow without limit.
+const maxCachedWildcards = 1024
+
+var (
+	// wildcardCache shares compiled patterns between parsed queries, so
+	// every live-tail client subscribed with the same pattern uses one
+	// matcher.
+	wildcardCache  sync.Map // pattern -> *wildcardMatcher
+	wildcardCached atomic.Int32
+)
+
+// compileWildcard returns the case-insensitive, anchored matcher for a
+// wildcard pattern. Outside the stars the pattern is a regexp, as it has
+// always been; one that fails to compile never matches.
+func compileWildcard(pattern string) *wildcardMatcher {
+	if m, ok := wildcardCache.Load(pattern); ok {
+		return m.(*wildcardMatcher)
+	}
+	m := newWildcardMatcher(pattern)
+	if wildcardCached.Load() < maxCachedWildcards {
+		if _, loaded := wildcardCache.LoadOrStore(pattern, m); !loaded {
+			wildcardCached.Add(1)
+		}
+	}
+	return m
+}
+
+func newWildcardMatcher(pattern string) *wildcardMatcher {
+	lit := strings.Trim(pattern, "*")
+	leading := strings.HasPrefix(pattern, "*")
+	trailing := strings.HasSuffix(pattern, "*")
+	// The fast paths apply when the stars only surround one literal that
+	// has no regexp syntax of its own.
+	if !strings.Contains(lit, "*") && regexp.QuoteMeta(lit) == lit {
+		lower := strings.ToLower(lit)
+		switch {
+		case lit == "" && (leading || trailing):
+			return &wildcardMatcher{kind: wildcardAny}
+		case leading && trailing:
+			return &wildcardMatcher{kind: wildcardContains, lit: lower}
+		case trailing:
+			return &wildcardMatcher{kind: wildcardPrefix, lit: lower}
+		case leading:
+			return &wildcardMatcher{kind: wildcardSuffix, lit: lower}
 		}
 	}
 
-	// Convert wildcard pattern to regex
-	pattern := strings.ReplaceAll(f.Pattern, "*", ".*")
-	pattern = "^" + pattern + "$"
-	matched, _ := regexp.MatchString("(?i)"+pattern, value)
-	return matched
+	// (?s) lets stars span newlines in multi-line values such as stack
+	// traces, as the fast paths do.
+	re, err := regexp.Compile("(?is)^" + strings.ReplaceAll(pattern, "*", ".*") + "$")
+	if err != nil {
+		re = matchNothing
+	}
+	return &wildcardMatcher{kind: wildcardRegexp, re: re}
+}
+
+// matchNothing stands in for wildcard patterns that fail to compile.
+var matchNothing = regexp.MustCompile(`[^\s\S]`)
+
+func (f *WildcardFilter) Match(entry *storage.LogEntry) bool {
+	value, ok := fieldValue(entry, f.Field)
+	if !ok {
+		return false
+	}
+
+	f.once.Do(func() { f.matcher = compileWildcard(f.Pattern) })
+	return f.matcher.match(value)
 }
 
 // TimestampRangeFilter filters by timestamp range
@@ -469,15 +415,41 @@ type TimestampRangeFilter struct {
 }
 
 func (f *TimestampRangeFilter) Match(entry *storage.LogEntry) bool {
-	if !f.Start.IsZero() && entry.Timestamp.Before(f.Start) {
+	return f.matchTime(entry.Timestamp)
+}
+
+func (f *TimestampRangeFilter) MatchMeta(meta storage.EntryMeta) (bool, bool) {
+	return f.matchTime(meta.Timestamp), true
+}
+
+func (f *TimestampRangeFilter) matchTime(ts time.Time) bool {
+	if !f.Start.IsZero() && ts.Before(f.Start) {
 		return false
 	}
-	if !f.End.IsZero() && entry.Timestamp.After(f.End) {
+	if !f.End.IsZero() && ts.After(f.End) {
 		return false
 	}
 	return true
 }
 
+// SessionFilter matches entries ingested by a specific collect session
+type SessionFilter struct {
+	Session string
+}
+
+func (f *SessionFilter) Match(entry *storage.LogEntry) bool {
+	return entry.Session == f.Session
+}
+
+// NamespaceFilter matches entries pushed into a specific namespace
+type NamespaceFilter struct {
+	Namespace string
+}
+
+func (f *NamespaceFilter) Match(entry *storage.LogEntry) bool {
+	return entry.Namespace == f.Namespace
+}
+
 // NumericRangeFilter filters numeric field values
 type NumericRangeFilter struct {
 	Field string
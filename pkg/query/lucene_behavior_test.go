@@ -60,6 +60,30 @@ func TestParseTimeValueFormats(t *testing.T) {
 	}
 }
 
+func TestParseTimeValueDefaultLocation(t *testing.T) {
+	p := &parser{}
+	if got, want := p.parseTimeValue("2025-01-01T10:11:12"), time.Date(2025, 1, 1, 10, 11, 12, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("parseTimeValue() without a default location = %v, want UTC %v", got, want)
+	}
+
+	loc := time.FixedZone("ART", -3*60*60)
+	SetDefaultLocation(loc)
+	defer SetDefaultLocation(nil)
+	tests := []struct {
+		input string
+		want  time.Time
+	}{
+		{input: "2025-01-01T10:11:12", want: time.Date(2025, 1, 1, 10, 11, 12, 0, loc)},
+		{input: "2025-01-01", want: time.Date(2025, 1, 1, 0, 0, 0, 0, loc)},
+		{input: "2025-01-01T10:11:12Z", want: time.Date(2025, 1, 1, 10, 11, 12, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := p.parseTimeValue(tt.input); !got.Equal(tt.want) {
+			t.Errorf("parseTimeValue(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
 func TestQueryMatchingBehavior(t *testing.T) {
 	entry := &storage.LogEntry{Level: "ERROR", Message: "db failure", Fields: map[string]interface{}{"service": "api", "status": 503}}
 
@@ -96,21 +120,21 @@ func TestQueryMatchingBehavior(t *testing.T) {
 func TestParseRangeAndTokenReader(t *testing.T) {
 	entry := &storage.LogEntry{Fields: map[string]interface{}{"status": 503}}
 
-	rf, err := (&parser{}).parseRange("status", "[500 TO 599]")
+	rf, err := Parse("status:[500 TO 599]")
 	if err != nil {
-		t.Fatalf("parseRange() error = %v", err)
+		t.Fatalf("Parse() error = %v", err)
 	}
 	if !rf.Match(entry) {
 		t.Fatalf("expected numeric range filter to match")
 	}
 
-	p := &parser{input: `"hello world" [1 TO 2] bare`, pos: 0}
-	tokens := []string{`"hello world"`, `[1 TO 2]`, `bare`}
+	p := &parser{input: `"hello world" [1 TO 2] bare msg:"a (b)" NOT`}
+	if err := p.lex(); err != nil {
+		t.Fatalf("lex() error = %v", err)
+	}
+	tokens := []string{`"hello world"`, `[1 TO 2]`, `bare`, `msg:"a (b)"`, `NOT`, ``}
 	for i, want := range tokens {
-		if i > 0 {
-			p.skipWhitespace()
-		}
-		if got := p.readToken(); got != want {
+		if got := p.tokens[i].text; got != want {
 			t.Fatalf("token %d = %q, want %q", i, got, want)
 		}
 	}
@@ -121,6 +145,8 @@ func TestFilterMatchingEdgeCases(t *testing.T) {
 		Timestamp: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC),
 		Level:     "INFO",
 		Message:   "service started",
+		Namespace: "team-a",
+		ID:        "abc123",
 		Fields: map[string]interface{}{
 			"service": "api-server",
 			"latency": "45.5",
@@ -140,6 +166,12 @@ func TestFilterMatchingEdgeCases(t *testing.T) {
 		{name: "numeric from string", filter: &NumericRangeFilter{Field: "latency", Start: 40, End: 50}, want: true},
 		{name: "numeric missing field", filter: &NumericRangeFilter{Field: "missing", Start: 1, End: 2}, want: false},
 		{name: "numeric parse failure", filter: &NumericRangeFilter{Field: "service", Start: 1, End: 2}, want: false},
+		{name: "session mismatch", filter: &SessionFilter{Session: "run-1"}, want: false},
+		{name: "namespace match", filter: &NamespaceFilter{Namespace: "team-a"}, want: true},
+		{name: "namespace mismatch", filter: &NamespaceFilter{Namespace: "team-b"}, want: false},
+		{name: "namespace field", filter: &FieldFilter{Field: "namespace", Value: "team-a", Exact: true}, want: true},
+		{name: "id field", filter: &FieldFilter{Field: "id", Value: "abc123", Exact: true}, want: true},
+		{name: "namespace wildcard", filter: &WildcardFilter{Field: "namespace", Pattern: "team-*"}, want: true},
 	}
 
 	for _, tt := range tests {
@@ -152,12 +184,55 @@ func TestFilterMatchingEdgeCases(t *testing.T) {
 }
 
 func TestParseTimestampRangeQuery(t *testing.T) {
-	rf, err := (&parser{}).parseRange("timestamp", "[2025-01-01T00:00:00Z TO 2025-01-02T00:00:00Z]")
+	rf, err := Parse("timestamp:[2025-01-01T00:00:00Z TO 2025-01-02T00:00:00Z]")
 	if err != nil {
-		t.Fatalf("parseRange() error = %v", err)
+		t.Fatalf("Parse() error = %v", err)
 	}
 	entry := &storage.LogEntry{Timestamp: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
 	if !rf.Match(entry) {
 		t.Fatalf("expected timestamp range filter to match entry")
 	}
 }
+
+func TestQueryMatchMeta(t *testing.T) {
+	meta := storage.EntryMeta{
+		Timestamp:  time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC),
+		Level:      "ERROR",
+		LevelKnown: true,
+	}
+
+	tests := []struct {
+		query       string
+		meta        storage.EntryMeta
+		wantMatch   bool
+		wantCertain bool
+	}{
+		{query: "*", meta: meta, wantMatch: true, wantCertain: true},
+		{query: "level:ERROR", meta: meta, wantMatch: true, wantCertain: true},
+		{query: "level:WARN", meta: meta, wantMatch: false, wantCertain: true},
+		{query: "NOT level:ERROR", meta: meta, wantMatch: false, wantCertain: true},
+		{query: "level:WARN OR level:ERR", meta: meta, wantMatch: true, wantCertain: true},
+		{query: "level:WARN AND service:api", meta: meta, wantMatch: false, wantCertain: true},
+		{query: "level:ERROR AND service:api", meta: meta, wantMatch: true, wantCertain: false},
+		{query: "NOT level:WARN", meta: meta, wantMatch: true, wantCertain: true},
+		{query: "level:ERROR", meta: storage.EntryMeta{Timestamp: meta.Timestamp}, wantMatch: true, wantCertain: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			match, certain := q.MatchMeta(tt.meta)
+			if certain != tt.wantCertain || (certain && match != tt.wantMatch) {
+				t.Fatalf("MatchMeta() = (%v, %v), want (%v, %v)", match, certain, tt.wantMatch, tt.wantCertain)
+			}
+		})
+	}
+
+	rf := &TimestampRangeFilter{Start: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)}
+	if match, certain := rf.MatchMeta(meta); match || !certain {
+		t.Fatalf("TimestampRangeFilter.MatchMeta() = (%v, %v), want (false, true)", match, certain)
+	}
+}
@@ -1,6 +1,8 @@
 package query
 
 import (
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -330,6 +332,12 @@ func TestWildcardFilter(t *testing.T) {
 			entry:  &storage.LogEntry{Level: "ERROR", Message: "test", Fields: map[string]interface{}{"service": "api-gateway"}},
 			want:   true,
 		},
+		{
+			name:   "invalid regexp never matches",
+			filter: &WildcardFilter{Field: "message", Pattern: "conn(*"},
+			entry:  &storage.LogEntry{Level: "ERROR", Message: "conn(ection", Fields: map[string]interface{}{}},
+			want:   false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -341,6 +349,117 @@ func TestWildcardFilter(t *testing.T) {
 	}
 }
 
+func TestWildcardMatcherFastPaths(t *testing.T) {
+	tests := []struct {
+		pattern string
+		kind    wildcardKind
+		value   string
+		want    bool
+	}{
+		{pattern: "*", kind: wildcardAny, value: "anything", want: true},
+		{pattern: "API*", kind: wildcardPrefix, value: "api-gateway", want: true},
+		{pattern: "api*", kind: wildcardPrefix, value: "my-api", want: false},
+		{pattern: "*Timeout", kind: wildcardSuffix, value: "connection TIMEOUT", want: true},
+		{pattern: "*time*", kind: wildcardContains, value: "runtime error", want: true},
+		{pattern: "*panic*", kind: wildcardContains, value: "goroutine 1\npanic: boom", want: true},
+		{pattern: "a*b", kind: wildcardRegexp, value: "a-to-b", want: true},
+		{pattern: "*a*b*", kind: wildcardRegexp, value: "xaxbx", want: true},
+		{pattern: "v1.2*", kind: wildcardRegexp, value: "v1x2.0", want: true}, // "." keeps its regexp meaning
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			m := newWildcardMatcher(tt.pattern)
+			if m.kind != tt.kind {
+				t.Fatalf("kind = %d, want %d", m.kind, tt.kind)
+			}
+			if got := m.match(tt.value); got != tt.want {
+				t.Fatalf("match(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	tests := []struct {
+		input   string
+		wantPos int
+	}{
+		{input: "level:ERROR AND", wantPos: 15},
+		{input: "(level:ERROR", wantPos: 12},
+		{input: "level:ERROR )", wantPos: 12},
+		{input: "status:[500]", wantPos: 0},
+		{input: "level:ERROR AND status:[500]", wantPos: 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			var pe *ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("Parse() error = %v, want *ParseError", err)
+			}
+			if pe.Pos != tt.wantPos {
+				t.Fatalf("Pos = %d, want %d (%v)", pe.Pos, tt.wantPos, err)
+			}
+		})
+	}
+}
+
+func TestParseComplexityLimits(t *testing.T) {
+	terms := func(n int) string {
+		return strings.TrimSpace(strings.Repeat("a ", n))
+	}
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{name: "clauses at limit", input: terms(MaxClauses)},
+		{name: "too many clauses", input: terms(MaxClauses + 1), wantErr: "clauses"},
+		{name: "nesting at limit", input: strings.Repeat("(", MaxNesting) + "a" + strings.Repeat(")", MaxNesting)},
+		{name: "nested too deep", input: strings.Repeat("(", MaxNesting+1) + "a" + strings.Repeat(")", MaxNesting+1), wantErr: "nested"},
+		{name: "pattern at limit", input: "msg:" + strings.Repeat("x", MaxPatternLength-1) + "*"},
+		{name: "pattern too long", input: "msg:" + strings.Repeat("x", MaxPatternLength) + "*", wantErr: "wildcard pattern"},
+		{name: "query too long", input: "msg:" + strings.Repeat("x", MaxQueryLength), wantErr: "longer than"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Parse() error = %v, want nil", err)
+				}
+				return
+			}
+			var pe *ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("Parse() error = %v, want *ParseError", err)
+			}
+			if !strings.Contains(pe.Msg, tt.wantErr) {
+				t.Fatalf("Parse() error = %q, want it to mention %q", pe.Msg, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseSharesCompiledWildcards(t *testing.T) {
+	first, err := Parse("service:api*")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	second, err := Parse("service:api*")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	a, ok := first.filters[0].(*WildcardFilter)
+	b, ok2 := second.filters[0].(*WildcardFilter)
+	if !ok || !ok2 {
+		t.Fatalf("Parse() filters = %T, %T; want *WildcardFilter", first.filters[0], second.filters[0])
+	}
+	if a.matcher == nil || a.matcher != b.matcher {
+		t.Fatalf("compiled matchers %p and %p, want one shared matcher", a.matcher, b.matcher)
+	}
+}
+
 func TestTimestampRangeFilter(t *testing.T) {
 	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
 	mid := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
@@ -658,3 +777,100 @@ func TestAllFilter(t *testing.T) {
 		t.Error("AllFilter should match all entries")
 	}
 }
+
+func TestTraceContextFields(t *testing.T) {
+	promoted := &storage.LogEntry{Message: "a", TraceID: "4bf92f3577b34da6", SpanID: "00f067aa0ba902b7"}
+	legacy := &storage.LogEntry{Message: "b", Fields: map[string]interface{}{"trace_id": "4bf92f3577b34da6", "span_id": "00f067aa0ba902b7"}}
+	other := &storage.LogEntry{Message: "c", TraceID: "aaaa"}
+
+	tests := []struct {
+		query     string
+		want      []bool // promoted, legacy, other
+		wantTrace string
+	}{
+		{query: `trace_id:"4bf92f3577b34da6"`, want: []bool{true, true, false}, wantTrace: "4bf92f3577b34da6"},
+		{query: `trace_id:"4bf92f3577b34da6" AND level:ERROR`, want: []bool{false, false, false}, wantTrace: "4bf92f3577b34da6"},
+		{query: `trace_id:4bf92f`, want: []bool{true, true, false}},
+		{query: `span_id:00f067aa0ba902b7`, want: []bool{true, true, false}},
+		{query: `trace_id:"aaaa" OR trace_id:"4bf92f3577b34da6"`, want: []bool{true, true, true}},
+		{query: `4bf92f3577b34da6`, want: []bool{true, true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			for i, entry := range []*storage.LogEntry{promoted, legacy, other} {
+				if got := q.Match(entry); got != tt.want[i] {
+					t.Errorf("Match(%s) = %v, want %v", entry.Message, got, tt.want[i])
+				}
+			}
+			field, id, ok := q.IndexLookup()
+			if id != tt.wantTrace || ok != (tt.wantTrace != "") || (ok && field != "trace_id") {
+				t.Errorf("IndexLookup() = %q, %q, %v, want trace_id %q", field, id, ok, tt.wantTrace)
+			}
+		})
+	}
+}
+
+func TestSourceField(t *testing.T) {
+	collected := &storage.LogEntry{Message: "a", Source: "/var/log/app.log", Fields: map[string]interface{}{"source": "db"}}
+	legacy := &storage.LogEntry{Message: "b", Fields: map[string]interface{}{"source": "/var/log/app.log"}}
+
+	tests := []struct {
+		query   string
+		want    []bool // collected, legacy
+		indexed bool
+	}{
+		{query: `source:"/var/log/app.log"`, want: []bool{true, true}, indexed: true},
+		{query: `source:"db"`, want: []bool{false, false}, indexed: true},
+		{query: `source:app`, want: []bool{true, true}},
+		{query: `source:*app*`, want: []bool{true, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			for i, entry := range []*storage.LogEntry{collected, legacy} {
+				if got := q.Match(entry); got != tt.want[i] {
+					t.Errorf("Match(%s) = %v, want %v", entry.Message, got, tt.want[i])
+				}
+			}
+			if field, _, ok := q.IndexLookup(); ok != tt.indexed || (ok && field != "source") {
+				t.Errorf("IndexLookup() = %q, %v, want indexed %v", field, ok, tt.indexed)
+			}
+		})
+	}
+}
+
+func TestHostFields(t *testing.T) {
+	entry := &storage.LogEntry{Message: "a", Host: &storage.HostInfo{Name: "web-1", OS: "linux", User: "deploy"}}
+	bare := &storage.LogEntry{Message: "b", Fields: map[string]interface{}{"user": "deploy"}}
+
+	tests := []struct {
+		query string
+		want  []bool // entry, bare
+	}{
+		{query: `host.name:"web-1"`, want: []bool{true, false}},
+		{query: `host.os:linux AND host.user:deploy`, want: []bool{true, false}},
+		{query: `host.name:web*`, want: []bool{true, false}},
+		{query: `user:deploy`, want: []bool{false, true}},
+		{query: `web-1`, want: []bool{true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			for i, e := range []*storage.LogEntry{entry, bare} {
+				if got := q.Match(e); got != tt.want[i] {
+					t.Errorf("Match(%s) = %v, want %v", e.Message, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,446 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Grammar, loosest binding first:
+//
+//	or      = and { "OR" and }
+//	and     = not { ["AND"] not }
+//	not     = "NOT" not | primary
+//	primary = "(" or ")" | term
+//
+// AND, OR and NOT are operators only as whole, unquoted words, so terms such
+// as NOTICE or ORDER are plain keywords.
+
+// tokenKind classifies lexer tokens.
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokLParen
+	tokRParen
+	tokAnd
+	tokOr
+	tokNot
+	tokTerm
+)
+
+// operators maps the operator words to their tokens.
+var operators = map[string]tokenKind{"AND": tokAnd, "OR": tokOr, "NOT": tokNot}
+
+// token is one lexeme of the query. For terms, text is the raw word and
+// colon the offset of its first unquoted ':' (-1 without one).
+type token struct {
+	kind  tokenKind
+	pos   int
+	text  string
+	colon int
+}
+
+// parser implements a recursive-descent Lucene query parser over the tokens
+// of input.
+type parser struct {
+	input   string
+	tokens  []token
+	next    int // index of the current token
+	clauses int // terms parsed so far, bounded by MaxClauses
+	depth   int // open parentheses, bounded by MaxNesting
+}
+
+// errorf returns a ParseError at pos.
+func (p *parser) errorf(pos int, format string, args ...interface{}) error {
+	return &ParseError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// ParseAST parses a Lucene-style query string into its expression tree.
+// Errors are *ParseError, as from Parse.
+func ParseAST(queryStr string) (Node, error) {
+	if queryStr == "" || queryStr == "*" {
+		return AllNode{}, nil
+	}
+	if len(queryStr) > MaxQueryLength {
+		return nil, &ParseError{Pos: MaxQueryLength, Msg: fmt.Sprintf("query is longer than %d bytes", MaxQueryLength)}
+	}
+	p := &parser{input: queryStr}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorf(tok.pos, "unexpected token near %q", p.input[tok.pos:])
+	}
+	return n, nil
+}
+
+// isSpace reports whether ch separates tokens.
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
+
+// lex splits the input into tokens, ending with tokEOF.
+func (p *parser) lex() error {
+	in := p.input
+	pos := 0
+	for {
+		for pos < len(in) && isSpace(in[pos]) {
+			pos++
+		}
+		if pos == len(in) {
+			p.tokens = append(p.tokens, token{kind: tokEOF, pos: pos})
+			return nil
+		}
+		switch in[pos] {
+		case '(':
+			p.tokens = append(p.tokens, token{kind: tokLParen, pos: pos})
+			pos++
+			continue
+		case ')':
+			p.tokens = append(p.tokens, token{kind: tokRParen, pos: pos})
+			pos++
+			continue
+		}
+
+		tok, err := p.lexWord(pos)
+		if err != nil {
+			return err
+		}
+		pos += len(tok.text)
+		p.tokens = append(p.tokens, tok)
+	}
+}
+
+// lexWord reads the term or operator starting at start. Quoted strings may
+// hold spaces and parentheses, and so may a range directly after the
+// field's ':'.
+func (p *parser) lexWord(start int) (token, error) {
+	in := p.input
+	tok := token{kind: tokTerm, pos: start, colon: -1}
+	pos := start
+	for pos < len(in) && !isSpace(in[pos]) && in[pos] != '(' && in[pos] != ')' {
+		switch {
+		case in[pos] == '"':
+			end := closingQuote(in, pos)
+			if end < 0 {
+				return token{}, p.errorf(pos, "unterminated quoted string")
+			}
+			pos = end + 1
+		case in[pos] == '[' && (pos == start || tok.colon == pos-start-1):
+			end := strings.IndexByte(in[pos:], ']')
+			if end < 0 {
+				return token{}, p.errorf(pos, "unterminated range")
+			}
+			pos += end + 1
+		default:
+			if in[pos] == ':' && tok.colon < 0 {
+				tok.colon = pos - start
+			}
+			pos++
+		}
+	}
+	tok.text = in[start:pos]
+	if kind, ok := operators[tok.text]; ok {
+		tok.kind = kind
+	}
+	return tok, nil
+}
+
+// closingQuote returns the index of the quote closing the string opened at
+// in[open], skipping backslash escapes, or -1.
+func closingQuote(in string, open int) int {
+	for i := open + 1; i < len(in); i++ {
+		switch in[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// unquote returns the contents of s when s is exactly one quoted string.
+// \" and \\ are escapes; other backslashes are kept.
+func unquote(s string) (string, bool) {
+	if len(s) < 2 || s[0] != '"' || closingQuote(s, 0) != len(s)-1 {
+		return "", false
+	}
+	var b strings.Builder
+	for i := 1; i < len(s)-1; i++ {
+		if s[i] == '\\' && (s[i+1] == '"' || s[i+1] == '\\') {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String(), true
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	tok := p.tokens[p.next]
+	if tok.kind != tokEOF {
+		p.next++
+	}
+	return tok
+}
+
+func (p *parser) parseOr() (Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []Node{left}
+	for p.peek().kind == tokOr {
+		p.advance()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return &OrNode{Children: flatten(children, orChildren)}, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	children := []Node{left}
+	for {
+		switch p.peek().kind {
+		case tokAnd:
+			p.advance()
+		case tokTerm, tokNot, tokLParen:
+			// Implicit AND
+		default:
+			if len(children) == 1 {
+				return left, nil
+			}
+			return &AndNode{Children: flatten(children, andChildren)}, nil
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+}
+
+// flatten splices the children of parenthesized groups of the same operator,
+// so (a AND b) AND c is one AND of three terms.
+func flatten(children []Node, same func(Node) []Node) []Node {
+	out := make([]Node, 0, len(children))
+	for _, c := range children {
+		if grouped := same(c); grouped != nil {
+			out = append(out, grouped...)
+		} else {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func andChildren(n Node) []Node {
+	if a, ok := n.(*AndNode); ok {
+		return a.Children
+	}
+	return nil
+}
+
+func orChildren(n Node) []Node {
+	if o, ok := n.(*OrNode); ok {
+		return o.Children
+	}
+	return nil
+}
+
+func (p *parser) parseNot() (Node, error) {
+	if p.peek().kind == tokNot {
+		p.advance()
+		child, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &NotNode{Child: child}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	tok := p.advance()
+	switch tok.kind {
+	case tokLParen:
+		p.depth++
+		if p.depth > MaxNesting {
+			return nil, p.errorf(tok.pos+1, "query is nested deeper than %d levels", MaxNesting)
+		}
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.depth--
+		if closing := p.advance(); closing.kind != tokRParen {
+			return nil, p.errorf(closing.pos, "expected closing parenthesis")
+		}
+		return n, nil
+	case tokTerm:
+		return p.parseTerm(tok)
+	case tokEOF:
+		return nil, p.errorf(tok.pos, "unexpected end of query")
+	}
+	return nil, p.errorf(tok.pos, "unexpected token near %q", p.input[tok.pos:])
+}
+
+// parseTerm interprets a term token: a keyword, or field:value where value
+// is plain, "quoted", a wildcard pattern or a [low TO high] range.
+func (p *parser) parseTerm(tok token) (Node, error) {
+	p.clauses++
+	if p.clauses > MaxClauses {
+		return nil, p.errorf(tok.pos, "query has more than %d clauses", MaxClauses)
+	}
+
+	if tok.colon < 0 {
+		if tok.text[0] != '"' {
+			return &TermNode{Kind: TermKeyword, Value: tok.text}, nil
+		}
+		keyword, ok := unquote(tok.text)
+		if !ok {
+			return nil, p.errorf(tok.pos, "unexpected text after quoted string")
+		}
+		return &TermNode{Kind: TermKeyword, Value: keyword}, nil
+	}
+
+	field, value := tok.text[:tok.colon], tok.text[tok.colon+1:]
+	switch {
+	case strings.HasPrefix(value, "["):
+		low, high, ok := splitRange(value)
+		if !ok {
+			return nil, p.errorf(tok.pos, "invalid range format")
+		}
+		return &TermNode{Kind: TermRange, Field: field, Low: low, High: high}, nil
+	case strings.HasPrefix(value, `"`):
+		phrase, ok := unquote(value)
+		if !ok {
+			return nil, p.errorf(tok.pos, "unexpected text after quoted string")
+		}
+		return &TermNode{Kind: TermPhrase, Field: field, Value: phrase}, nil
+	case strings.Contains(value, "*"):
+		if len(value) > MaxPatternLength {
+			return nil, p.errorf(tok.pos, "wildcard pattern is longer than %d bytes", MaxPatternLength)
+		}
+		return &TermNode{Kind: TermWildcard, Field: field, Value: value}, nil
+	}
+	return &TermNode{Kind: TermMatch, Field: field, Value: value}, nil
+}
+
+// splitRange splits "[low TO high]" into its bounds.
+func splitRange(value string) (low, high string, ok bool) {
+	inner, ok := strings.CutSuffix(strings.TrimPrefix(value, "["), "]")
+	if !ok {
+		return "", "", false
+	}
+	parts := strings.Split(inner, " TO ")
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), true
+}
+
+// reDay matches a number followed by 'd' (days), e.g. "7d".
+var reDay = regexp.MustCompile(`(\d+)d`)
+
+// reWeek matches a number followed by 'w' (weeks), e.g. "2w".
+var reWeek = regexp.MustCompile(`(\d+)w`)
+
+// parseDurationExtended extends time.ParseDuration to support day ('d') and
+// week ('w') units by converting them to hours before parsing.
+func parseDurationExtended(s string) (time.Duration, error) {
+	s = reDay.ReplaceAllStringFunc(s, func(m string) string {
+		n, _ := strconv.Atoi(m[:len(m)-1])
+		return fmt.Sprintf("%dh", n*24)
+	})
+	s = reWeek.ReplaceAllStringFunc(s, func(m string) string {
+		n, _ := strconv.Atoi(m[:len(m)-1])
+		return fmt.Sprintf("%dh", n*7*24)
+	})
+	return time.ParseDuration(s)
+}
+
+// defaultLocation is where time values without a zone are read; nil reads
+// them in UTC.
+var defaultLocation atomic.Pointer[time.Location]
+
+// SetDefaultLocation sets where queries read time values that carry no
+// zone, such as timestamp:[2026-01-02 TO 2026-01-03] ([parsing]
+// default_timezone). nil restores UTC.
+func SetDefaultLocation(loc *time.Location) {
+	defaultLocation.Store(loc)
+}
+
+// location returns where time values without a zone are read.
+func location() *time.Location {
+	if loc := defaultLocation.Load(); loc != nil {
+		return loc
+	}
+	return time.UTC
+}
+
+func (p *parser) parseTimeValue(val string) time.Time {
+	// Handle relative time (e.g., now-1h, now-7d, now-2w)
+	if strings.HasPrefix(val, "now") {
+		duration := strings.TrimPrefix(val, "now")
+		if duration == "" {
+			return time.Now()
+		}
+		duration = strings.TrimPrefix(duration, "-")
+		if d, err := parseDurationExtended(duration); err == nil {
+			return time.Now().Add(-d)
+		}
+	}
+
+	// Parse absolute RFC3339 timestamp
+	if t, err := time.Parse(time.RFC3339, val); err == nil {
+		return t
+	}
+
+	// Parse datetime without timezone (in the default location)
+	if t, err := time.ParseInLocation("2006-01-02T15:04:05", val, location()); err == nil {
+		return t
+	}
+
+	// Parse date-only string (start of day in the default location)
+	if t, err := time.ParseInLocation("2006-01-02", val, location()); err == nil {
+		return t
+	}
+
+	// Parse epoch milliseconds (values > 1e12 are clearly milliseconds, not seconds)
+	if ms, err := strconv.ParseInt(val, 10, 64); err == nil && ms > 1_000_000_000_000 {
+		return time.Unix(0, ms*int64(time.Millisecond)).UTC()
+	}
+
+	return time.Time{}
+}
+
+func (p *parser) parseNumericValue(val string) float64 {
+	if f, err := strconv.ParseFloat(val, 64); err == nil {
+		return f
+	}
+	return 0
+}
@@ -0,0 +1,170 @@
+package query
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// dump renders n fully parenthesized, operator first, so tests can assert
+// the tree shape.
+func dump(n Node) string {
+	switch n := n.(type) {
+	case *AndNode:
+		return "(AND " + dumpAll(n.Children) + ")"
+	case *OrNode:
+		return "(OR " + dumpAll(n.Children) + ")"
+	case *NotNode:
+		return "(NOT " + dump(n.Child) + ")"
+	}
+	return n.String()
+}
+
+func dumpAll(nodes []Node) string {
+	parts := make([]string, len(nodes))
+	for i, n := range nodes {
+		parts[i] = dump(n)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestParsePrecedence(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "a OR b AND c", want: "(OR a (AND b c))"},
+		{query: "a AND b OR c", want: "(OR (AND a b) c)"},
+		{query: "a OR b c", want: "(OR a (AND b c))"},
+		{query: "a b OR c d", want: "(OR (AND a b) (AND c d))"},
+		{query: "(a OR b) AND c", want: "(AND (OR a b) c)"},
+		{query: "a AND (b AND c)", want: "(AND a b c)"},
+		{query: "a OR (b OR c) OR d", want: "(OR a b c d)"},
+		{query: "NOT a AND b", want: "(AND (NOT a) b)"},
+		{query: "NOT a OR b", want: "(OR (NOT a) b)"},
+		{query: "NOT (a OR b)", want: "(NOT (OR a b))"},
+		{query: "(NOT a OR NOT (b AND NOT c))", want: "(OR (NOT a) (NOT (AND b (NOT c))))"},
+		{query: "NOT NOT a", want: "(NOT (NOT a))"},
+		{query: "a AND NOT NOT b", want: "(AND a (NOT (NOT b)))"},
+		{query: "NOT(a)", want: "(NOT a)"},
+		{query: "((a))", want: "a"},
+		{query: "NOTICE ORDER ANDROID", want: "(AND NOTICE ORDER ANDROID)"},
+		{query: `"NOT" OR "a b"`, want: `(OR "NOT" "a b")`},
+		{query: `level:ERROR OR message:"conn (refused)"`, want: `(OR level:ERROR message:"conn (refused)")`},
+		{query: "status:[400 TO 499] AND service:api*", want: "(AND status:[400 TO 499] service:api*)"},
+		{query: "a\tOR\nb", want: "(OR a b)"},
+		{query: `msg:"say \"hi\""`, want: `msg:"say \"hi\""`},
+		{query: "*", want: "*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			n, err := ParseAST(tt.query)
+			if err != nil {
+				t.Fatalf("ParseAST() error = %v", err)
+			}
+			if got := dump(n); got != tt.want {
+				t.Fatalf("ParseAST() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTerms(t *testing.T) {
+	entry := &storage.LogEntry{
+		Level:   "NOTICE",
+		Message: `ORDER connection refused (retrying) after "dial"`,
+		Fields:  map[string]interface{}{"status": 503, "path": `C:\logs`},
+	}
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{query: "ORDER", want: true},
+		{query: "level:NOTICE AND NOT level:ERROR", want: true},
+		{query: `message:"ORDER connection refused (retrying) after \"dial\""`, want: true},
+		{query: `"refused (retrying)"`, want: true},
+		{query: "status:[500 TO 599]", want: true},
+		{query: "status:[400 TO 499]", want: false},
+		{query: `path:"C:\logs"`, want: true},
+		{query: `path:"C:\\logs"`, want: true},
+		{query: "NOT NOT level:NOTICE", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := q.Match(entry); got != tt.want {
+				t.Fatalf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		wantPos int
+		wantMsg string
+	}{
+		{input: "AND a", wantPos: 0, wantMsg: "unexpected token"},
+		{input: "a OR", wantPos: 4, wantMsg: "unexpected end"},
+		{input: "NOT", wantPos: 3, wantMsg: "unexpected end"},
+		{input: "()", wantPos: 1, wantMsg: "unexpected token"},
+		{input: `msg:"open`, wantPos: 4, wantMsg: "unterminated quoted string"},
+		{input: `status:[1 TO 2`, wantPos: 7, wantMsg: "unterminated range"},
+		{input: `msg:"a"b`, wantPos: 0, wantMsg: "after quoted string"},
+		{input: `a (b c`, wantPos: 6, wantMsg: "closing parenthesis"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			var pe *ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("Parse() error = %v, want *ParseError", err)
+			}
+			if pe.Pos != tt.wantPos || !strings.Contains(pe.Msg, tt.wantMsg) {
+				t.Fatalf("Parse() error = %q at %d, want %q at %d", pe.Msg, pe.Pos, tt.wantMsg, tt.wantPos)
+			}
+		})
+	}
+}
+
+// FuzzParse checks that parsing never panics, reports errors inside the
+// query, and that the printed form of a parsed query parses to the same
+// tree.
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{
+		"level:ERROR",
+		"a OR b AND c",
+		"NOT (a OR NOT b) c",
+		`message:"conn \"refused\"" OR "x y"`,
+		"status:[400 TO 499] service:api*",
+		"((a) OR (b AND (c)))",
+		`"[x" AND NOTICE`,
+		"a:b:c :x y: AND",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		n, err := ParseAST(input)
+		if err != nil {
+			var pe *ParseError
+			if !errors.As(err, &pe) || pe.Pos < 0 || pe.Pos > len(input) {
+				t.Fatalf("ParseAST(%q) error = %v, want *ParseError within the query", input, err)
+			}
+			return
+		}
+		printed := n.String()
+		again, err := ParseAST(printed)
+		if err != nil {
+			t.Fatalf("ParseAST(%q) of printed %q error = %v", input, printed, err)
+		}
+		if dump(again) != dump(n) {
+			t.Fatalf("ParseAST(%q) = %s, printed %q parses to %s", input, dump(n), printed, dump(again))
+		}
+	})
+}
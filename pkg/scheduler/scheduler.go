@@ -0,0 +1,137 @@
+// Package scheduler runs scheduled queries on their intervals and records
+// match counts as time series in storage, along with periodic snapshots of
+// the storage stats for /stats/history.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/query"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// DefaultTick is how often the runner checks for due queries.
+const DefaultTick = 5 * time.Second
+
+// Runner evaluates due scheduled queries on every tick.
+type Runner struct {
+	storage *storage.BadgerStorage
+	tick    time.Duration
+	now     func() time.Time
+	running sync.WaitGroup
+	// lastStats is when the last stats snapshot was recorded.
+	lastStats time.Time
+}
+
+// New creates a Runner that checks for due queries every tick.
+func New(db *storage.BadgerStorage, tick time.Duration) *Runner {
+	if tick <= 0 {
+		tick = DefaultTick
+	}
+	return &Runner{storage: db, tick: tick, now: time.Now}
+}
+
+// Start runs the scheduler in the background until ctx is cancelled.
+func (r *Runner) Start(ctx context.Context) {
+	r.running.Add(1)
+	go func() {
+		defer r.running.Done()
+		ticker := time.NewTicker(r.tick)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := r.RunDue(ctx); err != nil {
+					log.Printf("Scheduled queries: %v", err)
+				}
+				if err := r.RecordStats(); err != nil {
+					log.Printf("Stats history: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Wait blocks until a started Runner has stopped after its context was
+// cancelled, so a query run in progress finishes before storage closes.
+func (r *Runner) Wait() {
+	r.running.Wait()
+}
+
+// RunDue runs every scheduled query whose interval has elapsed since its
+// last run. A failing query is logged and does not stop the others.
+func (r *Runner) RunDue(ctx context.Context) error {
+	qs, err := r.storage.ListScheduledQueries()
+	if err != nil {
+		return err
+	}
+
+	now := r.now()
+	for i := range qs {
+		q := &qs[i]
+		interval, err := time.ParseDuration(q.Interval)
+		if err != nil {
+			log.Printf("Scheduled query %s: invalid interval %q", q.Name, q.Interval)
+			continue
+		}
+		if !q.LastRun.IsZero() && now.Sub(q.LastRun) < interval {
+			continue
+		}
+		if err := r.run(ctx, q, now, interval); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("Scheduled query %s: %v", q.Name, err)
+		}
+	}
+	return nil
+}
+
+// RecordStats records a stats snapshot unless one was already recorded in
+// the current storage.StatsHistoryInterval.
+func (r *Runner) RecordStats() error {
+	now := r.now()
+	if now.Truncate(storage.StatsHistoryInterval).Equal(r.lastStats.Truncate(storage.StatsHistoryInterval)) {
+		return nil
+	}
+	if err := r.storage.RecordStatsSnapshot(now); err != nil {
+		return err
+	}
+	r.lastStats = now
+	return nil
+}
+
+// run counts matches of q in the interval ending at now, within its
+// namespace when it has one, and records them.
+func (r *Runner) run(ctx context.Context, q *storage.ScheduledQuery, now time.Time, interval time.Duration) error {
+	parsed, err := query.Parse(q.Query)
+	if err != nil {
+		return fmt.Errorf("parse query: %w", err)
+	}
+
+	// Windows are (now-interval, now] so consecutive runs never double count.
+	start := now.Add(-interval).Add(time.Nanosecond)
+	var filter query.Filter = &query.AndFilter{
+		Left:  parsed,
+		Right: &query.TimestampRangeFilter{Start: start, End: now},
+	}
+	// A query saved in a namespace only counts that namespace's entries.
+	if q.Namespace != "" {
+		filter = &query.AndFilter{Left: filter, Right: &query.NamespaceFilter{Namespace: q.Namespace}}
+	}
+	_, count, err := r.storage.QueryContext(ctx, filter, storage.QueryOptions{
+		TimeRange: &storage.TimeRange{Start: start, End: now},
+	})
+	if err != nil {
+		return fmt.Errorf("count matches: %w", err)
+	}
+
+	return r.storage.RecordScheduledResult(q.Name, now, count)
+}
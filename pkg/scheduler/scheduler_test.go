@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+func TestRunDue(t *testing.T) {
+	db, err := storage.NewBadgerStorage(storage.Config{DBPath: t.TempDir(), RetentionSize: 1024 * 1024 * 100, RetentionDays: 30})
+	if err != nil {
+		t.Fatalf("NewBadgerStorage() error = %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	now := time.Now().UTC().Truncate(time.Second)
+	entries := []*storage.LogEntry{
+		{ID: "1", Timestamp: now.Add(-30 * time.Second), Level: "ERROR", Message: "a", Namespace: "alice"},
+		{ID: "2", Timestamp: now.Add(-10 * time.Second), Level: "ERROR", Message: "b"},
+		{ID: "3", Timestamp: now.Add(-10 * time.Second), Level: "INFO", Message: "c"},
+		{ID: "4", Timestamp: now.Add(-2 * time.Minute), Level: "ERROR", Message: "old"},
+	}
+	if err := db.StoreBatch(entries); err != nil {
+		t.Fatalf("StoreBatch() error = %v", err)
+	}
+	if err := db.SaveScheduledQuery(&storage.ScheduledQuery{Name: "errors", Query: "level:ERROR", Interval: "1m"}); err != nil {
+		t.Fatalf("SaveScheduledQuery() error = %v", err)
+	}
+	if err := db.SaveScheduledQuery(&storage.ScheduledQuery{Name: "alice-errors", Query: "level:ERROR", Interval: "1m", Namespace: "alice"}); err != nil {
+		t.Fatalf("SaveScheduledQuery() error = %v", err)
+	}
+
+	r := New(db, 0)
+	clock := now
+	r.now = func() time.Time { return clock }
+	ctx := context.Background()
+
+	if err := r.RunDue(ctx); err != nil {
+		t.Fatalf("RunDue() error = %v", err)
+	}
+	q, err := db.GetScheduledQuery("errors")
+	if err != nil || q.LastCount != 2 || !q.LastRun.Equal(now) {
+		t.Fatalf("GetScheduledQuery() = %+v, %v, want 2 matches at %s", q, err, now)
+	}
+	// A namespaced query only counts its namespace's entries.
+	if q, err := db.GetScheduledQuery("alice-errors"); err != nil || q.LastCount != 1 {
+		t.Fatalf("GetScheduledQuery(alice-errors) = %+v, %v, want 1 match", q, err)
+	}
+
+	// Not due again until the interval has elapsed.
+	clock = now.Add(30 * time.Second)
+	if err := r.RunDue(ctx); err != nil {
+		t.Fatalf("RunDue() error = %v", err)
+	}
+	if points, _ := db.GetSeries("errors", nil); len(points) != 1 {
+		t.Fatalf("GetSeries() = %+v, want 1 point before interval elapses", points)
+	}
+
+	clock = now.Add(time.Minute)
+	if err := r.RunDue(ctx); err != nil {
+		t.Fatalf("RunDue() error = %v", err)
+	}
+	points, err := db.GetSeries("errors", nil)
+	if err != nil || len(points) != 2 || points[1].Count != 0 {
+		t.Fatalf("GetSeries() = %+v, %v, want second point with 0 matches", points, err)
+	}
+}
+
+func TestRecordStats(t *testing.T) {
+	db, err := storage.NewBadgerStorage(storage.Config{DBPath: t.TempDir(), RetentionSize: 1024 * 1024 * 100, RetentionDays: 30})
+	if err != nil {
+		t.Fatalf("NewBadgerStorage() error = %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	r := New(db, 0)
+	start := time.Now().UTC().Truncate(storage.StatsHistoryInterval)
+	clock := start
+	r.now = func() time.Time { return clock }
+
+	// One snapshot per interval, however often the runner ticks.
+	for _, offset := range []time.Duration{0, time.Second, time.Minute, storage.StatsHistoryInterval, storage.StatsHistoryInterval + time.Minute} {
+		clock = start.Add(offset)
+		if err := r.RecordStats(); err != nil {
+			t.Fatalf("RecordStats() error = %v", err)
+		}
+	}
+	snaps, err := db.StatsHistory(start, clock)
+	if err != nil || len(snaps) != 2 || !snaps[0].Timestamp.Equal(start) || !snaps[1].Timestamp.Equal(start.Add(storage.StatsHistoryInterval)) {
+		t.Fatalf("StatsHistory() = %+v, %v, want snapshots at %s and %s", snaps, err, start, start.Add(storage.StatsHistoryInterval))
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// SetReloader enables POST /admin/reload, which calls reload to re-read the
+// parsing config of the running process. Nil disables the endpoint.
+func (s *Server) SetReloader(reload func() error) {
+	s.reload = reload
+}
+
+// handleReload handles POST /admin/reload. When authentication is enabled
+// only admin tokens may reload.
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if p := principalFrom(r.Context()); p != nil && !p.admin {
+		writeError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if s.reload == nil {
+		writeError(w, "Reload is not available", http.StatusNotFound)
+		return
+	}
+
+	if err := s.reload(); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"reloaded": true})
+}
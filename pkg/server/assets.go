@@ -0,0 +1,162 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed index.html van.min.js
+var embeddedUI embed.FS
+
+// assetsPath is where every file of the UI bundle except index.html is
+// served.
+const assetsPath = "/assets/"
+
+// uiAssets is a web UI bundle: index.html, served at /, and the files next
+// to it, served under /assets/. In an embedded bundle, "/assets/NAME" URLs
+// quoted in index.html get a ?v= content hash, so browsers keep the files
+// until the binary changes. A directory bundle (--ui-dir) is read on every
+// request and never cached, for front-end development.
+type uiAssets struct {
+	fsys fs.FS
+	dev  bool
+
+	once   sync.Once
+	bundle *uiBundle
+	err    error
+}
+
+// uiBundle is what the UI page needs from a bundle.
+type uiBundle struct {
+	index []byte
+	// hashes maps asset names to their content hash; empty for --ui-dir.
+	hashes map[string]string
+	// scripts are the CSP hash sources of index.html's inline scripts.
+	scripts []string
+}
+
+func newUIAssets(fsys fs.FS, dev bool) *uiAssets {
+	return &uiAssets{fsys: fsys, dev: dev}
+}
+
+// load reads index.html and hashes the assets, once for an embedded bundle.
+func (u *uiAssets) load() (*uiBundle, error) {
+	if u.dev {
+		index, err := fs.ReadFile(u.fsys, "index.html")
+		if err != nil {
+			return nil, err
+		}
+		return &uiBundle{index: index, scripts: scriptHashes(index)}, nil
+	}
+	u.once.Do(func() {
+		u.bundle, u.err = hashAssets(u.fsys)
+	})
+	return u.bundle, u.err
+}
+
+// hashAssets reads index.html with its asset URLs versioned, and the
+// version of each asset.
+func hashAssets(fsys fs.FS) (*uiBundle, error) {
+	index, err := fs.ReadFile(fsys, "index.html")
+	if err != nil {
+		return nil, err
+	}
+	hashes := make(map[string]string)
+	var urls []string
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || name == "index.html" {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		hashes[name] = hex.EncodeToString(sum[:6])
+		url := assetsPath + name
+		urls = append(urls, `"`+url+`"`, `"`+url+"?v="+hashes[name]+`"`)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	index = []byte(strings.NewReplacer(urls...).Replace(string(index)))
+	return &uiBundle{index: index, hashes: hashes, scripts: scriptHashes(index)}, nil
+}
+
+// SetUIDir serves the web UI from dir instead of the embedded bundle. dir
+// must contain index.html; its other files are served under /assets/. An
+// empty dir restores the embedded bundle.
+func (s *Server) SetUIDir(dir string) error {
+	if dir == "" {
+		s.ui = newUIAssets(embeddedUI, false)
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(dir, "index.html")); err != nil {
+		return fmt.Errorf("ui dir: %w", err)
+	}
+	s.ui = newUIAssets(os.DirFS(dir), true)
+	return nil
+}
+
+// handleIndex serves the web UI
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	bundle, err := s.ui.load()
+	if err != nil {
+		writeError(w, fmt.Sprintf("Web UI unavailable: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	// The asset versions live in the page, so it is always revalidated.
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Security-Policy", s.contentSecurityPolicy(bundle.scripts))
+	if len(s.security.FrameAncestors) == 0 && s.security.ContentSecurityPolicy == "" {
+		// For browsers without CSP frame-ancestors.
+		w.Header().Set("X-Frame-Options", "DENY")
+	}
+	w.Write(bundle.index)
+}
+
+// handleAsset handles GET /assets/NAME: a file of the UI bundle. Requests
+// for the current ?v= version may be cached for good.
+func (s *Server) handleAsset(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, assetsPath)
+	cache := "no-cache"
+	if bundle, err := s.ui.load(); err == nil && bundle.hashes[name] != "" && r.URL.Query().Get("v") == bundle.hashes[name] {
+		cache = "public, max-age=31536000, immutable"
+	}
+	s.serveAsset(w, r, name, cache)
+}
+
+// handleVanJS serves the bundled VanJS library at its pre-/assets/ path
+func (s *Server) handleVanJS(w http.ResponseWriter, r *http.Request) {
+	s.serveAsset(w, r, "van.min.js", "max-age=86400")
+}
+
+// serveAsset writes the bundle file name, or 404 if there is none.
+func (s *Server) serveAsset(w http.ResponseWriter, r *http.Request, name, cache string) {
+	if !fs.ValidPath(name) || name == "." {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := fs.ReadFile(s.ui.fsys, name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if s.ui.dev {
+		cache = "no-cache"
+	}
+	w.Header().Set("Cache-Control", cache)
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+}
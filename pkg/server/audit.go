@@ -0,0 +1,60 @@
+package server
+
+import (
+	"log"
+	"net"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// SetAuditRetention records every query run through /query and live-tail
+// subscriptions, keeping each record for retention; zero disables the audit
+// log.
+func (s *Server) SetAuditRetention(retention time.Duration) {
+	s.auditRetention = retention
+}
+
+// audit records a query run by caller p (nil without auth) from remoteAddr.
+// Failures are logged; they never fail the query.
+func (s *Server) audit(p *principal, remoteAddr string, rec storage.AuditRecord) {
+	if s.auditRetention <= 0 {
+		return
+	}
+	rec.Client = remoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		rec.Client = host
+	}
+	if p != nil && !p.admin {
+		rec.Namespace = p.namespace
+	}
+	if err := s.storage.RecordAudit(rec, s.auditRetention); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// auditLive records the initial query of a live-tail subscription.
+func (s *Server) auditLive(c *client, started time.Time, results int, err error) {
+	if s.auditRetention <= 0 {
+		return
+	}
+	rec := storage.AuditRecord{
+		Time:       started,
+		Endpoint:   "live",
+		Query:      c.query,
+		DurationMS: time.Since(started).Milliseconds(),
+		Results:    results,
+	}
+	if tr := c.timeRange; tr != nil {
+		if !tr.Start.IsZero() {
+			rec.Start = tr.Start.Format(time.RFC3339)
+		}
+		if !tr.End.IsZero() {
+			rec.End = tr.End.Format(time.RFC3339)
+		}
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	s.audit(c.principal, c.remoteAddr, rec)
+}
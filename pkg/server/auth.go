@@ -0,0 +1,235 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mchurichi/peek/pkg/query"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// Token grants API access. Entries pushed with a token are stored in its
+// namespace and its reads only see that namespace; admin tokens read across
+// all namespaces.
+type Token struct {
+	Token     string
+	Namespace string
+	Admin     bool
+}
+
+// principal is the authenticated caller attached to a request context.
+type principal struct {
+	namespace string
+	admin     bool
+}
+
+type principalKey struct{}
+
+// publicPaths, and the UI bundle under /assets/, are served without a token
+// so the UI can load and prompt for one.
+var publicPaths = map[string]bool{
+	"/":           true,
+	"/van.min.js": true,
+	"/health":     true,
+	"/ui-config":  true,
+}
+
+// The live-tail WebSocket authenticates itself, since browsers cannot set
+// headers on it: handleWebSocket accepts a bearer header, a ?token= query
+// parameter, or a first {"action":"auth","token":"..."} message, and closes
+// unauthenticated connections with closeUnauthorized.
+const wsPath = "/logs"
+
+// closeUnauthorized is the WebSocket close code for a missing or invalid
+// token, in the range reserved for applications.
+const closeUnauthorized = 4401
+
+// wsAuthTimeout bounds how long a WebSocket may take to send its auth
+// message.
+const wsAuthTimeout = 10 * time.Second
+
+// SetTokens enables token authentication. An empty list disables it.
+func (s *Server) SetTokens(tokens []Token) error {
+	seen := make(map[string]bool, len(tokens))
+	for i, t := range tokens {
+		if t.Token == "" {
+			return fmt.Errorf("token %d: token is required", i+1)
+		}
+		if seen[t.Token] {
+			return fmt.Errorf("token %d: duplicate token", i+1)
+		}
+		if !t.Admin && t.Namespace == "" {
+			return fmt.Errorf("token %d: namespace is required for non-admin tokens", i+1)
+		}
+		seen[t.Token] = true
+	}
+	s.tokens = tokens
+	return nil
+}
+
+// requireAuth rejects requests without a valid bearer token when tokens are
+// configured and attaches the caller's principal to the request context.
+func (s *Server) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(s.tokens) == 0 || publicPaths[r.URL.Path] || strings.HasPrefix(r.URL.Path, assetsPath) || r.URL.Path == wsPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p, ok := s.authenticate(bearerToken(r))
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="peek"`)
+			writeError(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	})
+}
+
+// authenticate returns the principal for token, comparing in constant time.
+func (s *Server) authenticate(token string) (*principal, bool) {
+	if token == "" {
+		return nil, false
+	}
+	for _, t := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t.Token)) == 1 {
+			return &principal{namespace: t.Namespace, admin: t.Admin}, true
+		}
+	}
+	return nil, false
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// authenticateWebSocket authenticates a freshly upgraded connection and
+// returns ctx carrying the caller's principal. Without a header or ?token=
+// it waits for an auth message. On failure the connection is closed with
+// closeUnauthorized and ok is false.
+func (s *Server) authenticateWebSocket(ctx context.Context, conn *websocket.Conn, r *http.Request) (_ context.Context, ok bool) {
+	if len(s.tokens) == 0 {
+		return ctx, true
+	}
+
+	token := bearerToken(r)
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	if token == "" {
+		var msg struct {
+			Action string `json:"action"`
+			Token  string `json:"token"`
+		}
+		conn.SetReadDeadline(time.Now().Add(wsAuthTimeout))
+		if err := conn.ReadJSON(&msg); err == nil && msg.Action == "auth" {
+			token = msg.Token
+		}
+		conn.SetReadDeadline(time.Time{})
+	}
+
+	p, ok := s.authenticate(token)
+	if !ok {
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(closeUnauthorized, "unauthorized"),
+			time.Now().Add(time.Second))
+		conn.Close()
+		return ctx, false
+	}
+	return context.WithValue(ctx, principalKey{}, p), true
+}
+
+// principalFrom returns the authenticated caller, or nil when auth is disabled.
+func principalFrom(ctx context.Context) *principal {
+	p, _ := ctx.Value(principalKey{}).(*principal)
+	return p
+}
+
+// namespaceFor returns the namespace new entries pushed by the caller go to.
+// Admin tokens may choose one with requested; others always use their own.
+func namespaceFor(ctx context.Context, requested string) string {
+	p := principalFrom(ctx)
+	if p == nil || p.admin {
+		return requested
+	}
+	return p.namespace
+}
+
+// namespaceFilter restricts non-admin callers to their token's namespace; it
+// is nil when auth is disabled or the caller is an admin.
+func namespaceFilter(ctx context.Context) query.Filter {
+	p := principalFrom(ctx)
+	if p == nil || p.admin {
+		return nil
+	}
+	return &query.NamespaceFilter{Namespace: p.namespace}
+}
+
+// owns reports whether the caller may see and change a saved view,
+// investigation or scheduled query of namespace: admins and callers
+// without auth own all of them, others only their token's namespace.
+func owns(ctx context.Context, namespace string) bool {
+	p := principalFrom(ctx)
+	return p == nil || p.admin || p.namespace == namespace
+}
+
+// scopeKey identifies scope(ctx): callers with equal keys are restricted to
+// the same entries.
+func scopeKey(ctx context.Context) string {
+	p := principalFrom(ctx)
+	if p == nil || p.admin {
+		return ""
+	}
+	return p.namespace
+}
+
+// scope returns the filter every search in ctx is restricted to: the
+// fresh-mode session and the caller's namespace. Nil means no restriction.
+func (s *Server) scope(ctx context.Context) query.Filter {
+	ns := namespaceFilter(ctx)
+	switch {
+	case ns == nil:
+		return s.defaultFilter
+	case s.defaultFilter == nil:
+		return ns
+	default:
+		return &query.AndFilter{Left: s.defaultFilter, Right: ns}
+	}
+}
+
+// visibleEntries drops entries outside the caller's namespace.
+func visibleEntries(ctx context.Context, entries []*storage.LogEntry) []*storage.LogEntry {
+	ns := namespaceFilter(ctx)
+	if ns == nil {
+		return entries
+	}
+	kept := entries[:0]
+	for _, e := range entries {
+		if ns.Match(e) {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// visible reports whether entry-ID endpoints may expose id to the caller.
+func (s *Server) visible(ctx context.Context, id string) (bool, error) {
+	if namespaceFilter(ctx) == nil {
+		return true, nil
+	}
+	entries, err := s.storage.GetEntries([]string{id})
+	if err != nil {
+		return false, err
+	}
+	return len(visibleEntries(ctx, entries)) == 1, nil
+}
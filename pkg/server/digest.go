@@ -0,0 +1,55 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultDigestWindow = time.Hour
+	defaultDigestLimit  = 10
+)
+
+// handleDigest handles GET /digest?window=1h&limit=10: the most frequent
+// ERROR/WARN message patterns seen within the window.
+func (s *Server) handleDigest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	window := defaultDigestWindow
+	if v := q.Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeError(w, "Invalid window", http.StatusBadRequest)
+			return
+		}
+		window = d
+	}
+	limit := defaultDigestLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxQueryLimit {
+			writeError(w, fmt.Sprintf("Invalid limit (use 1 to %d)", maxQueryLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	patterns, err := s.storage.GetDigest(r.Context(), time.Now().Add(-window), s.scope(r.Context()), limit)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"window":   window.String(),
+		"patterns": patterns,
+	})
+}
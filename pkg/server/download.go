@@ -0,0 +1,75 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// handleDownload handles GET /download: the original lines of matching
+// entries, oldest first, as a plain-text log file. Takes the same query,
+// session, start and end parameters as /fields/{name}/stats.
+func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	filter, tr, err := s.buildFilter(r.Context(), q.Get("query"), q.Get("session"), parseTime(q.Get("start")), parseTime(q.Get("end")))
+	if err != nil {
+		writeQueryError(w, "Invalid query", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", downloadFilename(tr, time.Now())))
+
+	// Nothing reaches the client until the first buffered chunk, so an
+	// early failure still gets an error response. Later ones can only cut
+	// the file short and are logged.
+	out := &sentWriter{w: w}
+	bw := bufio.NewWriterSize(out, 64*1024)
+	err = s.storage.ScanRaw(r.Context(), filter, tr, func(_ *storage.LogEntry, raw string) error {
+		if _, err := bw.WriteString(raw); err != nil {
+			return err
+		}
+		return bw.WriteByte('\n')
+	})
+	if err == nil {
+		err = bw.Flush()
+	}
+	switch {
+	case err != nil && !out.sent:
+		w.Header().Del("Content-Disposition")
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	case err != nil && r.Context().Err() == nil:
+		log.Printf("download: %v", err)
+	}
+}
+
+// sentWriter records whether anything reached the client.
+type sentWriter struct {
+	w    http.ResponseWriter
+	sent bool
+}
+
+func (s *sentWriter) Write(p []byte) (int, error) {
+	s.sent = true
+	return s.w.Write(p)
+}
+
+// downloadFilename names the file after the start of the requested window,
+// or after now when the window is open-ended.
+func downloadFilename(tr *storage.TimeRange, now time.Time) string {
+	from := now
+	if tr != nil && !tr.Start.IsZero() {
+		from = tr.Start
+	}
+	return "peek-" + from.UTC().Format("20060102-150405") + ".log"
+}
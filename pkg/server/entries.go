@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// handleEntry handles DELETE /entries/{id} and GET, PUT and DELETE
+// /entries/{id}/annotation
+func (s *Server) handleEntry(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/entries/")
+	id, sub, _ := strings.Cut(path, "/")
+	if id == "" {
+		writeError(w, "Missing entry id", http.StatusBadRequest)
+		return
+	}
+	if ok, err := s.visible(r.Context(), id); err != nil || !ok {
+		s.writeEntryResult(w, errOrNotFound(err), 0, nil)
+		return
+	}
+
+	switch sub {
+	case "":
+		if r.Method != http.MethodDelete {
+			writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.writeEntryResult(w, s.storage.DeleteEntry(id), http.StatusNoContent, nil)
+	case "annotation":
+		s.handleAnnotation(w, r, id)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleAnnotation serves the annotation sub-resource of an entry.
+func (s *Server) handleAnnotation(w http.ResponseWriter, r *http.Request, id string) {
+	switch r.Method {
+	case http.MethodGet:
+		a, err := s.storage.GetAnnotation(id)
+		s.writeEntryResult(w, err, http.StatusOK, a)
+	case http.MethodPut:
+		var a storage.Annotation
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			writeError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		a.ID = id
+		s.writeEntryResult(w, s.storage.SetAnnotation(&a), http.StatusOK, &a)
+	case http.MethodDelete:
+		err := s.storage.SetAnnotation(&storage.Annotation{ID: id})
+		s.writeEntryResult(w, err, http.StatusNoContent, nil)
+	default:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeEntryResult maps a storage error to a status code, or writes body
+// (when non-nil) with status on success.
+func (s *Server) writeEntryResult(w http.ResponseWriter, err error, status int, body interface{}) {
+	if errors.Is(err, storage.ErrNotFound) {
+		writeError(w, "Entry not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if body == nil {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// handleAnnotations handles GET /annotations
+func (s *Server) handleAnnotations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	annotations, err := s.storage.ListAnnotations()
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if annotations, err = s.visibleAnnotations(r.Context(), annotations); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"annotations": annotations})
+}
+
+// errOrNotFound returns err, or ErrNotFound when err is nil; entries hidden
+// by namespace are reported as missing.
+func errOrNotFound(err error) error {
+	if err == nil {
+		return storage.ErrNotFound
+	}
+	return err
+}
+
+// visibleAnnotations drops annotations of entries outside the caller's
+// namespace.
+func (s *Server) visibleAnnotations(ctx context.Context, annotations []storage.Annotation) ([]storage.Annotation, error) {
+	if namespaceFilter(ctx) == nil {
+		return annotations, nil
+	}
+
+	ids := make([]string, len(annotations))
+	for i, a := range annotations {
+		ids[i] = a.ID
+	}
+	entries, err := s.storage.GetEntries(ids)
+	if err != nil {
+		return nil, err
+	}
+	keep := make(map[string]bool, len(entries))
+	for _, e := range visibleEntries(ctx, entries) {
+		keep[e.ID] = true
+	}
+
+	kept := annotations[:0]
+	for _, a := range annotations {
+		if keep[a.ID] {
+			kept = append(kept, a)
+		}
+	}
+	return kept, nil
+}
+
+// handleLogEntry handles GET /logs/{id}: a single entry including its
+// original line, for deep links and external references.
+func (s *Server) handleLogEntry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/logs/")
+	if id == "" {
+		writeError(w, "Missing entry id", http.StatusBadRequest)
+		return
+	}
+	if ok, err := s.visible(r.Context(), id); err != nil || !ok {
+		s.writeEntryResult(w, errOrNotFound(err), 0, nil)
+		return
+	}
+
+	entry, err := s.storage.GetEntry(id)
+	s.writeEntryResult(w, err, http.StatusOK, entry)
+}
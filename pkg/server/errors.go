@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/mchurichi/peek/pkg/query"
+)
+
+// Error codes in the JSON error envelope. Clients branch on the code; the
+// message is for people.
+const (
+	codeBadRequest       = "bad_request"
+	codeInvalidQuery     = "invalid_query"
+	codeUnauthorized     = "unauthorized"
+	codeForbidden        = "forbidden"
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeTooLarge         = "request_too_large"
+	codeInternal         = "internal_error"
+)
+
+// apiError is the body of every error response:
+//
+//	{"error": {"code": "invalid_query", "message": "...", "position": 12}}
+type apiError struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+	// Position is the byte offset in the query of an invalid_query error.
+	Position *int `json:"position,omitempty"`
+}
+
+// writeError answers with status and the JSON error envelope. The code is
+// derived from status; writeQueryError covers query syntax errors.
+func writeError(w http.ResponseWriter, message string, status int) {
+	writeAPIError(w, status, apiError{Code: errorCode(status), Message: message})
+}
+
+// writeQueryError answers 400 invalid_query for an unparsable query, with
+// the position of the problem when the parser reports one. prefix labels
+// what was being parsed, e.g. "Invalid query".
+func writeQueryError(w http.ResponseWriter, prefix string, err error) {
+	writeAPIError(w, http.StatusBadRequest, queryError(prefix, err))
+}
+
+// queryError builds the invalid_query envelope for err.
+func queryError(prefix string, err error) apiError {
+	e := apiError{Code: codeInvalidQuery, Message: prefix + ": " + err.Error()}
+	var pe *query.ParseError
+	if errors.As(err, &pe) {
+		pos := pe.Pos
+		e.Position = &pos
+	}
+	return e
+}
+
+func writeAPIError(w http.ResponseWriter, status int, e apiError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]apiError{"error": e})
+}
+
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return codeBadRequest
+	case http.StatusUnauthorized:
+		return codeUnauthorized
+	case http.StatusForbidden:
+		return codeForbidden
+	case http.StatusNotFound:
+		return codeNotFound
+	case http.StatusMethodNotAllowed:
+		return codeMethodNotAllowed
+	case http.StatusRequestEntityTooLarge:
+		return codeTooLarge
+	case http.StatusInternalServerError:
+		return codeInternal
+	default:
+		return http.StatusText(status)
+	}
+}
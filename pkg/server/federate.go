@@ -0,0 +1,326 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mchurichi/peek/pkg/query"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// Peer is a remote peek instance whose logs federated queries and live
+// tails include.
+type Peer struct {
+	URL   string // base URL, e.g. http://hostA:8080
+	Token string // bearer token the peer requires; empty without auth
+}
+
+// federatedHeader marks requests a server sends to its peers. They are
+// answered from local storage only, so instances federating each other
+// don't loop.
+const federatedHeader = "X-Peek-Federated"
+
+// peerTimeout bounds a /query request or WebSocket handshake to one peer.
+const peerTimeout = 10 * time.Second
+
+// peerRetryDelay is how long a live tail waits before reconnecting to a
+// peer it lost.
+const peerRetryDelay = 5 * time.Second
+
+var peerDialer = &websocket.Dialer{
+	Proxy:            http.ProxyFromEnvironment,
+	HandshakeTimeout: peerTimeout,
+}
+
+// SetPeers makes /query and live tails include the logs of remote peek
+// instances. An empty list disables federation.
+func (s *Server) SetPeers(peers []Peer) error {
+	normalized := make([]Peer, len(peers))
+	for i, p := range peers {
+		u, err := url.Parse(strings.TrimRight(p.URL, "/"))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("peer %d: invalid URL %q (use http://host:port)", i+1, p.URL)
+		}
+		normalized[i] = Peer{URL: u.String(), Token: p.Token}
+	}
+	s.peers = normalized
+	return nil
+}
+
+// federates reports whether a request from r should fan out to peers.
+func (s *Server) federates(r *http.Request) bool {
+	return s.federating() && r.Header.Get(federatedHeader) == ""
+}
+
+// federating reports whether searches include peers. Fresh mode doesn't fan
+// out: it shows one local collect session, which no peer holds.
+func (s *Server) federating() bool {
+	return len(s.peers) > 0 && s.defaultFilter == nil
+}
+
+// peerQueryFor restricts queryStr to p's namespace when p is not an admin.
+// Peers are asked with the federate token, which may read every namespace,
+// so the caller's scope travels in the query itself.
+func peerQueryFor(p *principal, queryStr string) string {
+	if p == nil || p.admin {
+		return queryStr
+	}
+	ns := `namespace:"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(p.namespace) + `"`
+	if q := strings.TrimSpace(queryStr); q == "" || q == "*" {
+		return ns
+	}
+	return ns + " AND (" + queryStr + ")"
+}
+
+// scoped drops the peer entries outside scope, the filter the caller's local
+// searches are restricted to, in case a peer answers with more than asked.
+func scoped(scope query.Filter, entries []*storage.LogEntry) []*storage.LogEntry {
+	if scope == nil {
+		return entries
+	}
+	kept := entries[:0]
+	for _, e := range entries {
+		if scope.Match(e) {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// peerQuery is the /query request sent to every peer.
+type peerQuery struct {
+	Query       string `json:"query"`
+	Limit       int    `json:"limit"`
+	Start       string `json:"start,omitempty"`
+	End         string `json:"end,omitempty"`
+	CountMode   string `json:"count_mode,omitempty"`
+	afterCursor string
+}
+
+// peerStatus reports one peer's part of a federated query.
+type peerStatus struct {
+	URL     string `json:"url"`
+	Total   int    `json:"total"`
+	Error   string `json:"error,omitempty"`
+	hasMore bool
+}
+
+// queryPeers runs q on every peer concurrently, restricted to scope and
+// the namespace of the caller in ctx. A peer that fails is reported in its
+// status and contributes no entries.
+func (s *Server) queryPeers(ctx context.Context, scope query.Filter, q peerQuery) ([][]*storage.LogEntry, []peerStatus) {
+	q.Query = peerQueryFor(principalFrom(ctx), q.Query)
+	results := make([][]*storage.LogEntry, len(s.peers))
+	statuses := make([]peerStatus, len(s.peers))
+	var wg sync.WaitGroup
+	for i, p := range s.peers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i] = peerStatus{URL: p.URL}
+			entries, err := p.query(ctx, q, &statuses[i])
+			if err != nil {
+				statuses[i].Error = err.Error()
+				log.Printf("Peer %s: query failed: %v", p.URL, err)
+				return
+			}
+			results[i] = scoped(scope, entries)
+		}()
+	}
+	wg.Wait()
+	return results, statuses
+}
+
+// query runs q against the peer's /query endpoint, filling in its total.
+func (p Peer) query(ctx context.Context, q peerQuery, status *peerStatus) ([]*storage.LogEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, peerTimeout)
+	defer cancel()
+
+	body, err := json.Marshal(q)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := p.URL + "/query"
+	if q.afterCursor != "" {
+		endpoint += "?after_cursor=" + url.QueryEscape(q.afterCursor)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	p.authorize(req.Header)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var envelope struct {
+			Error apiError `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&envelope) == nil && envelope.Error.Message != "" {
+			return nil, fmt.Errorf("%s: %s", resp.Status, envelope.Error.Message)
+		}
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+
+	var result struct {
+		Logs    []*storage.LogEntry `json:"logs"`
+		Total   int                 `json:"total"`
+		HasMore bool                `json:"has_more"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	for _, entry := range result.Logs {
+		p.tag(entry)
+	}
+	status.Total, status.hasMore = result.Total, result.HasMore
+	return result.Logs, nil
+}
+
+// authorize sets the headers of a request to the peer.
+func (p Peer) authorize(h http.Header) {
+	h.Set(federatedHeader, "1")
+	if p.Token != "" {
+		h.Set("Authorization", "Bearer "+p.Token)
+	}
+}
+
+// tag records the peer's host:port in the entry's peer field so the UI
+// shows where it came from.
+func (p Peer) tag(entry *storage.LogEntry) {
+	if entry.Fields == nil {
+		entry.Fields = make(map[string]interface{})
+	}
+	if u, err := url.Parse(p.URL); err == nil {
+		entry.Fields["peer"] = u.Host
+	}
+}
+
+// mergeEntries merges lists that are each in log order (timestamp, then
+// ID) into one list in log order.
+func mergeEntries(lists ...[]*storage.LogEntry) []*storage.LogEntry {
+	var merged []*storage.LogEntry
+	for _, l := range lists {
+		merged = append(merged, l...)
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		a, b := merged[i], merged[j]
+		if !a.Timestamp.Equal(b.Timestamp) {
+			return a.Timestamp.Before(b.Timestamp)
+		}
+		return a.ID < b.ID
+	})
+	return merged
+}
+
+// pageOf returns entries[offset:offset+limit], clamped to the list.
+func pageOf(entries []*storage.LogEntry, offset, limit int) []*storage.LogEntry {
+	if offset >= len(entries) {
+		return nil
+	}
+	return entries[offset:min(offset+limit, len(entries))]
+}
+
+// peerSubscription is the live-tail subscription forwarded to peers.
+type peerSubscription struct {
+	Action string `json:"action"`
+	Query  string `json:"query"`
+	Start  string `json:"start,omitempty"`
+	End    string `json:"end,omitempty"`
+}
+
+// tailPeers streams live entries matching sub from every peer to the
+// client until stop is closed, restricted to the client's scope.
+func (s *Server) tailPeers(c *client, sub peerSubscription, stop <-chan struct{}) {
+	sub.Query = peerQueryFor(c.principal, sub.Query)
+	deliver := func(entry *storage.LogEntry) {
+		if c.scope == nil || c.scope.Match(entry) {
+			c.deliver(entry)
+		}
+	}
+	for _, p := range s.peers {
+		s.workers.Add(1)
+		go func() {
+			defer s.workers.Done()
+			for {
+				err := p.tail(sub, stop, deliver)
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				log.Printf("Peer %s: live tail: %v", p.URL, err)
+				select {
+				case <-stop:
+					return
+				case <-time.After(peerRetryDelay):
+				}
+			}
+		}()
+	}
+}
+
+// tail subscribes to the peer's /logs WebSocket and passes its live
+// entries to deliver until the connection fails or stop is closed.
+func (p Peer) tail(sub peerSubscription, stop <-chan struct{}, deliver func(*storage.LogEntry)) error {
+	u, err := url.Parse(p.URL + wsPath)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
+	}
+	header := http.Header{}
+	p.authorize(header)
+	conn, _, err := peerDialer.Dial(u.String(), header)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Closing the connection ends the blocked read below.
+	closed := make(chan struct{})
+	defer close(closed)
+	go func() {
+		select {
+		case <-stop:
+			conn.Close()
+		case <-closed:
+		}
+	}()
+
+	sub.Action = "subscribe"
+	if err := conn.WriteJSON(sub); err != nil {
+		return err
+	}
+	for {
+		var msg struct {
+			Type  string            `json:"type"`
+			Entry *storage.LogEntry `json:"entry"`
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			return err
+		}
+		// The peer's initial results are fetched through /query instead.
+		if msg.Type == "log" && msg.Entry != nil {
+			p.tag(msg.Entry)
+			deliver(msg.Entry)
+		}
+	}
+}
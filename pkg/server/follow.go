@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// maxQueryWait bounds how long a /query request may wait for new entries.
+const maxQueryWait = 60 * time.Second
+
+// A query cursor is a position in log order: "{ts_nano}:{id}". Entry IDs
+// never contain ':'. An empty ID stands before every entry of that instant.
+// Storage has no arrival order to resume from, so an entry stored after a
+// follower passed its timestamp (backfilled, replayed or clock-skewed) is
+// never returned to that follower.
+
+// logCursor matches entries stored after a cursor position.
+type logCursor struct {
+	ts int64
+	id string
+}
+
+// cursorOf returns the cursor position of entry.
+func cursorOf(entry *storage.LogEntry) string {
+	return formatCursor(entry.Timestamp.UnixNano(), entry.ID)
+}
+
+func formatCursor(ts int64, id string) string {
+	return strconv.FormatInt(ts, 10) + ":" + id
+}
+
+// parseCursor parses an after_cursor parameter.
+func parseCursor(v string) (*logCursor, error) {
+	tsText, id, ok := strings.Cut(v, ":")
+	if !ok {
+		return nil, errors.New("expected {timestamp}:{id}")
+	}
+	ts, err := strconv.ParseInt(tsText, 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid timestamp")
+	}
+	return &logCursor{ts: ts, id: id}, nil
+}
+
+// Match reports whether entry sorts after the cursor.
+func (c *logCursor) Match(entry *storage.LogEntry) bool {
+	ts := entry.Timestamp.UnixNano()
+	return ts > c.ts || (ts == c.ts && entry.ID > c.id)
+}
+
+// narrow moves the start of tr up to the cursor so the scan skips older keys.
+func (c *logCursor) narrow(tr *storage.TimeRange) *storage.TimeRange {
+	start := time.Unix(0, c.ts)
+	if tr == nil {
+		return &storage.TimeRange{Start: start}
+	}
+	if tr.Start.Before(start) {
+		narrowed := *tr
+		narrowed.Start = start
+		return &narrowed
+	}
+	return tr
+}
+
+// parseWait parses a wait parameter: a Go duration of at most maxQueryWait.
+func parseWait(v string) (time.Duration, error) {
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 || d > maxQueryWait {
+		return 0, fmt.Errorf("use a duration up to %s", maxQueryWait)
+	}
+	return d, nil
+}
+
+// logArrivals returns a channel closed when the next entry is broadcast.
+func (s *Server) logArrivals() <-chan struct{} {
+	s.arrivalsMu.Lock()
+	defer s.arrivalsMu.Unlock()
+	if s.arrivals == nil {
+		s.arrivals = make(chan struct{})
+	}
+	return s.arrivals
+}
+
+// notifyArrivals wakes every request waiting in waitForLogs.
+func (s *Server) notifyArrivals() {
+	s.arrivalsMu.Lock()
+	defer s.arrivalsMu.Unlock()
+	if s.arrivals != nil {
+		close(s.arrivals)
+		s.arrivals = nil
+	}
+}
+
+// waitForLogs blocks until arrivals is closed and reports true, or reports
+// false once deadline passes, ctx is done or the server shuts down.
+func (s *Server) waitForLogs(ctx context.Context, arrivals <-chan struct{}, deadline time.Time) bool {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case <-arrivals:
+		return true
+	case <-timer.C:
+	case <-ctx.Done():
+	case <-s.draining:
+	}
+	return false
+}
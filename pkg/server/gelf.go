@@ -0,0 +1,210 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/parser"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// gelfSource names the /health source of messages received over GELF UDP.
+const gelfSource = "gelf"
+
+const (
+	// maxGELFMessageBytes bounds a reassembled, decompressed message.
+	maxGELFMessageBytes = maxIngestLineBytes
+	// gelfChunkTimeout is how long the chunks of a message may take to
+	// arrive, per the GELF spec.
+	gelfChunkTimeout = 5 * time.Second
+	// maxGELFChunks is the most chunks a message may be split into.
+	maxGELFChunks = 128
+	// maxPendingGELF bounds the messages being reassembled at once.
+	maxPendingGELF = 1024
+)
+
+// gelfChunkMagic starts every chunk of a chunked GELF message.
+var gelfChunkMagic = []byte{0x1e, 0x0f}
+
+// ListenGELF receives GELF messages over UDP on addr (e.g. ":12201") until
+// Shutdown. Chunked messages are reassembled, and gzip or zlib payloads
+// decompressed. Messages are stored like lines pushed to /ingest, without a
+// namespace; those that are not valid GELF are kept as parse failures. It
+// returns the address it listens on.
+func (s *Server) ListenGELF(addr string) (net.Addr, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("gelf: %w", err)
+	}
+	log.Printf("Receiving GELF on udp %s", conn.LocalAddr())
+	s.SetSourceStatus(gelfSource, SourceRunning, nil)
+
+	s.workers.Add(2)
+	go func() {
+		defer s.workers.Done()
+		<-s.stop
+		conn.Close()
+	}()
+	go func() {
+		defer s.workers.Done()
+		chunks := newGELFChunks()
+		buf := make([]byte, 64*1024)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					s.SetSourceStatus(gelfSource, SourceFailed, err)
+				} else {
+					s.SetSourceStatus(gelfSource, SourceStopped, nil)
+				}
+				return
+			}
+			payload := chunks.add(buf[:n], time.Now())
+			if payload == nil {
+				continue
+			}
+			s.ingestGELF(payload)
+		}
+	}()
+	return conn.LocalAddr(), nil
+}
+
+// ingestGELF decompresses, parses, stores and broadcasts one message.
+func (s *Server) ingestGELF(payload []byte) {
+	data, err := decompressGELF(payload)
+	if err == nil {
+		var entry *storage.LogEntry
+		if entry, err = s.ingestDetector().ParseWithFormat(string(data), "gelf"); err == nil {
+			s.storeGELF(entry)
+			return
+		}
+	}
+	failure := storage.ParseFailure{Format: "gelf", Reason: err.Error(), Line: string(data), Session: s.session, Source: gelfSource}
+	if data == nil {
+		failure.Line = string(payload)
+	}
+	if err := s.storage.RecordParseFailures([]storage.ParseFailure{failure}); err != nil {
+		log.Printf("Warning: failed to keep a rejected GELF message: %v", err)
+	}
+}
+
+func (s *Server) storeGELF(entry *storage.LogEntry) {
+	newID, dedupeWindow, maxValueSize := s.ingestSettings()
+	parser.Truncate(entry, maxValueSize)
+	entry.Session = s.session
+	if newID != nil {
+		entry.ID = newID(entry)
+	}
+	stored, err := s.storage.StoreBatchUnique([]*storage.LogEntry{entry}, dedupeWindow)
+	switch {
+	case errors.Is(err, storage.ErrIngestPaused):
+		return
+	case err != nil:
+		s.SetSourceStatus(gelfSource, SourceRunning, err)
+		return
+	case !stored[0]:
+		return
+	}
+	s.BroadcastLog(entry)
+	s.noteSourceLines(gelfSource)
+}
+
+// decompressGELF returns the JSON of a payload: gzip- or zlib-compressed
+// (detected by their magic bytes) or plain.
+func decompressGELF(payload []byte) ([]byte, error) {
+	var r io.ReadCloser
+	var err error
+	switch {
+	case len(payload) >= 2 && payload[0] == 0x1f && payload[1] == 0x8b:
+		r, err = gzip.NewReader(bytes.NewReader(payload))
+	case len(payload) >= 2 && payload[0] == 0x78 && (uint16(payload[0])<<8|uint16(payload[1]))%31 == 0:
+		r, err = zlib.NewReader(bytes.NewReader(payload))
+	default:
+		return payload, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gelf: invalid compressed payload: %w", err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(io.LimitReader(r, maxGELFMessageBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("gelf: invalid compressed payload: %w", err)
+	}
+	if len(data) > maxGELFMessageBytes {
+		return nil, fmt.Errorf("gelf: message larger than %d bytes", maxGELFMessageBytes)
+	}
+	return data, nil
+}
+
+// gelfChunks reassembles chunked GELF messages. Each chunk is the magic
+// bytes, an 8-byte message ID, its sequence number and the chunk count,
+// followed by its part of the payload. gelfChunks is not safe for
+// concurrent use.
+type gelfChunks struct {
+	pending map[[8]byte]*gelfMessage
+}
+
+type gelfMessage struct {
+	parts [][]byte
+	got   int
+	size  int
+	first time.Time
+}
+
+func newGELFChunks() *gelfChunks {
+	return &gelfChunks{pending: make(map[[8]byte]*gelfMessage)}
+}
+
+// add takes one datagram received at now and returns a complete payload:
+// the datagram itself when it is not chunked, or the joined parts once the
+// last chunk of a message arrives. Otherwise it returns nil. Messages not
+// completed within gelfChunkTimeout are dropped.
+func (g *gelfChunks) add(datagram []byte, now time.Time) []byte {
+	if !bytes.HasPrefix(datagram, gelfChunkMagic) {
+		return bytes.Clone(datagram)
+	}
+	for id, m := range g.pending {
+		if now.Sub(m.first) > gelfChunkTimeout {
+			delete(g.pending, id)
+		}
+	}
+	if len(datagram) < 12 {
+		return nil
+	}
+	var id [8]byte
+	copy(id[:], datagram[2:10])
+	seq, count := int(datagram[10]), int(datagram[11])
+	if count == 0 || count > maxGELFChunks || seq >= count {
+		return nil
+	}
+	m := g.pending[id]
+	if m == nil {
+		if len(g.pending) >= maxPendingGELF {
+			return nil
+		}
+		m = &gelfMessage{parts: make([][]byte, count), first: now}
+		g.pending[id] = m
+	}
+	if len(m.parts) != count || m.parts[seq] != nil {
+		return nil
+	}
+	m.parts[seq] = bytes.Clone(datagram[12:])
+	m.got++
+	m.size += len(datagram) - 12
+	if m.size > maxGELFMessageBytes {
+		delete(g.pending, id)
+		return nil
+	}
+	if m.got < count {
+		return nil
+	}
+	delete(g.pending, id)
+	return bytes.Join(m.parts, nil)
+}
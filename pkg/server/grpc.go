@@ -0,0 +1,338 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/query"
+	"github.com/mchurichi/peek/pkg/storage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// liveStreamBuffer bounds the entries queued for a gRPC Stream call, like
+// a WebSocket client's send channel; entries beyond it are skipped.
+const liveStreamBuffer = 100
+
+// liveStream is a gRPC Stream call, fed by BroadcastLog alongside the
+// WebSocket clients.
+type liveStream struct {
+	filter    query.Filter
+	filterKey string // shared with WebSocket subscriptions to the same query
+	send      chan *storage.LogEntry
+}
+
+// peekService is the handler type of grpcServiceDesc, implemented by
+// grpcService.
+type peekService interface {
+	Query(ctx context.Context, req *queryRequest) (*queryResponse, error)
+	Stream(req *streamRequest, stream grpc.ServerStream) error
+	Ingest(stream grpc.ServerStream) error
+}
+
+// grpcServiceDesc describes the peek.v1.Peek service of peek.proto.
+var grpcServiceDesc = grpc.ServiceDesc{
+	ServiceName: "peek.v1.Peek",
+	HandlerType: (*peekService)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Query",
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			req := &queryRequest{}
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, req any) (any, error) {
+				return srv.(peekService).Query(ctx, req.(*queryRequest))
+			}
+			if interceptor == nil {
+				return handler(ctx, req)
+			}
+			return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/peek.v1.Peek/Query"}, handler)
+		},
+	}},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "Stream",
+			Handler: func(srv any, stream grpc.ServerStream) error {
+				req := &streamRequest{}
+				if err := stream.RecvMsg(req); err != nil {
+					return err
+				}
+				return srv.(peekService).Stream(req, stream)
+			},
+			ServerStreams: true,
+		},
+		{
+			StreamName: "Ingest",
+			Handler: func(srv any, stream grpc.ServerStream) error {
+				return srv.(peekService).Ingest(stream)
+			},
+			ClientStreams: true,
+		},
+	},
+	Metadata: "peek.proto",
+}
+
+// ListenGRPC serves the gRPC API of peek.proto on addr (e.g. ":9090") until
+// Shutdown: Query runs a /query page, Stream tails entries matching a query
+// like a WebSocket subscription, and Ingest stores lines like /ingest. It
+// shares the HTTP server's tokens, sent as "authorization: Bearer <token>"
+// metadata, and its query cache, audit log and live broadcasts. It returns
+// the address it listens on.
+func (s *Server) ListenGRPC(addr string) (net.Addr, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("grpc: %w", err)
+	}
+	gs := grpc.NewServer(
+		grpc.ForceServerCodec(wireCodec{}),
+		grpc.UnaryInterceptor(s.grpcAuthUnary),
+		grpc.StreamInterceptor(s.grpcAuthStream),
+	)
+	gs.RegisterService(&grpcServiceDesc, grpcService{s})
+	log.Printf("Serving gRPC on %s", lis.Addr())
+
+	s.workers.Add(2)
+	go func() {
+		defer s.workers.Done()
+		// Stream calls end when stop closes; in-flight Ingest calls
+		// finish storing before GracefulStop returns.
+		<-s.stop
+		gs.GracefulStop()
+	}()
+	go func() {
+		defer s.workers.Done()
+		if err := gs.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			log.Printf("gRPC server error: %v", err)
+		}
+	}()
+	return lis.Addr(), nil
+}
+
+// grpcAuthUnary and grpcAuthStream attach the caller's principal to the
+// call's context, like requireAuth for HTTP requests.
+func (s *Server) grpcAuthUnary(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := s.grpcAuthenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) grpcAuthStream(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.grpcAuthenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authedStream{ServerStream: stream, ctx: ctx})
+}
+
+// grpcAuthenticate checks the bearer token in ctx's metadata when tokens
+// are configured.
+func (s *Server) grpcAuthenticate(ctx context.Context) (context.Context, error) {
+	if len(s.tokens) == 0 {
+		return ctx, nil
+	}
+	var token string
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get("authorization"); len(v) > 0 {
+		token, _ = strings.CutPrefix(v[0], "Bearer ")
+	}
+	p, ok := s.authenticate(strings.TrimSpace(token))
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	return context.WithValue(ctx, principalKey{}, p), nil
+}
+
+// authedStream is a server stream whose context carries the principal.
+type authedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (a *authedStream) Context() context.Context { return a.ctx }
+
+// grpcService implements the calls of peek.proto on a Server.
+type grpcService struct {
+	s *Server
+}
+
+// Query runs one page of a query, with the defaults and limits of POST
+// /query. Federated peers are not queried.
+func (g grpcService) Query(ctx context.Context, req *queryRequest) (*queryResponse, error) {
+	s := g.s
+	if req.Limit == 0 {
+		req.Limit = 100
+	}
+	if req.Limit < 0 || req.Limit > maxQueryLimit {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid limit (use 1 to %d)", maxQueryLimit)
+	}
+	if req.Offset < 0 {
+		return nil, status.Error(codes.InvalidArgument, "Invalid offset (must not be negative)")
+	}
+	filter, tr, err := s.buildFilter(ctx, req.Query, req.Session, parseTime(req.Start), parseTime(req.End))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, queryError("Invalid query", err).Message)
+	}
+
+	countMode := ""
+	if req.SkipTotal {
+		countMode = "none"
+	}
+	key := queryCacheKey{
+		scope:     scopeKey(ctx),
+		session:   req.Session,
+		query:     req.Query,
+		start:     req.Start,
+		end:       req.End,
+		limit:     req.Limit,
+		offset:    req.Offset,
+		countMode: countMode,
+	}
+	opts := storage.QueryOptions{TimeRange: tr, Limit: req.Limit, Offset: req.Offset, SkipTotal: req.SkipTotal}
+	started := time.Now()
+	cached, _, err := s.cachedQuery(ctx, key, filter, opts)
+	took := time.Since(started)
+	audit := storage.AuditRecord{Time: started, Endpoint: "query", Query: req.Query, Start: req.Start, End: req.End, DurationMS: took.Milliseconds()}
+	if err != nil {
+		audit.Error = err.Error()
+		s.audit(principalFrom(ctx), grpcPeerAddr(ctx), audit)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	audit.Results = cached.total
+	s.audit(principalFrom(ctx), grpcPeerAddr(ctx), audit)
+
+	resp := &queryResponse{Logs: cached.entries, Total: cached.total, TookMS: took.Milliseconds()}
+	if req.SkipTotal {
+		// Only the current page was scanned; report what is known.
+		resp.Total = req.Offset + len(cached.entries)
+		resp.HasMore = cached.total > resp.Total
+	}
+	return resp, nil
+}
+
+// Stream sends entries matching req as they are stored, like a WebSocket
+// subscription without the initial results (use Query for those), until
+// the client cancels or the server shuts down. Entries the client is too
+// slow to take are skipped.
+func (g grpcService) Stream(req *streamRequest, stream grpc.ServerStream) error {
+	s := g.s
+	ctx := stream.Context()
+	queryStr := req.Query
+	if queryStr == "" {
+		queryStr = "*"
+	}
+	started := time.Now()
+	filter, _, err := s.buildFilter(ctx, queryStr, "", parseTime(req.Start), parseTime(req.End))
+	if err != nil {
+		return status.Error(codes.InvalidArgument, queryError("Invalid query", err).Message)
+	}
+	s.audit(principalFrom(ctx), grpcPeerAddr(ctx), storage.AuditRecord{
+		Time: started, Endpoint: "live", Query: queryStr, Start: req.Start, End: req.End,
+		DurationMS: time.Since(started).Milliseconds(),
+	})
+
+	ls := &liveStream{
+		filter:    filter,
+		filterKey: strings.Join([]string{scopeKey(ctx), queryStr, req.Start, req.End}, "\x00"),
+		send:      make(chan *storage.LogEntry, liveStreamBuffer),
+	}
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return status.Error(codes.Unavailable, "server is shutting down")
+	}
+	s.streams[ls] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.streams, ls)
+		s.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.stop:
+			return nil
+		case entry := <-ls.send:
+			if err := stream.SendMsg(wireEntry{entry}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Ingest stores the lines of every message the client sends, like one
+// POST /ingest, and replies once the client closes its side. The first
+// message's format, source, namespace and host apply to the whole call.
+func (g grpcService) Ingest(stream grpc.ServerStream) error {
+	s := g.s
+	if s.storage.IngestPaused() {
+		return status.Error(codes.ResourceExhausted, "Ingestion paused: low disk space")
+	}
+	var in *ingester
+	for {
+		req := &ingestRequest{}
+		err := stream.RecvMsg(req)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if in == nil {
+			if in, err = s.newIngester(stream.Context(), req.Format, req.Source, req.Namespace, req.Host); err != nil {
+				return status.Error(codes.InvalidArgument, err.Error())
+			}
+			in.input = "grpc"
+		}
+		for _, line := range req.Lines {
+			if err := in.add(line); err != nil {
+				in.recordFailures()
+				return grpcFlushError(err, in.accepted)
+			}
+		}
+	}
+	if in == nil {
+		return stream.SendMsg(&ingestResponse{RejectedLines: []int{}})
+	}
+	if err := in.finish(); err != nil {
+		return grpcFlushError(err, in.accepted)
+	}
+	return stream.SendMsg(&ingestResponse{
+		Accepted:      in.accepted,
+		Rejected:      in.rejected,
+		Duplicates:    in.duplicates,
+		RejectedLines: in.rejectedLines,
+		Namespace:     in.namespace,
+	})
+}
+
+// grpcFlushError is writeFlushError for gRPC: a disk-guard pause is
+// ResourceExhausted, which clients may retry.
+func grpcFlushError(err error, accepted int) error {
+	if errors.Is(err, storage.ErrIngestPaused) {
+		return status.Errorf(codes.ResourceExhausted, "Ingestion paused: low disk space (after %d accepted lines)", accepted)
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// grpcPeerAddr returns the caller's address for audit records.
+func grpcPeerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr.String()
+	}
+	return ""
+}
@@ -0,0 +1,423 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/mchurichi/peek/pkg/storage"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// The messages of the gRPC service, described for clients in peek.proto.
+// They are encoded by hand with protowire rather than generated, so entries
+// go on the wire straight from storage.LogEntry instead of being copied into
+// a parallel set of generated structs.
+
+// wireMessage is a message of peek.proto.
+type wireMessage interface {
+	appendWire(b []byte) []byte
+	readWire(b []byte) error
+}
+
+// wireCodec encodes wireMessages. It replaces the gRPC server's default
+// proto codec, so it keeps its name and clients send application/grpc as
+// with any protobuf service.
+type wireCodec struct{}
+
+func (wireCodec) Name() string { return "proto" }
+
+func (wireCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(wireMessage)
+	if !ok {
+		return nil, fmt.Errorf("grpc: can't encode %T", v)
+	}
+	return m.appendWire(nil), nil
+}
+
+func (wireCodec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(wireMessage)
+	if !ok {
+		return fmt.Errorf("grpc: can't decode into %T", v)
+	}
+	return m.readWire(data)
+}
+
+// wireEntry is a LogEntry message.
+type wireEntry struct {
+	*storage.LogEntry
+}
+
+func (e wireEntry) appendWire(b []byte) []byte {
+	b = appendWireString(b, 1, e.ID)
+	if !e.Timestamp.IsZero() {
+		ts, _ := proto.Marshal(timestamppb.New(e.Timestamp))
+		b = appendWireBytes(b, 2, ts)
+	}
+	b = appendWireString(b, 3, e.Level)
+	b = appendWireString(b, 4, e.Message)
+	if len(e.Fields) > 0 {
+		fields := &structpb.Struct{Fields: make(map[string]*structpb.Value, len(e.Fields))}
+		for k, v := range e.Fields {
+			pv, err := structpb.NewValue(v)
+			if err != nil {
+				pv = structpb.NewStringValue(fmt.Sprint(v))
+			}
+			fields.Fields[k] = pv
+		}
+		data, _ := proto.Marshal(fields)
+		b = appendWireBytes(b, 5, data)
+	}
+	b = appendWireString(b, 6, e.Raw)
+	b = appendWireString(b, 7, e.Session)
+	b = appendWireString(b, 8, e.Namespace)
+	b = appendWireString(b, 9, e.TraceID)
+	b = appendWireString(b, 10, e.SpanID)
+	b = appendWireString(b, 11, e.Source)
+	if e.Host != nil {
+		b = appendWireBytes(b, 12, appendWireHost(nil, e.Host))
+	}
+	return b
+}
+
+func (e wireEntry) readWire(b []byte) error {
+	return readWireFields(b, func(num protowire.Number, v wireValue) error {
+		var err error
+		switch num {
+		case 1:
+			e.ID = v.str()
+		case 2:
+			var ts timestamppb.Timestamp
+			if err = proto.Unmarshal(v.bytes, &ts); err == nil {
+				e.Timestamp = ts.AsTime()
+			}
+		case 3:
+			e.Level = v.str()
+		case 4:
+			e.Message = v.str()
+		case 5:
+			var fields structpb.Struct
+			if err = proto.Unmarshal(v.bytes, &fields); err == nil {
+				e.Fields = fields.AsMap()
+			}
+		case 6:
+			e.Raw = v.str()
+		case 7:
+			e.Session = v.str()
+		case 8:
+			e.Namespace = v.str()
+		case 9:
+			e.TraceID = v.str()
+		case 10:
+			e.SpanID = v.str()
+		case 11:
+			e.Source = v.str()
+		case 12:
+			e.Host, err = readWireHost(v.bytes)
+		}
+		return err
+	})
+}
+
+// appendWireHost encodes a Host message.
+func appendWireHost(b []byte, h *storage.HostInfo) []byte {
+	b = appendWireString(b, 1, h.Name)
+	b = appendWireString(b, 2, h.OS)
+	return appendWireString(b, 3, h.User)
+}
+
+// readWireHost decodes a Host message; an empty one is nil.
+func readWireHost(b []byte) (*storage.HostInfo, error) {
+	var h storage.HostInfo
+	err := readWireFields(b, func(num protowire.Number, v wireValue) error {
+		switch num {
+		case 1:
+			h.Name = v.str()
+		case 2:
+			h.OS = v.str()
+		case 3:
+			h.User = v.str()
+		}
+		return nil
+	})
+	if err != nil || h == (storage.HostInfo{}) {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// queryRequest is a QueryRequest message, the body of POST /query.
+type queryRequest struct {
+	Query     string
+	Limit     int
+	Offset    int
+	Start     string
+	End       string
+	Session   string
+	SkipTotal bool // count_mode "none"
+}
+
+func (r *queryRequest) appendWire(b []byte) []byte {
+	b = appendWireString(b, 1, r.Query)
+	b = appendWireVarint(b, 2, uint64(r.Limit))
+	b = appendWireVarint(b, 3, uint64(r.Offset))
+	b = appendWireString(b, 4, r.Start)
+	b = appendWireString(b, 5, r.End)
+	b = appendWireString(b, 6, r.Session)
+	return appendWireBool(b, 7, r.SkipTotal)
+}
+
+func (r *queryRequest) readWire(b []byte) error {
+	return readWireFields(b, func(num protowire.Number, v wireValue) error {
+		switch num {
+		case 1:
+			r.Query = v.str()
+		case 2:
+			r.Limit = int(int32(v.varint))
+		case 3:
+			r.Offset = int(int32(v.varint))
+		case 4:
+			r.Start = v.str()
+		case 5:
+			r.End = v.str()
+		case 6:
+			r.Session = v.str()
+		case 7:
+			r.SkipTotal = v.varint != 0
+		}
+		return nil
+	})
+}
+
+// queryResponse is a QueryResponse message.
+type queryResponse struct {
+	Logs    []*storage.LogEntry
+	Total   int
+	TookMS  int64
+	HasMore bool
+}
+
+func (r *queryResponse) appendWire(b []byte) []byte {
+	for _, e := range r.Logs {
+		b = appendWireBytes(b, 1, wireEntry{e}.appendWire(nil))
+	}
+	b = appendWireVarint(b, 2, uint64(r.Total))
+	b = appendWireVarint(b, 3, uint64(r.TookMS))
+	return appendWireBool(b, 4, r.HasMore)
+}
+
+func (r *queryResponse) readWire(b []byte) error {
+	return readWireFields(b, func(num protowire.Number, v wireValue) error {
+		switch num {
+		case 1:
+			e := &storage.LogEntry{}
+			if err := (wireEntry{e}).readWire(v.bytes); err != nil {
+				return err
+			}
+			r.Logs = append(r.Logs, e)
+		case 2:
+			r.Total = int(v.varint)
+		case 3:
+			r.TookMS = int64(v.varint)
+		case 4:
+			r.HasMore = v.varint != 0
+		}
+		return nil
+	})
+}
+
+// streamRequest is a StreamRequest message, the WebSocket subscribe action.
+type streamRequest struct {
+	Query string
+	Start string
+	End   string
+}
+
+func (r *streamRequest) appendWire(b []byte) []byte {
+	b = appendWireString(b, 1, r.Query)
+	b = appendWireString(b, 2, r.Start)
+	return appendWireString(b, 3, r.End)
+}
+
+func (r *streamRequest) readWire(b []byte) error {
+	return readWireFields(b, func(num protowire.Number, v wireValue) error {
+		switch num {
+		case 1:
+			r.Query = v.str()
+		case 2:
+			r.Start = v.str()
+		case 3:
+			r.End = v.str()
+		}
+		return nil
+	})
+}
+
+// ingestRequest is an IngestRequest message: lines, and in the first
+// message of a call the /ingest parameters.
+type ingestRequest struct {
+	Lines     []string
+	Format    string
+	Source    string
+	Namespace string
+	Host      *storage.HostInfo
+}
+
+func (r *ingestRequest) appendWire(b []byte) []byte {
+	for _, line := range r.Lines {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendString(b, line)
+	}
+	b = appendWireString(b, 2, r.Format)
+	b = appendWireString(b, 3, r.Source)
+	b = appendWireString(b, 4, r.Namespace)
+	if r.Host != nil {
+		b = appendWireBytes(b, 5, appendWireHost(nil, r.Host))
+	}
+	return b
+}
+
+func (r *ingestRequest) readWire(b []byte) error {
+	return readWireFields(b, func(num protowire.Number, v wireValue) error {
+		var err error
+		switch num {
+		case 1:
+			r.Lines = append(r.Lines, v.str())
+		case 2:
+			r.Format = v.str()
+		case 3:
+			r.Source = v.str()
+		case 4:
+			r.Namespace = v.str()
+		case 5:
+			r.Host, err = readWireHost(v.bytes)
+		}
+		return err
+	})
+}
+
+// ingestResponse is an IngestResponse message, the /ingest reply.
+type ingestResponse struct {
+	Accepted      int
+	Rejected      int
+	Duplicates    int
+	RejectedLines []int
+	Namespace     string
+}
+
+func (r *ingestResponse) appendWire(b []byte) []byte {
+	b = appendWireVarint(b, 1, uint64(r.Accepted))
+	b = appendWireVarint(b, 2, uint64(r.Rejected))
+	b = appendWireVarint(b, 3, uint64(r.Duplicates))
+	if len(r.RejectedLines) > 0 {
+		var packed []byte
+		for _, n := range r.RejectedLines {
+			packed = protowire.AppendVarint(packed, uint64(n))
+		}
+		b = appendWireBytes(b, 4, packed)
+	}
+	return appendWireString(b, 5, r.Namespace)
+}
+
+func (r *ingestResponse) readWire(b []byte) error {
+	return readWireFields(b, func(num protowire.Number, v wireValue) error {
+		switch num {
+		case 1:
+			r.Accepted = int(v.varint)
+		case 2:
+			r.Rejected = int(v.varint)
+		case 3:
+			r.Duplicates = int(v.varint)
+		case 4:
+			if v.typ == protowire.VarintType {
+				r.RejectedLines = append(r.RejectedLines, int(v.varint))
+				return nil
+			}
+			for packed := v.bytes; len(packed) > 0; {
+				n, size := protowire.ConsumeVarint(packed)
+				if size < 0 {
+					return protowire.ParseError(size)
+				}
+				r.RejectedLines = append(r.RejectedLines, int(n))
+				packed = packed[size:]
+			}
+		case 5:
+			r.Namespace = v.str()
+		}
+		return nil
+	})
+}
+
+// wireValue is the value of one decoded field: varint for varint fields,
+// bytes for length-delimited ones.
+type wireValue struct {
+	typ    protowire.Type
+	varint uint64
+	bytes  []byte
+}
+
+func (v wireValue) str() string { return string(v.bytes) }
+
+// readWireFields calls field with each varint and length-delimited field of
+// b in order, skipping fields of other types.
+func readWireFields(b []byte, field func(num protowire.Number, v wireValue) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		v := wireValue{typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			v.varint, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			v.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if typ != protowire.VarintType && typ != protowire.BytesType {
+			continue
+		}
+		if err := field(num, v); err != nil {
+			return fmt.Errorf("field %d: %w", num, err)
+		}
+	}
+	return nil
+}
+
+// appendWireString appends a string field unless it is empty, as proto3
+// does.
+func appendWireString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// appendWireBytes appends a length-delimited field: an embedded message or
+// packed values.
+func appendWireBytes(b []byte, num protowire.Number, data []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, data)
+}
+
+// appendWireVarint appends a varint field unless it is zero.
+func appendWireVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// appendWireBool appends a bool field unless it is false.
+func appendWireBool(b []byte, num protowire.Number, v bool) []byte {
+	return appendWireVarint(b, num, protowire.EncodeBool(v))
+}
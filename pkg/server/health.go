@@ -0,0 +1,176 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/parser"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// Source states reported by SetSourceStatus. A restarting or failed source
+// marks /health degraded.
+const (
+	SourceRunning    = "running"
+	SourceStopped    = "stopped"
+	SourceRestarting = "restarting"
+	SourceFailed     = "failed"
+)
+
+// ingestSource names the source for lines pushed to POST /ingest.
+const ingestSource = "ingest"
+
+// SourceStatus describes one input feeding the server: collected stdin, a
+// watched command, or lines pushed to /ingest.
+type SourceStatus struct {
+	Name        string     `json:"name"`
+	State       string     `json:"state"`
+	Since       time.Time  `json:"since"`
+	LastLine    *time.Time `json:"last_line,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// SetSourceStatus records the state of the input called name. err, when
+// non-nil, is kept as the source's last error.
+func (s *Server) SetSourceStatus(name, state string, err error) {
+	s.sourcesMu.Lock()
+	defer s.sourcesMu.Unlock()
+	src, ok := s.sources[name]
+	if !ok {
+		src = &SourceStatus{Name: name}
+		s.sources[name] = src
+	}
+	if src.State != state {
+		src.State = state
+		src.Since = time.Now()
+	}
+	if err != nil {
+		now := time.Now()
+		src.LastError, src.LastErrorAt = err.Error(), &now
+	}
+}
+
+// noteSourceLines records that the source called name delivered lines.
+func (s *Server) noteSourceLines(name string) {
+	s.sourcesMu.Lock()
+	defer s.sourcesMu.Unlock()
+	now := time.Now()
+	src, ok := s.sources[name]
+	if !ok {
+		src = &SourceStatus{Name: name, State: SourceRunning, Since: now}
+		s.sources[name] = src
+	}
+	src.LastLine = &now
+}
+
+// sourceStatuses returns a copy of every source, sorted by name.
+func (s *Server) sourceStatuses() []SourceStatus {
+	s.sourcesMu.Lock()
+	defer s.sourcesMu.Unlock()
+	out := make([]SourceStatus, 0, len(s.sources))
+	for _, src := range s.sources {
+		out = append(out, *src)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// healthReport is the body of GET /health.
+type healthReport struct {
+	Status      string   `json:"status"` // ok or degraded
+	Problems    []string `json:"problems,omitempty"`
+	LogsStored  int      `json:"logs_stored"`
+	DBSizeBytes int64    `json:"db_size_bytes"`
+	Session     string   `json:"session,omitempty"`
+	LastError   string   `json:"last_error,omitempty"`
+	Components  struct {
+		Storage   storage.StorageHealth `json:"storage"`
+		WebSocket struct {
+			Clients int `json:"clients"`
+		} `json:"websocket"`
+		Sources []SourceStatus `json:"sources"`
+		// Parsers reports the commands of custom formats parsed by an
+		// external program.
+		Parsers []parser.CommandStatus `json:"parsers,omitempty"`
+	} `json:"components"`
+}
+
+// handleHealth handles GET /health. It answers 200 while storage is open,
+// with status "degraded" and the reasons in problems when a component is
+// unhealthy, and 503 once storage is closed.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	report := healthReport{Status: "ok", Session: s.session}
+	problem := func(msg string) {
+		report.Status = "degraded"
+		report.Problems = append(report.Problems, msg)
+	}
+
+	st := s.storage.Health()
+	report.Components.Storage = st
+	report.LastError = st.LastError
+	lastErrorAt := time.Time{}
+	if st.LastErrorAt != nil {
+		lastErrorAt = *st.LastErrorAt
+	}
+
+	switch {
+	case !st.Open:
+		problem("storage is closed")
+	case !st.Writable:
+		problem("storage: last write failed: " + st.LastError)
+	}
+	if st.Retention.Enabled && st.Open && !st.Retention.Running {
+		problem("retention worker is not running")
+	}
+	if st.Retention.LastError != "" {
+		problem("retention: last sweep failed: " + st.Retention.LastError)
+	}
+	if st.Disk.Paused {
+		const mb = 1024 * 1024
+		problem(fmt.Sprintf("ingestion paused: %.0f MB free on the database disk, below the %.0f MB minimum; new lines are shown live but not stored",
+			float64(st.Disk.FreeBytes)/mb, float64(st.Disk.MinFreeBytes)/mb))
+	}
+
+	if st.Open {
+		if stats, err := s.storage.CachedStats(); err != nil {
+			problem("stats: " + err.Error())
+		} else {
+			report.LogsStored = stats.TotalLogs
+			report.DBSizeBytes = int64(stats.DBSizeMB * 1024 * 1024)
+		}
+	}
+
+	s.mu.RLock()
+	report.Components.WebSocket.Clients = len(s.clients)
+	s.mu.RUnlock()
+
+	report.Components.Sources = s.sourceStatuses()
+	for _, src := range report.Components.Sources {
+		if src.State == SourceRestarting || src.State == SourceFailed {
+			problem("source " + src.Name + " is " + src.State)
+		}
+		if src.LastErrorAt != nil && src.LastErrorAt.After(lastErrorAt) {
+			report.LastError = src.Name + ": " + src.LastError
+			lastErrorAt = *src.LastErrorAt
+		}
+	}
+
+	report.Components.Parsers = s.ingestDetector().CommandStatuses()
+	for _, p := range report.Components.Parsers {
+		if p.State == parser.CommandFailed {
+			problem("parser command " + p.Name + " failed: " + p.LastError)
+		}
+	}
+
+	status := http.StatusOK
+	if !st.Open {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,326 @@
+package server
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/parser"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// maxIngestLineBytes bounds a single pushed log line.
+const maxIngestLineBytes = 1024 * 1024
+
+// Pushed lines are stored in batches of up to ingestBatchLines lines or
+// ingestBatchBytes raw bytes, whichever fills first, so one request does not
+// commit a transaction per line.
+const (
+	ingestBatchLines = 500
+	ingestBatchBytes = 4 * 1024 * 1024
+)
+
+// maxRejectedLines bounds the line numbers listed in rejected_lines.
+const maxRejectedLines = 100
+
+// maxIngestFailures bounds the rejected lines one push keeps for GET
+// /parse-failures; further ones are only counted, so a large bad upload
+// doesn't grow memory without bound.
+const maxIngestFailures = 1000
+
+// handleIngest handles POST /ingest: newline-delimited log lines, optionally
+// gzip-compressed (Content-Encoding: gzip), are parsed like collected stdin,
+// stored in the caller's namespace in batches and broadcast live. While the
+// disk guard pauses storing it answers 507, which forwarders retry.
+func (s *Server) handleIngest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.storage.IngestPaused() {
+		writeError(w, "Ingestion paused: low disk space", http.StatusInsufficientStorage)
+		return
+	}
+
+	in, err := s.newIngester(r.Context(), r.URL.Query().Get("format"), r.URL.Query().Get("source"), r.URL.Query().Get("namespace"), ingestHost(r.URL.Query()))
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body := io.Reader(r.Body)
+	switch enc := r.Header.Get("Content-Encoding"); enc {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			writeError(w, fmt.Sprintf("Invalid gzip body: %v", err), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	default:
+		writeError(w, fmt.Sprintf("Unsupported Content-Encoding: %s", enc), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxIngestLineBytes)
+	for scanner.Scan() {
+		if err := in.add(scanner.Text()); err != nil {
+			in.recordFailures()
+			writeFlushError(w, err, in)
+			return
+		}
+	}
+	scanErr := scanner.Err()
+	if err := in.finish(); err != nil {
+		writeFlushError(w, err, in)
+		return
+	}
+	if scanErr != nil {
+		// Complete lines before the error are stored; report how many.
+		writeError(w, fmt.Sprintf("Invalid request body after %d accepted lines: %v", in.accepted, scanErr), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(in.counts())
+}
+
+// ingester parses, stores and broadcasts the lines of one push, to
+// /ingest or the gRPC Ingest call, in batches.
+type ingester struct {
+	s            *Server
+	input        string // names the push in pipeline traces
+	detector     *parser.Detector
+	format       string
+	source       string
+	namespace    string
+	host         *storage.HostInfo
+	newID        parser.IDGenerator
+	dedupeWindow time.Duration
+	maxValueSize int
+
+	lineNo     int
+	batch      []*storage.LogEntry
+	batchBytes int
+	// failures are the first maxIngestFailures rejected lines, until
+	// recordFailures keeps them; unkept counts the rest.
+	failures []storage.ParseFailure
+	unkept   int
+	// traces are the sampled entries of the batch, by batch index, until
+	// flush records them.
+	traces map[int]PipelineTrace
+
+	accepted, rejected, duplicates int
+	rejectedLines                  []int // first maxRejectedLines rejected line numbers
+}
+
+// newIngester starts a push of lines in format ("" for the source's
+// format) into the caller's namespace. Admin callers may pick namespace.
+func (s *Server) newIngester(ctx context.Context, format, source, namespace string, host *storage.HostInfo) (*ingester, error) {
+	// Each push is its own stream: a csv body starts with its header.
+	detector := s.ingestDetector().Stream(nil)
+	switch {
+	case format == "":
+		format = s.sourceFormat(source)
+	case !detector.ValidFormat(format):
+		return nil, fmt.Errorf("Invalid format: %s", format)
+	}
+	in := &ingester{
+		s:             s,
+		input:         ingestSource,
+		detector:      detector,
+		format:        format,
+		source:        source,
+		namespace:     namespaceFor(ctx, namespace),
+		host:          host,
+		rejectedLines: []int{},
+	}
+	in.newID, in.dedupeWindow, in.maxValueSize = s.ingestSettings()
+	return in, nil
+}
+
+// add parses the next line, storing a batch once it fills. Lines that
+// don't parse are kept for finish to record.
+func (in *ingester) add(line string) error {
+	in.lineNo++
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+
+	sampled := in.s.SamplePipeline()
+	started := time.Now()
+	entry, info, err := in.detector.ParseLinesInfo([]string{line}, in.format)
+	parsed := time.Since(started)
+	if errors.Is(err, parser.ErrHeaderRow) {
+		return nil
+	}
+	if err != nil {
+		if sampled {
+			in.s.RecordPipeline(PipelineTrace{
+				Time: time.Now(), Input: in.input, Source: in.source, Namespace: in.namespace,
+				Format: in.format, Lines: 1, Transforms: []string{}, Fields: []string{},
+				ParseUS: parsed.Microseconds(), Outcome: PipelineFailed, Error: err.Error(),
+			})
+		}
+		in.rejected++
+		if len(in.rejectedLines) < maxRejectedLines {
+			in.rejectedLines = append(in.rejectedLines, in.lineNo)
+		}
+		if len(in.failures) >= maxIngestFailures {
+			in.unkept++
+			return nil
+		}
+		in.failures = append(in.failures, storage.ParseFailure{
+			Format:    in.format,
+			Reason:    err.Error(),
+			Line:      line,
+			Session:   in.s.session,
+			Source:    in.source,
+			Namespace: in.namespace,
+			Host:      in.host,
+		})
+		return nil
+	}
+	truncated := parser.Truncate(entry, in.maxValueSize)
+	entry.Namespace = in.namespace
+	entry.Source = in.source
+	entry.Host = in.host
+	entry.Session = in.s.session
+	if in.newID != nil {
+		entry.ID = in.newID(entry)
+	}
+	if sampled {
+		t := NewPipelineTrace(entry, info, in.format, 1, truncated, in.maxValueSize, parsed)
+		t.EntryID, t.Input, t.Source, t.Namespace = entry.ID, in.input, in.source, in.namespace
+		if in.traces == nil {
+			in.traces = make(map[int]PipelineTrace)
+		}
+		in.traces[len(in.batch)] = t
+	}
+
+	in.batch = append(in.batch, entry)
+	in.batchBytes += len(line)
+	if len(in.batch) >= ingestBatchLines || in.batchBytes >= ingestBatchBytes {
+		return in.flush()
+	}
+	return nil
+}
+
+// flush stores and broadcasts the batch, counting duplicates.
+func (in *ingester) flush() error {
+	started := time.Now()
+	stored, err := in.s.storage.StoreBatchUnique(in.batch, in.dedupeWindow)
+	in.recordTraces(stored, time.Since(started), err)
+	if err != nil {
+		return err
+	}
+	for i, entry := range in.batch {
+		if !stored[i] {
+			in.duplicates++
+			continue
+		}
+		in.s.BroadcastLog(entry)
+		in.accepted++
+	}
+	in.batch, in.batchBytes = in.batch[:0], 0
+	return nil
+}
+
+// recordTraces hands the batch's sampled entries to RecordPipeline, with
+// the batch's store latency and outcome.
+func (in *ingester) recordTraces(stored []bool, took time.Duration, err error) {
+	for i, t := range in.traces {
+		t.StoreUS = took.Microseconds()
+		switch {
+		case errors.Is(err, storage.ErrIngestPaused):
+			t.Outcome = PipelineUnstored
+		case err != nil:
+			t.Outcome, t.Error = PipelineFailed, err.Error()
+		case !stored[i]:
+			t.Outcome = PipelineDuplicate
+		default:
+			t.Outcome = PipelineStored
+		}
+		in.s.RecordPipeline(t)
+	}
+	clear(in.traces)
+}
+
+// recordFailures keeps the rejected lines collected so far for GET
+// /parse-failures and peek reparse-failures. It runs when the push ends,
+// including when a batch write fails.
+func (in *ingester) recordFailures() {
+	if err := in.s.storage.RecordParseFailures(in.failures); err != nil {
+		log.Printf("Warning: failed to keep %d rejected lines: %v", len(in.failures), err)
+	}
+	if in.unkept > 0 {
+		log.Printf("Warning: kept the first %d rejected lines of a push; %d more were only counted", len(in.failures), in.unkept)
+	}
+	in.failures, in.unkept = nil, 0
+}
+
+// counts reports the push so far: the /ingest response, and the details of
+// a failed batch write.
+func (in *ingester) counts() map[string]interface{} {
+	return map[string]interface{}{
+		"accepted":       in.accepted,
+		"rejected":       in.rejected,
+		"duplicates":     in.duplicates,
+		"rejected_lines": in.rejectedLines,
+		"namespace":      in.namespace,
+	}
+}
+
+// finish records the rejected lines and stores the last batch.
+func (in *ingester) finish() error {
+	in.recordFailures()
+	if err := in.flush(); err != nil {
+		return err
+	}
+	if in.accepted > 0 {
+		in.s.noteSourceLines(ingestSourceName(in.namespace))
+	}
+	return nil
+}
+
+// writeFlushError reports a failed batch write. Batches stored before a
+// disk-guard pause are kept, so the reply says how many lines were accepted,
+// with the push's counts so far as details.
+func writeFlushError(w http.ResponseWriter, err error, in *ingester) {
+	status, message := http.StatusInternalServerError, err.Error()
+	if errors.Is(err, storage.ErrIngestPaused) {
+		status, message = http.StatusInsufficientStorage, fmt.Sprintf("Ingestion paused: low disk space (after %d accepted lines)", in.accepted)
+	}
+	writeAPIError(w, status, apiError{Code: errorCode(status), Message: message, Details: in.counts()})
+}
+
+// ingestHost returns the host metadata sent with host, host_os and
+// host_user, or nil when none was sent.
+func ingestHost(q url.Values) *storage.HostInfo {
+	h := storage.HostInfo{Name: q.Get("host"), OS: q.Get("host_os"), User: q.Get("host_user")}
+	if h == (storage.HostInfo{}) {
+		return nil
+	}
+	return &h
+}
+
+// ingestSourceName names the /health source of lines pushed into namespace,
+// so each forwarding namespace is reported on its own.
+func ingestSourceName(namespace string) string {
+	if namespace == "" {
+		return ingestSource
+	}
+	return ingestSource + ":" + namespace
+}
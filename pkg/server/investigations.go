@@ -0,0 +1,220 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// handleInvestigations handles GET /investigations (list) and POST
+// /investigations (create or replace)
+func (s *Server) handleInvestigations(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		all, err := s.storage.ListInvestigations()
+		if err != nil {
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		invs := make([]storage.Investigation, 0, len(all))
+		for _, inv := range all {
+			if owns(r.Context(), inv.Namespace) {
+				invs = append(invs, inv)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"investigations": invs})
+	case http.MethodPost:
+		var inv storage.Investigation
+		if err := json.NewDecoder(r.Body).Decode(&inv); err != nil {
+			writeError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		s.saveInvestigation(r.Context(), w, &inv, http.StatusCreated)
+	default:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleInvestigation handles GET, PUT and DELETE /investigations/{name} and
+// GET /investigations/{name}/export
+func (s *Server) handleInvestigation(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/investigations/")
+	if n, ok := strings.CutSuffix(name, "/export"); ok {
+		s.handleInvestigationExport(w, r, n)
+		return
+	}
+	if name == "" {
+		writeError(w, "Missing investigation name", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		inv, ok := s.ownedInvestigation(w, r, name)
+		if !ok {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(inv)
+	case http.MethodPut:
+		var inv storage.Investigation
+		if err := json.NewDecoder(r.Body).Decode(&inv); err != nil {
+			writeError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		inv.Name = name
+		s.saveInvestigation(r.Context(), w, &inv, http.StatusOK)
+	case http.MethodDelete:
+		if _, ok := s.ownedInvestigation(w, r, name); !ok {
+			return
+		}
+		err := s.storage.DeleteInvestigation(name)
+		if errors.Is(err, storage.ErrNotFound) {
+			writeError(w, "Investigation not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ownedInvestigation returns the named investigation, or writes 404 and
+// reports false when it does not exist or belongs to another namespace.
+func (s *Server) ownedInvestigation(w http.ResponseWriter, r *http.Request, name string) (*storage.Investigation, bool) {
+	inv, err := s.storage.GetInvestigation(name)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && !owns(r.Context(), inv.Namespace)) {
+		writeError(w, "Investigation not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return inv, true
+}
+
+// saveInvestigation validates and stores inv in the caller's namespace, then
+// writes it back with status. An investigation of another namespace is not
+// replaced.
+func (s *Server) saveInvestigation(ctx context.Context, w http.ResponseWriter, inv *storage.Investigation, status int) {
+	if strings.TrimSpace(inv.Name) == "" {
+		writeError(w, "Missing investigation name", http.StatusBadRequest)
+		return
+	}
+	existing, err := s.storage.GetInvestigation(inv.Name)
+	switch {
+	case err == nil && !owns(ctx, existing.Namespace):
+		writeError(w, "Investigation name is taken by another namespace", http.StatusConflict)
+		return
+	case err == nil && inv.Namespace == "":
+		inv.Namespace = existing.Namespace
+	case err != nil && !errors.Is(err, storage.ErrNotFound):
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	inv.Namespace = namespaceFor(ctx, inv.Namespace)
+
+	if err := s.storage.SaveInvestigation(inv); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(inv)
+}
+
+// handleInvestigationExport renders an investigation as a Markdown document
+// with its notes, queries and referenced entries.
+func (s *Server) handleInvestigationExport(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if format := r.URL.Query().Get("format"); format != "" && format != "markdown" {
+		writeError(w, "Unsupported format (use markdown)", http.StatusBadRequest)
+		return
+	}
+
+	inv, ok := s.ownedInvestigation(w, r, name)
+	if !ok {
+		return
+	}
+
+	entries, err := s.storage.GetEntries(inv.EntryIDs)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	entries = visibleEntries(r.Context(), entries)
+	annotations, err := s.storage.GetAnnotations(inv.EntryIDs)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".md"))
+	w.Write([]byte(renderInvestigationMarkdown(inv, entries, annotations)))
+}
+
+// renderInvestigationMarkdown formats an investigation as Markdown.
+func renderInvestigationMarkdown(inv *storage.Investigation, entries []*storage.LogEntry, annotations map[string]storage.Annotation) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", inv.Name)
+	fmt.Fprintf(&b, "_Updated %s_\n\n", inv.UpdatedAt.UTC().Format(time.RFC3339))
+	if notes := strings.TrimSpace(inv.Notes); notes != "" {
+		fmt.Fprintf(&b, "%s\n\n", notes)
+	}
+
+	if len(inv.Queries) > 0 {
+		b.WriteString("## Queries\n\n")
+		for _, q := range inv.Queries {
+			fmt.Fprintf(&b, "- `%s`\n", strings.ReplaceAll(q, "`", "'"))
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("## Entries\n\n")
+	if len(entries) == 0 {
+		b.WriteString("_No entries._\n")
+	} else {
+		b.WriteString("| Time | Level | Message | Note |\n|---|---|---|---|\n")
+		for _, e := range entries {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n",
+				e.Timestamp.UTC().Format(time.RFC3339Nano),
+				markdownCell(e.Level),
+				markdownCell(e.Message),
+				markdownCell(annotations[e.ID].Note))
+		}
+	}
+	unique := make(map[string]bool, len(inv.EntryIDs))
+	for _, id := range inv.EntryIDs {
+		unique[id] = true
+	}
+	if missing := len(unique) - len(entries); missing > 0 {
+		fmt.Fprintf(&b, "\n_%d referenced entries are no longer stored._\n", missing)
+	}
+
+	return b.String()
+}
+
+// markdownCell escapes a value for use inside a Markdown table cell.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	s = strings.ReplaceAll(s, "\r\n", "<br>")
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
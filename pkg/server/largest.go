@@ -0,0 +1,47 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const defaultLargestLimit = 20
+
+// handleLargest handles GET /stats/largest?limit=20: the largest stored
+// entries and the fields that make them large. Takes the same query,
+// session, start and end parameters as /fields/{name}/stats.
+func (s *Server) handleLargest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	limit := defaultLargestLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxQueryLimit {
+			writeError(w, fmt.Sprintf("Invalid limit (use 1 to %d)", maxQueryLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	filter, tr, err := s.buildFilter(r.Context(), q.Get("query"), q.Get("session"), parseTime(q.Get("start")), parseTime(q.Get("end")))
+	if err != nil {
+		writeQueryError(w, "Invalid query", err)
+		return
+	}
+
+	entries, err := s.storage.GetLargestEntries(r.Context(), filter, tr, limit)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entries": entries,
+	})
+}
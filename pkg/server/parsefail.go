@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+const defaultParseFailureLimit = 100
+
+// parseFailureReason counts the stored failures of one format and reason.
+type parseFailureReason struct {
+	Format   string    `json:"format"`
+	Reason   string    `json:"reason"`
+	Count    int       `json:"count"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// handleParseFailures handles GET /parse-failures?limit=100: counts of the
+// quarantined lines per format and reason, and the most recent lines.
+// Non-admin tokens only see their namespace's failures.
+func (s *Server) handleParseFailures(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	limit := defaultParseFailureLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxQueryLimit {
+			writeError(w, fmt.Sprintf("Invalid limit (use 0 to %d)", maxQueryLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	p := principalFrom(r.Context())
+	total := 0
+	failures := []storage.ParseFailure{}
+	byReason := make(map[[2]string]*parseFailureReason)
+	err := s.storage.ScanParseFailures(func(f storage.ParseFailure) error {
+		if p != nil && !p.admin && f.Namespace != p.namespace {
+			return nil
+		}
+		total++
+		if len(failures) < limit {
+			failures = append(failures, f)
+		}
+		key := [2]string{f.Format, f.Reason}
+		if byReason[key] == nil {
+			// Failures are scanned newest first.
+			byReason[key] = &parseFailureReason{Format: f.Format, Reason: f.Reason, LastSeen: f.Time}
+		}
+		byReason[key].Count++
+		return nil
+	})
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	reasons := make([]*parseFailureReason, 0, len(byReason))
+	for _, reason := range byReason {
+		reasons = append(reasons, reason)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if reasons[i].Count != reasons[j].Count {
+			return reasons[i].Count > reasons[j].Count
+		}
+		return reasons[i].LastSeen.After(reasons[j].LastSeen)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"total":    total,
+		"reasons":  reasons,
+		"failures": failures,
+	})
+}
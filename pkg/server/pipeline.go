@@ -0,0 +1,189 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mchurichi/peek/pkg/parser"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// pipelineTraceLimit bounds the traces kept for GET /pipeline-debug; older
+// ones are dropped.
+const pipelineTraceLimit = 500
+
+// Outcomes of a traced entry.
+const (
+	PipelineStored    = "stored"
+	PipelineDuplicate = "duplicate"
+	PipelineUnstored  = "not stored" // shown live while the disk guard paused storing
+	PipelineFailed    = "failed"
+)
+
+// PipelineTrace records how one sampled entry went through ingestion: the
+// parser that matched it, the transforms applied after parsing and how long
+// parsing and storing took.
+type PipelineTrace struct {
+	EntryID   string    `json:"entry_id"`
+	Time      time.Time `json:"time"`
+	Input     string    `json:"input"` // stdin, watch, demo, ingest or grpc
+	Source    string    `json:"source,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	// Format is the format asked for, auto or explicit, and Parser the one
+	// the entry was parsed as ("raw" when auto mode recognized none).
+	Format string `json:"format"`
+	Parser string `json:"parser"`
+	Lines  int    `json:"lines"` // input lines joined into the entry
+	// Transforms lists what changed the entry after parsing, in order:
+	// aliases ("alias: from -> to") and truncation.
+	Transforms []string `json:"transforms"`
+	Fields     []string `json:"fields"` // field names as stored
+	ParseUS    int64    `json:"parse_us"`
+	// StoreUS is the write the entry was part of: a batch for pushed lines.
+	StoreUS int64  `json:"store_us"`
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+}
+
+// NewPipelineTrace starts the trace of entry, parsed from lines input
+// lines in format as info describes, in parse. truncated reports whether
+// values longer than maxValueSize were cut. The caller fills in the input,
+// the entry ID once it is assigned, and the store latency and outcome.
+func NewPipelineTrace(entry *storage.LogEntry, info parser.ParseInfo, format string, lines int, truncated bool, maxValueSize int, parse time.Duration) PipelineTrace {
+	t := PipelineTrace{
+		Time:       time.Now(),
+		Format:     format,
+		Parser:     info.Parser,
+		Lines:      lines,
+		Transforms: []string{},
+		Fields:     slices.Sorted(maps.Keys(entry.Fields)),
+		ParseUS:    parse.Microseconds(),
+	}
+	for _, a := range info.Aliases {
+		t.Transforms = append(t.Transforms, "alias: "+a)
+	}
+	if truncated {
+		t.Transforms = append(t.Transforms, fmt.Sprintf("truncate: values over %d bytes", maxValueSize))
+	}
+	if t.Fields == nil {
+		t.Fields = []string{}
+	}
+	return t
+}
+
+// pipelineDebug samples ingested entries and keeps their traces.
+type pipelineDebug struct {
+	mu     sync.Mutex
+	every  int    // trace one in every entries; 0 disables
+	seen   uint64 // entries offered to sample
+	traces []PipelineTrace
+	next   int // ring position of the next trace once traces is full
+}
+
+// SetPipelineDebug traces one in every ingested entries for GET
+// /pipeline-debug, starting with the next one; 0 disables tracing and drops
+// the traces kept.
+func (s *Server) SetPipelineDebug(every int) {
+	p := &s.pipeline
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.every, p.seen, p.traces, p.next = every, 0, nil, 0
+}
+
+// SamplePipeline reports whether the entry being ingested should be traced
+// and handed to RecordPipeline.
+func (s *Server) SamplePipeline() bool {
+	p := &s.pipeline
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.every <= 0 {
+		return false
+	}
+	p.seen++
+	return (p.seen-1)%uint64(p.every) == 0
+}
+
+// RecordPipeline keeps t, dropping the oldest trace past
+// pipelineTraceLimit.
+func (s *Server) RecordPipeline(t PipelineTrace) {
+	p := &s.pipeline
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.every <= 0 {
+		return
+	}
+	if len(p.traces) < pipelineTraceLimit {
+		p.traces = append(p.traces, t)
+		return
+	}
+	p.traces[p.next] = t
+	p.next = (p.next + 1) % pipelineTraceLimit
+}
+
+// pipelineTraces returns the traces kept, newest first, and the sampling
+// rate; every is 0 when tracing is off.
+func (s *Server) pipelineTraces() (traces []PipelineTrace, every int) {
+	p := &s.pipeline
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	traces = make([]PipelineTrace, 0, len(p.traces))
+	for i := len(p.traces) - 1; i >= 0; i-- {
+		traces = append(traces, p.traces[(p.next+i)%len(p.traces)])
+	}
+	return traces, p.every
+}
+
+// handlePipelineDebug handles GET /pipeline-debug: the traces of sampled
+// entries, newest first, optionally only the one of ?id= and at most
+// ?limit= of them. When authentication is enabled only admin tokens may
+// read them, as they span namespaces.
+func (s *Server) handlePipelineDebug(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if p := principalFrom(r.Context()); p != nil && !p.admin {
+		writeError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	q := r.URL.Query()
+	limit := pipelineTraceLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > pipelineTraceLimit {
+			writeError(w, fmt.Sprintf("Invalid limit (use 1 to %d)", pipelineTraceLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	traces, every := s.pipelineTraces()
+	if every == 0 {
+		writeError(w, "Pipeline debugging is off (start peek with --debug-pipeline)", http.StatusNotFound)
+		return
+	}
+	if id := q.Get("id"); id != "" {
+		matched := traces[:0]
+		for _, t := range traces {
+			if t.EntryID == id {
+				matched = append(matched, t)
+			}
+		}
+		traces = matched
+	}
+	if len(traces) > limit {
+		traces = traces[:limit]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sample_every": every,
+		"traces":       traces,
+	})
+}
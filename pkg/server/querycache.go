@@ -0,0 +1,93 @@
+package server
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// queryCacheSize is how many /query results are kept. Identical requests
+// come from UI refreshes and several tabs on one view, so a handful of
+// slots covers them.
+const queryCacheSize = 64
+
+// queryCacheKey identifies a /query request: the caller's scope, the query
+// and time range, and the page.
+type queryCacheKey struct {
+	scope     string
+	session   string
+	query     string
+	start     string
+	end       string
+	limit     int
+	offset    int
+	countMode string
+	// afterCursor is the ?after_cursor= position of a follow request.
+	afterCursor string
+}
+
+// queryResult is a cached page of results and the storage generation it was
+// read at.
+type queryResult struct {
+	entries    []*storage.LogEntry
+	total      int
+	generation uint64
+}
+
+// queryCache is a small LRU of /query results. A result is only served while
+// the storage generation it was read at is current, so any store, delete or
+// rewrite invalidates every cached page at once.
+type queryCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // front is most recently used; values are *queryCacheItem
+	items map[queryCacheKey]*list.Element
+}
+
+type queryCacheItem struct {
+	key    queryCacheKey
+	result queryResult
+}
+
+func newQueryCache(size int) *queryCache {
+	return &queryCache{size: size, order: list.New(), items: make(map[queryCacheKey]*list.Element)}
+}
+
+// get returns the result cached for key if it was read at generation.
+func (c *queryCache) get(key queryCacheKey, generation uint64) (queryResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return queryResult{}, false
+	}
+	item := el.Value.(*queryCacheItem)
+	if item.result.generation != generation {
+		c.order.Remove(el)
+		delete(c.items, key)
+		return queryResult{}, false
+	}
+	c.order.MoveToFront(el)
+	return item.result, true
+}
+
+// put stores result under key, evicting the least recently used result
+// when the cache is full.
+func (c *queryCache) put(key queryCacheKey, result queryResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		el.Value.(*queryCacheItem).result = result
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&queryCacheItem{key: key, result: result})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*queryCacheItem).key)
+	}
+}
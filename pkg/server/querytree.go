@@ -0,0 +1,120 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/mchurichi/peek/pkg/query"
+)
+
+// handleQueryParse handles POST /query/parse: {"query": "..."} in, the
+// query's tree in its JSON form and canonical text out. Together with
+// /query/format it lets a form-based filter builder stay in sync with the
+// query text box.
+func (s *Server) handleQueryParse(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Query string `json:"query"`
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxQueryBodyBytes)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	n, err := query.ParseAST(req.Query)
+	if err != nil {
+		writeQueryError(w, "Invalid query", err)
+		return
+	}
+	ast, err := query.MarshalAST(n)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"ast":   json.RawMessage(ast),
+		"query": n.String(),
+	})
+}
+
+// handleQueryFormat handles POST /query/format: {"ast": {...}} in, the query
+// text of the tree out.
+func (s *Server) handleQueryFormat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		AST json.RawMessage `json:"ast"`
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxQueryBodyBytes)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.AST) == 0 {
+		writeError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	n, err := query.UnmarshalAST(req.AST)
+	if err != nil {
+		writeQueryError(w, "Invalid query tree", err)
+		return
+	}
+	text, err := query.Format(n)
+	if err != nil {
+		writeQueryError(w, "Invalid query tree", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"query": text})
+}
+
+// completeSample caps the entries /complete reads for field names and top
+// values, so completion stays fast while typing.
+const completeSample = 2000
+
+// handleComplete handles GET /complete?q=...&cursor=N: candidate field
+// names, operators and top values for the query token under the byte
+// offset cursor (default: the end of q). Candidates come from the field
+// catalog of up to completeSample entries, scoped like /fields by the
+// optional session, start and end parameters.
+func (s *Server) handleComplete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	input := q.Get("q")
+	cursor := len(input)
+	if v := q.Get("cursor"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > len(input) {
+			writeError(w, "Invalid cursor (use a byte offset into q)", http.StatusBadRequest)
+			return
+		}
+		cursor = n
+	}
+	filter, tr, err := s.buildFilter(r.Context(), "", q.Get("session"), parseTime(q.Get("start")), parseTime(q.Get("end")))
+	if err != nil {
+		writeQueryError(w, "Invalid query", err)
+		return
+	}
+
+	fields, _, err := s.storage.GetFieldsSampled(r.Context(), filter, tr, completeSample)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(query.Complete(input, cursor, fields))
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mchurichi/peek/pkg/query"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// handleScheduled handles GET /scheduled (list) and POST /scheduled (create or replace)
+func (s *Server) handleScheduled(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		all, err := s.storage.ListScheduledQueries()
+		if err != nil {
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		qs := make([]storage.ScheduledQuery, 0, len(all))
+		for _, q := range all {
+			if owns(r.Context(), q.Namespace) {
+				qs = append(qs, q)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"scheduled": qs})
+	case http.MethodPost:
+		var q storage.ScheduledQuery
+		if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+			writeError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		s.saveScheduled(r.Context(), w, &q, http.StatusCreated)
+	default:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleScheduledQuery handles GET, PUT and DELETE /scheduled/{name} and
+// GET /scheduled/{name}/series
+func (s *Server) handleScheduledQuery(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/scheduled/")
+	if n, ok := strings.CutSuffix(name, "/series"); ok {
+		s.handleScheduledSeries(w, r, n)
+		return
+	}
+	if name == "" {
+		writeError(w, "Missing scheduled query name", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		q, ok := s.ownedScheduled(w, r, name)
+		if !ok {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(q)
+	case http.MethodPut:
+		var q storage.ScheduledQuery
+		if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+			writeError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		q.Name = name
+		s.saveScheduled(r.Context(), w, &q, http.StatusOK)
+	case http.MethodDelete:
+		if _, ok := s.ownedScheduled(w, r, name); !ok {
+			return
+		}
+		err := s.storage.DeleteScheduledQuery(name)
+		if errors.Is(err, storage.ErrNotFound) {
+			writeError(w, "Scheduled query not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleScheduledSeries returns the recorded counts of a scheduled query,
+// optionally bounded by start/end (RFC3339).
+func (s *Server) handleScheduledSeries(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := s.ownedScheduled(w, r, name); !ok {
+		return
+	}
+
+	var tr *storage.TimeRange
+	start, end := parseTime(r.URL.Query().Get("start")), parseTime(r.URL.Query().Get("end"))
+	if !start.IsZero() || !end.IsZero() {
+		tr = &storage.TimeRange{Start: start, End: end}
+	}
+
+	points, err := s.storage.GetSeries(name, tr)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"name": name, "points": points})
+}
+
+// ownedScheduled returns the named scheduled query, or writes 404 and
+// reports false when it does not exist or belongs to another namespace.
+func (s *Server) ownedScheduled(w http.ResponseWriter, r *http.Request, name string) (*storage.ScheduledQuery, bool) {
+	q, err := s.storage.GetScheduledQuery(name)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && !owns(r.Context(), q.Namespace)) {
+		writeError(w, "Scheduled query not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return q, true
+}
+
+// saveScheduled validates and stores q in the caller's namespace, whose
+// entries are the only ones its runs count, then writes it back with status.
+// A scheduled query of another namespace is not replaced.
+func (s *Server) saveScheduled(ctx context.Context, w http.ResponseWriter, q *storage.ScheduledQuery, status int) {
+	if err := storage.ValidateScheduledQuery(q); err != nil {
+		writeError(w, fmt.Sprintf("Invalid scheduled query: %v", err), http.StatusBadRequest)
+		return
+	}
+	if _, err := query.Parse(q.Query); err != nil {
+		writeQueryError(w, "Invalid query", err)
+		return
+	}
+	existing, err := s.storage.GetScheduledQuery(q.Name)
+	switch {
+	case err == nil && !owns(ctx, existing.Namespace):
+		writeError(w, "Scheduled query name is taken by another namespace", http.StatusConflict)
+		return
+	case err == nil && q.Namespace == "":
+		q.Namespace = existing.Namespace
+	case err != nil && !errors.Is(err, storage.ErrNotFound):
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	q.Namespace = namespaceFor(ctx, q.Namespace)
+
+	if err := s.storage.SaveScheduledQuery(q); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(q)
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultSchemaExamples = 3
+	maxSchemaExamples     = 20
+)
+
+// handleSchemas handles GET /schemas?examples=3: the distinct field-name
+// shapes of stored entries with counts and recent examples. Takes the same
+// query, session, start and end parameters as /fields/{name}/stats.
+func (s *Server) handleSchemas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	examples := defaultSchemaExamples
+	if v := q.Get("examples"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxSchemaExamples {
+			writeError(w, fmt.Sprintf("Invalid examples (use 0 to %d)", maxSchemaExamples), http.StatusBadRequest)
+			return
+		}
+		examples = n
+	}
+	filter, tr, err := s.buildFilter(r.Context(), q.Get("query"), q.Get("session"), parseTime(q.Get("start")), parseTime(q.Get("end")))
+	if err != nil {
+		writeQueryError(w, "Invalid query", err)
+		return
+	}
+
+	schemas, other, err := s.storage.GetSchemas(r.Context(), filter, tr, examples)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"schemas": schemas,
+		"other":   other,
+	})
+}
@@ -1,159 +1,414 @@
 package server
 
 import (
-	_ "embed"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/mchurichi/peek/pkg/parser"
 	"github.com/mchurichi/peek/pkg/query"
 	"github.com/mchurichi/peek/pkg/storage"
 )
 
-//go:embed index.html
-var indexHTML string
-
-//go:embed van.min.js
-var vanJS []byte
-
 // Server represents the HTTP server
 type Server struct {
 	storage       *storage.BadgerStorage
 	upgrader      websocket.Upgrader
 	clients       map[*websocket.Conn]*client
+	streams       map[*liveStream]struct{} // gRPC Stream calls
 	mu            sync.RWMutex
 	defaultFilter query.Filter // Default filter applied to all queries (e.g., for fresh mode)
+	session       string       // Collect session shown in fresh mode; empty shows all logs
+	uiConfig      UIConfig
+	ui            *uiAssets // set with SetUIDir
+	security      SecurityConfig
+	tokens        []Token      // API tokens; empty disables authentication
+	reload        func() error // re-reads parsing config for POST /admin/reload; nil disables
+	peers         []Peer       // remote instances /query and live tails fan out to
+	queries       *queryCache  // recent /query pages, invalidated by the storage generation
+	// auditRetention keeps audit records of queries this long; 0 disables.
+	auditRetention time.Duration
+
+	// arrivalsMu guards arrivals, which BroadcastLog closes to wake /query
+	// requests waiting for new entries.
+	arrivalsMu sync.Mutex
+	arrivals   chan struct{}
+
+	sourcesMu sync.Mutex
+	sources   map[string]*SourceStatus // inputs reported in /health, by name
+
+	// ingestMu guards the ingest settings, which live reload may swap.
+	ingestMu     sync.RWMutex
+	newID        parser.IDGenerator
+	dedupeWindow time.Duration // skip pushed lines seen within this window; 0 disables
+	maxValueSize int           // truncate longer pushed values; 0 disables
+	detector     *parser.Detector
+	// sourceFormats maps ?source= labels to their default format.
+	sourceFormats map[string]string
+
+	pipeline pipelineDebug // sampled ingestion traces for /pipeline-debug
+
+	// Shutdown state: httpServer and stopped are guarded by mu. workers
+	// tracks the broadcast worker and WebSocket goroutines, which Shutdown
+	// waits for so none of them touches storage after it is closed.
+	httpServer *http.Server
+	stopped    bool
+	stop       chan struct{}
+	draining   chan struct{} // closed when Shutdown starts; ends waiting /query requests
+	workers    sync.WaitGroup
+}
+
+// UIConfig holds server-pushed defaults for the web UI's initial view.
+type UIConfig struct {
+	DefaultTimePreset string   `json:"default_time_preset,omitempty"`
+	DefaultQuery      string   `json:"default_query,omitempty"`
+	PinnedColumns     []string `json:"pinned_columns"`
+	Theme             string   `json:"theme,omitempty"`
+	AutoScroll        bool     `json:"auto_scroll"`
 }
 
 type client struct {
-	conn      *websocket.Conn
-	query     string
+	conn     *websocket.Conn
+	query    string
+	scope    query.Filter // fixed at connect: fresh-mode session and token namespace
+	scopeKey string       // identifies scope; clients with equal keys see the same entries
+	// principal and remoteAddr identify the caller in audit records.
+	principal  *principal
+	remoteAddr string
+	// filter and filterKey are guarded by Server.mu. filterKey identifies
+	// the subscription (scope, query and time range) so BroadcastLog
+	// evaluates each distinct filter once per entry.
 	filter    query.Filter
+	filterKey string
 	timeRange *storage.TimeRange
+	// federated marks a connection from a federating server, which only
+	// gets local entries. stopPeers ends the subscription's peer tails; it
+	// belongs to readPump.
+	federated bool
+	stopPeers chan struct{}
 	// send carries either *storage.LogEntry (live stream) or map[string]interface{} (results/control).
 	// All writes to conn are serialised through writePump which drains this channel.
 	send chan interface{}
 	done chan struct{}
+
+	// mu guards the live-tail pause state below.
+	mu      sync.Mutex
+	paused  bool
+	backlog []*storage.LogEntry // live entries held while paused, up to pauseBacklogLimit
+	skipped int                 // live entries dropped because the backlog was full
 }
 
-// NewServer creates a new HTTP server
-func NewServer(storage *storage.BadgerStorage, startTime *time.Time) *Server {
+// pauseBacklogLimit bounds how many live entries are held per paused client.
+const pauseBacklogLimit = 1000
+
+// Bounds on a /query request: the JSON body and the page size. Query text
+// itself is bounded by the query package's complexity limits.
+const (
+	maxQueryBodyBytes = 1 << 20
+	maxQueryLimit     = 10000
+)
+
+// resumeSendTimeout bounds how long resume waits to queue the backlog.
+const resumeSendTimeout = 5 * time.Second
+
+// NewServer creates a new HTTP server. When session is non-empty the server
+// runs in fresh mode and only shows entries ingested by that session.
+func NewServer(storage *storage.BadgerStorage, session string) *Server {
 	s := &Server{
 		storage: storage,
+		session: session,
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true // Allow all origins for local dev
 			},
 		},
-		clients: make(map[*websocket.Conn]*client),
+		clients:  make(map[*websocket.Conn]*client),
+		streams:  make(map[*liveStream]struct{}),
+		uiConfig: UIConfig{AutoScroll: true},
+		ui:       newUIAssets(embeddedUI, false),
+		stop:     make(chan struct{}),
+		draining: make(chan struct{}),
+		queries:  newQueryCache(queryCacheSize),
+		sources:  make(map[string]*SourceStatus),
 	}
 
-	// If startTime is provided, create a default filter for fresh mode
-	if startTime != nil {
-		s.defaultFilter = &query.TimestampRangeFilter{
-			Start: *startTime,
-			End:   time.Time{}, // No end time
-		}
+	// Fresh mode filters by session rather than timestamp so piped logs with
+	// historical timestamps still show up.
+	if session != "" {
+		s.defaultFilter = &query.SessionFilter{Session: session}
 	}
 
 	return s
 }
 
+// SetUIConfig sets the defaults served by GET /ui-config.
+func (s *Server) SetUIConfig(cfg UIConfig) {
+	s.uiConfig = cfg
+}
+
+// SetIDGenerator sets how entries pushed to /ingest get their IDs; nil keeps
+// the parser's random IDs.
+func (s *Server) SetIDGenerator(g parser.IDGenerator) {
+	s.ingestMu.Lock()
+	defer s.ingestMu.Unlock()
+	s.newID = g
+}
+
+// SetDedupeWindow makes /ingest skip lines already ingested within window;
+// zero disables duplicate detection.
+func (s *Server) SetDedupeWindow(window time.Duration) {
+	s.ingestMu.Lock()
+	defer s.ingestMu.Unlock()
+	s.dedupeWindow = window
+}
+
+// SetMaxValueSize makes /ingest truncate messages and field values longer
+// than n bytes; zero disables truncation.
+func (s *Server) SetMaxValueSize(n int) {
+	s.ingestMu.Lock()
+	defer s.ingestMu.Unlock()
+	s.maxValueSize = n
+}
+
+// SetDetector sets the format detector /ingest parses with, so pushed lines
+// can use the configured custom formats. Without one the built-in formats
+// are used.
+func (s *Server) SetDetector(d *parser.Detector) {
+	s.ingestMu.Lock()
+	defer s.ingestMu.Unlock()
+	s.detector = d
+}
+
+// SetSourceFormats sets the format /ingest parses lines pushed with
+// ?source=NAME as when no ?format= is given; other sources use auto.
+func (s *Server) SetSourceFormats(formats map[string]string) {
+	s.ingestMu.Lock()
+	defer s.ingestMu.Unlock()
+	s.sourceFormats = formats
+}
+
+// sourceFormat returns the format set for source, or "auto".
+func (s *Server) sourceFormat(source string) string {
+	s.ingestMu.RLock()
+	defer s.ingestMu.RUnlock()
+	if f, ok := s.sourceFormats[source]; ok {
+		return f
+	}
+	return "auto"
+}
+
+// ingestDetector returns the detector set with SetDetector, or one that
+// knows only the built-in formats.
+func (s *Server) ingestDetector() *parser.Detector {
+	s.ingestMu.RLock()
+	defer s.ingestMu.RUnlock()
+	if s.detector == nil {
+		return parser.NewDetector()
+	}
+	return s.detector
+}
+
+// ingestSettings returns the current ID generator, dedupe window and value
+// size limit.
+func (s *Server) ingestSettings() (parser.IDGenerator, time.Duration, int) {
+	s.ingestMu.RLock()
+	defer s.ingestMu.RUnlock()
+	return s.newID, s.dedupeWindow, s.maxValueSize
+}
+
 // Start starts the HTTP server
 func (s *Server) Start(port int) error {
+	addr := fmt.Sprintf(":%d", port)
+	log.Printf("Starting server on http://localhost%s", addr)
+
+	srv := &http.Server{Addr: addr, Handler: s.routes()}
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return http.ErrServerClosed
+	}
+	s.httpServer = srv
+	s.mu.Unlock()
+
+	return srv.ListenAndServe()
+}
+
+// Shutdown stops the server in flush-then-close order: it stops accepting
+// connections and waits for in-flight requests such as /ingest pushes to
+// store their entries, then stops the broadcast worker and closes live
+// WebSocket connections. Start returns http.ErrServerClosed. Storage stays
+// open; the caller closes it once Shutdown returns.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return nil
+	}
+	s.stopped = true
+	srv := s.httpServer
+	s.mu.Unlock()
+	close(s.draining)
+
+	var err error
+	if srv != nil {
+		err = srv.Shutdown(ctx)
+	}
+	close(s.stop)
+
+	// Hijacked WebSocket connections are not tracked by http.Server.
+	s.mu.RLock()
+	for conn := range s.clients {
+		conn.Close()
+	}
+	s.mu.RUnlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+	return err
+}
+
+// routes registers every endpoint behind token authentication.
+func (s *Server) routes() http.Handler {
 	mux := http.NewServeMux()
 
 	// Serve static web UI
 	mux.HandleFunc("/", s.handleIndex)
 
-	// Serve bundled VanJS (avoids CDN dependency)
+	// Serve the rest of the UI bundle, including VanJS (avoids CDN
+	// dependency); /van.min.js is its older path
+	mux.HandleFunc(assetsPath, s.handleAsset)
 	mux.HandleFunc("/van.min.js", s.handleVanJS)
 
 	// API endpoints
 	mux.HandleFunc("/health", s.handleHealth)
 	mux.HandleFunc("/stats", s.handleStats)
+	mux.HandleFunc("/stats/history", s.handleStatsHistory)
+	mux.HandleFunc("/stats/largest", s.handleLargest)
+	mux.HandleFunc("/schemas", s.handleSchemas)
 	mux.HandleFunc("/query", s.handleQuery)
+	mux.HandleFunc("/query/parse", s.handleQueryParse)
+	mux.HandleFunc("/query/format", s.handleQueryFormat)
+	mux.HandleFunc("/complete", s.handleComplete)
 	mux.HandleFunc("/fields", s.handleFields)
+	mux.HandleFunc("/fields/", s.handleFieldStats)
+	mux.HandleFunc("/raw/", s.handleRaw)
+	mux.HandleFunc("/download", s.handleDownload)
+	mux.HandleFunc("/ui-config", s.handleUIConfig)
+	mux.HandleFunc("/views", s.handleViews)
+	mux.HandleFunc("/views/", s.handleView)
+	mux.HandleFunc("/entries/", s.handleEntry)
+	mux.HandleFunc("/annotations", s.handleAnnotations)
+	mux.HandleFunc("/investigations", s.handleInvestigations)
+	mux.HandleFunc("/investigations/", s.handleInvestigation)
+	mux.HandleFunc("/scheduled", s.handleScheduled)
+	mux.HandleFunc("/scheduled/", s.handleScheduledQuery)
+	mux.HandleFunc("/digest", s.handleDigest)
+	mux.HandleFunc("/trace/", s.handleTrace)
+	mux.HandleFunc("/ingest", s.handleIngest)
+	mux.HandleFunc("/parse-failures", s.handleParseFailures)
+	mux.HandleFunc("/pipeline-debug", s.handlePipelineDebug)
 	mux.HandleFunc("/logs", s.handleWebSocket)
+	mux.HandleFunc("/logs/", s.handleLogEntry)
+	mux.HandleFunc("/admin/reload", s.handleReload)
 
-	addr := fmt.Sprintf(":%d", port)
-	log.Printf("Starting server on http://localhost%s", addr)
-
-	return http.ListenAndServe(addr, mux)
-}
-
-// handleVanJS serves the bundled VanJS library
-func (s *Server) handleVanJS(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/javascript")
-	w.Header().Set("Cache-Control", "max-age=86400")
-	if _, err := w.Write(vanJS); err != nil {
-		log.Printf("error writing van.js response: %v", err)
-	}
-}
-
-// handleIndex serves the web UI
-func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/html")
-	w.Write([]byte(indexHTML))
+	return s.secure(s.requireAuth(mux))
 }
 
-// handleHealth handles GET /health
-func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	stats, err := s.storage.GetStats()
+// handleStats handles GET /stats. The per-source and per-session breakdown
+// names inputs of every namespace, so only admin tokens get it.
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.storage.CachedStats()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	response := map[string]interface{}{
-		"status":         "ok",
-		"logs_stored":    stats.TotalLogs,
-		"db_size_bytes":  int64(stats.DBSizeMB * 1024 * 1024),
+	if namespaceFilter(r.Context()) != nil {
+		stats.Sources, stats.Sessions = []storage.VolumeStats{}, []storage.VolumeStats{}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(stats)
 }
 
-// handleStats handles GET /stats
-func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
-	stats, err := s.storage.GetStats()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+// handleStatsHistory handles GET /stats/history?window=24h: the stats
+// snapshots recorded within the window, oldest first.
+func (s *Server) handleStatsHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	response := map[string]interface{}{
-		"total_logs":  stats.TotalLogs,
-		"db_size_mb":  stats.DBSizeMB,
-		"levels":      stats.Levels,
+	window := storage.StatsHistorySpan
+	if v := r.URL.Query().Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeError(w, "Invalid window", http.StatusBadRequest)
+			return
+		}
+		window = min(d, storage.StatsHistorySpan)
+	}
+
+	now := time.Now()
+	snapshots, err := s.storage.StatsHistory(now.Add(-window), now)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"window":    window.String(),
+		"interval":  storage.StatsHistoryInterval.String(),
+		"snapshots": snapshots,
+	})
 }
 
-// handleQuery handles POST /query
+// handleQuery handles POST /query. With ?after_cursor= it only returns
+// entries after that position, and with ?wait= it holds the request until
+// such entries arrive or the wait ends. Entries arriving with timestamps
+// before the cursor are not returned; see logCursor.
 func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var req struct {
-		Query  string `json:"query"`
-		Limit  int    `json:"limit"`
-		Offset int    `json:"offset"`
-		Start  string `json:"start"`
-		End    string `json:"end"`
+		Query     string `json:"query"`
+		Limit     int    `json:"limit"`
+		Offset    int    `json:"offset"`
+		Start     string `json:"start"`
+		End       string `json:"end"`
+		CountMode string `json:"count_mode"` // exact (default) or none
+		Session   string `json:"session"`
 	}
 
+	r.Body = http.MaxBytesReader(w, r.Body, maxQueryBodyBytes)
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, fmt.Sprintf("Request body is larger than %d bytes", maxQueryBodyBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		writeError(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
@@ -161,103 +416,356 @@ func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
 	if req.Limit == 0 {
 		req.Limit = 100
 	}
+	if req.Limit < 0 || req.Limit > maxQueryLimit {
+		writeError(w, fmt.Sprintf("Invalid limit (use 1 to %d)", maxQueryLimit), http.StatusBadRequest)
+		return
+	}
+	if req.Offset < 0 {
+		writeError(w, "Invalid offset (must not be negative)", http.StatusBadRequest)
+		return
+	}
+	if req.CountMode != "" && req.CountMode != "exact" && req.CountMode != "none" {
+		writeError(w, "Invalid count_mode (use exact or none)", http.StatusBadRequest)
+		return
+	}
+	skipTotal := req.CountMode == "none"
+
+	params := r.URL.Query()
+	wait, err := parseWait(params.Get("wait"))
+	if err != nil {
+		writeError(w, "Invalid wait ("+err.Error()+")", http.StatusBadRequest)
+		return
+	}
+	var after *logCursor
+	if v := params.Get("after_cursor"); v != "" {
+		if after, err = parseCursor(v); err != nil {
+			writeError(w, "Invalid after_cursor ("+err.Error()+")", http.StatusBadRequest)
+			return
+		}
+	}
+
+	filter, tr, err := s.buildFilter(r.Context(), req.Query, req.Session, parseTime(req.Start), parseTime(req.End))
+	if err != nil {
+		writeQueryError(w, "Invalid query", err)
+		return
+	}
+	if after != nil {
+		filter = &query.AndFilter{Left: filter, Right: after}
+		tr = after.narrow(tr)
+	}
+
+	key := queryCacheKey{
+		scope:       scopeKey(r.Context()),
+		session:     req.Session,
+		query:       req.Query,
+		start:       req.Start,
+		end:         req.End,
+		limit:       req.Limit,
+		offset:      req.Offset,
+		countMode:   req.CountMode,
+		afterCursor: params.Get("after_cursor"),
+	}
+	opts := storage.QueryOptions{
+		TimeRange: tr,
+		Limit:     req.Limit,
+		Offset:    req.Offset,
+		SkipTotal: skipTotal,
+	}
+	federated := s.federates(r)
+	if federated {
+		// Every instance returns its first offset+limit entries and the
+		// page is cut from the merged list.
+		if req.Offset+req.Limit > maxQueryLimit {
+			writeError(w, fmt.Sprintf("Invalid offset (federated queries reach the first %d entries)", maxQueryLimit), http.StatusBadRequest)
+			return
+		}
+		opts.Limit, opts.Offset = req.Offset+req.Limit, 0
+		key.limit, key.offset = opts.Limit, 0
+	}
+
+	// Run the query until it finds entries or the wait ends. Arrivals are
+	// taken before each run so an entry stored during the scan wakes the
+	// next one.
+	started := time.Now()
+	deadline := started.Add(wait)
+	audit := storage.AuditRecord{Time: started, Endpoint: "query", Query: req.Query, Start: req.Start, End: req.End}
+	var (
+		cached      queryResult
+		cacheStatus string
+		took        time.Duration
+	)
+	for {
+		arrivals := s.logArrivals()
+		executionStart := time.Now()
+		cached, cacheStatus, err = s.cachedQuery(r.Context(), key, filter, opts)
+		if err != nil {
+			audit.DurationMS, audit.Error = time.Since(executionStart).Milliseconds(), err.Error()
+			s.audit(principalFrom(r.Context()), r.RemoteAddr, audit)
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		took = time.Since(executionStart)
+		if len(cached.entries) > 0 || !s.waitForLogs(r.Context(), arrivals, deadline) {
+			break
+		}
+	}
+	entries, total := cached.entries, cached.total
+	var (
+		peers    []peerStatus
+		peerMore bool
+	)
+	if federated {
+		var remote [][]*storage.LogEntry
+		remote, peers = s.queryPeers(r.Context(), s.scope(r.Context()), peerQuery{
+			Query:       req.Query,
+			Limit:       opts.Limit,
+			Start:       req.Start,
+			End:         req.End,
+			CountMode:   req.CountMode,
+			afterCursor: params.Get("after_cursor"),
+		})
+		for _, p := range peers {
+			total += p.Total
+			peerMore = peerMore || p.hasMore
+		}
+		merged := mergeEntries(append(remote, entries)...)
+		peerMore = peerMore || len(merged) > opts.Limit
+		entries = pageOf(merged, req.Offset, req.Limit)
+	}
+	audit.DurationMS, audit.Results = took.Milliseconds(), total
+	s.audit(principalFrom(r.Context()), r.RemoteAddr, audit)
+
+	// Ensure entries is never nil for JSON encoding
+	if entries == nil {
+		entries = []*storage.LogEntry{}
+	}
+
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.ID
+	}
+	annotations, err := s.storage.GetAnnotations(ids)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"logs":        entries,
+		"total":       total,
+		"took_ms":     took.Milliseconds(),
+		"annotations": annotations,
+		"cache":       cacheStatus,
+	}
+	if federated {
+		response["peers"] = peers
+	}
+	switch {
+	case len(entries) > 0:
+		response["cursor"] = cursorOf(entries[len(entries)-1])
+	case after != nil:
+		response["cursor"] = params.Get("after_cursor")
+	default:
+		response["cursor"] = formatCursor(time.Now().UnixNano(), "")
+	}
+	if skipTotal {
+		// Only the current page was scanned; report what is known.
+		response["total"] = req.Offset + len(entries)
+		response["has_more"] = total > req.Offset+len(entries) || peerMore
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// cachedQuery runs a /query page, or reuses it if nothing changed since it
+// was read. The generation is read first so a write during the scan
+// invalidates the stored result. The status is "hit" or "miss".
+func (s *Server) cachedQuery(ctx context.Context, key queryCacheKey, filter query.Filter, opts storage.QueryOptions) (queryResult, string, error) {
+	generation := s.storage.Generation()
+	if cached, ok := s.queries.get(key, generation); ok {
+		return cached, "hit", nil
+	}
+	entries, total, err := s.storage.QueryContext(ctx, filter, opts)
+	if err != nil {
+		return queryResult{}, "", err
+	}
+	cached := queryResult{entries: entries, total: total, generation: generation}
+	s.queries.put(key, cached)
+	return cached, "miss", nil
+}
 
-	// Parse query
-	queryStr := req.Query
+// buildFilter parses queryStr and scopes it to the server's default filter,
+// an optional session, and optional time bounds. The returned TimeRange is
+// nil when neither bound is set.
+func (s *Server) buildFilter(ctx context.Context, queryStr, session string, start, end time.Time) (query.Filter, *storage.TimeRange, error) {
 	if queryStr == "" {
 		queryStr = "*"
 	}
 
 	q, err := query.Parse(queryStr)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid query: %v", err), http.StatusBadRequest)
-		return
+		return nil, nil, err
 	}
 
-	// Apply default filter (e.g., for fresh mode)
+	// Apply the caller's scope (fresh mode session, token namespace)
 	var filter query.Filter = q
-	if s.defaultFilter != nil {
+	if scope := s.scope(ctx); scope != nil {
 		filter = &query.AndFilter{
-			Left:  s.defaultFilter,
+			Left:  scope,
 			Right: q,
 		}
 	}
 
-	// Parse optional time range parameters.
-	var tr *storage.TimeRange
-	var rangeStart, rangeEnd time.Time
-	if req.Start != "" {
-		if t, err := time.Parse(time.RFC3339, req.Start); err == nil {
-			rangeStart = t
-		}
-	}
-	if req.End != "" {
-		if t, err := time.Parse(time.RFC3339, req.End); err == nil {
-			rangeEnd = t
+	// Scope to a single collect session when requested.
+	if session != "" {
+		filter = &query.AndFilter{
+			Left:  filter,
+			Right: &query.SessionFilter{Session: session},
 		}
 	}
-	if !rangeStart.IsZero() || !rangeEnd.IsZero() {
-		tr = &storage.TimeRange{Start: rangeStart, End: rangeEnd}
+
+	var tr *storage.TimeRange
+	if !start.IsZero() || !end.IsZero() {
+		tr = &storage.TimeRange{Start: start, End: end}
 		// Also apply the time range as a filter so boundary conditions are correct.
 		filter = &query.AndFilter{
 			Left:  filter,
-			Right: &query.TimestampRangeFilter{Start: rangeStart, End: rangeEnd},
+			Right: &query.TimestampRangeFilter{Start: start, End: end},
 		}
 	}
 
-	// Execute query
-	executionStart := time.Now()
-	entries, total, err := s.storage.QueryWithTimeRange(filter, tr, req.Limit, req.Offset)
+	return filter, tr, nil
+}
+
+// parseTime parses an optional RFC3339 time; invalid or empty values yield
+// the zero time (no bound).
+func parseTime(v string) time.Time {
+	if v == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, v)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return time.Time{}
+	}
+	return t
+}
+
+// handleFields handles GET /fields. Takes the same query, session, start and
+// end parameters as /fields/{name}/stats; ?sample=N inspects at most N
+// entries spread across the range.
+func (s *Server) handleFields(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	took := time.Since(executionStart)
-	
-	// Ensure entries is never nil for JSON encoding
-	if entries == nil {
-		entries = []*storage.LogEntry{}
+
+	q := r.URL.Query()
+	sample := 0
+	if v := q.Get("sample"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, "Invalid sample (use a non-negative entry count)", http.StatusBadRequest)
+			return
+		}
+		sample = n
+	}
+	filter, tr, err := s.buildFilter(r.Context(), q.Get("query"), q.Get("session"), parseTime(q.Get("start")), parseTime(q.Get("end")))
+	if err != nil {
+		writeQueryError(w, "Invalid query", err)
+		return
 	}
 
-	response := map[string]interface{}{
-		"logs":    entries,
-		"total":   total,
-		"took_ms": took.Milliseconds(),
+	fields, rate, err := s.storage.GetFieldsSampled(r.Context(), filter, tr, sample)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(map[string]interface{}{"fields": fields, "sample_rate": rate})
 }
 
-// handleFields handles GET /fields
-func (s *Server) handleFields(w http.ResponseWriter, r *http.Request) {
+// handleFieldStats handles GET /fields/{name}/stats
+func (s *Server) handleFieldStats(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/fields/"), "/stats")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if name == "" {
+		writeError(w, "Missing field name", http.StatusBadRequest)
 		return
 	}
 
 	q := r.URL.Query()
-	var start, end time.Time
+	filter, tr, err := s.buildFilter(r.Context(), q.Get("query"), q.Get("session"), parseTime(q.Get("start")), parseTime(q.Get("end")))
+	if err != nil {
+		writeQueryError(w, "Invalid query", err)
+		return
+	}
 
-	if v := q.Get("start"); v != "" {
-		if t, err := time.Parse(time.RFC3339, v); err == nil {
-			start = t
-		}
+	stats, err := s.storage.GetFieldStats(r.Context(), name, filter, tr)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
-	if v := q.Get("end"); v != "" {
-		if t, err := time.Parse(time.RFC3339, v); err == nil {
-			end = t
-		}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// handleUIConfig handles GET /ui-config
+func (s *Server) handleUIConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg := s.uiConfig
+	if cfg.PinnedColumns == nil {
+		cfg.PinnedColumns = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cfg)
+}
+
+// handleRaw handles GET /raw/{id}
+func (s *Server) handleRaw(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/raw/")
+	if id == "" {
+		writeError(w, "Missing entry id", http.StatusBadRequest)
+		return
 	}
 
-	fields, err := s.storage.GetFields(start, end)
+	if ok, err := s.visible(r.Context(), id); err != nil || !ok {
+		s.writeEntryResult(w, errOrNotFound(err), 0, nil)
+		return
+	}
+
+	raw, err := s.storage.GetRaw(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		writeError(w, "Entry not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{"fields": fields})
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "raw": raw})
 }
 
 // handleWebSocket handles WS /logs
@@ -268,21 +776,43 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ctx, ok := s.authenticateWebSocket(r.Context(), conn, r)
+	if !ok {
+		return
+	}
+
 	c := &client{
-		conn: conn,
-		send: make(chan interface{}, 100),
-		done: make(chan struct{}),
+		conn:       conn,
+		scope:      s.scope(ctx),
+		scopeKey:   scopeKey(ctx),
+		principal:  principalFrom(ctx),
+		remoteAddr: r.RemoteAddr,
+		federated:  r.Header.Get(federatedHeader) != "",
+		send:       make(chan interface{}, 100),
+		done:       make(chan struct{}),
 	}
 
 	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		conn.Close()
+		return
+	}
 	s.clients[conn] = c
+	s.workers.Add(2)
 	s.mu.Unlock()
 
 	// Start sender goroutine
-	go s.writePump(c)
+	go func() {
+		defer s.workers.Done()
+		s.writePump(c)
+	}()
 
 	// Start reader goroutine
-	go s.readPump(c)
+	go func() {
+		defer s.workers.Done()
+		s.readPump(c)
+	}()
 }
 
 // readPump reads messages from the WebSocket
@@ -291,6 +821,7 @@ func (s *Server) readPump(c *client) {
 		s.mu.Lock()
 		delete(s.clients, c.conn)
 		s.mu.Unlock()
+		c.untailPeers()
 		close(c.done)
 		c.conn.Close()
 	}()
@@ -319,14 +850,18 @@ func (s *Server) readPump(c *client) {
 			q, err := query.Parse(queryStr)
 			if err != nil {
 				log.Printf("Invalid query: %v", err)
+				select {
+				case c.send <- map[string]interface{}{"type": "error", "error": queryError("Invalid query", err)}:
+				default:
+				}
 				continue
 			}
 
-			// Apply default filter (e.g., for fresh mode)
+			// Apply the connection's scope (fresh mode session, token namespace)
 			var filter query.Filter = q
-			if s.defaultFilter != nil {
+			if c.scope != nil {
 				filter = &query.AndFilter{
-					Left:  s.defaultFilter,
+					Left:  c.scope,
 					Right: q,
 				}
 			}
@@ -352,15 +887,43 @@ func (s *Server) readPump(c *client) {
 				}
 			}
 
+			s.mu.Lock()
 			c.filter = filter
+			c.filterKey = strings.Join([]string{c.scopeKey, queryStr, msg.Start, msg.End}, "\x00")
+			s.mu.Unlock()
 			c.timeRange = tr
 
+			// A held backlog belongs to the previous subscription.
+			c.mu.Lock()
+			c.backlog, c.skipped = nil, 0
+			c.mu.Unlock()
+
+			sub := peerSubscription{Query: queryStr, Start: msg.Start, End: msg.End}
+			c.untailPeers()
+			if s.federating() && !c.federated {
+				c.stopPeers = make(chan struct{})
+				s.tailPeers(c, sub, c.stopPeers)
+			}
+
 			// Send initial results
-			go s.sendInitialResults(c, filter)
+			s.workers.Add(1)
+			go func() {
+				defer s.workers.Done()
+				s.sendInitialResults(c, filter, sub)
+			}()
 
 		} else if msg.Action == "unsubscribe" {
-			c.filter = nil
+			s.mu.Lock()
+			c.filter, c.filterKey = nil, ""
+			s.mu.Unlock()
 			c.timeRange = nil
+			c.untailPeers()
+		} else if msg.Action == "pause" {
+			c.mu.Lock()
+			c.paused = true
+			c.mu.Unlock()
+		} else if msg.Action == "resume" {
+			c.resume()
 		}
 	}
 }
@@ -406,8 +969,11 @@ func (s *Server) writePump(c *client) {
 }
 
 // sendInitialResults sends initial query results through the write channel.
-func (s *Server) sendInitialResults(c *client, q query.Filter) {
+// With peers, their first results are merged in.
+func (s *Server) sendInitialResults(c *client, q query.Filter, sub peerSubscription) {
+	started := time.Now()
 	entries, total, err := s.storage.QueryWithTimeRange(q, c.timeRange, 100, 0)
+	s.auditLive(c, started, total, err)
 	if err != nil {
 		log.Printf("Query error: %v", err)
 		return
@@ -419,6 +985,16 @@ func (s *Server) sendInitialResults(c *client, q query.Filter) {
 		"total":   total,
 		"took_ms": 0,
 	}
+	if s.federating() && !c.federated {
+		ctx := context.WithValue(context.Background(), principalKey{}, c.principal)
+		remote, peers := s.queryPeers(ctx, c.scope, peerQuery{Query: sub.Query, Limit: 100, Start: sub.Start, End: sub.End})
+		for _, p := range peers {
+			total += p.Total
+		}
+		msg["logs"] = pageOf(mergeEntries(append(remote, entries)...), 0, 100)
+		msg["total"] = total
+		msg["peers"] = peers
+	}
 
 	select {
 	case c.send <- msg:
@@ -428,13 +1004,37 @@ func (s *Server) sendInitialResults(c *client, q query.Filter) {
 
 // BroadcastLog broadcasts a new log entry to all connected clients
 func (s *Server) BroadcastLog(entry *storage.LogEntry) {
+	s.notifyArrivals()
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	// Clients with the same subscription share one evaluation; many tabs
+	// tailing the same query cost a single filter pass per entry.
+	matched := make(map[string]bool, len(s.clients))
 	for _, c := range s.clients {
-		if c.filter == nil || c.filter.Match(entry) {
+		key, filter := c.filterKey, c.filter
+		if filter == nil {
+			key, filter = "\x01"+c.scopeKey, c.scope
+		}
+		ok, seen := matched[key]
+		if !seen || key == "" {
+			ok = filter == nil || filter.Match(entry)
+			matched[key] = ok
+		}
+		if ok {
+			c.deliver(entry)
+		}
+	}
+	for ls := range s.streams {
+		ok, seen := matched[ls.filterKey]
+		if !seen {
+			ok = ls.filter.Match(entry)
+			matched[ls.filterKey] = ok
+		}
+		if ok {
 			select {
-			case c.send <- entry:
+			case ls.send <- entry:
 			default:
 				// Channel full, skip
 			}
@@ -442,15 +1042,104 @@ func (s *Server) BroadcastLog(entry *storage.LogEntry) {
 	}
 }
 
-// StartBroadcastWorker starts a worker that broadcasts new logs
+// untailPeers stops the peer tails of the current subscription.
+func (c *client) untailPeers() {
+	if c.stopPeers != nil {
+		close(c.stopPeers)
+		c.stopPeers = nil
+	}
+}
+
+// deliver queues a live entry for the client, or holds it while paused.
+func (c *client) deliver(entry *storage.LogEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.paused {
+		if len(c.backlog) < pauseBacklogLimit {
+			c.backlog = append(c.backlog, entry)
+		} else {
+			c.skipped++
+		}
+		return
+	}
+
+	select {
+	case c.send <- entry:
+	default:
+		// Channel full, skip
+	}
+}
+
+// resume ends a pause and sends the held backlog as a single "backlog"
+// message, reporting how many entries did not fit. The client stays paused
+// while the backlog is queued, so live entries cannot overtake it, but the
+// lock is not held during the send: BroadcastLog must not wait on one slow
+// client.
+func (c *client) resume() {
+	c.mu.Lock()
+	if !c.paused {
+		c.mu.Unlock()
+		return
+	}
+	logs, skipped := c.backlog, c.skipped
+	c.backlog, c.skipped = nil, 0
+	c.mu.Unlock()
+
+	if logs == nil {
+		logs = []*storage.LogEntry{}
+	}
+	msg := map[string]interface{}{
+		"type":    "backlog",
+		"logs":    logs,
+		"skipped": skipped,
+	}
+	// resume runs on the read goroutine, so it cannot wait on done forever
+	// if the writer has already exited.
+	select {
+	case c.send <- msg:
+	case <-c.done:
+	case <-time.After(resumeSendTimeout):
+	}
+
+	// Entries held during the send follow the backlog as live entries. If
+	// some did not fit, a second backlog message reports the new total.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = false
+	late := c.skipped
+	for _, entry := range c.backlog {
+		select {
+		case c.send <- entry:
+		default:
+			late++
+		}
+	}
+	c.backlog, c.skipped = nil, 0
+	if late > 0 {
+		select {
+		case c.send <- map[string]interface{}{"type": "backlog", "logs": []*storage.LogEntry{}, "skipped": skipped + late}:
+		default:
+		}
+	}
+}
+
+// StartBroadcastWorker starts a worker that broadcasts new logs until Shutdown
 func (s *Server) StartBroadcastWorker() {
+	s.workers.Add(1)
 	go func() {
+		defer s.workers.Done()
 		ticker := time.NewTicker(100 * time.Millisecond)
 		defer ticker.Stop()
 
 		lastCheck := time.Now()
 
-		for range ticker.C {
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
 			now := time.Now()
 
 			// Query for new entries since last check
@@ -2,17 +2,36 @@ package server
 
 import (
 	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/mchurichi/peek/pkg/parser"
+	"github.com/mchurichi/peek/pkg/query"
 	"github.com/mchurichi/peek/pkg/storage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 func newTestStorage(t *testing.T) *storage.BadgerStorage {
@@ -35,10 +54,10 @@ func storeLog(t *testing.T, db *storage.BadgerStorage, id, level, msg string, ts
 func TestHTTPHandlers(t *testing.T) {
 	db := newTestStorage(t)
 	now := time.Now().UTC()
-	storeLog(t, db, "1", "INFO", "started", now.Add(-2*time.Minute), map[string]interface{}{"service": "api"})
-	storeLog(t, db, "2", "ERROR", "failed", now.Add(-time.Minute), map[string]interface{}{"service": "worker"})
+	storeLog(t, db, "1", "INFO", "started", now.Add(-2*time.Minute), map[string]interface{}{"service": "api", "status": 200})
+	storeLog(t, db, "2", "ERROR", "failed", now.Add(-time.Minute), map[string]interface{}{"service": "worker", "status": 500})
 
-	s := NewServer(db, nil)
+	s := NewServer(db, "")
 
 	tests := []struct {
 		name       string
@@ -87,8 +106,109 @@ func TestHTTPHandlers(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:       "query count mode none",
+			method:     http.MethodPost,
+			target:     "/query",
+			handler:    s.handleQuery,
+			wantStatus: http.StatusOK,
+			body:       `{"query":"*","limit":1,"count_mode":"none"}`,
+			check: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				t.Helper()
+				var resp struct {
+					Logs    []storage.LogEntry `json:"logs"`
+					Total   int                `json:"total"`
+					HasMore bool               `json:"has_more"`
+				}
+				if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if len(resp.Logs) != 1 || resp.Total != 1 || !resp.HasMore {
+					t.Fatalf("unexpected response: len=%d total=%d has_more=%v", len(resp.Logs), resp.Total, resp.HasMore)
+				}
+			},
+		},
+		{name: "query invalid count mode", method: http.MethodPost, target: "/query", body: `{"count_mode":"approx"}`, handler: s.handleQuery, wantStatus: http.StatusBadRequest},
 		{name: "fields", method: http.MethodGet, target: "/fields?start=invalid&end=invalid", handler: s.handleFields, wantStatus: http.StatusOK},
 		{name: "fields method not allowed", method: http.MethodPost, target: "/fields", handler: s.handleFields, wantStatus: http.StatusMethodNotAllowed},
+		{
+			name:       "fields sampled",
+			method:     http.MethodGet,
+			target:     "/fields?sample=1",
+			handler:    s.handleFields,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				t.Helper()
+				var resp struct {
+					SampleRate float64 `json:"sample_rate"`
+				}
+				if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if resp.SampleRate != 0.5 {
+					t.Fatalf("sample_rate = %v, want 0.5", resp.SampleRate)
+				}
+			},
+		},
+		{name: "fields invalid sample", method: http.MethodGet, target: "/fields?sample=-1", handler: s.handleFields, wantStatus: http.StatusBadRequest},
+		{name: "fields invalid query", method: http.MethodGet, target: "/fields?query=level:%5Bbad", handler: s.handleFields, wantStatus: http.StatusBadRequest},
+		{
+			name:       "field stats",
+			method:     http.MethodGet,
+			target:     "/fields/status/stats?query=service:worker",
+			handler:    s.handleFieldStats,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				t.Helper()
+				var resp storage.FieldStats
+				if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if resp.Field != "status" || resp.Count != 1 || resp.Max != 500 {
+					t.Fatalf("unexpected field stats: %+v", resp)
+				}
+			},
+		},
+		{name: "field stats invalid query", method: http.MethodGet, target: "/fields/status/stats?query=level:%5Bbad", handler: s.handleFieldStats, wantStatus: http.StatusBadRequest},
+		{name: "field stats missing name", method: http.MethodGet, target: "/fields//stats", handler: s.handleFieldStats, wantStatus: http.StatusBadRequest},
+		{name: "field stats unknown path", method: http.MethodGet, target: "/fields/status", handler: s.handleFieldStats, wantStatus: http.StatusNotFound},
+		{name: "field stats method not allowed", method: http.MethodPost, target: "/fields/status/stats", handler: s.handleFieldStats, wantStatus: http.StatusMethodNotAllowed},
+		{
+			name:       "raw",
+			method:     http.MethodGet,
+			target:     "/raw/1",
+			handler:    s.handleRaw,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				t.Helper()
+				var resp struct {
+					Raw string `json:"raw"`
+				}
+				if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if resp.Raw != "started" {
+					t.Fatalf("raw = %q", resp.Raw)
+				}
+			},
+		},
+		{name: "raw not found", method: http.MethodGet, target: "/raw/missing", handler: s.handleRaw, wantStatus: http.StatusNotFound},
+		{name: "raw missing id", method: http.MethodGet, target: "/raw/", handler: s.handleRaw, wantStatus: http.StatusBadRequest},
+		{name: "raw method not allowed", method: http.MethodPost, target: "/raw/1", handler: s.handleRaw, wantStatus: http.StatusMethodNotAllowed},
+		{
+			name:       "ui config defaults",
+			method:     http.MethodGet,
+			target:     "/ui-config",
+			handler:    s.handleUIConfig,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				t.Helper()
+				if body := rr.Body.String(); !strings.Contains(body, `"pinned_columns":[]`) || !strings.Contains(body, `"auto_scroll":true`) {
+					t.Fatalf("unexpected ui config: %s", body)
+				}
+			},
+		},
+		{name: "ui config method not allowed", method: http.MethodPost, target: "/ui-config", handler: s.handleUIConfig, wantStatus: http.StatusMethodNotAllowed},
 	}
 
 	for _, tt := range tests {
@@ -121,7 +241,7 @@ func TestWebSocketSubscribeAndBroadcast(t *testing.T) {
 	storeLog(t, db, "1", "ERROR", "boom", now.Add(-time.Second), map[string]interface{}{"service": "api"})
 	storeLog(t, db, "2", "INFO", "ok", now, map[string]interface{}{"service": "api"})
 
-	s := NewServer(db, nil)
+	s := NewServer(db, "")
 	mux := http.NewServeMux()
 	mux.HandleFunc("/logs", s.handleWebSocket)
 	ts := httptest.NewServer(mux)
@@ -165,7 +285,7 @@ func TestWebSocketSubscribeAndBroadcast(t *testing.T) {
 
 func TestStaticHandlers(t *testing.T) {
 	db := newTestStorage(t)
-	s := NewServer(db, nil)
+	s := NewServer(db, "")
 
 	tests := []struct {
 		name       string
@@ -213,9 +333,46 @@ func TestStaticHandlers(t *testing.T) {
 	}
 }
 
+// countingFilter matches everything and counts evaluations.
+type countingFilter struct{ calls int }
+
+func (f *countingFilter) Match(*storage.LogEntry) bool {
+	f.calls++
+	return true
+}
+
+func TestBroadcastLogSharesMatchesBetweenSubscriptions(t *testing.T) {
+	s := NewServer(newTestStorage(t), "")
+
+	shared, other := &countingFilter{}, &countingFilter{}
+	clients := []*client{
+		{filter: shared, filterKey: "\x00level:ERROR\x00\x00"},
+		{filter: shared, filterKey: "\x00level:ERROR\x00\x00"},
+		{filter: other, filterKey: "team\x00level:ERROR\x00\x00"},
+	}
+	s.mu.Lock()
+	for _, c := range clients {
+		c.send = make(chan interface{}, 1)
+		c.done = make(chan struct{})
+		s.clients[&websocket.Conn{}] = c
+	}
+	s.mu.Unlock()
+
+	s.BroadcastLog(&storage.LogEntry{ID: "e", Level: "ERROR"})
+
+	if shared.calls != 1 || other.calls != 1 {
+		t.Fatalf("filter evaluations = %d shared, %d other; want 1 each", shared.calls, other.calls)
+	}
+	for i, c := range clients {
+		if len(c.send) != 1 {
+			t.Fatalf("client %d got %d entries, want 1", i, len(c.send))
+		}
+	}
+}
+
 func TestStartBroadcastWorkerSendsNewEntries(t *testing.T) {
 	db := newTestStorage(t)
-	s := NewServer(db, nil)
+	s := NewServer(db, "")
 
 	c := &client{
 		send:   make(chan interface{}, 10),
@@ -242,7 +399,7 @@ func TestStartBroadcastWorkerSendsNewEntries(t *testing.T) {
 
 func TestStartServesHTTP(t *testing.T) {
 	db := newTestStorage(t)
-	s := NewServer(db, nil)
+	s := NewServer(db, "")
 
 	ln, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
@@ -272,6 +429,54 @@ func TestStartServesHTTP(t *testing.T) {
 	}
 }
 
+func TestShutdownStopsServerAndClosesWebSockets(t *testing.T) {
+	db := newTestStorage(t)
+	s := NewServer(db, "")
+	s.StartBroadcastWorker()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	_ = ln.Close()
+
+	startErr := make(chan error, 1)
+	go func() { startErr <- s.Start(port) }()
+
+	wsURL := fmt.Sprintf("ws://127.0.0.1:%d/logs", port)
+	var conn *websocket.Conn
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		conn, _, err = websocket.DefaultDialer.Dial(wsURL, nil)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server did not become ready: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if err := <-startErr; !errors.Is(err, http.ErrServerClosed) {
+		t.Fatalf("Start() error = %v, want http.ErrServerClosed", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("WebSocket still open after Shutdown")
+	}
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("second Shutdown() error = %v", err)
+	}
+}
+
 type failingWriter struct {
 	header http.Header
 }
@@ -290,7 +495,7 @@ func TestHandlerErrorsWhenStorageUnavailable(t *testing.T) {
 	if err != nil {
 		t.Fatalf("NewBadgerStorage() error = %v", err)
 	}
-	s := NewServer(db, nil)
+	s := NewServer(db, "")
 	if err := db.Close(); err != nil {
 		t.Fatalf("Close() error = %v", err)
 	}
@@ -303,10 +508,11 @@ func TestHandlerErrorsWhenStorageUnavailable(t *testing.T) {
 		body       string
 		wantStatus int
 	}{
-		{name: "health", handler: s.handleHealth, method: http.MethodGet, target: "/health", wantStatus: http.StatusInternalServerError},
+		{name: "health", handler: s.handleHealth, method: http.MethodGet, target: "/health", wantStatus: http.StatusServiceUnavailable},
 		{name: "stats", handler: s.handleStats, method: http.MethodGet, target: "/stats", wantStatus: http.StatusInternalServerError},
 		{name: "query", handler: s.handleQuery, method: http.MethodPost, target: "/query", body: `{"query":"*"}`, wantStatus: http.StatusInternalServerError},
 		{name: "fields", handler: s.handleFields, method: http.MethodGet, target: "/fields", wantStatus: http.StatusInternalServerError},
+		{name: "download", handler: s.handleDownload, method: http.MethodGet, target: "/download", wantStatus: http.StatusInternalServerError},
 	}
 
 	for _, tt := range tests {
@@ -321,10 +527,147 @@ func TestHandlerErrorsWhenStorageUnavailable(t *testing.T) {
 	}
 }
 
-func TestNewServerWithStartTimeAndVanJSWriteFailure(t *testing.T) {
+func TestUIAssets(t *testing.T) {
+	s := NewServer(newTestStorage(t), "")
+	if err := s.SetTokens([]Token{{Token: "secret", Admin: true}}); err != nil {
+		t.Fatalf("SetTokens() error = %v", err)
+	}
+	h := s.routes()
+	get := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		return rr
+	}
+
+	// The embedded index versions its asset URLs; assets need no token.
+	index := get("/").Body.String()
+	m := regexp.MustCompile(`"/assets/van\.min\.js\?v=([0-9a-f]+)"`).FindStringSubmatch(index)
+	if m == nil {
+		t.Fatal("index does not load a versioned /assets/van.min.js")
+	}
+	tests := []struct {
+		target     string
+		wantStatus int
+		wantCache  string
+	}{
+		{target: "/assets/van.min.js?v=" + m[1], wantStatus: http.StatusOK, wantCache: "public, max-age=31536000, immutable"},
+		{target: "/assets/van.min.js?v=stale", wantStatus: http.StatusOK, wantCache: "no-cache"},
+		{target: "/assets/van.min.js", wantStatus: http.StatusOK, wantCache: "no-cache"},
+		{target: "/assets/missing.js", wantStatus: http.StatusNotFound},
+		{target: "/assets/", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rr := get(tt.target)
+		if rr.Code != tt.wantStatus || rr.Header().Get("Cache-Control") != tt.wantCache {
+			t.Errorf("GET %s = %d, Cache-Control %q; want %d, %q", tt.target, rr.Code, rr.Header().Get("Cache-Control"), tt.wantStatus, tt.wantCache)
+		}
+	}
+
+	// A UI directory replaces the bundle and is never cached.
+	dir := t.TempDir()
+	if err := s.SetUIDir(dir); err == nil {
+		t.Fatal("SetUIDir() without index.html error = nil")
+	}
+	os.WriteFile(filepath.Join(dir, "index.html"), []byte(`<script src="/assets/js/app.js"></script>`), 0o644)
+	os.MkdirAll(filepath.Join(dir, "js"), 0o755)
+	os.WriteFile(filepath.Join(dir, "js", "app.js"), []byte("console.log(1)"), 0o644)
+	if err := s.SetUIDir(dir); err != nil {
+		t.Fatalf("SetUIDir() error = %v", err)
+	}
+	if got := get("/").Body.String(); got != `<script src="/assets/js/app.js"></script>` {
+		t.Errorf("index = %q", got)
+	}
+	rr := get("/assets/js/app.js")
+	if rr.Code != http.StatusOK || rr.Body.String() != "console.log(1)" || rr.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("GET /assets/js/app.js = %d %q, Cache-Control %q", rr.Code, rr.Body.String(), rr.Header().Get("Cache-Control"))
+	}
+	if rr := get("/assets/../index.html"); rr.Code == http.StatusOK {
+		t.Errorf("GET /assets/../index.html = %d", rr.Code)
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	s := NewServer(newTestStorage(t), "")
+	get := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		s.routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		return rr
+	}
+
+	rr := get("/")
+	csp := rr.Header().Get("Content-Security-Policy")
+	script := regexp.MustCompile(`(?s)<script type="module">(.*?)</script>`).FindSubmatch(rr.Body.Bytes())
+	if script == nil {
+		t.Fatal("index has no inline module script")
+	}
+	sum := sha256.Sum256(script[1])
+	for _, want := range []string{"default-src 'self'", "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'", "frame-ancestors 'none'", "object-src 'none'"} {
+		if !strings.Contains(csp, want) {
+			t.Errorf("Content-Security-Policy %q lacks %q", csp, want)
+		}
+	}
+	if rr.Header().Get("X-Frame-Options") != "DENY" || rr.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("headers = %v", rr.Header())
+	}
+	if rr := get("/health"); rr.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("/health X-Content-Type-Options = %q", rr.Header().Get("X-Content-Type-Options"))
+	}
+
+	s.SetSecurity(SecurityConfig{FrameAncestors: []string{"'self'", "https://grafana.example.com"}})
+	rr = get("/")
+	if csp := rr.Header().Get("Content-Security-Policy"); !strings.HasSuffix(csp, "frame-ancestors 'self' https://grafana.example.com") || rr.Header().Get("X-Frame-Options") != "" {
+		t.Errorf("with frame ancestors: CSP %q, X-Frame-Options %q", csp, rr.Header().Get("X-Frame-Options"))
+	}
+	s.SetSecurity(SecurityConfig{ContentSecurityPolicy: "default-src *"})
+	if csp := get("/").Header().Get("Content-Security-Policy"); csp != "default-src *" {
+		t.Errorf("overridden CSP = %q", csp)
+	}
+}
+
+func TestCrossOriginMutations(t *testing.T) {
+	s := NewServer(newTestStorage(t), "")
+	s.SetSecurity(SecurityConfig{AllowedOrigins: []string{"https://logs.example.org/"}})
+	do := func(method string, header http.Header) int {
+		req := httptest.NewRequest(method, "/ingest", strings.NewReader(`{"message":"hi"}`+"\n"))
+		req.Header = header
+		req.Header.Set("Authorization", "Bearer secret")
+		rr := httptest.NewRecorder()
+		s.routes().ServeHTTP(rr, req)
+		return rr.Code
+	}
+	tests := []struct {
+		name    string
+		method  string
+		header  http.Header
+		want    int
+		wantOff int // without auth
+	}{
+		{name: "no browser headers", method: http.MethodPost, header: http.Header{}, want: http.StatusOK, wantOff: http.StatusOK},
+		{name: "same origin", method: http.MethodPost, header: http.Header{"Origin": {"http://example.com"}}, want: http.StatusOK, wantOff: http.StatusOK},
+		{name: "allowed origin", method: http.MethodPost, header: http.Header{"Origin": {"https://logs.example.org"}}, want: http.StatusOK, wantOff: http.StatusOK},
+		{name: "other origin", method: http.MethodPost, header: http.Header{"Origin": {"https://evil.example"}}, want: http.StatusForbidden, wantOff: http.StatusOK},
+		{name: "null origin", method: http.MethodPost, header: http.Header{"Origin": {"null"}}, want: http.StatusForbidden, wantOff: http.StatusOK},
+		{name: "cross-site fetch", method: http.MethodPost, header: http.Header{"Sec-Fetch-Site": {"cross-site"}}, want: http.StatusForbidden, wantOff: http.StatusOK},
+		{name: "cross-origin read", method: http.MethodGet, header: http.Header{"Origin": {"https://evil.example"}}, want: http.StatusMethodNotAllowed, wantOff: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		if got := do(tt.method, tt.header.Clone()); got != tt.wantOff {
+			t.Errorf("%s without auth: status = %d, want %d", tt.name, got, tt.wantOff)
+		}
+	}
+	if err := s.SetTokens([]Token{{Token: "secret", Admin: true}}); err != nil {
+		t.Fatalf("SetTokens() error = %v", err)
+	}
+	for _, tt := range tests {
+		if got := do(tt.method, tt.header.Clone()); got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewServerWithSessionAndVanJSWriteFailure(t *testing.T) {
 	db := newTestStorage(t)
-	start := time.Now().Add(-time.Minute)
-	s := NewServer(db, &start)
+	s := NewServer(db, "run-1")
 	if s.defaultFilter == nil {
 		t.Fatalf("expected default filter in fresh mode")
 	}
@@ -338,7 +681,7 @@ func TestHandleWebSocketUpgradeFailureAndInitialResultsError(t *testing.T) {
 	if err != nil {
 		t.Fatalf("NewBadgerStorage() error = %v", err)
 	}
-	s := NewServer(db, nil)
+	s := NewServer(db, "")
 
 	// Regular HTTP request without websocket headers should fail upgrade path gracefully.
 	s.handleWebSocket(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/logs", nil))
@@ -348,7 +691,7 @@ func TestHandleWebSocketUpgradeFailureAndInitialResultsError(t *testing.T) {
 	}
 
 	c := &client{send: make(chan interface{}, 1), done: make(chan struct{})}
-	s.sendInitialResults(c, &storage.AllFilter{})
+	s.sendInitialResults(c, &storage.AllFilter{}, peerSubscription{})
 	select {
 	case <-c.send:
 		t.Fatalf("did not expect initial results when storage query fails")
@@ -356,7 +699,7 @@ func TestHandleWebSocketUpgradeFailureAndInitialResultsError(t *testing.T) {
 	}
 
 	close(c.done)
-	s.sendInitialResults(c, &storage.AllFilter{})
+	s.sendInitialResults(c, &storage.AllFilter{}, peerSubscription{})
 }
 
 func TestWritePumpExitsWhenDoneClosed(t *testing.T) {
@@ -369,8 +712,2366 @@ func TestWritePumpExitsWhenDoneClosed(t *testing.T) {
 func TestSendInitialResultsHonorsDoneChannel(t *testing.T) {
 	db := newTestStorage(t)
 	storeLog(t, db, "seed", "INFO", "seed", time.Now().UTC(), map[string]interface{}{})
-	s := NewServer(db, nil)
+	s := NewServer(db, "")
 	c := &client{send: make(chan interface{}, 1), done: make(chan struct{})}
 	close(c.done)
-	s.sendInitialResults(c, &storage.AllFilter{})
+	s.sendInitialResults(c, &storage.AllFilter{}, peerSubscription{})
+}
+
+func TestFreshModeFiltersBySession(t *testing.T) {
+	db := newTestStorage(t)
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, e := range []*storage.LogEntry{
+		{ID: "prev", Timestamp: time.Now().UTC(), Level: "INFO", Message: "previous run", Session: "run-0"},
+		{ID: "cur", Timestamp: old, Level: "INFO", Message: "historical timestamp", Session: "run-1"},
+	} {
+		if err := db.Store(e); err != nil {
+			t.Fatalf("Store() error = %v", err)
+		}
+	}
+
+	decode := func(rr *httptest.ResponseRecorder) []storage.LogEntry {
+		t.Helper()
+		var resp struct {
+			Logs []storage.LogEntry `json:"logs"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp.Logs
+	}
+
+	fresh := NewServer(db, "run-1")
+	rr := httptest.NewRecorder()
+	fresh.handleQuery(rr, httptest.NewRequest(http.MethodPost, "/query", bytes.NewBufferString(`{"query":"*"}`)))
+	if logs := decode(rr); len(logs) != 1 || logs[0].ID != "cur" {
+		t.Fatalf("fresh mode returned %+v, want only session run-1", logs)
+	}
+
+	all := NewServer(db, "")
+	rr = httptest.NewRecorder()
+	all.handleQuery(rr, httptest.NewRequest(http.MethodPost, "/query", bytes.NewBufferString(`{"query":"*","session":"run-0"}`)))
+	if logs := decode(rr); len(logs) != 1 || logs[0].ID != "prev" {
+		t.Fatalf("session query returned %+v, want only session run-0", logs)
+	}
+}
+
+func TestSetUIConfig(t *testing.T) {
+	s := NewServer(newTestStorage(t), "")
+	want := UIConfig{DefaultTimePreset: "1h", DefaultQuery: "level:ERROR", PinnedColumns: []string{"service"}, Theme: "light"}
+	s.SetUIConfig(want)
+
+	rr := httptest.NewRecorder()
+	s.handleUIConfig(rr, httptest.NewRequest(http.MethodGet, "/ui-config", nil))
+	var got UIConfig
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.DefaultTimePreset != want.DefaultTimePreset || got.DefaultQuery != want.DefaultQuery || got.Theme != want.Theme || got.AutoScroll ||
+		len(got.PinnedColumns) != 1 || got.PinnedColumns[0] != "service" {
+		t.Fatalf("ui config = %+v, want %+v", got, want)
+	}
+}
+
+func TestViewHandlers(t *testing.T) {
+	s := NewServer(newTestStorage(t), "")
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		handler    func(http.ResponseWriter, *http.Request)
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "list empty", method: http.MethodGet, target: "/views", handler: s.handleViews, wantStatus: http.StatusOK, wantBody: `"views":[]`},
+		{name: "create", method: http.MethodPost, target: "/views", body: `{"name":"Payments errors","query":"level:ERROR","columns":["service"],"time_preset":"1h"}`, handler: s.handleViews, wantStatus: http.StatusCreated, wantBody: `"name":"Payments errors"`},
+		{name: "create missing name", method: http.MethodPost, target: "/views", body: `{"query":"*"}`, handler: s.handleViews, wantStatus: http.StatusBadRequest},
+		{name: "create invalid query", method: http.MethodPost, target: "/views", body: `{"name":"bad","query":"level:[bad"}`, handler: s.handleViews, wantStatus: http.StatusBadRequest},
+		{name: "create custom without bounds", method: http.MethodPost, target: "/views", body: `{"name":"bad","time_preset":"custom"}`, handler: s.handleViews, wantStatus: http.StatusBadRequest},
+		{name: "create invalid json", method: http.MethodPost, target: "/views", body: "{", handler: s.handleViews, wantStatus: http.StatusBadRequest},
+		{name: "list", method: http.MethodGet, target: "/views", handler: s.handleViews, wantStatus: http.StatusOK, wantBody: `"columns":["service"]`},
+		{name: "list method not allowed", method: http.MethodDelete, target: "/views", handler: s.handleViews, wantStatus: http.StatusMethodNotAllowed},
+		{name: "get", method: http.MethodGet, target: "/views/Payments%20errors", handler: s.handleView, wantStatus: http.StatusOK, wantBody: `"query":"level:ERROR"`},
+		{name: "put replaces", method: http.MethodPut, target: "/views/Payments%20errors", body: `{"name":"ignored","query":"level:WARN"}`, handler: s.handleView, wantStatus: http.StatusOK, wantBody: `"name":"Payments errors"`},
+		{name: "get replaced", method: http.MethodGet, target: "/views/Payments%20errors", handler: s.handleView, wantStatus: http.StatusOK, wantBody: `"query":"level:WARN"`},
+		{name: "get missing", method: http.MethodGet, target: "/views/missing", handler: s.handleView, wantStatus: http.StatusNotFound},
+		{name: "missing name", method: http.MethodGet, target: "/views/", handler: s.handleView, wantStatus: http.StatusBadRequest},
+		{name: "delete", method: http.MethodDelete, target: "/views/Payments%20errors", handler: s.handleView, wantStatus: http.StatusNoContent},
+		{name: "delete missing", method: http.MethodDelete, target: "/views/Payments%20errors", handler: s.handleView, wantStatus: http.StatusNotFound},
+		{name: "view method not allowed", method: http.MethodPost, target: "/views/x", handler: s.handleView, wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			tt.handler(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d body=%s", rr.Code, rr.Body.String())
+			}
+			if tt.wantBody != "" && !strings.Contains(rr.Body.String(), tt.wantBody) {
+				t.Fatalf("body = %s, want substring %s", rr.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestClientPauseHoldsBoundedBacklog(t *testing.T) {
+	c := &client{send: make(chan interface{}, 4), done: make(chan struct{})}
+	entry := func(id string) *storage.LogEntry { return &storage.LogEntry{ID: id} }
+
+	c.deliver(entry("live"))
+	if got := (<-c.send).(*storage.LogEntry); got.ID != "live" {
+		t.Fatalf("unpaused deliver sent %q", got.ID)
+	}
+
+	c.mu.Lock()
+	c.paused = true
+	c.mu.Unlock()
+	for i := 0; i < pauseBacklogLimit+3; i++ {
+		c.deliver(entry(fmt.Sprint(i)))
+	}
+	if len(c.send) != 0 {
+		t.Fatalf("paused client received %d live messages", len(c.send))
+	}
+
+	c.resume()
+	msg, ok := (<-c.send).(map[string]interface{})
+	if !ok || msg["type"] != "backlog" {
+		t.Fatalf("resume sent %#v, want backlog message", msg)
+	}
+	logs := msg["logs"].([]*storage.LogEntry)
+	if len(logs) != pauseBacklogLimit || logs[0].ID != "0" || msg["skipped"] != 3 {
+		t.Fatalf("backlog len=%d first=%q skipped=%v", len(logs), logs[0].ID, msg["skipped"])
+	}
+
+	// Resuming an unpaused client is a no-op and live delivery continues.
+	c.resume()
+	c.deliver(entry("after"))
+	if got := (<-c.send).(*storage.LogEntry); got.ID != "after" {
+		t.Fatalf("deliver after resume sent %q", got.ID)
+	}
+}
+
+func TestClientResumeDoesNotBlockDelivery(t *testing.T) {
+	// The writer is behind: resume has to wait for room to queue the
+	// backlog.
+	c := &client{send: make(chan interface{}, 2), done: make(chan struct{})}
+	c.send <- "busy"
+	c.send <- "busy"
+	c.paused = true
+	c.deliver(&storage.LogEntry{ID: "held"})
+
+	resumed := make(chan struct{})
+	go func() {
+		c.resume()
+		close(resumed)
+	}()
+	delivered := make(chan struct{})
+	go func() {
+		c.deliver(&storage.LogEntry{ID: "during"})
+		close(delivered)
+	}()
+	select {
+	case <-delivered:
+	case <-time.After(time.Second):
+		t.Fatal("deliver blocked while resume waited to queue the backlog")
+	}
+
+	<-c.send
+	<-c.send
+	<-resumed
+	var ids []string
+	for len(c.send) > 0 {
+		switch msg := (<-c.send).(type) {
+		case map[string]interface{}:
+			for _, e := range msg["logs"].([]*storage.LogEntry) {
+				ids = append(ids, e.ID)
+			}
+		case *storage.LogEntry:
+			ids = append(ids, msg.ID)
+		}
+	}
+	if got := strings.Join(ids, ","); got != "held,during" {
+		t.Fatalf("delivered %s, want the backlog before the later entry", got)
+	}
+}
+
+func TestEntryHandlers(t *testing.T) {
+	db := newTestStorage(t)
+	now := time.Now().UTC()
+	storeLog(t, db, "1", "INFO", "started", now.Add(-time.Minute), nil)
+	storeLog(t, db, "2", "ERROR", "secret=hunter2", now, nil)
+
+	s := NewServer(db, "")
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		handler    func(http.ResponseWriter, *http.Request)
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "annotate", method: http.MethodPut, target: "/entries/1/annotation", body: `{"pinned":true,"note":"root cause"}`, handler: s.handleEntry, wantStatus: http.StatusOK, wantBody: `"id":"1"`},
+		{name: "annotate missing entry", method: http.MethodPut, target: "/entries/nope/annotation", body: `{"pinned":true}`, handler: s.handleEntry, wantStatus: http.StatusNotFound},
+		{name: "annotate invalid json", method: http.MethodPut, target: "/entries/1/annotation", body: "{", handler: s.handleEntry, wantStatus: http.StatusBadRequest},
+		{name: "get annotation", method: http.MethodGet, target: "/entries/1/annotation", handler: s.handleEntry, wantStatus: http.StatusOK, wantBody: `"note":"root cause"`},
+		{name: "query includes annotations", method: http.MethodPost, target: "/query", body: `{"query":"*"}`, handler: s.handleQuery, wantStatus: http.StatusOK, wantBody: `"annotations":{"1":{"id":"1","pinned":true`},
+		{name: "list annotations", method: http.MethodGet, target: "/annotations", handler: s.handleAnnotations, wantStatus: http.StatusOK, wantBody: `"note":"root cause"`},
+		{name: "list annotations method not allowed", method: http.MethodPost, target: "/annotations", handler: s.handleAnnotations, wantStatus: http.StatusMethodNotAllowed},
+		{name: "clear annotation", method: http.MethodDelete, target: "/entries/1/annotation", handler: s.handleEntry, wantStatus: http.StatusNoContent},
+		{name: "get cleared annotation", method: http.MethodGet, target: "/entries/1/annotation", handler: s.handleEntry, wantStatus: http.StatusNotFound},
+		{name: "annotation method not allowed", method: http.MethodPost, target: "/entries/1/annotation", handler: s.handleEntry, wantStatus: http.StatusMethodNotAllowed},
+		{name: "get entry", method: http.MethodGet, target: "/logs/2", handler: s.handleLogEntry, wantStatus: http.StatusOK, wantBody: `"raw":"secret=hunter2"`},
+		{name: "get entry missing id", method: http.MethodGet, target: "/logs/", handler: s.handleLogEntry, wantStatus: http.StatusBadRequest},
+		{name: "get entry method not allowed", method: http.MethodDelete, target: "/logs/2", handler: s.handleLogEntry, wantStatus: http.StatusMethodNotAllowed},
+		{name: "delete entry", method: http.MethodDelete, target: "/entries/2", handler: s.handleEntry, wantStatus: http.StatusNoContent},
+		{name: "get deleted entry", method: http.MethodGet, target: "/logs/2", handler: s.handleLogEntry, wantStatus: http.StatusNotFound},
+		{name: "delete missing entry", method: http.MethodDelete, target: "/entries/2", handler: s.handleEntry, wantStatus: http.StatusNotFound},
+		{name: "entry method not allowed", method: http.MethodPost, target: "/entries/1", handler: s.handleEntry, wantStatus: http.StatusMethodNotAllowed},
+		{name: "missing id", method: http.MethodDelete, target: "/entries/", handler: s.handleEntry, wantStatus: http.StatusBadRequest},
+		{name: "unknown sub-resource", method: http.MethodGet, target: "/entries/1/other", handler: s.handleEntry, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			tt.handler(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d body=%s", rr.Code, rr.Body.String())
+			}
+			if tt.wantBody != "" && !strings.Contains(rr.Body.String(), tt.wantBody) {
+				t.Fatalf("body = %s, want substring %s", rr.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestInvestigationHandlers(t *testing.T) {
+	db := newTestStorage(t)
+	now := time.Now().UTC()
+	storeLog(t, db, "1", "ERROR", "db | timeout", now.Add(-time.Minute), nil)
+	if err := db.SetAnnotation(&storage.Annotation{ID: "1", Note: "first\nsymptom"}); err != nil {
+		t.Fatalf("SetAnnotation() error = %v", err)
+	}
+
+	s := NewServer(db, "")
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		handler    func(http.ResponseWriter, *http.Request)
+		wantStatus int
+		wantBody   []string
+	}{
+		{name: "create", method: http.MethodPost, target: "/investigations", body: `{"name":"outage","entry_ids":["1","gone"],"queries":["level:ERROR"],"notes":"Failover at 10:30"}`, handler: s.handleInvestigations, wantStatus: http.StatusCreated, wantBody: []string{`"created_at"`}},
+		{name: "create missing name", method: http.MethodPost, target: "/investigations", body: `{}`, handler: s.handleInvestigations, wantStatus: http.StatusBadRequest},
+		{name: "create invalid json", method: http.MethodPost, target: "/investigations", body: "{", handler: s.handleInvestigations, wantStatus: http.StatusBadRequest},
+		{name: "list", method: http.MethodGet, target: "/investigations", handler: s.handleInvestigations, wantStatus: http.StatusOK, wantBody: []string{`"name":"outage"`}},
+		{name: "list method not allowed", method: http.MethodDelete, target: "/investigations", handler: s.handleInvestigations, wantStatus: http.StatusMethodNotAllowed},
+		{name: "get", method: http.MethodGet, target: "/investigations/outage", handler: s.handleInvestigation, wantStatus: http.StatusOK, wantBody: []string{`"entry_ids":["1","gone"]`}},
+		{
+			name: "export markdown", method: http.MethodGet, target: "/investigations/outage/export", handler: s.handleInvestigation, wantStatus: http.StatusOK,
+			wantBody: []string{"# outage", "Failover at 10:30", "- `level:ERROR`", `| ERROR | db \| timeout | first<br>symptom |`, "_1 referenced entries are no longer stored._"},
+		},
+		{name: "export unsupported format", method: http.MethodGet, target: "/investigations/outage/export?format=pdf", handler: s.handleInvestigation, wantStatus: http.StatusBadRequest},
+		{name: "export missing", method: http.MethodGet, target: "/investigations/nope/export", handler: s.handleInvestigation, wantStatus: http.StatusNotFound},
+		{name: "export method not allowed", method: http.MethodPost, target: "/investigations/outage/export", handler: s.handleInvestigation, wantStatus: http.StatusMethodNotAllowed},
+		{name: "put", method: http.MethodPut, target: "/investigations/outage", body: `{"notes":"resolved"}`, handler: s.handleInvestigation, wantStatus: http.StatusOK, wantBody: []string{`"notes":"resolved"`, `"entry_ids":[]`}},
+		{name: "get missing", method: http.MethodGet, target: "/investigations/nope", handler: s.handleInvestigation, wantStatus: http.StatusNotFound},
+		{name: "missing name", method: http.MethodGet, target: "/investigations/", handler: s.handleInvestigation, wantStatus: http.StatusBadRequest},
+		{name: "delete", method: http.MethodDelete, target: "/investigations/outage", handler: s.handleInvestigation, wantStatus: http.StatusNoContent},
+		{name: "delete missing", method: http.MethodDelete, target: "/investigations/outage", handler: s.handleInvestigation, wantStatus: http.StatusNotFound},
+		{name: "method not allowed", method: http.MethodPost, target: "/investigations/outage", handler: s.handleInvestigation, wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			tt.handler(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d body=%s", rr.Code, rr.Body.String())
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(rr.Body.String(), want) {
+					t.Fatalf("body = %s, want substring %s", rr.Body.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestScheduledHandlers(t *testing.T) {
+	db := newTestStorage(t)
+	s := NewServer(db, "")
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		handler    func(http.ResponseWriter, *http.Request)
+		wantStatus int
+		wantBody   []string
+	}{
+		{name: "create", method: http.MethodPost, target: "/scheduled", body: `{"name":"errors","query":"level:ERROR","interval":"1m"}`, handler: s.handleScheduled, wantStatus: http.StatusCreated, wantBody: []string{`"interval":"1m"`}},
+		{name: "create invalid interval", method: http.MethodPost, target: "/scheduled", body: `{"name":"fast","query":"*","interval":"1s"}`, handler: s.handleScheduled, wantStatus: http.StatusBadRequest},
+		{name: "create invalid query", method: http.MethodPost, target: "/scheduled", body: `{"name":"bad","query":"level:(","interval":"1m"}`, handler: s.handleScheduled, wantStatus: http.StatusBadRequest},
+		{name: "create invalid json", method: http.MethodPost, target: "/scheduled", body: "{", handler: s.handleScheduled, wantStatus: http.StatusBadRequest},
+		{name: "list", method: http.MethodGet, target: "/scheduled", handler: s.handleScheduled, wantStatus: http.StatusOK, wantBody: []string{`"name":"errors"`}},
+		{name: "list method not allowed", method: http.MethodDelete, target: "/scheduled", handler: s.handleScheduled, wantStatus: http.StatusMethodNotAllowed},
+		{name: "get", method: http.MethodGet, target: "/scheduled/errors", handler: s.handleScheduledQuery, wantStatus: http.StatusOK, wantBody: []string{`"query":"level:ERROR"`}},
+		{name: "put", method: http.MethodPut, target: "/scheduled/errors", body: `{"query":"level:WARN","interval":"5m"}`, handler: s.handleScheduledQuery, wantStatus: http.StatusOK, wantBody: []string{`"name":"errors"`, `"interval":"5m"`}},
+		{name: "series", method: http.MethodGet, target: "/scheduled/errors/series", handler: s.handleScheduledQuery, wantStatus: http.StatusOK, wantBody: []string{`"points":[]`}},
+		{name: "series missing", method: http.MethodGet, target: "/scheduled/nope/series", handler: s.handleScheduledQuery, wantStatus: http.StatusNotFound},
+		{name: "series method not allowed", method: http.MethodPost, target: "/scheduled/errors/series", handler: s.handleScheduledQuery, wantStatus: http.StatusMethodNotAllowed},
+		{name: "get missing", method: http.MethodGet, target: "/scheduled/nope", handler: s.handleScheduledQuery, wantStatus: http.StatusNotFound},
+		{name: "missing name", method: http.MethodGet, target: "/scheduled/", handler: s.handleScheduledQuery, wantStatus: http.StatusBadRequest},
+		{name: "delete", method: http.MethodDelete, target: "/scheduled/errors", handler: s.handleScheduledQuery, wantStatus: http.StatusNoContent},
+		{name: "delete missing", method: http.MethodDelete, target: "/scheduled/errors", handler: s.handleScheduledQuery, wantStatus: http.StatusNotFound},
+		{name: "method not allowed", method: http.MethodPost, target: "/scheduled/errors", handler: s.handleScheduledQuery, wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			tt.handler(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d body=%s", rr.Code, rr.Body.String())
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(rr.Body.String(), want) {
+					t.Fatalf("body = %s, want substring %s", rr.Body.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestIngestTruncatesLongValues(t *testing.T) {
+	s := NewServer(newTestStorage(t), "")
+	s.SetMaxValueSize(8)
+	line := `{"msg":"` + strings.Repeat("m", 20) + `","body":"` + strings.Repeat("b", 20) + `","status":200}`
+	rr := httptest.NewRecorder()
+	s.handleIngest(rr, httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(line+"\n")))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", rr.Code, rr.Body.String())
+	}
+
+	q, err := query.Parse("truncated_fields:body")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	entries, total, err := s.storage.Query(q, 10, 0)
+	if err != nil || total != 1 {
+		t.Fatalf("Query(truncated_fields) = %d entries, %v", total, err)
+	}
+	got := entries[0]
+	if got.Message != "mmmmmmmm…" || got.Fields["body"] != "bbbbbbbb…" || got.Fields["status"] != 200.0 {
+		t.Fatalf("stored entry = %q %v, want message and body cut to 8 bytes", got.Message, got.Fields)
+	}
+	if raw, err := s.storage.GetRaw(got.ID); err != nil || raw != line {
+		t.Fatalf("GetRaw() = %q, %v, want the original line", raw, err)
+	}
+}
+
+func TestIngestCustomFormat(t *testing.T) {
+	s := NewServer(newTestStorage(t), "")
+	d, err := parser.NewDetectorWithCustom([]parser.CustomFormat{{Name: "legacy", Pattern: `^(?P<level>[A-Z]+) \[(?P<module>\w+)\] (?P<message>.*)$`}})
+	if err != nil {
+		t.Fatalf("NewDetectorWithCustom() error = %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	s.handleIngest(rr, httptest.NewRequest(http.MethodPost, "/ingest?format=legacy", strings.NewReader("ERROR [db] down\n")))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status before SetDetector = %d, want 400", rr.Code)
+	}
+
+	s.SetDetector(d)
+	rr = httptest.NewRecorder()
+	s.handleIngest(rr, httptest.NewRequest(http.MethodPost, "/ingest?format=legacy", strings.NewReader("ERROR [db] down\n")))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", rr.Code, rr.Body.String())
+	}
+	q, err := query.Parse("module:db")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	entries, total, err := s.storage.Query(q, 10, 0)
+	if err != nil || total != 1 {
+		t.Fatalf("Query(module:db) = %d entries, %v", total, err)
+	}
+	if got := entries[0]; got.Level != "ERROR" || got.Message != "down" || got.Fields["module"] != "db" {
+		t.Fatalf("stored entry = %s %q %v", got.Level, got.Message, got.Fields)
+	}
+}
+
+func TestIngestCSVHeaderRow(t *testing.T) {
+	s := NewServer(newTestStorage(t), "")
+	d, err := parser.NewDetectorWithCustom([]parser.CustomFormat{{Name: "export", Delimiter: ","}})
+	if err != nil {
+		t.Fatalf("NewDetectorWithCustom() error = %v", err)
+	}
+	s.SetDetector(d)
+
+	rr := httptest.NewRecorder()
+	s.handleIngest(rr, httptest.NewRequest(http.MethodPost, "/ingest?format=export", strings.NewReader("level,message,rows\nwarn,slow scan,5000\nbroken\n")))
+	var resp struct {
+		Accepted int `json:"accepted"`
+		Rejected int `json:"rejected"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, decode error = %v", rr.Code, err)
+	}
+	if resp.Accepted != 1 || resp.Rejected != 1 {
+		t.Fatalf("accepted = %d, rejected = %d; want 1 and 1 (the header row is neither)", resp.Accepted, resp.Rejected)
+	}
+	entries, _, err := s.storage.Query(&storage.AllFilter{}, 10, 0)
+	if err != nil || len(entries) != 1 || entries[0].Message != "slow scan" || entries[0].Fields["rows"] != float64(5000) {
+		t.Fatalf("stored = %v, %v", entries, err)
+	}
+}
+
+func TestIngestCSVHeaderPerRequest(t *testing.T) {
+	s := NewServer(newTestStorage(t), "")
+
+	bodies := []string{
+		"level,message,rows\nwarn,slow scan,5000\n",
+		"message,user,level\nlogged in,ana,info\n",
+	}
+	for _, body := range bodies {
+		rr := httptest.NewRecorder()
+		s.handleIngest(rr, httptest.NewRequest(http.MethodPost, "/ingest?format=csv", strings.NewReader(body)))
+		var resp struct {
+			Accepted int `json:"accepted"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil || rr.Code != http.StatusOK || resp.Accepted != 1 {
+			t.Fatalf("status = %d, accepted = %d, decode error = %v", rr.Code, resp.Accepted, err)
+		}
+	}
+
+	q, err := query.Parse("user:ana")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	entries, total, err := s.storage.Query(q, 10, 0)
+	if err != nil || total != 1 {
+		t.Fatalf("Query(user:ana) = %d entries, %v; the second header was not read", total, err)
+	}
+	if got := entries[0]; got.Level != "INFO" || got.Message != "logged in" {
+		t.Fatalf("stored entry = %s %q %v", got.Level, got.Message, got.Fields)
+	}
+}
+
+func TestIngestSourceFormats(t *testing.T) {
+	s := NewServer(newTestStorage(t), "")
+	s.SetSourceFormats(map[string]string{"nginx": "access"})
+
+	tests := []struct {
+		target       string
+		wantAccepted int
+	}{
+		// The access format rejects the JSON line.
+		{target: "/ingest?source=nginx", wantAccepted: 1},
+		// ?format= wins over the source's format.
+		{target: "/ingest?source=nginx&format=json", wantAccepted: 1},
+		// Other sources are auto-detected.
+		{target: "/ingest?source=api", wantAccepted: 2},
+	}
+	body := `10.0.0.1 - - [17/Feb/2026:10:30:45 +0000] "GET / HTTP/1.1" 200 612` + "\n" + `{"level":"INFO","message":"ok"}` + "\n"
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		s.handleIngest(rr, httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(body)))
+		var resp struct {
+			Accepted int `json:"accepted"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil || rr.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, decode error = %v", tt.target, rr.Code, err)
+		}
+		if resp.Accepted != tt.wantAccepted {
+			t.Errorf("%s: accepted = %d, want %d", tt.target, resp.Accepted, tt.wantAccepted)
+		}
+	}
+}
+
+func TestParseFailures(t *testing.T) {
+	s := NewServer(newTestStorage(t), "")
+	if err := s.SetTokens([]Token{{Token: "admin-token", Admin: true}, {Token: "alice-token", Namespace: "alice"}}); err != nil {
+		t.Fatalf("SetTokens() error = %v", err)
+	}
+	h := s.routes()
+	do := func(method, target, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do(http.MethodPost, "/ingest?format=json&source=api", "alice-token", "{\"msg\":\"ok\"}\nnot json\nnot json either\n"); rr.Code != http.StatusOK {
+		t.Fatalf("ingest status = %d body=%s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodPost, "/ingest?format=logfmt", "admin-token", "plain text\n"); rr.Code != http.StatusOK {
+		t.Fatalf("ingest status = %d body=%s", rr.Code, rr.Body.String())
+	}
+
+	type response struct {
+		Total   int `json:"total"`
+		Reasons []struct {
+			Format string `json:"format"`
+			Count  int    `json:"count"`
+		} `json:"reasons"`
+		Failures []storage.ParseFailure `json:"failures"`
+	}
+	get := func(target, token string) response {
+		t.Helper()
+		rr := do(http.MethodGet, target, token, "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("GET %s status = %d body=%s", target, rr.Code, rr.Body.String())
+		}
+		var resp response
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp
+	}
+
+	all := get("/parse-failures", "admin-token")
+	if all.Total != 3 || len(all.Reasons) != 2 || all.Reasons[0].Format != "json" || all.Reasons[0].Count != 2 {
+		t.Fatalf("admin view = %+v", all)
+	}
+	if f := all.Failures[0]; f.Line != "plain text" || f.Reason == "" {
+		t.Fatalf("newest failure = %+v", f)
+	}
+
+	alice := get("/parse-failures?limit=1", "alice-token")
+	if alice.Total != 2 || len(alice.Failures) != 1 {
+		t.Fatalf("alice view = %+v", alice)
+	}
+	if f := alice.Failures[0]; f.Namespace != "alice" || f.Source != "api" || f.Format != "json" {
+		t.Fatalf("alice failure = %+v", f)
+	}
+
+	if rr := do(http.MethodGet, "/parse-failures?limit=x", "admin-token", ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("invalid limit status = %d", rr.Code)
+	}
+}
+
+func TestIngestPausedForDiskSpace(t *testing.T) {
+	// No filesystem has this much free space, so storing starts paused.
+	db, err := storage.NewBadgerStorage(storage.Config{DBPath: t.TempDir(), MinFreeBytes: 1 << 62})
+	if err != nil {
+		t.Fatalf("NewBadgerStorage() error = %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	s := NewServer(db, "")
+
+	rr := httptest.NewRecorder()
+	s.handleIngest(rr, httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader("level=INFO msg=hi\n")))
+	if rr.Code != http.StatusInsufficientStorage {
+		t.Fatalf("ingest status = %d body=%s, want 507", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	s.handleHealth(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var report healthReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode /health: %v", err)
+	}
+	if rr.Code != http.StatusOK || report.Status != "degraded" || !report.Components.Storage.Disk.Paused ||
+		len(report.Problems) != 1 || !strings.HasPrefix(report.Problems[0], "ingestion paused:") {
+		t.Fatalf("/health = %d %+v, want degraded with the ingestion pause", rr.Code, report)
+	}
+}
+
+func TestIngestBoundsRejectedLines(t *testing.T) {
+	countFailures := func(db *storage.BadgerStorage) int {
+		t.Helper()
+		n := 0
+		if err := db.ScanParseFailures(func(storage.ParseFailure) error { n++; return nil }); err != nil {
+			t.Fatalf("ScanParseFailures() error = %v", err)
+		}
+		return n
+	}
+
+	// Only the first maxIngestFailures rejected lines are kept; all are
+	// counted.
+	s := NewServer(newTestStorage(t), "")
+	body := strings.Repeat("not json\n", maxIngestFailures+5) + "{\"msg\":\"ok\"}\n"
+	rr := httptest.NewRecorder()
+	s.handleIngest(rr, httptest.NewRequest(http.MethodPost, "/ingest?format=json", strings.NewReader(body)))
+	var resp struct {
+		Accepted      int   `json:"accepted"`
+		Rejected      int   `json:"rejected"`
+		RejectedLines []int `json:"rejected_lines"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("ingest = %d, %v", rr.Code, err)
+	}
+	if resp.Accepted != 1 || resp.Rejected != maxIngestFailures+5 || len(resp.RejectedLines) != maxRejectedLines {
+		t.Fatalf("response = %+v", resp)
+	}
+	if n := countFailures(s.storage); n != maxIngestFailures {
+		t.Fatalf("kept %d rejected lines, want %d", n, maxIngestFailures)
+	}
+
+	// When a batch write fails, the lines rejected before it are still kept
+	// and reported.
+	db, err := storage.NewBadgerStorage(storage.Config{DBPath: t.TempDir(), MinFreeBytes: 1 << 62})
+	if err != nil {
+		t.Fatalf("NewBadgerStorage() error = %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	s = NewServer(db, "")
+	in, err := s.newIngester(context.Background(), "json", "", "", nil)
+	if err != nil {
+		t.Fatalf("newIngester() error = %v", err)
+	}
+	if err := in.add("not json"); err != nil {
+		t.Fatalf("add() error = %v", err)
+	}
+	for i := 0; i < ingestBatchLines && err == nil; i++ {
+		err = in.add(`{"msg":"ok"}`)
+	}
+	if !errors.Is(err, storage.ErrIngestPaused) {
+		t.Fatalf("add() error = %v, want the batch write to fail", err)
+	}
+	in.recordFailures()
+	rr = httptest.NewRecorder()
+	writeFlushError(rr, err, in)
+	var envelope struct {
+		Error struct {
+			Details struct {
+				Rejected      int   `json:"rejected"`
+				RejectedLines []int `json:"rejected_lines"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&envelope); err != nil || rr.Code != http.StatusInsufficientStorage {
+		t.Fatalf("flush error = %d, %v", rr.Code, err)
+	}
+	if d := envelope.Error.Details; d.Rejected != 1 || len(d.RejectedLines) != 1 || d.RejectedLines[0] != 1 {
+		t.Fatalf("details = %+v", d)
+	}
+	if n := countFailures(db); n != 1 {
+		t.Fatalf("kept %d rejected lines after the failed write, want 1", n)
+	}
+}
+
+func TestQueryTreeHandlers(t *testing.T) {
+	s := NewServer(newTestStorage(t), "")
+	ts := httptest.NewServer(s.routes())
+	defer ts.Close()
+
+	post := func(path, body string) (int, map[string]json.RawMessage) {
+		t.Helper()
+		resp, err := http.Post(ts.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST %s error = %v", path, err)
+		}
+		defer resp.Body.Close()
+		var out map[string]json.RawMessage
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	code, out := post("/query/parse", `{"query":"level:ERROR OR service:api AND NOT timeout"}`)
+	if code != http.StatusOK {
+		t.Fatalf("/query/parse status = %d", code)
+	}
+	wantAST := `{"op":"or","children":[{"op":"term","kind":"match","field":"level","value":"ERROR"},` +
+		`{"op":"and","children":[{"op":"term","kind":"match","field":"service","value":"api"},{"op":"not","child":{"op":"term","kind":"keyword","value":"timeout"}}]}]}`
+	if string(out["ast"]) != wantAST || string(out["query"]) != `"level:ERROR OR service:api AND NOT timeout"` {
+		t.Fatalf("/query/parse = %s", mustJSON(t, out))
+	}
+
+	// The builder edits the tree and asks for the text back.
+	edited := strings.Replace(wantAST, `"value":"ERROR"`, `"value":"WARN"`, 1)
+	code, out = post("/query/format", `{"ast":`+edited+`}`)
+	if code != http.StatusOK || string(out["query"]) != `"level:WARN OR service:api AND NOT timeout"` {
+		t.Fatalf("/query/format = %d %s", code, mustJSON(t, out))
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		wantCode int
+		wantPos  string
+	}{
+		{name: "parse error", path: "/query/parse", body: `{"query":"level:ERROR AND"}`, wantCode: http.StatusBadRequest, wantPos: "15"},
+		{name: "parse bad body", path: "/query/parse", body: `{`, wantCode: http.StatusBadRequest},
+		{name: "format missing ast", path: "/query/format", body: `{}`, wantCode: http.StatusBadRequest},
+		{name: "format unknown op", path: "/query/format", body: `{"ast":{"op":"xor"}}`, wantCode: http.StatusBadRequest},
+		{name: "format inexpressible term", path: "/query/format", body: `{"ast":{"op":"term","kind":"match","field":"msg","value":"a b"}}`, wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, out := post(tt.path, tt.body)
+			if code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (%s)", code, tt.wantCode, mustJSON(t, out))
+			}
+			if tt.wantPos != "" && !strings.Contains(string(out["error"]), `"position":`+tt.wantPos) {
+				t.Fatalf("error = %s, want position %s", out["error"], tt.wantPos)
+			}
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	s.handleQueryParse(rr, httptest.NewRequest(http.MethodGet, "/query/parse", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET /query/parse status = %d", rr.Code)
+	}
+}
+
+func TestHandleComplete(t *testing.T) {
+	db := newTestStorage(t)
+	now := time.Now().UTC()
+	storeLog(t, db, "1", "ERROR", "charge failed", now, map[string]interface{}{"service": "billing"})
+	storeLog(t, db, "2", "INFO", "ok", now, map[string]interface{}{"service": "api", "status": 200})
+	s := NewServer(db, "")
+
+	complete := func(rawQuery string) (int, query.Completions) {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		s.routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/complete?"+rawQuery, nil))
+		var c query.Completions
+		json.NewDecoder(rr.Body).Decode(&c)
+		return rr.Code, c
+	}
+
+	code, c := complete("q=level:ERROR+se")
+	if code != http.StatusOK || c.Start != 12 || c.End != 14 || len(c.Candidates) != 1 || c.Candidates[0].Text != "service:" {
+		t.Fatalf("/complete field = %d %+v, want service:", code, c)
+	}
+	code, c = complete("q=service:b+AND+x&cursor=9")
+	if code != http.StatusOK || c.Field != "service" || c.Start != 8 || c.End != 9 || len(c.Candidates) != 1 || c.Candidates[0].Text != "billing" {
+		t.Fatalf("/complete value = %d %+v, want billing", code, c)
+	}
+	if code, _ := complete("q=le&cursor=3"); code != http.StatusBadRequest {
+		t.Fatalf("/complete cursor past q status = %d, want 400", code)
+	}
+}
+
+func TestSchemasHandler(t *testing.T) {
+	db := newTestStorage(t)
+	now := time.Now().UTC()
+	storeLog(t, db, "1", "INFO", "request", now.Add(-3*time.Minute), map[string]interface{}{"path": "/a", "status": 200})
+	storeLog(t, db, "2", "INFO", "request", now.Add(-2*time.Minute), map[string]interface{}{"path": "/b", "status": 404})
+	storeLog(t, db, "3", "ERROR", "job failed", now.Add(-time.Minute), map[string]interface{}{"job": "sync"})
+	s := NewServer(db, "")
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		wantStatus int
+		wantBody   []string
+		notBody    []string
+	}{
+		{name: "default", method: http.MethodGet, target: "/schemas", wantStatus: http.StatusOK, wantBody: []string{`"fields":["path","status"],"count":2`, `"fields":["job"],"count":1`, `"id":"2"`, `"other":0`}},
+		{name: "no examples", method: http.MethodGet, target: "/schemas?examples=0", wantStatus: http.StatusOK, wantBody: []string{`"examples":null`}, notBody: []string{`"id":"2"`}},
+		{name: "query", method: http.MethodGet, target: "/schemas?query=level:ERROR", wantStatus: http.StatusOK, wantBody: []string{`"fields":["job"]`}, notBody: []string{`"path"`}},
+		{name: "invalid examples", method: http.MethodGet, target: "/schemas?examples=-1", wantStatus: http.StatusBadRequest},
+		{name: "invalid query", method: http.MethodGet, target: "/schemas?query=(", wantStatus: http.StatusBadRequest},
+		{name: "method not allowed", method: http.MethodPost, target: "/schemas", wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			s.handleSchemas(rr, httptest.NewRequest(tt.method, tt.target, nil))
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d body=%s", rr.Code, rr.Body.String())
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(rr.Body.String(), want) {
+					t.Fatalf("body = %s, want substring %s", rr.Body.String(), want)
+				}
+			}
+			for _, not := range tt.notBody {
+				if strings.Contains(rr.Body.String(), not) {
+					t.Fatalf("body = %s, want no %s", rr.Body.String(), not)
+				}
+			}
+		})
+	}
+}
+
+func TestLargestHandler(t *testing.T) {
+	db := newTestStorage(t)
+	now := time.Now().UTC()
+	storeLog(t, db, "1", "INFO", "started", now.Add(-2*time.Minute), map[string]interface{}{"service": "api"})
+	storeLog(t, db, "2", "ERROR", "crashed", now.Add(-time.Minute), map[string]interface{}{"stack": strings.Repeat("frame\n", 500)})
+	s := NewServer(db, "")
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		wantStatus int
+		wantBody   []string
+		notBody    []string
+	}{
+		{name: "default", method: http.MethodGet, target: "/stats/largest", wantStatus: http.StatusOK, wantBody: []string{`"id":"2"`, `"name":"stack"`, `"id":"1"`}},
+		{name: "limit", method: http.MethodGet, target: "/stats/largest?limit=1", wantStatus: http.StatusOK, wantBody: []string{`"id":"2"`}, notBody: []string{`"id":"1"`}},
+		{name: "query", method: http.MethodGet, target: "/stats/largest?query=level:INFO", wantStatus: http.StatusOK, wantBody: []string{`"id":"1"`}, notBody: []string{`"id":"2"`}},
+		{name: "invalid limit", method: http.MethodGet, target: "/stats/largest?limit=0", wantStatus: http.StatusBadRequest},
+		{name: "invalid query", method: http.MethodGet, target: "/stats/largest?query=(", wantStatus: http.StatusBadRequest},
+		{name: "method not allowed", method: http.MethodPost, target: "/stats/largest", wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			s.handleLargest(rr, httptest.NewRequest(tt.method, tt.target, nil))
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d body=%s", rr.Code, rr.Body.String())
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(rr.Body.String(), want) {
+					t.Fatalf("body = %s, want substring %s", rr.Body.String(), want)
+				}
+			}
+			for _, not := range tt.notBody {
+				if strings.Contains(rr.Body.String(), not) {
+					t.Fatalf("body = %s, want no %s", rr.Body.String(), not)
+				}
+			}
+		})
+	}
+}
+
+func TestStatsHistoryHandler(t *testing.T) {
+	db := newTestStorage(t)
+	storeLog(t, db, "1", "ERROR", "crashed", time.Now(), nil)
+	now := time.Now()
+	for _, at := range []time.Time{now.Add(-3 * time.Hour), now.Add(-30 * time.Minute)} {
+		if err := db.RecordStatsSnapshot(at); err != nil {
+			t.Fatalf("RecordStatsSnapshot() error = %v", err)
+		}
+	}
+	s := NewServer(db, "")
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		wantStatus int
+		wantCount  int
+	}{
+		{name: "default day", method: http.MethodGet, target: "/stats/history", wantStatus: http.StatusOK, wantCount: 2},
+		{name: "window", method: http.MethodGet, target: "/stats/history?window=1h", wantStatus: http.StatusOK, wantCount: 1},
+		{name: "invalid window", method: http.MethodGet, target: "/stats/history?window=soon", wantStatus: http.StatusBadRequest},
+		{name: "method not allowed", method: http.MethodPost, target: "/stats/history", wantStatus: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			s.handleStatsHistory(rr, httptest.NewRequest(tt.method, tt.target, nil))
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d body=%s", rr.Code, rr.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Interval  string                  `json:"interval"`
+				Snapshots []storage.StatsSnapshot `json:"snapshots"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if resp.Interval != "5m0s" || len(resp.Snapshots) != tt.wantCount {
+				t.Fatalf("response = %+v, want %d snapshots", resp, tt.wantCount)
+			}
+			if last := resp.Snapshots[len(resp.Snapshots)-1]; last.TotalLogs != 1 || last.Levels["ERROR"] != 1 {
+				t.Errorf("snapshot = %+v", last)
+			}
+		})
+	}
+}
+
+func TestDigestHandler(t *testing.T) {
+	db := newTestStorage(t)
+	now := time.Now().UTC()
+	storeLog(t, db, "1", "ERROR", "timeout after 100ms", now.Add(-10*time.Minute), nil)
+	storeLog(t, db, "2", "ERROR", "timeout after 250ms", now.Add(-5*time.Minute), nil)
+	storeLog(t, db, "3", "ERROR", "disk full", now.Add(-3*time.Hour), nil)
+	s := NewServer(db, "")
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		wantStatus int
+		wantBody   []string
+		notBody    []string
+	}{
+		{name: "default window", method: http.MethodGet, target: "/digest", wantStatus: http.StatusOK, wantBody: []string{`"window":"1h0m0s"`, `"pattern":"timeout after \u003cnum\u003ems"`, `"count":2`}, notBody: []string{"disk full"}},
+		{name: "wider window", method: http.MethodGet, target: "/digest?window=4h", wantStatus: http.StatusOK, wantBody: []string{"disk full"}},
+		{name: "limit", method: http.MethodGet, target: "/digest?window=4h&limit=1", wantStatus: http.StatusOK, notBody: []string{"disk full"}},
+		{name: "invalid window", method: http.MethodGet, target: "/digest?window=soon", wantStatus: http.StatusBadRequest},
+		{name: "invalid limit", method: http.MethodGet, target: "/digest?limit=0", wantStatus: http.StatusBadRequest},
+		{name: "method not allowed", method: http.MethodPost, target: "/digest", wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			s.handleDigest(rr, httptest.NewRequest(tt.method, tt.target, nil))
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d body=%s", rr.Code, rr.Body.String())
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(rr.Body.String(), want) {
+					t.Fatalf("body = %s, want substring %s", rr.Body.String(), want)
+				}
+			}
+			for _, not := range tt.notBody {
+				if strings.Contains(rr.Body.String(), not) {
+					t.Fatalf("body = %s, want no %s", rr.Body.String(), not)
+				}
+			}
+		})
+	}
+}
+
+func TestSetTokensValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		tokens  []Token
+		wantErr bool
+	}{
+		{name: "disabled", tokens: nil},
+		{name: "valid", tokens: []Token{{Token: "a", Namespace: "alice"}, {Token: "root", Admin: true}}},
+		{name: "empty token", tokens: []Token{{Namespace: "alice"}}, wantErr: true},
+		{name: "duplicate token", tokens: []Token{{Token: "a", Namespace: "alice"}, {Token: "a", Namespace: "bob"}}, wantErr: true},
+		{name: "missing namespace", tokens: []Token{{Token: "a"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(newTestStorage(t), "")
+			if err := s.SetTokens(tt.tokens); (err != nil) != tt.wantErr {
+				t.Fatalf("SetTokens() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNamespaceIsolation(t *testing.T) {
+	db := newTestStorage(t)
+	s := NewServer(db, "")
+	if err := s.SetTokens([]Token{
+		{Token: "alice-token", Namespace: "alice"},
+		{Token: "bob-token", Namespace: "bob"},
+		{Token: "admin-token", Admin: true},
+	}); err != nil {
+		t.Fatalf("SetTokens() error = %v", err)
+	}
+	ts := httptest.NewServer(s.routes())
+	defer ts.Close()
+
+	do := func(method, path, token, body string) (int, string) {
+		t.Helper()
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("NewRequest() error = %v", err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s error = %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		var buf bytes.Buffer
+		buf.ReadFrom(resp.Body)
+		return resp.StatusCode, buf.String()
+	}
+
+	// Non-admin pushes land in the token's namespace regardless of ?namespace=.
+	if code, body := do(http.MethodPost, "/ingest?namespace=bob", "alice-token", "{\"level\":\"ERROR\",\"msg\":\"alice failed\"}\nnot json\n"); code != http.StatusOK || !strings.Contains(body, `"accepted":2`) || !strings.Contains(body, `"namespace":"alice"`) {
+		t.Fatalf("alice ingest = %d %s", code, body)
+	}
+	if code, body := do(http.MethodPost, "/ingest?format=json", "bob-token", "{\"level\":\"ERROR\",\"msg\":\"bob failed\"}\nnot json\n"); code != http.StatusOK || !strings.Contains(body, `"accepted":1,`) || !strings.Contains(body, `"namespace":"bob","rejected":1`) {
+		t.Fatalf("bob ingest = %d %s", code, body)
+	}
+	if code, body := do(http.MethodPost, "/ingest?namespace=ops", "admin-token", `{"level":"INFO","msg":"admin push"}`); code != http.StatusOK || !strings.Contains(body, `"namespace":"ops"`) {
+		t.Fatalf("admin ingest = %d %s", code, body)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       string
+		wantStatus int
+		want       []string
+		notWant    []string
+	}{
+		{name: "public index", method: http.MethodGet, path: "/", wantStatus: http.StatusOK},
+		{name: "public health", method: http.MethodGet, path: "/health", wantStatus: http.StatusOK},
+		{name: "missing token", method: http.MethodGet, path: "/stats", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", method: http.MethodGet, path: "/stats", token: "nope", wantStatus: http.StatusUnauthorized},
+		{name: "alice query", method: http.MethodPost, path: "/query", token: "alice-token", body: `{"query":"*"}`, wantStatus: http.StatusOK, want: []string{"alice failed", `"total":2`}, notWant: []string{"bob failed", "admin push"}},
+		{name: "bob query", method: http.MethodPost, path: "/query", token: "bob-token", body: `{"query":"*"}`, wantStatus: http.StatusOK, want: []string{"bob failed"}, notWant: []string{"alice failed"}},
+		{name: "admin query all", method: http.MethodPost, path: "/query", token: "admin-token", body: `{"query":"*"}`, wantStatus: http.StatusOK, want: []string{"alice failed", "bob failed", "admin push"}},
+		{name: "admin query namespace", method: http.MethodPost, path: "/query", token: "admin-token", body: `{"query":"namespace:bob"}`, wantStatus: http.StatusOK, want: []string{"bob failed", `"total":1`}},
+		{name: "alice digest", method: http.MethodGet, path: "/digest", token: "alice-token", wantStatus: http.StatusOK, want: []string{"alice failed"}, notWant: []string{"bob failed"}},
+		{name: "alice fields", method: http.MethodGet, path: "/fields", token: "alice-token", wantStatus: http.StatusOK, notWant: []string{"bob"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := do(tt.method, tt.path, tt.token, tt.body)
+			if code != tt.wantStatus {
+				t.Fatalf("status = %d body=%s", code, body)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Fatalf("body = %s, want substring %s", body, want)
+				}
+			}
+			for _, not := range tt.notWant {
+				if strings.Contains(body, not) {
+					t.Fatalf("body = %s, want no %s", body, not)
+				}
+			}
+		})
+	}
+
+	// Entry-ID endpoints hide other namespaces' entries as missing.
+	entries, _, err := db.Query(&query.NamespaceFilter{Namespace: "bob"}, 10, 0)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Query(bob) = %v, %v", entries, err)
+	}
+	bobID := entries[0].ID
+	if code, _ := do(http.MethodGet, "/raw/"+bobID, "alice-token", ""); code != http.StatusNotFound {
+		t.Fatalf("alice raw of bob entry status = %d, want 404", code)
+	}
+	if code, _ := do(http.MethodDelete, "/entries/"+bobID, "alice-token", ""); code != http.StatusNotFound {
+		t.Fatalf("alice delete of bob entry status = %d, want 404", code)
+	}
+	if code, _ := do(http.MethodPut, "/entries/"+bobID+"/annotation", "bob-token", `{"note":"mine"}`); code != http.StatusOK {
+		t.Fatalf("bob annotate status = %d", code)
+	}
+	if code, body := do(http.MethodGet, "/annotations", "alice-token", ""); code != http.StatusOK || strings.Contains(body, "mine") {
+		t.Fatalf("alice annotations = %d %s", code, body)
+	}
+	if code, body := do(http.MethodGet, "/raw/"+bobID, "admin-token", ""); code != http.StatusOK || !strings.Contains(body, "bob failed") {
+		t.Fatalf("admin raw = %d %s", code, body)
+	}
+
+	// Saved views, investigations and scheduled queries belong to the
+	// namespace that saved them.
+	for _, res := range []struct{ path, body string }{
+		{path: "/views", body: `{"name":"errs","query":"level:ERROR"}`},
+		{path: "/investigations", body: `{"name":"errs","notes":"bob outage"}`},
+		{path: "/scheduled", body: `{"name":"errs","query":"level:ERROR","interval":"1m","namespace":"alice"}`},
+	} {
+		if code, body := do(http.MethodPost, res.path, "bob-token", res.body); code != http.StatusCreated || !strings.Contains(body, `"namespace":"bob"`) {
+			t.Fatalf("bob POST %s = %d %s", res.path, code, body)
+		}
+		if code, body := do(http.MethodGet, res.path, "alice-token", ""); code != http.StatusOK || strings.Contains(body, "errs") {
+			t.Fatalf("alice GET %s = %d %s, want bob's hidden", res.path, code, body)
+		}
+		if code, body := do(http.MethodGet, res.path, "admin-token", ""); code != http.StatusOK || !strings.Contains(body, "errs") {
+			t.Fatalf("admin GET %s = %d %s", res.path, code, body)
+		}
+		for _, method := range []string{http.MethodGet, http.MethodDelete} {
+			if code, _ := do(method, res.path+"/errs", "alice-token", ""); code != http.StatusNotFound {
+				t.Fatalf("alice %s %s/errs status = %d, want 404", method, res.path, code)
+			}
+		}
+		if code, _ := do(http.MethodPut, res.path+"/errs", "alice-token", res.body); code != http.StatusConflict {
+			t.Fatalf("alice PUT %s/errs status = %d, want 409", res.path, code)
+		}
+		// An admin edit keeps the owner's namespace.
+		if code, body := do(http.MethodPut, res.path+"/errs", "admin-token", strings.Replace(res.body, `,"namespace":"alice"`, "", 1)); code != http.StatusOK || !strings.Contains(body, `"namespace":"bob"`) {
+			t.Fatalf("admin PUT %s/errs = %d %s", res.path, code, body)
+		}
+		if res.path == "/scheduled" {
+			if code, _ := do(http.MethodGet, "/scheduled/errs/series", "alice-token", ""); code != http.StatusNotFound {
+				t.Fatalf("alice series of bob's query status = %d, want 404", code)
+			}
+		}
+		if code, _ := do(http.MethodDelete, res.path+"/errs", "bob-token", ""); code != http.StatusNoContent {
+			t.Fatalf("bob DELETE %s/errs status = %d", res.path, code)
+		}
+	}
+}
+
+func TestWebSocketAuth(t *testing.T) {
+	db := newTestStorage(t)
+	now := time.Now().UTC()
+	if err := db.Store(&storage.LogEntry{ID: "a", Timestamp: now, Level: "INFO", Message: "alice entry", Namespace: "alice"}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if err := db.Store(&storage.LogEntry{ID: "b", Timestamp: now, Level: "INFO", Message: "bob entry", Namespace: "bob"}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	s := NewServer(db, "")
+	if err := s.SetTokens([]Token{{Token: "alice-token", Namespace: "alice"}}); err != nil {
+		t.Fatalf("SetTokens() error = %v", err)
+	}
+	ts := httptest.NewServer(s.routes())
+	defer ts.Close()
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/logs"
+
+	tests := []struct {
+		name     string
+		query    string
+		header   string
+		authMsg  string
+		wantCode int // close code; 0 expects results
+	}{
+		{name: "query param", query: "?token=alice-token"},
+		{name: "header", header: "Bearer alice-token"},
+		{name: "auth message", authMsg: "alice-token"},
+		{name: "bad query param", query: "?token=nope", wantCode: closeUnauthorized},
+		{name: "bad auth message", authMsg: "nope", wantCode: closeUnauthorized},
+		{name: "subscribe without auth", wantCode: closeUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.header != "" {
+				header.Set("Authorization", tt.header)
+			}
+			conn, _, err := websocket.DefaultDialer.Dial(wsURL+tt.query, header)
+			if err != nil {
+				t.Fatalf("Dial() error = %v", err)
+			}
+			defer conn.Close()
+
+			if tt.authMsg != "" {
+				conn.WriteJSON(map[string]string{"action": "auth", "token": tt.authMsg})
+			}
+			conn.WriteJSON(map[string]string{"action": "subscribe", "query": "*"})
+
+			conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			var msg map[string]interface{}
+			err = conn.ReadJSON(&msg)
+			if tt.wantCode != 0 {
+				if !websocket.IsCloseError(err, tt.wantCode) {
+					t.Fatalf("ReadJSON() = %v, %v, want close %d", msg, err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil || msg["type"] != "results" {
+				t.Fatalf("ReadJSON() = %v, %v, want results", msg, err)
+			}
+			if logs, _ := msg["logs"].([]interface{}); len(logs) != 1 || logs[0].(map[string]interface{})["id"] != "a" {
+				t.Fatalf("results = %v, want alice's entry only", msg["logs"])
+			}
+		})
+	}
+}
+
+func TestAuditRecordsQueries(t *testing.T) {
+	db := newTestStorage(t)
+	now := time.Now().UTC()
+	if err := db.Store(&storage.LogEntry{ID: "a", Timestamp: now, Level: "ERROR", Message: "alice entry", Namespace: "alice"}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	s := NewServer(db, "")
+	if err := s.SetTokens([]Token{{Token: "alice-token", Namespace: "alice"}}); err != nil {
+		t.Fatalf("SetTokens() error = %v", err)
+	}
+	ts := httptest.NewServer(s.routes())
+	defer ts.Close()
+
+	runQuery := func() {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/query", bytes.NewBufferString(`{"query":"level:ERROR","start":"`+now.Add(-time.Hour).Format(time.RFC3339)+`"}`))
+		req.Header.Set("Authorization", "Bearer alice-token")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST /query error = %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST /query status = %d", resp.StatusCode)
+		}
+	}
+
+	// Auditing is off until a retention is set.
+	runQuery()
+	if records, _ := db.GetAudit(time.Time{}, 0); len(records) != 0 {
+		t.Fatalf("GetAudit() without retention = %+v, want none", records)
+	}
+
+	s.SetAuditRetention(time.Hour)
+	runQuery()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/logs?token=alice-token", nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+	conn.WriteJSON(map[string]string{"action": "subscribe", "query": "alice"})
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg map[string]interface{}
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("ReadJSON() error = %v", err)
+	}
+
+	records, err := db.GetAudit(time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("GetAudit() error = %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("GetAudit() = %+v, want 2 records", records)
+	}
+	live, q := records[0], records[1]
+	if q.Endpoint != "query" || q.Query != "level:ERROR" || q.Results != 1 || q.Client != "127.0.0.1" || q.Namespace != "alice" || q.Start == "" {
+		t.Fatalf("query record = %+v", q)
+	}
+	if live.Endpoint != "live" || live.Query != "alice" || live.Results != 1 || live.Namespace != "alice" {
+		t.Fatalf("live record = %+v", live)
+	}
+}
+
+func TestIngestHandler(t *testing.T) {
+	s := NewServer(newTestStorage(t), "run-1")
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "auto format", method: http.MethodPost, target: "/ingest", body: "{\"msg\":\"a\"}\n\nlevel=info msg=b\nplain text\n", wantStatus: http.StatusOK, wantBody: `"accepted":3`},
+		{name: "invalid format", method: http.MethodPost, target: "/ingest?format=xml", wantStatus: http.StatusBadRequest},
+		{name: "method not allowed", method: http.MethodGet, target: "/ingest", wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			s.handleIngest(rr, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d body=%s", rr.Code, rr.Body.String())
+			}
+			if !strings.Contains(rr.Body.String(), tt.wantBody) {
+				t.Fatalf("body = %s, want substring %s", rr.Body.String(), tt.wantBody)
+			}
+		})
+	}
+
+	// Fresh mode shows pushed entries because they carry the session.
+	entries, total, err := s.storage.Query(&query.SessionFilter{Session: "run-1"}, 10, 0)
+	if err != nil || total != 3 || entries[0].Namespace != "" {
+		t.Fatalf("Query(session) = %d entries, %v", total, err)
+	}
+
+	// Content-hash IDs make re-pushing the same timestamped lines idempotent.
+	gen, err := parser.NewIDGenerator(parser.IDHash)
+	if err != nil {
+		t.Fatalf("NewIDGenerator() error = %v", err)
+	}
+	s.SetIDGenerator(gen)
+	body := "{\"time\":\"2026-03-01T10:00:00Z\",\"msg\":\"again\"}\n"
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		s.handleIngest(rr, httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("re-push %d status = %d body=%s", i, rr.Code, rr.Body.String())
+		}
+	}
+	if _, total, err = s.storage.Query(&query.SessionFilter{Session: "run-1"}, 10, 0); err != nil || total != 4 {
+		t.Fatalf("Query(session) after re-push = %d entries, %v, want 4", total, err)
+	}
+
+	// With a dedupe window, lines seen before are skipped and reported.
+	s.SetDedupeWindow(time.Hour)
+	for i, want := range []string{`"duplicates":0`, `"duplicates":1`} {
+		rr := httptest.NewRecorder()
+		s.handleIngest(rr, httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader("plain repeat\n")))
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), want) {
+			t.Fatalf("dedupe push %d = %d %s, want %s", i, rr.Code, rr.Body.String(), want)
+		}
+	}
+}
+
+func TestIngestGzipAndBatches(t *testing.T) {
+	s := NewServer(newTestStorage(t), "")
+	s.SetDedupeWindow(time.Hour)
+
+	var lines strings.Builder
+	for i := 0; i < ingestBatchLines+20; i++ {
+		fmt.Fprintf(&lines, "level=info msg=line-%d\n", i)
+	}
+	lines.WriteString("level=info msg=line-0\n") // repeats a line of the first batch
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(lines.String()))
+	zw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/ingest", &gz)
+	req.Header.Set("Content-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	s.handleIngest(rr, req)
+	var resp struct {
+		Accepted, Rejected, Duplicates int
+		RejectedLines                  []int `json:"rejected_lines"`
+	}
+	if rr.Code != http.StatusOK || json.NewDecoder(rr.Body).Decode(&resp) != nil {
+		t.Fatalf("gzip push status = %d body=%s", rr.Code, rr.Body.String())
+	}
+	if resp.Accepted != ingestBatchLines+20 || resp.Duplicates != 1 || resp.Rejected != 0 {
+		t.Fatalf("gzip push = %+v", resp)
+	}
+	if _, total, err := s.storage.Query(&storage.AllFilter{}, 1, 0); err != nil || total != ingestBatchLines+20 {
+		t.Fatalf("stored %d entries, %v", total, err)
+	}
+
+	tests := []struct {
+		name       string
+		encoding   string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "rejected lines", body: "{\"msg\":\"ok\"}\n{\"msg\":\n\n{broken\n", wantStatus: http.StatusOK, wantBody: `"rejected_lines":[2,4]`},
+		{name: "none rejected", body: "{\"msg\":\"fine\"}\n", wantStatus: http.StatusOK, wantBody: `"rejected_lines":[]`},
+		{name: "bad gzip", encoding: "gzip", body: "not gzip", wantStatus: http.StatusBadRequest},
+		{name: "unsupported encoding", encoding: "br", body: "x", wantStatus: http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/ingest?format=json", strings.NewReader(tt.body))
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+			rr := httptest.NewRecorder()
+			s.handleIngest(rr, req)
+			if rr.Code != tt.wantStatus || !strings.Contains(rr.Body.String(), tt.wantBody) {
+				t.Fatalf("status = %d body=%s, want %d %s", rr.Code, rr.Body.String(), tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestListenGELF(t *testing.T) {
+	db := newTestStorage(t)
+	s := NewServer(db, "")
+	addr, err := s.ListenGELF("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenGELF() error = %v", err)
+	}
+	defer s.Shutdown(context.Background())
+	conn, err := net.Dial("udp", addr.String())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	message := func(msg string) []byte {
+		return []byte(`{"version":"1.1","host":"web01","short_message":"` + msg + `","level":3,"_service":"api"}`)
+	}
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(message("gzip"))
+	zw.Close()
+	var zl bytes.Buffer
+	zlw := zlib.NewWriter(&zl)
+	zlw.Write(message("zlib"))
+	zlw.Close()
+	chunked := message("chunked")
+	id := []byte("msgid-01")
+	datagrams := [][]byte{
+		message("plain"),
+		gz.Bytes(),
+		zl.Bytes(),
+		// Chunks may arrive out of order.
+		append(append(append([]byte{0x1e, 0x0f}, id...), 1, 2), chunked[10:]...),
+		append(append(append([]byte{0x1e, 0x0f}, id...), 0, 2), chunked[:10]...),
+		[]byte(`not gelf`),
+	}
+	for _, d := range datagrams {
+		if _, err := conn.Write(d); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	var got []string
+	deadline := time.Now().Add(5 * time.Second)
+	for len(got) < 4 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		entries, _, err := db.Query(&storage.AllFilter{}, 10, 0)
+		if err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+		got = got[:0]
+		for _, e := range entries {
+			if e.Level != "ERROR" || e.Fields["service"] != "api" {
+				t.Errorf("entry = %+v", e)
+			}
+			got = append(got, e.Message)
+		}
+	}
+	sort.Strings(got)
+	if want := []string{"chunked", "gzip", "plain", "zlib"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("messages = %q, want %q", got, want)
+	}
+
+	var failures []storage.ParseFailure
+	for time.Now().Before(deadline) && len(failures) == 0 {
+		failures = failures[:0]
+		db.ScanParseFailures(func(f storage.ParseFailure) error {
+			failures = append(failures, f)
+			return nil
+		})
+		time.Sleep(20 * time.Millisecond)
+	}
+	if len(failures) != 1 || failures[0].Line != "not gelf" || failures[0].Format != "gelf" {
+		t.Fatalf("parse failures = %+v", failures)
+	}
+}
+
+func TestGELFChunks(t *testing.T) {
+	now := time.Now()
+	chunk := func(id string, seq, count byte, data string) []byte {
+		return append(append(append([]byte{0x1e, 0x0f}, id...), seq, count), data...)
+	}
+	g := newGELFChunks()
+	if got := g.add(chunk("aaaaaaaa", 0, 2, "he"), now); got != nil {
+		t.Fatalf("first chunk = %q, want nil", got)
+	}
+	// A chunk arriving after the timeout starts over.
+	if got := g.add(chunk("aaaaaaaa", 1, 2, "llo"), now.Add(gelfChunkTimeout+time.Second)); got != nil {
+		t.Fatalf("late chunk = %q, want nil", got)
+	}
+	if got := g.add(chunk("aaaaaaaa", 1, 2, "llo"), now); got != nil {
+		t.Fatalf("duplicate chunk = %q, want nil", got)
+	}
+	for _, bad := range [][]byte{chunk("bbbbbbbb", 2, 2, "x"), chunk("cccccccc", 0, 0, "x"), chunk("dddddddd", 0, 129, "x"), {0x1e, 0x0f, 1}} {
+		if got := g.add(bad, now); got != nil {
+			t.Errorf("add(%q) = %q, want nil", bad, got)
+		}
+	}
+	g = newGELFChunks()
+	g.add(chunk("aaaaaaaa", 1, 2, "llo"), now)
+	if got := g.add(chunk("aaaaaaaa", 0, 2, "he"), now); string(got) != "hello" {
+		t.Fatalf("reassembled = %q, want hello", got)
+	}
+	if len(g.pending) != 0 {
+		t.Errorf("pending = %d after reassembly", len(g.pending))
+	}
+}
+
+func TestIngestRecordsSourceAndHost(t *testing.T) {
+	s := NewServer(newTestStorage(t), "")
+	for _, target := range []string{"/ingest?source=web-1%3A%2Fvar%2Flog%2Fapp.log", "/ingest?source=web-2", "/ingest"} {
+		rr := httptest.NewRecorder()
+		s.handleIngest(rr, httptest.NewRequest(http.MethodPost, target, strings.NewReader("level=info msg=hello\n")))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("POST %s status = %d body=%s", target, rr.Code, rr.Body.String())
+		}
+	}
+
+	q, err := query.Parse(`source:"web-1:/var/log/app.log"`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	entries, total, err := s.storage.Query(q, 10, 0)
+	if err != nil || total != 1 || entries[0].Source != "web-1:/var/log/app.log" || entries[0].Host != nil {
+		t.Fatalf("Query(source) = %+v, total %d, %v", entries, total, err)
+	}
+
+	rr := httptest.NewRecorder()
+	s.handleIngest(rr, httptest.NewRequest(http.MethodPost, "/ingest?host=web-3&host_os=linux&host_user=deploy", strings.NewReader("level=info msg=hello\n")))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("POST with host status = %d body=%s", rr.Code, rr.Body.String())
+	}
+	q, err = query.Parse(`host.name:"web-3" AND host.user:deploy`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	entries, total, err = s.storage.Query(q, 10, 0)
+	if err != nil || total != 1 || *entries[0].Host != (storage.HostInfo{Name: "web-3", OS: "linux", User: "deploy"}) {
+		t.Fatalf("Query(host) = %+v, total %d, %v", entries, total, err)
+	}
+}
+
+func TestReloadHandler(t *testing.T) {
+	s := NewServer(newTestStorage(t), "")
+	if err := s.SetTokens([]Token{{Token: "alice-token", Namespace: "alice"}, {Token: "admin-token", Admin: true}}); err != nil {
+		t.Fatalf("SetTokens() error = %v", err)
+	}
+	handler := s.routes()
+
+	do := func(method, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin/reload", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do(http.MethodPost, "admin-token"); rr.Code != http.StatusNotFound {
+		t.Fatalf("reload without reloader = %d, want 404", rr.Code)
+	}
+
+	calls := 0
+	var reloadErr error
+	s.SetReloader(func() error {
+		calls++
+		return reloadErr
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		err    error
+		want   int
+	}{
+		{name: "wrong method", method: http.MethodGet, token: "admin-token", want: http.StatusMethodNotAllowed},
+		{name: "non-admin", method: http.MethodPost, token: "alice-token", want: http.StatusForbidden},
+		{name: "invalid config", method: http.MethodPost, token: "admin-token", err: fmt.Errorf("invalid parsing format"), want: http.StatusBadRequest},
+		{name: "reloaded", method: http.MethodPost, token: "admin-token", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reloadErr = tt.err
+			if rr := do(tt.method, tt.token); rr.Code != tt.want {
+				t.Fatalf("%s /admin/reload = %d %s, want %d", tt.method, rr.Code, rr.Body.String(), tt.want)
+			}
+		})
+	}
+	if calls != 2 {
+		t.Fatalf("reloader called %d times, want 2", calls)
+	}
+}
+
+func TestQueryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newQueryCache(2)
+	a, b, d := queryCacheKey{query: "a"}, queryCacheKey{query: "b"}, queryCacheKey{query: "d"}
+
+	c.put(a, queryResult{total: 1, generation: 7})
+	c.put(b, queryResult{total: 2, generation: 7})
+	if _, ok := c.get(a, 7); !ok {
+		t.Fatal("get(a) missed")
+	}
+	c.put(d, queryResult{total: 3, generation: 7}) // evicts b, the least recently used
+
+	if _, ok := c.get(b, 7); ok {
+		t.Fatal("get(b) hit after eviction")
+	}
+	if got, ok := c.get(d, 7); !ok || got.total != 3 {
+		t.Fatalf("get(d) = %+v, %v", got, ok)
+	}
+	if _, ok := c.get(a, 8); ok {
+		t.Fatal("get(a) hit at a newer generation")
+	}
+	if _, ok := c.get(a, 7); ok {
+		t.Fatal("stale result was not dropped")
+	}
+}
+
+func TestHandleQueryCacheStatus(t *testing.T) {
+	db := newTestStorage(t)
+	now := time.Now().UTC()
+	storeLog(t, db, "1", "ERROR", "first", now.Add(-time.Minute), nil)
+	s := NewServer(db, "")
+
+	query := func(body string) (string, int, []storage.LogEntry) {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		s.handleQuery(rr, httptest.NewRequest(http.MethodPost, "/query", bytes.NewBufferString(body)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rr.Code, rr.Body.String())
+		}
+		var resp struct {
+			Cache string             `json:"cache"`
+			Total int                `json:"total"`
+			Logs  []storage.LogEntry `json:"logs"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp.Cache, resp.Total, resp.Logs
+	}
+
+	steps := []struct {
+		name      string
+		before    func()
+		body      string
+		wantCache string
+		wantTotal int
+	}{
+		{name: "first request scans", body: `{"query":"level:ERROR"}`, wantCache: "miss", wantTotal: 1},
+		{name: "repeat is cached", body: `{"query":"level:ERROR"}`, wantCache: "hit", wantTotal: 1},
+		{name: "other page scans", body: `{"query":"level:ERROR","offset":1}`, wantCache: "miss", wantTotal: 1},
+		{name: "write invalidates", before: func() { storeLog(t, db, "2", "ERROR", "second", now, nil) }, body: `{"query":"level:ERROR"}`, wantCache: "miss", wantTotal: 2},
+		{name: "cached before entry delete", body: `{"query":"level:ERROR"}`, wantCache: "hit", wantTotal: 2},
+		{name: "entry delete invalidates", before: func() {
+			rr := httptest.NewRecorder()
+			s.handleEntry(rr, httptest.NewRequest(http.MethodDelete, "/entries/2", nil))
+			if rr.Code != http.StatusNoContent {
+				t.Fatalf("DELETE /entries/2 status = %d", rr.Code)
+			}
+		}, body: `{"query":"level:ERROR"}`, wantCache: "miss", wantTotal: 1},
+		{name: "delete invalidates", before: func() {
+			if _, err := db.DeleteByLevel("ERROR"); err != nil {
+				t.Fatalf("DeleteByLevel() error = %v", err)
+			}
+		}, body: `{"query":"level:ERROR"}`, wantCache: "miss", wantTotal: 0},
+	}
+	for _, step := range steps {
+		if step.before != nil {
+			step.before()
+		}
+		cache, total, logs := query(step.body)
+		if cache != step.wantCache || total != step.wantTotal {
+			t.Fatalf("%s: cache = %q, total = %d; want %q, %d", step.name, cache, total, step.wantCache, step.wantTotal)
+		}
+		for _, entry := range logs {
+			if entry.ID == "2" && step.name == "entry delete invalidates" {
+				t.Fatalf("%s: deleted entry still returned", step.name)
+			}
+		}
+	}
+}
+
+func TestHandleQueryFollow(t *testing.T) {
+	db := newTestStorage(t)
+	now := time.Now().UTC()
+	storeLog(t, db, "1", "ERROR", "first", now.Add(-2*time.Minute), nil)
+	storeLog(t, db, "2", "INFO", "second", now.Add(-time.Minute), nil)
+	s := NewServer(db, "")
+
+	type response struct {
+		Logs   []*storage.LogEntry `json:"logs"`
+		Cursor string              `json:"cursor"`
+	}
+	query := func(params, body string) (response, int) {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		s.handleQuery(rr, httptest.NewRequest(http.MethodPost, "/query"+params, bytes.NewBufferString(body)))
+		var resp response
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return resp, rr.Code
+	}
+
+	resp, _ := query("", `{"query":"*"}`)
+	if len(resp.Logs) != 2 || resp.Cursor != formatCursor(now.Add(-time.Minute).UnixNano(), "2") {
+		t.Fatalf("initial query = %d logs, cursor %q", len(resp.Logs), resp.Cursor)
+	}
+	cursor := resp.Cursor
+
+	if resp, _ = query("?after_cursor="+cursor, `{"query":"*"}`); len(resp.Logs) != 0 || resp.Cursor != cursor {
+		t.Fatalf("after cursor = %d logs, cursor %q, want none and the same cursor", len(resp.Logs), resp.Cursor)
+	}
+
+	start := time.Now()
+	if resp, _ = query("?wait=100ms&after_cursor="+cursor, `{"query":"*"}`); len(resp.Logs) != 0 || time.Since(start) < 100*time.Millisecond {
+		t.Fatalf("wait without arrivals = %d logs after %v", len(resp.Logs), time.Since(start))
+	}
+
+	done := make(chan response, 1)
+	go func() {
+		resp, _ := query("?wait=30s&after_cursor="+cursor, `{"query":"level:ERROR"}`)
+		done <- resp
+	}()
+	time.Sleep(50 * time.Millisecond)
+	for _, e := range []*storage.LogEntry{
+		{ID: "3", Timestamp: now, Level: "INFO", Message: "skipped"},
+		{ID: "4", Timestamp: now.Add(time.Millisecond), Level: "ERROR", Message: "woke"},
+	} {
+		if err := db.Store(e); err != nil {
+			t.Fatalf("Store() error = %v", err)
+		}
+		s.BroadcastLog(e)
+		time.Sleep(20 * time.Millisecond)
+	}
+	select {
+	case resp = <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("waiting query did not return after a matching entry arrived")
+	}
+	if len(resp.Logs) != 1 || resp.Logs[0].ID != "4" || resp.Cursor != cursorOf(resp.Logs[0]) {
+		t.Fatalf("woken query = %+v, cursor %q, want entry 4", resp.Logs, resp.Cursor)
+	}
+
+	for _, params := range []string{"?wait=soon", "?wait=2m", "?after_cursor=nope", "?after_cursor=x:1"} {
+		if _, code := query(params, `{"query":"*"}`); code != http.StatusBadRequest {
+			t.Errorf("query%s status = %d, want 400", params, code)
+		}
+	}
+}
+
+// The cursor is a position in log order: entries that arrive late with
+// older timestamps are not returned to a follower. This is the documented
+// limitation of /query?after_cursor=.
+func TestHandleQueryFollowSkipsLateOlderEntries(t *testing.T) {
+	db := newTestStorage(t)
+	now := time.Now().UTC()
+	storeLog(t, db, "1", "ERROR", "seen", now.Add(-time.Minute), nil)
+	s := NewServer(db, "")
+
+	query := func(params, body string) (logs []*storage.LogEntry, cursor string) {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		s.handleQuery(rr, httptest.NewRequest(http.MethodPost, "/query"+params, bytes.NewBufferString(body)))
+		var resp struct {
+			Logs   []*storage.LogEntry `json:"logs"`
+			Cursor string              `json:"cursor"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil || rr.Code != http.StatusOK {
+			t.Fatalf("status = %d, decode error = %v", rr.Code, err)
+		}
+		return resp.Logs, resp.Cursor
+	}
+
+	_, cursor := query("", `{"query":"level:ERROR"}`)
+	storeLog(t, db, "late", "ERROR", "backfilled", now.Add(-2*time.Minute), nil)
+	storeLog(t, db, "new", "ERROR", "fresh", now, nil)
+	if logs, _ := query("?after_cursor="+cursor, `{"query":"level:ERROR"}`); len(logs) != 1 || logs[0].ID != "new" {
+		t.Fatalf("after cursor = %+v, want only the entry after the cursor", logs)
+	}
+
+	// Without matches the cursor starts at the current time, so an entry
+	// stored afterwards with a timestamp a second old is not returned.
+	_, cursor = query("", `{"query":"level:WARN"}`)
+	storeLog(t, db, "lagging", "WARN", "clock skew", time.Now().Add(-time.Second), nil)
+	if logs, _ := query("?after_cursor="+cursor, `{"query":"level:WARN"}`); len(logs) != 0 {
+		t.Fatalf("after empty cursor = %+v, want the lagging entry skipped", logs)
+	}
+}
+
+func TestLogCursorOrdersEntriesOfOneInstant(t *testing.T) {
+	ts := time.Unix(100, 0)
+	c, err := parseCursor(formatCursor(ts.UnixNano(), "b"))
+	if err != nil {
+		t.Fatalf("parseCursor() error = %v", err)
+	}
+	tests := []struct {
+		entry storage.LogEntry
+		want  bool
+	}{
+		{storage.LogEntry{ID: "a", Timestamp: ts}, false},
+		{storage.LogEntry{ID: "b", Timestamp: ts}, false},
+		{storage.LogEntry{ID: "c", Timestamp: ts}, true},
+		{storage.LogEntry{ID: "a", Timestamp: ts.Add(time.Nanosecond)}, true},
+		{storage.LogEntry{ID: "z", Timestamp: ts.Add(-time.Nanosecond)}, false},
+	}
+	for _, tt := range tests {
+		if got := c.Match(&tt.entry); got != tt.want {
+			t.Errorf("Match(%s@%d) = %v, want %v", tt.entry.ID, tt.entry.Timestamp.UnixNano(), got, tt.want)
+		}
+	}
+}
+
+func TestErrorResponsesUseJSONEnvelope(t *testing.T) {
+	s := NewServer(newTestStorage(t), "")
+
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		body         string
+		handler      func(http.ResponseWriter, *http.Request)
+		wantStatus   int
+		wantCode     string
+		wantPosition *int
+	}{
+		{name: "parse error", method: http.MethodPost, target: "/query", body: `{"query":"level:ERROR AND"}`, handler: s.handleQuery, wantStatus: http.StatusBadRequest, wantCode: "invalid_query", wantPosition: intPtr(15)},
+		{name: "bad body", method: http.MethodPost, target: "/query", body: "{", handler: s.handleQuery, wantStatus: http.StatusBadRequest, wantCode: "bad_request"},
+		{name: "limit too large", method: http.MethodPost, target: "/query", body: `{"limit":10001}`, handler: s.handleQuery, wantStatus: http.StatusBadRequest, wantCode: "bad_request"},
+		{name: "negative offset", method: http.MethodPost, target: "/query", body: `{"offset":-1}`, handler: s.handleQuery, wantStatus: http.StatusBadRequest, wantCode: "bad_request"},
+		{name: "body too large", method: http.MethodPost, target: "/query", body: `{"query":"` + strings.Repeat("a", maxQueryBodyBytes) + `"}`, handler: s.handleQuery, wantStatus: http.StatusRequestEntityTooLarge, wantCode: "request_too_large"},
+		{name: "too many clauses", method: http.MethodPost, target: "/query", body: `{"query":"` + strings.Repeat("a ", query.MaxClauses+1) + `"}`, handler: s.handleQuery, wantStatus: http.StatusBadRequest, wantCode: "invalid_query", wantPosition: intPtr(2 * query.MaxClauses)},
+		{name: "digest limit too large", method: http.MethodGet, target: "/digest?limit=10001", handler: s.handleDigest, wantStatus: http.StatusBadRequest, wantCode: "bad_request"},
+		{name: "method", method: http.MethodGet, target: "/query", handler: s.handleQuery, wantStatus: http.StatusMethodNotAllowed, wantCode: "method_not_allowed"},
+		{name: "not found", method: http.MethodGet, target: "/raw/missing", handler: s.handleRaw, wantStatus: http.StatusNotFound, wantCode: "not_found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			tt.handler(rr, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+				t.Fatalf("Content-Type = %q", ct)
+			}
+			var body struct {
+				Error apiError `json:"error"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if body.Error.Code != tt.wantCode || body.Error.Message == "" {
+				t.Fatalf("error = %+v, want code %q with a message", body.Error, tt.wantCode)
+			}
+			switch {
+			case tt.wantPosition == nil && body.Error.Position != nil:
+				t.Fatalf("position = %d, want none", *body.Error.Position)
+			case tt.wantPosition != nil && (body.Error.Position == nil || *body.Error.Position != *tt.wantPosition):
+				t.Fatalf("position = %v, want %d", body.Error.Position, *tt.wantPosition)
+			}
+		})
+	}
+}
+
+func intPtr(v int) *int { return &v }
+
+func TestHandleDownloadStreamsRawLines(t *testing.T) {
+	db := newTestStorage(t)
+	s := NewServer(db, "")
+	base := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	storeLog(t, db, "b", "ERROR", `{"level":"error","msg":"second"}`, base.Add(2*time.Minute), nil)
+	storeLog(t, db, "a", "ERROR", `{"level":"error","msg":"first"}`, base.Add(time.Minute), nil)
+	storeLog(t, db, "c", "INFO", `{"level":"info","msg":"other"}`, base.Add(3*time.Minute), nil)
+	storeLog(t, db, "d", "ERROR", `{"level":"error","msg":"later"}`, base.Add(time.Hour), nil)
+
+	target := "/download?query=level:ERROR&start=" + base.Format(time.RFC3339) + "&end=" + base.Add(10*time.Minute).Format(time.RFC3339)
+	rr := httptest.NewRecorder()
+	s.handleDownload(rr, httptest.NewRequest(http.MethodGet, target, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	want := `{"level":"error","msg":"first"}` + "\n" + `{"level":"error","msg":"second"}` + "\n"
+	if got := rr.Body.String(); got != want {
+		t.Fatalf("body = %q, want %q", got, want)
+	}
+	if cd := rr.Header().Get("Content-Disposition"); cd != `attachment; filename="peek-20260301-100000.log"` {
+		t.Fatalf("Content-Disposition = %q", cd)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	rr = httptest.NewRecorder()
+	s.handleDownload(rr, httptest.NewRequest(http.MethodGet, "/download?query=level:[bad", nil))
+	if rr.Code != http.StatusBadRequest || rr.Header().Get("Content-Disposition") != "" {
+		t.Fatalf("invalid query: status = %d, headers = %v", rr.Code, rr.Header())
+	}
+}
+
+func TestHandleHealthReportsComponents(t *testing.T) {
+	db := newTestStorage(t)
+	s := NewServer(db, "")
+	storeLog(t, db, "h1", "INFO", "hello", time.Now(), nil)
+
+	health := func() healthReport {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		s.handleHealth(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", rr.Code)
+		}
+		var report healthReport
+		if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return report
+	}
+
+	report := health()
+	if report.Status != "ok" || len(report.Problems) != 0 {
+		t.Fatalf("status = %q, problems = %v; want ok", report.Status, report.Problems)
+	}
+	st := report.Components.Storage
+	if !st.Open || !st.Writable || st.LastWrite == nil || !st.Retention.Enabled || !st.Retention.Running {
+		t.Fatalf("storage = %+v, want open, writable, written and retention running", st)
+	}
+	if report.LogsStored != 1 {
+		t.Fatalf("logs_stored = %d, want 1", report.LogsStored)
+	}
+
+	rr := httptest.NewRecorder()
+	s.handleIngest(rr, httptest.NewRequest(http.MethodPost, "/ingest?namespace=team", strings.NewReader(`{"level":"INFO","message":"pushed"}`+"\n")))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ingest status = %d", rr.Code)
+	}
+	s.SetSourceStatus("stdin", SourceRunning, nil)
+	s.SetSourceStatus("tail", SourceRestarting, errors.New("exited with status 1"))
+
+	report = health()
+	if report.Status != "degraded" || len(report.Problems) != 1 || !strings.Contains(report.Problems[0], "tail") {
+		t.Fatalf("status = %q, problems = %v; want degraded by the tail source", report.Status, report.Problems)
+	}
+	if report.LastError != "tail: exited with status 1" {
+		t.Fatalf("last_error = %q", report.LastError)
+	}
+	var names []string
+	for _, src := range report.Components.Sources {
+		names = append(names, src.Name+"="+src.State)
+	}
+	if got := strings.Join(names, ","); got != "ingest:team=running,stdin=running,tail=restarting" {
+		t.Fatalf("sources = %s", got)
+	}
+
+	s.SetSourceStatus("tail", SourceRunning, nil)
+	if report = health(); report.Status != "ok" {
+		t.Fatalf("status = %q after the source recovered, problems = %v", report.Status, report.Problems)
+	}
+}
+
+func TestFederatedQuery(t *testing.T) {
+	base := time.Now().UTC().Add(-time.Hour)
+	newInstance := func(ids ...string) (*Server, *httptest.Server) {
+		db := newTestStorage(t)
+		for _, id := range ids {
+			offset, _ := time.ParseDuration(id + "s")
+			storeLog(t, db, id, "INFO", "entry "+id, base.Add(offset), nil)
+		}
+		s := NewServer(db, "")
+		ts := httptest.NewServer(s.routes())
+		t.Cleanup(ts.Close)
+		return s, ts
+	}
+	local, localTS := newInstance("1", "4")
+	peerA, peerATS := newInstance("2", "5")
+	_, peerBTS := newInstance("3", "6")
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	if err := local.SetPeers([]Peer{{URL: peerATS.URL + "/"}, {URL: peerBTS.URL}, {URL: down.URL}}); err != nil {
+		t.Fatalf("SetPeers() error = %v", err)
+	}
+	// A peer federating back must not loop.
+	if err := peerA.SetPeers([]Peer{{URL: localTS.URL}}); err != nil {
+		t.Fatalf("SetPeers() error = %v", err)
+	}
+
+	query := func(body map[string]interface{}) map[string]interface{} {
+		t.Helper()
+		resp, err := http.Post(localTS.URL+"/query", "application/json", strings.NewReader(mustJSON(t, body)))
+		if err != nil {
+			t.Fatalf("POST /query error = %v", err)
+		}
+		defer resp.Body.Close()
+		var result map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d: %v", resp.StatusCode, result)
+		}
+		return result
+	}
+	ids := func(result map[string]interface{}) string {
+		var got []string
+		for _, l := range result["logs"].([]interface{}) {
+			entry := l.(map[string]interface{})
+			id := entry["id"].(string)
+			if fields, _ := entry["fields"].(map[string]interface{}); fields["peer"] != nil {
+				id += "@" + strings.Split(fields["peer"].(string), ":")[0]
+			}
+			got = append(got, id)
+		}
+		return strings.Join(got, ",")
+	}
+
+	all := query(map[string]interface{}{"query": "*"})
+	if got := ids(all); got != "1,2@127.0.0.1,3@127.0.0.1,4,5@127.0.0.1,6@127.0.0.1" {
+		t.Fatalf("merged ids = %s", got)
+	}
+	if all["total"] != float64(6) {
+		t.Fatalf("total = %v, want 6", all["total"])
+	}
+	peers := all["peers"].([]interface{})
+	if len(peers) != 3 || peers[0].(map[string]interface{})["total"] != float64(2) || peers[2].(map[string]interface{})["error"] == nil {
+		t.Fatalf("peers = %v", peers)
+	}
+
+	if got := ids(query(map[string]interface{}{"query": "*", "offset": 2, "limit": 3})); got != "3@127.0.0.1,4,5@127.0.0.1" {
+		t.Fatalf("page ids = %s", got)
+	}
+	if got := ids(query(map[string]interface{}{"query": "message:\"entry 5\" OR message:\"entry 1\""})); got != "1,5@127.0.0.1" {
+		t.Fatalf("filtered ids = %s", got)
+	}
+	page := query(map[string]interface{}{"query": "*", "limit": 2, "count_mode": "none"})
+	if got := ids(page); got != "1,2@127.0.0.1" || page["has_more"] != true {
+		t.Fatalf("count_mode none = %s, has_more %v", got, page["has_more"])
+	}
+}
+
+func TestFederatedQueryKeepsNamespace(t *testing.T) {
+	now := time.Now().UTC()
+	peerDB := newTestStorage(t)
+	for i, ns := range []string{"alice", "bob", "alice", "bob"} {
+		entry := &storage.LogEntry{ID: fmt.Sprintf("%s-%d", ns, i), Timestamp: now.Add(time.Duration(i-10) * time.Second), Level: "ERROR", Message: "remote", Namespace: ns}
+		if err := peerDB.Store(entry); err != nil {
+			t.Fatalf("Store() error = %v", err)
+		}
+	}
+	peer := NewServer(peerDB, "")
+	if err := peer.SetTokens([]Token{{Token: "federate-token", Admin: true}}); err != nil {
+		t.Fatalf("SetTokens() error = %v", err)
+	}
+	peerTS := httptest.NewServer(peer.routes())
+	defer peerTS.Close()
+
+	s := NewServer(newTestStorage(t), "")
+	if err := s.SetTokens([]Token{{Token: "alice-token", Namespace: "alice"}}); err != nil {
+		t.Fatalf("SetTokens() error = %v", err)
+	}
+	if err := s.SetPeers([]Peer{{URL: peerTS.URL, Token: "federate-token"}}); err != nil {
+		t.Fatalf("SetPeers() error = %v", err)
+	}
+
+	for _, q := range []string{"*", "level:ERROR OR message:remote"} {
+		req := httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(mustJSON(t, map[string]interface{}{"query": q})))
+		req.Header.Set("Authorization", "Bearer alice-token")
+		rr := httptest.NewRecorder()
+		s.routes().ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("query %q status = %d body=%s", q, rr.Code, rr.Body.String())
+		}
+		var resp struct {
+			Logs  []storage.LogEntry `json:"logs"`
+			Total int                `json:"total"`
+			Peers []peerStatus       `json:"peers"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		var ids []string
+		for _, e := range resp.Logs {
+			ids = append(ids, e.ID)
+		}
+		if got := strings.Join(ids, ","); got != "alice-0,alice-2" || resp.Total != 2 {
+			t.Fatalf("query %q = %s (total %d), want alice's entries only; peers = %+v", q, got, resp.Total, resp.Peers)
+		}
+	}
+}
+
+func TestFederatedLiveTail(t *testing.T) {
+	peer := NewServer(newTestStorage(t), "")
+	peerTS := httptest.NewServer(peer.routes())
+	defer peerTS.Close()
+
+	s := NewServer(newTestStorage(t), "")
+	if err := s.SetPeers([]Peer{{URL: peerTS.URL}}); err != nil {
+		t.Fatalf("SetPeers() error = %v", err)
+	}
+	ts := httptest.NewServer(s.routes())
+	defer ts.Close()
+	defer s.Shutdown(context.Background())
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/logs", nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+	if err := conn.WriteJSON(map[string]string{"action": "subscribe", "query": "level:ERROR"}); err != nil {
+		t.Fatalf("WriteJSON subscribe: %v", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var first map[string]interface{}
+	if err := conn.ReadJSON(&first); err != nil || first["type"] != "results" {
+		t.Fatalf("initial message = %v, %v", first, err)
+	}
+
+	// Broadcast on the peer until its tail has subscribed and relays one.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			peer.BroadcastLog(&storage.LogEntry{ID: "info", Timestamp: time.Now(), Level: "INFO", Message: "skip", Fields: map[string]interface{}{}})
+			peer.BroadcastLog(&storage.LogEntry{ID: "error", Timestamp: time.Now(), Level: "ERROR", Message: "remote", Fields: map[string]interface{}{}})
+		}
+	}()
+
+	var msg struct {
+		Type  string           `json:"type"`
+		Entry storage.LogEntry `json:"entry"`
+	}
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("ReadJSON live: %v", err)
+	}
+	if msg.Type != "log" || msg.Entry.ID != "error" || msg.Entry.Fields["peer"] != strings.TrimPrefix(peerTS.URL, "http://") {
+		t.Fatalf("live message = %+v", msg)
+	}
+}
+
+func TestSetPeersRejectsInvalidURL(t *testing.T) {
+	s := NewServer(newTestStorage(t), "")
+	for _, u := range []string{"hostA:8080", "ftp://hostA", "http://"} {
+		if err := s.SetPeers([]Peer{{URL: u}}); err == nil {
+			t.Errorf("SetPeers(%q) error = nil", u)
+		}
+	}
+}
+
+func TestGRPCService(t *testing.T) {
+	s := NewServer(newTestStorage(t), "")
+	if err := s.SetTokens([]Token{{Token: "team-a", Namespace: "a"}}); err != nil {
+		t.Fatalf("SetTokens() error = %v", err)
+	}
+	addr, err := s.ListenGRPC("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenGRPC() error = %v", err)
+	}
+	defer s.Shutdown(context.Background())
+
+	conn, err := grpc.NewClient(addr.String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(wireCodec{})))
+	if err != nil {
+		t.Fatalf("grpc.NewClient() error = %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	authed := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer team-a")
+
+	// Calls without a token are refused.
+	var resp queryResponse
+	err = conn.Invoke(ctx, "/peek.v1.Peek/Query", &queryRequest{}, &resp)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("Query without a token error = %v, want Unauthenticated", err)
+	}
+
+	// A live stream sees entries ingested after it starts.
+	streamCtx, stopStream := context.WithCancel(authed)
+	defer stopStream()
+	live, err := conn.NewStream(streamCtx, &grpcServiceDesc.Streams[0], "/peek.v1.Peek/Stream")
+	if err != nil {
+		t.Fatalf("NewStream(Stream) error = %v", err)
+	}
+	if err := live.SendMsg(&streamRequest{Query: "level:ERROR"}); err != nil {
+		t.Fatalf("Stream SendMsg() error = %v", err)
+	}
+	live.CloseSend()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		s.mu.RLock()
+		n := len(s.streams)
+		s.mu.RUnlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stream did not subscribe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Ingest is client-streaming; the first message's options apply to all.
+	push, err := conn.NewStream(authed, &grpcServiceDesc.Streams[1], "/peek.v1.Peek/Ingest")
+	if err != nil {
+		t.Fatalf("NewStream(Ingest) error = %v", err)
+	}
+	reqs := []*ingestRequest{
+		{Format: "logfmt", Source: "api", Namespace: "b", Host: &storage.HostInfo{Name: "vm1"}, Lines: []string{"level=info msg=started", "not logfmt"}},
+		{Lines: []string{`level=error msg="db down" attempt=3`}},
+	}
+	for _, req := range reqs {
+		if err := push.SendMsg(req); err != nil {
+			t.Fatalf("Ingest SendMsg() error = %v", err)
+		}
+	}
+	push.CloseSend()
+	var ingested ingestResponse
+	if err := push.RecvMsg(&ingested); err != nil {
+		t.Fatalf("Ingest RecvMsg() error = %v", err)
+	}
+	want := ingestResponse{Accepted: 2, Rejected: 1, RejectedLines: []int{2}, Namespace: "a"}
+	if !reflect.DeepEqual(ingested, want) {
+		t.Fatalf("Ingest = %+v, want %+v (a non-admin token keeps its namespace)", ingested, want)
+	}
+
+	var got storage.LogEntry
+	if err := live.RecvMsg(&wireEntry{&got}); err != nil {
+		t.Fatalf("Stream RecvMsg() error = %v", err)
+	}
+	if got.Message != "db down" || fmt.Sprint(got.Fields["attempt"]) != "3" || got.Host == nil || got.Host.Name != "vm1" || got.Source != "api" {
+		t.Fatalf("streamed entry = %+v", got)
+	}
+
+	if err := conn.Invoke(authed, "/peek.v1.Peek/Query", &queryRequest{Query: "source:api", Limit: 1, SkipTotal: true}, &resp); err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(resp.Logs) != 1 || resp.Total != 1 || !resp.HasMore || resp.Logs[0].Namespace != "a" || resp.Logs[0].Timestamp.IsZero() {
+		t.Fatalf("Query = %+v", resp)
+	}
+	err = conn.Invoke(authed, "/peek.v1.Peek/Query", &queryRequest{Query: "level:("}, &resp)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Query with an invalid query error = %v, want InvalidArgument", err)
+	}
+}
+
+func TestTraceHandler(t *testing.T) {
+	db := newTestStorage(t)
+	base := time.Now().UTC().Add(-time.Hour)
+	for i, e := range []*storage.LogEntry{
+		{ID: "gateway", TraceID: "req-7", Message: "GET /checkout"},
+		{ID: "api", Fields: map[string]interface{}{"request_id": "req-7"}, Message: "charging card"},
+		{ID: "other", TraceID: "req-8", Message: "GET /cart"},
+		{ID: "billing", Fields: map[string]interface{}{"correlation_id": "req-7"}, Message: "card declined"},
+		{ID: "prefix", Fields: map[string]interface{}{"request_id": "req-77"}, Message: "not this one"},
+	} {
+		e.Timestamp, e.Level, e.Raw = base.Add(time.Duration(i)*time.Second), "INFO", e.Message
+		if err := db.Store(e); err != nil {
+			t.Fatalf("Store() error = %v", err)
+		}
+	}
+	s := NewServer(db, "")
+
+	tests := []struct {
+		target     string
+		wantStatus int
+		wantIDs    []string
+		wantTotal  int
+	}{
+		{target: "/trace/req-7", wantStatus: http.StatusOK, wantIDs: []string{"gateway", "api", "billing"}, wantTotal: 3},
+		{target: "/trace/req-7?limit=2", wantStatus: http.StatusOK, wantIDs: []string{"gateway", "api"}, wantTotal: 3},
+		{target: "/trace/unknown", wantStatus: http.StatusOK, wantIDs: []string{}},
+		{target: "/trace/", wantStatus: http.StatusBadRequest},
+		{target: "/trace/req-7?limit=0", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		s.handleTrace(rr, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if rr.Code != tt.wantStatus {
+			t.Fatalf("%s: status = %d body=%s", tt.target, rr.Code, rr.Body.String())
+		}
+		if tt.wantIDs == nil {
+			continue
+		}
+		var resp struct {
+			Logs  []storage.LogEntry `json:"logs"`
+			Total int                `json:"total"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: decode: %v", tt.target, err)
+		}
+		ids := []string{}
+		for _, e := range resp.Logs {
+			ids = append(ids, e.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.wantIDs) || resp.Total != tt.wantTotal {
+			t.Fatalf("%s: ids = %v (total %d), want %v (total %d) oldest first", tt.target, ids, resp.Total, tt.wantIDs, tt.wantTotal)
+		}
+	}
+}
+
+func TestPipelineDebug(t *testing.T) {
+	s := NewServer(newTestStorage(t), "")
+	rr := httptest.NewRecorder()
+	s.handlePipelineDebug(rr, httptest.NewRequest(http.MethodGet, "/pipeline-debug", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("status while off = %d, want 404", rr.Code)
+	}
+
+	d := parser.NewDetector()
+	if err := d.SetAliases(map[string]string{"svc": "service"}); err != nil {
+		t.Fatalf("SetAliases() error = %v", err)
+	}
+	s.SetDetector(d)
+	s.SetPipelineDebug(2)
+	for _, push := range []struct{ target, body string }{
+		{"/ingest?source=api", "{\"level\":\"info\",\"message\":\"a\",\"svc\":\"api\"}\nlevel=info msg=b\nplain text\n"},
+		{"/ingest?format=json", "{\"message\":\"c\"}\nnot json\n"},
+	} {
+		rr = httptest.NewRecorder()
+		s.handleIngest(rr, httptest.NewRequest(http.MethodPost, push.target, strings.NewReader(push.body)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("ingest status = %d body=%s", rr.Code, rr.Body.String())
+		}
+	}
+
+	get := func(target string) []PipelineTrace {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		s.handlePipelineDebug(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d body=%s", target, rr.Code, rr.Body.String())
+		}
+		var resp struct {
+			SampleEvery int             `json:"sample_every"`
+			Traces      []PipelineTrace `json:"traces"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || resp.SampleEvery != 2 {
+			t.Fatalf("%s: decode = %+v, %v", target, resp, err)
+		}
+		return resp.Traces
+	}
+	traces := get("/pipeline-debug")
+	if len(traces) != 3 {
+		t.Fatalf("traces = %+v, want 3 (every other line)", traces)
+	}
+	failed, raw, aliased := traces[0], traces[1], traces[2]
+	if failed.Outcome != PipelineFailed || failed.Format != "json" || failed.Error == "" {
+		t.Errorf("newest trace = %+v, want the failed json line", failed)
+	}
+	if raw.Parser != "raw" || raw.Outcome != PipelineStored || raw.Source != "api" {
+		t.Errorf("second trace = %+v, want the raw line stored", raw)
+	}
+	if aliased.Parser != "json" || aliased.Input != "ingest" || aliased.Lines != 1 || aliased.EntryID == "" ||
+		!reflect.DeepEqual(aliased.Transforms, []string{"alias: svc -> service"}) || !slices.Contains(aliased.Fields, "service") {
+		t.Errorf("oldest trace = %+v, want the aliased json line", aliased)
+	}
+
+	if got := get("/pipeline-debug?id=" + aliased.EntryID); len(got) != 1 || got[0].EntryID != aliased.EntryID {
+		t.Errorf("?id= traces = %+v, want the json line's", got)
+	}
+	if got := get("/pipeline-debug?limit=1"); len(got) != 1 || got[0].Outcome != PipelineFailed {
+		t.Errorf("?limit=1 traces = %+v, want the newest", got)
+	}
+}
+
+func TestHealthReportsParserCommands(t *testing.T) {
+	s := NewServer(newTestStorage(t), "")
+	d, err := parser.NewDetectorWithCustom([]parser.CustomFormat{{Name: "vendor", Command: []string{"sh", "-c", "exit 1"}}})
+	if err != nil {
+		t.Fatalf("NewDetectorWithCustom() error = %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+	s.SetDetector(d)
+
+	// The command exits without answering, so the pushed line is rejected.
+	rr := httptest.NewRecorder()
+	s.handleIngest(rr, httptest.NewRequest(http.MethodPost, "/ingest?format=vendor", strings.NewReader("opaque record\n")))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"rejected":1`) {
+		t.Fatalf("ingest = %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	s.handleHealth(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var report healthReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	parsers := report.Components.Parsers
+	if len(parsers) != 1 || parsers[0].Name != "vendor" || parsers[0].State != parser.CommandFailed {
+		t.Fatalf("parsers = %+v", parsers)
+	}
+	if report.Status != "degraded" || !strings.Contains(strings.Join(report.Problems, "\n"), "parser command vendor failed") {
+		t.Errorf("status = %s, problems = %v", report.Status, report.Problems)
+	}
 }
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mchurichi/peek/pkg/parser"
+	"github.com/mchurichi/peek/pkg/query"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// defaultTraceLimit bounds the entries GET /trace/{id} returns by default.
+const defaultTraceLimit = 1000
+
+// handleTrace handles GET /trace/{id}: every entry of one request, the
+// entries whose trace_id, request_id or correlation_id is id, oldest first.
+// It takes /query's start and end and a limit, which keeps the first
+// entries of a longer trace.
+func (s *Server) handleTrace(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/trace/")
+	if id == "" {
+		writeError(w, "Missing trace id", http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	limit := defaultTraceLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxQueryLimit {
+			writeError(w, fmt.Sprintf("Invalid limit (use 1 to %d)", maxQueryLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	filter, tr, err := s.buildFilter(r.Context(), "*", "", parseTime(q.Get("start")), parseTime(q.Get("end")))
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	filter = &query.AndFilter{Left: filter, Right: correlationFilter(id)}
+	entries, total, err := s.storage.QueryContext(r.Context(), filter, storage.QueryOptions{TimeRange: tr, Limit: limit})
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []*storage.LogEntry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":    id,
+		"logs":  entries,
+		"total": total,
+	})
+}
+
+// correlationFilter matches entries whose trace, request or correlation id
+// is id.
+func correlationFilter(id string) query.Filter {
+	return &query.OrFilter{
+		Left: &query.FieldFilter{Field: "trace_id", Value: id, Exact: true},
+		Right: &query.OrFilter{
+			Left:  &query.FieldFilter{Field: parser.RequestIDField, Value: id, Exact: true},
+			Right: &query.FieldFilter{Field: parser.CorrelationIDField, Value: id, Exact: true},
+		},
+	}
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/mchurichi/peek/pkg/query"
+	"github.com/mchurichi/peek/pkg/storage"
+)
+
+// handleViews handles GET /views (list) and POST /views (create or replace)
+func (s *Server) handleViews(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		all, err := s.storage.ListViews()
+		if err != nil {
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		views := make([]storage.View, 0, len(all))
+		for _, v := range all {
+			if owns(r.Context(), v.Namespace) {
+				views = append(views, v)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"views": views})
+	case http.MethodPost:
+		var v storage.View
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			writeError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		s.saveView(r.Context(), w, &v, http.StatusCreated)
+	default:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleView handles GET, PUT and DELETE /views/{name}
+func (s *Server) handleView(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/views/")
+	if name == "" {
+		writeError(w, "Missing view name", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		v, ok := s.ownedView(w, r, name)
+		if !ok {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	case http.MethodPut:
+		var v storage.View
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			writeError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		v.Name = name
+		s.saveView(r.Context(), w, &v, http.StatusOK)
+	case http.MethodDelete:
+		if _, ok := s.ownedView(w, r, name); !ok {
+			return
+		}
+		err := s.storage.DeleteView(name)
+		if errors.Is(err, storage.ErrNotFound) {
+			writeError(w, "View not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ownedView returns the named view, or writes 404 and reports false when it
+// does not exist or belongs to another namespace.
+func (s *Server) ownedView(w http.ResponseWriter, r *http.Request, name string) (*storage.View, bool) {
+	v, err := s.storage.GetView(name)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && !owns(r.Context(), v.Namespace)) {
+		writeError(w, "View not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return v, true
+}
+
+// saveView validates and stores v in the caller's namespace, then writes it
+// back with status. A view of another namespace is not replaced.
+func (s *Server) saveView(ctx context.Context, w http.ResponseWriter, v *storage.View, status int) {
+	if strings.TrimSpace(v.Name) == "" {
+		writeError(w, "Missing view name", http.StatusBadRequest)
+		return
+	}
+	existing, err := s.storage.GetView(v.Name)
+	switch {
+	case err == nil && !owns(ctx, existing.Namespace):
+		writeError(w, "View name is taken by another namespace", http.StatusConflict)
+		return
+	case err == nil && v.Namespace == "":
+		v.Namespace = existing.Namespace
+	case err != nil && !errors.Is(err, storage.ErrNotFound):
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	v.Namespace = namespaceFor(ctx, v.Namespace)
+	if v.Query != "" {
+		if _, err := query.Parse(v.Query); err != nil {
+			writeQueryError(w, "Invalid query", err)
+			return
+		}
+	}
+	if v.TimePreset == "custom" && v.Start == nil && v.End == nil {
+		writeError(w, "Custom time range needs start or end", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.storage.SaveView(v); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
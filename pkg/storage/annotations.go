@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// SetAnnotation stores the annotation for an existing entry. An annotation
+// that is neither pinned nor has a note is removed instead.
+func (s *BadgerStorage) SetAnnotation(a *Annotation) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	err := s.db.Update(func(txn *badger.Txn) error {
+		if err := entryExists(txn, a.ID); err != nil {
+			return err
+		}
+		if !a.Pinned && a.Note == "" {
+			return txn.Delete(annotationKey(a.ID))
+		}
+
+		a.UpdatedAt = time.Now().UTC()
+		data, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+		return txn.Set(annotationKey(a.ID), data)
+	})
+	if err != nil {
+		return fmt.Errorf("set annotation %s: %w", a.ID, err)
+	}
+	return nil
+}
+
+// GetAnnotation returns the annotation for an entry or ErrNotFound.
+func (s *BadgerStorage) GetAnnotation(id string) (*Annotation, error) {
+	var a *Annotation
+	err := s.db.View(func(txn *badger.Txn) error {
+		var err error
+		a, err = getAnnotation(txn, id)
+		if err == nil && a == nil {
+			return ErrNotFound
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get annotation %s: %w", id, err)
+	}
+	return a, nil
+}
+
+// GetAnnotations returns the annotations that exist for the given entry IDs,
+// keyed by ID.
+func (s *BadgerStorage) GetAnnotations(ids []string) (map[string]Annotation, error) {
+	result := make(map[string]Annotation)
+	err := s.db.View(func(txn *badger.Txn) error {
+		for _, id := range ids {
+			a, err := getAnnotation(txn, id)
+			if err != nil {
+				return err
+			}
+			if a != nil {
+				result[id] = *a
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get annotations: %w", err)
+	}
+	return result, nil
+}
+
+// ListAnnotations returns every stored annotation.
+func (s *BadgerStorage) ListAnnotations() ([]Annotation, error) {
+	annotations := []Annotation{}
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte(metaPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var a Annotation
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &a)
+			}); err != nil {
+				return err
+			}
+			annotations = append(annotations, a)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list annotations: %w", err)
+	}
+	return annotations, nil
+}
+
+// getAnnotation reads the annotation for id; a missing annotation is (nil, nil).
+func getAnnotation(txn *badger.Txn, id string) (*Annotation, error) {
+	item, err := txn.Get(annotationKey(id))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var a Annotation
+	if err := item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &a)
+	}); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// entryExists checks for the entry's raw key and falls back to a key scan
+// for entries stored without a raw line.
+func entryExists(txn *badger.Txn, id string) error {
+	_, err := txn.Get(rawKey(id))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		_, err = findLogKey(txn, id)
+	}
+	return err
+}
+
+// annotationKey returns the meta key holding an entry's annotation.
+func annotationKey(id string) []byte {
+	return []byte(metaPrefix + id)
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAnnotationsAndDeleteEntry(t *testing.T) {
+	s := newBehaviorStorage(t)
+	base := time.Now().UTC().Add(-time.Minute)
+	addEntry(t, s, "a", base, "INFO", nil)
+	addEntry(t, s, "b", base.Add(time.Second), "ERROR", nil)
+
+	if err := s.SetAnnotation(&Annotation{ID: "missing", Pinned: true}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("SetAnnotation() on missing entry error = %v, want ErrNotFound", err)
+	}
+	if err := s.SetAnnotation(&Annotation{ID: "a", Pinned: true, Note: "first failure"}); err != nil {
+		t.Fatalf("SetAnnotation() error = %v", err)
+	}
+	if err := s.SetAnnotation(&Annotation{ID: "b", Note: "leaked token"}); err != nil {
+		t.Fatalf("SetAnnotation() error = %v", err)
+	}
+
+	got, err := s.GetAnnotation("a")
+	if err != nil || !got.Pinned || got.Note != "first failure" || got.UpdatedAt.IsZero() {
+		t.Fatalf("GetAnnotation() = %+v, %v", got, err)
+	}
+	byID, err := s.GetAnnotations([]string{"a", "b", "none"})
+	if err != nil || len(byID) != 2 || byID["b"].Note != "leaked token" {
+		t.Fatalf("GetAnnotations() = %+v, %v", byID, err)
+	}
+
+	// Clearing both pin and note removes the annotation.
+	if err := s.SetAnnotation(&Annotation{ID: "a"}); err != nil {
+		t.Fatalf("SetAnnotation() clear error = %v", err)
+	}
+	if _, err := s.GetAnnotation("a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetAnnotation() after clear error = %v, want ErrNotFound", err)
+	}
+
+	// Deleting an entry removes its raw line and annotation too.
+	if err := s.DeleteEntry("b"); err != nil {
+		t.Fatalf("DeleteEntry() error = %v", err)
+	}
+	if err := s.DeleteEntry("b"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("DeleteEntry() again error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetRaw("b"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetRaw() after delete error = %v, want ErrNotFound", err)
+	}
+	if list, err := s.ListAnnotations(); err != nil || len(list) != 0 {
+		t.Fatalf("ListAnnotations() = %+v, %v; want none", list, err)
+	}
+	if _, total, _ := s.Query(AllFilter{}, 10, 0); total != 1 {
+		t.Fatalf("Query() total = %d, want 1", total)
+	}
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// AuditRecord describes one query run against the API.
+type AuditRecord struct {
+	Time time.Time `json:"time"`
+	// Endpoint is the API that ran the query, e.g. "query" or "live".
+	Endpoint string `json:"endpoint"`
+	Query    string `json:"query"`
+	Start    string `json:"start,omitempty"`
+	End      string `json:"end,omitempty"`
+	// DurationMS is how long the query took, in milliseconds.
+	DurationMS int64 `json:"duration_ms"`
+	Results    int   `json:"results"`
+	// Client is the caller's address; Namespace is its token's namespace.
+	Client    string `json:"client"`
+	Namespace string `json:"namespace,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// RecordAudit stores rec under audit:{timestamp_nano}:{seq}, expiring after
+// retention. Audit records live outside the log keyspace, so log retention
+// and DeleteAll leave them alone.
+func (s *BadgerStorage) RecordAudit(rec AuditRecord, retention time.Duration) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	key := fmt.Appendf(nil, "%s%019d:%d", auditPrefix, rec.Time.UnixNano(), s.auditSeq.Add(1))
+	err = s.db.Update(func(txn *badger.Txn) error {
+		return txn.SetEntry(badger.NewEntry(key, data).WithTTL(retention))
+	})
+	if err != nil {
+		return fmt.Errorf("record audit: %w", err)
+	}
+	return nil
+}
+
+// GetAudit returns up to limit audit records at or after since (zero for
+// all), newest first. limit <= 0 returns every record.
+func (s *BadgerStorage) GetAudit(since time.Time, limit int) ([]AuditRecord, error) {
+	records := []AuditRecord{}
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Reverse = true
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte(auditPrefix)
+		var stop []byte
+		if !since.IsZero() {
+			stop = fmt.Appendf(nil, "%s%019d", auditPrefix, since.UnixNano())
+		}
+		for it.Seek(append(prefix, 0xFF)); it.ValidForPrefix(prefix); it.Next() {
+			if stop != nil && string(it.Item().Key()) < string(stop) {
+				break
+			}
+			var rec AuditRecord
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &rec)
+			}); err != nil {
+				return fmt.Errorf("decode %s: %w", it.Item().Key(), err)
+			}
+			records = append(records, rec)
+			if limit > 0 && len(records) >= limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get audit: %w", err)
+	}
+	return records, nil
+}
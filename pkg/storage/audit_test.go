@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAuditRecords(t *testing.T) {
+	s := newBehaviorStorage(t)
+	base := time.Now().UTC().Add(-time.Hour)
+	for i, q := range []string{"a", "b", "c"} {
+		if err := s.RecordAudit(AuditRecord{Time: base.Add(time.Duration(i) * time.Minute), Endpoint: "query", Query: q}, time.Hour); err != nil {
+			t.Fatalf("RecordAudit(%s) error = %v", q, err)
+		}
+	}
+	// Records of one instant are all kept.
+	if err := s.RecordAudit(AuditRecord{Time: base, Endpoint: "live", Query: "a2"}, time.Hour); err != nil {
+		t.Fatalf("RecordAudit(a2) error = %v", err)
+	}
+
+	queries := func(records []AuditRecord) string {
+		out := ""
+		for _, r := range records {
+			out += r.Query + ","
+		}
+		return out
+	}
+	tests := []struct {
+		name  string
+		since time.Time
+		limit int
+		want  string
+	}{
+		{name: "all", want: "c,b,a2,a,"},
+		{name: "limit", limit: 2, want: "c,b,"},
+		{name: "since", since: base.Add(30 * time.Second), want: "c,b,"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.GetAudit(tt.since, tt.limit)
+			if err != nil {
+				t.Fatalf("GetAudit() error = %v", err)
+			}
+			if queries(got) != tt.want {
+				t.Fatalf("GetAudit() = %s, want %s", queries(got), tt.want)
+			}
+		})
+	}
+
+	// Clearing the logs keeps the audit trail.
+	if _, err := s.DeleteAll(); err != nil {
+		t.Fatalf("DeleteAll() error = %v", err)
+	}
+	if got, err := s.GetAudit(time.Time{}, 0); err != nil || len(got) != 4 {
+		t.Fatalf("GetAudit() after DeleteAll = %d records, %v", len(got), err)
+	}
+}
@@ -1,40 +1,85 @@
 package storage
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/badger/v4/options"
 )
 
 const (
 	logPrefix = "log:"
+	rawPrefix = "raw:"
+	// idPrefix maps an entry ID to its log key, so entries are found by ID
+	// without scanning.
+	idPrefix     = "id:"
+	viewPrefix   = "view:"
+	metaPrefix   = "meta:"
+	invPrefix    = "inv:"
+	schedPrefix  = "sched:"
+	seriesPrefix = "series:"
+	dedupePrefix = "dedup:"
+	queuePrefix  = "queue:"
+	tracePrefix  = "trace:"
+	sourcePrefix = "source:"
+	auditPrefix  = "audit:"
+	// sessionPrefix holds the names given to collect sessions.
+	sessionPrefix = "session:"
+	// quarantinePrefix holds records moved aside by Verify.
+	quarantinePrefix = "quarantine:"
+	// parseFailPrefix holds lines that failed explicit-format parsing.
+	parseFailPrefix = "parsefail:"
+	// statsHistPrefix holds the ring of periodic stats snapshots.
+	statsHistPrefix = "statshist:"
+	// importPrefix holds the checkpoints of unfinished peek import runs.
+	importPrefix = "import:"
 )
 
+// ErrNotFound is returned when a requested entry or record does not exist.
+var ErrNotFound = errors.New("not found")
+
 // BadgerStorage implements log storage with Badger
 type BadgerStorage struct {
 	db              *badger.DB
 	retentionSize   int64 // in bytes
 	retentionDays   int
 	mu              sync.RWMutex
+	retentionMu     sync.Mutex // serializes retention sweeps; never held with mu
 	writeCount      int
 	cleanupInterval int
 	cleanupChan     chan struct{}
 	doneChan        chan struct{}
+	workers         sync.WaitGroup // background GC and cleanup; Close waits for them
+	queryWorkers    int
+	progress        ProgressFunc
+	statsCache      statsCache
+	generation      atomic.Uint64 // advanced by every change to log entries
+	queue           queueState
+	health          healthState
+	disk            diskGuard
+	window          *MaintenanceWindow // nil runs all retention work on writes
+	auditSeq        atomic.Uint64      // disambiguates audit records of one instant
+	parseFailSeq    atomic.Uint64      // disambiguates parse failures of one instant
 }
 
 // CompactionResult describes a compaction run.
 type CompactionResult struct {
-	Passes         int
-	BeforeBytes    int64
-	AfterBytes     int64
-	ReclaimedBytes int64
+	Passes         int   `json:"passes"`
+	BeforeBytes    int64 `json:"before_bytes"`
+	AfterBytes     int64 `json:"after_bytes"`
+	ReclaimedBytes int64 `json:"reclaimed_bytes"`
 }
 
 // Config holds storage configuration
@@ -42,6 +87,23 @@ type Config struct {
 	DBPath        string
 	RetentionSize int64 // in bytes (e.g., 1GB = 1073741824)
 	RetentionDays int
+	QueryWorkers  int // parallel scan workers per query; 0 uses runtime.NumCPU()
+	// MinFreeBytes pauses storing while the filesystem holding the database
+	// has less free space; 0 disables the guard.
+	MinFreeBytes int64
+	// MaintenanceWindow defers size-cap sweeps and full compaction to a
+	// daily window; nil runs them whenever writes trigger retention.
+	MaintenanceWindow *MaintenanceWindow
+	Badger            BadgerTuning
+}
+
+// BadgerTuning holds optional Badger overrides; zero values keep the defaults.
+type BadgerTuning struct {
+	DisableSyncWrites bool
+	ValueLogFileSize  int64  // in bytes
+	MemTableSize      int64  // in bytes
+	Compression       string // none, snappy, zstd; empty keeps Badger's default
+	NumCompactors     int
 }
 
 // NewBadgerStorage creates a new Badger storage instance
@@ -56,18 +118,21 @@ func NewBadgerStorage(cfg Config) (*BadgerStorage, error) {
 
 	// Open Badger database
 	opts := badger.DefaultOptions(dbPath)
-	opts.Logger = nil      // Disable badger logging
-	opts.SyncWrites = true // Ensure writes are synced to disk
+	opts.Logger = nil // Disable badger logging
+	opts, err := applyBadgerTuning(opts, cfg.Badger)
+	if err != nil {
+		return nil, err
+	}
 
 	db, err := badger.Open(opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open badger db: %w", err)
 	}
 
-	// Run value log garbage collection in background
-	go func() {
-		db.RunValueLogGC(0.5)
-	}()
+	queryWorkers := cfg.QueryWorkers
+	if queryWorkers <= 0 {
+		queryWorkers = runtime.NumCPU()
+	}
 
 	s := &BadgerStorage{
 		db:              db,
@@ -76,6 +141,29 @@ func NewBadgerStorage(cfg Config) (*BadgerStorage, error) {
 		cleanupInterval: 1000, // Run cleanup every 1000 writes
 		cleanupChan:     make(chan struct{}, 1),
 		doneChan:        make(chan struct{}),
+		queryWorkers:    queryWorkers,
+		disk:            diskGuard{path: dbPath, min: cfg.MinFreeBytes, free: -1},
+		window:          cfg.MaintenanceWindow,
+	}
+
+	// Run value log garbage collection in background
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		db.RunValueLogGC(0.5)
+	}()
+
+	// Move entries written before hourly buckets into them, so retention
+	// and time-range seeks see the whole keyspace in one layout.
+	if _, err := s.migrateLegacyKeys(); err != nil {
+		return nil, err
+	}
+	// Index entries stored before a secondary index existed.
+	if err := s.buildIndexes(); err != nil {
+		return nil, err
+	}
+	if err := s.buildIDIndex(); err != nil {
+		return nil, err
 	}
 
 	// Run initial cleanup
@@ -84,41 +172,155 @@ func NewBadgerStorage(cfg Config) (*BadgerStorage, error) {
 	}
 
 	// Start background cleanup worker
+	s.workers.Add(1)
 	go s.cleanupWorker()
 
+	if cfg.MinFreeBytes > 0 {
+		s.checkDisk()
+		s.workers.Add(1)
+		go s.diskWorker()
+	}
+	if s.window != nil {
+		s.workers.Add(1)
+		go s.maintenanceWorker()
+	}
+
 	return s, nil
 }
 
-// Store saves a log entry
-func (s *BadgerStorage) Store(entry *LogEntry) error {
-	s.mu.Lock()
-	s.writeCount++
-	shouldCleanup := s.writeCount%s.cleanupInterval == 0
-	s.mu.Unlock()
+// applyBadgerTuning applies t on top of opts. Writes are synced to disk unless
+// explicitly disabled.
+func applyBadgerTuning(opts badger.Options, t BadgerTuning) (badger.Options, error) {
+	opts.SyncWrites = !t.DisableSyncWrites
+	if t.ValueLogFileSize > 0 {
+		opts.ValueLogFileSize = t.ValueLogFileSize
+	}
+	if t.MemTableSize > 0 {
+		opts.MemTableSize = t.MemTableSize
+	}
+	if t.NumCompactors > 0 {
+		opts.NumCompactors = t.NumCompactors
+	}
+
+	switch strings.ToLower(t.Compression) {
+	case "":
+	case "none":
+		opts.Compression = options.None
+	case "snappy":
+		opts.Compression = options.Snappy
+	case "zstd":
+		opts.Compression = options.ZSTD
+	default:
+		return opts, fmt.Errorf("invalid badger compression: %s (use none, snappy, or zstd)", t.Compression)
+	}
 
-	// Generate key: log:{timestamp}:{id}
-	key := fmt.Sprintf("%s%d:%s", logPrefix, entry.Timestamp.UnixNano(), entry.ID)
+	return opts, nil
+}
 
-	// Serialize entry
-	data, err := entry.ToJSON()
+// Store saves a log entry
+func (s *BadgerStorage) Store(entry *LogEntry) error {
+	if s.IngestPaused() {
+		return ErrIngestPaused
+	}
+	writes, err := entryWrites(entry)
 	if err != nil {
-		return fmt.Errorf("failed to serialize entry: %w", err)
+		return err
 	}
 
 	// Store in Badger
 	err = s.db.Update(func(txn *badger.Txn) error {
-		// Store main entry
-		if err := txn.Set([]byte(key), data); err != nil {
-			return err
+		for _, e := range writes {
+			if err := txn.SetEntry(e); err != nil {
+				return err
+			}
 		}
-
 		return nil
 	})
 
 	if err != nil {
+		s.noteWriteResult(err)
 		return fmt.Errorf("failed to store entry: %w", err)
 	}
 
+	s.noteWrites(1)
+	return nil
+}
+
+// StoreBatch saves entries through a single Badger write batch, coalescing
+// the entry and raw keys of every entry instead of committing one small
+// transaction per line.
+func (s *BadgerStorage) StoreBatch(entries []*LogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	if s.IngestPaused() {
+		return ErrIngestPaused
+	}
+
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	for _, entry := range entries {
+		writes, err := entryWrites(entry)
+		if err != nil {
+			return err
+		}
+		for _, e := range writes {
+			if err := wb.SetEntry(e); err != nil {
+				s.noteWriteResult(err)
+				return fmt.Errorf("failed to store batch: %w", err)
+			}
+		}
+	}
+
+	if err := wb.Flush(); err != nil {
+		s.noteWriteResult(err)
+		return fmt.Errorf("failed to store batch: %w", err)
+	}
+
+	s.noteWrites(len(entries))
+	return nil
+}
+
+// entryWrites returns the Badger entries written for a log entry: the main
+// log:{bucket}:{timestamp}:{id} key, the id:{id} key pointing at it and, when
+// present, the raw:{id} sibling and the secondary index keys.
+func entryWrites(entry *LogEntry) ([]*badger.Entry, error) {
+	key := logKey(entry.Timestamp.UnixNano(), entry.ID)
+
+	// Serialize entry without Raw; the raw line lives under a sibling key so
+	// query decode paths only read the structured part.
+	stored := *entry
+	stored.Raw = ""
+	data, err := stored.ToJSON()
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize entry: %w", err)
+	}
+
+	// Store main entry, with the level in the user-meta byte so queries can
+	// pre-filter without decoding the value.
+	writes := []*badger.Entry{
+		badger.NewEntry(key, data).WithMeta(levelMeta(entry.Level)),
+		badger.NewEntry(idKey(entry.ID), key),
+	}
+	if entry.Raw != "" {
+		writes = append(writes, badger.NewEntry(rawKey(entry.ID), []byte(entry.Raw)))
+	}
+	writes = append(writes, indexEntryWrites(entry)...)
+
+	return writes, nil
+}
+
+// noteWrites counts n stored entries and periodically triggers cleanup.
+func (s *BadgerStorage) noteWrites(n int) {
+	s.noteWriteResult(nil)
+	s.generation.Add(1)
+	s.mu.Lock()
+	before := s.writeCount
+	s.writeCount += n
+	shouldCleanup := before/s.cleanupInterval != s.writeCount/s.cleanupInterval
+	s.mu.Unlock()
+
 	// Periodically run cleanup
 	if shouldCleanup {
 		// Use a channel to prevent multiple concurrent cleanups
@@ -129,8 +331,13 @@ func (s *BadgerStorage) Store(entry *LogEntry) error {
 			// Cleanup already in progress, skip
 		}
 	}
+}
 
-	return nil
+// Generation returns a counter that advances whenever log entries are
+// stored, deleted or rewritten. Callers caching query results compare it to
+// tell whether a cached result may be stale.
+func (s *BadgerStorage) Generation() uint64 {
+	return s.generation.Load()
 }
 
 // Query retrieves log entries based on filters
@@ -142,238 +349,979 @@ func (s *BadgerStorage) Query(filter Filter, limit, offset int) ([]*LogEntry, in
 // When tr is non-nil, iteration starts at tr.Start and stops after tr.End,
 // avoiding a full scan of the log keyspace.
 func (s *BadgerStorage) QueryWithTimeRange(filter Filter, tr *TimeRange, limit, offset int) ([]*LogEntry, int, error) {
+	return s.QueryContext(context.Background(), filter, QueryOptions{TimeRange: tr, Limit: limit, Offset: offset})
+}
+
+// QueryOptions controls the range, pagination, and counting of QueryContext.
+type QueryOptions struct {
+	TimeRange *TimeRange
+	Limit     int
+	Offset    int
+	// SkipTotal stops iterating once the page is filled instead of counting
+	// every match. The returned total is then the number of matches seen,
+	// which exceeds Offset+len(entries) only when more matches exist.
+	SkipTotal bool
+}
+
+// QueryContext retrieves log entries matching filter and stops early when ctx
+// is cancelled. Full counts split the key range into time shards that are
+// scanned concurrently so large scans use multiple cores.
+func (s *BadgerStorage) QueryContext(ctx context.Context, filter Filter, opts QueryOptions) ([]*LogEntry, int, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	var entries []*LogEntry
 	total := 0
-	skipped := 0
+	limit, offset := opts.Limit, opts.Offset
 
 	err := s.db.View(func(txn *badger.Txn) error {
+		// A query pinned to one trace or source only reads its entries.
+		if idx, value, ok := indexedQuery(filter); ok {
+			var err error
+			entries, total, err = queryIndex(ctx, txn, idx, value, filter, opts)
+			return err
+		}
+
+		// Without a total the first page is found by a single ordered scan.
+		shards := []keyRange{newKeyRange(opts.TimeRange)}
+		if !opts.SkipTotal {
+			shards = s.queryShards(txn, opts.TimeRange)
+		}
+		// Shards retain every match up to the end of the page, since the
+		// page may fall in any of them. Past maxRetainedMatches they only
+		// count, and the page is read by a second scan of the shard it
+		// starts in, skipping the matches before it.
+		skip := offset
+		scan := shardScan{filter: filter, keep: offset + limit, countAll: !opts.SkipTotal}
+		deep := false
+		switch {
+		case opts.SkipTotal:
+			scan.skip, scan.keep, skip = offset, limit, 0
+		case offset+limit > maxRetainedMatches:
+			scan.keep, deep = 0, true
+		}
+
+		results := make([]shardResult, len(shards))
+		if len(shards) == 1 {
+			results[0] = scan.run(ctx, txn, shards[0])
+		} else {
+			var wg sync.WaitGroup
+			for i, r := range shards {
+				wg.Add(1)
+				go func(i int, r keyRange) {
+					defer wg.Done()
+					results[i] = scan.run(ctx, txn, r)
+				}(i, r)
+			}
+			wg.Wait()
+		}
+
+		// Shards are ordered by time, so concatenating their matches preserves
+		// global key order for pagination.
+		for i, res := range results {
+			if res.err != nil {
+				return res.err
+			}
+			total += res.total
+			if deep {
+				if skip >= res.total || len(entries) >= limit {
+					skip -= min(skip, res.total)
+					continue
+				}
+				page := shardScan{filter: filter, skip: skip, keep: limit - len(entries)}.run(ctx, txn, shards[i])
+				if page.err != nil {
+					return page.err
+				}
+				entries = append(entries, page.entries...)
+				skip = 0
+				continue
+			}
+			for _, entry := range res.entries {
+				if skip > 0 {
+					skip--
+					continue
+				}
+				if len(entries) < limit {
+					entries = append(entries, entry)
+				}
+			}
+		}
+
+		return nil
+	})
+
+	return entries, total, err
+}
+
+// keyRange is an inclusive range of key timestamps; zero bounds are open.
+type keyRange struct {
+	start int64
+	end   int64
+}
+
+// shardResult holds the matches collected from a single keyRange.
+type shardResult struct {
+	entries []*LogEntry
+	total   int
+	err     error
+}
+
+// newKeyRange converts optional time bounds into a keyRange.
+func newKeyRange(tr *TimeRange) keyRange {
+	var r keyRange
+	if tr != nil && !tr.Start.IsZero() {
+		r.start = tr.Start.UnixNano()
+	}
+	if tr != nil && !tr.End.IsZero() {
+		r.end = tr.End.UnixNano()
+	}
+	return r
+}
+
+// queryShards splits the requested time range into one keyRange per query
+// worker. Ranges are only split when both bounds are known, either from tr or
+// from the first and last keys in the database.
+func (s *BadgerStorage) queryShards(txn *badger.Txn, tr *TimeRange) []keyRange {
+	r := newKeyRange(tr)
+
+	workers := s.queryWorkers
+	if workers <= 1 {
+		return []keyRange{r}
+	}
+
+	lo, hi := r.start, r.end
+	first, last, ok := keyTimestampBounds(txn)
+	if !ok {
+		return []keyRange{r}
+	}
+	if lo == 0 || first > lo {
+		lo = first
+	}
+	if hi == 0 || last < hi {
+		hi = last
+	}
+	span := hi - lo
+	if span < int64(workers) {
+		return []keyRange{r}
+	}
+
+	step := span / int64(workers)
+	shards := make([]keyRange, 0, workers)
+	for i := 0; i < workers; i++ {
+		shard := keyRange{start: lo + int64(i)*step, end: lo + int64(i+1)*step - 1}
+		if i == workers-1 {
+			shard.end = hi
+		}
+		shards = append(shards, shard)
+	}
+	return shards
+}
+
+// keyTimestampBounds returns the timestamps of the first and last log keys.
+func keyTimestampBounds(txn *badger.Txn) (first, last int64, ok bool) {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	prefix := []byte(logPrefix)
+
+	it := txn.NewIterator(opts)
+	it.Seek(prefix)
+	if it.ValidForPrefix(prefix) {
+		first, ok = keyTimestamp(it.Item().Key())
+	}
+	it.Close()
+	if !ok {
+		return 0, 0, false
+	}
+
+	opts.Reverse = true
+	rit := txn.NewIterator(opts)
+	defer rit.Close()
+	rit.Seek(append(prefix, 0xFF))
+	if !rit.ValidForPrefix(prefix) {
+		return 0, 0, false
+	}
+	last, ok = keyTimestamp(rit.Item().Key())
+	return first, last, ok
+}
+
+// keyTimestamp extracts the nanosecond timestamp from a
+// log:{bucket}:{timestamp}:{id} key.
+func keyTimestamp(key []byte) (int64, bool) {
+	rest := key[len(logPrefix):]
+	i := bytes.IndexByte(rest, ':')
+	if i < 0 {
+		return 0, false
+	}
+	rest = rest[i+1:]
+	if i := bytes.IndexByte(rest, ':'); i >= 0 {
+		rest = rest[:i]
+	}
+	ts, err := strconv.ParseInt(string(rest), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return ts, true
+}
+
+// metaLevels maps the user-meta byte stored with each entry to its level.
+// Code 0 is reserved for "unknown" so older entries are never skipped.
+var metaLevels = [...]string{1: "", 2: "TRACE", 3: "DEBUG", 4: "INFO", 5: "WARN", 6: "ERROR", 7: "FATAL"}
+
+// levelMeta returns the user-meta byte for level, or 0 if it has no code.
+func levelMeta(level string) byte {
+	for code := 1; code < len(metaLevels); code++ {
+		if metaLevels[code] == level {
+			return byte(code)
+		}
+	}
+	return 0
+}
+
+// itemMeta builds EntryMeta from a log item's key and user-meta byte.
+func itemMeta(item *badger.Item) EntryMeta {
+	var meta EntryMeta
+	if ts, ok := keyTimestamp(item.Key()); ok {
+		meta.Timestamp = time.Unix(0, ts)
+	}
+	if code := int(item.UserMeta()); code != 0 && code < len(metaLevels) {
+		meta.Level = metaLevels[code]
+		meta.LevelKnown = true
+	}
+	return meta
+}
+
+// shardScan describes how a single keyRange is scanned.
+type shardScan struct {
+	filter Filter
+	// skip is how many leading matches are counted without being retained.
+	skip int
+	// keep is how many matches after skip to retain in key order.
+	keep int
+	// countAll keeps counting matches after the first skip+keep; otherwise
+	// the scan stops once it has seen one match beyond them.
+	countAll bool
+}
+
+// maxRetainedMatches bounds how many matches each shard of a counted query
+// holds in memory; deeper pages are read by a second, skipping scan.
+var maxRetainedMatches = 10000
+
+// ctxCheckInterval is how many keys a scan visits between context checks.
+const ctxCheckInterval = 256
+
+// run scans r and collects matches according to the scan settings.
+func (sc shardScan) run(ctx context.Context, txn *badger.Txn, r keyRange) shardResult {
+	var res shardResult
+	filter, keep := sc.filter, sc.keep
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = true
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	prefix := []byte(logPrefix)
+
+	// Seek directly to the start of the requested time range when provided.
+	seekKey := prefix
+	if r.start != 0 {
+		seekKey = logSeekKey(r.start)
+	}
+
+	metaFilter, _ := filter.(MetaFilter)
+
+	visited := 0
+	for it.Seek(seekKey); it.ValidForPrefix(prefix); it.Next() {
+		if !sc.countAll && res.total > sc.skip+keep {
+			break
+		}
+		visited++
+		if visited%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				res.err = err
+				return res
+			}
+		}
+
+		item := it.Item()
+
+		// Early exit when entry exceeds end time.
+		if r.end != 0 {
+			if ts, ok := keyTimestamp(item.Key()); ok && ts > r.end {
+				break
+			}
+		}
+
+		// Skip decoding when key metadata already decides the match, or when
+		// the match is skipped or the page is full and only the total is
+		// still being counted.
+		if metaFilter != nil {
+			if match, certain := metaFilter.MatchMeta(itemMeta(item)); certain {
+				if !match {
+					continue
+				}
+				if res.total < sc.skip || len(res.entries) >= keep {
+					res.total++
+					continue
+				}
+			}
+		}
+
+		err := item.Value(func(val []byte) error {
+			entry, err := FromJSON(val)
+			if err != nil {
+				return nil // Skip invalid entries
+			}
+
+			// Apply filter
+			if !filter.Match(entry) {
+				return nil
+			}
+
+			res.total++
+			if res.total > sc.skip && len(res.entries) < keep {
+				res.entries = append(res.entries, entry)
+			}
+
+			return nil
+		})
+		if err != nil {
+			res.err = err
+			return res
+		}
+	}
+
+	return res
+}
+
+// GetStats returns entry counts, storage breakdown and a size forecast.
+func (s *BadgerStorage) GetStats() (Stats, error) {
+	return s.statsAt(time.Now())
+}
+
+// statsAt computes Stats with the ingest rate measured over the hour before now.
+func (s *BadgerStorage) statsAt(now time.Time) (Stats, error) {
+	// Copy DB pointer under lock, then release so stats scan doesn't block writers.
+	s.mu.RLock()
+	db := s.db
+	s.mu.RUnlock()
+
+	stats := Stats{
+		Levels:             make(map[string]int),
+		RetentionSizeBytes: s.retentionSize,
+	}
+	hourAgo := now.Add(-time.Hour).UnixNano()
+	var liveBytes, entryBytes int64
+	sources, sessions := volumeCounter{}, volumeCounter{}
+
+	// Walk every key once: log keys give counts by level, source and
+	// session and the ingest rate, and every key contributes its live size
+	// for the reclaimable estimate.
+	err := db.View(func(txn *badger.Txn) error {
 		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = true
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		logKeys, rawKeys := []byte(logPrefix), []byte(rawPrefix)
+		countLevel := func(level string) {
+			if level == "" {
+				level = "Unknown"
+			}
+			stats.Levels[level]++
+		}
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			size := item.EstimatedSize()
+			liveBytes += size
+
+			if bytes.HasPrefix(item.Key(), rawKeys) {
+				entryBytes += size
+				continue
+			}
+			if !bytes.HasPrefix(item.Key(), logKeys) {
+				continue
+			}
+
+			stats.TotalLogs++
+			entryBytes += size
+			if ts, ok := keyTimestamp(item.Key()); ok && ts >= hourAgo && ts <= now.UnixNano() {
+				stats.IngestRatePerHour++
+			}
+			meta := itemMeta(item)
+			if meta.LevelKnown {
+				countLevel(meta.Level)
+			}
+			err := item.Value(func(val []byte) error {
+				if !meta.LevelKnown {
+					entry, err := FromJSON(val)
+					if err != nil {
+						return nil // skip invalid entries
+					}
+					countLevel(entry.Level)
+				}
+				origin, err := decodeOrigin(val)
+				if err != nil {
+					return nil
+				}
+				// The raw line is stored under a sibling key.
+				if raw, err := txn.Get(rawKey(origin.ID)); err == nil {
+					size += raw.EstimatedSize()
+				}
+				sources.add(origin.sourceName(), size)
+				if origin.Session != "" {
+					sessions.add(origin.Session, size)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return stats, err
+	}
+	stats.Sources, stats.Sessions = sources.top(), sessions.top()
+
+	// Get DB size
+	lsm, vlog := s.db.Size()
+	stats.DBSizeMB = float64(lsm+vlog) / (1024 * 1024)
+	stats.LSMSizeBytes, stats.VlogSizeBytes = lsm, vlog
+	if onDisk := lsm + vlog; onDisk > liveBytes {
+		stats.ReclaimableBytes = onDisk - liveBytes
+	}
+	if stats.TotalLogs > 0 {
+		stats.AvgEntryBytes = float64(entryBytes) / float64(stats.TotalLogs)
+	}
+	stats.DaysUntilFull = daysUntilFull(s.retentionSize, lsm+vlog, stats.IngestRatePerHour, stats.AvgEntryBytes)
+
+	return stats, nil
+}
+
+// daysUntilFull projects when size reaches the retention cap at the given
+// hourly entry rate. It returns nil when there is no cap or no ingest.
+func daysUntilFull(capBytes, size int64, perHour int, avgEntryBytes float64) *float64 {
+	if capBytes <= 0 || perHour == 0 || avgEntryBytes == 0 {
+		return nil
+	}
+	days := 0.0
+	if remaining := capBytes - size; remaining > 0 {
+		days = float64(remaining) / (float64(perHour) * avgEntryBytes * 24)
+	}
+	return &days
+}
+
+// retentionBatchSize bounds how many keys a retention sweep deletes per
+// transaction before yielding to writers and queries.
+const retentionBatchSize = 1000
+
+// enforceRetention removes old entries based on retention policy. It works in
+// small batches without holding s.mu, so Store and queries keep running while
+// a sweep is in progress. With a maintenance window, only the age limit is
+// enforced here; the window runs the rest.
+func (s *BadgerStorage) enforceRetention() error {
+	return s.sweepRetention(s.retentionPolicy, s.window != nil)
+}
+
+// retentionPolicy returns the configured size cap and age limit.
+func (s *BadgerStorage) retentionPolicy() (int64, int) {
+	return s.retentionSize, s.retentionDays
+}
+
+// EnforceRetention runs a retention sweep now under the given size cap
+// (bytes) and age limit (days) instead of the configured ones. Zero disables
+// either limit.
+func (s *BadgerStorage) EnforceRetention(sizeBytes int64, days int) error {
+	return s.sweepRetention(func() (int64, int) { return sizeBytes, days }, false)
+}
+
+// sweepRetention deletes what the policy returned by policy, read under
+// retentionMu, has due now. A light sweep only deletes entries past the age
+// limit, key by key, leaving the size cap, dropping whole hours and index
+// pruning to a maintenance run.
+func (s *BadgerStorage) sweepRetention(policy func() (sizeBytes int64, days int), light bool) (err error) {
+	defer s.invalidateStats()
+	defer func() { s.noteSweep(err) }()
+	s.retentionMu.Lock()
+	defer s.retentionMu.Unlock()
+
+	if light {
+		if _, days := policy(); days > 0 {
+			return s.deleteOldestInBatches(cutoffStop(time.Now().AddDate(0, 0, -days)))
+		}
+		return nil
+	}
+
+	// Index keys of deleted entries are pruned once the oldest entry moved.
+	oldest := s.oldestLogTimestamp()
+	defer func() {
+		if err == nil && s.oldestLogTimestamp() != oldest {
+			_, err = s.pruneIndexes()
+		}
+	}()
+
+	targetBytes, cutoff := s.retentionTarget(policy())
+	switch {
+	case targetBytes > 0:
+		return s.deleteOldestEntries(targetBytes)
+	case !cutoff.IsZero():
+		return s.deleteEntriesOlderThan(cutoff)
+	}
+	return nil
+}
+
+// retentionTarget returns what a policy has due now. Over the size cap, it is
+// targetBytes of the oldest entries: the excess plus 20% so the next writes
+// don't trigger another sweep right away. Otherwise, with an age limit, it is
+// the entries before cutoff. Both are zero when nothing is due.
+func (s *BadgerStorage) retentionTarget(sizeBytes int64, days int) (targetBytes int, cutoff time.Time) {
+	lsm, vlog := s.db.Size()
+	if currentSize := lsm + vlog; sizeBytes > 0 && currentSize > sizeBytes {
+		return int(float64(currentSize-sizeBytes) * 1.2), time.Time{}
+	}
+	if days > 0 {
+		return 0, time.Now().AddDate(0, 0, -days)
+	}
+	return 0, time.Time{}
+}
+
+// oldestLogTimestamp returns the timestamp of the first log key, or 0 when
+// there are no entries.
+func (s *BadgerStorage) oldestLogTimestamp() int64 {
+	var oldest int64
+	s.db.View(func(txn *badger.Txn) error {
+		oldest, _, _ = keyTimestampBounds(txn)
+		return nil
+	})
+	return oldest
+}
+
+// deleteOldestEntries deletes approximately targetBytes worth of oldest entries
+func (s *BadgerStorage) deleteOldestEntries(targetBytes int) error {
+	return s.deleteOldestInBatches(bytesStop(targetBytes))
+}
+
+// bytesStop returns a stop predicate for deleteOldestInBatches that keeps
+// every key after the first targetBytes worth.
+func bytesStop(targetBytes int) func(item *badger.Item) bool {
+	deletedSize := 0
+	return func(item *badger.Item) bool {
+		if deletedSize >= targetBytes {
+			return true
+		}
+		deletedSize += int(item.EstimatedSize())
+		return false
+	}
+}
+
+// deleteEntriesOlderThan deletes entries older than the cutoff time. Whole
+// expired hours are dropped by prefix; only the cutoff's own hour is walked
+// key by key.
+func (s *BadgerStorage) deleteEntriesOlderThan(cutoff time.Time) error {
+	if _, err := s.dropBucketsBefore(cutoff); err != nil {
+		return err
+	}
+	return s.deleteOldestInBatches(cutoffStop(cutoff))
+}
+
+// cutoffStop returns a stop predicate for deleteOldestInBatches that keeps
+// every key from cutoff on.
+func cutoffStop(cutoff time.Time) func(item *badger.Item) bool {
+	cutoffNano := cutoff.UnixNano()
+	return func(item *badger.Item) bool {
+		ts, ok := keyTimestamp(item.Key())
+		return ok && ts >= cutoffNano
+	}
+}
+
+// deleteOldestInBatches deletes log keys in ascending order until stop
+// returns true for a key or the keyspace is exhausted. Each batch is collected
+// and deleted in its own transactions, yielding in between.
+func (s *BadgerStorage) deleteOldestInBatches(stop func(item *badger.Item) bool) error {
+	for {
+		var keys [][]byte
+		done := false
+
+		err := s.db.View(func(txn *badger.Txn) error {
+			opts := badger.DefaultIteratorOptions
+			opts.PrefetchValues = false
+			it := txn.NewIterator(opts)
+			defer it.Close()
+
+			prefix := []byte(logPrefix)
+			for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+				if stop(it.Item()) {
+					done = true
+					return nil
+				}
+				keys = append(keys, it.Item().KeyCopy(nil))
+				if len(keys) >= retentionBatchSize {
+					return nil
+				}
+			}
+			done = true
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := s.deleteLogKeys(keys); err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+
+		// Leave the rest for the next sweep when the DB is closing.
+		select {
+		case <-s.doneChan:
+			return nil
+		default:
+		}
+		runtime.Gosched()
+	}
+}
+
+// deleteLogKeys deletes the given log keys together with their raw and
+// annotation sibling keys.
+func (s *BadgerStorage) deleteLogKeys(keys [][]byte) error {
+	d := s.newLogDeleter(0, nil)
+	defer d.cancel()
+	for _, key := range keys {
+		if err := d.delete(key); err != nil {
+			return err
+		}
+	}
+	return d.flush()
+}
+
+// ProgressFunc receives the running amount of work done by a long operation.
+type ProgressFunc func(done int)
+
+// SetProgress registers fn to be called periodically during long
+// maintenance operations: with the entries deleted so far by DeleteByLevel
+// and DeleteOlderThan, the entries visited so far by Reparse, and the GC
+// passes completed so far by CompactDatabaseFully. Pass nil to stop.
+func (s *BadgerStorage) SetProgress(fn ProgressFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.progress = fn
+}
+
+// progressFunc returns the registered ProgressFunc, or nil. Callers must not
+// hold s.mu.
+func (s *BadgerStorage) progressFunc() ProgressFunc {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.progress
+}
+
+// logDeleter deletes log keys and their raw, ID and annotation siblings through
+// a write batch, which commits in transactions that stay within Badger's
+// size limits however many keys are deleted.
+type logDeleter struct {
+	wb       *badger.WriteBatch
+	deleted  int
+	progress ProgressFunc
+}
+
+// newLogDeleter starts a deleter whose progress count begins at deleted.
+func (s *BadgerStorage) newLogDeleter(deleted int, progress ProgressFunc) *logDeleter {
+	return &logDeleter{wb: s.db.NewWriteBatch(), deleted: deleted, progress: progress}
+}
+
+// delete queues a log key and its siblings for deletion. The key is copied.
+func (d *logDeleter) delete(key []byte) error {
+	if err := d.wb.Delete(bytes.Clone(key)); err != nil {
+		return fmt.Errorf("failed to delete entries: %w", err)
+	}
+	if id, ok := keyID(key); ok {
+		if err := d.wb.Delete(rawKey(id)); err != nil {
+			return fmt.Errorf("failed to delete entries: %w", err)
+		}
+		if err := d.wb.Delete(idKey(id)); err != nil {
+			return fmt.Errorf("failed to delete entries: %w", err)
+		}
+		if err := d.wb.Delete(annotationKey(id)); err != nil {
+			return fmt.Errorf("failed to delete entries: %w", err)
+		}
+	}
+	d.deleted++
+	if d.progress != nil && d.deleted%retentionBatchSize == 0 {
+		d.progress(d.deleted)
+	}
+	return nil
+}
+
+// flush commits every queued delete and reports the final count.
+func (d *logDeleter) flush() error {
+	if err := d.wb.Flush(); err != nil {
+		return fmt.Errorf("failed to delete entries: %w", err)
+	}
+	if d.progress != nil {
+		d.progress(d.deleted)
+	}
+	return nil
+}
+
+// cancel discards queued deletes that were not flushed.
+func (d *logDeleter) cancel() {
+	d.wb.Cancel()
+}
+
+// GetRaw returns the original log line stored for the entry with the given ID.
+func (s *BadgerStorage) GetRaw(id string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var raw string
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(rawKey(id))
+		if err == nil {
+			return item.Value(func(val []byte) error {
+				raw = string(val)
+				return nil
+			})
+		}
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		// Entries written before Raw was split out keep it inline.
+		entry, err := findByID(txn, id)
+		if err != nil {
+			return err
+		}
+		raw = entry.Raw
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("get raw %s: %w", id, err)
+	}
+
+	return raw, nil
+}
+
+// ScanRaw calls fn with the original line of every entry matching filter
+// within tr, oldest first. Entries stored without one fall back to their
+// message. It stops at the first error from fn or once ctx is done.
+func (s *BadgerStorage) ScanRaw(ctx context.Context, filter Filter, tr *TimeRange, fn func(entry *LogEntry, raw string) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r := newKeyRange(tr)
+	return s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
 		it := txn.NewIterator(opts)
 		defer it.Close()
 
 		prefix := []byte(logPrefix)
-
-		// Seek directly to the start of the requested time range when provided.
-		var seekKey []byte
-		if tr != nil && !tr.Start.IsZero() {
-			seekKey = []byte(fmt.Sprintf("%s%d:", logPrefix, tr.Start.UnixNano()))
-		} else {
-			seekKey = prefix
-		}
-
-		endNano := int64(0)
-		if tr != nil && !tr.End.IsZero() {
-			endNano = tr.End.UnixNano()
+		seekKey := prefix
+		if r.start != 0 {
+			seekKey = logSeekKey(r.start)
 		}
+		metaFilter, _ := filter.(MetaFilter)
 
+		visited := 0
 		for it.Seek(seekKey); it.ValidForPrefix(prefix); it.Next() {
-			// Early exit when entry exceeds end time.
-			if endNano > 0 {
-				key := string(it.Item().Key())
-				parts := strings.SplitN(key[len(logPrefix):], ":", 2)
-				if len(parts) >= 1 {
-					var ts int64
-					if _, err := fmt.Sscanf(parts[0], "%d", &ts); err == nil && ts > endNano {
-						break
-					}
+			visited++
+			if visited%ctxCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
 				}
 			}
 
 			item := it.Item()
-			err := item.Value(func(val []byte) error {
-				entry, err := FromJSON(val)
-				if err != nil {
-					return nil // Skip invalid entries
+			if r.end != 0 {
+				if ts, ok := keyTimestamp(item.Key()); ok && ts > r.end {
+					break
 				}
-
-				// Apply filter
-				if !filter.Match(entry) {
-					return nil
+			}
+			if metaFilter != nil {
+				if match, certain := metaFilter.MatchMeta(itemMeta(item)); certain && !match {
+					continue
 				}
+			}
 
-				total++
+			var entry *LogEntry
+			if err := item.Value(func(val []byte) error {
+				var err error
+				entry, err = FromJSON(val)
+				return err
+			}); err != nil {
+				continue // Skip invalid entries
+			}
+			if !filter.Match(entry) {
+				continue
+			}
 
-				// Handle pagination
-				if skipped < offset {
-					skipped++
+			raw := entry.Raw
+			if rawItem, err := txn.Get(rawKey(entry.ID)); err == nil {
+				if err := rawItem.Value(func(val []byte) error {
+					raw = string(val)
 					return nil
+				}); err != nil {
+					return err
 				}
-
-				if len(entries) < limit {
-					entries = append(entries, entry)
-				}
-
-				return nil
-			})
-			if err != nil {
+			} else if !errors.Is(err, badger.ErrKeyNotFound) {
+				return err
+			}
+			if raw == "" {
+				raw = entry.Message
+			}
+			if err := fn(entry, raw); err != nil {
 				return err
 			}
 		}
-
 		return nil
 	})
-
-	return entries, total, err
 }
-func (s *BadgerStorage) GetStats() (Stats, error) {
-	// Copy DB pointer under lock, then release so stats scan doesn't block writers.
-	s.mu.RLock()
-	db := s.db
-	s.mu.RUnlock()
-
-	stats := Stats{
-		Levels: make(map[string]int),
-	}
 
-	// Count total logs and by level from primary log entries
-	err := db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = true
-		it := txn.NewIterator(opts)
-		defer it.Close()
+// GetEntry returns the entry with the given ID, including its original
+// line, or ErrNotFound.
+func (s *BadgerStorage) GetEntry(id string) (*LogEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-		prefix := []byte(logPrefix)
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			stats.TotalLogs++
-			err := it.Item().Value(func(val []byte) error {
-				entry, err := FromJSON(val)
-				if err != nil {
-					return nil // skip invalid entries
-				}
-				level := entry.Level
-				if level == "" {
-					level = "Unknown"
-				}
-				stats.Levels[level]++
-				return nil
-			})
-			if err != nil {
-				return err
-			}
+	var entry *LogEntry
+	err := s.db.View(func(txn *badger.Txn) error {
+		var err error
+		entry, err = findByID(txn, id)
+		if err != nil {
+			return err
 		}
 
-		return nil
+		item, err := txn.Get(rawKey(id))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil // raw kept inline or never stored
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			entry.Raw = string(val)
+			return nil
+		})
 	})
-
 	if err != nil {
-		return stats, err
+		return nil, fmt.Errorf("get entry %s: %w", id, err)
 	}
-
-	// Get DB size
-	lsm, vlog := s.db.Size()
-	stats.DBSizeMB = float64(lsm+vlog) / (1024 * 1024)
-
-	return stats, nil
+	return entry, nil
 }
 
-// enforceRetention removes old entries based on retention policy
-func (s *BadgerStorage) enforceRetention() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	// Check size-based retention
-	lsm, vlog := s.db.Size()
-	currentSize := lsm + vlog
-
-	if s.retentionSize > 0 && currentSize > s.retentionSize {
-		// Delete oldest entries
-		return s.deleteOldestEntries(int(float64(currentSize-s.retentionSize) * 1.2)) // Delete 20% more to have buffer
+// findByID scans log keys for the entry with the given ID.
+func findByID(txn *badger.Txn, id string) (*LogEntry, error) {
+	key, err := findLogKey(txn, id)
+	if err != nil {
+		return nil, err
 	}
-
-	// Check time-based retention
-	if s.retentionDays > 0 {
-		cutoff := time.Now().AddDate(0, 0, -s.retentionDays)
-		return s.deleteEntriesOlderThan(cutoff)
+	item, err := txn.Get(key)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	var entry *LogEntry
+	err = item.Value(func(val []byte) error {
+		entry, err = FromJSON(val)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
 }
 
-// deleteOldestEntries deletes approximately targetBytes worth of oldest entries
-func (s *BadgerStorage) deleteOldestEntries(targetBytes int) error {
-	var keysToDelete [][]byte
-	deletedSize := 0
-
-	err := s.db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = true
-		it := txn.NewIterator(opts)
-		defer it.Close()
-
-		prefix := []byte(logPrefix)
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			item := it.Item()
-			keysToDelete = append(keysToDelete, item.KeyCopy(nil))
-			deletedSize += int(item.EstimatedSize())
-
-			if deletedSize >= targetBytes {
-				break
-			}
+// findLogKey returns the log key holding the entry with the given ID, read
+// from its id:{id} key. Until buildIDIndex has indexed the entries of an
+// older database, they are found by scanning the log keys instead.
+func findLogKey(txn *badger.Txn, id string) ([]byte, error) {
+	item, err := txn.Get(idKey(id))
+	switch {
+	case err == nil:
+		key, err := item.ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		// A stale ID key outlives an entry that was moved or dropped
+		// without it.
+		if _, err := txn.Get(key); err == nil {
+			return key, nil
+		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+			return nil, err
 		}
+		return nil, ErrNotFound
+	case !errors.Is(err, badger.ErrKeyNotFound):
+		return nil, err
+	}
+	if _, err := txn.Get([]byte(idIndexMarker)); err == nil {
+		return nil, ErrNotFound
+	} else if !errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, err
+	}
 
-		return nil
-	})
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
 
-	if err != nil {
-		return err
+	prefix := []byte(logPrefix)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		if keyID, ok := keyID(it.Item().Key()); ok && keyID == id {
+			return it.Item().KeyCopy(nil), nil
+		}
 	}
 
-	// Delete keys in batches
-	return s.db.Update(func(txn *badger.Txn) error {
-		for _, key := range keysToDelete {
-			if err := txn.Delete(key); err != nil {
-				return err
-			}
-		}
-		return nil
-	})
+	return nil, ErrNotFound
 }
 
-// deleteEntriesOlderThan deletes entries older than the cutoff time
-func (s *BadgerStorage) deleteEntriesOlderThan(cutoff time.Time) error {
-	cutoffNano := cutoff.UnixNano()
-	var keysToDelete [][]byte
+// DeleteEntry deletes the entry with the given ID together with its raw
+// line and annotation, or returns ErrNotFound.
+func (s *BadgerStorage) DeleteEntry(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
+	var key []byte
 	err := s.db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = false
-		it := txn.NewIterator(opts)
-		defer it.Close()
-
-		prefix := []byte(logPrefix)
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			key := string(it.Item().Key())
-			// Extract timestamp from key: log:{timestamp}:{id}
-			parts := strings.SplitN(key[len(logPrefix):], ":", 2)
-			if len(parts) >= 1 {
-				var ts int64
-				fmt.Sscanf(parts[0], "%d", &ts)
-				if ts < cutoffNano {
-					keysToDelete = append(keysToDelete, it.Item().KeyCopy(nil))
-				}
-			}
-		}
-
-		return nil
+		var err error
+		key, err = findLogKey(txn, id)
+		return err
 	})
-
+	if err == nil {
+		err = s.deleteLogKeys([][]byte{key})
+	}
 	if err != nil {
-		return err
+		return fmt.Errorf("delete entry %s: %w", id, err)
 	}
+	s.invalidateStats()
+	return nil
+}
 
-	// Delete keys in batches
-	return s.db.Update(func(txn *badger.Txn) error {
-		for _, key := range keysToDelete {
-			if err := txn.Delete(key); err != nil {
-				return err
-			}
+// rawKey returns the sibling key holding the raw line for an entry ID.
+func rawKey(id string) []byte {
+	return []byte(rawPrefix + id)
+}
+
+// idKey returns the key holding the log key of an entry ID.
+func idKey(id string) []byte {
+	return []byte(idPrefix + id)
+}
+
+// keyID extracts the entry ID from a log:{bucket}:{timestamp}:{id} key.
+func keyID(key []byte) (string, bool) {
+	rest := key[len(logPrefix):]
+	for range 2 {
+		i := bytes.IndexByte(rest, ':')
+		if i < 0 {
+			return "", false
 		}
-		return nil
-	})
+		rest = rest[i+1:]
+	}
+	return string(rest), true
 }
 
 // GetOldestNewest returns the oldest and newest timestamps in the database
@@ -432,56 +1380,49 @@ func (s *BadgerStorage) GetOldestNewest() (oldest, newest time.Time, err error)
 	return oldest, newest, err
 }
 
-// DeleteAll deletes all log entries from the database
+// DeleteAll deletes all log entries from the database. Entries, raw lines,
+// ID keys, annotations and dedupe hashes are dropped by prefix rather than deleted key
+// by key, so clearing a large database takes about as long as counting it and
+// never builds a transaction per key. Views, investigations and scheduled
+// queries are kept.
 func (s *BadgerStorage) DeleteAll() (int, error) {
+	defer s.invalidateStats()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	count := 0
-	var keysToDelete [][]byte
-
-	// Collect all log keys
 	err := s.db.View(func(txn *badger.Txn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchValues = false
 		it := txn.NewIterator(opts)
 		defer it.Close()
 
-		logPrefixBytes := []byte(logPrefix)
-		for it.Seek(logPrefixBytes); it.ValidForPrefix(logPrefixBytes); it.Next() {
-			keysToDelete = append(keysToDelete, it.Item().KeyCopy(nil))
+		prefix := []byte(logPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
 			count++
 		}
-
 		return nil
 	})
-
 	if err != nil {
 		return 0, err
 	}
 
-	// Delete all keys in batches
-	err = s.db.Update(func(txn *badger.Txn) error {
-		for _, key := range keysToDelete {
-			if err := txn.Delete(key); err != nil {
-				return err
-			}
-		}
-		return nil
-	})
-
-	return count, err
+	if err := s.db.DropPrefix([]byte(logPrefix), []byte(rawPrefix), []byte(idPrefix), []byte(metaPrefix), []byte(dedupePrefix), []byte(tracePrefix), []byte(sourcePrefix), []byte(sessionPrefix), []byte(importPrefix)); err != nil {
+		return 0, fmt.Errorf("failed to drop log entries: %w", err)
+	}
+	return count, nil
 }
 
 // DeleteByLevel deletes all log entries with the specified level
 func (s *BadgerStorage) DeleteByLevel(level string) (int, error) {
+	defer s.invalidateStats()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	count := 0
-	var keysToDelete [][]byte
+	d := s.newLogDeleter(0, s.progress)
+	defer d.cancel()
 
-	// Collect keys for entries with the specified level
+	// Queue deletes while scanning; the write batch commits them as it goes.
 	err := s.db.View(func(txn *badger.Txn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchValues = true
@@ -491,22 +1432,23 @@ func (s *BadgerStorage) DeleteByLevel(level string) (int, error) {
 		prefix := []byte(logPrefix)
 		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
 			item := it.Item()
+			var match bool
 			err := item.Value(func(val []byte) error {
 				entry, err := FromJSON(val)
 				if err != nil {
 					return nil // Skip invalid entries
 				}
-
-				if entry.Level == level {
-					keysToDelete = append(keysToDelete, item.KeyCopy(nil))
-					count++
-				}
-
+				match = entry.Level == level
 				return nil
 			})
 			if err != nil {
 				return err
 			}
+			if match {
+				if err := d.delete(item.Key()); err != nil {
+					return err
+				}
+			}
 		}
 
 		return nil
@@ -516,46 +1458,48 @@ func (s *BadgerStorage) DeleteByLevel(level string) (int, error) {
 		return 0, err
 	}
 
-	// Delete all keys
-	err = s.db.Update(func(txn *badger.Txn) error {
-		for _, key := range keysToDelete {
-			if err := txn.Delete(key); err != nil {
-				return err
-			}
-		}
-		return nil
-	})
-
-	return count, err
+	if err := d.flush(); err != nil {
+		return 0, err
+	}
+	return d.deleted, nil
 }
 
-// DeleteOlderThan deletes all log entries older than the cutoff time
+// DeleteOlderThan deletes all log entries older than the cutoff time. Hours
+// that ended before the cutoff are dropped as whole buckets.
 func (s *BadgerStorage) DeleteOlderThan(cutoff time.Time) (int, error) {
+	defer s.invalidateStats()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	count, err := s.dropBucketsBefore(cutoff)
+	if err != nil {
+		return count, err
+	}
+	if s.progress != nil && count > 0 {
+		s.progress(count)
+	}
+
 	cutoffNano := cutoff.UnixNano()
-	var keysToDelete [][]byte
-	count := 0
+	d := s.newLogDeleter(count, s.progress)
+	defer d.cancel()
 
-	err := s.db.View(func(txn *badger.Txn) error {
+	err = s.db.View(func(txn *badger.Txn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchValues = false
 		it := txn.NewIterator(opts)
 		defer it.Close()
 
 		prefix := []byte(logPrefix)
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			key := string(it.Item().Key())
-			// Extract timestamp from key: log:{timestamp}:{id}
-			parts := strings.SplitN(key[len(logPrefix):], ":", 2)
-			if len(parts) >= 1 {
-				var ts int64
-				fmt.Sscanf(parts[0], "%d", &ts)
-				if ts < cutoffNano {
-					keysToDelete = append(keysToDelete, it.Item().KeyCopy(nil))
-					count++
-				}
+		for it.Seek(logBucketPrefix(cutoffNano)); it.ValidForPrefix(prefix); it.Next() {
+			ts, ok := keyTimestamp(it.Item().Key())
+			if !ok {
+				continue
+			}
+			if ts >= cutoffNano {
+				break
+			}
+			if err := d.delete(it.Item().Key()); err != nil {
+				return err
 			}
 		}
 
@@ -563,20 +1507,16 @@ func (s *BadgerStorage) DeleteOlderThan(cutoff time.Time) (int, error) {
 	})
 
 	if err != nil {
-		return 0, err
+		return count, err
 	}
 
-	// Delete keys in batches
-	err = s.db.Update(func(txn *badger.Txn) error {
-		for _, key := range keysToDelete {
-			if err := txn.Delete(key); err != nil {
-				return err
-			}
-		}
-		return nil
-	})
-
-	return count, err
+	if err := d.flush(); err != nil {
+		return count, err
+	}
+	if _, err := s.pruneIndexes(); err != nil {
+		return d.deleted, err
+	}
+	return d.deleted, nil
 }
 
 // CompactDatabase runs garbage collection to reclaim disk space
@@ -597,6 +1537,9 @@ func (s *BadgerStorage) CompactDatabaseFully() (CompactionResult, error) {
 		err := s.db.RunValueLogGC(0.5)
 		if err == nil {
 			res.Passes++
+			if s.progress != nil {
+				s.progress(res.Passes)
+			}
 			continue
 		}
 		if errors.Is(err, badger.ErrNoRewrite) {
@@ -624,9 +1567,12 @@ func (s *BadgerStorage) GetDBPath() string {
 	return s.db.Opts().Dir
 }
 
-// Close closes the database
+// Close stops the background workers, waits for a running retention sweep
+// or GC pass to finish, and then closes the database, so no worker writes to
+// a closed DB.
 func (s *BadgerStorage) Close() error {
 	close(s.doneChan)
+	s.workers.Wait()
 	return s.db.Close()
 }
 
@@ -637,6 +1583,7 @@ func (s *BadgerStorage) Sync() error {
 
 // cleanupWorker runs in the background and handles cleanup requests
 func (s *BadgerStorage) cleanupWorker() {
+	defer s.workers.Done()
 	for {
 		select {
 		case <-s.cleanupChan:
@@ -652,6 +1599,26 @@ type Stats struct {
 	TotalLogs int            `json:"total_logs"`
 	DBSizeMB  float64        `json:"db_size_mb"`
 	Levels    map[string]int `json:"levels"`
+
+	// On-disk breakdown as reported by Badger.
+	LSMSizeBytes  int64 `json:"lsm_size_bytes"`
+	VlogSizeBytes int64 `json:"vlog_size_bytes"`
+	// ReclaimableBytes estimates on-disk bytes not backing live keys (deleted
+	// or overwritten data awaiting compaction and value log GC).
+	ReclaimableBytes int64 `json:"reclaimable_bytes"`
+	// AvgEntryBytes is the average stored size of an entry, raw line included.
+	AvgEntryBytes float64 `json:"avg_entry_bytes"`
+	// IngestRatePerHour counts entries timestamped within the last hour.
+	IngestRatePerHour  int   `json:"ingest_rate_per_hour"`
+	RetentionSizeBytes int64 `json:"retention_size_bytes"`
+	// DaysUntilFull projects when the retention size cap is reached at the
+	// current ingest rate; nil without a cap or recent ingest.
+	DaysUntilFull *float64 `json:"days_until_full,omitempty"`
+	// Sources and Sessions break the stored volume down by entry source
+	// ("" for entries without one) and collect session, largest first and
+	// at most maxVolumeGroups of each.
+	Sources  []VolumeStats `json:"sources"`
+	Sessions []VolumeStats `json:"sessions"`
 }
 
 // Filter represents a query filter
@@ -677,6 +1644,10 @@ func (f AllFilter) Match(entry *LogEntry) bool {
 	return true
 }
 
+func (f AllFilter) MatchMeta(meta EntryMeta) (bool, bool) {
+	return true, true
+}
+
 // LevelFilter matches entries by level
 type LevelFilter struct {
 	Level string
@@ -686,6 +1657,10 @@ func (f LevelFilter) Match(entry *LogEntry) bool {
 	return entry.Level == f.Level
 }
 
+func (f LevelFilter) MatchMeta(meta EntryMeta) (bool, bool) {
+	return meta.Level == f.Level, meta.LevelKnown
+}
+
 // Scan iterates over all log entries
 func (s *BadgerStorage) Scan(callback func(*LogEntry) error) error {
 	return s.db.View(func(txn *badger.Txn) error {
@@ -715,72 +1690,164 @@ func (s *BadgerStorage) Scan(callback func(*LogEntry) error) error {
 // GetFields returns distinct field names and their top values from stored log entries.
 // start and end are optional; zero values mean no bound.
 func (s *BadgerStorage) GetFields(start, end time.Time) ([]FieldInfo, error) {
+	return s.GetFieldsFiltered(start, end, nil)
+}
+
+// GetFieldsFiltered is GetFields restricted to entries matching filter; a nil
+// filter includes every entry.
+func (s *BadgerStorage) GetFieldsFiltered(start, end time.Time, filter Filter) ([]FieldInfo, error) {
+	fields, _, err := s.GetFieldsSampled(context.Background(), filter, &TimeRange{Start: start, End: end}, 0)
+	return fields, err
+}
+
+// GetFieldsSampled is GetFieldsFiltered over tr (nil for all time) that
+// decodes at most sample entries (0 for all), spread evenly across the
+// range. rate is the fraction of entries in the range that were inspected; it
+// is 1 when nothing was skipped, and top values and cardinalities are
+// approximate below that.
+func (s *BadgerStorage) GetFieldsSampled(ctx context.Context, filter Filter, tr *TimeRange, sample int) (fields []FieldInfo, rate float64, err error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	const maxTopValues = 10
 
-	// fieldValues maps field name → value → count
-	fieldValues := make(map[string]map[string]int)
+	// fieldValues maps field name → value statistics
+	fieldValues := make(map[string]*fieldValueStats)
 
 	// Initialize built-in fields so they always appear in the result.
 	for _, b := range []string{"level", "message", "timestamp"} {
-		fieldValues[b] = make(map[string]int)
+		fieldValues[b] = newFieldValueStats()
+	}
+	// Trace context, source and host metadata are listed once seen; entries
+	// stored before promotion count through their Fields. A collector-set source shadows a
+	// "source" field, as it does in queries.
+	addPromoted := func(name, value string) {
+		if value == "" {
+			return
+		}
+		if fieldValues[name] == nil {
+			fieldValues[name] = newFieldValueStats()
+		}
+		fieldValues[name].add(value)
 	}
 
-	err := s.db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = true
-		it := txn.NewIterator(opts)
-		defer it.Close()
+	r := newKeyRange(tr)
+	metaFilter, _ := filter.(MetaFilter)
+	rate = 1
 
+	err = s.db.View(func(txn *badger.Txn) error {
+		// Seek directly to the start of the requested time range when provided.
+		// Keys are "log:{bucket}:{timestamp_nano}:{id}" in ascending order;
+		// nanosecond timestamps since 2001 are always 19 digits, so
+		// lexicographic order matches chronological order.
 		prefix := []byte(logPrefix)
+		seekKey := prefix
+		if r.start != 0 {
+			seekKey = logSeekKey(r.start)
+		}
 
-		// Seek directly to the start of the requested time range when provided.
-		// Keys are "log:{timestamp_nano}:{id}" in ascending order; nanosecond
-		// timestamps since 2001 are always 19 digits, so lexicographic order
-		// matches chronological order.
-		var seekKey []byte
-		if !start.IsZero() {
-			seekKey = []byte(fmt.Sprintf("%s%d:", logPrefix, start.UnixNano()))
-		} else {
-			seekKey = prefix
+		// inRange reports whether the iterator's key is before the end of the
+		// range and not ruled out by its metadata; done is true past the end.
+		inRange := func(item *badger.Item) (ok, done bool) {
+			if r.end != 0 {
+				if ts, ok := keyTimestamp(item.Key()); ok && ts > r.end {
+					return false, true
+				}
+			}
+			if metaFilter != nil {
+				if match, certain := metaFilter.MatchMeta(itemMeta(item)); certain && !match {
+					return false, false
+				}
+			}
+			return true, false
 		}
 
-		endNano := int64(0)
-		if !end.IsZero() {
-			endNano = end.UnixNano()
+		// With a sample size, count the candidate keys first so the sample
+		// can stride across the whole range.
+		stride := 1.0
+		if sample > 0 {
+			opts := badger.DefaultIteratorOptions
+			opts.PrefetchValues = false
+			it := txn.NewIterator(opts)
+			total := 0
+			for it.Seek(seekKey); it.ValidForPrefix(prefix); it.Next() {
+				ok, done := inRange(it.Item())
+				if done {
+					break
+				}
+				if ok {
+					total++
+					if total%ctxCheckInterval == 0 {
+						if err := ctx.Err(); err != nil {
+							it.Close()
+							return err
+						}
+					}
+				}
+			}
+			it.Close()
+			if total > sample {
+				stride = float64(total) / float64(sample)
+				rate = float64(sample) / float64(total)
+			}
 		}
 
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = stride == 1
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		candidates, next := 0, 0.0
 		for it.Seek(seekKey); it.ValidForPrefix(prefix); it.Next() {
-			// Early exit when entry exceeds end time.
-			if endNano > 0 {
-				key := string(it.Item().Key())
-				parts := strings.SplitN(key[len(logPrefix):], ":", 2)
-				if len(parts) >= 1 {
-					var ts int64
-					fmt.Sscanf(parts[0], "%d", &ts)
-					if ts > endNano {
-						break
-					}
+			item := it.Item()
+			ok, done := inRange(item)
+			if done {
+				break
+			}
+			if !ok {
+				continue
+			}
+			candidates++
+			if candidates%ctxCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
 				}
 			}
+			// Inspect the candidate at every whole multiple of stride.
+			if float64(candidates-1) < next {
+				continue
+			}
+			next += stride
 
-			err := it.Item().Value(func(val []byte) error {
+			err := item.Value(func(val []byte) error {
 				entry, err := FromJSON(val)
 				if err != nil {
 					return nil // skip
 				}
+				if filter != nil && !filter.Match(entry) {
+					return nil
+				}
 				// Built-in field values
 				if entry.Level != "" {
-					fieldValues["level"][entry.Level]++
+					fieldValues["level"].add(entry.Level)
+				}
+				addPromoted("trace_id", entry.TraceID)
+				addPromoted("span_id", entry.SpanID)
+				addPromoted("source", entry.Source)
+				if entry.Host != nil {
+					addPromoted("host.name", entry.Host.Name)
+					addPromoted("host.os", entry.Host.OS)
+					addPromoted("host.user", entry.Host.User)
 				}
 				// Dynamic fields
 				for k, v := range entry.Fields {
+					if k == "source" && entry.Source != "" {
+						continue
+					}
 					if fieldValues[k] == nil {
-						fieldValues[k] = make(map[string]int)
+						fieldValues[k] = newFieldValueStats()
 					}
-					fieldValues[k][fmt.Sprintf("%v", v)]++
+					fieldValues[k].add(fmt.Sprintf("%v", v))
 				}
 				return nil
 			})
@@ -791,20 +1858,16 @@ func (s *BadgerStorage) GetFields(start, end time.Time) ([]FieldInfo, error) {
 		return nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("get fields: %w", err)
+		return nil, 0, fmt.Errorf("get fields: %w", err)
 	}
 
 	// Build result slice.
-	result := make([]FieldInfo, 0, len(fieldValues))
-	for name, valCounts := range fieldValues {
-		result = append(result, FieldInfo{
-			Name:      name,
-			Type:      "string",
-			TopValues: topN(valCounts, maxTopValues),
-		})
+	fields = make([]FieldInfo, 0, len(fieldValues))
+	for name, stats := range fieldValues {
+		fields = append(fields, stats.info(name, maxTopValues))
 	}
 
-	return result, nil
+	return fields, rate, nil
 }
 
 // topN returns up to n keys from counts, ordered by descending count.
@@ -1,7 +1,11 @@
 package storage
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -86,6 +90,72 @@ func TestDeletionAndOldestNewest(t *testing.T) {
 	}
 }
 
+func TestDeleteAllDropsEntryKeyspace(t *testing.T) {
+	s := newBehaviorStorage(t)
+	now := time.Now().UTC()
+	addEntry(t, s, "a", now.Add(-time.Minute), "INFO", nil)
+	if _, err := s.StoreUnique(&LogEntry{ID: "b", Timestamp: now, Message: "b", Raw: "b"}, time.Hour); err != nil {
+		t.Fatalf("StoreUnique() error = %v", err)
+	}
+	if err := s.SetAnnotation(&Annotation{ID: "a", Note: "keep?"}); err != nil {
+		t.Fatalf("SetAnnotation() error = %v", err)
+	}
+	if err := s.SaveView(&View{Name: "errors", Query: "level:ERROR"}); err != nil {
+		t.Fatalf("SaveView() error = %v", err)
+	}
+
+	deleted, err := s.DeleteAll()
+	if err != nil || deleted != 2 {
+		t.Fatalf("DeleteAll() = %d, %v, want 2", deleted, err)
+	}
+	if stats, err := s.GetStats(); err != nil || stats.TotalLogs != 0 {
+		t.Fatalf("GetStats() after DeleteAll = %+v, %v", stats, err)
+	}
+	if _, err := s.GetAnnotation("a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetAnnotation() error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetView("errors"); err != nil {
+		t.Fatalf("GetView() error = %v, want view kept", err)
+	}
+
+	// The dedupe hash went with its entry, so the same line is stored again.
+	stored, err := s.StoreUnique(&LogEntry{ID: "c", Timestamp: now, Message: "b", Raw: "b"}, time.Hour)
+	if err != nil || !stored {
+		t.Fatalf("StoreUnique() after DeleteAll = %v, %v, want stored", stored, err)
+	}
+}
+
+func TestDeleteByLevelReportsProgress(t *testing.T) {
+	s := newBehaviorStorage(t)
+	now := time.Now().UTC()
+
+	const n = 2*retentionBatchSize + 500
+	entries := make([]*LogEntry, 0, n+1)
+	for i := range n {
+		entries = append(entries, &LogEntry{ID: fmt.Sprintf("d%d", i), Timestamp: now.Add(-time.Duration(i) * time.Millisecond), Level: "DEBUG", Raw: "x"})
+	}
+	entries = append(entries, &LogEntry{ID: "keep", Timestamp: now, Level: "INFO"})
+	if err := s.StoreBatch(entries); err != nil {
+		t.Fatalf("StoreBatch() error = %v", err)
+	}
+
+	var reports []int
+	s.SetProgress(func(deleted int) { reports = append(reports, deleted) })
+	deleted, err := s.DeleteByLevel("DEBUG")
+	if err != nil || deleted != n {
+		t.Fatalf("DeleteByLevel() = %d, %v, want %d", deleted, err, n)
+	}
+	if want := []int{1000, 2000, n}; fmt.Sprint(reports) != fmt.Sprint(want) {
+		t.Fatalf("progress reports = %v, want %v", reports, want)
+	}
+	if _, err := s.GetRaw("d1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetRaw(d1) error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetEntry("keep"); err != nil {
+		t.Fatalf("GetEntry(keep) error = %v", err)
+	}
+}
+
 func TestSubscribeAndMaintenanceAPIs(t *testing.T) {
 	s := newBehaviorStorage(t)
 	ch, cancel := s.Subscribe(AllFilter{})
@@ -166,6 +236,103 @@ func TestCompactDatabaseFullyAfterCloseReturnsError(t *testing.T) {
 	}
 }
 
+func TestCloseStopsBackgroundWorkers(t *testing.T) {
+	s, err := NewBadgerStorage(Config{DBPath: t.TempDir(), RetentionSize: 1024 * 1024 * 100, RetentionDays: 30})
+	if err != nil {
+		t.Fatalf("NewBadgerStorage() error = %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		addEntry(t, s, fmt.Sprintf("w%d", i), time.Now().UTC(), "INFO", nil)
+	}
+	// Queue a retention sweep so Close races a running worker.
+	s.cleanupChan <- struct{}{}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	stopped := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("background workers still running after Close")
+	}
+}
+
+func TestHealthTracksWritesAndRetention(t *testing.T) {
+	s, err := NewBadgerStorage(Config{DBPath: t.TempDir(), RetentionDays: 30})
+	if err != nil {
+		t.Fatalf("NewBadgerStorage() error = %v", err)
+	}
+
+	h := s.Health()
+	if !h.Open || !h.Writable || h.LastWrite != nil {
+		t.Fatalf("Health() = %+v, want open and writable with no write yet", h)
+	}
+	if !h.Retention.Enabled || !h.Retention.Running || h.Retention.Sweeps != 1 {
+		t.Fatalf("Retention = %+v, want the startup sweep and a running worker", h.Retention)
+	}
+
+	addEntry(t, s, "h1", time.Now().UTC(), "INFO", nil)
+	if h = s.Health(); h.LastWrite == nil {
+		t.Fatalf("Health().LastWrite = nil after a write")
+	}
+
+	s.noteWriteResult(errors.New("disk full"))
+	if h = s.Health(); h.Writable || h.LastError != "disk full" || h.LastErrorAt == nil {
+		t.Fatalf("Health() = %+v, want not writable with the write error", h)
+	}
+	addEntry(t, s, "h2", time.Now().UTC(), "INFO", nil)
+	if h = s.Health(); !h.Writable || h.LastError != "disk full" {
+		t.Fatalf("Health() = %+v, want writable again, keeping the last error", h)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if h = s.Health(); h.Open || h.Writable || h.Retention.Running {
+		t.Fatalf("Health() = %+v after Close, want closed", h)
+	}
+}
+
+func TestScanRawStreamsOriginalLinesInTimeOrder(t *testing.T) {
+	s := newBehaviorStorage(t)
+	base := time.Now().UTC().Add(-3 * time.Hour)
+	addEntry(t, s, "late", base.Add(2*time.Hour), "ERROR", nil)
+	addEntry(t, s, "early", base, "ERROR", nil)
+	addEntry(t, s, "info", base.Add(time.Hour), "INFO", nil)
+	addEntry(t, s, "outside", base.Add(5*time.Hour), "ERROR", nil)
+	if err := s.Store(&LogEntry{ID: "noraw", Timestamp: base.Add(90 * time.Minute), Level: "ERROR", Message: "message only"}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	var lines []string
+	tr := &TimeRange{Start: base, End: base.Add(3 * time.Hour)}
+	err := s.ScanRaw(context.Background(), LevelFilter{Level: "ERROR"}, tr, func(_ *LogEntry, raw string) error {
+		lines = append(lines, raw)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ScanRaw() error = %v", err)
+	}
+	if got := strings.Join(lines, ","); got != "early,message only,late" {
+		t.Fatalf("ScanRaw() lines = %q", got)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = s.ScanRaw(context.Background(), AllFilter{}, nil, func(*LogEntry, string) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("ScanRaw() error = %v after %d calls, want the callback error after 1", err, calls)
+	}
+}
+
 func TestGetStatsTracksUnknownLevelAndExpandPath(t *testing.T) {
 	s := newBehaviorStorage(t)
 	addEntry(t, s, "u", time.Now().UTC(), "", nil)
@@ -185,6 +352,98 @@ func TestGetStatsTracksUnknownLevelAndExpandPath(t *testing.T) {
 	}
 }
 
+func TestGetStatsStorageBreakdownAndForecast(t *testing.T) {
+	s := newBehaviorStorage(t)
+	now := time.Now().UTC()
+	addEntry(t, s, "recent-1", now.Add(-10*time.Minute), "INFO", nil)
+	addEntry(t, s, "recent-2", now.Add(-50*time.Minute), "INFO", nil)
+	addEntry(t, s, "old", now.Add(-3*time.Hour), "INFO", nil)
+	if err := s.SaveView(&View{Name: "v", Query: "*"}); err != nil {
+		t.Fatalf("SaveView() error = %v", err)
+	}
+
+	stats, err := s.statsAt(now)
+	if err != nil {
+		t.Fatalf("statsAt() error = %v", err)
+	}
+	if stats.TotalLogs != 3 || stats.IngestRatePerHour != 2 {
+		t.Fatalf("statsAt() total=%d rate=%d, want 3 and 2", stats.TotalLogs, stats.IngestRatePerHour)
+	}
+	if stats.AvgEntryBytes <= 0 || stats.RetentionSizeBytes != s.retentionSize {
+		t.Fatalf("statsAt() avg=%f cap=%d", stats.AvgEntryBytes, stats.RetentionSizeBytes)
+	}
+	if stats.DaysUntilFull == nil || *stats.DaysUntilFull <= 0 {
+		t.Fatalf("statsAt() DaysUntilFull = %v, want a positive projection", stats.DaysUntilFull)
+	}
+
+	if quiet, err := s.statsAt(now.Add(24 * time.Hour)); err != nil || quiet.IngestRatePerHour != 0 || quiet.DaysUntilFull != nil {
+		t.Fatalf("statsAt(+24h) = %+v, %v, want no rate and no projection", quiet, err)
+	}
+}
+
+func TestGetStatsVolumeBySourceAndSession(t *testing.T) {
+	s := newBehaviorStorage(t)
+	now := time.Now().UTC()
+	entries := []*LogEntry{
+		{ID: "a1", Timestamp: now, Level: "INFO", Message: "a", Raw: strings.Repeat("x", 4000), Source: "api", Session: "s1"},
+		{ID: "a2", Timestamp: now, Level: "INFO", Message: "a", Raw: strings.Repeat("x", 4000), Source: "api", Session: "s1"},
+		{ID: "w1", Timestamp: now, Level: "INFO", Message: "w", Raw: "w", Fields: map[string]interface{}{"source": "worker"}, Session: "s2"},
+		{ID: "n1", Timestamp: now, Level: "INFO", Message: "n", Raw: "n"},
+	}
+	if err := s.StoreBatch(entries); err != nil {
+		t.Fatalf("StoreBatch() error = %v", err)
+	}
+
+	stats, err := s.GetStats()
+	if err != nil {
+		t.Fatalf("GetStats() error = %v", err)
+	}
+	names := func(groups []VolumeStats) []string {
+		var out []string
+		for _, g := range groups {
+			out = append(out, fmt.Sprintf("%s=%d", g.Name, g.Count))
+		}
+		return out
+	}
+	// Largest first: the api raw lines outweigh the others.
+	if got := names(stats.Sources); len(got) != 3 || got[0] != "api=2" || !slices.Contains(got, "worker=1") || !slices.Contains(got, "=1") {
+		t.Fatalf("Sources = %v, want api=2 first, then worker=1 and =1", got)
+	}
+	if got := names(stats.Sessions); !reflect.DeepEqual(got, []string{"s1=2", "s2=1"}) {
+		t.Fatalf("Sessions = %v, want [s1=2 s2=1]", got)
+	}
+	if api := stats.Sources[0]; api.Bytes < 8000 {
+		t.Fatalf("api bytes = %d, want the raw lines counted", api.Bytes)
+	}
+}
+
+func TestDaysUntilFull(t *testing.T) {
+	tests := []struct {
+		name     string
+		capBytes int64
+		size     int64
+		perHour  int
+		avg      float64
+		want     *float64
+	}{
+		{name: "no cap", size: 10, perHour: 10, avg: 100},
+		{name: "no ingest", capBytes: 1000, size: 10, avg: 100},
+		{name: "projected", capBytes: 24_000 + 500, size: 500, perHour: 10, avg: 100, want: ptrFloat(1)},
+		{name: "already over cap", capBytes: 100, size: 500, perHour: 10, avg: 100, want: ptrFloat(0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := daysUntilFull(tt.capBytes, tt.size, tt.perHour, tt.avg)
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Fatalf("daysUntilFull() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func ptrFloat(v float64) *float64 { return &v }
+
 func TestEnforceRetentionNoopWhenDisabled(t *testing.T) {
 	s := newBehaviorStorage(t)
 	s.retentionDays = 0
@@ -193,3 +452,269 @@ func TestEnforceRetentionNoopWhenDisabled(t *testing.T) {
 		t.Fatalf("enforceRetention() error = %v", err)
 	}
 }
+
+func TestQueryWithTimeRangeShardsPreserveOrderAndPagination(t *testing.T) {
+	s, err := NewBadgerStorage(Config{DBPath: t.TempDir(), RetentionSize: 1024 * 1024 * 100, RetentionDays: 30, QueryWorkers: 4})
+	if err != nil {
+		t.Fatalf("NewBadgerStorage() error = %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	base := time.Now().UTC().Add(-time.Hour)
+	for i := 0; i < 40; i++ {
+		level := "INFO"
+		if i%2 == 0 {
+			level = "ERROR"
+		}
+		addEntry(t, s, fmt.Sprintf("e%02d", i), base.Add(time.Duration(i)*time.Minute), level, nil)
+	}
+
+	results, total, err := s.QueryWithTimeRange(LevelFilter{Level: "ERROR"}, nil, 5, 3)
+	if err != nil {
+		t.Fatalf("QueryWithTimeRange() error = %v", err)
+	}
+	if total != 20 || len(results) != 5 {
+		t.Fatalf("unexpected sharded result: total=%d len=%d", total, len(results))
+	}
+	for i, entry := range results {
+		if want := fmt.Sprintf("e%02d", (i+3)*2); entry.ID != want {
+			t.Fatalf("results[%d].ID = %s, want %s", i, entry.ID, want)
+		}
+	}
+
+	// Deep pages are found by counting shards, then skipping through the
+	// one the page starts in, with the same result.
+	originalRetained := maxRetainedMatches
+	maxRetainedMatches = 4
+	defer func() { maxRetainedMatches = originalRetained }()
+	for _, skipTotal := range []bool{false, true} {
+		results, total, err := s.QueryContext(context.Background(), LevelFilter{Level: "ERROR"}, QueryOptions{Limit: 5, Offset: 13, SkipTotal: skipTotal})
+		if err != nil {
+			t.Fatalf("QueryContext() error = %v", err)
+		}
+		if len(results) != 5 || (!skipTotal && total != 20) {
+			t.Fatalf("deep page (skipTotal=%v): total=%d len=%d", skipTotal, total, len(results))
+		}
+		for i, entry := range results {
+			if want := fmt.Sprintf("e%02d", (i+13)*2); entry.ID != want {
+				t.Fatalf("deep page (skipTotal=%v) results[%d].ID = %s, want %s", skipTotal, i, entry.ID, want)
+			}
+		}
+	}
+
+	tr := &TimeRange{Start: base.Add(10 * time.Minute), End: base.Add(19 * time.Minute)}
+	results, total, err = s.QueryWithTimeRange(AllFilter{}, tr, 100, 0)
+	if err != nil {
+		t.Fatalf("QueryWithTimeRange() error = %v", err)
+	}
+	if total != 10 || len(results) != 10 || results[0].ID != "e10" || results[9].ID != "e19" {
+		t.Fatalf("unexpected sharded range result: total=%d len=%d", total, len(results))
+	}
+}
+
+func TestRawStoredSeparatelyFromEntry(t *testing.T) {
+	s := newBehaviorStorage(t)
+	now := time.Now().UTC()
+	addEntry(t, s, "a", now, "INFO", nil)
+
+	results, _, err := s.Query(AllFilter{}, 10, 0)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(results) != 1 || results[0].Raw != "" {
+		t.Fatalf("expected query results without raw, got %+v", results)
+	}
+
+	raw, err := s.GetRaw("a")
+	if err != nil || raw != "a" {
+		t.Fatalf("GetRaw() = %q, %v", raw, err)
+	}
+	if _, err := s.GetRaw("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetRaw(missing) error = %v, want ErrNotFound", err)
+	}
+
+	entry, err := s.GetEntry("a")
+	if err != nil || entry.ID != "a" || entry.Raw != "a" || entry.Level != "INFO" {
+		t.Fatalf("GetEntry() = %+v, %v, want entry with raw", entry, err)
+	}
+	if _, err := s.GetEntry("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetEntry(missing) error = %v, want ErrNotFound", err)
+	}
+
+	if _, err := s.DeleteAll(); err != nil {
+		t.Fatalf("DeleteAll() error = %v", err)
+	}
+	if _, err := s.GetRaw("a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetRaw after delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLevelMetaPreFiltering(t *testing.T) {
+	s := newBehaviorStorage(t)
+	now := time.Now().UTC()
+	addEntry(t, s, "1", now.Add(-3*time.Minute), "ERROR", nil)
+	addEntry(t, s, "2", now.Add(-2*time.Minute), "INFO", nil)
+	addEntry(t, s, "3", now.Add(-time.Minute), "ERROR", nil)
+	addEntry(t, s, "4", now, "CUSTOM", nil)
+
+	results, total, err := s.Query(LevelFilter{Level: "ERROR"}, 1, 0)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if total != 2 || len(results) != 1 || results[0].ID != "1" {
+		t.Fatalf("unexpected level query: total=%d len=%d", total, len(results))
+	}
+
+	results, total, err = s.Query(LevelFilter{Level: "CUSTOM"}, 10, 0)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if total != 1 || len(results) != 1 || results[0].ID != "4" {
+		t.Fatalf("unexpected custom level query: total=%d len=%d", total, len(results))
+	}
+
+	stats, err := s.GetStats()
+	if err != nil {
+		t.Fatalf("GetStats() error = %v", err)
+	}
+	if stats.Levels["ERROR"] != 2 || stats.Levels["INFO"] != 1 || stats.Levels["CUSTOM"] != 1 {
+		t.Fatalf("unexpected level stats: %+v", stats.Levels)
+	}
+
+	if got := levelMeta("WARN"); got == 0 {
+		t.Fatalf("levelMeta(WARN) = 0, want a code")
+	}
+	if got := levelMeta("CUSTOM"); got != 0 {
+		t.Fatalf("levelMeta(CUSTOM) = %d, want 0", got)
+	}
+}
+
+func TestBadgerTuning(t *testing.T) {
+	s, err := NewBadgerStorage(Config{
+		DBPath: t.TempDir(),
+		Badger: BadgerTuning{
+			DisableSyncWrites: true,
+			ValueLogFileSize:  16 << 20,
+			MemTableSize:      8 << 20,
+			Compression:       "zstd",
+			NumCompactors:     2,
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewBadgerStorage() error = %v", err)
+	}
+	defer s.Close()
+
+	opts := s.db.Opts()
+	if opts.SyncWrites || opts.ValueLogFileSize != 16<<20 || opts.MemTableSize != 8<<20 || opts.NumCompactors != 2 {
+		t.Fatalf("tuning not applied: sync=%v vlog=%d memtable=%d compactors=%d", opts.SyncWrites, opts.ValueLogFileSize, opts.MemTableSize, opts.NumCompactors)
+	}
+
+	if _, err := NewBadgerStorage(Config{DBPath: t.TempDir(), Badger: BadgerTuning{Compression: "lz4"}}); err == nil {
+		t.Fatalf("expected error for invalid compression")
+	}
+}
+
+func TestEnforceRetentionBatchesWithoutStorageLock(t *testing.T) {
+	s, err := NewBadgerStorage(Config{DBPath: t.TempDir(), Badger: BadgerTuning{DisableSyncWrites: true}})
+	if err != nil {
+		t.Fatalf("NewBadgerStorage() error = %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	old := time.Now().UTC().AddDate(0, 0, -10)
+	for i := 0; i < retentionBatchSize*2+5; i++ {
+		addEntry(t, s, fmt.Sprintf("old-%d", i), old.Add(time.Duration(i)*time.Millisecond), "INFO", nil)
+	}
+	addEntry(t, s, "fresh", time.Now().UTC(), "INFO", nil)
+
+	s.retentionMu.Lock()
+	s.retentionDays = 1
+	s.retentionMu.Unlock()
+
+	// Hold the storage lock for the whole sweep: retention must not need it.
+	s.mu.Lock()
+	done := make(chan error, 1)
+	go func() { done <- s.enforceRetention() }()
+	select {
+	case err := <-done:
+		s.mu.Unlock()
+		if err != nil {
+			t.Fatalf("enforceRetention() error = %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		s.mu.Unlock()
+		t.Fatalf("enforceRetention() blocked on the storage lock")
+	}
+
+	results, total, err := s.Query(AllFilter{}, 10, 0)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if total != 1 || results[0].ID != "fresh" {
+		t.Fatalf("unexpected entries after retention: total=%d", total)
+	}
+}
+
+func TestQueryContextSkipTotalAndCancellation(t *testing.T) {
+	s := newBehaviorStorage(t)
+	base := time.Now().UTC().Add(-time.Hour)
+	for i := 0; i < 10; i++ {
+		addEntry(t, s, fmt.Sprintf("e%d", i), base.Add(time.Duration(i)*time.Minute), "INFO", nil)
+	}
+
+	results, total, err := s.QueryContext(context.Background(), AllFilter{}, QueryOptions{Limit: 3, Offset: 2, SkipTotal: true})
+	if err != nil {
+		t.Fatalf("QueryContext() error = %v", err)
+	}
+	if len(results) != 3 || results[0].ID != "e2" || total != 6 {
+		t.Fatalf("unexpected skip-total result: total=%d len=%d", total, len(results))
+	}
+
+	results, total, err = s.QueryContext(context.Background(), AllFilter{}, QueryOptions{Limit: 5, Offset: 8, SkipTotal: true})
+	if err != nil {
+		t.Fatalf("QueryContext() error = %v", err)
+	}
+	if len(results) != 2 || total != 10 {
+		t.Fatalf("unexpected last page: total=%d len=%d", total, len(results))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 10; i < ctxCheckInterval+10; i++ {
+		addEntry(t, s, fmt.Sprintf("e%d", i), base.Add(time.Duration(i)*time.Second), "INFO", nil)
+	}
+	if _, _, err := s.QueryContext(ctx, AllFilter{}, QueryOptions{Limit: 10}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("QueryContext(cancelled) error = %v, want context.Canceled", err)
+	}
+}
+
+func TestStoreBatch(t *testing.T) {
+	s := newBehaviorStorage(t)
+	now := time.Now().UTC()
+
+	batch := []*LogEntry{
+		{ID: "b1", Timestamp: now.Add(-time.Minute), Level: "ERROR", Message: "one", Raw: "raw one"},
+		{ID: "b2", Timestamp: now, Level: "INFO", Message: "two"},
+	}
+	if err := s.StoreBatch(batch); err != nil {
+		t.Fatalf("StoreBatch() error = %v", err)
+	}
+	if err := s.StoreBatch(nil); err != nil {
+		t.Fatalf("StoreBatch(nil) error = %v", err)
+	}
+
+	results, total, err := s.Query(LevelFilter{Level: "ERROR"}, 10, 0)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if total != 1 || results[0].ID != "b1" {
+		t.Fatalf("unexpected batch query: total=%d", total)
+	}
+	if raw, err := s.GetRaw("b1"); err != nil || raw != "raw one" {
+		t.Fatalf("GetRaw(b1) = %q, %v", raw, err)
+	}
+	if s.writeCount != 2 {
+		t.Fatalf("writeCount = %d, want 2", s.writeCount)
+	}
+}
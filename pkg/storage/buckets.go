@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Log keys are grouped into hourly buckets: log:{yyyymmddhh}:{ts}:{id}, with
+// the bucket taken from the timestamp in UTC. Keys still sort chronologically,
+// and retention can drop every key of an expired hour with one DropPrefix
+// instead of deleting them one by one.
+const bucketLayout = "2006010215"
+
+// logKey returns the key an entry with timestamp ts (Unix nanoseconds) and
+// the given ID is stored under.
+func logKey(ts int64, id string) []byte {
+	return append(logSeekKey(ts), id...)
+}
+
+// logSeekKey returns the smallest possible key for timestamp ts, for seeking
+// to the start of a time range.
+func logSeekKey(ts int64) []byte {
+	return fmt.Appendf(logBucketPrefix(ts), "%d:", ts)
+}
+
+// logBucketPrefix returns the log:{yyyymmddhh}: prefix shared by every key in
+// the hour containing ts.
+func logBucketPrefix(ts int64) []byte {
+	return []byte(logPrefix + time.Unix(0, ts).UTC().Format(bucketLayout) + ":")
+}
+
+// keyBucket returns the bucket prefix of a log key.
+func keyBucket(key []byte) ([]byte, bool) {
+	rest := key[len(logPrefix):]
+	if bytes.IndexByte(rest, ':') != len(bucketLayout) {
+		return nil, false
+	}
+	return key[:len(logPrefix)+len(bucketLayout)+1], true
+}
+
+// dropBucketsBefore removes every hourly bucket that ends before cutoff and
+// returns how many entries it held. Raw lines, ID keys and annotations are
+// keyed by ID rather than time, so their keys are deleted first; the log keys
+// themselves then go in a single DropPrefix. The bucket containing cutoff is left for
+// the caller to trim key by key.
+func (s *BadgerStorage) dropBucketsBefore(cutoff time.Time) (int, error) {
+	limit := logBucketPrefix(cutoff.UnixNano())
+	var prefixes [][]byte
+	count := 0
+
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte(logPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			key := it.Item().Key()
+			if bytes.Compare(key, limit) >= 0 {
+				break
+			}
+			bucket, ok := keyBucket(key)
+			if !ok {
+				continue
+			}
+			if n := len(prefixes); n == 0 || !bytes.Equal(prefixes[n-1], bucket) {
+				prefixes = append(prefixes, bytes.Clone(bucket))
+			}
+			if id, ok := keyID(key); ok {
+				if err := wb.Delete(rawKey(id)); err != nil {
+					return err
+				}
+				if err := wb.Delete(idKey(id)); err != nil {
+					return err
+				}
+				if err := wb.Delete(annotationKey(id)); err != nil {
+					return err
+				}
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(prefixes) == 0 {
+		return 0, nil
+	}
+
+	if err := wb.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to delete siblings of expired buckets: %w", err)
+	}
+	if err := s.db.DropPrefix(prefixes...); err != nil {
+		return 0, fmt.Errorf("failed to drop expired buckets: %w", err)
+	}
+	return count, nil
+}
+
+// isLegacyLogKey reports whether key uses the pre-bucket log:{ts}:{id} layout,
+// whose first segment is a nanosecond timestamp, negative before 1970,
+// rather than a bucket.
+func isLegacyLogKey(key []byte) bool {
+	rest := key[len(logPrefix):]
+	i := bytes.IndexByte(rest, ':')
+	if i <= 0 {
+		return false
+	}
+	digits := rest[:i]
+	if digits[0] == '-' {
+		digits = digits[1:]
+	} else if i == len(bucketLayout) {
+		return false
+	}
+	if len(digits) == 0 {
+		return false
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// migrateLegacyKeys rewrites log:{ts}:{id} keys from older databases into
+// their hourly buckets, keeping each value and level meta byte. Legacy keys
+// mostly sort before every bucket key, but negative timestamps and keys
+// written after a partial migration may not, so the whole log keyspace is
+// walked, keys only, resuming each batch where the last one stopped. It
+// returns how many keys were moved.
+func (s *BadgerStorage) migrateLegacyKeys() (int, error) {
+	moved := 0
+	prefix := []byte(logPrefix)
+	seek := prefix
+	for {
+		n := 0
+		done := true
+		wb := s.db.NewWriteBatch()
+		err := s.db.View(func(txn *badger.Txn) error {
+			opts := badger.DefaultIteratorOptions
+			opts.PrefetchValues = false
+			it := txn.NewIterator(opts)
+			defer it.Close()
+
+			for it.Seek(seek); it.ValidForPrefix(prefix); it.Next() {
+				item := it.Item()
+				if n == retentionBatchSize {
+					seek, done = item.KeyCopy(nil), false
+					break
+				}
+				if !isLegacyLogKey(item.Key()) {
+					continue
+				}
+				ts, id, ok := legacyKeyParts(item.Key())
+				if !ok {
+					continue
+				}
+				val, err := item.ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				key := logKey(ts, id)
+				if err := wb.SetEntry(badger.NewEntry(key, val).WithMeta(item.UserMeta())); err != nil {
+					return err
+				}
+				if err := wb.Set(idKey(id), key); err != nil {
+					return err
+				}
+				if err := wb.Delete(item.KeyCopy(nil)); err != nil {
+					return err
+				}
+				n++
+			}
+			return nil
+		})
+		if err == nil {
+			err = wb.Flush()
+		}
+		wb.Cancel()
+		if err != nil {
+			return moved, fmt.Errorf("failed to migrate log keys: %w", err)
+		}
+
+		moved += n
+		if done {
+			return moved, nil
+		}
+		runtime.Gosched()
+	}
+}
+
+// legacyKeyParts splits a log:{ts}:{id} key.
+func legacyKeyParts(key []byte) (int64, string, bool) {
+	tsPart, id, ok := strings.Cut(string(key[len(logPrefix):]), ":")
+	if !ok {
+		return 0, "", false
+	}
+	ts, err := strconv.ParseInt(tsPart, 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return ts, id, true
+}
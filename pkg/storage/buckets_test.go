@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func TestLogKeyLayout(t *testing.T) {
+	ts := time.Date(2026, 3, 4, 5, 6, 7, 8, time.UTC).UnixNano()
+	key := logKey(ts, "abc:1")
+
+	want := "log:2026030405:" + strconv.FormatInt(ts, 10) + ":abc:1"
+	if string(key) != want {
+		t.Fatalf("logKey() = %q, want %q", key, want)
+	}
+	if got, ok := keyTimestamp(key); !ok || got != ts {
+		t.Fatalf("keyTimestamp() = %d, %v, want %d", got, ok, ts)
+	}
+	if got, ok := keyID(key); !ok || got != "abc:1" {
+		t.Fatalf("keyID() = %q, %v, want abc:1", got, ok)
+	}
+	if got, ok := keyBucket(key); !ok || string(got) != "log:2026030405:" {
+		t.Fatalf("keyBucket() = %q, %v", got, ok)
+	}
+	if isLegacyLogKey(key) {
+		t.Fatal("isLegacyLogKey() = true for a bucketed key")
+	}
+	if !isLegacyLogKey([]byte("log:" + strconv.FormatInt(ts, 10) + ":abc")) {
+		t.Fatal("isLegacyLogKey() = false for a log:{ts}:{id} key")
+	}
+}
+
+func TestMigrateLegacyKeys(t *testing.T) {
+	s := newBehaviorStorage(t)
+	base := time.Now().Add(-time.Hour)
+
+	const n = retentionBatchSize + 5
+	err := s.db.Update(func(txn *badger.Txn) error {
+		for i := range n {
+			e := &LogEntry{ID: "e" + strconv.Itoa(i), Timestamp: base.Add(time.Duration(i) * time.Millisecond), Level: "ERROR", Message: "m"}
+			data, _ := e.ToJSON()
+			key := "log:" + strconv.FormatInt(e.Timestamp.UnixNano(), 10) + ":" + e.ID
+			if err := txn.SetEntry(badger.NewEntry([]byte(key), data).WithMeta(levelMeta(e.Level))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("seed error = %v", err)
+	}
+
+	moved, err := s.migrateLegacyKeys()
+	if err != nil || moved != n {
+		t.Fatalf("migrateLegacyKeys() = %d, %v, want %d", moved, err, n)
+	}
+	if moved, err := s.migrateLegacyKeys(); err != nil || moved != 0 {
+		t.Fatalf("second migrateLegacyKeys() = %d, %v, want 0", moved, err)
+	}
+
+	res, err := s.Verify(context.Background(), false)
+	if err != nil || res.Checked != n || len(res.Issues) != 0 {
+		t.Fatalf("Verify() = %+v, %v, want %d clean entries", res, err, n)
+	}
+	entry, err := s.GetEntry("e7")
+	if err != nil || entry.Level != "ERROR" {
+		t.Fatalf("GetEntry(e7) = %+v, %v", entry, err)
+	}
+	err = s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(logKey(entry.Timestamp.UnixNano(), "e7"))
+		if err != nil {
+			return err
+		}
+		if item.UserMeta() != levelMeta("ERROR") {
+			t.Errorf("migrated UserMeta() = %d, want level meta kept", item.UserMeta())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("migrated key lookup error = %v", err)
+	}
+}
+
+func TestMigrateLegacyKeysSkipsBucketedKeys(t *testing.T) {
+	s := newBehaviorStorage(t)
+	now := time.Now().UTC()
+	addEntry(t, s, "bucketed", now, "INFO", nil)
+
+	// Legacy keys on both sides of the bucketed one: a far-future timestamp
+	// sorts after every current bucket.
+	legacy := map[string]time.Time{
+		"before": time.Unix(0, -5000),
+		"after":  time.Date(2090, 1, 1, 0, 0, 0, 0, time.UTC),
+		"middle": now.Add(-time.Hour),
+	}
+	err := s.db.Update(func(txn *badger.Txn) error {
+		for id, ts := range legacy {
+			data, _ := (&LogEntry{ID: id, Timestamp: ts, Level: "WARN", Message: id}).ToJSON()
+			key := "log:" + strconv.FormatInt(ts.UnixNano(), 10) + ":" + id
+			if err := txn.SetEntry(badger.NewEntry([]byte(key), data).WithMeta(levelMeta("WARN"))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("seed error = %v", err)
+	}
+
+	moved, err := s.migrateLegacyKeys()
+	if err != nil || moved != len(legacy) {
+		t.Fatalf("migrateLegacyKeys() = %d, %v, want %d", moved, err, len(legacy))
+	}
+	res, err := s.Verify(context.Background(), false)
+	if err != nil || res.Checked != len(legacy)+1 || len(res.Issues) != 0 {
+		t.Fatalf("Verify() = %+v, %v, want %d clean entries", res, err, len(legacy)+1)
+	}
+	for id, ts := range legacy {
+		err := s.db.View(func(txn *badger.Txn) error {
+			_, err := txn.Get(logKey(ts.UnixNano(), id))
+			return err
+		})
+		if err != nil {
+			t.Errorf("bucketed key of %s: %v", id, err)
+		}
+	}
+}
+
+func TestDeleteOlderThanDropsBuckets(t *testing.T) {
+	s := newBehaviorStorage(t)
+	cutoff := time.Now().Truncate(time.Hour).Add(-2*time.Hour + 30*time.Minute)
+
+	addEntry(t, s, "old-hour", cutoff.Add(-3*time.Hour), "INFO", nil)
+	addEntry(t, s, "old-hour-2", cutoff.Add(-2*time.Hour), "INFO", nil)
+	addEntry(t, s, "same-hour-old", cutoff.Add(-10*time.Minute), "INFO", nil)
+	addEntry(t, s, "same-hour-new", cutoff.Add(10*time.Minute), "INFO", nil)
+	addEntry(t, s, "recent", cutoff.Add(time.Hour), "INFO", nil)
+	if err := s.SetAnnotation(&Annotation{ID: "old-hour", Pinned: true}); err != nil {
+		t.Fatalf("SetAnnotation() error = %v", err)
+	}
+
+	n, err := s.DeleteOlderThan(cutoff)
+	if err != nil || n != 3 {
+		t.Fatalf("DeleteOlderThan() = %d, %v, want 3", n, err)
+	}
+
+	for _, id := range []string{"old-hour", "old-hour-2", "same-hour-old"} {
+		if _, err := s.GetEntry(id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetEntry(%s) error = %v, want ErrNotFound", id, err)
+		}
+		if _, err := s.GetRaw(id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetRaw(%s) error = %v, want ErrNotFound", id, err)
+		}
+	}
+	if _, err := s.GetAnnotation("old-hour"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetAnnotation(old-hour) error = %v, want ErrNotFound", err)
+	}
+	for _, id := range []string{"same-hour-new", "recent"} {
+		if _, err := s.GetEntry(id); err != nil {
+			t.Errorf("GetEntry(%s) error = %v, want kept", id, err)
+		}
+	}
+}
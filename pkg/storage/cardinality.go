@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// highCardinalityThreshold is how many distinct values GetFields tracks per
+// field before dropping its value counts and reporting it as high cardinality.
+const highCardinalityThreshold = 1000
+
+// hllPrecision gives 2^12 registers: 4 KiB per field, ~1.6% standard error.
+const hllPrecision = 12
+
+// hyperLogLog estimates the number of distinct strings added to it.
+type hyperLogLog struct {
+	registers [1 << hllPrecision]uint8
+}
+
+// add records v.
+func (h *hyperLogLog) add(v string) {
+	f := fnv.New64a()
+	f.Write([]byte(v))
+	x := mix64(f.Sum64())
+
+	idx := x >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1)) + 1)
+	if rank > h.registers[idx] {
+		h.registers[idx] = rank
+	}
+}
+
+// count returns the estimated number of distinct values added.
+func (h *hyperLogLog) count() uint64 {
+	const m = float64(len(h.registers))
+	sum, zeros := 0.0, 0
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate while many registers are empty.
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// mix64 is the murmur3 finalizer; it spreads FNV's weak low-entropy bits so
+// similar values (sequential IDs) land in different registers.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// fieldValueStats accumulates one field's values for GetFields. Exact counts
+// are kept until the field proves to be high cardinality; from then on only
+// a HyperLogLog estimate is kept.
+type fieldValueStats struct {
+	counts map[string]int
+	hll    *hyperLogLog // set once high cardinality
+	typ    string       // inferred from the sample seen before counts were dropped
+}
+
+func newFieldValueStats() *fieldValueStats {
+	return &fieldValueStats{counts: make(map[string]int)}
+}
+
+// add records one occurrence of v.
+func (f *fieldValueStats) add(v string) {
+	if f.hll != nil {
+		f.hll.add(v)
+		return
+	}
+	f.counts[v]++
+	if len(f.counts) > highCardinalityThreshold {
+		f.hll = &hyperLogLog{}
+		for seen := range f.counts {
+			f.hll.add(seen)
+		}
+		f.typ = inferFieldType(f.counts)
+		f.counts = nil
+	}
+}
+
+// info builds the FieldInfo for the field called name.
+func (f *fieldValueStats) info(name string, maxTopValues int) FieldInfo {
+	fi := FieldInfo{Name: name, TopValues: []string{}}
+	if f.hll != nil {
+		fi.Type = f.typ
+		fi.Cardinality = f.hll.count()
+		fi.HighCardinality = true
+	} else {
+		fi.Type = inferFieldType(f.counts)
+		fi.TopValues = topN(f.counts, maxTopValues)
+		fi.Cardinality = uint64(len(f.counts)) // exact below the threshold
+	}
+	if t, ok := builtinFieldTypes[name]; ok {
+		fi.Type = t
+	}
+	return fi
+}
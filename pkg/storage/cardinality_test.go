@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHyperLogLogCount(t *testing.T) {
+	for _, n := range []int{10, 1000, 100000} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			var h hyperLogLog
+			for i := 0; i < n; i++ {
+				h.add(fmt.Sprintf("req-%d", i))
+				h.add(fmt.Sprintf("req-%d", i)) // repeats don't count
+			}
+			got := h.count()
+			if errRate := math.Abs(float64(got)-float64(n)) / float64(n); errRate > 0.05 {
+				t.Fatalf("count() = %d, want %d within 5%%", got, n)
+			}
+		})
+	}
+}
+
+func TestGetFieldsHighCardinality(t *testing.T) {
+	s := newBehaviorStorage(t)
+	ts := time.Now().UTC().Add(-time.Hour)
+
+	const n = highCardinalityThreshold + 500
+	entries := make([]*LogEntry, 0, n)
+	for i := 0; i < n; i++ {
+		entries = append(entries, &LogEntry{
+			ID:        fmt.Sprintf("e%d", i),
+			Timestamp: ts.Add(time.Duration(i) * time.Millisecond),
+			Level:     "INFO",
+			Fields:    map[string]interface{}{"request_id": fmt.Sprintf("%08x", i*7919), "service": fmt.Sprintf("svc-%d", i%3)},
+		})
+	}
+	if err := s.StoreBatch(entries); err != nil {
+		t.Fatalf("StoreBatch() error = %v", err)
+	}
+
+	fields, err := s.GetFields(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("GetFields() error = %v", err)
+	}
+	byName := make(map[string]FieldInfo)
+	for _, f := range fields {
+		byName[f.Name] = f
+	}
+
+	rid := byName["request_id"]
+	if !rid.HighCardinality || len(rid.TopValues) != 0 || rid.Type != FieldTypeString {
+		t.Fatalf("request_id = %+v, want high cardinality string without top values", rid)
+	}
+	if errRate := math.Abs(float64(rid.Cardinality)-n) / n; errRate > 0.05 {
+		t.Fatalf("request_id cardinality = %d, want ~%d", rid.Cardinality, n)
+	}
+
+	svc := byName["service"]
+	if svc.HighCardinality || svc.Cardinality != 3 || len(svc.TopValues) != 3 {
+		t.Fatalf("service = %+v, want 3 exact values", svc)
+	}
+	if lvl := byName["level"]; lvl.Cardinality != 1 || lvl.Type != FieldTypeString {
+		t.Fatalf("level = %+v, want one string value", lvl)
+	}
+}
+
+func TestGetFieldsSampled(t *testing.T) {
+	s := newBehaviorStorage(t)
+	base := time.Now().UTC().Add(-time.Hour)
+
+	// 100 entries a second apart; each ten-second stretch has its own zone.
+	entries := make([]*LogEntry, 0, 100)
+	for i := 0; i < 100; i++ {
+		entries = append(entries, &LogEntry{
+			ID:        fmt.Sprintf("e%03d", i),
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+			Level:     "INFO",
+			Fields:    map[string]interface{}{"zone": fmt.Sprintf("z%d", i/10)},
+		})
+	}
+	if err := s.StoreBatch(entries); err != nil {
+		t.Fatalf("StoreBatch() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		tr        *TimeRange
+		sample    int
+		wantRate  float64
+		wantZones uint64
+	}{
+		{name: "all", wantRate: 1, wantZones: 10},
+		{name: "sample spreads across range", sample: 10, wantRate: 0.1, wantZones: 10},
+		{name: "sample larger than range", sample: 500, wantRate: 1, wantZones: 10},
+		{name: "time range", tr: &TimeRange{Start: base.Add(50 * time.Second), End: base.Add(69 * time.Second)}, sample: 5, wantRate: 0.25, wantZones: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, rate, err := s.GetFieldsSampled(context.Background(), nil, tt.tr, tt.sample)
+			if err != nil {
+				t.Fatalf("GetFieldsSampled() error = %v", err)
+			}
+			if rate != tt.wantRate {
+				t.Fatalf("rate = %v, want %v", rate, tt.wantRate)
+			}
+			byName := make(map[string]FieldInfo)
+			for _, f := range fields {
+				byName[f.Name] = f
+			}
+			if got := byName["zone"].Cardinality; got != tt.wantZones {
+				t.Fatalf("zone cardinality = %d, want %d", got, tt.wantZones)
+			}
+		})
+	}
+}
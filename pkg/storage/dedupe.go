@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// StoreUnique stores entry unless an entry with the same namespace and raw
+// line was stored within window, and reports whether it was stored. Seen
+// lines are remembered under dedup:{hash} keys that expire after window, so
+// re-running a pipeline skips what it already imported. Entries without a
+// raw line, or a window <= 0, are always stored.
+func (s *BadgerStorage) StoreUnique(entry *LogEntry, window time.Duration) (bool, error) {
+	if window <= 0 || entry.Raw == "" {
+		return true, s.Store(entry)
+	}
+	if s.IngestPaused() {
+		return false, ErrIngestPaused
+	}
+
+	stored := false
+	err := s.db.Update(func(txn *badger.Txn) error {
+		var err error
+		stored, err = storeUniqueTxn(txn, entry, window)
+		return err
+	})
+	if err != nil {
+		s.noteWriteResult(err)
+		return false, fmt.Errorf("failed to store entry: %w", err)
+	}
+
+	if stored {
+		s.noteWrites(1)
+	}
+	return stored, nil
+}
+
+// StoreBatchUnique stores entries like StoreUnique, in as few transactions
+// as Badger's size limit allows, and reports which were stored. A line
+// repeated within entries is stored once.
+func (s *BadgerStorage) StoreBatchUnique(entries []*LogEntry, window time.Duration) ([]bool, error) {
+	stored := make([]bool, len(entries))
+	if window <= 0 {
+		if err := s.StoreBatch(entries); err != nil {
+			return nil, err
+		}
+		for i := range stored {
+			stored[i] = true
+		}
+		return stored, nil
+	}
+	if len(entries) == 0 {
+		return stored, nil
+	}
+	if s.IngestPaused() {
+		return nil, ErrIngestPaused
+	}
+
+	if err := s.storeUniqueChunk(entries, stored, window); err != nil {
+		s.noteWriteResult(err)
+		return nil, fmt.Errorf("failed to store batch: %w", err)
+	}
+
+	n := 0
+	for _, ok := range stored {
+		if ok {
+			n++
+		}
+	}
+	if n > 0 {
+		s.noteWrites(n)
+	}
+	return stored, nil
+}
+
+// storeUniqueChunk stores entries in one transaction, recording in stored
+// which were written. A transaction that outgrows Badger's limit is discarded
+// and each half of entries stored on its own; halves committed earlier are
+// seen by the dedupe check of later ones.
+func (s *BadgerStorage) storeUniqueChunk(entries []*LogEntry, stored []bool, window time.Duration) error {
+	err := s.db.Update(func(txn *badger.Txn) error {
+		for i, entry := range entries {
+			var err error
+			if stored[i], err = storeUniqueTxn(txn, entry, window); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, badger.ErrTxnTooBig) && len(entries) > 1 {
+		half := len(entries) / 2
+		if err := s.storeUniqueChunk(entries[:half], stored[:half], window); err != nil {
+			return err
+		}
+		return s.storeUniqueChunk(entries[half:], stored[half:], window)
+	}
+	return err
+}
+
+// storeUniqueTxn writes entry in txn unless its line was stored within
+// window, and reports whether it was written.
+func storeUniqueTxn(txn *badger.Txn, entry *LogEntry, window time.Duration) (bool, error) {
+	writes, err := entryWrites(entry)
+	if err != nil {
+		return false, err
+	}
+	if entry.Raw == "" {
+		return true, setEntries(txn, writes)
+	}
+
+	key := dedupeKey(entry)
+	item, err := txn.Get(key)
+	switch {
+	case err == nil:
+		id, err := item.ValueCopy(nil)
+		if err != nil {
+			return false, err
+		}
+		// The earlier entry may have been deleted since; only skip
+		// while it still exists.
+		if _, err := txn.Get(rawKey(string(id))); err == nil {
+			return false, nil
+		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+			return false, err
+		}
+	case !errors.Is(err, badger.ErrKeyNotFound):
+		return false, err
+	}
+
+	if err := setEntries(txn, writes); err != nil {
+		return false, err
+	}
+	return true, txn.SetEntry(badger.NewEntry(key, []byte(entry.ID)).WithTTL(window))
+}
+
+// setEntries writes every entry in txn.
+func setEntries(txn *badger.Txn, writes []*badger.Entry) error {
+	for _, e := range writes {
+		if err := txn.SetEntry(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dedupeKey returns the dedup:{hash} key for an entry's namespace and raw line.
+func dedupeKey(entry *LogEntry) []byte {
+	h := sha256.New()
+	h.Write([]byte(entry.Namespace))
+	h.Write([]byte{0})
+	h.Write([]byte(entry.Raw))
+	return []byte(dedupePrefix + hex.EncodeToString(h.Sum(nil)[:16]))
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStoreUnique(t *testing.T) {
+	s := newBehaviorStorage(t)
+	ts := time.Now().UTC()
+	entry := func(id, raw, namespace string) *LogEntry {
+		return &LogEntry{ID: id, Timestamp: ts, Level: "INFO", Message: raw, Raw: raw, Namespace: namespace}
+	}
+
+	tests := []struct {
+		name   string
+		entry  *LogEntry
+		window time.Duration
+		want   bool
+	}{
+		{name: "first line", entry: entry("a", "hello", ""), window: time.Hour, want: true},
+		{name: "same line again", entry: entry("b", "hello", ""), window: time.Hour, want: false},
+		{name: "other namespace", entry: entry("c", "hello", "team"), window: time.Hour, want: true},
+		{name: "other line", entry: entry("d", "bye", ""), window: time.Hour, want: true},
+		{name: "dedupe disabled", entry: entry("e", "hello", ""), window: 0, want: true},
+		{name: "no raw line", entry: &LogEntry{ID: "f", Timestamp: ts, Message: "hello"}, window: time.Hour, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.StoreUnique(tt.entry, tt.window)
+			if err != nil {
+				t.Fatalf("StoreUnique() error = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("StoreUnique() = %v, want %v", got, tt.want)
+			}
+			if _, err := s.GetEntry(tt.entry.ID); (err == nil) != tt.want {
+				t.Fatalf("GetEntry(%s) error = %v, want stored=%v", tt.entry.ID, err, tt.want)
+			}
+		})
+	}
+
+	// Once the earlier entry is deleted the line can be imported again.
+	if err := s.DeleteEntry("a"); err != nil {
+		t.Fatalf("DeleteEntry() error = %v", err)
+	}
+	if got, err := s.StoreUnique(entry("g", "hello", ""), time.Hour); err != nil || !got {
+		t.Fatalf("StoreUnique() after delete = %v, %v, want stored", got, err)
+	}
+}
+
+func TestStoreBatchUnique(t *testing.T) {
+	s := newBehaviorStorage(t)
+	ts := time.Now().UTC()
+	entry := func(id, raw string) *LogEntry {
+		return &LogEntry{ID: id, Timestamp: ts, Level: "INFO", Message: raw, Raw: raw}
+	}
+	if _, err := s.StoreUnique(entry("old", "seen"), time.Hour); err != nil {
+		t.Fatalf("StoreUnique() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		entries []*LogEntry
+		window  time.Duration
+		want    []bool
+	}{
+		{name: "empty", want: []bool{}},
+		{name: "mixed", entries: []*LogEntry{entry("a", "new"), entry("b", "seen"), entry("c", "new"), {ID: "d", Timestamp: ts}}, window: time.Hour, want: []bool{true, false, false, true}},
+		{name: "dedupe disabled", entries: []*LogEntry{entry("e", "seen"), entry("f", "seen")}, want: []bool{true, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.StoreBatchUnique(tt.entries, tt.window)
+			if err != nil {
+				t.Fatalf("StoreBatchUnique() error = %v", err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("StoreBatchUnique() = %v, want %v", got, tt.want)
+			}
+			for i, e := range tt.entries {
+				if _, err := s.GetEntry(e.ID); (err == nil) != tt.want[i] {
+					t.Fatalf("GetEntry(%s) error = %v, want stored=%v", e.ID, err, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStoreBatchUniqueSplitsLargeBatches(t *testing.T) {
+	// A small memtable lowers Badger's transaction limit well below the
+	// batch below.
+	s, err := NewBadgerStorage(Config{DBPath: t.TempDir(), RetentionSize: 1024 * 1024 * 100, RetentionDays: 30, Badger: BadgerTuning{MemTableSize: 8 << 20}})
+	if err != nil {
+		t.Fatalf("NewBadgerStorage() error = %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	ts := time.Now().UTC()
+	padding := strings.Repeat("x", 8192)
+	var entries []*LogEntry
+	for i := range 200 {
+		raw := fmt.Sprintf("line %d %s", i, padding)
+		entries = append(entries, &LogEntry{ID: fmt.Sprintf("e%d", i), Timestamp: ts, Level: "INFO", Message: raw, Raw: raw})
+	}
+	// The first line repeats at the end, in a later transaction.
+	entries = append(entries, &LogEntry{ID: "dup", Timestamp: ts, Level: "INFO", Message: entries[0].Raw, Raw: entries[0].Raw})
+
+	got, err := s.StoreBatchUnique(entries, time.Hour)
+	if err != nil {
+		t.Fatalf("StoreBatchUnique() error = %v", err)
+	}
+	for i, ok := range got {
+		if want := i < 200; ok != want {
+			t.Fatalf("StoreBatchUnique()[%d] = %v, want %v", i, ok, want)
+		}
+	}
+	if _, total, _ := s.Query(AllFilter{}, 1, 0); total != 200 {
+		t.Fatalf("Query() total = %d, want 200", total)
+	}
+}
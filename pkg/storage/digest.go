@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// DigestPattern is a recurring ERROR/WARN message shape with variable parts
+// (numbers, IDs, addresses, quoted values) replaced by placeholders.
+type DigestPattern struct {
+	Level     string    `json:"level"`
+	Pattern   string    `json:"pattern"`
+	Example   string    `json:"example"`
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// digestLevels are the levels summarized by GetDigest.
+var digestLevels = map[string]bool{"ERROR": true, "WARN": true}
+
+// Placeholder rules applied in order by messagePattern; earlier rules consume
+// text that later, broader rules would otherwise split up.
+var messagePatternRules = []struct {
+	re          *regexp.Regexp
+	placeholder string
+}{
+	{regexp.MustCompile(`"[^"]*"|'[^']*'`), "<str>"},
+	{regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`), "<uuid>"},
+	{regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}(?::\d+)?\b`), "<ip>"},
+	{regexp.MustCompile(`(?i)\b0x[0-9a-f]+\b|\b[0-9a-f]*\d[0-9a-f]*[a-f][0-9a-f]*\b|\b[0-9a-f]*[a-f][0-9a-f]*\d[0-9a-f]*\b`), "<hex>"},
+	{regexp.MustCompile(`\d+(?:\.\d+)?`), "<num>"},
+}
+
+// messagePattern normalizes msg so occurrences of the same log statement
+// group together regardless of the values they carry.
+func messagePattern(msg string) string {
+	for _, rule := range messagePatternRules {
+		msg = rule.re.ReplaceAllString(msg, rule.placeholder)
+	}
+	return msg
+}
+
+// GetDigest groups ERROR and WARN entries at or after since that match filter
+// (nil for all) by message pattern and returns the limit most frequent, most
+// recent first on ties.
+func (s *BadgerStorage) GetDigest(ctx context.Context, since time.Time, filter Filter, limit int) ([]DigestPattern, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	groups := make(map[string]*DigestPattern)
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte(logPrefix)
+		seekKey := prefix
+		if !since.IsZero() {
+			seekKey = logSeekKey(since.UnixNano())
+		}
+
+		visited := 0
+		for it.Seek(seekKey); it.ValidForPrefix(prefix); it.Next() {
+			visited++
+			if visited%ctxCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+
+			item := it.Item()
+			if meta := itemMeta(item); meta.LevelKnown && !digestLevels[meta.Level] {
+				continue
+			}
+
+			err := item.Value(func(val []byte) error {
+				entry, err := FromJSON(val)
+				if err != nil || !digestLevels[entry.Level] || (filter != nil && !filter.Match(entry)) {
+					return nil
+				}
+
+				pattern := messagePattern(entry.Message)
+				key := entry.Level + "\x00" + pattern
+				g, ok := groups[key]
+				if !ok {
+					g = &DigestPattern{Level: entry.Level, Pattern: pattern, Example: entry.Message, FirstSeen: entry.Timestamp}
+					groups[key] = g
+				}
+				g.Count++
+				if entry.Timestamp.Before(g.FirstSeen) {
+					g.FirstSeen = entry.Timestamp
+				}
+				if entry.Timestamp.After(g.LastSeen) {
+					g.LastSeen = entry.Timestamp
+					g.Example = entry.Message
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get digest: %w", err)
+	}
+
+	patterns := make([]DigestPattern, 0, len(groups))
+	for _, g := range groups {
+		patterns = append(patterns, *g)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		a, b := patterns[i], patterns[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if !a.LastSeen.Equal(b.LastSeen) {
+			return a.LastSeen.After(b.LastSeen)
+		}
+		return a.Pattern < b.Pattern
+	})
+	if limit > 0 && len(patterns) > limit {
+		patterns = patterns[:limit]
+	}
+	return patterns, nil
+}
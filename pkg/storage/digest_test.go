@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMessagePattern(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want string
+	}{
+		{msg: "timeout after 350ms", want: "timeout after <num>ms"},
+		{msg: "user 42 not found", want: "user <num> not found"},
+		{msg: "connect to 10.0.0.12:5432 refused", want: "connect to <ip> refused"},
+		{msg: "request 3f2b8c1e-9d4a-4f6b-8a2e-1c5d7e9f0a1b failed", want: "request <uuid> failed"},
+		{msg: "bad object 0x1f3a at deadbeef42", want: "bad object <hex> at <hex>"},
+		{msg: `missing key "tenant_7"`, want: "missing key <str>"},
+		{msg: "disk full", want: "disk full"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			if got := messagePattern(tt.msg); got != tt.want {
+				t.Fatalf("messagePattern(%q) = %q, want %q", tt.msg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetDigest(t *testing.T) {
+	s := newBehaviorStorage(t)
+	now := time.Now().UTC()
+
+	addEntry(t, s, "old", now.Add(-2*time.Hour), "ERROR", nil)
+	for i, msg := range []string{"timeout after 100ms", "timeout after 250ms", "timeout after 9ms"} {
+		if err := s.Store(&LogEntry{ID: "t" + string(rune('a'+i)), Timestamp: now.Add(time.Duration(i-30) * time.Minute), Level: "ERROR", Message: msg}); err != nil {
+			t.Fatalf("Store() error = %v", err)
+		}
+	}
+	for i, msg := range []string{"retrying job 1", "retrying job 2"} {
+		if err := s.Store(&LogEntry{ID: "w" + string(rune('a'+i)), Timestamp: now.Add(time.Duration(i-10) * time.Minute), Level: "WARN", Message: msg}); err != nil {
+			t.Fatalf("Store() error = %v", err)
+		}
+	}
+	if err := s.Store(&LogEntry{ID: "info", Timestamp: now.Add(-time.Minute), Level: "INFO", Message: "started 3 workers"}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	patterns, err := s.GetDigest(context.Background(), now.Add(-time.Hour), nil, 10)
+	if err != nil {
+		t.Fatalf("GetDigest() error = %v", err)
+	}
+	if len(patterns) != 2 {
+		t.Fatalf("GetDigest() = %+v, want 2 patterns", patterns)
+	}
+	top := patterns[0]
+	if top.Level != "ERROR" || top.Pattern != "timeout after <num>ms" || top.Count != 3 || top.Example != "timeout after 9ms" {
+		t.Fatalf("top pattern = %+v", top)
+	}
+	if !top.FirstSeen.Equal(now.Add(-30*time.Minute)) || !top.LastSeen.Equal(now.Add(-28*time.Minute)) {
+		t.Fatalf("top pattern seen range = %s..%s", top.FirstSeen, top.LastSeen)
+	}
+	if patterns[1].Level != "WARN" || patterns[1].Count != 2 {
+		t.Fatalf("second pattern = %+v", patterns[1])
+	}
+
+	if limited, err := s.GetDigest(context.Background(), now.Add(-time.Hour), nil, 1); err != nil || len(limited) != 1 {
+		t.Fatalf("GetDigest(limit 1) = %+v, %v", limited, err)
+	}
+	if all, err := s.GetDigest(context.Background(), time.Time{}, nil, 0); err != nil || len(all) != 3 {
+		t.Fatalf("GetDigest(all) = %+v, %v, want old entry included", all, err)
+	}
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrIngestPaused is returned by the Store methods while the filesystem
+// holding the database has less free space than Config.MinFreeBytes.
+// Nothing is written; storing resumes by itself once space frees up.
+var ErrIngestPaused = errors.New("ingestion paused: low disk space")
+
+// diskCheckInterval is how often the disk guard re-reads free space.
+const diskCheckInterval = 5 * time.Second
+
+// diskGuard pauses storing while free space on the database filesystem is
+// below min.
+type diskGuard struct {
+	paused atomic.Bool
+
+	mu    sync.Mutex
+	path  string
+	min   int64
+	free  int64 // -1 until read, or when the platform can't report it
+	since time.Time
+}
+
+// DiskHealth describes the free-space guard.
+type DiskHealth struct {
+	// Enabled is true when a minimum free space is configured.
+	Enabled      bool  `json:"enabled"`
+	MinFreeBytes int64 `json:"min_free_bytes"`
+	// FreeBytes is the free space last read, or -1 when it is unknown.
+	FreeBytes int64 `json:"free_bytes"`
+	// Paused is true while entries are not stored for lack of space.
+	Paused      bool       `json:"paused"`
+	PausedSince *time.Time `json:"paused_since,omitempty"`
+}
+
+// checkDisk re-reads free space and pauses or resumes storing. Storing is
+// never paused while free space can't be read.
+func (s *BadgerStorage) checkDisk() {
+	g := &s.disk
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.min <= 0 {
+		return
+	}
+	free, ok := diskFree(g.path)
+	if !ok {
+		g.free = -1
+		g.paused.Store(false)
+		return
+	}
+	g.free = free
+	low := free < g.min
+	if low && !g.paused.Load() {
+		g.since = time.Now()
+	}
+	g.paused.Store(low)
+}
+
+// diskWorker re-checks free space every diskCheckInterval until Close.
+func (s *BadgerStorage) diskWorker() {
+	defer s.workers.Done()
+	ticker := time.NewTicker(diskCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.checkDisk()
+		case <-s.doneChan:
+			return
+		}
+	}
+}
+
+// IngestPaused reports whether storing is paused for lack of disk space.
+func (s *BadgerStorage) IngestPaused() bool {
+	return s.disk.paused.Load()
+}
+
+// diskHealth reports the guard's state for Health.
+func (s *BadgerStorage) diskHealth() DiskHealth {
+	g := &s.disk
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	h := DiskHealth{Enabled: g.min > 0, MinFreeBytes: g.min, FreeBytes: g.free, Paused: g.paused.Load()}
+	if h.Paused {
+		t := g.since
+		h.PausedSince = &t
+	}
+	return h
+}
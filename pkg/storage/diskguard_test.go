@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDiskGuardPausesStoring(t *testing.T) {
+	// No filesystem has this much free space, so the guard pauses on open.
+	s, err := NewBadgerStorage(Config{DBPath: t.TempDir(), MinFreeBytes: 1 << 62})
+	if err != nil {
+		t.Fatalf("NewBadgerStorage() error = %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	entry := &LogEntry{ID: "a", Timestamp: time.Now(), Message: "m", Raw: "m"}
+	if !s.IngestPaused() {
+		t.Fatalf("IngestPaused() = false with the minimum above any free space")
+	}
+	if err := s.Store(entry); !errors.Is(err, ErrIngestPaused) {
+		t.Fatalf("Store() error = %v, want ErrIngestPaused", err)
+	}
+	if err := s.StoreBatch([]*LogEntry{entry}); !errors.Is(err, ErrIngestPaused) {
+		t.Fatalf("StoreBatch() error = %v, want ErrIngestPaused", err)
+	}
+	if _, err := s.StoreUnique(entry, time.Hour); !errors.Is(err, ErrIngestPaused) {
+		t.Fatalf("StoreUnique() error = %v, want ErrIngestPaused", err)
+	}
+	if _, err := s.StoreBatchUnique([]*LogEntry{entry}, time.Hour); !errors.Is(err, ErrIngestPaused) {
+		t.Fatalf("StoreBatchUnique() error = %v, want ErrIngestPaused", err)
+	}
+	h := s.Health()
+	if !h.Disk.Enabled || !h.Disk.Paused || h.Disk.PausedSince == nil || h.Disk.FreeBytes <= 0 || !h.Writable {
+		t.Fatalf("Health().Disk = %+v, writable %v; want enabled, paused with free space read, storage still writable", h.Disk, h.Writable)
+	}
+
+	// Space frees up: the next check resumes storing.
+	s.disk.mu.Lock()
+	s.disk.min = 1
+	s.disk.mu.Unlock()
+	s.checkDisk()
+	if s.IngestPaused() {
+		t.Fatalf("IngestPaused() = true after space freed up")
+	}
+	if err := s.Store(entry); err != nil {
+		t.Fatalf("Store() after resume error = %v", err)
+	}
+	if h := s.Health().Disk; h.Paused || h.PausedSince != nil {
+		t.Fatalf("Health().Disk after resume = %+v", h)
+	}
+}
+
+func TestDiskGuardDisabled(t *testing.T) {
+	s := newBehaviorStorage(t)
+	if h := s.Health().Disk; h.Enabled || h.Paused || h.FreeBytes != -1 {
+		t.Fatalf("Health().Disk = %+v, want disabled with unknown free space", h)
+	}
+	addEntry(t, s, "a", time.Now(), "INFO", nil)
+}
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// EntrySize is the stored footprint of one entry and the parts that make it
+// up, largest first.
+type EntrySize struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Level     string    `json:"level"`
+	// Message is the start of the entry's message.
+	Message string `json:"message"`
+	// Bytes counts the stored entry and its raw line, keys included.
+	Bytes    int64      `json:"bytes"`
+	RawBytes int64      `json:"raw_bytes"`
+	Parts    []PartSize `json:"parts"`
+}
+
+// PartSize is the encoded size of one part of an entry: "message", "raw",
+// or a field name.
+type PartSize struct {
+	Name  string `json:"name"`
+	Bytes int64  `json:"bytes"`
+}
+
+const (
+	// sizePreviewLength bounds EntrySize.Message, in runes.
+	sizePreviewLength = 120
+	// maxSizeParts bounds EntrySize.Parts.
+	maxSizeParts = 5
+)
+
+// GetLargestEntries returns the n largest entries within tr (nil for all
+// time) that match filter (nil for all), largest first. Entry sizes come from
+// key metadata; only entries that could make the list are decoded.
+func (s *BadgerStorage) GetLargestEntries(ctx context.Context, filter Filter, tr *TimeRange, n int) ([]EntrySize, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type candidate struct {
+		entry          *LogEntry
+		bytes, rawSize int64
+	}
+	var top []candidate // sorted by bytes, largest first
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		r := newKeyRange(tr)
+		prefix := []byte(logPrefix)
+		seekKey := prefix
+		if r.start != 0 {
+			seekKey = logSeekKey(r.start)
+		}
+		visited := 0
+		for it.Seek(seekKey); it.ValidForPrefix(prefix); it.Next() {
+			visited++
+			if visited%ctxCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+
+			item := it.Item()
+			ts, ok := keyTimestamp(item.Key())
+			if !ok || (r.start != 0 && ts < r.start) {
+				continue
+			}
+			if r.end != 0 && ts > r.end {
+				break
+			}
+			id, _ := keyID(item.Key())
+
+			var rawSize int64
+			raw, err := txn.Get(rawKey(id))
+			if err == nil {
+				rawSize = raw.EstimatedSize()
+			} else if !errors.Is(err, badger.ErrKeyNotFound) {
+				return err
+			}
+			size := item.EstimatedSize() + rawSize
+			if len(top) == n && size <= top[n-1].bytes {
+				continue
+			}
+
+			var entry *LogEntry
+			if err := item.Value(func(val []byte) error {
+				entry, err = FromJSON(val)
+				return err
+			}); err != nil {
+				continue // Verify reports undecodable entries
+			}
+			if filter != nil && !filter.Match(entry) {
+				continue
+			}
+
+			i := sort.Search(len(top), func(i int) bool { return top[i].bytes < size })
+			top = append(top, candidate{})
+			copy(top[i+1:], top[i:])
+			top[i] = candidate{entry: entry, bytes: size, rawSize: rawSize}
+			if len(top) > n {
+				top = top[:n]
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find largest entries: %w", err)
+	}
+
+	out := make([]EntrySize, len(top))
+	for i, c := range top {
+		out[i] = EntrySize{
+			ID:        c.entry.ID,
+			Timestamp: c.entry.Timestamp,
+			Level:     c.entry.Level,
+			Message:   preview(c.entry.Message, sizePreviewLength),
+			Bytes:     c.bytes,
+			RawBytes:  c.rawSize,
+			Parts:     entryParts(c.entry, c.rawSize),
+		}
+	}
+	return out, nil
+}
+
+// entryParts returns the largest encoded parts of entry, with rawSize as the
+// size of its raw line.
+func entryParts(entry *LogEntry, rawSize int64) []PartSize {
+	parts := []PartSize{{Name: "message", Bytes: encodedSize(entry.Message)}}
+	if rawSize > 0 {
+		parts = append(parts, PartSize{Name: "raw", Bytes: rawSize})
+	}
+	for k, v := range entry.Fields {
+		parts = append(parts, PartSize{Name: k, Bytes: encodedSize(k) + encodedSize(v) + 1})
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		if parts[i].Bytes != parts[j].Bytes {
+			return parts[i].Bytes > parts[j].Bytes
+		}
+		return parts[i].Name < parts[j].Name
+	})
+	if len(parts) > maxSizeParts {
+		parts = parts[:maxSizeParts]
+	}
+	return parts
+}
+
+// encodedSize returns the length of v encoded as JSON.
+func encodedSize(v interface{}) int64 {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return int64(len(data))
+}
+
+// preview returns s cut to at most n runes, marking a cut with "…".
+func preview(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
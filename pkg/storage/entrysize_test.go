@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// levelOnly matches entries of one level.
+type levelOnly string
+
+func (f levelOnly) Match(entry *LogEntry) bool { return entry.Level == string(f) }
+
+func TestGetLargestEntries(t *testing.T) {
+	s := newBehaviorStorage(t)
+	base := time.Now().UTC().Add(-time.Hour)
+
+	trace := strings.Repeat("at com.example.Handler.run(Handler.java:42)\n", 200)
+	payload := strings.Repeat("x", 4000)
+	addEntry(t, s, "small", base, "INFO", map[string]interface{}{"service": "api"})
+	addEntry(t, s, "stack", base.Add(time.Minute), "ERROR", map[string]interface{}{"stack": trace, "service": "api"})
+	addEntry(t, s, "payload", base.Add(2*time.Minute), "INFO", map[string]interface{}{"body": payload})
+	if err := s.Store(&LogEntry{ID: "rawbig", Timestamp: base.Add(3 * time.Minute), Level: "INFO", Message: "short", Raw: strings.Repeat("r", 2000)}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	ids := func(entries []EntrySize) string {
+		out := make([]string, len(entries))
+		for i, e := range entries {
+			out[i] = e.ID
+		}
+		return strings.Join(out, ",")
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		tr     *TimeRange
+		n      int
+		want   string
+	}{
+		{name: "all", n: 10, want: "stack,payload,rawbig,small"},
+		{name: "top two", n: 2, want: "stack,payload"},
+		{name: "filter", filter: levelOnly("INFO"), n: 2, want: "payload,rawbig"},
+		{name: "time range", tr: &TimeRange{Start: base.Add(90 * time.Second)}, n: 10, want: "payload,rawbig"},
+		{name: "none", n: 0, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.GetLargestEntries(context.Background(), tt.filter, tt.tr, tt.n)
+			if err != nil {
+				t.Fatalf("GetLargestEntries() error = %v", err)
+			}
+			if ids(got) != tt.want {
+				t.Fatalf("GetLargestEntries() = %s, want %s", ids(got), tt.want)
+			}
+		})
+	}
+
+	got, err := s.GetLargestEntries(context.Background(), nil, nil, 3)
+	if err != nil {
+		t.Fatalf("GetLargestEntries() error = %v", err)
+	}
+	if p := got[0].Parts[0]; p.Name != "stack" || p.Bytes < int64(len(trace)) {
+		t.Fatalf("largest part of stack entry = %+v, want the stack field", p)
+	}
+	if got[0].Bytes <= got[1].Bytes || got[0].RawBytes == 0 {
+		t.Fatalf("sizes = %d/%d raw %d, want descending with raw counted", got[0].Bytes, got[1].Bytes, got[0].RawBytes)
+	}
+	if p := got[2].Parts[0]; got[2].ID != "rawbig" || p.Name != "raw" {
+		t.Fatalf("largest part of rawbig = %+v, want raw", got[2].Parts)
+	}
+}
+
+func TestPreview(t *testing.T) {
+	if got := preview("héllo", 10); got != "héllo" {
+		t.Fatalf("preview() = %q", got)
+	}
+	if got := preview("héllo wörld", 5); got != "héllo…" {
+		t.Fatalf("preview() = %q, want cut on a rune boundary", got)
+	}
+}
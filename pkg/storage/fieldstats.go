@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// FieldStats summarizes the numeric values of a field across matching entries.
+type FieldStats struct {
+	Field string `json:"field"`
+	// Count is the number of matching entries with a numeric value.
+	Count int `json:"count"`
+	// Missing counts matching entries where the field is absent or not numeric.
+	Missing int     `json:"missing"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	Avg     float64 `json:"avg"`
+	P50     float64 `json:"p50"`
+	P95     float64 `json:"p95"`
+}
+
+// GetFieldStats computes min/max/avg/p50/p95 of field over entries matching
+// filter within tr. Numeric strings (as produced by logfmt) are included.
+func (s *BadgerStorage) GetFieldStats(ctx context.Context, field string, filter Filter, tr *TimeRange) (FieldStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := FieldStats{Field: field}
+	var values []float64
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		r := newKeyRange(tr)
+
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = true
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte(logPrefix)
+		seekKey := prefix
+		if r.start != 0 {
+			seekKey = logSeekKey(r.start)
+		}
+
+		metaFilter, _ := filter.(MetaFilter)
+
+		visited := 0
+		for it.Seek(seekKey); it.ValidForPrefix(prefix); it.Next() {
+			visited++
+			if visited%ctxCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+
+			item := it.Item()
+			if r.end != 0 {
+				if ts, ok := keyTimestamp(item.Key()); ok && ts > r.end {
+					break
+				}
+			}
+			if metaFilter != nil {
+				if match, certain := metaFilter.MatchMeta(itemMeta(item)); certain && !match {
+					continue
+				}
+			}
+
+			err := item.Value(func(val []byte) error {
+				entry, err := FromJSON(val)
+				if err != nil || !filter.Match(entry) {
+					return nil
+				}
+				if v, ok := numericValue(entry.Fields[field]); ok {
+					values = append(values, v)
+				} else {
+					stats.Missing++
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("field stats %s: %w", field, err)
+	}
+
+	if len(values) == 0 {
+		return stats, nil
+	}
+
+	sort.Float64s(values)
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	stats.Count = len(values)
+	stats.Min = values[0]
+	stats.Max = values[len(values)-1]
+	stats.Avg = sum / float64(len(values))
+	stats.P50 = percentile(values, 50)
+	stats.P95 = percentile(values, 95)
+
+	return stats, nil
+}
+
+// numericValue converts a decoded field value to float64 when it is a number
+// or a string holding one.
+func numericValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return 0, false
+		}
+		return f, true
+	default:
+		return 0, false
+	}
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
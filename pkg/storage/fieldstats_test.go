@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetFieldStats(t *testing.T) {
+	s := newBehaviorStorage(t)
+	base := time.Now().UTC().Add(-time.Hour)
+	for i := 1; i <= 20; i++ {
+		addEntry(t, s, "lat"+string(rune('a'+i)), base.Add(time.Duration(i)*time.Second), "INFO", map[string]interface{}{"latency_ms": float64(i * 10)})
+	}
+	addEntry(t, s, "str", base.Add(30*time.Second), "INFO", map[string]interface{}{"latency_ms": "5"})
+	addEntry(t, s, "bad", base.Add(31*time.Second), "INFO", map[string]interface{}{"latency_ms": "slow"})
+	addEntry(t, s, "none", base.Add(32*time.Second), "INFO", nil)
+	addEntry(t, s, "err", base.Add(33*time.Second), "ERROR", map[string]interface{}{"latency_ms": float64(9999)})
+
+	tests := []struct {
+		name   string
+		filter Filter
+		tr     *TimeRange
+		want   FieldStats
+	}{
+		{
+			name:   "level filter",
+			filter: LevelFilter{Level: "INFO"},
+			want:   FieldStats{Field: "latency_ms", Count: 21, Missing: 2, Min: 5, Max: 200, Avg: 2105.0 / 21, P50: 100, P95: 190},
+		},
+		{
+			name:   "time range",
+			filter: AllFilter{},
+			tr:     &TimeRange{Start: base.Add(time.Second), End: base.Add(2 * time.Second)},
+			want:   FieldStats{Field: "latency_ms", Count: 2, Min: 10, Max: 20, Avg: 15, P50: 10, P95: 20},
+		},
+		{
+			name:   "no values",
+			filter: LevelFilter{Level: "DEBUG"},
+			want:   FieldStats{Field: "latency_ms"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.GetFieldStats(context.Background(), "latency_ms", tt.filter, tt.tr)
+			if err != nil {
+				t.Fatalf("GetFieldStats() error = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("GetFieldStats() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < ctxCheckInterval; i++ {
+		addEntry(t, s, "fill"+time.Duration(i).String(), base.Add(time.Minute+time.Duration(i)), "INFO", nil)
+	}
+	if _, err := s.GetFieldStats(ctx, "latency_ms", AllFilter{}, nil); err == nil {
+		t.Fatalf("GetFieldStats() with cancelled context error = nil")
+	}
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Field types reported in FieldInfo.Type.
+const (
+	FieldTypeString    = "string"
+	FieldTypeNumber    = "number"
+	FieldTypeBool      = "bool"
+	FieldTypeDuration  = "duration"
+	FieldTypeIP        = "ip"
+	FieldTypeTimestamp = "timestamp"
+)
+
+// builtinFieldTypes are the types of LogEntry's top-level fields.
+var builtinFieldTypes = map[string]string{
+	"level":     FieldTypeString,
+	"message":   FieldTypeString,
+	"timestamp": FieldTypeTimestamp,
+}
+
+// inferFieldType returns the type shared by all observed values, or
+// FieldTypeString when they disagree or no values were seen.
+func inferFieldType(values map[string]int) string {
+	fieldType := ""
+	for v := range values {
+		t := inferValueType(v)
+		if fieldType == "" {
+			fieldType = t
+		} else if t != fieldType {
+			return FieldTypeString
+		}
+	}
+	if fieldType == "" {
+		return FieldTypeString
+	}
+	return fieldType
+}
+
+// inferValueType classifies a single formatted field value.
+func inferValueType(v string) string {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return FieldTypeString
+	}
+	if v == "true" || v == "false" {
+		return FieldTypeBool
+	}
+	if f, err := strconv.ParseFloat(v, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+		return FieldTypeNumber
+	}
+	if _, err := time.ParseDuration(v); err == nil {
+		return FieldTypeDuration
+	}
+	if net.ParseIP(v) != nil {
+		return FieldTypeIP
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05"} {
+		if _, err := time.Parse(layout, v); err == nil {
+			return FieldTypeTimestamp
+		}
+	}
+	return FieldTypeString
+}
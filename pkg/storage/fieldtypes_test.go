@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInferValueType(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"42", FieldTypeNumber},
+		{"-3.5", FieldTypeNumber},
+		{"1.5e+06", FieldTypeNumber},
+		{"NaN", FieldTypeString},
+		{"true", FieldTypeBool},
+		{"false", FieldTypeBool},
+		{"150ms", FieldTypeDuration},
+		{"1h30m", FieldTypeDuration},
+		{"10.0.0.1", FieldTypeIP},
+		{"::1", FieldTypeIP},
+		{"2026-02-17T10:30:45Z", FieldTypeTimestamp},
+		{"2026-02-17T10:30:45.123+02:00", FieldTypeTimestamp},
+		{"2026-02-17 10:30:45", FieldTypeTimestamp},
+		{"api", FieldTypeString},
+		{"", FieldTypeString},
+	}
+	for _, tt := range tests {
+		if got := inferValueType(tt.in); got != tt.want {
+			t.Errorf("inferValueType(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestGetFieldsInfersTypes(t *testing.T) {
+	s := newBehaviorStorage(t)
+	base := time.Now().UTC().Add(-time.Hour)
+	addEntry(t, s, "a", base, "INFO", map[string]interface{}{
+		"status": float64(200), "ok": true, "took": "12ms", "client": "10.0.0.1", "at": "2026-02-17T10:30:45Z", "mixed": float64(1), "service": "api",
+	})
+	addEntry(t, s, "b", base.Add(time.Second), "ERROR", map[string]interface{}{
+		"status": "500", "ok": false, "took": "1.5s", "client": "10.0.0.2", "at": "2026-02-17T10:31:00Z", "mixed": "n/a", "service": "worker",
+	})
+
+	fields, err := s.GetFields(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("GetFields() error = %v", err)
+	}
+
+	want := map[string]string{
+		"level": FieldTypeString, "message": FieldTypeString, "timestamp": FieldTypeTimestamp,
+		"status": FieldTypeNumber, "ok": FieldTypeBool, "took": FieldTypeDuration, "client": FieldTypeIP,
+		"at": FieldTypeTimestamp, "mixed": FieldTypeString, "service": FieldTypeString,
+	}
+	got := make(map[string]string, len(fields))
+	for _, f := range fields {
+		got[f.Name] = f.Type
+	}
+	for name, typ := range want {
+		if got[name] != typ {
+			t.Errorf("field %s type = %q, want %q", name, got[name], typ)
+		}
+	}
+}
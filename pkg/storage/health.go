@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// StorageHealth reports whether storage can serve reads and accept writes,
+// and how the retention worker is doing.
+type StorageHealth struct {
+	Open bool `json:"open"`
+	// Writable is false while the most recent write failed.
+	Writable    bool            `json:"writable"`
+	LastWrite   *time.Time      `json:"last_write,omitempty"`
+	Retention   RetentionHealth `json:"retention"`
+	Disk        DiskHealth      `json:"disk"`
+	LastError   string          `json:"last_error,omitempty"`
+	LastErrorAt *time.Time      `json:"last_error_at,omitempty"`
+}
+
+// RetentionHealth describes the background retention worker.
+type RetentionHealth struct {
+	// Enabled is true when a size or age limit is configured.
+	Enabled bool `json:"enabled"`
+	// Running is true while the worker goroutine is alive.
+	Running   bool       `json:"running"`
+	Sweeps    int        `json:"sweeps"`
+	LastSweep *time.Time `json:"last_sweep,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+	// MaintenanceWindow is the daily window of size-cap sweeps and full
+	// compaction, as HH:MM-HH:MM local time; empty when they run on writes.
+	MaintenanceWindow string     `json:"maintenance_window,omitempty"`
+	LastMaintenance   *time.Time `json:"last_maintenance,omitempty"`
+}
+
+// healthState records the outcome of writes and retention sweeps.
+type healthState struct {
+	mu          sync.Mutex
+	lastWrite   time.Time
+	writeFailed bool
+	sweeps      int
+	lastSweep   time.Time
+	// lastMaintenance is when a maintenance run last finished.
+	lastMaintenance time.Time
+	sweepErr        string
+	lastErr         string
+	lastErrAt       time.Time
+}
+
+// noteWriteResult records the outcome of a write of log entries.
+func (s *BadgerStorage) noteWriteResult(err error) {
+	h := &s.health
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	if err != nil {
+		h.writeFailed = true
+		h.lastErr, h.lastErrAt = err.Error(), now
+		return
+	}
+	h.writeFailed = false
+	h.lastWrite = now
+}
+
+// noteSweep records the outcome of a retention sweep.
+func (s *BadgerStorage) noteSweep(err error) {
+	h := &s.health
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	h.sweeps++
+	h.lastSweep = now
+	h.sweepErr = ""
+	if err != nil {
+		h.sweepErr = err.Error()
+		h.lastErr, h.lastErrAt = "retention: "+err.Error(), now
+	}
+}
+
+// Health reports the current storage health. It never touches the disk, so
+// it is cheap enough for frequent polling.
+func (s *BadgerStorage) Health() StorageHealth {
+	open := !s.db.IsClosed()
+	running := false
+	select {
+	case <-s.doneChan:
+	default:
+		running = open
+	}
+
+	h := &s.health
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	health := StorageHealth{
+		Open:     open,
+		Writable: open && !h.writeFailed,
+		Retention: RetentionHealth{
+			Enabled:   s.retentionSize > 0 || s.retentionDays > 0,
+			Running:   running,
+			Sweeps:    h.sweeps,
+			LastError: h.sweepErr,
+		},
+		Disk:      s.diskHealth(),
+		LastError: h.lastErr,
+	}
+	if !h.lastWrite.IsZero() {
+		t := h.lastWrite
+		health.LastWrite = &t
+	}
+	if !h.lastSweep.IsZero() {
+		t := h.lastSweep
+		health.Retention.LastSweep = &t
+	}
+	if s.window != nil {
+		health.Retention.MaintenanceWindow = s.window.String()
+	}
+	if !h.lastMaintenance.IsZero() {
+		t := h.lastMaintenance
+		health.Retention.LastMaintenance = &t
+	}
+	if !h.lastErrAt.IsZero() {
+		t := h.lastErrAt
+		health.LastErrorAt = &t
+	}
+	return health
+}
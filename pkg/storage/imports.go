@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// ImportCheckpoint records how far an unfinished peek import got through a
+// file, so --resume can continue after the last stored batch.
+type ImportCheckpoint struct {
+	// Path is the absolute path of the imported file.
+	Path string `json:"path"`
+	// Offset is the byte offset of the first line not yet stored, and Line
+	// the number of lines before it.
+	Offset int64 `json:"offset"`
+	Line   int   `json:"line"`
+	// Stored counts the entries stored so far.
+	Stored int `json:"stored"`
+	// HeadHash is the SHA-256 of the file's first HeadSize bytes, to tell
+	// whether the file on disk is still the one being imported.
+	HeadHash string `json:"head_hash"`
+	HeadSize int64  `json:"head_size"`
+	// Session is the session the import's entries are tagged with.
+	Session   string    `json:"session"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SaveImportCheckpoint creates or replaces the checkpoint of cp.Path.
+func (s *BadgerStorage) SaveImportCheckpoint(cp *ImportCheckpoint) error {
+	cp.UpdatedAt = time.Now().UTC()
+	if err := s.putRecord(importKey(cp.Path), cp); err != nil {
+		return fmt.Errorf("save import checkpoint %s: %w", cp.Path, err)
+	}
+	return nil
+}
+
+// GetImportCheckpoint returns the checkpoint of the file at path or
+// ErrNotFound.
+func (s *BadgerStorage) GetImportCheckpoint(path string) (*ImportCheckpoint, error) {
+	var cp ImportCheckpoint
+	if err := s.getRecord(importKey(path), &cp); err != nil {
+		return nil, fmt.Errorf("get import checkpoint %s: %w", path, err)
+	}
+	return &cp, nil
+}
+
+// ListImportCheckpoints returns the checkpoints of unfinished imports sorted
+// by path.
+func (s *BadgerStorage) ListImportCheckpoints() ([]ImportCheckpoint, error) {
+	cps, err := listRecords[ImportCheckpoint](s, importPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("list import checkpoints: %w", err)
+	}
+	return cps, nil
+}
+
+// DeleteImportCheckpoint removes the checkpoint of the file at path or
+// returns ErrNotFound.
+func (s *BadgerStorage) DeleteImportCheckpoint(path string) error {
+	if err := s.deleteRecord(importKey(path)); err != nil {
+		return fmt.Errorf("delete import checkpoint %s: %w", path, err)
+	}
+	return nil
+}
+
+// importKey returns the key holding the checkpoint of the file at path.
+func importKey(path string) []byte {
+	return []byte(importPrefix + path)
+}
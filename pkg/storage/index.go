@@ -0,0 +1,334 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Secondary indexes map a field value to its entries:
+// {prefix}{value}:{ts_nano}:{id}, with an empty value. Keys of one value sort
+// chronologically, so a query pinned to a value reads just those entries in
+// log order. Retention drops log keys without decoding them, so index keys of
+// deleted entries are pruned afterwards by timestamp (pruneIndexes); lookups
+// skip any key whose entry is gone.
+
+// secondaryIndex describes one indexed field.
+type secondaryIndex struct {
+	// field is the query field the index answers.
+	field  string
+	prefix string
+	// marker records that entries stored before the index existed have been
+	// indexed.
+	marker string
+	value  func(entry *LogEntry) string
+}
+
+var secondaryIndexes = []secondaryIndex{
+	{field: "trace_id", prefix: tracePrefix, marker: "index:trace", value: func(e *LogEntry) string {
+		traceID, _ := e.TraceContext()
+		return traceID
+	}},
+	{field: "source", prefix: sourcePrefix, marker: "index:source", value: (*LogEntry).SourceName},
+}
+
+// maxIndexValueLength bounds indexed values; longer ones are matched by scan.
+const maxIndexValueLength = 128
+
+// IndexFilter is implemented by filters that only match entries whose field
+// has a single value. QueryContext then reads that field's index instead of
+// scanning.
+type IndexFilter interface {
+	IndexLookup() (field, value string, ok bool)
+}
+
+// IndexedField reports whether field has a secondary index.
+func IndexedField(field string) bool {
+	_, ok := indexFor(field)
+	return ok
+}
+
+// indexFor returns the secondary index of field.
+func indexFor(field string) (secondaryIndex, bool) {
+	for _, idx := range secondaryIndexes {
+		if idx.field == field {
+			return idx, true
+		}
+	}
+	return secondaryIndex{}, false
+}
+
+// indexValueEscaper keeps ':' out of index values so keys split unambiguously.
+var indexValueEscaper = strings.NewReplacer("%", "%25", ":", "%3A")
+
+// indexable reports whether value can be stored in an index key.
+func indexable(value string) bool {
+	return value != "" && len(value) <= maxIndexValueLength
+}
+
+// key returns the index key of the entry with the given value, timestamp
+// and ID.
+func (idx secondaryIndex) key(value string, ts int64, id string) []byte {
+	return fmt.Appendf(nil, "%s%s:%d:%s", idx.prefix, indexValueEscaper.Replace(value), ts, id)
+}
+
+// valuePrefix returns the prefix shared by all index keys of value.
+func (idx secondaryIndex) valuePrefix(value string) []byte {
+	return []byte(idx.prefix + indexValueEscaper.Replace(value) + ":")
+}
+
+// indexEntryWrites returns the index keys written for entry.
+func indexEntryWrites(entry *LogEntry) []*badger.Entry {
+	var writes []*badger.Entry
+	for _, idx := range secondaryIndexes {
+		if v := idx.value(entry); indexable(v) {
+			writes = append(writes, badger.NewEntry(idx.key(v, entry.Timestamp.UnixNano(), entry.ID), nil))
+		}
+	}
+	return writes
+}
+
+// indexKeyParts extracts the timestamp and entry ID from an index key.
+// Entry IDs never contain ':', so the key splits from the end.
+func indexKeyParts(key []byte) (ts int64, id string, ok bool) {
+	i := bytes.LastIndexByte(key, ':')
+	if i < 0 {
+		return 0, "", false
+	}
+	j := bytes.LastIndexByte(key[:i], ':')
+	if j < 0 {
+		return 0, "", false
+	}
+	ts, err := strconv.ParseInt(string(key[j+1:i]), 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return ts, string(key[i+1:]), true
+}
+
+// buildIndexes indexes stored entries for every index whose marker is
+// missing, once per database.
+func (s *BadgerStorage) buildIndexes() error {
+	var missing []secondaryIndex
+	err := s.db.View(func(txn *badger.Txn) error {
+		for _, idx := range secondaryIndexes {
+			_, err := txn.Get([]byte(idx.marker))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				missing = append(missing, idx)
+			} else if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil || len(missing) == 0 {
+		if err != nil {
+			return fmt.Errorf("failed to build indexes: %w", err)
+		}
+		return nil
+	}
+
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+	err = s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte(logPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var entry *LogEntry
+			if err := it.Item().Value(func(val []byte) error {
+				var err error
+				entry, err = FromJSON(val)
+				return err
+			}); err != nil {
+				continue // Verify reports undecodable entries
+			}
+			for _, idx := range missing {
+				if v := idx.value(entry); indexable(v) {
+					if err := wb.Set(idx.key(v, entry.Timestamp.UnixNano(), entry.ID), nil); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+	for _, idx := range missing {
+		if err == nil {
+			err = wb.Set([]byte(idx.marker), nil)
+		}
+	}
+	if err == nil {
+		err = wb.Flush()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to build indexes: %w", err)
+	}
+	return nil
+}
+
+// idIndexMarker records that entries stored before id:{id} keys existed
+// have been given one.
+const idIndexMarker = "index:id"
+
+// buildIDIndex writes the id:{id} key of every stored entry, once per
+// database.
+func (s *BadgerStorage) buildIDIndex() error {
+	err := s.db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get([]byte(idIndexMarker))
+		return err
+	})
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, badger.ErrKeyNotFound) {
+		return fmt.Errorf("failed to build id index: %w", err)
+	}
+
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+	err = s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte(logPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if id, ok := keyID(it.Item().Key()); ok {
+				if err := wb.Set(idKey(id), it.Item().KeyCopy(nil)); err != nil {
+					return err
+				}
+			}
+		}
+		return wb.Set([]byte(idIndexMarker), nil)
+	})
+	if err == nil {
+		err = wb.Flush()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to build id index: %w", err)
+	}
+	return nil
+}
+
+// pruneIndexes deletes index keys older than the oldest stored entry, left
+// behind by retention and DeleteOlderThan. It returns how many keys it
+// removed.
+func (s *BadgerStorage) pruneIndexes() (int, error) {
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	n := 0
+	err := s.db.View(func(txn *badger.Txn) error {
+		oldest, _, haveEntries := keyTimestampBounds(txn)
+
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for _, idx := range secondaryIndexes {
+			prefix := []byte(idx.prefix)
+			for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+				ts, _, ok := indexKeyParts(it.Item().Key())
+				if ok && haveEntries && ts >= oldest {
+					continue
+				}
+				if err := wb.Delete(it.Item().KeyCopy(nil)); err != nil {
+					return err
+				}
+				n++
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		err = wb.Flush()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune indexes: %w", err)
+	}
+	return n, nil
+}
+
+// indexedQuery returns the index and value filter is pinned to, if the
+// value is indexable.
+func indexedQuery(filter Filter) (secondaryIndex, string, bool) {
+	f, ok := filter.(IndexFilter)
+	if !ok {
+		return secondaryIndex{}, "", false
+	}
+	field, value, ok := f.IndexLookup()
+	if !ok || !indexable(value) {
+		return secondaryIndex{}, "", false
+	}
+	idx, ok := indexFor(field)
+	return idx, value, ok
+}
+
+// queryIndex answers QueryContext for a filter pinned to value of idx's
+// field, with the same pagination and counting.
+func queryIndex(ctx context.Context, txn *badger.Txn, idx secondaryIndex, value string, filter Filter, opts QueryOptions) ([]*LogEntry, int, error) {
+	r := newKeyRange(opts.TimeRange)
+	keep := opts.Offset + opts.Limit
+
+	itOpts := badger.DefaultIteratorOptions
+	itOpts.PrefetchValues = false
+	it := txn.NewIterator(itOpts)
+	defer it.Close()
+
+	var entries []*LogEntry
+	total := 0
+	prefix := idx.valuePrefix(value)
+	visited := 0
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		if opts.SkipTotal && total > keep {
+			break
+		}
+		visited++
+		if visited%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, 0, err
+			}
+		}
+
+		ts, id, ok := indexKeyParts(it.Item().Key())
+		if !ok || (r.start != 0 && ts < r.start) {
+			continue
+		}
+		if r.end != 0 && ts > r.end {
+			break
+		}
+
+		item, err := txn.Get(logKey(ts, id))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			continue // entry deleted; the index key awaits pruning
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		var entry *LogEntry
+		if err := item.Value(func(val []byte) error {
+			entry, err = FromJSON(val)
+			return err
+		}); err != nil {
+			continue // Skip invalid entries
+		}
+		if !filter.Match(entry) {
+			continue
+		}
+
+		total++
+		if total > opts.Offset && len(entries) < opts.Limit {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, total, nil
+}
@@ -0,0 +1,269 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// traceFilter matches entries of one trace and pins queries to it.
+type traceFilter struct{ id string }
+
+func (f traceFilter) Match(entry *LogEntry) bool {
+	traceID, _ := entry.TraceContext()
+	return traceID == f.id
+}
+
+func (f traceFilter) IndexLookup() (string, string, bool) { return "trace_id", f.id, true }
+
+// sourceFilter matches entries of one source and pins queries to it.
+type sourceFilter struct{ source string }
+
+func (f sourceFilter) Match(entry *LogEntry) bool { return entry.SourceName() == f.source }
+
+func (f sourceFilter) IndexLookup() (string, string, bool) { return "source", f.source, true }
+
+func storeTraced(t *testing.T, s *BadgerStorage, id, traceID string, ts time.Time) {
+	t.Helper()
+	if err := s.Store(&LogEntry{ID: id, Timestamp: ts, Level: "INFO", Message: id, TraceID: traceID}); err != nil {
+		t.Fatalf("Store(%s) error = %v", id, err)
+	}
+}
+
+func indexKeyCount(t *testing.T, s *BadgerStorage, prefix string) int {
+	t.Helper()
+	n := 0
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek([]byte(prefix)); it.ValidForPrefix([]byte(prefix)); it.Next() {
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("count %s keys: %v", prefix, err)
+	}
+	return n
+}
+
+func queryIDs(t *testing.T, s *BadgerStorage, f Filter, opts QueryOptions) (string, int) {
+	t.Helper()
+	entries, total, err := s.QueryContext(context.Background(), f, opts)
+	if err != nil {
+		t.Fatalf("QueryContext() error = %v", err)
+	}
+	ids := make([]string, len(entries))
+	for i, e := range entries {
+		ids[i] = e.ID
+	}
+	return strings.Join(ids, ","), total
+}
+
+func TestQueryContextUsesTraceIndex(t *testing.T) {
+	s := newBehaviorStorage(t)
+	base := time.Now().UTC().Add(-time.Hour)
+	storeTraced(t, s, "b", "t1", base.Add(2*time.Minute))
+	storeTraced(t, s, "a", "t1", base)
+	storeTraced(t, s, "x", "t2", base.Add(time.Minute))
+	// Stored before promotion: the trace id only lives in Fields.
+	if err := s.Store(&LogEntry{ID: "legacy", Timestamp: base.Add(3 * time.Minute), Message: "legacy", Fields: map[string]interface{}{"trace_id": "t1"}}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		opts      QueryOptions
+		wantIDs   string
+		wantTotal int
+	}{
+		{name: "all", opts: QueryOptions{Limit: 10}, wantIDs: "a,b,legacy", wantTotal: 3},
+		{name: "page", opts: QueryOptions{Limit: 1, Offset: 1}, wantIDs: "b", wantTotal: 3},
+		{name: "skip total", opts: QueryOptions{Limit: 1, SkipTotal: true}, wantIDs: "a", wantTotal: 2},
+		{name: "time range", opts: QueryOptions{Limit: 10, TimeRange: &TimeRange{Start: base.Add(time.Minute), End: base.Add(150 * time.Second)}}, wantIDs: "b", wantTotal: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, total := queryIDs(t, s, traceFilter{id: "t1"}, tt.opts)
+			if ids != tt.wantIDs || total != tt.wantTotal {
+				t.Fatalf("QueryContext() = %q (total %d), want %q (total %d)", ids, total, tt.wantIDs, tt.wantTotal)
+			}
+		})
+	}
+
+	// A deleted entry leaves its index key behind; lookups skip it and
+	// Verify reports it.
+	if err := s.DeleteEntry("b"); err != nil {
+		t.Fatalf("DeleteEntry() error = %v", err)
+	}
+	if ids, total := queryIDs(t, s, traceFilter{id: "t1"}, QueryOptions{Limit: 10}); ids != "a,legacy" || total != 2 {
+		t.Fatalf("QueryContext() after delete = %q (total %d)", ids, total)
+	}
+	res, err := s.Verify(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if len(res.Issues) != 1 || res.Issues[0].Kind != IssueOrphanIndex {
+		t.Fatalf("Verify() issues = %+v, want one orphan_index", res.Issues)
+	}
+}
+
+func TestBuildIndexesIndexesExistingEntries(t *testing.T) {
+	s := newBehaviorStorage(t)
+	base := time.Now().UTC().Add(-time.Hour)
+	storeTraced(t, s, "a", "t1", base)
+	storeTraced(t, s, "b", "t1", base.Add(time.Minute))
+
+	// Simulate a database from before the index existed.
+	if err := s.db.DropPrefix([]byte(tracePrefix), []byte("index:trace")); err != nil {
+		t.Fatalf("DropPrefix() error = %v", err)
+	}
+	if n := indexKeyCount(t, s, tracePrefix); n != 0 {
+		t.Fatalf("trace index keys = %d after drop", n)
+	}
+
+	if err := s.buildIndexes(); err != nil {
+		t.Fatalf("buildIndexes() error = %v", err)
+	}
+	if n := indexKeyCount(t, s, tracePrefix); n != 2 {
+		t.Fatalf("trace index keys = %d, want 2", n)
+	}
+	if ids, _ := queryIDs(t, s, traceFilter{id: "t1"}, QueryOptions{Limit: 10}); ids != "a,b" {
+		t.Fatalf("QueryContext() = %q, want a,b", ids)
+	}
+
+	// Later opens find the marker and leave the index alone.
+	if err := s.db.DropPrefix([]byte(tracePrefix)); err != nil {
+		t.Fatalf("DropPrefix() error = %v", err)
+	}
+	if err := s.buildIndexes(); err != nil {
+		t.Fatalf("buildIndexes() error = %v", err)
+	}
+	if n := indexKeyCount(t, s, tracePrefix); n != 0 {
+		t.Fatalf("trace index keys = %d, want the marker to skip the rebuild", n)
+	}
+}
+
+func TestIDIndexFindsEntriesWithoutScanning(t *testing.T) {
+	s := newBehaviorStorage(t)
+	base := time.Now().UTC().Add(-time.Hour)
+	storeTraced(t, s, "a", "", base)
+	storeTraced(t, s, "b", "", base.Add(time.Minute))
+
+	// Simulate a database from before ID keys existed: entries are found by
+	// scanning until the index is built.
+	if err := s.db.DropPrefix([]byte(idPrefix), []byte(idIndexMarker)); err != nil {
+		t.Fatalf("DropPrefix() error = %v", err)
+	}
+	if entry, err := s.GetEntry("b"); err != nil || entry.ID != "b" {
+		t.Fatalf("GetEntry() before index = %+v, %v", entry, err)
+	}
+	if err := s.buildIDIndex(); err != nil {
+		t.Fatalf("buildIDIndex() error = %v", err)
+	}
+	if n := indexKeyCount(t, s, idPrefix); n != 2 {
+		t.Fatalf("id keys = %d, want 2", n)
+	}
+
+	// With the marker set, a missing ID key means the entry is gone, even
+	// if its log key were still there.
+	if err := s.db.DropPrefix(idKey("a")); err != nil {
+		t.Fatalf("DropPrefix() error = %v", err)
+	}
+	if _, err := s.GetEntry("a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetEntry() without id key error = %v, want ErrNotFound", err)
+	}
+	if entry, err := s.GetEntry("b"); err != nil || entry.ID != "b" {
+		t.Fatalf("GetEntry() = %+v, %v", entry, err)
+	}
+	if err := s.DeleteEntry("b"); err != nil {
+		t.Fatalf("DeleteEntry() error = %v", err)
+	}
+	if n := indexKeyCount(t, s, idPrefix); n != 0 {
+		t.Fatalf("id keys after delete = %d, want 0", n)
+	}
+	if _, err := s.GetEntry("b"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetEntry() after delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDeleteOlderThanPrunesIndexes(t *testing.T) {
+	s := newBehaviorStorage(t)
+	now := time.Now().UTC()
+	storeTraced(t, s, "old", "t1", now.Add(-48*time.Hour))
+	storeTraced(t, s, "new", "t1", now.Add(-time.Minute))
+
+	if _, err := s.DeleteOlderThan(now.Add(-24 * time.Hour)); err != nil {
+		t.Fatalf("DeleteOlderThan() error = %v", err)
+	}
+	if n := indexKeyCount(t, s, tracePrefix); n != 1 {
+		t.Fatalf("trace index keys = %d, want only the kept entry's", n)
+	}
+
+	if _, err := s.DeleteAll(); err != nil {
+		t.Fatalf("DeleteAll() error = %v", err)
+	}
+	if n := indexKeyCount(t, s, tracePrefix); n != 0 {
+		t.Fatalf("trace index keys = %d after DeleteAll", n)
+	}
+}
+
+func TestQueryContextUsesSourceIndex(t *testing.T) {
+	s := newBehaviorStorage(t)
+	base := time.Now().UTC().Add(-time.Hour)
+	entries := []*LogEntry{
+		{ID: "a", Timestamp: base, Message: "a", Source: "10.0.0.5:5514"},
+		{ID: "b", Timestamp: base.Add(time.Minute), Message: "b", Source: "/var/log/app.log"},
+		{ID: "c", Timestamp: base.Add(2 * time.Minute), Message: "c", Source: "10.0.0.5:5514"},
+		// Before collectors set Source, it could only live in Fields.
+		{ID: "d", Timestamp: base.Add(3 * time.Minute), Message: "d", Fields: map[string]interface{}{"source": "10.0.0.5:5514"}},
+		// Shares a key prefix with the peer above once ':' is escaped.
+		{ID: "e", Timestamp: base.Add(4 * time.Minute), Message: "e", Source: "10.0.0.5"},
+	}
+	for _, e := range entries {
+		if err := s.Store(e); err != nil {
+			t.Fatalf("Store(%s) error = %v", e.ID, err)
+		}
+	}
+
+	tests := []struct {
+		source  string
+		wantIDs string
+	}{
+		{source: "10.0.0.5:5514", wantIDs: "a,c,d"},
+		{source: "10.0.0.5", wantIDs: "e"},
+		{source: "/var/log/app.log", wantIDs: "b"},
+		{source: "missing", wantIDs: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			if ids, _ := queryIDs(t, s, sourceFilter{source: tt.source}, QueryOptions{Limit: 10}); ids != tt.wantIDs {
+				t.Fatalf("QueryContext() = %q, want %q", ids, tt.wantIDs)
+			}
+		})
+	}
+	if n := indexKeyCount(t, s, sourcePrefix); n != len(entries) {
+		t.Fatalf("source index keys = %d, want %d", n, len(entries))
+	}
+}
+
+func TestIndexKeyParts(t *testing.T) {
+	idx, _ := indexFor("source")
+	key := idx.key("host:514%x", 1771452930123456789, "3f9a1c2b7d4e5f60")
+	if want := "source:host%3A514%25x:1771452930123456789:3f9a1c2b7d4e5f60"; string(key) != want {
+		t.Fatalf("key() = %q, want %q", key, want)
+	}
+	ts, id, ok := indexKeyParts(key)
+	if !ok || ts != 1771452930123456789 || id != "3f9a1c2b7d4e5f60" {
+		t.Fatalf("indexKeyParts() = %d, %q, %v", ts, id, ok)
+	}
+	if _, _, ok := indexKeyParts([]byte("source:broken")); ok {
+		t.Fatal("indexKeyParts() accepted a key without timestamp and ID")
+	}
+}
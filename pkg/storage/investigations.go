@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// SaveInvestigation creates or replaces the investigation with inv.Name,
+// keeping the original creation time when it already exists.
+func (s *BadgerStorage) SaveInvestigation(inv *Investigation) error {
+	if strings.TrimSpace(inv.Name) == "" {
+		return fmt.Errorf("save investigation: name is required")
+	}
+
+	now := time.Now().UTC()
+	inv.CreatedAt = now
+	var existing Investigation
+	err := s.getRecord(investigationKey(inv.Name), &existing)
+	switch {
+	case err == nil:
+		inv.CreatedAt = existing.CreatedAt
+	case !errors.Is(err, ErrNotFound):
+		return fmt.Errorf("save investigation %s: %w", inv.Name, err)
+	}
+	inv.UpdatedAt = now
+	if inv.EntryIDs == nil {
+		inv.EntryIDs = []string{}
+	}
+	if inv.Queries == nil {
+		inv.Queries = []string{}
+	}
+
+	if err := s.putRecord(investigationKey(inv.Name), inv); err != nil {
+		return fmt.Errorf("save investigation %s: %w", inv.Name, err)
+	}
+	return nil
+}
+
+// GetInvestigation returns the investigation with the given name or ErrNotFound.
+func (s *BadgerStorage) GetInvestigation(name string) (*Investigation, error) {
+	var inv Investigation
+	if err := s.getRecord(investigationKey(name), &inv); err != nil {
+		return nil, fmt.Errorf("get investigation %s: %w", name, err)
+	}
+	return &inv, nil
+}
+
+// ListInvestigations returns all investigations sorted by name.
+func (s *BadgerStorage) ListInvestigations() ([]Investigation, error) {
+	invs, err := listRecords[Investigation](s, invPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("list investigations: %w", err)
+	}
+	return invs, nil
+}
+
+// DeleteInvestigation removes the investigation or returns ErrNotFound.
+// The referenced entries are left untouched.
+func (s *BadgerStorage) DeleteInvestigation(name string) error {
+	if err := s.deleteRecord(investigationKey(name)); err != nil {
+		return fmt.Errorf("delete investigation %s: %w", name, err)
+	}
+	return nil
+}
+
+// GetEntries returns the stored entries with the given IDs in timestamp
+// order. IDs that no longer exist are skipped.
+func (s *BadgerStorage) GetEntries(ids []string) ([]*LogEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	want := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		want[id] = true
+	}
+
+	var entries []*LogEntry
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte(logPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix) && len(entries) < len(want); it.Next() {
+			if id, ok := keyID(it.Item().Key()); !ok || !want[id] {
+				continue
+			}
+			err := it.Item().Value(func(val []byte) error {
+				entry, err := FromJSON(val)
+				if err != nil {
+					return err
+				}
+				entries = append(entries, entry)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get entries: %w", err)
+	}
+	return entries, nil
+}
+
+// investigationKey returns the key holding the investigation with the given name.
+func investigationKey(name string) []byte {
+	return []byte(invPrefix + name)
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInvestigationsCRUD(t *testing.T) {
+	s := newBehaviorStorage(t)
+
+	if err := s.SaveInvestigation(&Investigation{}); err == nil {
+		t.Fatalf("SaveInvestigation() with empty name error = nil")
+	}
+
+	inv := &Investigation{Name: "outage", Queries: []string{"level:ERROR"}, Notes: "db failover"}
+	if err := s.SaveInvestigation(inv); err != nil {
+		t.Fatalf("SaveInvestigation() error = %v", err)
+	}
+	if inv.EntryIDs == nil || inv.CreatedAt.IsZero() {
+		t.Fatalf("SaveInvestigation() left %+v", inv)
+	}
+	created := inv.CreatedAt
+
+	time.Sleep(time.Millisecond)
+	if err := s.SaveInvestigation(&Investigation{Name: "outage", EntryIDs: []string{"a"}}); err != nil {
+		t.Fatalf("SaveInvestigation() replace error = %v", err)
+	}
+	got, err := s.GetInvestigation("outage")
+	if err != nil {
+		t.Fatalf("GetInvestigation() error = %v", err)
+	}
+	if !got.CreatedAt.Equal(created) || !got.UpdatedAt.After(created) || len(got.EntryIDs) != 1 || got.Notes != "" {
+		t.Fatalf("GetInvestigation() = %+v, want created_at kept and fields replaced", got)
+	}
+
+	if list, err := s.ListInvestigations(); err != nil || len(list) != 1 {
+		t.Fatalf("ListInvestigations() = %+v, %v", list, err)
+	}
+	if err := s.DeleteInvestigation("outage"); err != nil {
+		t.Fatalf("DeleteInvestigation() error = %v", err)
+	}
+	if _, err := s.GetInvestigation("outage"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetInvestigation() after delete error = %v, want ErrNotFound", err)
+	}
+	if err := s.DeleteInvestigation("outage"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("DeleteInvestigation() missing error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestGetEntries(t *testing.T) {
+	s := newBehaviorStorage(t)
+	base := time.Now().UTC().Add(-time.Minute)
+	addEntry(t, s, "late", base.Add(2*time.Second), "INFO", nil)
+	addEntry(t, s, "early", base, "INFO", nil)
+	addEntry(t, s, "other", base.Add(time.Second), "INFO", nil)
+
+	entries, err := s.GetEntries([]string{"late", "missing", "early"})
+	if err != nil {
+		t.Fatalf("GetEntries() error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].ID != "early" || entries[1].ID != "late" {
+		t.Fatalf("GetEntries() = %v, want early, late", entries)
+	}
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maintenanceCheckInterval is how often the maintenance worker checks
+// whether the window has opened.
+var maintenanceCheckInterval = time.Minute
+
+// MaintenanceWindow is a daily span of local time, such as 03:00-05:00,
+// in which the heavy retention work runs: size-cap sweeps, dropping whole
+// expired hours and full compaction. Outside it, writes only trigger the
+// age limit, deleted in small batches. A window may cross midnight.
+type MaintenanceWindow struct {
+	Start time.Duration // since local midnight
+	End   time.Duration // since local midnight; at or before Start crosses midnight
+}
+
+// ParseMaintenanceWindow reads "HH:MM-HH:MM", or "HH:MM" for a window of
+// one hour.
+func ParseMaintenanceWindow(s string) (*MaintenanceWindow, error) {
+	start, end, hasEnd := strings.Cut(strings.TrimSpace(s), "-")
+	w := &MaintenanceWindow{}
+	var err error
+	if w.Start, err = parseClock(start); err != nil {
+		return nil, err
+	}
+	if !hasEnd {
+		w.End = (w.Start + time.Hour) % (24 * time.Hour)
+		return w, nil
+	}
+	if w.End, err = parseClock(end); err != nil {
+		return nil, err
+	}
+	if w.End == w.Start {
+		return nil, fmt.Errorf("maintenance window %q is empty", s)
+	}
+	return w, nil
+}
+
+// parseClock reads a time of day as HH:MM.
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time of day (use HH:MM)", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// String returns the window as HH:MM-HH:MM.
+func (w MaintenanceWindow) String() string {
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d/time.Hour), int(d%time.Hour/time.Minute))
+	}
+	return clock(w.Start) + "-" + clock(w.End)
+}
+
+// opening returns when the occurrence of the window containing t opened,
+// and false when t is outside the window.
+func (w MaintenanceWindow) opening(t time.Time) (time.Time, bool) {
+	length := w.End - w.Start
+	if length <= 0 {
+		length += 24 * time.Hour
+	}
+	hour, minute := int(w.Start/time.Hour), int(w.Start%time.Hour/time.Minute)
+	// The occurrence containing t opened today or, crossing midnight,
+	// yesterday.
+	for _, day := range []int{0, -1} {
+		start := time.Date(t.Year(), t.Month(), t.Day()+day, hour, minute, 0, 0, t.Location())
+		if !t.Before(start) && t.Before(start.Add(length)) {
+			return start, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Contains reports whether t falls in the window, in t's location.
+func (w MaintenanceWindow) Contains(t time.Time) bool {
+	_, ok := w.opening(t)
+	return ok
+}
+
+// RunMaintenance runs the heavy retention work now: a full retention sweep
+// under the configured policy, then value log GC until there is nothing
+// left to rewrite. The maintenance worker calls it once per window.
+func (s *BadgerStorage) RunMaintenance() (CompactionResult, error) {
+	defer s.noteMaintenance()
+	if err := s.sweepRetention(s.retentionPolicy, false); err != nil {
+		return CompactionResult{}, err
+	}
+	return s.CompactDatabaseFully()
+}
+
+// maintenanceWorker runs RunMaintenance once in each occurrence of the
+// window until Close.
+func (s *BadgerStorage) maintenanceWorker() {
+	defer s.workers.Done()
+	ticker := time.NewTicker(maintenanceCheckInterval)
+	defer ticker.Stop()
+	var last time.Time // opening of the window maintenance last ran in
+	for {
+		select {
+		case now := <-ticker.C:
+			opened, ok := s.window.opening(now)
+			if !ok || opened.Equal(last) {
+				continue
+			}
+			last = opened
+			s.RunMaintenance()
+		case <-s.doneChan:
+			return
+		}
+	}
+}
+
+// noteMaintenance records that a maintenance run finished.
+func (s *BadgerStorage) noteMaintenance() {
+	h := &s.health
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastMaintenance = time.Now()
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// ParseFailure is a line that did not parse with the explicit format it was
+// ingested with. It is kept under parsefail: so it can be parsed again once
+// the format is fixed, instead of being lost.
+type ParseFailure struct {
+	// ID identifies the failure for DeleteParseFailures.
+	ID     string    `json:"id"`
+	Time   time.Time `json:"time"`
+	Format string    `json:"format"`
+	Reason string    `json:"reason"`
+	// Line is the raw text; continuation lines are joined with "\n".
+	Line string `json:"line"`
+	// Session, Source, Namespace and Host are what the entry would have
+	// been stored with.
+	Session   string    `json:"session,omitempty"`
+	Source    string    `json:"source,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	Host      *HostInfo `json:"host,omitempty"`
+}
+
+// RecordParseFailures stores failures under parsefail:{timestamp_nano}:{seq},
+// filling in their IDs. With retention days configured they expire like
+// log entries; they live outside the log keyspace otherwise.
+func (s *BadgerStorage) RecordParseFailures(failures []ParseFailure) error {
+	if len(failures) == 0 {
+		return nil
+	}
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+	for i := range failures {
+		f := &failures[i]
+		if f.Time.IsZero() {
+			f.Time = time.Now()
+		}
+		f.ID = fmt.Sprintf("%019d:%d", f.Time.UnixNano(), s.parseFailSeq.Add(1))
+		data, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+		e := badger.NewEntry([]byte(parseFailPrefix+f.ID), data)
+		if s.retentionDays > 0 {
+			e = e.WithTTL(time.Duration(s.retentionDays) * 24 * time.Hour)
+		}
+		if err := wb.SetEntry(e); err != nil {
+			return fmt.Errorf("record parse failure: %w", err)
+		}
+	}
+	if err := wb.Flush(); err != nil {
+		return fmt.Errorf("record parse failure: %w", err)
+	}
+	return nil
+}
+
+// ScanParseFailures calls fn for every stored parse failure, newest first,
+// until fn returns an error.
+func (s *BadgerStorage) ScanParseFailures(fn func(ParseFailure) error) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Reverse = true
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte(parseFailPrefix)
+		for it.Seek(append(prefix, 0xFF)); it.ValidForPrefix(prefix); it.Next() {
+			var f ParseFailure
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &f)
+			}); err != nil {
+				return fmt.Errorf("decode %s: %w", it.Item().Key(), err)
+			}
+			if err := fn(f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteParseFailures removes the failures with the given IDs; unknown IDs
+// are ignored.
+func (s *BadgerStorage) DeleteParseFailures(ids []string) error {
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+	for _, id := range ids {
+		if err := wb.Delete([]byte(parseFailPrefix + id)); err != nil {
+			return fmt.Errorf("delete parse failure: %w", err)
+		}
+	}
+	if err := wb.Flush(); err != nil {
+		return fmt.Errorf("delete parse failure: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseFailures(t *testing.T) {
+	s := newBehaviorStorage(t)
+	base := time.Now().UTC().Add(-time.Hour)
+	failures := []ParseFailure{
+		{Time: base, Format: "json", Reason: "line does not match format json", Line: "not json"},
+		{Time: base, Format: "json", Reason: "line does not match format json", Line: "also not json", Namespace: "team-a"},
+		{Time: base.Add(time.Minute), Format: "csv", Reason: "csv: line has 2 values, expected 3", Line: "a,b", Source: "export.csv"},
+	}
+	if err := s.RecordParseFailures(failures); err != nil {
+		t.Fatalf("RecordParseFailures() error = %v", err)
+	}
+	if failures[0].ID == "" || failures[0].ID == failures[1].ID {
+		t.Fatalf("IDs = %q, %q; want distinct IDs", failures[0].ID, failures[1].ID)
+	}
+
+	var lines []string
+	scan := func() {
+		t.Helper()
+		lines = nil
+		if err := s.ScanParseFailures(func(f ParseFailure) error {
+			lines = append(lines, f.Line)
+			return nil
+		}); err != nil {
+			t.Fatalf("ScanParseFailures() error = %v", err)
+		}
+	}
+	scan()
+	if len(lines) != 3 || lines[0] != "a,b" {
+		t.Fatalf("scanned %q, want newest first", lines)
+	}
+
+	// Stored logs are unaffected.
+	if _, total, err := s.Query(&AllFilter{}, 10, 0); err != nil || total != 0 {
+		t.Fatalf("Query() total = %d, %v", total, err)
+	}
+
+	stop := errors.New("stop")
+	n := 0
+	if err := s.ScanParseFailures(func(ParseFailure) error { n++; return stop }); !errors.Is(err, stop) || n != 1 {
+		t.Fatalf("ScanParseFailures() stopping = %v after %d", err, n)
+	}
+
+	if err := s.DeleteParseFailures([]string{failures[2].ID, "0:0"}); err != nil {
+		t.Fatalf("DeleteParseFailures() error = %v", err)
+	}
+	scan()
+	if len(lines) != 2 || lines[0] == "a,b" {
+		t.Fatalf("after delete scanned %q", lines)
+	}
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// DeletePlan describes what a delete would remove, worked out by walking the
+// same keys the delete would without removing any.
+type DeletePlan struct {
+	Entries int `json:"entries"`
+	// Bytes is the estimated size of the entries and their raw lines.
+	Bytes int64 `json:"bytes"`
+	// Levels and Sources count the entries by level and source; entries
+	// without one are counted under "".
+	Levels  map[string]int `json:"levels"`
+	Sources map[string]int `json:"sources"`
+	// OldestSurviving is the timestamp of the oldest entry that would be
+	// kept, or nil when none would.
+	OldestSurviving *time.Time `json:"oldest_surviving,omitempty"`
+}
+
+// PlanDeleteAll reports what DeleteAll would remove.
+func (s *BadgerStorage) PlanDeleteAll() (DeletePlan, error) {
+	return s.planDelete(func(*badger.Item, *LogEntry) (bool, bool) { return true, false })
+}
+
+// PlanDeleteByLevel reports what DeleteByLevel would remove.
+func (s *BadgerStorage) PlanDeleteByLevel(level string) (DeletePlan, error) {
+	return s.planDelete(func(_ *badger.Item, entry *LogEntry) (bool, bool) {
+		return entry.Level == level, false
+	})
+}
+
+// PlanDeleteOlderThan reports what DeleteOlderThan would remove.
+func (s *BadgerStorage) PlanDeleteOlderThan(cutoff time.Time) (DeletePlan, error) {
+	return s.planOldest(cutoffStop(cutoff))
+}
+
+// PlanRetention reports what a retention sweep under the given size cap
+// (bytes) and age limit (days) would remove now. Zero disables either limit.
+func (s *BadgerStorage) PlanRetention(sizeBytes int64, days int) (DeletePlan, error) {
+	targetBytes, cutoff := s.retentionTarget(sizeBytes, days)
+	switch {
+	case targetBytes > 0:
+		return s.planOldest(bytesStop(targetBytes))
+	case !cutoff.IsZero():
+		return s.planOldest(cutoffStop(cutoff))
+	}
+	return s.planOldest(func(*badger.Item) bool { return true })
+}
+
+// planOldest plans a delete of the oldest entries up to the first key stop
+// returns true for, as deleteOldestInBatches would delete them.
+func (s *BadgerStorage) planOldest(stop func(item *badger.Item) bool) (DeletePlan, error) {
+	return s.planDelete(func(item *badger.Item, _ *LogEntry) (bool, bool) {
+		if stop(item) {
+			return false, true
+		}
+		return true, false
+	})
+}
+
+// planDelete walks the log keys oldest first and adds up the entries decide
+// marks for deletion. decide ends the walk by returning stop. Entries that
+// can't be decoded are passed as an empty LogEntry.
+func (s *BadgerStorage) planDelete(decide func(item *badger.Item, entry *LogEntry) (del, stop bool)) (DeletePlan, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	plan := DeletePlan{Levels: make(map[string]int), Sources: make(map[string]int)}
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte(logPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			entry := &LogEntry{}
+			err := item.Value(func(val []byte) error {
+				if e, err := FromJSON(val); err == nil {
+					entry = e
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			del, stop := decide(item, entry)
+			if !del {
+				if plan.OldestSurviving == nil {
+					if ts, ok := keyTimestamp(item.Key()); ok {
+						t := time.Unix(0, ts)
+						plan.OldestSurviving = &t
+					}
+				}
+				if stop {
+					return nil
+				}
+				continue
+			}
+
+			plan.Entries++
+			plan.Bytes += item.EstimatedSize()
+			if id, ok := keyID(item.Key()); ok {
+				if raw, err := txn.Get(rawKey(id)); err == nil {
+					plan.Bytes += raw.EstimatedSize()
+				}
+			}
+			plan.Levels[entry.Level]++
+			plan.Sources[entry.SourceName()]++
+		}
+		return nil
+	})
+	return plan, err
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestDeletePlans(t *testing.T) {
+	base := time.Now().Add(-10 * 24 * time.Hour).Truncate(time.Second)
+	seed := func(t *testing.T) *BadgerStorage {
+		s := newBehaviorStorage(t)
+		for i, e := range []struct {
+			level, source string
+			age           time.Duration
+		}{
+			{"DEBUG", "api", 0},
+			{"INFO", "api", time.Hour},
+			{"DEBUG", "", 2 * time.Hour},
+			{"ERROR", "worker", 9 * 24 * time.Hour},
+		} {
+			entry := &LogEntry{ID: string(rune('a' + i)), Timestamp: base.Add(e.age), Level: e.level, Source: e.source, Message: "m", Raw: "m"}
+			if err := s.Store(entry); err != nil {
+				t.Fatalf("Store() error = %v", err)
+			}
+		}
+		return s
+	}
+
+	tests := []struct {
+		name        string
+		plan        func(s *BadgerStorage) (DeletePlan, error)
+		del         func(s *BadgerStorage) (int, error)
+		wantLevels  map[string]int
+		wantSources map[string]int
+		wantOldest  time.Time
+	}{
+		{
+			name:        "all",
+			plan:        (*BadgerStorage).PlanDeleteAll,
+			del:         (*BadgerStorage).DeleteAll,
+			wantLevels:  map[string]int{"DEBUG": 2, "INFO": 1, "ERROR": 1},
+			wantSources: map[string]int{"api": 2, "": 1, "worker": 1},
+		},
+		{
+			name:        "level",
+			plan:        func(s *BadgerStorage) (DeletePlan, error) { return s.PlanDeleteByLevel("DEBUG") },
+			del:         func(s *BadgerStorage) (int, error) { return s.DeleteByLevel("DEBUG") },
+			wantLevels:  map[string]int{"DEBUG": 2},
+			wantSources: map[string]int{"api": 1, "": 1},
+			wantOldest:  base.Add(time.Hour),
+		},
+		{
+			name:        "older than",
+			plan:        func(s *BadgerStorage) (DeletePlan, error) { return s.PlanDeleteOlderThan(base.Add(90 * time.Minute)) },
+			del:         func(s *BadgerStorage) (int, error) { return s.DeleteOlderThan(base.Add(90 * time.Minute)) },
+			wantLevels:  map[string]int{"DEBUG": 1, "INFO": 1},
+			wantSources: map[string]int{"api": 2},
+			wantOldest:  base.Add(2 * time.Hour),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := seed(t)
+			plan, err := tt.plan(s)
+			if err != nil {
+				t.Fatalf("plan error = %v", err)
+			}
+			if !maps.Equal(plan.Levels, tt.wantLevels) || !maps.Equal(plan.Sources, tt.wantSources) {
+				t.Fatalf("plan levels = %v, sources = %v, want %v, %v", plan.Levels, plan.Sources, tt.wantLevels, tt.wantSources)
+			}
+			if (plan.OldestSurviving == nil) != tt.wantOldest.IsZero() || (plan.OldestSurviving != nil && !plan.OldestSurviving.Equal(tt.wantOldest)) {
+				t.Fatalf("plan oldest surviving = %v, want %v", plan.OldestSurviving, tt.wantOldest)
+			}
+			if plan.Bytes <= 0 {
+				t.Fatalf("plan bytes = %d, want > 0", plan.Bytes)
+			}
+
+			// The plan deleted nothing, and matches the real delete.
+			if stats, _ := s.GetStats(); stats.TotalLogs != 4 {
+				t.Fatalf("TotalLogs after plan = %d, want 4", stats.TotalLogs)
+			}
+			deleted, err := tt.del(s)
+			if err != nil {
+				t.Fatalf("delete error = %v", err)
+			}
+			if deleted != plan.Entries {
+				t.Fatalf("deleted %d entries, plan said %d", deleted, plan.Entries)
+			}
+		})
+	}
+}
+
+func TestPlanRetention(t *testing.T) {
+	s := newBehaviorStorage(t)
+	now := time.Now()
+	addEntry(t, s, "old", now.Add(-5*24*time.Hour), "INFO", nil)
+	addEntry(t, s, "older", now.Add(-6*24*time.Hour), "WARN", nil)
+	addEntry(t, s, "new", now.Add(-time.Hour), "INFO", nil)
+
+	plan, err := s.PlanRetention(0, 0)
+	if err != nil || plan.Entries != 0 || plan.OldestSurviving == nil {
+		t.Fatalf("PlanRetention(no policy) = %+v, %v; want nothing due", plan, err)
+	}
+
+	plan, err = s.PlanRetention(0, 3)
+	if err != nil {
+		t.Fatalf("PlanRetention() error = %v", err)
+	}
+	if plan.Entries != 2 || plan.Levels["INFO"] != 1 || plan.Levels["WARN"] != 1 {
+		t.Fatalf("PlanRetention(3 days) = %+v, want the two old entries", plan)
+	}
+	if err := s.EnforceRetention(0, 3); err != nil {
+		t.Fatalf("EnforceRetention() error = %v", err)
+	}
+	if stats, _ := s.GetStats(); stats.TotalLogs != 1 {
+		t.Fatalf("TotalLogs after EnforceRetention = %d, want 1", stats.TotalLogs)
+	}
+}
@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// The forward queue holds raw lines that peek forward has read but not yet
+// delivered, under queue:{seq}, so a down or unreachable server does not
+// lose them. seq is a big-endian uint64, so keys sort in arrival order.
+
+// QueuedLine is a line waiting in the forward queue.
+type QueuedLine struct {
+	Seq  uint64
+	Line string
+}
+
+// queueState caches the queue's bounds and size; it is loaded from the
+// keyspace on first use.
+type queueState struct {
+	mu     sync.Mutex
+	loaded bool
+	head   uint64 // oldest seq still queued
+	next   uint64 // seq for the next enqueued line
+	lines  int
+	bytes  int64
+}
+
+func queueKey(seq uint64) []byte {
+	key := make([]byte, len(queuePrefix)+8)
+	copy(key, queuePrefix)
+	binary.BigEndian.PutUint64(key[len(queuePrefix):], seq)
+	return key
+}
+
+func queueSeq(key []byte) uint64 {
+	return binary.BigEndian.Uint64(key[len(queuePrefix):])
+}
+
+// loadQueue scans the queue keyspace once to restore the bounds left by an
+// earlier run. Callers hold s.queue.mu.
+func (s *BadgerStorage) loadQueue() error {
+	q := &s.queue
+	if q.loaded {
+		return nil
+	}
+	q.head, q.next, q.lines, q.bytes = 0, 0, 0, 0
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(queuePrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			seq := queueSeq(it.Item().Key())
+			if q.lines == 0 {
+				q.head = seq
+			}
+			q.next = seq + 1
+			q.lines++
+			q.bytes += it.Item().ValueSize()
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load forward queue: %w", err)
+	}
+	q.loaded = true
+	return nil
+}
+
+// Enqueue appends lines to the forward queue. When maxBytes is positive and
+// the queue grows past it, the oldest lines are dropped to make room and
+// their count is returned.
+func (s *BadgerStorage) Enqueue(lines []string, maxBytes int64) (int, error) {
+	q := &s.queue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := s.loadQueue(); err != nil {
+		return 0, err
+	}
+
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+	next, added := q.next, int64(0)
+	for _, line := range lines {
+		if err := wb.Set(queueKey(next), []byte(line)); err != nil {
+			return 0, fmt.Errorf("failed to enqueue lines: %w", err)
+		}
+		next++
+		added += int64(len(line))
+	}
+	if err := wb.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to enqueue lines: %w", err)
+	}
+	if q.lines == 0 {
+		q.head = q.next
+	}
+	q.next = next
+	q.lines += len(lines)
+	q.bytes += added
+
+	if maxBytes <= 0 || q.bytes <= maxBytes {
+		return 0, nil
+	}
+	return s.trimQueue(maxBytes)
+}
+
+// trimQueue drops the oldest lines until the queue fits in maxBytes.
+// Callers hold s.queue.mu.
+func (s *BadgerStorage) trimQueue(maxBytes int64) (int, error) {
+	q := &s.queue
+	dropped := 0
+	var upTo uint64
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(queuePrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		bytes := q.bytes
+		for it.Rewind(); it.Valid() && bytes > maxBytes; it.Next() {
+			bytes -= it.Item().ValueSize()
+			upTo = queueSeq(it.Item().Key())
+			dropped++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to trim forward queue: %w", err)
+	}
+	if dropped == 0 {
+		return 0, nil
+	}
+	if err := s.ackQueue(upTo); err != nil {
+		return 0, err
+	}
+	return dropped, nil
+}
+
+// PeekQueue returns up to n of the oldest queued lines without removing
+// them; AckQueue removes them once they are delivered.
+func (s *BadgerStorage) PeekQueue(n int) ([]QueuedLine, error) {
+	q := &s.queue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := s.loadQueue(); err != nil {
+		return nil, err
+	}
+
+	out := []QueuedLine{}
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(queuePrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid() && len(out) < n; it.Next() {
+			val, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			out = append(out, QueuedLine{Seq: queueSeq(it.Item().Key()), Line: string(val)})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read forward queue: %w", err)
+	}
+	return out, nil
+}
+
+// AckQueue removes every queued line up to and including seq.
+func (s *BadgerStorage) AckQueue(seq uint64) error {
+	q := &s.queue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := s.loadQueue(); err != nil {
+		return err
+	}
+	return s.ackQueue(seq)
+}
+
+// ackQueue deletes lines from the head through seq. Callers hold
+// s.queue.mu with the queue loaded.
+func (s *BadgerStorage) ackQueue(seq uint64) error {
+	q := &s.queue
+	if q.lines == 0 || seq < q.head {
+		return nil
+	}
+
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+	var lines int
+	var bytes int64
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(queuePrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			key := it.Item().Key()
+			if queueSeq(key) > seq {
+				break
+			}
+			if err := wb.Delete(it.Item().KeyCopy(nil)); err != nil {
+				return err
+			}
+			lines++
+			bytes += it.Item().ValueSize()
+		}
+		return nil
+	})
+	if err == nil {
+		err = wb.Flush()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to acknowledge forward queue: %w", err)
+	}
+
+	q.lines -= lines
+	q.bytes -= bytes
+	q.head = seq + 1
+	return nil
+}
+
+// QueueLen reports how many lines and value bytes are queued.
+func (s *BadgerStorage) QueueLen() (int, int64, error) {
+	q := &s.queue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := s.loadQueue(); err != nil {
+		return 0, 0, err
+	}
+	return q.lines, q.bytes, nil
+}
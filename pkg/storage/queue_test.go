@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestForwardQueue(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewBadgerStorage(Config{DBPath: dir})
+	if err != nil {
+		t.Fatalf("NewBadgerStorage() error = %v", err)
+	}
+
+	if dropped, err := s.Enqueue([]string{"a", "b", "c"}, 0); err != nil || dropped != 0 {
+		t.Fatalf("Enqueue() = %d, %v", dropped, err)
+	}
+	got, err := s.PeekQueue(2)
+	if err != nil {
+		t.Fatalf("PeekQueue() error = %v", err)
+	}
+	if len(got) != 2 || got[0].Line != "a" || got[1].Line != "b" {
+		t.Fatalf("PeekQueue(2) = %+v, want a, b", got)
+	}
+	if err := s.AckQueue(got[1].Seq); err != nil {
+		t.Fatalf("AckQueue() error = %v", err)
+	}
+
+	// The rest survives a restart and new lines follow it in order.
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	s, err = NewBadgerStorage(Config{DBPath: dir})
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	defer s.Close()
+	if lines, bytes, err := s.QueueLen(); err != nil || lines != 1 || bytes != 1 {
+		t.Fatalf("QueueLen() after reopen = %d, %d, %v; want 1 line, 1 byte", lines, bytes, err)
+	}
+	if _, err := s.Enqueue([]string{"d"}, 0); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	got, err = s.PeekQueue(10)
+	if err != nil {
+		t.Fatalf("PeekQueue() error = %v", err)
+	}
+	if len(got) != 2 || got[0].Line != "c" || got[1].Line != "d" {
+		t.Fatalf("PeekQueue() after reopen = %+v, want c, d", got)
+	}
+}
+
+func TestForwardQueueDropsOldestOverCap(t *testing.T) {
+	s := newBehaviorStorage(t)
+
+	lines := make([]string, 10)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line-%02d", i) // 7 bytes each
+	}
+	dropped, err := s.Enqueue(lines, 35)
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	if dropped != 5 {
+		t.Fatalf("dropped = %d, want 5", dropped)
+	}
+	got, err := s.PeekQueue(10)
+	if err != nil {
+		t.Fatalf("PeekQueue() error = %v", err)
+	}
+	if len(got) != 5 || got[0].Line != "line-05" {
+		t.Fatalf("PeekQueue() = %+v, want line-05 through line-09", got)
+	}
+	if n, size, _ := s.QueueLen(); n != 5 || size != 35 {
+		t.Fatalf("QueueLen() = %d, %d; want 5 lines, 35 bytes", n, size)
+	}
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Named records (views, investigations) are JSON values stored under
+// {prefix}{name}, outside the log keyspace.
+
+// putRecord stores v as JSON under key.
+func (s *BadgerStorage) putRecord(key []byte, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, data)
+	})
+}
+
+// getRecord decodes the JSON value under key into v, or returns ErrNotFound.
+func (s *BadgerStorage) getRecord(key []byte, v interface{}) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, v)
+		})
+	})
+}
+
+// listRecords decodes every JSON value under prefix, in key order.
+func listRecords[T any](s *BadgerStorage, prefix string) ([]T, error) {
+	records := []T{}
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		p := []byte(prefix)
+		for it.Seek(p); it.ValidForPrefix(p); it.Next() {
+			var r T
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &r)
+			}); err != nil {
+				return fmt.Errorf("decode %s: %w", it.Item().Key(), err)
+			}
+			records = append(records, r)
+		}
+		return nil
+	})
+	return records, err
+}
+
+// deleteRecord removes key, or returns ErrNotFound when it does not exist.
+func (s *BadgerStorage) deleteRecord(key []byte) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get(key); err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+		return txn.Delete(key)
+	})
+}
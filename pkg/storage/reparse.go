@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// ReparseFunc turns an original log line into a freshly parsed entry.
+type ReparseFunc func(raw string) (*LogEntry, error)
+
+// ReparseResult counts the entries visited by Reparse.
+type ReparseResult struct {
+	Matched   int `json:"matched"`   // entries matching the filter
+	Updated   int `json:"updated"`   // entries whose level, message or fields changed
+	Unchanged int `json:"unchanged"` // entries the parser produced identically
+	Skipped   int `json:"skipped"`   // entries without a raw line or whose line failed to parse
+}
+
+// Reparse re-runs parse over the raw line of every entry matching filter (nil
+// for all) and rewrites its level, message, fields and trace context in
+// place. ID,
+// timestamp, session and namespace are kept, so keys, annotations and deep
+// links stay valid.
+func (s *BadgerStorage) Reparse(ctx context.Context, filter Filter, parse ReparseFunc) (ReparseResult, error) {
+	defer s.invalidateStats()
+	var res ReparseResult
+	progress := s.progressFunc()
+
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte(logPrefix)
+		visited := 0
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			visited++
+			if visited%ctxCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			if progress != nil && visited%retentionBatchSize == 0 {
+				progress(visited)
+			}
+
+			item := it.Item()
+			var entry *LogEntry
+			err := item.Value(func(val []byte) error {
+				var err error
+				entry, err = FromJSON(val)
+				return err
+			})
+			if err != nil {
+				continue // skip undecodable entries, as queries do
+			}
+			if filter != nil && !filter.Match(entry) {
+				continue
+			}
+			res.Matched++
+
+			raw, err := storedRaw(txn, entry)
+			if err != nil {
+				return err
+			}
+			if raw == "" {
+				res.Skipped++
+				continue
+			}
+
+			parsed, err := parse(raw)
+			if err != nil || parsed == nil {
+				res.Skipped++
+				continue
+			}
+
+			updated := *entry
+			updated.Level = parsed.Level
+			updated.Message = parsed.Message
+			updated.Fields = parsed.Fields
+			updated.TraceID, updated.SpanID = parsed.TraceID, parsed.SpanID
+			updated.Raw = raw
+
+			changed, err := entryChanged(entry, &updated)
+			if err != nil {
+				return err
+			}
+			if !changed {
+				res.Unchanged++
+				continue
+			}
+
+			writes, err := entryWrites(&updated)
+			if err != nil {
+				return err
+			}
+			for _, e := range writes {
+				if err := wb.SetEntry(e); err != nil {
+					return err
+				}
+			}
+			res.Updated++
+		}
+		return nil
+	})
+	if err == nil {
+		err = wb.Flush()
+	}
+	if err != nil {
+		return res, fmt.Errorf("reparse: %w", err)
+	}
+	return res, nil
+}
+
+// storedRaw returns the raw line for entry from its sibling key, falling back
+// to the inline copy kept by entries written before Raw was split out.
+func storedRaw(txn *badger.Txn, entry *LogEntry) (string, error) {
+	item, err := txn.Get(rawKey(entry.ID))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return entry.Raw, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return "", err
+	}
+	return string(val), nil
+}
+
+// entryChanged reports whether the structured parts of two entries differ,
+// treating nil and empty field maps as equal.
+func entryChanged(before, after *LogEntry) (bool, error) {
+	a, b := *before, *after
+	a.Raw, b.Raw = "", ""
+	if len(a.Fields) == 0 {
+		a.Fields = nil
+	}
+	if len(b.Fields) == 0 {
+		b.Fields = nil
+	}
+	aj, err := a.ToJSON()
+	if err != nil {
+		return false, err
+	}
+	bj, err := b.ToJSON()
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(aj, bj), nil
+}
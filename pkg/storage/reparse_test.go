@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReparse(t *testing.T) {
+	s := newBehaviorStorage(t)
+	ts := time.Now().UTC().Add(-time.Minute)
+
+	for _, e := range []*LogEntry{
+		{ID: "plain", Timestamp: ts, Level: "INFO", Message: "user=bob action=login", Raw: "user=bob action=login", Session: "s1", Namespace: "team"},
+		{ID: "same", Timestamp: ts, Level: "INFO", Message: "hello", Raw: "hello"},
+		{ID: "bad", Timestamp: ts, Level: "INFO", Message: "broken", Raw: "broken"},
+		{ID: "noraw", Timestamp: ts, Level: "INFO", Message: "no raw"},
+		{ID: "debug", Timestamp: ts, Level: "DEBUG", Message: "x=1", Raw: "x=1"},
+	} {
+		if err := s.Store(e); err != nil {
+			t.Fatalf("Store() error = %v", err)
+		}
+	}
+
+	// A stand-in for an improved parser: key=value pairs become fields.
+	parse := func(raw string) (*LogEntry, error) {
+		if raw == "broken" {
+			return nil, errors.New("parse failed")
+		}
+		entry := &LogEntry{ID: "fresh", Timestamp: time.Now(), Level: "INFO", Message: raw, Fields: map[string]interface{}{}}
+		if strings.Contains(raw, "=") {
+			entry.Level = "WARN"
+			entry.Message = "parsed"
+			for _, kv := range strings.Fields(raw) {
+				k, v, _ := strings.Cut(kv, "=")
+				entry.Fields[k] = v
+			}
+		}
+		return entry, nil
+	}
+
+	res, err := s.Reparse(context.Background(), LevelFilter{Level: "INFO"}, parse)
+	if err != nil {
+		t.Fatalf("Reparse() error = %v", err)
+	}
+	want := ReparseResult{Matched: 4, Updated: 1, Unchanged: 1, Skipped: 2}
+	if res != want {
+		t.Fatalf("Reparse() = %+v, want %+v", res, want)
+	}
+
+	got, err := s.GetEntry("plain")
+	if err != nil {
+		t.Fatalf("GetEntry() error = %v", err)
+	}
+	if got.Level != "WARN" || got.Message != "parsed" || got.Fields["user"] != "bob" || got.Fields["action"] != "login" {
+		t.Fatalf("reparsed entry = %+v, want WARN/parsed with user and action fields", got)
+	}
+	if !got.Timestamp.Equal(ts) || got.Session != "s1" || got.Namespace != "team" || got.Raw != "user=bob action=login" {
+		t.Fatalf("reparsed entry = %+v, want timestamp, session, namespace and raw kept", got)
+	}
+
+	// The level meta byte is rewritten along with the value.
+	entries, _, err := s.Query(LevelFilter{Level: "WARN"}, 10, 0)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].ID != "plain" {
+		t.Fatalf("Query(WARN) = %d entries, want only plain", len(entries))
+	}
+
+	debug, err := s.GetEntry("debug")
+	if err != nil {
+		t.Fatalf("GetEntry() error = %v", err)
+	}
+	if debug.Level != "DEBUG" || len(debug.Fields) != 0 {
+		t.Fatalf("entry outside filter = %+v, want untouched", debug)
+	}
+}
+
+func TestReparseKeepsTraceContext(t *testing.T) {
+	s := newBehaviorStorage(t)
+	ts := time.Now().UTC().Add(-time.Minute)
+	if err := s.Store(&LogEntry{ID: "t", Timestamp: ts, Level: "INFO", Message: "trace_id=abc", Raw: "trace_id=abc"}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	// Parsers move trace context out of Fields into the entry.
+	parse := func(raw string) (*LogEntry, error) {
+		_, id, _ := strings.Cut(raw, "=")
+		return &LogEntry{Level: "INFO", Message: "traced", TraceID: id}, nil
+	}
+	if _, err := s.Reparse(context.Background(), nil, parse); err != nil {
+		t.Fatalf("Reparse() error = %v", err)
+	}
+
+	if ids, _ := queryIDs(t, s, traceFilter{id: "abc"}, QueryOptions{Limit: 10}); ids != "t" {
+		t.Fatalf("trace query after reparse = %q, want t", ids)
+	}
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// MinScheduleInterval is the shortest allowed scheduled query interval.
+const MinScheduleInterval = 10 * time.Second
+
+// seriesLimit caps the points kept per scheduled query; older points are
+// pruned as new ones are recorded.
+const seriesLimit = 10080
+
+// ValidateScheduledQuery checks the name and interval of q. Query syntax is
+// validated by callers that can parse it.
+func ValidateScheduledQuery(q *ScheduledQuery) error {
+	if strings.TrimSpace(q.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if strings.ContainsAny(q.Name, ":/") {
+		return fmt.Errorf("name must not contain ':' or '/'")
+	}
+	interval, err := time.ParseDuration(q.Interval)
+	if err != nil {
+		return fmt.Errorf("invalid interval: %w", err)
+	}
+	if interval < MinScheduleInterval {
+		return fmt.Errorf("interval must be at least %s", MinScheduleInterval)
+	}
+	return nil
+}
+
+// SaveScheduledQuery creates or replaces a scheduled query definition.
+// Existing run state and recorded points are kept.
+func (s *BadgerStorage) SaveScheduledQuery(q *ScheduledQuery) error {
+	if err := ValidateScheduledQuery(q); err != nil {
+		return fmt.Errorf("save scheduled query: %w", err)
+	}
+
+	var existing ScheduledQuery
+	if err := s.getRecord(scheduledKey(q.Name), &existing); err == nil {
+		q.LastRun, q.LastCount = existing.LastRun, existing.LastCount
+	}
+
+	if err := s.putRecord(scheduledKey(q.Name), q); err != nil {
+		return fmt.Errorf("save scheduled query %s: %w", q.Name, err)
+	}
+	return nil
+}
+
+// GetScheduledQuery returns the named scheduled query or ErrNotFound.
+func (s *BadgerStorage) GetScheduledQuery(name string) (*ScheduledQuery, error) {
+	var q ScheduledQuery
+	if err := s.getRecord(scheduledKey(name), &q); err != nil {
+		return nil, fmt.Errorf("get scheduled query %s: %w", name, err)
+	}
+	return &q, nil
+}
+
+// ListScheduledQueries returns all scheduled queries sorted by name.
+func (s *BadgerStorage) ListScheduledQueries() ([]ScheduledQuery, error) {
+	qs, err := listRecords[ScheduledQuery](s, schedPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("list scheduled queries: %w", err)
+	}
+	return qs, nil
+}
+
+// DeleteScheduledQuery removes the definition and its recorded points, or
+// returns ErrNotFound.
+func (s *BadgerStorage) DeleteScheduledQuery(name string) error {
+	if err := s.deleteRecord(scheduledKey(name)); err != nil {
+		return fmt.Errorf("delete scheduled query %s: %w", name, err)
+	}
+	if err := s.db.DropPrefix([]byte(seriesKeyPrefix(name))); err != nil {
+		return fmt.Errorf("delete scheduled query %s series: %w", name, err)
+	}
+	return nil
+}
+
+// RecordScheduledResult appends a point to the query's series, updates its
+// last run state and prunes points beyond seriesLimit.
+func (s *BadgerStorage) RecordScheduledResult(name string, at time.Time, count int) error {
+	q, err := s.GetScheduledQuery(name)
+	if err != nil {
+		return err
+	}
+	q.LastRun, q.LastCount = at.UTC(), count
+
+	point, err := json.Marshal(SeriesPoint{Timestamp: at.UTC(), Count: count})
+	if err != nil {
+		return err
+	}
+	def, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Update(func(txn *badger.Txn) error {
+		if err := txn.Set(seriesKey(name, at), point); err != nil {
+			return err
+		}
+		return txn.Set(scheduledKey(name), def)
+	})
+	if err != nil {
+		return fmt.Errorf("record scheduled result %s: %w", name, err)
+	}
+
+	return s.pruneSeries(name)
+}
+
+// GetSeries returns the recorded points for name within tr (nil for all),
+// oldest first.
+func (s *BadgerStorage) GetSeries(name string, tr *TimeRange) ([]SeriesPoint, error) {
+	points := []SeriesPoint{}
+	prefix := []byte(seriesKeyPrefix(name))
+	r := newKeyRange(tr)
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		seek := prefix
+		if r.start != 0 {
+			seek = seriesKey(name, time.Unix(0, r.start))
+		}
+		for it.Seek(seek); it.ValidForPrefix(prefix); it.Next() {
+			var p SeriesPoint
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &p)
+			}); err != nil {
+				return err
+			}
+			if r.end != 0 && p.Timestamp.UnixNano() > r.end {
+				break
+			}
+			points = append(points, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get series %s: %w", name, err)
+	}
+	return points, nil
+}
+
+// pruneSeries deletes the oldest points of name beyond seriesLimit.
+func (s *BadgerStorage) pruneSeries(name string) error {
+	prefix := []byte(seriesKeyPrefix(name))
+
+	var keys [][]byte
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			keys = append(keys, it.Item().KeyCopy(nil))
+		}
+		return nil
+	})
+	if err != nil || len(keys) <= seriesLimit {
+		return err
+	}
+
+	return s.db.Update(func(txn *badger.Txn) error {
+		for _, key := range keys[:len(keys)-seriesLimit] {
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// scheduledKey returns the key holding a scheduled query definition.
+func scheduledKey(name string) []byte {
+	return []byte(schedPrefix + name)
+}
+
+// seriesKeyPrefix returns the key prefix shared by all points of name.
+func seriesKeyPrefix(name string) string {
+	return seriesPrefix + name + ":"
+}
+
+// seriesKey returns series:{name}:{timestamp_nano}; like log keys, 19-digit
+// nanosecond timestamps keep lexicographic order chronological.
+func seriesKey(name string, at time.Time) []byte {
+	return []byte(fmt.Sprintf("%s%d", seriesKeyPrefix(name), at.UnixNano()))
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func TestValidateScheduledQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		q       ScheduledQuery
+		wantErr bool
+	}{
+		{name: "valid", q: ScheduledQuery{Name: "errors", Query: "level:ERROR", Interval: "1m"}},
+		{name: "missing name", q: ScheduledQuery{Interval: "1m"}, wantErr: true},
+		{name: "name with colon", q: ScheduledQuery{Name: "a:b", Interval: "1m"}, wantErr: true},
+		{name: "name with slash", q: ScheduledQuery{Name: "a/b", Interval: "1m"}, wantErr: true},
+		{name: "invalid interval", q: ScheduledQuery{Name: "errors", Interval: "often"}, wantErr: true},
+		{name: "interval too short", q: ScheduledQuery{Name: "errors", Interval: "1s"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateScheduledQuery(&tt.q); (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateScheduledQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScheduledQueriesCRUD(t *testing.T) {
+	s := newBehaviorStorage(t)
+
+	q := &ScheduledQuery{Name: "errors", Query: "level:ERROR", Interval: "1m"}
+	if err := s.SaveScheduledQuery(q); err != nil {
+		t.Fatalf("SaveScheduledQuery() error = %v", err)
+	}
+	at := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	if err := s.RecordScheduledResult("errors", at, 3); err != nil {
+		t.Fatalf("RecordScheduledResult() error = %v", err)
+	}
+
+	// Replacing the definition keeps the run state.
+	if err := s.SaveScheduledQuery(&ScheduledQuery{Name: "errors", Query: "level:WARN", Interval: "5m"}); err != nil {
+		t.Fatalf("SaveScheduledQuery() replace error = %v", err)
+	}
+	got, err := s.GetScheduledQuery("errors")
+	if err != nil {
+		t.Fatalf("GetScheduledQuery() error = %v", err)
+	}
+	if got.Query != "level:WARN" || !got.LastRun.Equal(at) || got.LastCount != 3 {
+		t.Fatalf("GetScheduledQuery() = %+v, want replaced query with run state kept", got)
+	}
+
+	if list, err := s.ListScheduledQueries(); err != nil || len(list) != 1 {
+		t.Fatalf("ListScheduledQueries() = %+v, %v", list, err)
+	}
+
+	if err := s.DeleteScheduledQuery("errors"); err != nil {
+		t.Fatalf("DeleteScheduledQuery() error = %v", err)
+	}
+	if _, err := s.GetScheduledQuery("errors"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetScheduledQuery() after delete error = %v, want ErrNotFound", err)
+	}
+	if err := s.DeleteScheduledQuery("errors"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("DeleteScheduledQuery() missing error = %v, want ErrNotFound", err)
+	}
+
+	// Series points are removed with the definition.
+	if err := s.SaveScheduledQuery(q); err != nil {
+		t.Fatalf("SaveScheduledQuery() error = %v", err)
+	}
+	if points, err := s.GetSeries("errors", nil); err != nil || len(points) != 0 {
+		t.Fatalf("GetSeries() after recreate = %+v, %v, want empty", points, err)
+	}
+}
+
+func TestScheduledSeries(t *testing.T) {
+	s := newBehaviorStorage(t)
+
+	if err := s.RecordScheduledResult("missing", time.Now(), 1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("RecordScheduledResult() missing error = %v, want ErrNotFound", err)
+	}
+
+	// A name that prefixes another must not see its points.
+	for _, name := range []string{"err", "errors"} {
+		if err := s.SaveScheduledQuery(&ScheduledQuery{Name: name, Query: "*", Interval: "1m"}); err != nil {
+			t.Fatalf("SaveScheduledQuery(%s) error = %v", name, err)
+		}
+	}
+
+	base := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		if err := s.RecordScheduledResult("errors", base.Add(time.Duration(i)*time.Minute), i); err != nil {
+			t.Fatalf("RecordScheduledResult() error = %v", err)
+		}
+	}
+
+	all, err := s.GetSeries("errors", nil)
+	if err != nil || len(all) != 5 || all[0].Count != 0 || all[4].Count != 4 {
+		t.Fatalf("GetSeries() = %+v, %v, want 5 points oldest first", all, err)
+	}
+	if other, err := s.GetSeries("err", nil); err != nil || len(other) != 0 {
+		t.Fatalf("GetSeries(err) = %+v, %v, want empty", other, err)
+	}
+
+	ranged, err := s.GetSeries("errors", &TimeRange{Start: base.Add(time.Minute), End: base.Add(3 * time.Minute)})
+	if err != nil || len(ranged) != 3 || ranged[0].Count != 1 || ranged[2].Count != 3 {
+		t.Fatalf("GetSeries() ranged = %+v, %v, want counts 1..3", ranged, err)
+	}
+}
+
+func TestPruneSeries(t *testing.T) {
+	s := newBehaviorStorage(t)
+	if err := s.SaveScheduledQuery(&ScheduledQuery{Name: "errors", Query: "*", Interval: "1m"}); err != nil {
+		t.Fatalf("SaveScheduledQuery() error = %v", err)
+	}
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	err := s.db.Update(func(txn *badger.Txn) error {
+		for i := 0; i < seriesLimit+2; i++ {
+			if err := txn.Set(seriesKey("errors", base.Add(time.Duration(i)*time.Minute)), []byte(`{"count":0}`)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("seed points error = %v", err)
+	}
+	if err := s.pruneSeries("errors"); err != nil {
+		t.Fatalf("pruneSeries() error = %v", err)
+	}
+
+	points, err := s.GetSeries("errors", nil)
+	if err != nil || len(points) != seriesLimit {
+		t.Fatalf("GetSeries() after prune = %d points, %v, want %d", len(points), err, seriesLimit)
+	}
+}
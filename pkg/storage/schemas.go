@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Schema is one shape of entries: the set of field names they carry.
+type Schema struct {
+	// ID is a short hash of Fields, stable across calls.
+	ID     string   `json:"id"`
+	Fields []string `json:"fields"`
+	Count  int      `json:"count"`
+	// Examples are the most recent entries of this shape, newest first.
+	Examples  []*LogEntry `json:"examples"`
+	FirstSeen time.Time   `json:"first_seen"`
+	LastSeen  time.Time   `json:"last_seen"`
+}
+
+// maxSchemas bounds the shapes GetSchemas tracks; entries of further shapes
+// are only counted.
+const maxSchemas = 1000
+
+// schemaFields returns the sorted field names that make up entry's shape.
+func schemaFields(entry *LogEntry) []string {
+	names := make([]string, 0, len(entry.Fields))
+	for k := range entry.Fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// schemaID hashes a sorted field name list.
+func schemaID(fields []string) string {
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join(fields, "\x00")))
+	return fmt.Sprintf("%016x", h.Sum64())[:8]
+}
+
+// GetSchemas groups entries within tr (nil for all time) that match filter
+// (nil for all) by their field names and returns the shapes, most common
+// first, each with up to examples recent entries. Entries whose shape did not
+// fit among the first maxSchemas are counted in other.
+func (s *BadgerStorage) GetSchemas(ctx context.Context, filter Filter, tr *TimeRange, examples int) (schemas []Schema, other int, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	groups := make(map[string]*Schema)
+
+	err = s.db.View(func(txn *badger.Txn) error {
+		r := newKeyRange(tr)
+
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte(logPrefix)
+		seekKey := prefix
+		if r.start != 0 {
+			seekKey = logSeekKey(r.start)
+		}
+
+		metaFilter, _ := filter.(MetaFilter)
+
+		visited := 0
+		for it.Seek(seekKey); it.ValidForPrefix(prefix); it.Next() {
+			visited++
+			if visited%ctxCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+
+			item := it.Item()
+			if r.end != 0 {
+				if ts, ok := keyTimestamp(item.Key()); ok && ts > r.end {
+					break
+				}
+			}
+			if metaFilter != nil {
+				if match, certain := metaFilter.MatchMeta(itemMeta(item)); certain && !match {
+					continue
+				}
+			}
+
+			err := item.Value(func(val []byte) error {
+				entry, err := FromJSON(val)
+				if err != nil || (filter != nil && !filter.Match(entry)) {
+					return nil
+				}
+
+				fields := schemaFields(entry)
+				key := strings.Join(fields, "\x00")
+				g, ok := groups[key]
+				if !ok {
+					if len(groups) >= maxSchemas {
+						other++
+						return nil
+					}
+					g = &Schema{ID: schemaID(fields), Fields: fields, FirstSeen: entry.Timestamp}
+					groups[key] = g
+				}
+				g.Count++
+				if entry.Timestamp.Before(g.FirstSeen) {
+					g.FirstSeen = entry.Timestamp
+				}
+				if entry.Timestamp.After(g.LastSeen) {
+					g.LastSeen = entry.Timestamp
+				}
+				if examples > 0 {
+					// Keys are chronological, so the newest entry comes last.
+					g.Examples = append([]*LogEntry{entry}, g.Examples...)
+					if len(g.Examples) > examples {
+						g.Examples = g.Examples[:examples]
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("get schemas: %w", err)
+	}
+
+	schemas = make([]Schema, 0, len(groups))
+	for _, g := range groups {
+		schemas = append(schemas, *g)
+	}
+	sort.Slice(schemas, func(i, j int) bool {
+		a, b := schemas[i], schemas[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return strings.Join(a.Fields, ",") < strings.Join(b.Fields, ",")
+	})
+	return schemas, other, nil
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetSchemas(t *testing.T) {
+	s := newBehaviorStorage(t)
+	base := time.Now().UTC().Add(-time.Hour)
+	addEntry(t, s, "a1", base, "INFO", map[string]interface{}{"path": "/a", "status": float64(200)})
+	addEntry(t, s, "b1", base.Add(time.Minute), "ERROR", map[string]interface{}{"job": "sync"})
+	addEntry(t, s, "a2", base.Add(2*time.Minute), "INFO", map[string]interface{}{"status": float64(500), "path": "/b"})
+	addEntry(t, s, "a3", base.Add(3*time.Minute), "WARN", map[string]interface{}{"path": "/c", "status": float64(404)})
+	addEntry(t, s, "c1", base.Add(4*time.Minute), "INFO", nil)
+
+	shapes := func(schemas []Schema) string {
+		out := make([]string, len(schemas))
+		for i, sc := range schemas {
+			out[i] = fmt.Sprintf("%s=%d", strings.Join(sc.Fields, "+"), sc.Count)
+		}
+		return strings.Join(out, ",")
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		tr     *TimeRange
+		want   string
+	}{
+		{name: "all", want: "path+status=3,=1,job=1"},
+		{name: "filter", filter: levelOnly("INFO"), want: "path+status=2,=1"},
+		{name: "time range", tr: &TimeRange{Start: base.Add(90 * time.Second), End: base.Add(150 * time.Second)}, want: "path+status=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, other, err := s.GetSchemas(context.Background(), tt.filter, tt.tr, 2)
+			if err != nil || other != 0 {
+				t.Fatalf("GetSchemas() other = %d, error = %v", other, err)
+			}
+			if shapes(got) != tt.want {
+				t.Fatalf("GetSchemas() = %s, want %s", shapes(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestGetSchemasExamplesAndLimit(t *testing.T) {
+	s := newBehaviorStorage(t)
+	base := time.Now().UTC().Add(-time.Hour)
+	for i := 0; i < 3; i++ {
+		addEntry(t, s, fmt.Sprintf("a%d", i), base.Add(time.Duration(i)*time.Minute), "INFO", map[string]interface{}{"path": "/a"})
+	}
+	for i := 0; i < maxSchemas+2; i++ {
+		addEntry(t, s, fmt.Sprintf("u%d", i), base.Add(time.Hour), "INFO", map[string]interface{}{fmt.Sprintf("f%d", i): 1.0})
+	}
+
+	schemas, other, err := s.GetSchemas(context.Background(), nil, nil, 2)
+	if err != nil {
+		t.Fatalf("GetSchemas() error = %v", err)
+	}
+	if len(schemas) != maxSchemas || other != 3 {
+		t.Fatalf("GetSchemas() = %d schemas, other %d, want %d and 3", len(schemas), other, maxSchemas)
+	}
+	top := schemas[0]
+	if top.Count != 3 || len(top.Examples) != 2 || top.Examples[0].ID != "a2" || top.Examples[1].ID != "a1" {
+		t.Fatalf("top schema = %+v, want path x3 with examples a2, a1", top)
+	}
+	if !top.FirstSeen.Equal(base) || !top.LastSeen.Equal(base.Add(2*time.Minute)) {
+		t.Fatalf("top schema seen %v..%v", top.FirstSeen, top.LastSeen)
+	}
+	if top.ID != schemaID([]string{"path"}) || len(top.ID) != 8 {
+		t.Fatalf("top schema ID = %q", top.ID)
+	}
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// SessionInfo summarizes the entries ingested by one collect session.
+type SessionInfo struct {
+	Session string    `json:"session"`
+	Name    string    `json:"name,omitempty"` // upstream command or --name
+	Count   int       `json:"count"`
+	First   time.Time `json:"first"` // oldest entry timestamp
+	Last    time.Time `json:"last"`  // newest entry timestamp
+}
+
+// sessionRecord is the value stored under sessionPrefix.
+type sessionRecord struct {
+	Name string `json:"name"`
+}
+
+// SetSessionName records a readable name for session, such as the command
+// piping into peek. Names are listed by GetSessions.
+func (s *BadgerStorage) SetSessionName(session, name string) error {
+	if session == "" {
+		return fmt.Errorf("session is required")
+	}
+	if err := s.putRecord([]byte(sessionPrefix+session), sessionRecord{Name: name}); err != nil {
+		return fmt.Errorf("set session name: %w", err)
+	}
+	return nil
+}
+
+// GetSessions returns the sessions of all stored entries, most recently
+// active first. Entries without a session are not counted.
+func (s *BadgerStorage) GetSessions(ctx context.Context) ([]SessionInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sessions := make(map[string]*SessionInfo)
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte(logPrefix)
+		visited := 0
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			visited++
+			if visited%ctxCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+
+			err := it.Item().Value(func(val []byte) error {
+				entry, err := FromJSON(val)
+				if err != nil || entry.Session == "" {
+					return nil
+				}
+				info, ok := sessions[entry.Session]
+				if !ok {
+					info = &SessionInfo{Session: entry.Session, First: entry.Timestamp, Last: entry.Timestamp}
+					sessions[entry.Session] = info
+				}
+				info.Count++
+				if entry.Timestamp.Before(info.First) {
+					info.First = entry.Timestamp
+				}
+				if entry.Timestamp.After(info.Last) {
+					info.Last = entry.Timestamp
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return s.sessionNames(txn, sessions)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get sessions: %w", err)
+	}
+
+	result := make([]SessionInfo, 0, len(sessions))
+	for _, info := range sessions {
+		result = append(result, *info)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Last.Equal(result[j].Last) {
+			return result[i].Last.After(result[j].Last)
+		}
+		return result[i].Session < result[j].Session
+	})
+	return result, nil
+}
+
+// sessionNames fills in the recorded names of sessions.
+func (s *BadgerStorage) sessionNames(txn *badger.Txn, sessions map[string]*SessionInfo) error {
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	prefix := []byte(sessionPrefix)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		info, ok := sessions[string(it.Item().Key()[len(prefix):])]
+		if !ok {
+			continue
+		}
+		var rec sessionRecord
+		if err := it.Item().Value(func(val []byte) error {
+			return json.Unmarshal(val, &rec)
+		}); err != nil {
+			return err
+		}
+		info.Name = rec.Name
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetSessions(t *testing.T) {
+	s := newBehaviorStorage(t)
+	base := time.Now().UTC().Add(-time.Hour)
+
+	for _, e := range []*LogEntry{
+		{ID: "a1", Timestamp: base, Session: "run-a"},
+		{ID: "a2", Timestamp: base.Add(2 * time.Minute), Session: "run-a"},
+		{ID: "b1", Timestamp: base.Add(5 * time.Minute), Session: "run-b"},
+		{ID: "x", Timestamp: base.Add(10 * time.Minute)},
+	} {
+		if err := s.Store(e); err != nil {
+			t.Fatalf("Store() error = %v", err)
+		}
+	}
+
+	if err := s.SetSessionName("run-b", "kubectl logs api-7f9c -f"); err != nil {
+		t.Fatalf("SetSessionName() error = %v", err)
+	}
+	if err := s.SetSessionName("run-gone", "tail -f app.log"); err != nil {
+		t.Fatalf("SetSessionName() error = %v", err)
+	}
+
+	got, err := s.GetSessions(context.Background())
+	if err != nil {
+		t.Fatalf("GetSessions() error = %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("GetSessions() = %+v, want 2 sessions", got)
+	}
+	if got[0].Session != "run-b" || got[0].Count != 1 || got[0].Name != "kubectl logs api-7f9c -f" {
+		t.Fatalf("GetSessions()[0] = %+v, want named run-b first", got[0])
+	}
+	if a := got[1]; a.Session != "run-a" || a.Name != "" || a.Count != 2 || !a.First.Equal(base) || !a.Last.Equal(base.Add(2*time.Minute)) {
+		t.Fatalf("GetSessions()[1] = %+v, want run-a with 2 entries spanning 2m", a)
+	}
+}
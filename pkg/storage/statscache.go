@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// statsCacheTTL is how long cached stats are served while entries are
+	// being written, which bounds stats passes to one per TTL under ingest.
+	statsCacheTTL = 2 * time.Second
+
+	// statsCacheMaxAge is how long cached stats are served while nothing is
+	// written, so sizes still catch up with compaction on an idle database.
+	statsCacheMaxAge = 30 * time.Second
+)
+
+// statsCache holds the last Stats pass for CachedStats. Stores invalidate it
+// by advancing writeCount; deletes, which can shrink the totals at once,
+// drop it through invalidateStats.
+type statsCache struct {
+	mu     sync.Mutex // held across a refresh so concurrent callers share one pass
+	stats  Stats
+	writes int // writeCount when stats was computed
+	at     time.Time
+	valid  bool
+}
+
+// CachedStats returns storage statistics for frequently polled endpoints
+// such as /stats and /health. A cached result is reused while nothing was
+// written since it was computed (up to statsCacheMaxAge), or for
+// statsCacheTTL under ingest; otherwise it is recomputed with GetStats.
+func (s *BadgerStorage) CachedStats() (Stats, error) {
+	return s.cachedStatsAt(time.Now())
+}
+
+func (s *BadgerStorage) cachedStatsAt(now time.Time) (Stats, error) {
+	s.mu.RLock()
+	writes := s.writeCount
+	s.mu.RUnlock()
+
+	c := &s.statsCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.valid {
+		age := now.Sub(c.at)
+		if age < statsCacheTTL || (writes == c.writes && age < statsCacheMaxAge) {
+			return c.stats.clone(), nil
+		}
+	}
+
+	stats, err := s.statsAt(now)
+	if err != nil {
+		return Stats{}, err
+	}
+	c.stats, c.writes, c.at, c.valid = stats, writes, now, true
+	return stats.clone(), nil
+}
+
+// invalidateStats makes the next CachedStats call recompute and advances
+// the write generation. Deletes, retention and rewrites call it.
+func (s *BadgerStorage) invalidateStats() {
+	s.generation.Add(1)
+	s.statsCache.mu.Lock()
+	s.statsCache.valid = false
+	s.statsCache.mu.Unlock()
+}
+
+// clone copies st so callers cannot mutate the cached Levels map or the
+// volume breakdowns.
+func (st Stats) clone() Stats {
+	levels := make(map[string]int, len(st.Levels))
+	for level, n := range st.Levels {
+		levels[level] = n
+	}
+	st.Levels = levels
+	st.Sources = slices.Clone(st.Sources)
+	st.Sessions = slices.Clone(st.Sessions)
+	return st
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCachedStatsInvalidation(t *testing.T) {
+	s := newBehaviorStorage(t)
+	now := time.Now().UTC()
+	addEntry(t, s, "a", now, "INFO", nil)
+
+	first, err := s.cachedStatsAt(now)
+	if err != nil {
+		t.Fatalf("cachedStatsAt() error = %v", err)
+	}
+	if first.TotalLogs != 1 {
+		t.Fatalf("TotalLogs = %d, want 1", first.TotalLogs)
+	}
+
+	steps := []struct {
+		name   string
+		mutate func()
+		at     time.Duration // since the first pass
+		want   int
+	}{
+		{name: "idle within max age is cached", at: 20 * time.Second, want: 1},
+		{name: "write within ttl is served cached", mutate: func() { addEntry(t, s, "b", now, "INFO", nil) }, at: time.Second, want: 1},
+		{name: "write past ttl recomputes", at: statsCacheTTL + time.Second, want: 2},
+		{name: "delete recomputes at once", mutate: func() {
+			if _, err := s.DeleteByLevel("INFO"); err != nil {
+				t.Fatalf("DeleteByLevel() error = %v", err)
+			}
+		}, at: statsCacheTTL + 2*time.Second, want: 0},
+		{name: "later write past ttl recomputes", mutate: func() { addEntry(t, s, "c", now, "ERROR", nil) }, at: statsCacheTTL + 2*time.Second + statsCacheMaxAge, want: 1},
+	}
+	for _, step := range steps {
+		if step.mutate != nil {
+			step.mutate()
+		}
+		got, err := s.cachedStatsAt(now.Add(step.at))
+		if err != nil {
+			t.Fatalf("%s: cachedStatsAt() error = %v", step.name, err)
+		}
+		if got.TotalLogs != step.want {
+			t.Fatalf("%s: TotalLogs = %d, want %d", step.name, got.TotalLogs, step.want)
+		}
+	}
+}
+
+func TestCachedStatsReturnsCopy(t *testing.T) {
+	s := newBehaviorStorage(t)
+	addEntry(t, s, "a", time.Now().UTC(), "INFO", nil)
+
+	first, err := s.CachedStats()
+	if err != nil {
+		t.Fatalf("CachedStats() error = %v", err)
+	}
+	first.Levels["INFO"] = 99
+
+	second, err := s.CachedStats()
+	if err != nil {
+		t.Fatalf("CachedStats() error = %v", err)
+	}
+	if second.Levels["INFO"] != 1 {
+		t.Fatalf("cached Levels[INFO] = %d, want 1", second.Levels["INFO"])
+	}
+}
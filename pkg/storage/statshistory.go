@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+const (
+	// StatsHistoryInterval is the spacing of recorded stats snapshots.
+	StatsHistoryInterval = 5 * time.Minute
+	// statsHistorySlots is the size of the snapshot ring: a day of
+	// StatsHistoryInterval snapshots.
+	statsHistorySlots = 288
+	// StatsHistorySpan is how far back the snapshot ring reaches.
+	StatsHistorySpan = statsHistorySlots * StatsHistoryInterval
+)
+
+// RecordStatsSnapshot stores the current total, per-level counts and
+// database size as the snapshot of the StatsHistoryInterval containing at.
+// Snapshots live in a fixed ring of statsHistorySlots keys, so recording
+// overwrites the one from StatsHistorySpan ago instead of growing.
+func (s *BadgerStorage) RecordStatsSnapshot(at time.Time) error {
+	stats, err := s.CachedStats()
+	if err != nil {
+		return fmt.Errorf("record stats snapshot: %w", err)
+	}
+	snap := StatsSnapshot{
+		Timestamp:   at.UTC(),
+		TotalLogs:   stats.TotalLogs,
+		Levels:      stats.Levels,
+		DBSizeBytes: stats.LSMSizeBytes + stats.VlogSizeBytes,
+	}
+	if err := s.putRecord(statsHistoryKey(at), snap); err != nil {
+		return fmt.Errorf("record stats snapshot: %w", err)
+	}
+	return nil
+}
+
+// StatsHistory returns the recorded snapshots taken at or after since,
+// oldest first. Snapshots older than StatsHistorySpan before now have been
+// overwritten or are left over from an earlier run, and are skipped.
+func (s *BadgerStorage) StatsHistory(since, now time.Time) ([]StatsSnapshot, error) {
+	if oldest := now.Add(-StatsHistorySpan); since.Before(oldest) {
+		since = oldest
+	}
+	snaps := []StatsSnapshot{}
+	prefix := []byte(statsHistPrefix)
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var snap StatsSnapshot
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &snap)
+			}); err != nil {
+				return err
+			}
+			if !snap.Timestamp.Before(since) && !snap.Timestamp.After(now) {
+				snaps = append(snaps, snap)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get stats history: %w", err)
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].Timestamp.Before(snaps[j].Timestamp) })
+	return snaps, nil
+}
+
+// statsHistoryKey returns statshist:{slot}, the ring slot of the
+// StatsHistoryInterval containing at.
+func statsHistoryKey(at time.Time) []byte {
+	slot := at.Unix() / int64(StatsHistoryInterval/time.Second) % statsHistorySlots
+	return []byte(fmt.Sprintf("%s%03d", statsHistPrefix, slot))
+}
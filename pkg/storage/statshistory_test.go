@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func TestStatsHistory(t *testing.T) {
+	s := newBehaviorStorage(t)
+	if err := s.Store(&LogEntry{ID: "1", Timestamp: time.Now(), Level: "ERROR", Message: "boom"}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	start := time.Date(2026, 2, 17, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		if err := s.RecordStatsSnapshot(start.Add(time.Duration(i) * StatsHistoryInterval)); err != nil {
+			t.Fatalf("RecordStatsSnapshot() error = %v", err)
+		}
+	}
+	// A second snapshot within an interval replaces the first.
+	last := start.Add(2*StatsHistoryInterval + time.Minute)
+	if err := s.RecordStatsSnapshot(last); err != nil {
+		t.Fatalf("RecordStatsSnapshot() error = %v", err)
+	}
+
+	snaps, err := s.StatsHistory(start, last)
+	if err != nil {
+		t.Fatalf("StatsHistory() error = %v", err)
+	}
+	if len(snaps) != 3 || !snaps[0].Timestamp.Equal(start) || !snaps[2].Timestamp.Equal(last) {
+		t.Fatalf("snapshots = %+v", snaps)
+	}
+	if snaps[0].TotalLogs != 1 || snaps[0].Levels["ERROR"] != 1 || snaps[0].DBSizeBytes < 0 {
+		t.Errorf("snapshot = %+v", snaps[0])
+	}
+
+	if snaps, _ := s.StatsHistory(start.Add(StatsHistoryInterval), last); len(snaps) != 2 {
+		t.Errorf("since second snapshot = %d snapshots, want 2", len(snaps))
+	}
+
+	// A day later the ring has wrapped: the first slot is overwritten and
+	// the snapshots left from the previous day are out of range.
+	wrapped := start.Add(StatsHistorySpan)
+	if err := s.RecordStatsSnapshot(wrapped); err != nil {
+		t.Fatalf("RecordStatsSnapshot() error = %v", err)
+	}
+	snaps, err = s.StatsHistory(time.Time{}, wrapped)
+	if err != nil {
+		t.Fatalf("StatsHistory() error = %v", err)
+	}
+	if len(snaps) != 3 || !snaps[2].Timestamp.Equal(wrapped) {
+		t.Fatalf("after wrapping = %+v", snaps)
+	}
+	keys := 0
+	s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.IteratorOptions{Prefix: []byte(statsHistPrefix)})
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			keys++
+		}
+		return nil
+	})
+	if keys != 3 {
+		t.Errorf("ring keys = %d, want 3", keys)
+	}
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 )
 
@@ -12,14 +13,150 @@ type LogEntry struct {
 	Level     string                 `json:"level"`
 	Message   string                 `json:"message"`
 	Fields    map[string]interface{} `json:"fields"`
-	Raw       string                 `json:"raw"`
+	Raw       string                 `json:"raw,omitempty"`
+	// Session identifies the collect run that ingested the entry.
+	Session string `json:"session,omitempty"`
+	// Namespace isolates entries pushed with a namespaced API token; empty
+	// for locally collected entries.
+	Namespace string `json:"namespace,omitempty"`
+	// TraceID and SpanID carry trace context promoted out of Fields by the
+	// parsers. Entries stored before they existed keep the ids in Fields;
+	// TraceContext reads either.
+	TraceID string `json:"trace_id,omitempty"`
+	SpanID  string `json:"span_id,omitempty"`
+	// Source names the input the entry came from (file path, container,
+	// pod, socket peer), as set by the collector.
+	Source string `json:"source,omitempty"`
+	// Host identifies the machine the entry was collected on, when host
+	// metadata is enabled.
+	Host *HostInfo `json:"host,omitempty"`
+}
+
+// HostInfo identifies a machine and account that collected entries. Queries
+// address its parts as host.name, host.os and host.user.
+type HostInfo struct {
+	Name string `json:"name,omitempty"`
+	OS   string `json:"os,omitempty"`
+	User string `json:"user,omitempty"`
+}
+
+// HostField returns the value of the host.* field called name; ok is false
+// when name is not a host field.
+func (l *LogEntry) HostField(name string) (value string, ok bool) {
+	var h HostInfo
+	if l.Host != nil {
+		h = *l.Host
+	}
+	switch name {
+	case "host.name":
+		return h.Name, true
+	case "host.os":
+		return h.OS, true
+	case "host.user":
+		return h.User, true
+	}
+	return "", false
+}
+
+// TraceContext returns the entry's trace and span ids, falling back to
+// trace_id and span_id in Fields for entries stored before promotion.
+func (l *LogEntry) TraceContext() (traceID, spanID string) {
+	traceID, spanID = l.TraceID, l.SpanID
+	if v, ok := l.Fields["trace_id"]; ok && traceID == "" {
+		traceID = fmt.Sprintf("%v", v)
+	}
+	if v, ok := l.Fields["span_id"]; ok && spanID == "" {
+		spanID = fmt.Sprintf("%v", v)
+	}
+	return traceID, spanID
+}
+
+// SourceName returns the entry's source, falling back to a "source" field
+// for entries stored before collectors set it.
+func (l *LogEntry) SourceName() string {
+	if v, ok := l.Fields["source"]; ok && l.Source == "" {
+		return fmt.Sprintf("%v", v)
+	}
+	return l.Source
 }
 
 // FieldInfo describes a field name observed in stored logs and its most common values.
 type FieldInfo struct {
-	Name      string   `json:"name"`
+	Name string `json:"name"`
+	// Type is inferred from observed values; see the FieldType constants.
 	Type      string   `json:"type"`
 	TopValues []string `json:"top_values"`
+	// Cardinality is the number of distinct values: exact for ordinary
+	// fields, a HyperLogLog estimate when HighCardinality is set.
+	Cardinality uint64 `json:"cardinality"`
+	// HighCardinality marks fields with too many distinct values to list;
+	// their TopValues are empty.
+	HighCardinality bool `json:"high_cardinality,omitempty"`
+}
+
+// View is a named, saved combination of query, columns and time range.
+type View struct {
+	Name    string   `json:"name"`
+	Query   string   `json:"query"`
+	Columns []string `json:"columns,omitempty"`
+	// TimePreset is a UI preset (all, 15m, 1h, ...); "custom" uses Start/End.
+	TimePreset string     `json:"time_preset,omitempty"`
+	Start      *time.Time `json:"start,omitempty"`
+	End        *time.Time `json:"end,omitempty"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	// Namespace is the namespace of the API token that saved the view;
+	// only that namespace and admin tokens see it.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Investigation is a named collection of entries, queries and notes
+// gathered while debugging.
+type Investigation struct {
+	Name      string    `json:"name"`
+	EntryIDs  []string  `json:"entry_ids"`
+	Queries   []string  `json:"queries"`
+	Notes     string    `json:"notes,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Namespace is the namespace of the API token that saved the
+	// investigation; only that namespace and admin tokens see it.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// ScheduledQuery is a query counted on a fixed interval; each run records
+// the number of matches in the preceding interval as a SeriesPoint.
+type ScheduledQuery struct {
+	Name      string    `json:"name"`
+	Query     string    `json:"query"`
+	Interval  string    `json:"interval"` // Go duration, e.g. "1m"
+	LastRun   time.Time `json:"last_run,omitempty"`
+	LastCount int       `json:"last_count"`
+	// Namespace is the namespace of the API token that saved the query.
+	// Runs only count entries of that namespace; empty counts them all.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// SeriesPoint is one recorded result of a scheduled query.
+type SeriesPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Count     int       `json:"count"`
+}
+
+// StatsSnapshot is a periodic record of the storage totals, kept for
+// trends such as ingestion and error rates over the past day.
+type StatsSnapshot struct {
+	Timestamp   time.Time      `json:"timestamp"`
+	TotalLogs   int            `json:"total_logs"`
+	Levels      map[string]int `json:"levels"`
+	DBSizeBytes int64          `json:"db_size_bytes"`
+}
+
+// Annotation marks a stored entry as pinned and/or attaches a note to it.
+type Annotation struct {
+	ID        string    `json:"id"`
+	Pinned    bool      `json:"pinned"`
+	Note      string    `json:"note,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 // TimeRange holds optional time bounds for storage-level key seeking.
@@ -29,6 +166,23 @@ type TimeRange struct {
 	End   time.Time
 }
 
+// EntryMeta is the per-entry metadata available from a key and its Badger
+// user-meta byte, without decoding the stored value.
+type EntryMeta struct {
+	Timestamp time.Time
+	Level     string
+	// LevelKnown is false for entries written before level metadata existed
+	// and for non-standard levels that have no meta code.
+	LevelKnown bool
+}
+
+// MetaFilter is implemented by filters that can be evaluated against
+// EntryMeta. When certain is true, match is the final answer and the entry
+// value does not need to be decoded.
+type MetaFilter interface {
+	MatchMeta(meta EntryMeta) (match, certain bool)
+}
+
 // ToJSON serializes the LogEntry to JSON
 func (l *LogEntry) ToJSON() ([]byte, error) {
 	return json.Marshal(l)
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Issue kinds reported by Verify.
+const (
+	IssueBadKey            = "bad_key"            // log key without a timestamp or ID, or in the wrong bucket
+	IssueCorrupt           = "corrupt"            // value is not a decodable entry
+	IssueIDMismatch        = "id_mismatch"        // entry ID differs from the key's
+	IssueTimestampMismatch = "timestamp_mismatch" // entry timestamp differs from the key's
+	IssueOrphanRaw         = "orphan_raw"         // raw:{id} without a log entry
+	IssueOrphanAnnotation  = "orphan_annotation"  // meta:{id} without a log entry
+	IssueOrphanIndex       = "orphan_index"       // trace: or source: index key without its log entry
+)
+
+// VerifyIssue is a record that failed verification.
+type VerifyIssue struct {
+	Key    string `json:"key"`
+	Kind   string `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// VerifyResult summarizes a Verify run.
+type VerifyResult struct {
+	Checked     int           `json:"checked"`
+	Issues      []VerifyIssue `json:"issues"`
+	Quarantined int           `json:"quarantined"`
+}
+
+// Verify checks that every log entry decodes and agrees with its key, and
+// that every raw line and annotation belongs to an existing entry. With
+// quarantine set, flagged records are moved under quarantine:{key} (log
+// entries together with their raw line and annotation) so queries, stats and
+// retention no longer see them while the data stays recoverable.
+func (s *BadgerStorage) Verify(ctx context.Context, quarantine bool) (VerifyResult, error) {
+	defer s.invalidateStats()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := VerifyResult{Issues: []VerifyIssue{}}
+	ids := make(map[string]bool)
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte(logPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			res.Checked++
+			if res.Checked%ctxCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+
+			item := it.Item()
+			key := string(item.Key())
+			ts, tsOK := keyTimestamp(item.Key())
+			id, idOK := keyID(item.Key())
+			if !tsOK || !idOK || id == "" || !bytes.HasPrefix(item.Key(), logBucketPrefix(ts)) {
+				res.Issues = append(res.Issues, VerifyIssue{Key: key, Kind: IssueBadKey})
+				continue
+			}
+			ids[id] = true
+
+			var entry *LogEntry
+			err := item.Value(func(val []byte) error {
+				var err error
+				entry, err = FromJSON(val)
+				return err
+			})
+			switch {
+			case err != nil:
+				res.Issues = append(res.Issues, VerifyIssue{Key: key, Kind: IssueCorrupt, Detail: err.Error()})
+			case entry.ID != id:
+				res.Issues = append(res.Issues, VerifyIssue{Key: key, Kind: IssueIDMismatch, Detail: fmt.Sprintf("entry id %q", entry.ID)})
+			case entry.Timestamp.UnixNano() != ts:
+				res.Issues = append(res.Issues, VerifyIssue{Key: key, Kind: IssueTimestampMismatch, Detail: "entry timestamp " + strconv.FormatInt(entry.Timestamp.UnixNano(), 10)})
+			}
+		}
+
+		for _, sib := range []struct{ prefix, kind string }{{rawPrefix, IssueOrphanRaw}, {metaPrefix, IssueOrphanAnnotation}} {
+			p := []byte(sib.prefix)
+			for it.Seek(p); it.ValidForPrefix(p); it.Next() {
+				if id := string(it.Item().Key()[len(p):]); !ids[id] {
+					res.Issues = append(res.Issues, VerifyIssue{Key: string(it.Item().Key()), Kind: sib.kind})
+				}
+			}
+		}
+
+		for _, idx := range secondaryIndexes {
+			p := []byte(idx.prefix)
+			for it.Seek(p); it.ValidForPrefix(p); it.Next() {
+				ts, id, ok := indexKeyParts(it.Item().Key())
+				if ok && ids[id] {
+					if _, err := txn.Get(logKey(ts, id)); err == nil {
+						continue
+					} else if !errors.Is(err, badger.ErrKeyNotFound) {
+						return err
+					}
+				}
+				res.Issues = append(res.Issues, VerifyIssue{Key: string(it.Item().Key()), Kind: IssueOrphanIndex})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return res, fmt.Errorf("verify: %w", err)
+	}
+
+	if quarantine && len(res.Issues) > 0 {
+		n, err := s.quarantine(res.Issues)
+		res.Quarantined = n
+		if err != nil {
+			return res, fmt.Errorf("quarantine: %w", err)
+		}
+	}
+	return res, nil
+}
+
+// isOrphanKind reports whether kind flags a sibling record rather than a log
+// entry; such records are quarantined on their own.
+func isOrphanKind(kind string) bool {
+	return kind == IssueOrphanRaw || kind == IssueOrphanAnnotation || kind == IssueOrphanIndex
+}
+
+// quarantine moves the records behind issues under quarantinePrefix and
+// returns how many issues were moved. Log entries take their raw line and
+// annotation along.
+func (s *BadgerStorage) quarantine(issues []VerifyIssue) (int, error) {
+	moved := 0
+	for start := 0; start < len(issues); start += retentionBatchSize {
+		batch := issues[start:min(start+retentionBatchSize, len(issues))]
+		err := s.db.Update(func(txn *badger.Txn) error {
+			for _, issue := range batch {
+				keys := [][]byte{[]byte(issue.Key)}
+				if id, ok := keyID(keys[0]); ok && !isOrphanKind(issue.Kind) {
+					keys = append(keys, rawKey(id), idKey(id), annotationKey(id))
+				}
+				for _, key := range keys {
+					if err := moveToQuarantine(txn, key); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return moved, err
+		}
+		moved += len(batch)
+	}
+	return moved, nil
+}
+
+// moveToQuarantine copies key's value under quarantinePrefix and deletes the
+// original. Missing keys are ignored.
+func moveToQuarantine(txn *badger.Txn, key []byte) error {
+	item, err := txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return err
+	}
+	if err := txn.Set(append([]byte(quarantinePrefix), key...), val); err != nil {
+		return err
+	}
+	return txn.Delete(key)
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func TestVerify(t *testing.T) {
+	s := newBehaviorStorage(t)
+	ts := time.Now().UTC().Add(-time.Minute)
+
+	if err := s.Store(&LogEntry{ID: "good", Timestamp: ts, Level: "INFO", Message: "ok", Raw: "ok"}); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if err := s.SetAnnotation(&Annotation{ID: "good", Pinned: true}); err != nil {
+		t.Fatalf("SetAnnotation() error = %v", err)
+	}
+
+	moved := &LogEntry{ID: "moved", Timestamp: ts.Add(time.Second), Message: "x"}
+	movedJSON, _ := moved.ToJSON()
+	raw := map[string]string{
+		string(logKey(ts.UnixNano(), "corrupt")): "{not json",
+		string(logKey(ts.UnixNano(), "moved")):   string(movedJSON),
+		string(logKey(ts.UnixNano(), "other")):   `{"id":"someone-else"}`,
+		"log:garbage":                            "{}",
+		"raw:corrupt":                            "kept with its entry",
+		"raw:ghost":                              "line without entry",
+		"meta:ghost":                             `{"pinned":true}`,
+	}
+	err := s.db.Update(func(txn *badger.Txn) error {
+		for k, v := range raw {
+			if err := txn.Set([]byte(k), []byte(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("seed error = %v", err)
+	}
+
+	res, err := s.Verify(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if res.Checked != 5 {
+		t.Fatalf("Verify().Checked = %d, want 5", res.Checked)
+	}
+	var kinds []string
+	for _, issue := range res.Issues {
+		kinds = append(kinds, issue.Kind)
+	}
+	sort.Strings(kinds)
+	want := []string{IssueBadKey, IssueCorrupt, IssueIDMismatch, IssueOrphanAnnotation, IssueOrphanRaw, IssueTimestampMismatch}
+	if fmt.Sprint(kinds) != fmt.Sprint(want) {
+		t.Fatalf("Verify() issue kinds = %v, want %v", kinds, want)
+	}
+
+	res, err = s.Verify(context.Background(), true)
+	if err != nil {
+		t.Fatalf("Verify(quarantine) error = %v", err)
+	}
+	if res.Quarantined != 6 {
+		t.Fatalf("Verify(quarantine).Quarantined = %d, want 6", res.Quarantined)
+	}
+
+	res, err = s.Verify(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Verify() after quarantine error = %v", err)
+	}
+	if res.Checked != 1 || len(res.Issues) != 0 {
+		t.Fatalf("Verify() after quarantine = %+v, want 1 clean entry", res)
+	}
+	if _, err := s.GetAnnotation("good"); err != nil {
+		t.Fatalf("GetAnnotation(good) error = %v, want annotation kept", err)
+	}
+
+	// Quarantined records are kept, with siblings moved alongside their entry.
+	err = s.db.View(func(txn *badger.Txn) error {
+		for _, k := range []string{string(logKey(ts.UnixNano(), "corrupt")), "raw:corrupt", "raw:ghost", "meta:ghost"} {
+			if _, err := txn.Get([]byte(quarantinePrefix + k)); err != nil {
+				return fmt.Errorf("%s: %w", k, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("quarantined key missing: %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SaveView creates or replaces the view with v.Name.
+func (s *BadgerStorage) SaveView(v *View) error {
+	if strings.TrimSpace(v.Name) == "" {
+		return fmt.Errorf("save view: name is required")
+	}
+	v.UpdatedAt = time.Now().UTC()
+
+	if err := s.putRecord(viewKey(v.Name), v); err != nil {
+		return fmt.Errorf("save view %s: %w", v.Name, err)
+	}
+	return nil
+}
+
+// GetView returns the view with the given name or ErrNotFound.
+func (s *BadgerStorage) GetView(name string) (*View, error) {
+	var v View
+	if err := s.getRecord(viewKey(name), &v); err != nil {
+		return nil, fmt.Errorf("get view %s: %w", name, err)
+	}
+	return &v, nil
+}
+
+// ListViews returns all saved views sorted by name.
+func (s *BadgerStorage) ListViews() ([]View, error) {
+	views, err := listRecords[View](s, viewPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("list views: %w", err)
+	}
+	return views, nil
+}
+
+// DeleteView removes the view with the given name or returns ErrNotFound.
+func (s *BadgerStorage) DeleteView(name string) error {
+	if err := s.deleteRecord(viewKey(name)); err != nil {
+		return fmt.Errorf("delete view %s: %w", name, err)
+	}
+	return nil
+}
+
+// viewKey returns the key holding the view with the given name.
+func viewKey(name string) []byte {
+	return []byte(viewPrefix + name)
+}
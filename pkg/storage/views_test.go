@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestViewsCRUD(t *testing.T) {
+	s := newBehaviorStorage(t)
+	addEntry(t, s, "e1", time.Now().UTC(), "ERROR", nil)
+
+	if err := s.SaveView(&View{Name: " "}); err == nil {
+		t.Fatalf("SaveView() with empty name error = nil")
+	}
+
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, v := range []*View{
+		{Name: "b", Query: "level:ERROR", Columns: []string{"service"}, TimePreset: "custom", Start: &start},
+		{Name: "a", Query: "*", TimePreset: "1h"},
+	} {
+		if err := s.SaveView(v); err != nil {
+			t.Fatalf("SaveView(%s) error = %v", v.Name, err)
+		}
+	}
+
+	got, err := s.GetView("b")
+	if err != nil {
+		t.Fatalf("GetView() error = %v", err)
+	}
+	if got.Query != "level:ERROR" || len(got.Columns) != 1 || got.Start == nil || !got.Start.Equal(start) || got.UpdatedAt.IsZero() {
+		t.Fatalf("GetView() = %+v", got)
+	}
+
+	views, err := s.ListViews()
+	if err != nil {
+		t.Fatalf("ListViews() error = %v", err)
+	}
+	if len(views) != 2 || views[0].Name != "a" || views[1].Name != "b" {
+		t.Fatalf("ListViews() = %+v, want a, b", views)
+	}
+
+	// Views live outside the log keyspace.
+	if stats, err := s.GetStats(); err != nil || stats.TotalLogs != 1 {
+		t.Fatalf("GetStats() = %+v, %v; want 1 log", stats, err)
+	}
+	if _, err := s.DeleteAll(); err != nil {
+		t.Fatalf("DeleteAll() error = %v", err)
+	}
+
+	if err := s.DeleteView("a"); err != nil {
+		t.Fatalf("DeleteView() error = %v", err)
+	}
+	if err := s.DeleteView("a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("DeleteView() missing error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetView("a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetView() missing error = %v, want ErrNotFound", err)
+	}
+	if views, _ := s.ListViews(); len(views) != 1 || views[0].Name != "b" {
+		t.Fatalf("ListViews() after delete = %+v, want b", views)
+	}
+}
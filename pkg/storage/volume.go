@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// maxVolumeGroups bounds how many sources and sessions Stats lists.
+const maxVolumeGroups = 50
+
+// VolumeStats is the stored volume of one source or session.
+type VolumeStats struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+	// Bytes is the stored size of the entries, raw lines included.
+	Bytes int64 `json:"bytes"`
+}
+
+// entryOrigin is the part of a stored entry the volume breakdown reads,
+// decoded without building the fields map.
+type entryOrigin struct {
+	ID      string `json:"id"`
+	Session string `json:"session"`
+	Source  string `json:"source"`
+	Fields  struct {
+		Source interface{} `json:"source"`
+	} `json:"fields"`
+}
+
+// sourceName mirrors LogEntry.SourceName.
+func (o *entryOrigin) sourceName() string {
+	if o.Source == "" && o.Fields.Source != nil {
+		return fmt.Sprintf("%v", o.Fields.Source)
+	}
+	return o.Source
+}
+
+// volumeCounter accumulates VolumeStats by name.
+type volumeCounter map[string]*VolumeStats
+
+func (c volumeCounter) add(name string, bytes int64) {
+	v, ok := c[name]
+	if !ok {
+		v = &VolumeStats{Name: name}
+		c[name] = v
+	}
+	v.Count++
+	v.Bytes += bytes
+}
+
+// top returns the maxVolumeGroups largest groups by bytes, then count and
+// name.
+func (c volumeCounter) top() []VolumeStats {
+	groups := make([]VolumeStats, 0, len(c))
+	for _, v := range c {
+		groups = append(groups, *v)
+	}
+	slices.SortFunc(groups, func(a, b VolumeStats) int {
+		return cmp.Or(cmp.Compare(b.Bytes, a.Bytes), cmp.Compare(b.Count, a.Count), cmp.Compare(a.Name, b.Name))
+	})
+	if len(groups) > maxVolumeGroups {
+		groups = groups[:maxVolumeGroups]
+	}
+	return groups
+}
+
+// decodeOrigin reads the origin of a stored entry value.
+func decodeOrigin(val []byte) (entryOrigin, error) {
+	var o entryOrigin
+	err := json.Unmarshal(val, &o)
+	return o, err
+}